@@ -37,6 +37,7 @@ export interface ModelPricing {
   completion_price_per_1m: number;
   cache_read_price_per_1m: number;
   cache_write_price_per_1m: number;
+  price_per_second?: number;
   source?: string;
   source_provider?: string;
   source_model?: string;
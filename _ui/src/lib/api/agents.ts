@@ -27,9 +27,13 @@ export interface AgentConfig {
   mcp_urls: string[];
   /** Workflow NAMES exposed to the agent as callable wf_<name> tools. */
   workflows?: string[];
+  /** Guardrail policy IDs applied to every LLM call the agent makes. */
+  guardrail_ids?: string[];
   builtin_tools: string[];
   max_iterations: number;
   tool_timeout: number;
+  /** Opt out of automatic prompt caching (Anthropic breakpoints, Gemini cachedContents). */
+  disable_prompt_cache?: boolean;
   confirmation_required_tools?: string[];
   avatar_seed?: string;
   /** provider name → connection ID (agent-level default binding) */
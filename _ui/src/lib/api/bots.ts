@@ -33,6 +33,7 @@ export interface BotConfig {
   container_memory?: string;
   speech_to_text?: string;
   whisper_model?: string;
+  guardrail_ids?: string[];
   created_at: string;
   updated_at: string;
   created_by: string;
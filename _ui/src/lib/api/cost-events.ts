@@ -13,6 +13,7 @@ export interface CostEvent {
   billing_code: string;
   run_id: string;
   provider: string;
+  api_key?: string;
   model: string;
   input_tokens: number;
   output_tokens: number;
@@ -0,0 +1,75 @@
+import axios from 'axios';
+import type { ListResult, ListParams } from './types';
+
+const api = axios.create({
+  baseURL: 'api/v1',
+});
+
+// ─── Types ───
+
+export interface GatewayHook {
+  id: string;
+  name: string;
+  description: string;
+  enabled: boolean;
+  script: string;
+  timeout_ms: number; // 0 = server default
+  on_error: string; // "skip" (default) | "fail"
+  created_at: string;
+  updated_at: string;
+  created_by: string;
+  updated_by: string;
+}
+
+export interface GatewayHookTestRequest {
+  hook_id?: string;
+  script?: string;
+  timeout_ms?: number;
+  request: Record<string, any>;
+  response?: Record<string, any>;
+}
+
+export interface GatewayHookTestResult {
+  request: Record<string, any>;
+  request_ran: boolean;
+  request_error?: string;
+  request_ms: number;
+  response?: Record<string, any>;
+  response_ran: boolean;
+  response_error?: string;
+  response_ms: number;
+  validation_error?: string;
+}
+
+// ─── CRUD ───
+
+export async function listGatewayHooks(params?: ListParams): Promise<ListResult<GatewayHook>> {
+  const res = await api.get<ListResult<GatewayHook>>('/gateway-hooks', { params });
+  return res.data;
+}
+
+export async function getGatewayHook(id: string): Promise<GatewayHook> {
+  const res = await api.get<GatewayHook>(`/gateway-hooks/${id}`);
+  return res.data;
+}
+
+export async function createGatewayHook(data: Partial<GatewayHook>): Promise<GatewayHook> {
+  const res = await api.post<GatewayHook>('/gateway-hooks', data);
+  return res.data;
+}
+
+export async function updateGatewayHook(id: string, data: Partial<GatewayHook>): Promise<GatewayHook> {
+  const res = await api.put<GatewayHook>(`/gateway-hooks/${id}`, data);
+  return res.data;
+}
+
+export async function deleteGatewayHook(id: string): Promise<void> {
+  await api.delete(`/gateway-hooks/${id}`);
+}
+
+// ─── Dry run ───
+
+export async function testGatewayHook(data: GatewayHookTestRequest): Promise<GatewayHookTestResult> {
+  const res = await api.post<GatewayHookTestResult>('/gateway-hooks/test', data);
+  return res.data;
+}
@@ -0,0 +1,81 @@
+import axios from 'axios';
+import type { ListResult, ListParams } from './types';
+
+const api = axios.create({
+  baseURL: 'api/v1',
+});
+
+// ─── Types ───
+
+export interface GuardrailPIIRule {
+  entity: string;
+  action: string;
+  replacement?: string;
+}
+
+export interface GuardrailPattern {
+  name: string;
+  pattern: string;
+  action: string;
+  replacement?: string;
+}
+
+export interface GuardrailModeration {
+  model: string;
+  action?: string;
+  categories?: string[];
+}
+
+export interface GuardrailConfig {
+  input: {
+    pii?: GuardrailPIIRule[];
+    patterns?: GuardrailPattern[];
+    deny_list?: string[];
+    moderation?: GuardrailModeration;
+    max_tokens?: number;
+    max_chars?: number;
+  };
+  output: {
+    moderation?: GuardrailModeration;
+    require_json?: boolean;
+    json_action?: string;
+  };
+}
+
+export interface GuardrailPolicy {
+  id: string;
+  name: string;
+  description: string;
+  enabled: boolean;
+  config: GuardrailConfig;
+  created_at: string;
+  updated_at: string;
+  created_by: string;
+  updated_by: string;
+}
+
+// ─── CRUD ───
+
+export async function listGuardrails(params?: ListParams): Promise<ListResult<GuardrailPolicy>> {
+  const res = await api.get<ListResult<GuardrailPolicy>>('/guardrails', { params });
+  return res.data;
+}
+
+export async function getGuardrail(id: string): Promise<GuardrailPolicy> {
+  const res = await api.get<GuardrailPolicy>(`/guardrails/${id}`);
+  return res.data;
+}
+
+export async function createGuardrail(data: Partial<GuardrailPolicy>): Promise<GuardrailPolicy> {
+  const res = await api.post<GuardrailPolicy>('/guardrails', data);
+  return res.data;
+}
+
+export async function updateGuardrail(id: string, data: Partial<GuardrailPolicy>): Promise<GuardrailPolicy> {
+  const res = await api.put<GuardrailPolicy>(`/guardrails/${id}`, data);
+  return res.data;
+}
+
+export async function deleteGuardrail(id: string): Promise<void> {
+  await api.delete(`/guardrails/${id}`);
+}
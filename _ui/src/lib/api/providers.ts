@@ -19,9 +19,27 @@ export interface RateLimitConfig {
   retry_after_cap_ms?: number;
 }
 
+export interface ToolEmulationConfig {
+  // Models to emulate tool calling for; empty = every model of the provider.
+  models?: string[];
+  // Tool-call block format requested in the prompt: "json" (default) or "xml".
+  format?: string;
+}
+
+export interface APIKeyConfig {
+  // Label used in usage stats and metrics; defaults to "key-N".
+  name?: string;
+  // Redacted as "***" by the server; send "" to keep the stored key.
+  key: string;
+  // Per-key limits; keys without one use the provider's rate_limit.
+  rate_limit?: RateLimitConfig;
+}
+
 export interface LLMConfig {
   type: string;
   api_key?: string;
+  // Pool of keys used in rotation (static api-key auth only).
+  api_keys?: APIKeyConfig[];
   base_url?: string;
   model: string;
   models?: string[];
@@ -36,6 +54,11 @@ export interface LLMConfig {
   proxy?: string;
   insecure_skip_verify?: boolean;
   rate_limit?: RateLimitConfig;
+  // Prompt-based tool calling for models without native function calling
+  // (openai and azure types).
+  tool_emulation?: ToolEmulationConfig;
+  // Gateway hook IDs run on chat completion requests routed to this provider.
+  hook_ids?: string[];
 }
 
 export interface ProviderRecord {
@@ -88,6 +111,107 @@ export async function discoverEmbeddingModels(config: Partial<LLMConfig>, key?:
   return res.data.models;
 }
 
+// ─── API Key Pool Usage ───
+
+export interface KeyUsage {
+  name: string;
+  requests: number;
+  errors: number;
+  rate_limited: number;
+  prompt_tokens: number;
+  completion_tokens: number;
+  cooldown_until?: string;
+}
+
+export async function getProviderKeyUsage(key: string): Promise<KeyUsage[]> {
+  const res = await api.get<{ keys: KeyUsage[] }>(`/providers/${key}/key-usage`);
+  return res.data.keys;
+}
+
+// ─── Model Management (providers that host their own models, e.g. Ollama) ───
+
+export interface ModelPullEvent {
+  status?: string;
+  digest?: string;
+  total?: number;
+  completed?: number;
+  error?: string;
+}
+
+export async function listProviderModels(key: string): Promise<string[]> {
+  const res = await api.get<DiscoverModelsResponse>(`/providers/${key}/models`);
+  return res.data.models;
+}
+
+export async function deleteProviderModel(key: string, model: string): Promise<void> {
+  await api.delete(`/providers/${key}/models`, { params: { model } });
+}
+
+// pullProviderModel streams pull progress via SSE. The last event has
+// status "success" or an error.
+export function pullProviderModel(
+  key: string,
+  model: string,
+  onEvent: (event: ModelPullEvent) => void,
+  onDone?: () => void,
+): AbortController {
+  const controller = new AbortController();
+
+  fetch(`api/v1/providers/${encodeURIComponent(key)}/models/pull`, {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify({ model }),
+    signal: controller.signal,
+  })
+    .then(async (response) => {
+      if (!response.ok) {
+        const text = await response.text();
+        onEvent({ error: text || `HTTP ${response.status}` });
+        onDone?.();
+        return;
+      }
+
+      const reader = response.body?.getReader();
+      if (!reader) {
+        onEvent({ error: 'No response body' });
+        onDone?.();
+        return;
+      }
+
+      const decoder = new TextDecoder();
+      let buffer = '';
+
+      while (true) {
+        const { done, value } = await reader.read();
+        if (done) break;
+
+        buffer += decoder.decode(value, { stream: true });
+        const lines = buffer.split('\n');
+        buffer = lines.pop() || '';
+
+        for (const line of lines) {
+          const trimmed = line.trim();
+          if (!trimmed.startsWith('data: ')) continue;
+          try {
+            onEvent(JSON.parse(trimmed.slice(6)) as ModelPullEvent);
+          } catch {
+            // Skip malformed JSON
+          }
+        }
+      }
+
+      onDone?.();
+    })
+    .catch((err) => {
+      if (err.name !== 'AbortError') {
+        onEvent({ error: err.message || 'Pull failed' });
+      }
+      onDone?.();
+    });
+
+  return controller;
+}
+
 // ─── Device Auth (subscription-backed provider device flows) ───
 
 export interface DeviceAuthResponse {
@@ -22,6 +22,11 @@ export interface APIToken {
   spend_limit_cents: number | null;
   limit_reset_interval: string | null;
   last_reset_at: string | null;
+  cache_enabled: boolean;
+  cache_ttl: string | null;
+  semantic_cache_threshold: number | null;
+  guardrail_ids: string[] | null;
+  hook_ids: string[] | null;
   created_at: string;
   last_used_at: string | null;
   created_by: string;
@@ -53,6 +58,11 @@ export interface CreateTokenRequest {
   total_token_limit?: number;
   spend_limit_cents?: number;
   limit_reset_interval?: string; // "daily", "weekly", "monthly"
+  cache_enabled?: boolean;
+  cache_ttl?: string; // duration string, empty/omitted = server default
+  semantic_cache_threshold?: number; // cosine similarity (0-1], omitted = exact matches only
+  guardrail_ids?: string[]; // guardrail policy IDs applied to chat calls
+  hook_ids?: string[]; // gateway hook IDs run on chat completion requests
 }
 
 export interface UpdateTokenRequest {
@@ -69,6 +79,11 @@ export interface UpdateTokenRequest {
   total_token_limit?: number;
   spend_limit_cents?: number;
   limit_reset_interval?: string; // "daily", "weekly", "monthly"
+  cache_enabled?: boolean;
+  cache_ttl?: string; // duration string, empty/omitted = server default
+  semantic_cache_threshold?: number; // cosine similarity (0-1], omitted = exact matches only
+  guardrail_ids?: string[]; // guardrail policy IDs applied to chat calls
+  hook_ids?: string[]; // gateway hook IDs run on chat completion requests
 }
 
 export interface CreateTokenResponse {
@@ -103,3 +118,12 @@ export async function getTokenUsage(id: string): Promise<TokenUsage[]> {
 export async function resetTokenUsage(id: string): Promise<void> {
   await api.post(`/api-tokens/${id}/usage/reset`);
 }
+
+// purgeResponseCache drops cached gateway responses for one token, or the
+// whole cache when tokenId is omitted. Returns the number of entries removed.
+export async function purgeResponseCache(tokenId?: string): Promise<number> {
+  const res = await api.delete<{ deleted: number }>('/response-cache', {
+    params: tokenId ? { token_id: tokenId } : undefined,
+  });
+  return res.data.deleted;
+}
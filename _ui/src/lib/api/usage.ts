@@ -22,6 +22,8 @@ export interface UsageSummary {
   output_tokens: number;
   cache_read_tokens: number;
   cache_write_tokens: number;
+  /** Share of input tokens served from the prompt cache (0..1). */
+  cache_hit_rate: number;
   total_tokens: number;
   request_count: number;
   error_count: number;
@@ -39,6 +41,8 @@ export interface UsageTimeSeriesPoint {
   output_tokens: number;
   cache_read_tokens: number;
   cache_write_tokens: number;
+  /** Share of input tokens served from the prompt cache (0..1). */
+  cache_hit_rate: number;
   total_tokens: number;
   request_count: number;
   error_count: number;
@@ -56,7 +60,7 @@ export interface BudgetUtilization extends BudgetSchedule {
   usage_percent: number;
 }
 
-export type GroupBy = 'provider' | 'model' | 'agent' | 'org' | 'project' | 'goal' | 'billing_code' | 'status';
+export type GroupBy = 'provider' | 'model' | 'agent' | 'org' | 'project' | 'goal' | 'billing_code' | 'status' | 'api_key';
 export type Bucket = 'hour' | 'day';
 
 // Convert a UsageFilter into the flattened query-string object axios expects.
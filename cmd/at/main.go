@@ -10,6 +10,9 @@ import (
 
 	"github.com/rakunlabs/into"
 	"github.com/rakunlabs/logi"
+	"github.com/rakunlabs/tell"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
 
@@ -20,10 +23,14 @@ import (
 	"github.com/rakunlabs/at/internal/service/llm/antropic"
 	"github.com/rakunlabs/at/internal/service/llm/bedrock"
 	"github.com/rakunlabs/at/internal/service/llm/cohere"
+	"github.com/rakunlabs/at/internal/service/llm/common"
 	"github.com/rakunlabs/at/internal/service/llm/gemini"
+	"github.com/rakunlabs/at/internal/service/llm/keypool"
 	"github.com/rakunlabs/at/internal/service/llm/minimax"
+	"github.com/rakunlabs/at/internal/service/llm/ollama"
 	"github.com/rakunlabs/at/internal/service/llm/openai"
 	"github.com/rakunlabs/at/internal/service/llm/vertex"
+	"github.com/rakunlabs/at/internal/service/metrics"
 	"github.com/rakunlabs/at/internal/service/ratelimit"
 	"github.com/rakunlabs/at/internal/store"
 )
@@ -63,9 +70,19 @@ func (g *googleADCSource) Token() (string, error) {
 	return tok.AccessToken, nil
 }
 
+// buildToolEmulation converts the provider's tool_emulation config, or
+// returns nil when emulation is off.
+func buildToolEmulation(te *config.ToolEmulationConfig) *common.ToolEmulation {
+	if te == nil {
+		return nil
+	}
+	return &common.ToolEmulation{Models: te.Models, Format: te.Format}
+}
+
 // buildLimiter constructs a rate limiter from the provider config, or
-// returns nil when no limits are configured.
-func buildLimiter(rl *config.RateLimitConfig) *ratelimit.Limiter {
+// returns nil when no limits are configured. key labels the limiter's
+// metrics.
+func buildLimiter(key string, rl *config.RateLimitConfig) *ratelimit.Limiter {
 	if rl.IsZero() {
 		return nil
 	}
@@ -74,9 +91,19 @@ func buildLimiter(rl *config.RateLimitConfig) *ratelimit.Limiter {
 		InputTokensPerMin: rl.InputTokensPerMinute,
 		MaxConcurrent:     rl.MaxConcurrent,
 		WaitTimeout:       rl.WaitTimeout(),
+		Name:              key,
+		Observer:          observeRateLimit,
 	})
 }
 
+// observeRateLimit feeds limiter outcomes into the Prometheus metrics.
+func observeRateLimit(name string, waited time.Duration, err *ratelimit.Error) {
+	metrics.RateLimitWait.Observe(waited.Seconds(), name)
+	if err != nil {
+		metrics.RateLimitRejections.Inc(name, err.Reason.String())
+	}
+}
+
 var (
 	name    = "at"
 	version = "v0.0.0"
@@ -95,10 +122,14 @@ func main() {
 
 // ///////////////////////////////////////////////////////////////////
 
-func newProvider(cfg config.LLMConfig) (service.LLMProvider, error) {
+func newProvider(key string, cfg config.LLMConfig) (service.LLMProvider, error) {
+	if len(cfg.APIKeys) > 0 {
+		return newKeyPool(key, cfg)
+	}
+
 	// Build the per-provider rate limiter once. It's safe to share with
 	// any of the provider types; nil means no limiting.
-	limiter := buildLimiter(cfg.RateLimit)
+	limiter := buildLimiter(key, cfg.RateLimit)
 
 	switch cfg.Type {
 	case "anthropic":
@@ -242,6 +273,9 @@ func newProvider(cfg config.LLMConfig) (service.LLMProvider, error) {
 		if limiter != nil {
 			opts = append(opts, openai.WithRateLimiter(limiter))
 		}
+		if te := buildToolEmulation(cfg.ToolEmulation); te != nil {
+			opts = append(opts, openai.WithToolEmulation(te))
+		}
 
 		return openai.New(cfg.APIKey, cfg.Model, cfg.BaseURL, cfg.Proxy, cfg.InsecureSkipVerify, headers, opts...)
 	case "vertex":
@@ -258,6 +292,11 @@ func newProvider(cfg config.LLMConfig) (service.LLMProvider, error) {
 		if limiter != nil {
 			opts = append(opts, gemini.WithRateLimiter(limiter))
 		}
+		// Same switch as anthropic: "off" disables the automatic
+		// cachedContents for long system instructions and tool catalogs.
+		if v, ok := cfg.ExtraHeaders["at-prompt-caching"]; ok && strings.EqualFold(v, "off") {
+			opts = append(opts, gemini.WithContextCachingDisabled(true))
+		}
 		return gemini.New(cfg.APIKey, cfg.Model, cfg.BaseURL, cfg.Proxy, cfg.InsecureSkipVerify, opts...)
 	case "minimax":
 		if cfg.APIKey == "" {
@@ -295,6 +334,9 @@ func newProvider(cfg config.LLMConfig) (service.LLMProvider, error) {
 		if limiter != nil {
 			azOpts = append(azOpts, openai.WithRateLimiter(limiter))
 		}
+		if te := buildToolEmulation(cfg.ToolEmulation); te != nil {
+			azOpts = append(azOpts, openai.WithToolEmulation(te))
+		}
 		// Pass apiKey="" so the OpenAI adapter doesn't also set
 		// `Authorization: Bearer <key>`, which Azure rejects.
 		return openai.New("", cfg.Model, cfg.BaseURL, cfg.Proxy, cfg.InsecureSkipVerify, headers, azOpts...)
@@ -333,6 +375,37 @@ func newProvider(cfg config.LLMConfig) (service.LLMProvider, error) {
 			gopts = append(gopts, gemini.WithRateLimiter(limiter))
 		}
 		return gemini.New("", cfg.Model, cfg.BaseURL, cfg.Proxy, cfg.InsecureSkipVerify, gopts...)
+	case "vertex-anthropic":
+		// Claude on Vertex AI via the rawPredict/streamRawPredict
+		// publisher endpoints. Reuses the anthropic provider (message
+		// translation, thinking, prompt caching) with Google ADC auth.
+		// base_url defaults to the regional aiplatform host.
+		project := cfg.ExtraHeaders["vertex_project"]
+		region := cfg.ExtraHeaders["vertex_region"]
+		if project == "" {
+			return nil, fmt.Errorf("vertex-anthropic provider requires extra_headers.vertex_project")
+		}
+		if region == "" {
+			region = "us-east5"
+		}
+		baseURL := cfg.BaseURL
+		if baseURL == "" {
+			baseURL = antropic.VertexBaseURL(region)
+		}
+
+		ts, err := googleADCTokenSource(context.Background())
+		if err != nil {
+			return nil, fmt.Errorf("vertex-anthropic ADC: %w", err)
+		}
+
+		aopts := []antropic.Option{antropic.WithVertex(project, region, ts)}
+		if limiter != nil {
+			aopts = append(aopts, antropic.WithRateLimiter(limiter))
+		}
+		if v, ok := cfg.ExtraHeaders["at-prompt-caching"]; ok && strings.EqualFold(v, "off") {
+			aopts = append(aopts, antropic.WithPromptCachingDisabled(true))
+		}
+		return antropic.New("", cfg.Model, baseURL, cfg.Proxy, cfg.InsecureSkipVerify, aopts...)
 	case "cohere":
 		if cfg.APIKey == "" {
 			return nil, fmt.Errorf("cohere provider requires an api_key (get one from https://dashboard.cohere.com)")
@@ -342,17 +415,94 @@ func newProvider(cfg config.LLMConfig) (service.LLMProvider, error) {
 			copts = append(copts, cohere.WithRateLimiter(limiter))
 		}
 		return cohere.New(cfg.APIKey, cfg.Model, cfg.BaseURL, cfg.Proxy, cfg.InsecureSkipVerify, copts...)
+	case "ollama":
+		var oopts []ollama.Option
+		if limiter != nil {
+			oopts = append(oopts, ollama.WithRateLimiter(limiter))
+		}
+		if keepAlive := cfg.ExtraHeaders["keep_alive"]; keepAlive != "" {
+			oopts = append(oopts, ollama.WithKeepAlive(keepAlive))
+		}
+		return ollama.New(cfg.APIKey, cfg.Model, cfg.BaseURL, cfg.Proxy, cfg.InsecureSkipVerify, oopts...)
 	default:
 		return nil, fmt.Errorf("unknown provider type: %q (supported: %s)", cfg.Type, strings.Join(service.SupportedProviderTypes, ", "))
 	}
 }
 
+// newKeyPool builds one provider per configured API key and rotates
+// calls over them. api_key, when also set, joins the pool first as
+// "primary"; a key's own rate_limit replaces the provider's.
+func newKeyPool(key string, cfg config.LLMConfig) (service.LLMProvider, error) {
+	if cfg.AuthType != "" {
+		return nil, fmt.Errorf("api_keys cannot be combined with auth_type %q", cfg.AuthType)
+	}
+	switch cfg.Type {
+	case "vertex", "vertex-gemini", "vertex-anthropic":
+		return nil, fmt.Errorf("%s provider authenticates with ADC and does not use api_keys", cfg.Type)
+	}
+
+	keys := make([]config.APIKeyConfig, 0, len(cfg.APIKeys)+1)
+	if cfg.APIKey != "" {
+		keys = append(keys, config.APIKeyConfig{Name: "primary", Key: cfg.APIKey})
+	}
+	for i, k := range cfg.APIKeys {
+		if k.Name == "" {
+			k.Name = fmt.Sprintf("key-%d", i+1)
+		}
+		keys = append(keys, k)
+	}
+
+	members := make([]keypool.Member, 0, len(keys))
+	for _, k := range keys {
+		if k.Key == "" {
+			return nil, fmt.Errorf("api key %q is empty", k.Name)
+		}
+		memberCfg := cfg
+		memberCfg.APIKey = k.Key
+		memberCfg.APIKeys = nil
+		if k.RateLimit != nil {
+			memberCfg.RateLimit = k.RateLimit
+		}
+		p, err := newProvider(key+"/"+k.Name, memberCfg)
+		if err != nil {
+			return nil, fmt.Errorf("api key %q: %w", k.Name, err)
+		}
+		members = append(members, keypool.Member{Name: k.Name, Provider: p})
+	}
+
+	return keypool.New(key, members, keypool.WithObserver(func(name, outcome string, usage *service.Usage) {
+		metrics.ProviderKeyRequests.Inc(key, name, outcome)
+		if usage != nil {
+			metrics.ProviderKeyTokens.Add(float64(usage.PromptTokens), key, name, "input")
+			metrics.ProviderKeyTokens.Add(float64(usage.CompletionTokens), key, name, "output")
+		}
+	}))
+}
+
 func run(ctx context.Context) error {
 	cfg, err := config.Load(ctx, name)
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
 
+	// OpenTelemetry: export traces when telemetry.collector (or
+	// OTEL_EXPORTER_OTLP_ENDPOINT) is set. The W3C propagator is installed
+	// either way so an inbound traceparent is honoured and forwarded to
+	// providers and MCP servers even when AT itself exports nothing.
+	collector, err := tell.New(ctx, cfg.Telemetry)
+	if err != nil {
+		return fmt.Errorf("failed to initialize telemetry: %w", err)
+	}
+	defer func() {
+		if err := collector.Shutdown(); err != nil {
+			slog.Warn("failed to shutdown telemetry", "error", err)
+		}
+	}()
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
 	// Initialize store. Postgres is the only supported backend; startup
 	// fails with a descriptive error when store.postgres is not configured.
 	st, err := store.New(ctx, cfg.Store)
@@ -370,7 +520,7 @@ func run(ctx context.Context) error {
 
 	providers := make(map[string]server.ProviderInfo, len(dbRecords.Data))
 	for _, rec := range dbRecords.Data {
-		provider, err := newProvider(rec.Config)
+		provider, err := newProvider(rec.Key, rec.Config)
 		if err != nil {
 			slog.Warn("failed to create DB provider, skipping", "key", rec.Key, "error", err)
 			continue
@@ -1,14 +1,16 @@
 package main
 
 import (
+	"slices"
 	"testing"
 
 	"github.com/rakunlabs/at/internal/config"
+	"github.com/rakunlabs/at/internal/service/llm/keypool"
 	"github.com/rakunlabs/at/internal/service/llm/openai"
 )
 
 func TestNewProviderCreatesUnauthenticatedChatGPTCodexProvider(t *testing.T) {
-	provider, err := newProvider(config.LLMConfig{
+	provider, err := newProvider("chatgpt", config.LLMConfig{
 		Type:     "openai",
 		AuthType: "chatgpt",
 		Model:    "gpt-5.3-codex",
@@ -20,3 +22,43 @@ func TestNewProviderCreatesUnauthenticatedChatGPTCodexProvider(t *testing.T) {
 		t.Fatalf("provider type = %T, want *openai.CodexProvider", provider)
 	}
 }
+
+func TestNewProviderBuildsKeyPool(t *testing.T) {
+	provider, err := newProvider("openai", config.LLMConfig{
+		Type:   "openai",
+		Model:  "gpt-4o",
+		APIKey: "sk-primary",
+		APIKeys: []config.APIKeyConfig{
+			{Name: "team-a", Key: "sk-a", RateLimit: &config.RateLimitConfig{RequestsPerMinute: 10}},
+			{Key: "sk-b"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("newProvider: %v", err)
+	}
+	pool, ok := provider.(*keypool.Pool)
+	if !ok {
+		t.Fatalf("provider type = %T, want *keypool.Pool", provider)
+	}
+	var names []string
+	for _, u := range pool.KeyUsage() {
+		names = append(names, u.Name)
+	}
+	if want := []string{"primary", "team-a", "key-2"}; !slices.Equal(names, want) {
+		t.Errorf("key names = %v, want %v", names, want)
+	}
+
+	if _, err := newProvider("openai", config.LLMConfig{
+		Type:     "openai",
+		AuthType: "chatgpt",
+		APIKeys:  []config.APIKeyConfig{{Key: "sk-a"}},
+	}); err == nil {
+		t.Error("api_keys with auth_type should be rejected")
+	}
+	if _, err := newProvider("openai", config.LLMConfig{
+		Type:    "openai",
+		APIKeys: []config.APIKeyConfig{{Name: "empty"}},
+	}); err == nil {
+		t.Error("an empty pool key should be rejected")
+	}
+}
@@ -108,6 +108,70 @@ type Server struct {
 	// data disk (e.g. `/mnt/disk/at-tasks`) so the video pipeline
 	// doesn't fill the root filesystem.
 	Workspace *Workspace `cfg:"workspace"`
+
+	// ResponseCache tunes the gateway's exact-match response cache.
+	// Caching itself is opted into per API token (`cache_enabled`);
+	// this block only selects where entries live and their defaults.
+	// Omitted = in-memory backend with the defaults below.
+	ResponseCache *ResponseCache `cfg:"response_cache"`
+
+	// StoredResponseTTL is how long Responses API results created with
+	// `store` (the default) are kept for previous_response_id and the
+	// retrieval endpoints. 0 = 30 days.
+	StoredResponseTTL time.Duration `cfg:"stored_response_ttl"`
+
+	// BatchFileTTL is how long Files API uploads and batch output/error
+	// files are kept. 0 = 30 days.
+	BatchFileTTL time.Duration `cfg:"batch_file_ttl"`
+
+	// Metrics exposes Prometheus metrics for the gateway, providers,
+	// agents and workflows. Omitted = disabled.
+	Metrics *Metrics `cfg:"metrics"`
+}
+
+// Metrics configures the Prometheus endpoint and its cardinality controls.
+type Metrics struct {
+	// Enabled turns on metric collection and the endpoint.
+	Enabled bool `cfg:"enabled"`
+
+	// Path is where the endpoint is served, under base_path.
+	// Default "/metrics".
+	Path string `cfg:"path"`
+
+	// Token, if set, requires scrapers to send
+	// "Authorization: Bearer <token>".
+	Token string `cfg:"token" log:"-"`
+
+	// DropLabels removes label dimensions from every metric, e.g.
+	// ["token"] on a deployment with many API tokens or ["model"] when
+	// clients pass arbitrary model names.
+	DropLabels []string `cfg:"drop_labels"`
+
+	// MaxSeries caps the distinct label sets per metric; new label sets
+	// beyond the cap are folded into one series labelled "_other".
+	// 0 = 1000, negative = unlimited.
+	MaxSeries int `cfg:"max_series"`
+}
+
+// ResponseCache configures the gateway response cache backend.
+type ResponseCache struct {
+	// Backend is "memory" (default, per-instance) or "postgres"
+	// (shared across instances via the response_cache table).
+	Backend string `cfg:"backend"`
+
+	// TTL is the default entry lifetime for tokens that enable caching
+	// without setting their own cache_ttl. 0 = 1h.
+	TTL time.Duration `cfg:"ttl"`
+
+	// MaxEntries bounds the in-memory backend; the oldest entries are
+	// evicted first. 0 = 10000. Ignored by the postgres backend.
+	MaxEntries int `cfg:"max_entries"`
+
+	// SemanticModel is the "provider/model" embedding model used by the
+	// semantic cache layer (e.g. "openai/text-embedding-3-small"). The
+	// provider must implement embeddings. Empty disables semantic
+	// matching; tokens opt in with semantic_cache_threshold.
+	SemanticModel string `cfg:"semantic_model"`
 }
 
 // Workspace bundles the bootstrap-time workspace knobs. They are passed
@@ -162,6 +226,11 @@ type LLMConfig struct {
 	// The "openai" type works with any OpenAI-compatible API.
 	// The "vertex" type uses Google Application Default Credentials (ADC).
 	// The "gemini" type uses API key authentication with generativelanguage.googleapis.com.
+	// The "vertex-anthropic" type serves Claude through Vertex AI's rawPredict
+	// endpoints with ADC; project and region come from ExtraHeaders
+	// ("vertex_project", "vertex_region").
+	// The "ollama" type uses Ollama's native API; ExtraHeaders "keep_alive"
+	// sets how long models stay loaded.
 	Type string `cfg:"type" json:"type"`
 
 	// APIKey is the authentication key for the provider.
@@ -169,6 +238,20 @@ type LLMConfig struct {
 	// Required for "gemini" type (get one from https://aistudio.google.com/apikey).
 	APIKey string `cfg:"api_key" json:"api_key" log:"-"`
 
+	// APIKeys holds a pool of credentials used in rotation. Requests are
+	// spread round-robin across the keys; a key that answers with a rate
+	// limit is cooled down for its Retry-After and the request moves on to
+	// the next key. When APIKey is also set it joins the pool first.
+	// Only static api-key auth (empty AuthType) can use a pool.
+	//
+	//   api_keys:
+	//     - name: team-a
+	//       key: sk-...
+	//       rate_limit:
+	//         requests_per_minute: 500
+	//     - key: sk-...
+	APIKeys []APIKeyConfig `cfg:"api_keys" json:"api_keys,omitempty"`
+
 	// BaseURL is the full endpoint URL for the provider's chat completions API.
 	// For "openai" type, defaults to "https://api.openai.com/v1/chat/completions".
 	// For "anthropic" type, defaults to "https://api.anthropic.com".
@@ -256,6 +339,49 @@ type LLMConfig struct {
 	//     wait_timeout_ms: 60000
 	//     retry_after_cap_ms: 60000
 	RateLimit *RateLimitConfig `cfg:"rate_limit" json:"rate_limit,omitempty"`
+
+	// ToolEmulation, if set, enables prompt-based tool calling for models
+	// that don't support native function calling (e.g. self-hosted models
+	// behind an OpenAI-compatible server). Tool schemas are injected into
+	// the system prompt and tool-call blocks in the reply are returned as
+	// regular tool calls, so agents work unchanged. Supported by the
+	// "openai" and "azure" types. Leave nil to use native tool calling.
+	//
+	//   tool_emulation:
+	//     models: ["llama3:8b"]   # empty = every model of this provider
+	//     format: xml             # "json" (default) or "xml"
+	ToolEmulation *ToolEmulationConfig `cfg:"tool_emulation" json:"tool_emulation,omitempty"`
+
+	// HookIDs lists gateway hooks (managed via /api/v1/gateway-hooks) run on
+	// chat completion requests routed to this provider, after the calling
+	// token's own hooks.
+	HookIDs []string `cfg:"hook_ids" json:"hook_ids,omitempty"`
+}
+
+// APIKeyConfig is one credential of a provider's key pool.
+type APIKeyConfig struct {
+	// Name labels the key in usage stats and metrics. Defaults to
+	// "key-N" (1-based position in the pool).
+	Name string `cfg:"name" json:"name,omitempty"`
+
+	// Key is the secret credential, used the way APIKey would be.
+	Key string `cfg:"key" json:"key" log:"-"`
+
+	// RateLimit applies to this key only. Keys without one use the
+	// provider's RateLimit.
+	RateLimit *RateLimitConfig `cfg:"rate_limit" json:"rate_limit,omitempty"`
+}
+
+// ToolEmulationConfig selects the models and block format used for
+// prompt-based tool calling.
+type ToolEmulationConfig struct {
+	// Models limits emulation to these models. Empty means all models.
+	Models []string `cfg:"models" json:"models,omitempty"`
+
+	// Format is the tool-call block the model is asked to write: "json"
+	// (a fenced tool_call block) or "xml" (<tool_call> tags). Defaults to
+	// "json". Both formats are parsed either way.
+	Format string `cfg:"format" json:"format,omitempty"`
 }
 
 // RateLimitConfig describes the per-provider rate-limit policy. All fields
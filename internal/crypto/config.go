@@ -2,12 +2,13 @@ package crypto
 
 import (
 	"fmt"
+	"slices"
 
 	"github.com/rakunlabs/at/internal/config"
 )
 
-// EncryptLLMConfig encrypts the sensitive fields of an LLMConfig (api_key and
-// extra_headers values) in-place and returns the modified config.
+// EncryptLLMConfig encrypts the sensitive fields of an LLMConfig (api_key,
+// api_keys, refresh_token and extra_headers values) in-place and returns the modified config.
 // If key is nil, the config is returned unchanged (no-op).
 func EncryptLLMConfig(cfg config.LLMConfig, key []byte) (config.LLMConfig, error) {
 	if key == nil {
@@ -22,6 +23,22 @@ func EncryptLLMConfig(cfg config.LLMConfig, key []byte) (config.LLMConfig, error
 		cfg.APIKey = enc
 	}
 
+	if len(cfg.APIKeys) > 0 {
+		// Copy so the caller's slice keeps its plaintext keys.
+		keys := slices.Clone(cfg.APIKeys)
+		for i := range keys {
+			if keys[i].Key == "" {
+				continue
+			}
+			enc, err := Encrypt(keys[i].Key, key)
+			if err != nil {
+				return cfg, fmt.Errorf("encrypt api_keys[%d]: %w", i, err)
+			}
+			keys[i].Key = enc
+		}
+		cfg.APIKeys = keys
+	}
+
 	if cfg.RefreshToken != "" {
 		enc, err := Encrypt(cfg.RefreshToken, key)
 		if err != nil {
@@ -45,8 +62,8 @@ func EncryptLLMConfig(cfg config.LLMConfig, key []byte) (config.LLMConfig, error
 	return cfg, nil
 }
 
-// DecryptLLMConfig decrypts the sensitive fields of an LLMConfig (api_key and
-// extra_headers values) in-place and returns the modified config.
+// DecryptLLMConfig decrypts the sensitive fields of an LLMConfig (api_key,
+// api_keys, refresh_token and extra_headers values) in-place and returns the modified config.
 // If key is nil, the config is returned unchanged (no-op).
 // Values that are not encrypted (no "enc:" prefix) are left as-is.
 func DecryptLLMConfig(cfg config.LLMConfig, key []byte) (config.LLMConfig, error) {
@@ -62,6 +79,21 @@ func DecryptLLMConfig(cfg config.LLMConfig, key []byte) (config.LLMConfig, error
 		cfg.APIKey = dec
 	}
 
+	if len(cfg.APIKeys) > 0 {
+		keys := slices.Clone(cfg.APIKeys)
+		for i := range keys {
+			if keys[i].Key == "" {
+				continue
+			}
+			dec, err := Decrypt(keys[i].Key, key)
+			if err != nil {
+				return cfg, fmt.Errorf("decrypt api_keys[%d]: %w", i, err)
+			}
+			keys[i].Key = dec
+		}
+		cfg.APIKeys = keys
+	}
+
 	if cfg.RefreshToken != "" {
 		dec, err := Decrypt(cfg.RefreshToken, key)
 		if err != nil {
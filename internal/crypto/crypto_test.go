@@ -210,6 +210,44 @@ func TestEncryptDecryptLLMConfig(t *testing.T) {
 	}
 }
 
+func TestEncryptDecryptLLMConfigAPIKeys(t *testing.T) {
+	key := testKey()
+
+	original := config.LLMConfig{
+		Type: "openai",
+		APIKeys: []config.APIKeyConfig{
+			{Name: "a", Key: "sk-a"},
+			{Name: "b", Key: "sk-b", RateLimit: &config.RateLimitConfig{RequestsPerMinute: 10}},
+		},
+	}
+
+	encrypted, err := EncryptLLMConfig(original, key)
+	if err != nil {
+		t.Fatalf("EncryptLLMConfig: %v", err)
+	}
+	for i, k := range encrypted.APIKeys {
+		if !IsEncrypted(k.Key) {
+			t.Fatalf("api_keys[%d] should be encrypted, got %q", i, k.Key)
+		}
+	}
+	if original.APIKeys[0].Key != "sk-a" {
+		t.Fatalf("original config was mutated: %q", original.APIKeys[0].Key)
+	}
+	if encrypted.APIKeys[1].Name != "b" || encrypted.APIKeys[1].RateLimit.RequestsPerMinute != 10 {
+		t.Fatalf("non-secret key fields changed: %+v", encrypted.APIKeys[1])
+	}
+
+	decrypted, err := DecryptLLMConfig(encrypted, key)
+	if err != nil {
+		t.Fatalf("DecryptLLMConfig: %v", err)
+	}
+	for i, k := range decrypted.APIKeys {
+		if k.Key != original.APIKeys[i].Key {
+			t.Fatalf("api_keys[%d] round-trip: got %q, want %q", i, k.Key, original.APIKeys[i].Key)
+		}
+	}
+}
+
 func TestEncryptDecryptLLMConfigNilKey(t *testing.T) {
 	original := config.LLMConfig{
 		Type:   "openai",
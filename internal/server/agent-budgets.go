@@ -218,6 +218,7 @@ func (s *Server) SetModelPricingAPI(w http.ResponseWriter, r *http.Request) {
 		httpResponse(w, fmt.Sprintf("failed to set model pricing: %v", err), http.StatusInternalServerError)
 		return
 	}
+	s.invalidateModelPricing()
 
 	httpResponse(w, "pricing updated", http.StatusOK)
 }
@@ -238,6 +239,7 @@ func (s *Server) DeleteModelPricingAPI(w http.ResponseWriter, r *http.Request) {
 		httpResponse(w, fmt.Sprintf("failed to delete model pricing: %v", err), http.StatusInternalServerError)
 		return
 	}
+	s.invalidateModelPricing()
 	httpResponse(w, "pricing deleted", http.StatusOK)
 }
 
@@ -257,5 +259,6 @@ func (s *Server) ResetModelPricingAPI(w http.ResponseWriter, r *http.Request) {
 		httpResponse(w, fmt.Sprintf("failed to reset model pricing: %v", err), http.StatusInternalServerError)
 		return
 	}
+	s.invalidateModelPricing()
 	httpResponse(w, "pricing reset", http.StatusOK)
 }
@@ -0,0 +1,29 @@
+package server
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+)
+
+// PurgeResponseCacheAPI handles DELETE /api/v1/response-cache.
+//
+// Drops cached gateway responses (exact and semantic entries alike). The
+// optional `token_id` query parameter limits the purge to one token;
+// without it the whole cache is cleared.
+func (s *Server) PurgeResponseCacheAPI(w http.ResponseWriter, r *http.Request) {
+	if s.responseCache == nil {
+		httpResponse(w, "response cache not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	tokenID := r.URL.Query().Get("token_id")
+	n, err := s.responseCache.backend.purge(r.Context(), tokenID)
+	if err != nil {
+		slog.Error("purge response cache failed", "token_id", tokenID, "error", err)
+		httpResponse(w, fmt.Sprintf("failed to purge response cache: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	httpResponseJSON(w, map[string]any{"deleted": n}, http.StatusOK)
+}
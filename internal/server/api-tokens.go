@@ -23,39 +23,49 @@ import (
 // createTokenRequest is the JSON body for POST /api/v1/api-tokens.
 type createTokenRequest struct {
 	Name                 string   `json:"name"`
-	AllowedProvidersMode string   `json:"allowed_providers_mode,omitempty"` // "all" (default/""), "none", or "list"
-	AllowedProviders     []string `json:"allowed_providers,omitempty"`      // used when mode = "list"
-	AllowedModelsMode    string   `json:"allowed_models_mode,omitempty"`    // "all" (default/""), "none", or "list"
-	AllowedModels        []string `json:"allowed_models,omitempty"`         // used when mode = "list"
-	AllowedWebhooksMode  string   `json:"allowed_webhooks_mode,omitempty"`  // "all" (default/""), "none", or "list"
-	AllowedWebhooks      []string `json:"allowed_webhooks,omitempty"`       // used when mode = "list"
-	AllowedMCPsMode      string   `json:"allowed_mcps_mode,omitempty"`      // "all" (default/""), "none", or "list"
-	AllowedMCPs          []string `json:"allowed_mcps,omitempty"`           // used when mode = "list" (gateway MCP server names)
-	LegacyRAGMCPsMode    string   `json:"allowed_rag_mcps_mode,omitempty"`  // deprecated alias for allowed_mcps_mode
-	LegacyRAGMCPs        []string `json:"allowed_rag_mcps,omitempty"`       // deprecated alias for allowed_mcps
-	ExpiresAt            *string  `json:"expires_at,omitempty"`             // RFC3339 timestamp, nil/empty = no expiry
-	TotalTokenLimit      *int64   `json:"total_token_limit,omitempty"`      // max total tokens; nil = unlimited
-	SpendLimitCents      *float64 `json:"spend_limit_cents,omitempty"`      // max spend in cents; nil = unlimited
-	LimitResetInterval   *string  `json:"limit_reset_interval,omitempty"`   // duration string (e.g. "24h", "7d", "30d"), or nil = manual
+	AllowedProvidersMode string   `json:"allowed_providers_mode,omitempty"`   // "all" (default/""), "none", or "list"
+	AllowedProviders     []string `json:"allowed_providers,omitempty"`        // used when mode = "list"
+	AllowedModelsMode    string   `json:"allowed_models_mode,omitempty"`      // "all" (default/""), "none", or "list"
+	AllowedModels        []string `json:"allowed_models,omitempty"`           // used when mode = "list"
+	AllowedWebhooksMode  string   `json:"allowed_webhooks_mode,omitempty"`    // "all" (default/""), "none", or "list"
+	AllowedWebhooks      []string `json:"allowed_webhooks,omitempty"`         // used when mode = "list"
+	AllowedMCPsMode      string   `json:"allowed_mcps_mode,omitempty"`        // "all" (default/""), "none", or "list"
+	AllowedMCPs          []string `json:"allowed_mcps,omitempty"`             // used when mode = "list" (gateway MCP server names)
+	LegacyRAGMCPsMode    string   `json:"allowed_rag_mcps_mode,omitempty"`    // deprecated alias for allowed_mcps_mode
+	LegacyRAGMCPs        []string `json:"allowed_rag_mcps,omitempty"`         // deprecated alias for allowed_mcps
+	ExpiresAt            *string  `json:"expires_at,omitempty"`               // RFC3339 timestamp, nil/empty = no expiry
+	TotalTokenLimit      *int64   `json:"total_token_limit,omitempty"`        // max total tokens; nil = unlimited
+	SpendLimitCents      *float64 `json:"spend_limit_cents,omitempty"`        // max spend in cents; nil = unlimited
+	LimitResetInterval   *string  `json:"limit_reset_interval,omitempty"`     // duration string (e.g. "24h", "7d", "30d"), or nil = manual
+	CacheEnabled         bool     `json:"cache_enabled,omitempty"`            // opt-in to the gateway response cache
+	CacheTTL             *string  `json:"cache_ttl,omitempty"`                // duration string (e.g. "1h"); nil = server default
+	SemanticThreshold    *float64 `json:"semantic_cache_threshold,omitempty"` // cosine similarity (0-1]; nil = exact matches only
+	GuardrailIDs         []string `json:"guardrail_ids,omitempty"`            // guardrail policies applied to chat calls
+	HookIDs              []string `json:"hook_ids,omitempty"`                 // gateway hooks run on chat completion requests
 }
 
 // updateTokenRequest is the JSON body for PUT /api/v1/api-tokens/{id}.
 type updateTokenRequest struct {
 	Name                 string   `json:"name"`
-	AllowedProvidersMode string   `json:"allowed_providers_mode,omitempty"` // "all" (default/""), "none", or "list"
-	AllowedProviders     []string `json:"allowed_providers,omitempty"`      // used when mode = "list"
-	AllowedModelsMode    string   `json:"allowed_models_mode,omitempty"`    // "all" (default/""), "none", or "list"
-	AllowedModels        []string `json:"allowed_models,omitempty"`         // used when mode = "list"
-	AllowedWebhooksMode  string   `json:"allowed_webhooks_mode,omitempty"`  // "all" (default/""), "none", or "list"
-	AllowedWebhooks      []string `json:"allowed_webhooks,omitempty"`       // used when mode = "list"
-	AllowedMCPsMode      string   `json:"allowed_mcps_mode,omitempty"`      // "all" (default/""), "none", or "list"
-	AllowedMCPs          []string `json:"allowed_mcps,omitempty"`           // used when mode = "list" (gateway MCP server names)
-	LegacyRAGMCPsMode    string   `json:"allowed_rag_mcps_mode,omitempty"`  // deprecated alias for allowed_mcps_mode
-	LegacyRAGMCPs        []string `json:"allowed_rag_mcps,omitempty"`       // deprecated alias for allowed_mcps
-	ExpiresAt            *string  `json:"expires_at,omitempty"`             // RFC3339 timestamp, nil/empty = no expiry
-	TotalTokenLimit      *int64   `json:"total_token_limit,omitempty"`      // max total tokens; nil = unlimited
-	SpendLimitCents      *float64 `json:"spend_limit_cents,omitempty"`      // max spend in cents; nil = unlimited
-	LimitResetInterval   *string  `json:"limit_reset_interval,omitempty"`   // duration string (e.g. "24h", "7d", "30d"), or nil = manual
+	AllowedProvidersMode string   `json:"allowed_providers_mode,omitempty"`   // "all" (default/""), "none", or "list"
+	AllowedProviders     []string `json:"allowed_providers,omitempty"`        // used when mode = "list"
+	AllowedModelsMode    string   `json:"allowed_models_mode,omitempty"`      // "all" (default/""), "none", or "list"
+	AllowedModels        []string `json:"allowed_models,omitempty"`           // used when mode = "list"
+	AllowedWebhooksMode  string   `json:"allowed_webhooks_mode,omitempty"`    // "all" (default/""), "none", or "list"
+	AllowedWebhooks      []string `json:"allowed_webhooks,omitempty"`         // used when mode = "list"
+	AllowedMCPsMode      string   `json:"allowed_mcps_mode,omitempty"`        // "all" (default/""), "none", or "list"
+	AllowedMCPs          []string `json:"allowed_mcps,omitempty"`             // used when mode = "list" (gateway MCP server names)
+	LegacyRAGMCPsMode    string   `json:"allowed_rag_mcps_mode,omitempty"`    // deprecated alias for allowed_mcps_mode
+	LegacyRAGMCPs        []string `json:"allowed_rag_mcps,omitempty"`         // deprecated alias for allowed_mcps
+	ExpiresAt            *string  `json:"expires_at,omitempty"`               // RFC3339 timestamp, nil/empty = no expiry
+	TotalTokenLimit      *int64   `json:"total_token_limit,omitempty"`        // max total tokens; nil = unlimited
+	SpendLimitCents      *float64 `json:"spend_limit_cents,omitempty"`        // max spend in cents; nil = unlimited
+	LimitResetInterval   *string  `json:"limit_reset_interval,omitempty"`     // duration string (e.g. "24h", "7d", "30d"), or nil = manual
+	CacheEnabled         bool     `json:"cache_enabled,omitempty"`            // opt-in to the gateway response cache
+	CacheTTL             *string  `json:"cache_ttl,omitempty"`                // duration string (e.g. "1h"); nil = server default
+	SemanticThreshold    *float64 `json:"semantic_cache_threshold,omitempty"` // cosine similarity (0-1]; nil = exact matches only
+	GuardrailIDs         []string `json:"guardrail_ids,omitempty"`            // guardrail policies applied to chat calls
+	HookIDs              []string `json:"hook_ids,omitempty"`                 // gateway hooks run on chat completion requests
 }
 
 // createTokenResponse is returned once on creation (the only time the full token is shown).
@@ -156,6 +166,16 @@ func (s *Server) CreateAPITokenAPI(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 	}
+	if req.CacheTTL != nil && *req.CacheTTL != "" {
+		if _, err := str2duration.ParseDuration(*req.CacheTTL); err != nil {
+			httpResponse(w, fmt.Sprintf("invalid cache_ttl %q: %v", *req.CacheTTL, err), http.StatusBadRequest)
+			return
+		}
+	}
+	if req.SemanticThreshold != nil && (*req.SemanticThreshold <= 0 || *req.SemanticThreshold > 1) {
+		httpResponse(w, "semantic_cache_threshold must be in (0, 1]", http.StatusBadRequest)
+		return
+	}
 
 	userEmail := s.getUserEmail(r)
 	token := service.APIToken{
@@ -173,6 +193,11 @@ func (s *Server) CreateAPITokenAPI(w http.ResponseWriter, r *http.Request) {
 		TotalTokenLimit:      toNullInt64(req.TotalTokenLimit),
 		SpendLimitCents:      toNullFloat64(req.SpendLimitCents),
 		LimitResetInterval:   toNullString(req.LimitResetInterval),
+		CacheEnabled:         req.CacheEnabled,
+		CacheTTL:             toNullString(req.CacheTTL),
+		SemanticThreshold:    toNullFloat64(req.SemanticThreshold),
+		GuardrailIDs:         req.GuardrailIDs,
+		HookIDs:              req.HookIDs,
 		CreatedBy:            userEmail,
 		UpdatedBy:            userEmail,
 	}
@@ -261,6 +286,16 @@ func (s *Server) UpdateAPITokenAPI(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 	}
+	if req.CacheTTL != nil && *req.CacheTTL != "" {
+		if _, err := str2duration.ParseDuration(*req.CacheTTL); err != nil {
+			httpResponse(w, fmt.Sprintf("invalid cache_ttl %q: %v", *req.CacheTTL, err), http.StatusBadRequest)
+			return
+		}
+	}
+	if req.SemanticThreshold != nil && (*req.SemanticThreshold <= 0 || *req.SemanticThreshold > 1) {
+		httpResponse(w, "semantic_cache_threshold must be in (0, 1]", http.StatusBadRequest)
+		return
+	}
 
 	userEmail := s.getUserEmail(r)
 	token := service.APIToken{
@@ -277,6 +312,11 @@ func (s *Server) UpdateAPITokenAPI(w http.ResponseWriter, r *http.Request) {
 		TotalTokenLimit:      toNullInt64(req.TotalTokenLimit),
 		SpendLimitCents:      toNullFloat64(req.SpendLimitCents),
 		LimitResetInterval:   toNullString(req.LimitResetInterval),
+		CacheEnabled:         req.CacheEnabled,
+		CacheTTL:             toNullString(req.CacheTTL),
+		SemanticThreshold:    toNullFloat64(req.SemanticThreshold),
+		GuardrailIDs:         req.GuardrailIDs,
+		HookIDs:              req.HookIDs,
 		UpdatedBy:            userEmail,
 	}
 
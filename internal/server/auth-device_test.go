@@ -31,7 +31,7 @@ func TestSaveCodexAuthTokensPersistsCredentialsAndAccount(t *testing.T) {
 	s := &Server{
 		store:     store,
 		providers: make(map[string]ProviderInfo),
-		providerFactory: func(config.LLMConfig) (service.LLMProvider, error) {
+		providerFactory: func(string, config.LLMConfig) (service.LLMProvider, error) {
 			return codexAuthTestProvider{}, nil
 		},
 	}
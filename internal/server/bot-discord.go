@@ -9,6 +9,7 @@ import (
 
 	"github.com/bwmarrin/discordgo"
 	"github.com/rakunlabs/at/internal/config"
+	"github.com/rakunlabs/at/internal/service/metrics"
 )
 
 // discordContext holds per-bot context passed to message handlers.
@@ -81,6 +82,8 @@ func (s *Server) startDiscordBot(ctx context.Context, botID string, cfg *config.
 }
 
 func (s *Server) handleDiscordMessage(ctx context.Context, sess *discordgo.Session, m *discordgo.MessageCreate, agentID string, dcCtx *discordContext) {
+	metrics.BotMessages.Inc("discord", dcCtx.botID)
+
 	sessionID, sessionAgentID, err := s.findOrCreateBotSession(ctx, "discord", dcCtx.botID, m.Author.ID, m.ChannelID, agentID)
 	if err != nil {
 		slog.Error("discord bot: session lookup failed", "error", err)
@@ -21,6 +21,7 @@ import (
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/rakunlabs/at/internal/config"
 	"github.com/rakunlabs/at/internal/service"
+	"github.com/rakunlabs/at/internal/service/metrics"
 	"github.com/rakunlabs/at/internal/service/workflow"
 )
 
@@ -773,6 +774,8 @@ func (s *Server) startTelegramBot(ctx context.Context, botID string, cfg *config
 }
 
 func (s *Server) handleTelegramMessage(ctx context.Context, bot *tgbotapi.BotAPI, msg *tgbotapi.Message, agentID string, tgCtx *telegramContext) {
+	metrics.BotMessages.Inc("telegram", tgCtx.botID)
+
 	chatIDStr := fmt.Sprintf("%d", msg.Chat.ID)
 	userIDStr := fmt.Sprintf("%d", msg.From.ID)
 	lockValue, _ := tgCtx.messageLocks.LoadOrStore(chatIDStr, &sync.Mutex{})
@@ -41,6 +41,9 @@ func (s *Server) execAgentCreate(ctx context.Context, args map[string]any) (stri
 	if v, ok := args["tool_timeout"].(float64); ok {
 		config.ToolTimeout = int(v)
 	}
+	if v, ok := args["disable_prompt_cache"].(bool); ok {
+		config.DisablePromptCache = v
+	}
 
 	// Parse skills array.
 	if raw, ok := args["skills"]; ok {
@@ -219,6 +222,9 @@ func (s *Server) execAgentUpdate(ctx context.Context, args map[string]any) (stri
 	if v, ok := args["tool_timeout"].(float64); ok {
 		existing.Config.ToolTimeout = int(v)
 	}
+	if v, ok := args["disable_prompt_cache"].(bool); ok {
+		existing.Config.DisablePromptCache = v
+	}
 
 	// Replace skills if provided.
 	if raw, ok := args["skills"]; ok {
@@ -235,6 +235,10 @@ func (s *Server) dispatchBuiltinTool(ctx context.Context, name string, args map[
 		return s.execProviderDelete(ctx, args)
 	case "provider_discover_models":
 		return s.execProviderDiscoverModels(ctx, args)
+	case "provider_pull_model":
+		return s.execProviderPullModel(ctx, args)
+	case "provider_delete_model":
+		return s.execProviderDeleteModel(ctx, args)
 
 	// API token tools.
 	case "apitoken_list":
@@ -165,6 +165,9 @@ func (s *Server) execProviderCreate(ctx context.Context, args map[string]any) (s
 	if msg := validateRateLimitConfig(cfg.RateLimit); msg != "" {
 		return "", fmt.Errorf("%s", msg)
 	}
+	if msg := validateAPIKeys(cfg.APIKeys); msg != "" {
+		return "", fmt.Errorf("%s", msg)
+	}
 
 	if existing, _ := s.store.GetProvider(ctx, key); existing != nil {
 		return "", fmt.Errorf("provider %q already exists", key)
@@ -212,6 +215,9 @@ func (s *Server) execProviderUpdate(ctx context.Context, args map[string]any) (s
 	if msg := validateRateLimitConfig(cfg.RateLimit); msg != "" {
 		return "", fmt.Errorf("%s", msg)
 	}
+	if msg := validateAPIKeys(cfg.APIKeys); msg != "" {
+		return "", fmt.Errorf("%s", msg)
+	}
 
 	// Preserve managed OAuth fields omitted by redacted provider reads.
 	existing, err := s.store.GetProvider(ctx, key)
@@ -283,6 +289,8 @@ func (s *Server) execProviderDiscoverModels(ctx context.Context, args map[string
 		}
 	}
 
+	useFirstPoolKey(&cfg)
+
 	var models []string
 	key, _ := args["key"].(string)
 	switch cfg.Type {
@@ -299,6 +307,8 @@ func (s *Server) execProviderDiscoverModels(ctx context.Context, args map[string
 		}
 	case "gemini":
 		models, err = discoverGeminiModels(ctx, cfg)
+	case "ollama":
+		models, err = discoverOllamaModels(ctx, cfg)
 	case "minimax":
 		models = []string{
 			"MiniMax-M2.7",
@@ -310,7 +320,7 @@ func (s *Server) execProviderDiscoverModels(ctx context.Context, args map[string
 			"MiniMax-M2",
 		}
 	default:
-		return "", fmt.Errorf("model discovery is not supported for provider type %q (supported: openai, anthropic, gemini, minimax)", cfg.Type)
+		return "", fmt.Errorf("model discovery is not supported for provider type %q (supported: openai, anthropic, gemini, ollama, minimax)", cfg.Type)
 	}
 	if err != nil {
 		return "", fmt.Errorf("discover models: %w", err)
@@ -321,3 +331,38 @@ func (s *Server) execProviderDiscoverModels(ctx context.Context, args map[string
 	out, _ := json.MarshalIndent(map[string]any{"models": models}, "", "  ")
 	return string(out), nil
 }
+
+// execProviderPullModel pulls a model onto a ModelManager provider and
+// waits for the download to finish.
+func (s *Server) execProviderPullModel(ctx context.Context, args map[string]any) (string, error) {
+	key, _ := args["key"].(string)
+	model, _ := args["model"].(string)
+	if key == "" || strings.TrimSpace(model) == "" {
+		return "", fmt.Errorf("key and model are required")
+	}
+	mm, _, err := s.modelManagerFor(key)
+	if err != nil {
+		return "", err
+	}
+	if err := mm.PullModel(ctx, strings.TrimSpace(model), nil); err != nil {
+		return "", fmt.Errorf("pull model: %w", err)
+	}
+	return fmt.Sprintf(`{"status":"pulled","key":%q,"model":%q}`, key, strings.TrimSpace(model)), nil
+}
+
+// execProviderDeleteModel removes an installed model from a ModelManager provider.
+func (s *Server) execProviderDeleteModel(ctx context.Context, args map[string]any) (string, error) {
+	key, _ := args["key"].(string)
+	model, _ := args["model"].(string)
+	if key == "" || strings.TrimSpace(model) == "" {
+		return "", fmt.Errorf("key and model are required")
+	}
+	mm, _, err := s.modelManagerFor(key)
+	if err != nil {
+		return "", err
+	}
+	if err := mm.DeleteModel(ctx, strings.TrimSpace(model)); err != nil {
+		return "", fmt.Errorf("delete model: %w", err)
+	}
+	return fmt.Sprintf(`{"status":"deleted","key":%q,"model":%q}`, key, strings.TrimSpace(model)), nil
+}
@@ -434,6 +434,7 @@ func TestAtManagementTemplate_HasNewTools(t *testing.T) {
 		"bot_create", "bot_delete", "bot_start", "bot_stop", "bot_status",
 		// Phase 2: Provider write
 		"provider_create", "provider_update", "provider_delete", "provider_discover_models",
+		"provider_pull_model", "provider_delete_model",
 		// Phase 2: API tokens
 		"apitoken_list", "apitoken_create", "apitoken_update", "apitoken_delete",
 		"apitoken_get_usage", "apitoken_reset_usage",
@@ -508,6 +509,7 @@ func TestDispatch_NewToolsHaveDefinitions(t *testing.T) {
 		// Phase 2
 		"bot_create", "bot_delete", "bot_start", "bot_stop", "bot_status",
 		"provider_create", "provider_update", "provider_delete", "provider_discover_models",
+		"provider_pull_model", "provider_delete_model",
 		"apitoken_list", "apitoken_create", "apitoken_update", "apitoken_delete",
 		"apitoken_get_usage", "apitoken_reset_usage",
 		"variable_list", "variable_get", "variable_create", "variable_update", "variable_delete",
@@ -164,10 +164,10 @@ var builtinTools = []builtinToolDef{
 	{Name: "org_task_intake", Description: "Submit a task to an organization for processing. The task is assigned to the org's head agent who delegates to specialist agents. Returns immediately with the task ID while delegation runs in the background. This is the primary way to trigger agent pipelines (e.g. 'create a YouTube Short about X').", InputSchema: map[string]any{"type": "object", "properties": map[string]any{"organization_id": map[string]any{"type": "string", "description": "The organization ID to submit the task to"}, "title": map[string]any{"type": "string", "description": "Task title (e.g. 'Create a short about quantum computing')"}, "description": map[string]any{"type": "string", "description": "Additional context or requirements"}, "priority_level": map[string]any{"type": "string", "description": "Priority: critical, high, medium, low", "enum": []string{"critical", "high", "medium", "low"}}, "max_iterations": map[string]any{"type": "number", "description": "Per-task override of the head agent's max iterations. 0 = use agent default."}}, "required": []string{"organization_id", "title"}}},
 
 	// ─── Agent Management Tools ───
-	{Name: "agent_create", Description: "Create a new AI agent with LLM provider, model, system prompt, and tool configuration.", InputSchema: map[string]any{"type": "object", "properties": map[string]any{"name": map[string]any{"type": "string", "description": "Agent name (unique identifier)"}, "provider": map[string]any{"type": "string", "description": "LLM provider key (configured in AT providers)"}, "model": map[string]any{"type": "string", "description": "Model identifier (e.g. gpt-4o, claude-sonnet-4-20250514)"}, "system_prompt": map[string]any{"type": "string", "description": "System prompt that defines the agent's behavior and role"}, "description": map[string]any{"type": "string", "description": "Agent description"}, "skills": map[string]any{"type": "array", "items": map[string]any{"type": "string"}, "description": "Skill IDs or names to assign to the agent"}, "mcp_sets": map[string]any{"type": "array", "items": map[string]any{"type": "string"}, "description": "MCP Set names (internal MCPs) to assign to the agent"}, "builtin_tools": map[string]any{"type": "array", "items": map[string]any{"type": "string"}, "description": "Built-in tool names to enable for the agent"}, "max_iterations": map[string]any{"type": "number", "description": "Maximum agentic loop iterations (default: 10)"}, "tool_timeout": map[string]any{"type": "number", "description": "Per-tool timeout in seconds (default: 60)"}}, "required": []string{"name"}}},
+	{Name: "agent_create", Description: "Create a new AI agent with LLM provider, model, system prompt, and tool configuration.", InputSchema: map[string]any{"type": "object", "properties": map[string]any{"name": map[string]any{"type": "string", "description": "Agent name (unique identifier)"}, "provider": map[string]any{"type": "string", "description": "LLM provider key (configured in AT providers)"}, "model": map[string]any{"type": "string", "description": "Model identifier (e.g. gpt-4o, claude-sonnet-4-20250514)"}, "system_prompt": map[string]any{"type": "string", "description": "System prompt that defines the agent's behavior and role"}, "description": map[string]any{"type": "string", "description": "Agent description"}, "skills": map[string]any{"type": "array", "items": map[string]any{"type": "string"}, "description": "Skill IDs or names to assign to the agent"}, "mcp_sets": map[string]any{"type": "array", "items": map[string]any{"type": "string"}, "description": "MCP Set names (internal MCPs) to assign to the agent"}, "builtin_tools": map[string]any{"type": "array", "items": map[string]any{"type": "string"}, "description": "Built-in tool names to enable for the agent"}, "max_iterations": map[string]any{"type": "number", "description": "Maximum agentic loop iterations (default: 10)"}, "tool_timeout": map[string]any{"type": "number", "description": "Per-tool timeout in seconds (default: 60)"}, "disable_prompt_cache": map[string]any{"type": "boolean", "description": "Opt out of automatic prompt caching (default: false)"}}, "required": []string{"name"}}},
 	{Name: "agent_list", Description: "List all AI agents with their key configuration details.", InputSchema: map[string]any{"type": "object", "properties": map[string]any{}}},
 	{Name: "agent_get", Description: "Get an agent's full details including its complete configuration (provider, model, system prompt, skills, tools).", InputSchema: map[string]any{"type": "object", "properties": map[string]any{"id": map[string]any{"type": "string", "description": "The agent ID"}}, "required": []string{"id"}}},
-	{Name: "agent_update", Description: "Update an agent's configuration. Only provided fields are changed.", InputSchema: map[string]any{"type": "object", "properties": map[string]any{"id": map[string]any{"type": "string", "description": "The agent ID to update"}, "name": map[string]any{"type": "string", "description": "New agent name"}, "provider": map[string]any{"type": "string", "description": "New LLM provider key"}, "model": map[string]any{"type": "string", "description": "New model identifier"}, "system_prompt": map[string]any{"type": "string", "description": "New system prompt"}, "description": map[string]any{"type": "string", "description": "New description"}, "skills": map[string]any{"type": "array", "items": map[string]any{"type": "string"}, "description": "New skill list (replaces existing)"}, "mcp_sets": map[string]any{"type": "array", "items": map[string]any{"type": "string"}, "description": "New MCP set list (replaces existing)"}, "builtin_tools": map[string]any{"type": "array", "items": map[string]any{"type": "string"}, "description": "New built-in tools list (replaces existing)"}, "max_iterations": map[string]any{"type": "number", "description": "New max iterations"}, "tool_timeout": map[string]any{"type": "number", "description": "New tool timeout in seconds"}}, "required": []string{"id"}}},
+	{Name: "agent_update", Description: "Update an agent's configuration. Only provided fields are changed.", InputSchema: map[string]any{"type": "object", "properties": map[string]any{"id": map[string]any{"type": "string", "description": "The agent ID to update"}, "name": map[string]any{"type": "string", "description": "New agent name"}, "provider": map[string]any{"type": "string", "description": "New LLM provider key"}, "model": map[string]any{"type": "string", "description": "New model identifier"}, "system_prompt": map[string]any{"type": "string", "description": "New system prompt"}, "description": map[string]any{"type": "string", "description": "New description"}, "skills": map[string]any{"type": "array", "items": map[string]any{"type": "string"}, "description": "New skill list (replaces existing)"}, "mcp_sets": map[string]any{"type": "array", "items": map[string]any{"type": "string"}, "description": "New MCP set list (replaces existing)"}, "builtin_tools": map[string]any{"type": "array", "items": map[string]any{"type": "string"}, "description": "New built-in tools list (replaces existing)"}, "max_iterations": map[string]any{"type": "number", "description": "New max iterations"}, "tool_timeout": map[string]any{"type": "number", "description": "New tool timeout in seconds"}, "disable_prompt_cache": map[string]any{"type": "boolean", "description": "Opt out of automatic prompt caching"}}, "required": []string{"id"}}},
 
 	// ─── Skill Management Tools ───
 	{Name: "skill_list", Description: "List installed skills and available skill templates. Shows both what's already installed and what templates can be installed.", InputSchema: map[string]any{"type": "object", "properties": map[string]any{"category": map[string]any{"type": "string", "description": "Filter templates by category (e.g. 'Content Creation', 'Development', 'Utilities')"}}}},
@@ -458,6 +458,19 @@ var builtinTools = []builtinToolDef{
 					"extra_headers": map[string]any{"type": "object", "description": "Extra HTTP headers added on every request"},
 					"proxy":         map[string]any{"type": "string", "description": "HTTP/HTTPS proxy URL"},
 					"refresh_token": map[string]any{"type": "string", "description": "Refresh token (stored encrypted) for OAuth-based providers"},
+					"api_keys": map[string]any{
+						"type":        "array",
+						"description": "Pool of API keys used in rotation; rate-limited keys cool down for their Retry-After (stored encrypted, static api-key auth only)",
+						"items": map[string]any{
+							"type": "object",
+							"properties": map[string]any{
+								"name":       map[string]any{"type": "string", "description": "Label in usage stats (default key-N)"},
+								"key":        map[string]any{"type": "string", "description": "API key; empty or '***' keeps the stored key on update"},
+								"rate_limit": map[string]any{"type": "object", "description": "Per-key rate limit, same shape as config.rate_limit"},
+							},
+							"required": []string{"key"},
+						},
+					},
 					"rate_limit": map[string]any{
 						"type":        "object",
 						"description": "Per-provider rate limits (all >= 0; retry_after_cap_ms may be -1 = no cap)",
@@ -476,7 +489,7 @@ var builtinTools = []builtinToolDef{
 		},
 		"required": []string{"key", "config"},
 	}},
-	{Name: "provider_update", Description: "Update an existing LLM provider config. Empty `api_key` or `refresh_token` preserve the current stored secrets (so callers can edit other fields without re-supplying tokens). The provider is hot-reloaded on success.", InputSchema: map[string]any{
+	{Name: "provider_update", Description: "Update an existing LLM provider config. Empty `api_key` or `refresh_token` (and empty or '***' `api_keys[].key`) preserve the current stored secrets (so callers can edit other fields without re-supplying tokens). The provider is hot-reloaded on success.", InputSchema: map[string]any{
 		"type": "object",
 		"properties": map[string]any{
 			"key":    map[string]any{"type": "string", "description": "Provider key to update"},
@@ -485,7 +498,7 @@ var builtinTools = []builtinToolDef{
 		"required": []string{"key", "config"},
 	}},
 	{Name: "provider_delete", Description: "Delete an LLM provider by key. The provider is also removed from the in-memory registry; agents referencing it will fail until they're updated.", InputSchema: map[string]any{"type": "object", "properties": map[string]any{"key": map[string]any{"type": "string", "description": "Provider key to delete"}}, "required": []string{"key"}}},
-	{Name: "provider_discover_models", Description: "Discover available model IDs for a provider config by calling its model-listing API. Supported types: openai, anthropic, gemini, minimax. Pass an existing `key` to fall back to the stored API key if `config.api_key` is empty (useful when editing a provider whose key is redacted). Returns {models: [...]}.", InputSchema: map[string]any{
+	{Name: "provider_discover_models", Description: "Discover available model IDs for a provider config by calling its model-listing API. Supported types: openai, anthropic, gemini, ollama, minimax. Pass an existing `key` to fall back to the stored API key if `config.api_key` is empty (useful when editing a provider whose key is redacted). Returns {models: [...]}.", InputSchema: map[string]any{
 		"type": "object",
 		"properties": map[string]any{
 			"config": map[string]any{
@@ -505,6 +518,22 @@ var builtinTools = []builtinToolDef{
 		},
 		"required": []string{"config"},
 	}},
+	{Name: "provider_pull_model", Description: "Download a model onto a provider that hosts its own models (type ollama). Blocks until the pull finishes, which can take minutes for large models. Use provider_discover_models to list installed models.", InputSchema: map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"key":   map[string]any{"type": "string", "description": "Provider key"},
+			"model": map[string]any{"type": "string", "description": "Model name, e.g. 'llama3.2' or 'qwen3:8b'"},
+		},
+		"required": []string{"key", "model"},
+	}},
+	{Name: "provider_delete_model", Description: "Remove an installed model from a provider that hosts its own models (type ollama).", InputSchema: map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"key":   map[string]any{"type": "string", "description": "Provider key"},
+			"model": map[string]any{"type": "string", "description": "Installed model name, e.g. 'llama3.2:latest'"},
+		},
+		"required": []string{"key", "model"},
+	}},
 
 	// ─── API Token Tools (Phase 2) ───
 	// Gateway API tokens authenticate inbound `/gateway/v1/*` calls. The
@@ -12,10 +12,13 @@ import (
 	"time"
 	"unicode/utf8"
 
-	"github.com/oklog/ulid/v2"
 	"github.com/rakunlabs/at/internal/service"
+	"github.com/rakunlabs/at/internal/service/metrics"
+	"github.com/rakunlabs/at/internal/service/tracing"
 	"github.com/rakunlabs/at/internal/service/workflow"
 	"github.com/rakunlabs/query"
+	"go.opentelemetry.io/otel/attribute"
+	oteltrace "go.opentelemetry.io/otel/trace"
 )
 
 // ─── Chat Session CRUD ───
@@ -353,6 +356,21 @@ func (s *Server) RunAgenticLoop(ctx context.Context, sessionID, content string,
 		model = info.defaultModel
 	}
 
+	// Guardrails: the agent's policies, then those of the bot that owns
+	// the session.
+	guardrailIDs := slices.Clone(agent.Config.GuardrailIDs)
+	if botID := session.Config.BotConfigID; botID != "" && s.botConfigStore != nil {
+		if bot, err := s.botConfigStore.GetBotConfig(ctx, botID); err != nil {
+			return fmt.Errorf("get bot config: %w", err)
+		} else if bot != nil {
+			guardrailIDs = append(guardrailIDs, bot.GuardrailIDs...)
+		}
+	}
+	info.provider = s.withGuardrails(info.provider, guardrailIDs, guardrailScope{
+		source: "chat", fullModel: providerKey + "/" + model,
+		sessionID: sessionID, agentID: session.AgentID, orgID: session.OrganizationID,
+	})
+
 	// 4. Load message history.
 	// The chat-session loop applies a recency window so long-running
 	// sessions don't replay an unbounded history on every user turn.
@@ -857,8 +875,15 @@ func (s *Server) RunAgenticLoop(ctx context.Context, sessionID, content string,
 	}
 
 	// Trace identity: one agentic-loop turn is one trace; the chat
-	// session groups turns into a session.
-	turnTraceID := ulid.Make().String()
+	// session groups turns into a session. The turn span joins the
+	// caller's trace when the request carried a traceparent, and the
+	// audit trace ID follows the OTel trace ID.
+	ctx, turnSpan := tracing.StartSpan(ctx, "agent.turn",
+		attribute.String("at.agent_id", session.AgentID),
+		attribute.String("at.session_id", sessionID),
+	)
+	defer turnSpan.End()
+	turnTraceID := traceIDFor(ctx)
 	traceTaskID := ""
 	if taskLinked != nil {
 		traceTaskID = taskLinked.ID
@@ -870,7 +895,29 @@ func (s *Server) RunAgenticLoop(ctx context.Context, sessionID, content string,
 	// the terminal status+result, so the natural-finish auto-sync must not
 	// overwrite it (e.g. blocked → completed) with the closing chat text.
 	terminalToolCalled := false
+
+	// Each iteration gets a span under the turn span; the previous one is
+	// ended when the next one starts (or when the turn returns).
+	loopCtx := ctx
+	var iterSpan oteltrace.Span
+	defer func() {
+		if iterSpan != nil {
+			iterSpan.End()
+		}
+	}()
+
 	for iteration := 0; iteration < maxIterations; iteration++ {
+		metrics.AgentLoopIterations.Inc(agent.Name, "chat")
+
+		if iterSpan != nil {
+			iterSpan.End()
+		}
+		ctx, span := tracing.StartSpan(loopCtx, "agent.iteration",
+			attribute.String("at.agent_id", session.AgentID),
+			attribute.Int("at.iteration", iteration),
+		)
+		iterSpan = span
+
 		// Rebuild LLM tool list each iteration: base tools + tools from
 		// any skills the LLM has activated so far.
 		llmTools := append([]service.Tool{}, baseLLMTools...)
@@ -917,9 +964,11 @@ func (s *Server) RunAgenticLoop(ctx context.Context, sessionID, content string,
 				}
 				return sessionID
 			}(), llmMessages, llmTools)
-		chatOpts := s.loopGov.ChatOptions()
+		chatOpts := s.loopGov.ChatOptions(agent.Config)
 		callStart := time.Now()
-		resp, err := info.provider.Chat(ctx, model, windowed, llmTools, chatOpts)
+		chatCtx, chatSpan := tracing.StartProviderSpan(ctx, providerKey, model, 1)
+		resp, err := info.provider.Chat(chatCtx, model, windowed, llmTools, chatOpts)
+		tracing.EndProviderSpan(chatSpan, resp, err)
 		latencyMs := time.Since(callStart).Milliseconds()
 		if err != nil {
 			// Recover from corrupted tool call history — sanitize and retry once.
@@ -935,7 +984,9 @@ func (s *Server) RunAgenticLoop(ctx context.Context, sessionID, content string,
 						return sessionID
 					}(), llmMessages, llmTools)
 				callStart = time.Now()
-				resp, err = info.provider.Chat(ctx, model, windowed, llmTools, chatOpts)
+				chatCtx, chatSpan = tracing.StartProviderSpan(ctx, providerKey, model, 2)
+				resp, err = info.provider.Chat(chatCtx, model, windowed, llmTools, chatOpts)
+				tracing.EndProviderSpan(chatSpan, resp, err)
 				latencyMs = time.Since(callStart).Milliseconds()
 			}
 			if err != nil {
@@ -1154,6 +1205,7 @@ func (s *Server) RunAgenticLoop(ctx context.Context, sessionID, content string,
 			onEvent(AgenticEvent{Type: "tool_call", ToolName: tc.Name, ToolID: tc.ID})
 
 			toolStarted := time.Now()
+			ctx, toolSpan := tracing.StartToolSpan(ctx, tc.Name, "chat", tc.Arguments)
 			var result string
 			var callErr error
 
@@ -1282,6 +1334,7 @@ func (s *Server) RunAgenticLoop(ctx context.Context, sessionID, content string,
 			// Observation: tool call, parented to this iteration's
 			// generation. Captures the input arguments and the
 			// (post-truncation) output that entered the LLM history.
+			tracing.EndToolSpan(toolSpan, result, callErr != nil)
 			toolLevel := service.ObservationLevelDefault
 			if callErr != nil {
 				toolLevel = service.ObservationLevelError
@@ -2,20 +2,63 @@ package server
 
 import (
 	"context"
+	"log/slog"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/rakunlabs/at/internal/service"
 )
 
-func (s *Server) estimateGatewayUsageCostCents(ctx context.Context, providerKey, actualModel, fullModel string, usage service.Usage) float64 {
+// modelPricingCacheTTL bounds how stale another instance's pricing edits
+// can be; local edits invalidate the cache immediately.
+const modelPricingCacheTTL = 30 * time.Second
+
+type modelPricingCache struct {
+	mu       sync.Mutex
+	list     []service.ModelPricing
+	loaded   bool
+	loadedAt time.Time
+}
+
+// modelPricing returns the pricing table used to price calls, reloading
+// it when the cache has expired. A failed reload keeps serving the
+// previous list.
+func (s *Server) modelPricing(ctx context.Context) []service.ModelPricing {
 	if s.agentBudgetStore == nil {
-		return 0
+		return nil
 	}
-	pricingList, err := s.agentBudgetStore.ListModelPricing(ctx)
+
+	c := &s.pricingCache
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.loaded && time.Since(c.loadedAt) < modelPricingCacheTTL {
+		return c.list
+	}
+
+	list, err := s.agentBudgetStore.ListModelPricing(ctx)
 	if err != nil {
-		return 0
+		slog.Warn("load model pricing failed", "error", err)
+		c.loadedAt = time.Now()
+		return c.list
 	}
-	return estimateUsageCostCents(pricingList, providerKey, actualModel, fullModel, usage)
+	c.list, c.loaded, c.loadedAt = list, true, time.Now()
+	return list
+}
+
+func (s *Server) invalidateModelPricing() {
+	s.pricingCache.mu.Lock()
+	s.pricingCache.loaded = false
+	s.pricingCache.mu.Unlock()
+}
+
+func (s *Server) estimateGatewayUsageCostCents(ctx context.Context, providerKey, actualModel, fullModel string, usage service.Usage) float64 {
+	return estimateUsageCostCents(s.modelPricing(ctx), providerKey, actualModel, fullModel, usage)
+}
+
+// estimateGatewayVideoCostCents prices a finished video job.
+func (s *Server) estimateGatewayVideoCostCents(ctx context.Context, v *service.VideoGeneration) float64 {
+	return estimateVideoCostCents(s.modelPricing(ctx), v.ProviderKey, v.Model, v.ProviderKey+"/"+v.Model, v.Seconds)
 }
 
 func estimateUsageCostCents(pricingList []service.ModelPricing, providerKey, actualModel, fullModel string, usage service.Usage) float64 {
@@ -64,3 +107,13 @@ func pricingModelMatches(pricingModel, actualModel, fullModel string) bool {
 	}
 	return false
 }
+
+// estimateVideoCostCents prices a generated video at the model's
+// per-second rate.
+func estimateVideoCostCents(pricingList []service.ModelPricing, providerKey, actualModel, fullModel string, seconds int) float64 {
+	pricing, ok := findModelPricing(pricingList, providerKey, actualModel, fullModel)
+	if !ok || seconds <= 0 || pricing.PricePerSecond <= 0 {
+		return 0
+	}
+	return float64(seconds) * pricing.PricePerSecond * 100
+}
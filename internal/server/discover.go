@@ -15,6 +15,7 @@ import (
 	"github.com/worldline-go/klient"
 
 	"github.com/rakunlabs/at/internal/config"
+	"github.com/rakunlabs/at/internal/service/llm/ollama"
 	"github.com/rakunlabs/at/internal/service/llm/openai"
 )
 
@@ -57,6 +58,8 @@ func (s *Server) DiscoverModelsAPI(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	useFirstPoolKey(&req.Config)
+
 	ctx, cancel := context.WithTimeout(r.Context(), 15*time.Second)
 	defer cancel()
 
@@ -77,6 +80,8 @@ func (s *Server) DiscoverModelsAPI(w http.ResponseWriter, r *http.Request) {
 		}
 	case "gemini":
 		models, err = discoverGeminiModels(ctx, req.Config)
+	case "ollama":
+		models, err = discoverOllamaModels(ctx, req.Config)
 	case "minimax":
 		// MiniMax does not have a /v1/models endpoint. Return known models.
 		models = []string{
@@ -129,6 +134,8 @@ func (s *Server) DiscoverEmbeddingModelsAPI(w http.ResponseWriter, r *http.Reque
 		}
 	}
 
+	useFirstPoolKey(&req.Config)
+
 	ctx, cancel := context.WithTimeout(r.Context(), 15*time.Second)
 	defer cancel()
 
@@ -145,6 +152,11 @@ func (s *Server) DiscoverEmbeddingModelsAPI(w http.ResponseWriter, r *http.Reque
 		models, err = discoverGeminiEmbeddingModels(ctx, req.Config, false)
 	case "cohere":
 		models, err = discoverCohereEmbeddingModels(ctx, req.Config)
+	case "ollama":
+		models, err = discoverOllamaModels(ctx, req.Config)
+		if err == nil {
+			models = filterEmbeddingModelIDs(models)
+		}
 	default:
 		httpResponse(w, fmt.Sprintf("embedding model discovery is not supported for provider type %q", req.Config.Type), http.StatusBadRequest)
 		return
@@ -159,6 +171,14 @@ func (s *Server) DiscoverEmbeddingModelsAPI(w http.ResponseWriter, r *http.Reque
 	httpResponseJSON(w, discoverResponse{Models: models}, http.StatusOK)
 }
 
+// useFirstPoolKey lends discovery the first api_keys entry when the
+// provider has no api_key of its own.
+func useFirstPoolKey(cfg *config.LLMConfig) {
+	if cfg.APIKey == "" && len(cfg.APIKeys) > 0 {
+		cfg.APIKey = cfg.APIKeys[0].Key
+	}
+}
+
 // filterEmbeddingModelIDs keeps model IDs that look like embedding models
 // (name-based heuristic for OpenAI-compatible providers, whose /models
 // endpoint carries no capability metadata).
@@ -173,6 +193,16 @@ func filterEmbeddingModelIDs(models []string) []string {
 	return out
 }
 
+// discoverOllamaModels lists the models installed on an Ollama server
+// (GET /api/tags).
+func discoverOllamaModels(ctx context.Context, cfg config.LLMConfig) ([]string, error) {
+	p, err := ollama.New(cfg.APIKey, cfg.Model, cfg.BaseURL, cfg.Proxy, cfg.InsecureSkipVerify)
+	if err != nil {
+		return nil, err
+	}
+	return p.ListModels(ctx)
+}
+
 // discoverCohereEmbeddingModels calls GET /v1/models?endpoint=embed on the Cohere API.
 func discoverCohereEmbeddingModels(ctx context.Context, cfg config.LLMConfig) ([]string, error) {
 	baseURL := cfg.BaseURL
@@ -535,6 +565,9 @@ func klientForConfig(cfg config.LLMConfig) (*klient.Client, error) {
 type geminiModel struct {
 	Name                       string   `json:"name"`
 	SupportedGenerationMethods []string `json:"supportedGenerationMethods"`
+	InputTokenLimit            int      `json:"inputTokenLimit"`
+	OutputTokenLimit           int      `json:"outputTokenLimit"`
+	Thinking                   bool     `json:"thinking"`
 }
 
 // listGeminiModels calls GET /v1beta/models on the Google Generative Language API
@@ -0,0 +1,1321 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+
+	"github.com/rakunlabs/at/internal/service"
+	"github.com/rakunlabs/at/internal/service/ratelimit"
+)
+
+// ─── Batch API ───
+//
+// /gateway/v1/batches runs an uploaded OpenAI batch JSONL file (one
+// /v1/chat/completions request per line) in the background. Requests may
+// target any provider/model the token can use.
+//
+// When every request targets the same `openai` provider (API-key auth)
+// and neither the token nor the provider has guardrails or hooks, the job
+// is handed to that provider's native /v1/batches API for the batch
+// discount and the gateway only polls it. Otherwise the gateway's
+// own workers run each request through the provider client, so the
+// provider's rate_limit applies: concurrency per provider is capped at
+// rate_limit.max_concurrent (default 4) and a request that times out
+// waiting for the limiter is retried rather than failed.
+//
+// Progress is flushed to the store every few seconds, so a restart
+// resumes a batch without re-running finished requests. Each batch runs
+// under a lease in the store, so with several replicas only one executes
+// it and another picks it up when that one stops renewing. Requests run
+// under the batch's API token as it is now: allow-lists, guardrails and
+// hooks edited after creation apply, and the token's usage limits are
+// checked before every request. Usage and cost are recorded per API
+// token like any gateway call.
+
+const (
+	batchEndpointChatCompletions = "/v1/chat/completions"
+	batchCompletionWindow        = "24h"
+
+	// maxBatchRequests caps the requests in one input file, matching OpenAI.
+	maxBatchRequests = 50_000
+
+	// defaultBatchConcurrency is the per-provider worker count when the
+	// provider has no rate_limit.max_concurrent.
+	defaultBatchConcurrency = 4
+
+	// nativeBatchCostScale is the price of provider batch API calls
+	// relative to list price.
+	nativeBatchCostScale = 0.5
+)
+
+var (
+	// batchFlushInterval is how often running batches persist progress.
+	batchFlushInterval = 5 * time.Second
+
+	// batchLimiterBackoff is the pause before retrying a request whose
+	// provider rate limiter was saturated.
+	batchLimiterBackoff = 2 * time.Second
+
+	// nativeBatchPollInterval is how often provider batch jobs are polled.
+	nativeBatchPollInterval = time.Minute
+
+	// batchLeaseTTL is how long a batch run lease lasts without renewal.
+	// Leases are renewed at a third of it, and unfinished batches are
+	// rescanned at this interval to take over those whose owner stopped.
+	batchLeaseTTL = time.Minute
+)
+
+type createBatchRequest struct {
+	InputFileID      string            `json:"input_file_id"`
+	Endpoint         string            `json:"endpoint"`
+	CompletionWindow string            `json:"completion_window"`
+	Metadata         map[string]string `json:"metadata"`
+}
+
+// batchRequestLine is one line of a batch input file.
+type batchRequestLine struct {
+	CustomID string          `json:"custom_id"`
+	Method   string          `json:"method"`
+	URL      string          `json:"url"`
+	Body     json.RawMessage `json:"body"`
+
+	// model is body.model, filled in by parseBatchInput.
+	model string
+}
+
+// parseBatchInput decodes and validates a batch input file.
+func parseBatchInput(content []byte, endpoint string) ([]batchRequestLine, error) {
+	var (
+		lines []batchRequestLine
+		seen  = map[string]bool{}
+	)
+	for i, raw := range bytes.Split(content, []byte("\n")) {
+		raw = bytes.TrimSpace(raw)
+		if len(raw) == 0 {
+			continue
+		}
+		var line batchRequestLine
+		if err := json.Unmarshal(raw, &line); err != nil {
+			return nil, fmt.Errorf("line %d: %v", i+1, err)
+		}
+		switch {
+		case line.CustomID == "":
+			return nil, fmt.Errorf("line %d: custom_id is required", i+1)
+		case seen[line.CustomID]:
+			return nil, fmt.Errorf("line %d: duplicate custom_id %q", i+1, line.CustomID)
+		case line.Method != "" && !strings.EqualFold(line.Method, http.MethodPost):
+			return nil, fmt.Errorf("line %d: method must be POST", i+1)
+		case line.URL != endpoint:
+			return nil, fmt.Errorf("line %d: url %q does not match the batch endpoint %q", i+1, line.URL, endpoint)
+		}
+		var body struct {
+			Model  string `json:"model"`
+			Stream bool   `json:"stream"`
+		}
+		if err := json.Unmarshal(line.Body, &body); err != nil {
+			return nil, fmt.Errorf("line %d: body must be a JSON object: %v", i+1, err)
+		}
+		if body.Model == "" {
+			return nil, fmt.Errorf("line %d: body.model is required", i+1)
+		}
+		if body.Stream {
+			return nil, fmt.Errorf("line %d: streaming is not supported in batches", i+1)
+		}
+		seen[line.CustomID] = true
+		line.model = body.Model
+		lines = append(lines, line)
+		if len(lines) > maxBatchRequests {
+			return nil, fmt.Errorf("a batch may contain at most %d requests", maxBatchRequests)
+		}
+	}
+	if len(lines) == 0 {
+		return nil, errors.New("input file contains no requests")
+	}
+	return lines, nil
+}
+
+// batchAuth loads the API token a batch runs under, so changes made to
+// it after the batch was created apply. msg is set when the token was
+// deleted or has expired.
+func (s *Server) batchAuth(ctx context.Context, b *service.Batch) (*authResult, string, error) {
+	if b.TokenID == "" {
+		return &authResult{}, "", nil
+	}
+	if s.tokenStore == nil {
+		return nil, "", errors.New("no token store configured")
+	}
+	token, err := s.tokenStore.GetAPIToken(ctx, b.TokenID)
+	if err != nil {
+		return nil, "", fmt.Errorf("load api token: %w", err)
+	}
+	switch {
+	case token == nil:
+		return nil, "the API token that created the batch no longer exists", nil
+	case token.ExpiresAt.Valid && token.ExpiresAt.V.Time.Before(time.Now().UTC()):
+		return nil, "the API token that created the batch has expired", nil
+	}
+	return &authResult{token: token}, "", nil
+}
+
+// batchUsageAuth attributes usage of work that already ran upstream to
+// the batch's token, even when the token has since been removed.
+func (s *Server) batchUsageAuth(ctx context.Context, b *service.Batch) *authResult {
+	if auth, _, err := s.batchAuth(ctx, b); err == nil && auth != nil {
+		return auth
+	}
+	return &authResult{token: &service.APIToken{ID: b.TokenID, Name: b.TokenName}}
+}
+
+func batchTerminal(status string) bool {
+	switch status {
+	case service.BatchStatusCompleted, service.BatchStatusFailed, service.BatchStatusExpired, service.BatchStatusCancelled:
+		return true
+	}
+	return false
+}
+
+// batchObject renders a batch in the OpenAI batch object shape.
+func batchObject(b *service.Batch) map[string]any {
+	unix := func(t *time.Time) any {
+		if t == nil {
+			return nil
+		}
+		return t.Unix()
+	}
+	fileID := func(id string) any {
+		if id == "" || !batchTerminal(b.Status) {
+			return nil
+		}
+		return id
+	}
+	var errs any
+	if b.ErrorMessage != "" {
+		errs = map[string]any{
+			"object": "list",
+			"data":   []any{map[string]any{"code": "batch_failed", "message": b.ErrorMessage}},
+		}
+	}
+	metadata := b.Metadata
+	if metadata == nil {
+		metadata = map[string]string{}
+	}
+	return map[string]any{
+		"id":                b.ID,
+		"object":            "batch",
+		"endpoint":          b.Endpoint,
+		"errors":            errs,
+		"input_file_id":     b.InputFileID,
+		"completion_window": b.CompletionWindow,
+		"status":            b.Status,
+		"output_file_id":    fileID(b.OutputFileID),
+		"error_file_id":     fileID(b.ErrorFileID),
+		"created_at":        b.CreatedAt.Unix(),
+		"in_progress_at":    unix(b.InProgressAt),
+		"expires_at":        b.ExpiresAt.Unix(),
+		"finalizing_at":     unix(b.FinalizingAt),
+		"completed_at":      unix(b.CompletedAt),
+		"failed_at":         unix(b.FailedAt),
+		"expired_at":        unix(b.ExpiredAt),
+		"cancelling_at":     unix(b.CancellingAt),
+		"cancelled_at":      unix(b.CancelledAt),
+		"request_counts": map[string]any{
+			"total":     b.RequestTotal,
+			"completed": b.RequestCompleted,
+			"failed":    b.RequestFailed,
+		},
+		"metadata": metadata,
+	}
+}
+
+// CreateBatch handles POST /gateway/v1/batches.
+func (s *Server) CreateBatch(w http.ResponseWriter, r *http.Request) {
+	auth, ok := s.batchRequestAuth(w, r)
+	if !ok {
+		return
+	}
+
+	var req createBatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		gatewayBadRequest(w, fmt.Sprintf("invalid request body: %v", err), "", "")
+		return
+	}
+	if req.Endpoint != batchEndpointChatCompletions {
+		gatewayBadRequest(w, fmt.Sprintf("endpoint %q is not supported; use %q", req.Endpoint, batchEndpointChatCompletions), "endpoint", "")
+		return
+	}
+	if req.CompletionWindow == "" {
+		req.CompletionWindow = batchCompletionWindow
+	}
+	if req.CompletionWindow != batchCompletionWindow {
+		gatewayBadRequest(w, fmt.Sprintf("completion_window must be %q", batchCompletionWindow), "completion_window", "")
+		return
+	}
+
+	input, err := s.getOwnedBatchFile(r, auth, req.InputFileID)
+	if err != nil {
+		writeBatchServerError(w, err)
+		return
+	}
+	if input == nil || input.Purpose != batchFilePurpose {
+		writeBatchNotFound(w, "File", req.InputFileID)
+		return
+	}
+
+	lines, err := parseBatchInput(input.Content, req.Endpoint)
+	if err != nil {
+		gatewayBadRequest(w, err.Error(), "input_file_id", "invalid_batch_input")
+		return
+	}
+	for _, line := range lines {
+		if _, _, _, err := s.resolveModel(auth, line.model); err != nil {
+			gatewayBadRequest(w, fmt.Sprintf("custom_id %q: %v", line.CustomID, err), "input_file_id", "model_not_found")
+			return
+		}
+	}
+
+	if limitMessage, limitErr := s.checkTokenLimits(r.Context(), auth); limitErr != nil {
+		slog.Error("token limit check failed", "error", limitErr)
+	} else if limitMessage != "" {
+		httpResponseJSON(w, map[string]any{
+			"error": map[string]any{
+				"message": limitMessage,
+				"type":    "tokens",
+				"code":    "rate_limit_exceeded",
+			},
+		}, http.StatusTooManyRequests)
+		return
+	}
+
+	now := time.Now()
+	b := service.Batch{
+		ID:               "batch_" + ulid.Make().String(),
+		TokenID:          storedResponseTokenID(auth),
+		Endpoint:         req.Endpoint,
+		InputFileID:      input.ID,
+		CompletionWindow: req.CompletionWindow,
+		Status:           service.BatchStatusValidating,
+		Metadata:         req.Metadata,
+		RequestTotal:     len(lines),
+		CreatedAt:        now,
+		ExpiresAt:        now.Add(24 * time.Hour),
+	}
+	if auth.token != nil {
+		b.TokenName = auth.token.Name
+	}
+	if err := s.batchStore.PutBatch(r.Context(), b); err != nil {
+		writeBatchServerError(w, err)
+		return
+	}
+
+	s.startBatch(b.ID)
+	httpResponseJSON(w, batchObject(&b), http.StatusOK)
+}
+
+// GetBatch handles GET /gateway/v1/batches/{id}.
+func (s *Server) GetBatch(w http.ResponseWriter, r *http.Request) {
+	b, ok := s.batchJobRequest(w, r)
+	if !ok {
+		return
+	}
+	httpResponseJSON(w, batchObject(b), http.StatusOK)
+}
+
+// ListBatches handles GET /gateway/v1/batches with OpenAI's after/limit
+// (1-100, default 20) cursor parameters.
+func (s *Server) ListBatches(w http.ResponseWriter, r *http.Request) {
+	auth, ok := s.batchRequestAuth(w, r)
+	if !ok {
+		return
+	}
+
+	batches, err := s.batchStore.ListBatches(r.Context(), storedResponseTokenID(auth))
+	if err != nil {
+		writeBatchServerError(w, err)
+		return
+	}
+
+	q := r.URL.Query()
+	if after := q.Get("after"); after != "" {
+		for i := range batches {
+			if batches[i].ID == after {
+				batches = batches[i+1:]
+				break
+			}
+		}
+	}
+	limit := 20
+	if v, err := strconv.Atoi(q.Get("limit")); err == nil && v > 0 {
+		limit = min(v, 100)
+	}
+	hasMore := len(batches) > limit
+	if hasMore {
+		batches = batches[:limit]
+	}
+
+	data := make([]map[string]any, 0, len(batches))
+	for i := range batches {
+		data = append(data, batchObject(&batches[i]))
+	}
+	list := map[string]any{"object": "list", "data": data, "has_more": hasMore, "first_id": nil, "last_id": nil}
+	if len(batches) > 0 {
+		list["first_id"] = batches[0].ID
+		list["last_id"] = batches[len(batches)-1].ID
+	}
+	httpResponseJSON(w, list, http.StatusOK)
+}
+
+// CancelBatch handles POST /gateway/v1/batches/{id}/cancel. The batch
+// moves to "cancelling"; requests already sent finish, their results stay
+// in the output file, and the batch ends as "cancelled".
+func (s *Server) CancelBatch(w http.ResponseWriter, r *http.Request) {
+	b, ok := s.batchJobRequest(w, r)
+	if !ok {
+		return
+	}
+	if batchTerminal(b.Status) {
+		gatewayBadRequest(w, fmt.Sprintf("cannot cancel a batch with status %q", b.Status), "", "")
+		return
+	}
+
+	if b.Status != service.BatchStatusCancelling {
+		if b.UpstreamBatchID != "" {
+			if err := s.cancelNativeBatch(r.Context(), b); err != nil {
+				slog.Warn("batches: upstream cancel failed", "batch_id", b.ID, "error", err.Error())
+			}
+		}
+		now := time.Now()
+		b.Status = service.BatchStatusCancelling
+		b.CancellingAt = &now
+		if err := s.batchStore.PutBatch(r.Context(), *b); err != nil {
+			writeBatchServerError(w, err)
+			return
+		}
+	}
+	if cancel, ok := s.batchRuns.Load(b.ID); ok {
+		cancel.(context.CancelFunc)()
+	}
+
+	httpResponseJSON(w, batchObject(b), http.StatusOK)
+}
+
+// batchJobRequest authenticates a batch request and loads the token's
+// batch named by the {id} path value.
+func (s *Server) batchJobRequest(w http.ResponseWriter, r *http.Request) (*service.Batch, bool) {
+	auth, ok := s.batchRequestAuth(w, r)
+	if !ok {
+		return nil, false
+	}
+
+	id := r.PathValue("id")
+	b, err := s.batchStore.GetBatch(r.Context(), id)
+	if err != nil {
+		writeBatchServerError(w, err)
+		return nil, false
+	}
+	if b == nil || b.TokenID != storedResponseTokenID(auth) {
+		writeBatchNotFound(w, "Batch", id)
+		return nil, false
+	}
+	return b, true
+}
+
+// ─── Execution ───
+
+// startBatchWorkers resumes unfinished batches, keeps rescanning them
+// for batches another replica stopped running, and starts the janitor
+// for expired files.
+func (s *Server) startBatchWorkers(ctx context.Context) {
+	if s.batchStore == nil {
+		return
+	}
+
+	s.resumeBatches(ctx)
+	go func() {
+		ticker := time.NewTicker(batchLeaseTTL)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.resumeBatches(ctx)
+			}
+		}
+	}()
+
+	go func() {
+		ticker := time.NewTicker(storedResponseJanitorInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				n, err := s.batchStore.DeleteBatchFilesBefore(ctx, time.Now())
+				if err != nil {
+					slog.Debug("batch_file_janitor: sweep failed", "error", err.Error())
+				} else if n > 0 {
+					slog.Debug("batch_file_janitor: swept files", "removed", n)
+				}
+			}
+		}
+	}()
+}
+
+// resumeBatches starts every unfinished batch; those leased by another
+// instance are skipped by startBatch.
+func (s *Server) resumeBatches(ctx context.Context) {
+	batches, err := s.batchStore.ListBatchesByStatus(ctx,
+		service.BatchStatusValidating, service.BatchStatusInProgress,
+		service.BatchStatusFinalizing, service.BatchStatusCancelling)
+	if err != nil {
+		slog.Error("batches: list unfinished batches failed", "error", err.Error())
+	}
+	for _, b := range batches {
+		s.startBatch(b.ID)
+	}
+}
+
+// startBatch runs a batch in the background unless it is already running
+// on this instance or leased by another one.
+func (s *Server) startBatch(id string) {
+	server := s.ctx
+	if server == nil {
+		server = context.Background()
+	}
+	// parent ends when the server stops or the lease is lost; either way
+	// the batch is left for the next owner to resume.
+	parent, dropLease := context.WithCancel(server)
+	ctx, cancel := context.WithCancel(parent)
+	if _, running := s.batchRuns.LoadOrStore(id, cancel); running {
+		cancel()
+		dropLease()
+		return
+	}
+
+	go func() {
+		defer func() {
+			s.batchRuns.Delete(id)
+			cancel()
+			dropLease()
+		}()
+
+		claimed, err := s.batchStore.ClaimBatch(parent, id, s.instanceID, time.Now().Add(batchLeaseTTL))
+		if err != nil {
+			slog.Error("batches: claim failed", "batch_id", id, "error", err.Error())
+			return
+		}
+		if !claimed {
+			return // running on another instance
+		}
+		defer func() {
+			if err := s.batchStore.ReleaseBatch(context.WithoutCancel(server), id, s.instanceID); err != nil {
+				slog.Warn("batches: release lease failed", "batch_id", id, "error", err.Error())
+			}
+		}()
+
+		done := make(chan struct{})
+		defer close(done)
+		go s.renewBatchLease(parent, dropLease, id, done)
+
+		if err := s.runBatch(ctx, parent, id); err != nil {
+			slog.Error("batches: run failed", "batch_id", id, "error", err.Error())
+		}
+	}()
+}
+
+// renewBatchLease extends the run lease on batch id until done is closed,
+// calling drop when another instance has taken the batch over.
+func (s *Server) renewBatchLease(ctx context.Context, drop context.CancelFunc, id string, done <-chan struct{}) {
+	ticker := time.NewTicker(batchLeaseTTL / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		claimed, err := s.batchStore.ClaimBatch(ctx, id, s.instanceID, time.Now().Add(batchLeaseTTL))
+		if err != nil {
+			slog.Warn("batches: renew lease failed", "batch_id", id, "error", err.Error())
+			continue
+		}
+		if !claimed {
+			slog.Warn("batches: lease lost to another instance", "batch_id", id)
+			drop()
+			return
+		}
+	}
+}
+
+// runBatch drives one batch to a terminal status. ctx is cancelled by
+// CancelBatch; parent is the server context, whose cancellation leaves the
+// batch to be resumed on the next start.
+func (s *Server) runBatch(ctx, parent context.Context, id string) error {
+	b, err := s.batchStore.GetBatch(parent, id)
+	if err != nil || b == nil {
+		return err
+	}
+	input, err := s.batchStore.GetBatchFile(parent, b.InputFileID)
+	if err != nil {
+		return err
+	}
+	if input == nil {
+		return s.failBatch(parent, b, "input file no longer exists")
+	}
+	lines, err := parseBatchInput(input.Content, b.Endpoint)
+	if err != nil {
+		return s.failBatch(parent, b, err.Error())
+	}
+
+	if b.Status == service.BatchStatusValidating {
+		auth, msg, err := s.batchAuth(parent, b)
+		if err != nil {
+			return err
+		} else if msg != "" {
+			return s.failBatch(parent, b, msg)
+		}
+		now := time.Now()
+		b.Status = service.BatchStatusInProgress
+		b.InProgressAt = &now
+		// A provider batch gets the raw lines, so batches of tokens with
+		// guardrails or hooks run on the gateway workers where they apply.
+		if b.UpstreamBatchID == "" && !hasTokenGuardrails(auth) && len(tokenGatewayHookIDs(auth)) == 0 {
+			if target, ok := s.nativeBatchTarget(lines); ok && s.nativeBatchChecked(parent, auth, lines) {
+				if err := s.submitNativeBatch(parent, b, target, lines); err != nil {
+					slog.Warn("batches: provider batch API unavailable, running requests through the gateway",
+						"batch_id", b.ID, "provider", target.providerKey, "error", err.Error())
+				}
+			}
+		}
+		if err := s.batchStore.PutBatch(parent, *b); err != nil {
+			return err
+		}
+	}
+
+	if b.UpstreamBatchID != "" {
+		return s.pollNativeBatch(parent, b)
+	}
+	return s.runEmulatedBatch(ctx, parent, b, lines)
+}
+
+func (s *Server) failBatch(ctx context.Context, b *service.Batch, msg string) error {
+	now := time.Now()
+	b.Status = service.BatchStatusFailed
+	b.FailedAt = &now
+	b.ErrorMessage = msg
+	return s.batchStore.PutBatch(ctx, *b)
+}
+
+// batchResultLine renders one output/error file line.
+func batchResultLine(customID string, statusCode int, body any) []byte {
+	line, _ := json.Marshal(map[string]any{
+		"id":        "batch_req_" + ulid.Make().String(),
+		"custom_id": customID,
+		"response": map[string]any{
+			"status_code": statusCode,
+			"request_id":  "req_" + ulid.Make().String(),
+			"body":        body,
+		},
+		"error": nil,
+	})
+	return append(line, '\n')
+}
+
+// batchProgress accumulates the output and error files of a running batch.
+type batchProgress struct {
+	mu     sync.Mutex
+	output []byte
+	errors []byte
+	done   map[string]bool
+	dirty  bool
+}
+
+// loadBatchProgress picks up results flushed by an earlier run.
+func (s *Server) loadBatchProgress(ctx context.Context, b *service.Batch) (*batchProgress, error) {
+	p := &batchProgress{done: map[string]bool{}}
+	for _, ref := range []struct {
+		id  string
+		buf *[]byte
+	}{{b.OutputFileID, &p.output}, {b.ErrorFileID, &p.errors}} {
+		if ref.id == "" {
+			continue
+		}
+		f, err := s.batchStore.GetBatchFile(ctx, ref.id)
+		if err != nil {
+			return nil, err
+		}
+		if f == nil {
+			continue
+		}
+		*ref.buf = f.Content
+		for _, raw := range bytes.Split(f.Content, []byte("\n")) {
+			var line struct {
+				CustomID string `json:"custom_id"`
+			}
+			if json.Unmarshal(raw, &line) == nil && line.CustomID != "" {
+				p.done[line.CustomID] = true
+			}
+		}
+	}
+	return p, nil
+}
+
+func (p *batchProgress) add(customID string, line []byte, failed bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if failed {
+		p.errors = append(p.errors, line...)
+	} else {
+		p.output = append(p.output, line...)
+	}
+	p.done[customID] = true
+	p.dirty = true
+}
+
+// flush writes the output/error files and request counts. The stored
+// batch is re-read first so a cancel from another instance is noticed.
+func (s *Server) flushBatch(ctx context.Context, b *service.Batch, p *batchProgress) error {
+	p.mu.Lock()
+	output, errs, dirty := bytes.Clone(p.output), bytes.Clone(p.errors), p.dirty
+	p.dirty = false
+	p.mu.Unlock()
+
+	s.adoptBatchCancel(ctx, b)
+	if !dirty {
+		return s.batchStore.PutBatch(ctx, *b)
+	}
+
+	b.RequestCompleted = bytes.Count(output, []byte("\n"))
+	b.RequestFailed = bytes.Count(errs, []byte("\n"))
+	for _, f := range []struct {
+		id      *string
+		content []byte
+		suffix  string
+	}{{&b.OutputFileID, output, "output"}, {&b.ErrorFileID, errs, "errors"}} {
+		if len(f.content) == 0 {
+			continue
+		}
+		if *f.id == "" {
+			*f.id = "file-" + ulid.Make().String()
+		}
+		if err := s.batchStore.PutBatchFile(ctx, service.BatchFile{
+			ID:        *f.id,
+			TokenID:   b.TokenID,
+			Filename:  b.ID + "_" + f.suffix + ".jsonl",
+			Purpose:   batchOutputFilePurpose,
+			Content:   f.content,
+			CreatedAt: b.CreatedAt,
+			ExpiresAt: s.batchFileExpiry(time.Now()),
+		}); err != nil {
+			return err
+		}
+	}
+	return s.batchStore.PutBatch(ctx, *b)
+}
+
+// adoptBatchCancel picks up a cancel request stored by CancelBatch,
+// possibly on another instance, unless b already reached a final status.
+func (s *Server) adoptBatchCancel(ctx context.Context, b *service.Batch) {
+	if batchTerminal(b.Status) {
+		return
+	}
+	if stored, err := s.batchStore.GetBatch(ctx, b.ID); err == nil && stored != nil && stored.Status == service.BatchStatusCancelling {
+		b.Status = stored.Status
+		b.CancellingAt = stored.CancellingAt
+	}
+}
+
+// batchConcurrency is the worker count for one provider.
+func batchConcurrency(info ProviderInfo) int {
+	if info.rateLimit != nil && info.rateLimit.MaxConcurrent > 0 {
+		return info.rateLimit.MaxConcurrent
+	}
+	return defaultBatchConcurrency
+}
+
+// runEmulatedBatch executes the remaining requests of a batch through the
+// gateway's provider clients.
+func (s *Server) runEmulatedBatch(ctx, parent context.Context, b *service.Batch, lines []batchRequestLine) error {
+	progress, err := s.loadBatchProgress(parent, b)
+	if err != nil {
+		return err
+	}
+	// The token is reloaded at every flush so edits and revocation
+	// reach the running batch.
+	var auth atomic.Pointer[authResult]
+	if a, msg, err := s.batchAuth(parent, b); err != nil {
+		return err
+	} else if msg != "" {
+		return s.failBatch(parent, b, msg)
+	} else {
+		auth.Store(a)
+	}
+	var revoked string
+
+	runCtx, stop := context.WithDeadline(ctx, b.ExpiresAt)
+	defer stop()
+
+	if b.Status == service.BatchStatusCancelling {
+		stop()
+	}
+
+	// One queue per provider so each is drained at its own concurrency.
+	queues := map[string]chan batchRequestLine{}
+	workers := map[string]int{}
+	for _, line := range lines {
+		if progress.done[line.CustomID] {
+			continue
+		}
+		providerKey, _ := splitProviderModel(line.model)
+		if _, ok := queues[providerKey]; !ok {
+			queues[providerKey] = make(chan batchRequestLine, len(lines))
+			workers[providerKey] = defaultBatchConcurrency
+			if info, ok := s.getProviderInfo(providerKey); ok {
+				workers[providerKey] = batchConcurrency(info)
+			}
+		}
+		queues[providerKey] <- line
+	}
+
+	var wg sync.WaitGroup
+	for providerKey, queue := range queues {
+		close(queue)
+		for range workers[providerKey] {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for line := range queue {
+					if runCtx.Err() != nil {
+						return
+					}
+					status, body, ok := s.executeBatchRequest(runCtx, auth.Load(), line)
+					if !ok {
+						continue // interrupted; the request is retried on resume
+					}
+					progress.add(line.CustomID, batchResultLine(line.CustomID, status, body), status != http.StatusOK)
+				}
+			}()
+		}
+	}
+
+	finished := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(finished)
+	}()
+
+	ticker := time.NewTicker(batchFlushInterval)
+	defer ticker.Stop()
+	for running := true; running; {
+		select {
+		case <-finished:
+			running = false
+		case <-ticker.C:
+			if err := s.flushBatch(parent, b, progress); err != nil {
+				slog.Warn("batches: flush progress failed", "batch_id", b.ID, "error", err.Error())
+			}
+			if b.Status == service.BatchStatusCancelling {
+				stop()
+			}
+			if a, msg, err := s.batchAuth(parent, b); err != nil {
+				slog.Warn("batches: reload token failed", "batch_id", b.ID, "error", err.Error())
+			} else if msg != "" {
+				revoked = msg
+				stop()
+			} else {
+				auth.Store(a)
+			}
+		}
+	}
+
+	if parent.Err() != nil {
+		// Server shutting down: keep the batch in progress for resume.
+		return s.flushBatch(context.WithoutCancel(parent), b, progress)
+	}
+
+	now := time.Now()
+	switch {
+	case revoked != "" && b.Status != service.BatchStatusCancelling:
+		b.Status = service.BatchStatusFailed
+		b.FailedAt = &now
+		b.ErrorMessage = revoked
+	case b.Status == service.BatchStatusCancelling || (ctx.Err() != nil && !errors.Is(runCtx.Err(), context.DeadlineExceeded)):
+		b.Status = service.BatchStatusCancelled
+		b.CancelledAt = &now
+	case runCtx.Err() != nil:
+		b.Status = service.BatchStatusExpired
+		b.ExpiredAt = &now
+	default:
+		b.Status = service.BatchStatusFinalizing
+		b.FinalizingAt = &now
+		if err := s.flushBatch(parent, b, progress); err != nil {
+			return err
+		}
+		if b.Status == service.BatchStatusCancelling {
+			// Cancelled while the last requests finished; nothing left to stop.
+			b.Status = service.BatchStatusCancelled
+			b.CancelledAt = &now
+		} else {
+			b.Status = service.BatchStatusCompleted
+			b.CompletedAt = &now
+		}
+	}
+	progress.dirty = true
+	return s.flushBatch(parent, b, progress)
+}
+
+// executeBatchRequest runs one chat completion request. ok is false when
+// the batch was stopped before the request finished.
+func (s *Server) executeBatchRequest(ctx context.Context, auth *authResult, line batchRequestLine) (int, any, bool) {
+	var req ChatCompletionRequest
+	if err := json.Unmarshal(line.Body, &req); err != nil {
+		return http.StatusBadRequest, map[string]any{"error": map[string]any{
+			"message": fmt.Sprintf("invalid request body: %v", err),
+			"type":    "invalid_request_error",
+		}}, true
+	}
+
+	hooks, _, err := s.applyRequestHooks(ctx, auth, &req)
+	if err != nil {
+		status, body := classifyGatewayError(err)
+		return status, body, true
+	}
+
+	// Limits are checked per request: a batch must not run past the
+	// token's budget just because it was under it at creation.
+	if limitMessage, limitErr := s.checkTokenLimits(ctx, auth); limitErr != nil {
+		slog.Error("token limit check failed", "error", limitErr)
+	} else if limitMessage != "" {
+		return http.StatusTooManyRequests, map[string]any{"error": map[string]any{
+			"message": limitMessage,
+			"type":    "tokens",
+			"code":    "rate_limit_exceeded",
+		}}, true
+	}
+
+	call, status, body := s.prepareBatchRequest(ctx, auth, &req)
+	if status != 0 {
+		return status, body, true
+	}
+	target, messages, tools, baseOpts := call.target, call.messages, call.tools, call.opts
+
+	for {
+		start := time.Now()
+		resp, err := chatChoices(ctx, target.info.provider, cloneChatOptions(baseOpts),
+			func(ctx context.Context, opts *service.ChatOptions) (*service.LLMResponse, error) {
+				return callWithGatewayRetry(ctx, target.providerKey, target.actualModel, target.info.RetryAfterCap(),
+					func(ctx context.Context) (*service.LLMResponse, error) {
+						return target.info.provider.Chat(ctx, target.actualModel, messages, tools, opts)
+					})
+			})
+		latency := time.Since(start).Milliseconds()
+
+		if ctx.Err() != nil {
+			return 0, nil, false
+		}
+		var limiterErr *ratelimit.Error
+		if errors.As(err, &limiterErr) {
+			// The provider's own rate limiter is saturated; wait for capacity.
+			select {
+			case <-ctx.Done():
+				return 0, nil, false
+			case <-time.After(batchLimiterBackoff):
+			}
+			continue
+		}
+		audit := llmAuditParams{
+			auth: auth, source: "gateway", endpoint: line.URL, userField: req.User,
+			requestBody: line.Body, requestedModel: req.Model, fullModel: target.fullModel,
+			latencyMs: latency, metadata: map[string]any{"batch_custom_id": line.CustomID},
+		}
+		if err != nil {
+			s.recordUsageAsync(ctx, auth, target.fullModel, choicesUsage(err), latency, "error", classifyHTTPError(err), err.Error())
+			audit.usage, audit.status = choicesUsage(err), "error"
+			audit.errCode, audit.errMsg = classifyHTTPError(err), err.Error()
+			s.recordLLMCallAsync(ctx, audit)
+			status, body := classifyGatewayError(err)
+			return status, body, true
+		}
+
+		s.recordUsageAsync(ctx, auth, target.fullModel, resp.Usage, latency, "ok", "", "")
+		chatResp, err := s.applyResponseHooks(ctx, auth, hooks, &req, buildOpenAIResponse(generateChatID(), target.fullModel, resp))
+		if err != nil {
+			status, body := classifyGatewayError(err)
+			return status, body, true
+		}
+		audit.usage, audit.status = resp.Usage, "ok"
+		audit.responseBody, _ = json.Marshal(chatResp)
+		audit.finishReason = chatRespFinishReason(chatResp)
+		s.recordLLMCallAsync(ctx, audit)
+		return http.StatusOK, chatResp, true
+	}
+}
+
+// batchCall is a batch request resolved to its target and the provider
+// inputs built from it.
+type batchCall struct {
+	target   chatCallTarget
+	messages []service.Message
+	tools    []service.Tool
+	opts     *service.ChatOptions
+}
+
+// prepareBatchRequest resolves req to its target and runs the checks a
+// request passes before it is sent: the model, n, the model's
+// capabilities and the n budget. A non-zero status is the rejection.
+func (s *Server) prepareBatchRequest(ctx context.Context, auth *authResult, req *ChatCompletionRequest) (batchCall, int, any) {
+	target := s.chatCallChain(auth, req.Model, nil)[0]
+	if target.err != nil {
+		return batchCall{}, http.StatusNotFound, map[string]any{"error": map[string]any{
+			"message": target.err.Error(),
+			"type":    "invalid_request_error",
+			"param":   "model",
+			"code":    "model_not_found",
+		}}
+	}
+
+	if msg := checkChoicesTargets(req.N, false, target); msg != "" {
+		return batchCall{}, http.StatusBadRequest, map[string]any{"error": map[string]any{
+			"message": msg,
+			"type":    "invalid_request_error",
+			"param":   "n",
+		}}
+	}
+
+	messages, tools := s.buildProviderMessages(target.info.providerType, req.Messages, req.Tools)
+	opts := cloneChatOptions(buildChatOptions(req))
+	if err := s.adaptToModelCapabilities(ctx, &target, messages, tools, opts, false); err != nil {
+		status, body := classifyGatewayError(err)
+		return batchCall{}, status, body
+	}
+	if limitMessage, limitErr := s.checkChoicesBudget(ctx, auth, target, messages, tools, opts); limitErr != nil {
+		slog.Error("token limit check failed", "error", limitErr)
+	} else if limitMessage != "" {
+		return batchCall{}, http.StatusTooManyRequests, map[string]any{"error": map[string]any{
+			"message": limitMessage,
+			"type":    "tokens",
+			"code":    "rate_limit_exceeded",
+		}}
+	}
+
+	return batchCall{target: target, messages: messages, tools: tools, opts: opts}, 0, nil
+}
+
+// ─── Provider-native batches ───
+
+type batchProxier interface {
+	Proxy(w http.ResponseWriter, r *http.Request, path string) error
+}
+
+// nativeBatchTarget reports the provider to hand the batch to: every
+// request must target the same OpenAI-type provider using API-key auth
+// and no gateway hooks.
+func (s *Server) nativeBatchTarget(lines []batchRequestLine) (chatCallTarget, bool) {
+	var target chatCallTarget
+	for i, line := range lines {
+		providerKey, actualModel := splitProviderModel(line.model)
+		if i == 0 {
+			info, ok := s.getProviderInfo(providerKey)
+			if !ok || info.providerType != "openai" || info.authType != "" || len(info.hookIDs) > 0 {
+				return chatCallTarget{}, false
+			}
+			if _, ok := info.provider.(batchProxier); !ok {
+				return chatCallTarget{}, false
+			}
+			target = chatCallTarget{fullModel: line.model, providerKey: providerKey, actualModel: actualModel, info: info}
+			continue
+		}
+		if providerKey != target.providerKey {
+			return chatCallTarget{}, false
+		}
+	}
+	return target, true
+}
+
+// nativeBatchChecked runs the checks of the gateway workers on every
+// request before the batch goes to the provider. A batch with a request
+// they would reject runs on the workers, which report it on its line.
+func (s *Server) nativeBatchChecked(ctx context.Context, auth *authResult, lines []batchRequestLine) bool {
+	if limitMessage, limitErr := s.checkTokenLimits(ctx, auth); limitErr != nil || limitMessage != "" {
+		return false
+	}
+	for _, line := range lines {
+		var req ChatCompletionRequest
+		if err := json.Unmarshal(line.Body, &req); err != nil {
+			return false
+		}
+		if _, status, _ := s.prepareBatchRequest(ctx, auth, &req); status != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// nativeBatchCall sends one request to the provider API through its
+// proxy, which injects the provider credentials.
+func (s *Server) nativeBatchCall(ctx context.Context, providerKey, method, path, contentType string, body []byte) ([]byte, error) {
+	info, ok := s.getProviderInfo(providerKey)
+	if !ok {
+		return nil, fmt.Errorf("provider %q not found", providerKey)
+	}
+	proxier, ok := info.provider.(batchProxier)
+	if !ok {
+		return nil, fmt.Errorf("provider %q does not support proxying", providerKey)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, "http://gateway"+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	cw := newCaptureWriter()
+	if err := proxier.Proxy(cw, req, path); err != nil {
+		return nil, err
+	}
+	if cw.statusCode >= http.StatusMultipleChoices {
+		msg := string(cw.body)
+		if len(msg) > 300 {
+			msg = msg[:300]
+		}
+		return nil, fmt.Errorf("%s %s: upstream status %d: %s", method, path, cw.statusCode, msg)
+	}
+	return cw.body, nil
+}
+
+// submitNativeBatch uploads the requests (with provider prefixes removed
+// from the model) to the provider and creates the provider batch.
+func (s *Server) submitNativeBatch(ctx context.Context, b *service.Batch, target chatCallTarget, lines []batchRequestLine) error {
+	var jsonl bytes.Buffer
+	for _, line := range lines {
+		var body map[string]any
+		if err := json.Unmarshal(line.Body, &body); err != nil {
+			return err
+		}
+		_, body["model"] = splitProviderModel(line.model)
+		raw, err := json.Marshal(map[string]any{"custom_id": line.CustomID, "method": http.MethodPost, "url": b.Endpoint, "body": body})
+		if err != nil {
+			return err
+		}
+		jsonl.Write(raw)
+		jsonl.WriteByte('\n')
+	}
+
+	form, contentType, err := multipartBatchFile(b.ID+".jsonl", jsonl.Bytes())
+	if err != nil {
+		return err
+	}
+	raw, err := s.nativeBatchCall(ctx, target.providerKey, http.MethodPost, "/v1/files", contentType, form)
+	if err != nil {
+		return err
+	}
+	var file struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(raw, &file); err != nil || file.ID == "" {
+		return fmt.Errorf("unexpected file upload response: %s", raw)
+	}
+
+	create, _ := json.Marshal(map[string]any{
+		"input_file_id":     file.ID,
+		"endpoint":          b.Endpoint,
+		"completion_window": b.CompletionWindow,
+	})
+	raw, err = s.nativeBatchCall(ctx, target.providerKey, http.MethodPost, "/v1/batches", "application/json", create)
+	if err != nil {
+		return err
+	}
+	var upstream struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(raw, &upstream); err != nil || upstream.ID == "" {
+		return fmt.Errorf("unexpected batch create response: %s", raw)
+	}
+
+	b.ProviderKey = target.providerKey
+	b.UpstreamBatchID = upstream.ID
+	return nil
+}
+
+// multipartBatchFile builds a Files API upload form with purpose "batch".
+func multipartBatchFile(filename string, content []byte) ([]byte, string, error) {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	if err := mw.WriteField("purpose", batchFilePurpose); err != nil {
+		return nil, "", err
+	}
+	part, err := mw.CreateFormFile("file", filename)
+	if err != nil {
+		return nil, "", err
+	}
+	if _, err := part.Write(content); err != nil {
+		return nil, "", err
+	}
+	if err := mw.Close(); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), mw.FormDataContentType(), nil
+}
+
+func (s *Server) cancelNativeBatch(ctx context.Context, b *service.Batch) error {
+	_, err := s.nativeBatchCall(ctx, b.ProviderKey, http.MethodPost, "/v1/batches/"+b.UpstreamBatchID+"/cancel", "application/json", nil)
+	return err
+}
+
+// upstreamBatch is the subset of the provider batch object the gateway
+// mirrors.
+type upstreamBatch struct {
+	Status        string `json:"status"`
+	OutputFileID  string `json:"output_file_id"`
+	ErrorFileID   string `json:"error_file_id"`
+	RequestCounts struct {
+		Total     int `json:"total"`
+		Completed int `json:"completed"`
+		Failed    int `json:"failed"`
+	} `json:"request_counts"`
+	Errors *struct {
+		Data []struct {
+			Message string `json:"message"`
+		} `json:"data"`
+	} `json:"errors"`
+}
+
+// pollNativeBatch mirrors the provider batch until it finishes, then
+// copies its output and error files and records usage.
+func (s *Server) pollNativeBatch(ctx context.Context, b *service.Batch) error {
+	ticker := time.NewTicker(nativeBatchPollInterval)
+	defer ticker.Stop()
+	for {
+		done, err := s.syncNativeBatch(ctx, b)
+		if err != nil {
+			slog.Warn("batches: poll provider batch failed", "batch_id", b.ID, "upstream_batch_id", b.UpstreamBatchID, "error", err.Error())
+		}
+		if done {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil // resumed on the next start
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Server) syncNativeBatch(ctx context.Context, b *service.Batch) (bool, error) {
+	raw, err := s.nativeBatchCall(ctx, b.ProviderKey, http.MethodGet, "/v1/batches/"+b.UpstreamBatchID, "", nil)
+	if err != nil {
+		return false, err
+	}
+	var up upstreamBatch
+	if err := json.Unmarshal(raw, &up); err != nil {
+		return false, fmt.Errorf("decode provider batch: %w", err)
+	}
+
+	s.adoptBatchCancel(ctx, b)
+	b.RequestCompleted = up.RequestCounts.Completed
+	b.RequestFailed = up.RequestCounts.Failed
+
+	now := time.Now()
+	if !batchTerminal(up.Status) {
+		if up.Status == service.BatchStatusFinalizing && b.FinalizingAt == nil {
+			b.Status = service.BatchStatusFinalizing
+			b.FinalizingAt = &now
+		}
+		return false, s.batchStore.PutBatch(ctx, *b)
+	}
+
+	auth := s.batchUsageAuth(ctx, b)
+	for _, ref := range []struct {
+		upstreamID string
+		id         *string
+		suffix     string
+	}{{up.OutputFileID, &b.OutputFileID, "output"}, {up.ErrorFileID, &b.ErrorFileID, "errors"}} {
+		if ref.upstreamID == "" {
+			continue
+		}
+		content, err := s.nativeBatchCall(ctx, b.ProviderKey, http.MethodGet, "/v1/files/"+ref.upstreamID+"/content", "", nil)
+		if err != nil {
+			return false, err
+		}
+		if ref.suffix == "output" {
+			s.recordNativeBatchUsage(ctx, auth, b.ProviderKey, content)
+		}
+		*ref.id = "file-" + ulid.Make().String()
+		if err := s.batchStore.PutBatchFile(ctx, service.BatchFile{
+			ID:        *ref.id,
+			TokenID:   b.TokenID,
+			Filename:  b.ID + "_" + ref.suffix + ".jsonl",
+			Purpose:   batchOutputFilePurpose,
+			Content:   content,
+			CreatedAt: now,
+			ExpiresAt: s.batchFileExpiry(now),
+		}); err != nil {
+			return false, err
+		}
+	}
+
+	b.Status = up.Status
+	switch up.Status {
+	case service.BatchStatusCompleted:
+		b.CompletedAt = &now
+	case service.BatchStatusFailed:
+		b.FailedAt = &now
+		if up.Errors != nil && len(up.Errors.Data) > 0 {
+			b.ErrorMessage = up.Errors.Data[0].Message
+		}
+	case service.BatchStatusExpired:
+		b.ExpiredAt = &now
+	case service.BatchStatusCancelled:
+		b.CancelledAt = &now
+	}
+	return true, s.batchStore.PutBatch(ctx, *b)
+}
+
+// recordNativeBatchUsage records usage for each successful line of a
+// provider batch output file at the batch price.
+func (s *Server) recordNativeBatchUsage(ctx context.Context, auth *authResult, providerKey string, output []byte) {
+	for _, raw := range bytes.Split(output, []byte("\n")) {
+		var line struct {
+			Response struct {
+				StatusCode int `json:"status_code"`
+				Body       struct {
+					Model string `json:"model"`
+					Usage struct {
+						PromptTokens        int `json:"prompt_tokens"`
+						CompletionTokens    int `json:"completion_tokens"`
+						PromptTokensDetails struct {
+							CachedTokens int `json:"cached_tokens"`
+						} `json:"prompt_tokens_details"`
+					} `json:"usage"`
+				} `json:"body"`
+			} `json:"response"`
+		}
+		if json.Unmarshal(raw, &line) != nil || line.Response.StatusCode != http.StatusOK {
+			continue
+		}
+		u := line.Response.Body.Usage
+		usage := service.Usage{
+			PromptTokens:     max(u.PromptTokens-u.PromptTokensDetails.CachedTokens, 0),
+			CompletionTokens: u.CompletionTokens,
+			CacheReadTokens:  u.PromptTokensDetails.CachedTokens,
+		}
+		s.recordScaledUsageAsync(ctx, auth, providerKey+"/"+line.Response.Body.Model, usage, nativeBatchCostScale, 0, "ok", "", "")
+	}
+}
@@ -0,0 +1,730 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/worldline-go/types"
+
+	"github.com/rakunlabs/at/internal/config"
+	"github.com/rakunlabs/at/internal/service"
+	"github.com/rakunlabs/at/internal/service/ratelimit"
+)
+
+// memoryBatchStore is an in-memory BatchStorer for tests.
+type memoryBatchStore struct {
+	mu      sync.Mutex
+	files   map[string]service.BatchFile
+	batches map[string]service.Batch
+	leases  map[string]batchLease
+}
+
+type batchLease struct {
+	owner string
+	until time.Time
+}
+
+func newMemoryBatchStore() *memoryBatchStore {
+	return &memoryBatchStore{files: map[string]service.BatchFile{}, batches: map[string]service.Batch{}, leases: map[string]batchLease{}}
+}
+
+func (m *memoryBatchStore) GetBatchFile(_ context.Context, id string) (*service.BatchFile, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f, ok := m.files[id]
+	if !ok {
+		return nil, nil
+	}
+	return &f, nil
+}
+
+func (m *memoryBatchStore) ListBatchFiles(_ context.Context, tokenID string) ([]service.BatchFile, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []service.BatchFile
+	for _, f := range m.files {
+		if f.TokenID == tokenID {
+			f.Content = nil
+			out = append(out, f)
+		}
+	}
+	return out, nil
+}
+
+func (m *memoryBatchStore) PutBatchFile(_ context.Context, file service.BatchFile) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	file.Bytes = int64(len(file.Content))
+	m.files[file.ID] = file
+	return nil
+}
+
+func (m *memoryBatchStore) DeleteBatchFile(_ context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.files, id)
+	return nil
+}
+
+func (m *memoryBatchStore) DeleteBatchFilesBefore(context.Context, time.Time) (int64, error) {
+	return 0, nil
+}
+
+func (m *memoryBatchStore) GetBatch(_ context.Context, id string) (*service.Batch, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	b, ok := m.batches[id]
+	if !ok {
+		return nil, nil
+	}
+	return &b, nil
+}
+
+func (m *memoryBatchStore) ListBatches(_ context.Context, tokenID string) ([]service.Batch, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []service.Batch
+	for _, b := range m.batches {
+		if b.TokenID == tokenID {
+			out = append(out, b)
+		}
+	}
+	return out, nil
+}
+
+func (m *memoryBatchStore) ListBatchesByStatus(_ context.Context, statuses ...string) ([]service.Batch, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []service.Batch
+	for _, b := range m.batches {
+		for _, st := range statuses {
+			if b.Status == st {
+				out = append(out, b)
+			}
+		}
+	}
+	return out, nil
+}
+
+func (m *memoryBatchStore) PutBatch(_ context.Context, b service.Batch) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.batches[b.ID] = b
+	return nil
+}
+
+func (m *memoryBatchStore) ClaimBatch(_ context.Context, id, owner string, until time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if l, ok := m.leases[id]; ok && l.owner != owner && l.until.After(time.Now()) {
+		return false, nil
+	}
+	m.leases[id] = batchLease{owner: owner, until: until}
+	return true, nil
+}
+
+func (m *memoryBatchStore) ReleaseBatch(_ context.Context, id, owner string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.leases[id].owner == owner {
+		delete(m.leases, id)
+	}
+	return nil
+}
+
+// batchTestProvider answers "echo", fails requests
+// containing "fail", reports a saturated limiter once, and tracks its
+// peak concurrency.
+type batchTestProvider struct {
+	inFlight, peak atomic.Int32
+	limited        atomic.Bool
+}
+
+func (p *batchTestProvider) Chat(_ context.Context, _ string, messages []service.Message, _ []service.Tool, _ *service.ChatOptions) (*service.LLMResponse, error) {
+	n := p.inFlight.Add(1)
+	defer p.inFlight.Add(-1)
+	for {
+		peak := p.peak.Load()
+		if n <= peak || p.peak.CompareAndSwap(peak, n) {
+			break
+		}
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	if p.limited.CompareAndSwap(false, true) {
+		return nil, &ratelimit.Error{Reason: ratelimit.ReasonConcurrent, Wait: time.Second, Underlying: context.DeadlineExceeded}
+	}
+	text := fmt.Sprint(messages[len(messages)-1].Content)
+	if strings.Contains(text, "fail") {
+		return nil, fmt.Errorf("upstream exploded")
+	}
+	return &service.LLMResponse{Content: "echo", Finished: true, Usage: service.Usage{PromptTokens: 3, CompletionTokens: 1}}, nil
+}
+
+func doBatchRequest(s *Server, method, path string, body io.Reader, contentType string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, body)
+	req.Header.Set("Authorization", "Bearer test-token")
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	rest := strings.TrimPrefix(req.URL.Path, "/gateway/v1/")
+	kind, rest, _ := strings.Cut(rest, "/")
+	id, sub, _ := strings.Cut(rest, "/")
+	req.SetPathValue("id", id)
+	rec := httptest.NewRecorder()
+	switch {
+	case kind == "files" && method == http.MethodPost:
+		s.UploadFile(rec, req)
+	case kind == "files" && id == "":
+		s.ListFiles(rec, req)
+	case kind == "files" && sub == "content":
+		s.GetFileContent(rec, req)
+	case kind == "files" && method == http.MethodDelete:
+		s.DeleteFile(rec, req)
+	case kind == "files":
+		s.GetFile(rec, req)
+	case sub == "cancel":
+		s.CancelBatch(rec, req)
+	case method == http.MethodPost:
+		s.CreateBatch(rec, req)
+	case id == "":
+		s.ListBatches(rec, req)
+	default:
+		s.GetBatch(rec, req)
+	}
+	return rec
+}
+
+func uploadBatchFile(t *testing.T, s *Server, jsonl string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	_ = mw.WriteField("purpose", "batch")
+	part, _ := mw.CreateFormFile("file", "input.jsonl")
+	_, _ = part.Write([]byte(jsonl))
+	_ = mw.Close()
+
+	rec := doBatchRequest(s, http.MethodPost, "/gateway/v1/files", &buf, mw.FormDataContentType())
+	if rec.Code != http.StatusOK {
+		t.Fatalf("upload status = %d; body=%s", rec.Code, rec.Body.String())
+	}
+	var f struct {
+		ID string `json:"id"`
+	}
+	_ = json.Unmarshal(rec.Body.Bytes(), &f)
+	return f.ID
+}
+
+func batchLine(customID, model, text string) string {
+	return fmt.Sprintf(`{"custom_id":%q,"method":"POST","url":"/v1/chat/completions","body":{"model":%q,"messages":[{"role":"user","content":%q}]}}`, customID, model, text) + "\n"
+}
+
+// waitBatch polls until the batch reaches a final status.
+func waitBatch(t *testing.T, s *Server, id string) map[string]any {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		rec := doBatchRequest(s, http.MethodGet, "/gateway/v1/batches/"+id, nil, "")
+		var b map[string]any
+		_ = json.Unmarshal(rec.Body.Bytes(), &b)
+		if batchTerminal(fmt.Sprint(b["status"])) {
+			return b
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("batch %s did not finish", id)
+	return nil
+}
+
+func withFastBatchTimers(t *testing.T) {
+	flush, backoff, poll := batchFlushInterval, batchLimiterBackoff, nativeBatchPollInterval
+	batchFlushInterval, batchLimiterBackoff, nativeBatchPollInterval = 10*time.Millisecond, time.Millisecond, 10*time.Millisecond
+	t.Cleanup(func() {
+		batchFlushInterval, batchLimiterBackoff, nativeBatchPollInterval = flush, backoff, poll
+	})
+}
+
+func TestBatchRunsRequestsThroughGateway(t *testing.T) {
+	withFastBatchTimers(t)
+	provider := &batchTestProvider{}
+	s := newMessagesTestServer(provider, "anthropic")
+	s.providers["gemini"] = ProviderInfo{provider: provider, providerType: "anthropic", rateLimit: &config.RateLimitConfig{MaxConcurrent: 2}}
+	s.batchStore = newMemoryBatchStore()
+	obs := &fakeLLMCallStore{}
+	s.llmCallStore = obs
+
+	var jsonl strings.Builder
+	for i := range 6 {
+		jsonl.WriteString(batchLine(fmt.Sprintf("req-%d", i), "gemini/claude", "hello"))
+	}
+	jsonl.WriteString(batchLine("req-fail", "gemini/claude", "please fail"))
+	fileID := uploadBatchFile(t, s, jsonl.String())
+
+	rec := doBatchRequest(s, http.MethodPost, "/gateway/v1/batches",
+		strings.NewReader(`{"input_file_id":"`+fileID+`","endpoint":"/v1/chat/completions","completion_window":"24h","metadata":{"job":"t"}}`), "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("create status = %d; body=%s", rec.Code, rec.Body.String())
+	}
+	var created map[string]any
+	_ = json.Unmarshal(rec.Body.Bytes(), &created)
+
+	final := waitBatch(t, s, created["id"].(string))
+	counts := final["request_counts"].(map[string]any)
+	if final["status"] != "completed" || counts["total"] != float64(7) || counts["completed"] != float64(6) || counts["failed"] != float64(1) {
+		t.Fatalf("final batch = %+v", final)
+	}
+	if peak := provider.peak.Load(); peak > 2 {
+		t.Errorf("peak concurrency = %d, want <= 2 (rate_limit.max_concurrent)", peak)
+	}
+
+	// Every request is in the LLM call audit, tagged with its line.
+	failed := 0
+	for _, call := range waitForObservations(t, obs, 7) {
+		if call.Endpoint != "/v1/chat/completions" || call.Metadata["batch_custom_id"] == nil {
+			t.Errorf("audit entry = %+v", call)
+		}
+		if call.Status == "error" {
+			failed++
+		}
+	}
+	if failed != 1 {
+		t.Errorf("audit error entries = %d, want 1", failed)
+	}
+
+	rec = doBatchRequest(s, http.MethodGet, "/gateway/v1/files/"+final["output_file_id"].(string)+"/content", nil, "")
+	if lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n"); len(lines) != 6 || !strings.Contains(lines[0], `"status_code":200`) || !strings.Contains(lines[0], `"content":"echo"`) {
+		t.Fatalf("output file = %s", rec.Body.String())
+	}
+	rec = doBatchRequest(s, http.MethodGet, "/gateway/v1/files/"+final["error_file_id"].(string)+"/content", nil, "")
+	if !strings.Contains(rec.Body.String(), `"custom_id":"req-fail"`) || !strings.Contains(rec.Body.String(), "upstream exploded") {
+		t.Fatalf("error file = %s", rec.Body.String())
+	}
+
+	// Finished batches cannot be cancelled.
+	if rec := doBatchRequest(s, http.MethodPost, "/gateway/v1/batches/"+created["id"].(string)+"/cancel", nil, ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("cancel finished batch status = %d", rec.Code)
+	}
+}
+
+func TestBatchCreateValidatesInput(t *testing.T) {
+	s := newMessagesTestServer(&batchTestProvider{}, "anthropic")
+	s.batchStore = newMemoryBatchStore()
+
+	for name, jsonl := range map[string]string{
+		"unknown provider": batchLine("a", "missing/model", "hi"),
+		"duplicate id":     batchLine("a", "gemini/claude", "hi") + batchLine("a", "gemini/claude", "hi"),
+		"wrong url":        strings.Replace(batchLine("a", "gemini/claude", "hi"), "/v1/chat/completions", "/v1/embeddings", 1),
+	} {
+		fileID := uploadBatchFile(t, s, jsonl)
+		rec := doBatchRequest(s, http.MethodPost, "/gateway/v1/batches",
+			strings.NewReader(`{"input_file_id":"`+fileID+`","endpoint":"/v1/chat/completions"}`), "")
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d; body=%s", name, rec.Code, rec.Body.String())
+		}
+	}
+
+	rec := doBatchRequest(s, http.MethodPost, "/gateway/v1/batches",
+		strings.NewReader(`{"input_file_id":"file-missing","endpoint":"/v1/chat/completions"}`), "")
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("missing file status = %d", rec.Code)
+	}
+}
+
+// nativeBatchProvider fakes an OpenAI account with a batch API.
+type nativeBatchProvider struct {
+	batchTestProvider
+	mu       sync.Mutex
+	uploaded string
+}
+
+func (p *nativeBatchProvider) Proxy(w http.ResponseWriter, r *http.Request, path string) error {
+	switch {
+	case r.Method == http.MethodPost && path == "/v1/files":
+		_ = r.ParseMultipartForm(1 << 20)
+		f, _, _ := r.FormFile("file")
+		content, _ := io.ReadAll(f)
+		p.mu.Lock()
+		p.uploaded = string(content)
+		p.mu.Unlock()
+		_, _ = w.Write([]byte(`{"id":"file-up-in"}`))
+	case r.Method == http.MethodPost && path == "/v1/batches":
+		_, _ = w.Write([]byte(`{"id":"batch_up","status":"validating"}`))
+	case path == "/v1/batches/batch_up":
+		_, _ = w.Write([]byte(`{"id":"batch_up","status":"completed","output_file_id":"file-up-out","request_counts":{"total":1,"completed":1,"failed":0}}`))
+	case path == "/v1/files/file-up-out/content":
+		_, _ = w.Write([]byte(`{"id":"batch_req_1","custom_id":"a","response":{"status_code":200,"body":{"model":"gpt-4o-mini","usage":{"prompt_tokens":10,"completion_tokens":2}}},"error":null}` + "\n"))
+	default:
+		http.NotFound(w, r)
+	}
+	return nil
+}
+
+func TestBatchUsesProviderBatchAPI(t *testing.T) {
+	withFastBatchTimers(t)
+	provider := &nativeBatchProvider{}
+	s := newMessagesTestServer(provider, "openai")
+	store := newMemoryBatchStore()
+	s.batchStore = store
+
+	fileID := uploadBatchFile(t, s, batchLine("a", "gemini/gpt-4o-mini", "hi"))
+	rec := doBatchRequest(s, http.MethodPost, "/gateway/v1/batches",
+		strings.NewReader(`{"input_file_id":"`+fileID+`","endpoint":"/v1/chat/completions"}`), "")
+	var created map[string]any
+	_ = json.Unmarshal(rec.Body.Bytes(), &created)
+
+	final := waitBatch(t, s, created["id"].(string))
+	if final["status"] != "completed" {
+		t.Fatalf("final batch = %+v", final)
+	}
+	stored, _ := store.GetBatch(context.Background(), created["id"].(string))
+	if stored.UpstreamBatchID != "batch_up" || stored.ProviderKey != "gemini" {
+		t.Errorf("stored batch = %+v", stored)
+	}
+	provider.mu.Lock()
+	uploaded := provider.uploaded
+	provider.mu.Unlock()
+	if !strings.Contains(uploaded, `"model":"gpt-4o-mini"`) || strings.Contains(uploaded, "gemini/") {
+		t.Errorf("uploaded input = %s", uploaded)
+	}
+	if provider.peak.Load() != 0 {
+		t.Error("requests ran through the gateway instead of the provider batch API")
+	}
+
+	rec = doBatchRequest(s, http.MethodGet, "/gateway/v1/files/"+final["output_file_id"].(string)+"/content", nil, "")
+	if !strings.Contains(rec.Body.String(), `"custom_id":"a"`) {
+		t.Errorf("output file = %s", rec.Body.String())
+	}
+}
+
+func TestBatchChecksRequestsBeforeProviderBatchAPI(t *testing.T) {
+	withFastBatchTimers(t)
+	provider := &nativeBatchProvider{}
+	s := newMessagesTestServer(provider, "openai")
+	s.tokenStore = gatewayTestToken("test-token", service.APIToken{
+		ID:                   "tok-1",
+		AllowedProvidersMode: service.AccessModeAll,
+		AllowedModelsMode:    service.AccessModeAll,
+		TotalTokenLimit:      types.NewNull(int64(100)),
+	})
+	s.tokenUsageStore = &countingUsageStore{}
+	s.batchStore = newMemoryBatchStore()
+
+	// Line b asks for more choices than the token has left.
+	fileID := uploadBatchFile(t, s, batchLine("a", "gemini/gpt-4o-mini", "hi")+
+		`{"custom_id":"b","method":"POST","url":"/v1/chat/completions","body":{"model":"gemini/gpt-4o-mini","n":4,"max_tokens":50,"messages":[{"role":"user","content":"hi"}]}}`+"\n")
+	rec := doBatchRequest(s, http.MethodPost, "/gateway/v1/batches",
+		strings.NewReader(`{"input_file_id":"`+fileID+`","endpoint":"/v1/chat/completions"}`), "")
+	var created map[string]any
+	_ = json.Unmarshal(rec.Body.Bytes(), &created)
+
+	final := waitBatch(t, s, created["id"].(string))
+	counts := final["request_counts"].(map[string]any)
+	if final["status"] != "completed" || counts["completed"] != float64(1) || counts["failed"] != float64(1) {
+		t.Fatalf("final batch = %+v", final)
+	}
+	provider.mu.Lock()
+	uploaded := provider.uploaded
+	provider.mu.Unlock()
+	if uploaded != "" {
+		t.Errorf("a batch with a rejected request was sent to the provider batch API: %s", uploaded)
+	}
+	rec = doBatchRequest(s, http.MethodGet, "/gateway/v1/files/"+final["error_file_id"].(string)+"/content", nil, "")
+	if !strings.Contains(rec.Body.String(), `"custom_id":"b"`) || !strings.Contains(rec.Body.String(), `"status_code":429`) {
+		t.Fatalf("error file = %s", rec.Body.String())
+	}
+}
+
+func TestBatchFilesAreScopedToToken(t *testing.T) {
+	s := newMessagesTestServer(&batchTestProvider{}, "anthropic")
+	store := newMemoryBatchStore()
+	s.batchStore = store
+	_ = store.PutBatchFile(context.Background(), service.BatchFile{ID: "file-other", TokenID: "tok-other", Purpose: "batch"})
+
+	if rec := doBatchRequest(s, http.MethodGet, "/gateway/v1/files/file-other", nil, ""); rec.Code != http.StatusNotFound {
+		t.Errorf("other token's file status = %d", rec.Code)
+	}
+
+	fileID := uploadBatchFile(t, s, batchLine("a", "gemini/claude", "hi"))
+	rec := doBatchRequest(s, http.MethodGet, "/gateway/v1/files", nil, "")
+	if !strings.Contains(rec.Body.String(), fileID) || strings.Contains(rec.Body.String(), "file-other") {
+		t.Errorf("list = %s", rec.Body.String())
+	}
+	if rec := doBatchRequest(s, http.MethodDelete, "/gateway/v1/files/"+fileID, nil, ""); !strings.Contains(rec.Body.String(), `"deleted":true`) {
+		t.Errorf("delete = %s", rec.Body.String())
+	}
+}
+
+func TestBatchCancelStopsRemainingRequests(t *testing.T) {
+	withFastBatchTimers(t)
+	provider := &batchTestProvider{}
+	provider.limited.Store(true)
+	s := newMessagesTestServer(provider, "anthropic")
+	s.providers["gemini"] = ProviderInfo{provider: provider, providerType: "anthropic", rateLimit: &config.RateLimitConfig{MaxConcurrent: 1}}
+	s.batchStore = newMemoryBatchStore()
+
+	var jsonl strings.Builder
+	for i := range 200 {
+		jsonl.WriteString(batchLine(fmt.Sprintf("req-%d", i), "gemini/claude", "hello"))
+	}
+	fileID := uploadBatchFile(t, s, jsonl.String())
+	rec := doBatchRequest(s, http.MethodPost, "/gateway/v1/batches",
+		strings.NewReader(`{"input_file_id":"`+fileID+`","endpoint":"/v1/chat/completions"}`), "")
+	var created map[string]any
+	_ = json.Unmarshal(rec.Body.Bytes(), &created)
+	id := created["id"].(string)
+
+	rec = doBatchRequest(s, http.MethodPost, "/gateway/v1/batches/"+id+"/cancel", nil, "")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"status":"cancelling"`) {
+		t.Fatalf("cancel = %d %s", rec.Code, rec.Body.String())
+	}
+
+	final := waitBatch(t, s, id)
+	counts := final["request_counts"].(map[string]any)
+	if final["status"] != "cancelled" || counts["completed"].(float64) >= 200 {
+		t.Fatalf("final batch = %+v", final)
+	}
+}
+
+// countingUsageStore sums recorded tokens for per-request limit checks.
+type countingUsageStore struct {
+	budgetTokenUsageStore
+	total atomic.Int64
+}
+
+func (c *countingUsageStore) RecordUsage(_ context.Context, _, _ string, usage service.Usage) error {
+	c.total.Add(int64(usage.PromptTokens + usage.CompletionTokens))
+	return nil
+}
+
+func (c *countingUsageStore) GetTokenTotalUsage(context.Context, string) (int64, error) {
+	return c.total.Load(), nil
+}
+
+func TestBatchChecksTokenLimitPerRequest(t *testing.T) {
+	withFastBatchTimers(t)
+	provider := &batchTestProvider{}
+	provider.limited.Store(true)
+	s := newMessagesTestServer(provider, "anthropic")
+	s.providers["gemini"] = ProviderInfo{provider: provider, providerType: "anthropic", rateLimit: &config.RateLimitConfig{MaxConcurrent: 1}}
+	s.tokenStore = gatewayTestToken("test-token", service.APIToken{
+		ID:                   "tok-1",
+		AllowedProvidersMode: service.AccessModeAll,
+		AllowedModelsMode:    service.AccessModeAll,
+		TotalTokenLimit:      types.NewNull(int64(8)),
+	})
+	s.tokenUsageStore = &countingUsageStore{}
+	s.batchStore = newMemoryBatchStore()
+
+	var jsonl strings.Builder
+	for i := range 20 {
+		jsonl.WriteString(batchLine(fmt.Sprintf("req-%d", i), "gemini/claude", "hello"))
+	}
+	fileID := uploadBatchFile(t, s, jsonl.String())
+	rec := doBatchRequest(s, http.MethodPost, "/gateway/v1/batches",
+		strings.NewReader(`{"input_file_id":"`+fileID+`","endpoint":"/v1/chat/completions"}`), "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("create status = %d; body=%s", rec.Code, rec.Body.String())
+	}
+	var created map[string]any
+	_ = json.Unmarshal(rec.Body.Bytes(), &created)
+
+	final := waitBatch(t, s, created["id"].(string))
+	counts := final["request_counts"].(map[string]any)
+	if final["status"] != "completed" || counts["completed"].(float64) >= 20 || counts["failed"].(float64) == 0 {
+		t.Fatalf("final batch = %+v", final)
+	}
+	rec = doBatchRequest(s, http.MethodGet, "/gateway/v1/files/"+final["error_file_id"].(string)+"/content", nil, "")
+	if !strings.Contains(rec.Body.String(), `"status_code":429`) || !strings.Contains(rec.Body.String(), "token usage limit exceeded") {
+		t.Fatalf("error file = %s", rec.Body.String())
+	}
+}
+
+// deletedTokenStore authenticates requests but no longer finds the token
+// by ID, as after the token was deleted.
+type deletedTokenStore struct {
+	service.APITokenStorer
+}
+
+func (deletedTokenStore) GetAPIToken(context.Context, string) (*service.APIToken, error) {
+	return nil, nil
+}
+
+func TestBatchFailsWhenTokenIsDeleted(t *testing.T) {
+	withFastBatchTimers(t)
+	provider := &batchTestProvider{}
+	s := newMessagesTestServer(provider, "anthropic")
+	s.tokenStore = deletedTokenStore{s.tokenStore}
+	s.batchStore = newMemoryBatchStore()
+
+	fileID := uploadBatchFile(t, s, batchLine("a", "gemini/claude", "hello"))
+	rec := doBatchRequest(s, http.MethodPost, "/gateway/v1/batches",
+		strings.NewReader(`{"input_file_id":"`+fileID+`","endpoint":"/v1/chat/completions"}`), "")
+	var created map[string]any
+	_ = json.Unmarshal(rec.Body.Bytes(), &created)
+
+	final := waitBatch(t, s, created["id"].(string))
+	if final["status"] != "failed" || !strings.Contains(fmt.Sprint(final["errors"]), "no longer exists") {
+		t.Fatalf("final batch = %+v", final)
+	}
+	if provider.peak.Load() != 0 {
+		t.Error("requests ran under a deleted token")
+	}
+}
+
+func TestBatchRunsOnlyOnLeaseHolder(t *testing.T) {
+	withFastBatchTimers(t)
+	provider := &batchTestProvider{}
+	provider.limited.Store(true)
+	s := newMessagesTestServer(provider, "anthropic")
+	s.instanceID = "replica-a"
+	store := newMemoryBatchStore()
+	s.batchStore = store
+
+	fileID := uploadBatchFile(t, s, batchLine("a", "gemini/claude", "hello"))
+	// claimAll gives every batch lease to another replica.
+	claimAll := func(until time.Time) {
+		store.mu.Lock()
+		defer store.mu.Unlock()
+		for id := range store.batches {
+			store.leases[id] = batchLease{owner: "replica-b", until: until}
+		}
+	}
+
+	store.mu.Lock()
+	store.batches["batch_held"] = service.Batch{
+		ID:               "batch_held",
+		TokenID:          "tok-1",
+		Endpoint:         "/v1/chat/completions",
+		InputFileID:      fileID,
+		CompletionWindow: "24h",
+		Status:           service.BatchStatusValidating,
+		RequestTotal:     1,
+		CreatedAt:        time.Now(),
+		ExpiresAt:        time.Now().Add(time.Hour),
+	}
+	store.mu.Unlock()
+	claimAll(time.Now().Add(time.Hour))
+
+	s.resumeBatches(context.Background())
+	time.Sleep(50 * time.Millisecond)
+	if b, _ := store.GetBatch(context.Background(), "batch_held"); b.Status != service.BatchStatusValidating || provider.peak.Load() != 0 {
+		t.Fatalf("batch leased by another replica ran here: %+v", b)
+	}
+
+	// The other replica stopped renewing: the lease expires and this one takes over.
+	claimAll(time.Now().Add(-time.Second))
+	s.resumeBatches(context.Background())
+	if final := waitBatch(t, s, "batch_held"); final["status"] != "completed" {
+		t.Fatalf("final batch = %+v", final)
+	}
+	deadline := time.Now().Add(time.Second)
+	for {
+		store.mu.Lock()
+		_, leased := store.leases["batch_held"]
+		store.mu.Unlock()
+		if !leased {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("lease not released after the batch finished")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestBatchAppliesTokenGuardrails(t *testing.T) {
+	withFastBatchTimers(t)
+	provider := &nativeBatchProvider{}
+	s := newMessagesTestServer(provider, "openai")
+	s.tokenStore = gatewayTestToken("test-token", service.APIToken{
+		ID:                   "tok-1",
+		AllowedProvidersMode: service.AccessModeAll,
+		AllowedModelsMode:    service.AccessModeAll,
+		GuardrailIDs:         []string{"g1"},
+	})
+	s.guardrailStore = newMemoryGuardrailStore(redactEmailBlockProjectX)
+	s.batchStore = newMemoryBatchStore()
+
+	fileID := uploadBatchFile(t, s, batchLine("a", "gemini/gpt-4o-mini", "hello")+batchLine("b", "gemini/gpt-4o-mini", "about project x"))
+	rec := doBatchRequest(s, http.MethodPost, "/gateway/v1/batches",
+		strings.NewReader(`{"input_file_id":"`+fileID+`","endpoint":"/v1/chat/completions"}`), "")
+	var created map[string]any
+	_ = json.Unmarshal(rec.Body.Bytes(), &created)
+
+	final := waitBatch(t, s, created["id"].(string))
+	counts := final["request_counts"].(map[string]any)
+	if final["status"] != "completed" || counts["completed"] != float64(1) || counts["failed"] != float64(1) {
+		t.Fatalf("final batch = %+v", final)
+	}
+	provider.mu.Lock()
+	uploaded := provider.uploaded
+	provider.mu.Unlock()
+	if uploaded != "" {
+		t.Errorf("raw lines were sent to the provider batch API: %s", uploaded)
+	}
+	rec = doBatchRequest(s, http.MethodGet, "/gateway/v1/files/"+final["error_file_id"].(string)+"/content", nil, "")
+	if !strings.Contains(rec.Body.String(), `"custom_id":"b"`) || !strings.Contains(rec.Body.String(), "guardrail_violation") {
+		t.Fatalf("error file = %s", rec.Body.String())
+	}
+}
+
+func TestBatchAppliesGatewayHooks(t *testing.T) {
+	withFastBatchTimers(t)
+	provider := &nativeBatchProvider{}
+	s := newMessagesTestServer(provider, "openai")
+	s.providers["gemini"] = ProviderInfo{provider: provider, providerType: "openai", hookIDs: []string{"upper"}}
+	s.tokenStore = gatewayTestToken("test-token", service.APIToken{
+		ID:                   "tok-1",
+		AllowedProvidersMode: service.AccessModeAll,
+		AllowedModelsMode:    service.AccessModeAll,
+		HookIDs:              []string{"block"},
+	})
+	s.gatewayHookStore = newMemoryGatewayHookStore(
+		service.GatewayHook{ID: "block", Name: "block", Enabled: true, OnError: service.GatewayHookOnErrorFail, Script: `
+			function onRequest(req) {
+				if (req.messages[0].content.indexOf("secret") >= 0) throw new Error("no secrets");
+			}`},
+		service.GatewayHook{ID: "upper", Name: "upper", Enabled: true, Script: `
+			function onResponse(resp) {
+				resp.choices[0].message.content = resp.choices[0].message.content.toUpperCase();
+			}`},
+	)
+	s.batchStore = newMemoryBatchStore()
+
+	fileID := uploadBatchFile(t, s, batchLine("a", "gemini/gpt-4o-mini", "hello")+batchLine("b", "gemini/gpt-4o-mini", "a secret"))
+	rec := doBatchRequest(s, http.MethodPost, "/gateway/v1/batches",
+		strings.NewReader(`{"input_file_id":"`+fileID+`","endpoint":"/v1/chat/completions"}`), "")
+	var created map[string]any
+	_ = json.Unmarshal(rec.Body.Bytes(), &created)
+
+	final := waitBatch(t, s, created["id"].(string))
+	counts := final["request_counts"].(map[string]any)
+	if final["status"] != "completed" || counts["completed"] != float64(1) || counts["failed"] != float64(1) {
+		t.Fatalf("final batch = %+v", final)
+	}
+	provider.mu.Lock()
+	uploaded := provider.uploaded
+	provider.mu.Unlock()
+	if uploaded != "" {
+		t.Errorf("raw lines were sent to the provider batch API: %s", uploaded)
+	}
+	rec = doBatchRequest(s, http.MethodGet, "/gateway/v1/files/"+final["output_file_id"].(string)+"/content", nil, "")
+	if !strings.Contains(rec.Body.String(), `"content":"ECHO"`) {
+		t.Errorf("output file = %s, want the response hook applied", rec.Body.String())
+	}
+	rec = doBatchRequest(s, http.MethodGet, "/gateway/v1/files/"+final["error_file_id"].(string)+"/content", nil, "")
+	if !strings.Contains(rec.Body.String(), `"custom_id":"b"`) || !strings.Contains(rec.Body.String(), "gateway_hook_failed") {
+		t.Fatalf("error file = %s", rec.Body.String())
+	}
+}
@@ -0,0 +1,665 @@
+package server
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rakunlabs/at/internal/config"
+	"github.com/rakunlabs/at/internal/service"
+	str2duration "github.com/xhit/go-str2duration/v2"
+)
+
+// ─── Exact-match response cache ───
+//
+// Tokens with `cache_enabled` get identical chat completion and embedding
+// requests answered from a cache instead of the provider. The key is a
+// SHA-256 over the token ID, the endpoint kind, and the canonicalised
+// request body (model, messages, tools, generation params) with
+// transport-only fields stripped, so `stream: true` and `stream: false`
+// share an entry and a streaming hit is replayed as SSE.
+//
+// Clients control the cache per request with the standard header:
+//   - `Cache-Control: no-cache` skips the lookup but still stores the fresh
+//     response;
+//   - `Cache-Control: no-store` skips both.
+//
+// Every cacheable response carries `x-at-cache: hit|miss`. Hits are
+// recorded as zero-cost cost events with status "cache_hit" and do not
+// count against the token's usage limits.
+//
+// Semantic layer: when `response_cache.semantic_model` is configured and
+// the token sets `semantic_cache_threshold`, an exact miss embeds the last
+// user message and serves the most similar prior chat response (cosine
+// similarity at or above the threshold) within the same token, model, and
+// system-prompt scope. Those responses carry `x-at-cache: semantic`. The
+// embedding call itself is billed to the token like any embeddings
+// request.
+
+const (
+	defaultResponseCacheTTL        = 1 * time.Hour
+	defaultResponseCacheMaxEntries = 10000
+
+	// responseCacheJanitorInterval is how often expired entries are swept.
+	responseCacheJanitorInterval = 10 * time.Minute
+
+	// semanticCacheCandidateLimit caps how many entries of one scope are
+	// compared per lookup (newest first).
+	semanticCacheCandidateLimit = 500
+)
+
+// responseCacheIgnoredFields are request fields that do not change the
+// generated output and are dropped before hashing.
+var responseCacheIgnoredFields = []string{
+	"stream", "stream_options", "user", "metadata", "store", "service_tier",
+	"timeout_ms", "at_fallbacks", "at_hedge", "mock_response",
+}
+
+// responseCacheBackend is the storage behind the response cache.
+type responseCacheBackend interface {
+	get(ctx context.Context, key string) ([]byte, bool)
+	put(ctx context.Context, entry service.ResponseCacheEntry)
+	sweep(ctx context.Context) int64
+	// listScope returns unexpired entries with embeddings in the semantic
+	// scope, newest first.
+	listScope(ctx context.Context, scope string, limit int) []service.ResponseCacheEntry
+	// purge drops every entry for tokenID ("" = all).
+	purge(ctx context.Context, tokenID string) (int64, error)
+}
+
+// responseCache wraps a backend with the server-wide default TTL. A nil
+// *responseCache disables caching.
+type responseCache struct {
+	backend       responseCacheBackend
+	defaultTTL    time.Duration
+	semanticModel string // "provider/model"; empty disables the semantic layer
+}
+
+// newResponseCache builds the cache from the bootstrap config. The
+// postgres backend is only used when a store is wired; otherwise the
+// in-memory backend is the fallback.
+func newResponseCache(cfg *config.ResponseCache, store service.ResponseCacheStorer) *responseCache {
+	rc := &responseCache{defaultTTL: defaultResponseCacheTTL}
+	maxEntries := defaultResponseCacheMaxEntries
+	backend := ""
+	if cfg != nil {
+		if cfg.TTL > 0 {
+			rc.defaultTTL = cfg.TTL
+		}
+		if cfg.MaxEntries > 0 {
+			maxEntries = cfg.MaxEntries
+		}
+		backend = strings.ToLower(strings.TrimSpace(cfg.Backend))
+		rc.semanticModel = strings.TrimSpace(cfg.SemanticModel)
+	}
+
+	switch backend {
+	case "postgres":
+		if store != nil {
+			rc.backend = &dbResponseCache{store: store}
+			return rc
+		}
+		slog.Warn("response cache: postgres backend requested without a store, using memory")
+	case "", "memory":
+	default:
+		slog.Warn("response cache: unknown backend, using memory", "backend", backend)
+	}
+
+	rc.backend = newMemoryResponseCache(maxEntries)
+	return rc
+}
+
+// ttlFor returns the entry lifetime for the token: its own cache_ttl when
+// set and valid, else the server default.
+func (c *responseCache) ttlFor(token *service.APIToken) time.Duration {
+	if token != nil && token.CacheTTL.Valid && token.CacheTTL.V != "" {
+		if d, err := str2duration.ParseDuration(token.CacheTTL.V); err == nil && d > 0 {
+			return d
+		}
+	}
+	return c.defaultTTL
+}
+
+// responseCacheMode is the per-request cache decision.
+type responseCacheMode struct {
+	key     string // empty = caching disabled for this request
+	tokenID string
+	lookup  bool
+	store   bool
+	ttl     time.Duration
+}
+
+// enabled reports whether the request participates in caching at all.
+func (m responseCacheMode) enabled() bool { return m.key != "" }
+
+// responseCacheModeFor decides whether a request can be served from / stored
+// into the cache. Only DB tokens with cache_enabled participate.
+func (s *Server) responseCacheModeFor(r *http.Request, auth *authResult, kind string, body []byte) responseCacheMode {
+	if s.responseCache == nil || auth == nil || auth.token == nil || !auth.token.CacheEnabled {
+		return responseCacheMode{}
+	}
+
+	noCache, noStore := parseRequestCacheControl(r.Header.Get("Cache-Control"))
+	if noStore {
+		return responseCacheMode{}
+	}
+
+	key, err := responseCacheKey(auth.token.ID, kind, body)
+	if err != nil {
+		slog.Debug("response cache: cannot canonicalise request", "error", err.Error())
+		return responseCacheMode{}
+	}
+
+	return responseCacheMode{
+		key:     key,
+		tokenID: auth.token.ID,
+		lookup:  !noCache,
+		store:   true,
+		ttl:     s.responseCache.ttlFor(auth.token),
+	}
+}
+
+// lookupResponseCache returns the cached body for the mode's key.
+func (s *Server) lookupResponseCache(ctx context.Context, mode responseCacheMode) ([]byte, bool) {
+	if !mode.enabled() || !mode.lookup {
+		return nil, false
+	}
+	return s.responseCache.backend.get(ctx, mode.key)
+}
+
+// storeResponseCache saves a successful response body under the mode's key.
+// A non-nil probe also indexes the entry for semantic lookups.
+func (s *Server) storeResponseCache(ctx context.Context, mode responseCacheMode, kind, model string, body []byte, probe *semanticCacheProbe) {
+	if !mode.enabled() || !mode.store || len(body) == 0 {
+		return
+	}
+	now := time.Now()
+	entry := service.ResponseCacheEntry{
+		Key:       mode.key,
+		TokenID:   mode.tokenID,
+		Kind:      kind,
+		Model:     model,
+		Body:      append([]byte(nil), body...),
+		CreatedAt: now,
+		ExpiresAt: now.Add(mode.ttl),
+	}
+	if probe != nil {
+		entry.Scope = probe.scope
+		entry.Embedding = probe.vector
+	}
+	s.responseCache.backend.put(context.WithoutCancel(ctx), entry)
+}
+
+// serveCachedChat writes a cached chat completion body as JSON or, for
+// streaming requests, as a replayed SSE stream. It returns false when the
+// body cannot be decoded so the caller falls through to the provider.
+func (s *Server) serveCachedChat(w http.ResponseWriter, r *http.Request, auth *authResult, req *ChatCompletionRequest, body []byte, cacheHeader string) bool {
+	var cached ChatCompletionResponse
+	if err := json.Unmarshal(body, &cached); err != nil {
+		return false
+	}
+	w.Header().Set("x-at-cache", cacheHeader)
+	s.recordUsageAsync(r.Context(), auth, req.Model, service.Usage{}, 0, "cache_hit", "", "")
+	if req.Stream {
+		writeCachedChatStream(w, &cached, req.StreamOptions != nil && req.StreamOptions.IncludeUsage)
+	} else {
+		httpResponseJSONByte(w, body, http.StatusOK)
+	}
+	return true
+}
+
+// ─── Semantic layer ───
+
+// semanticCacheProbe is the embedded form of a chat request: its scope and
+// the vector of its last user message.
+type semanticCacheProbe struct {
+	scope     string
+	vector    []float64
+	threshold float64
+}
+
+// semanticCacheProbeFor embeds the request's last user message when the
+// semantic layer is configured and the token opted in. It returns nil when
+// semantic caching does not apply or the embedding call fails.
+func (s *Server) semanticCacheProbeFor(ctx context.Context, auth *authResult, mode responseCacheMode, req *ChatCompletionRequest) *semanticCacheProbe {
+	if !mode.enabled() || s.responseCache.semanticModel == "" {
+		return nil
+	}
+	threshold := auth.token.SemanticThreshold
+	if !threshold.Valid || threshold.V <= 0 {
+		return nil
+	}
+	// The probe embeds the raw prompt, which guardrails have not seen.
+	if hasTokenGuardrails(auth) {
+		return nil
+	}
+
+	var system []string
+	lastUser := ""
+	for _, m := range req.Messages {
+		switch m.Role {
+		case "system", "developer":
+			system = append(system, extractContentString(m.Content))
+		case "user":
+			lastUser = extractContentString(m.Content)
+		}
+	}
+	if strings.TrimSpace(lastUser) == "" {
+		return nil
+	}
+
+	fullModel := s.responseCache.semanticModel
+	providerKey, actualModel, err := parseModelID(fullModel)
+	if err != nil {
+		slog.Warn("semantic cache: invalid semantic_model", "model", fullModel, "error", err.Error())
+		return nil
+	}
+	info, ok := s.getProviderInfo(providerKey)
+	if !ok {
+		slog.Warn("semantic cache: embedding provider not found", "provider", providerKey)
+		return nil
+	}
+	embProvider, ok := service.As[service.EmbeddingProvider](info.provider)
+	if !ok {
+		slog.Warn("semantic cache: provider does not support embeddings", "provider", providerKey)
+		return nil
+	}
+
+	callStart := time.Now()
+	resp, err := embProvider.CreateEmbedding(ctx, service.EmbeddingRequest{
+		Input: []string{lastUser},
+		Model: actualModel,
+	})
+	latencyMs := time.Since(callStart).Milliseconds()
+	if err != nil {
+		slog.Warn("semantic cache: embedding failed", "model", fullModel, "error", err.Error())
+		s.recordUsageAsync(ctx, auth, fullModel, service.Usage{}, latencyMs, "error", classifyHTTPError(err), err.Error())
+		return nil
+	}
+	s.recordUsageAsync(ctx, auth, fullModel, resp.Usage, latencyMs, "ok", "", "")
+	if len(resp.Embeddings) == 0 || len(resp.Embeddings[0]) == 0 {
+		return nil
+	}
+
+	h := sha256.New()
+	h.Write([]byte(auth.token.ID))
+	h.Write([]byte{0})
+	h.Write([]byte(req.Model))
+	h.Write([]byte{0})
+	h.Write([]byte(strings.Join(system, "\n")))
+	// Tools, tool_choice, response_format and n shape the answer; a
+	// near-duplicate prompt only matches a request that agrees on them.
+	n := 1
+	if req.N != nil && *req.N > 1 {
+		n = *req.N
+	}
+	shape, _ := json.Marshal(struct {
+		Tools          []OpenAITool    `json:"tools,omitempty"`
+		ToolChoice     json.RawMessage `json:"tool_choice,omitempty"`
+		ResponseFormat map[string]any  `json:"response_format,omitempty"`
+		N              int             `json:"n"`
+	}{req.Tools, req.ToolChoice, req.ResponseFormat, n})
+	h.Write([]byte{0})
+	h.Write(shape)
+
+	return &semanticCacheProbe{
+		scope:     hex.EncodeToString(h.Sum(nil)),
+		vector:    resp.Embeddings[0],
+		threshold: threshold.V,
+	}
+}
+
+// lookupSemanticCache returns the body of the most similar entry in the
+// probe's scope when its similarity reaches the token threshold.
+func (s *Server) lookupSemanticCache(ctx context.Context, mode responseCacheMode, probe *semanticCacheProbe) ([]byte, bool) {
+	if probe == nil || !mode.lookup {
+		return nil, false
+	}
+	var (
+		best      []byte
+		bestScore float64
+	)
+	for _, e := range s.responseCache.backend.listScope(ctx, probe.scope, semanticCacheCandidateLimit) {
+		score := cosineSimilarity(probe.vector, e.Embedding)
+		if score >= probe.threshold && score > bestScore {
+			best, bestScore = e.Body, score
+		}
+	}
+	return best, best != nil
+}
+
+// cosineSimilarity returns the cosine of the angle between a and b, or 0
+// when the vectors differ in length or either is zero.
+func cosineSimilarity(a, b []float64) float64 {
+	if len(a) == 0 || len(a) != len(b) {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += a[i] * b[i]
+		na += a[i] * a[i]
+		nb += b[i] * b[i]
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
+
+// parseRequestCacheControl extracts the no-cache / no-store directives.
+func parseRequestCacheControl(header string) (noCache, noStore bool) {
+	for _, part := range strings.Split(header, ",") {
+		switch strings.ToLower(strings.TrimSpace(part)) {
+		case "no-cache":
+			noCache = true
+		case "no-store":
+			noStore = true
+		}
+	}
+	return noCache, noStore
+}
+
+// responseCacheKey hashes the canonical form of a JSON request body.
+// Fields in responseCacheIgnoredFields are removed and the remainder is
+// re-marshalled (encoding/json sorts map keys), so key order and
+// whitespace in the client payload don't matter.
+func responseCacheKey(tokenID, kind string, body []byte) (string, error) {
+	var m map[string]any
+	if err := json.Unmarshal(body, &m); err != nil {
+		return "", err
+	}
+	for _, f := range responseCacheIgnoredFields {
+		delete(m, f)
+	}
+	canonical, err := json.Marshal(m)
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	h.Write([]byte(tokenID))
+	h.Write([]byte{0})
+	h.Write([]byte(kind))
+	h.Write([]byte{0})
+	h.Write(canonical)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// startResponseCacheJanitor periodically drops expired entries. No-op when
+// the cache is disabled.
+func (s *Server) startResponseCacheJanitor(ctx context.Context) {
+	if s.responseCache == nil {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(responseCacheJanitorInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if n := s.responseCache.backend.sweep(ctx); n > 0 {
+					slog.Debug("response_cache_janitor: swept entries", "removed", n)
+				}
+			}
+		}
+	}()
+}
+
+// writeCachedChatStream replays a cached chat completion as an SSE stream
+// with the same chunk layout as the fake-streaming path.
+func writeCachedChatStream(w http.ResponseWriter, resp *ChatCompletionResponse, includeUsage bool) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		httpResponseJSON(w, map[string]any{
+			"error": map[string]any{
+				"message": "streaming not supported by this server",
+				"type":    "server_error",
+			},
+		}, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+
+	for _, choice := range resp.Choices {
+		writeSSEChunk(w, flusher, ChatCompletionChunk{
+			ID:     resp.ID,
+			Object: "chat.completion.chunk",
+			Model:  resp.Model,
+			Choices: []ChunkChoice{{
+				Index: choice.Index,
+				Delta: ChunkDelta{Role: "assistant"},
+			}},
+		})
+
+		msg := choice.Message
+		if msg.ReasoningContent != nil && *msg.ReasoningContent != "" {
+			writeSSEChunk(w, flusher, ChatCompletionChunk{
+				ID:     resp.ID,
+				Object: "chat.completion.chunk",
+				Model:  resp.Model,
+				Choices: []ChunkChoice{{
+					Index: choice.Index,
+					Delta: ChunkDelta{ReasoningContent: *msg.ReasoningContent},
+				}},
+			})
+		}
+		if msg.Content != nil && *msg.Content != "" {
+			writeSSEChunk(w, flusher, ChatCompletionChunk{
+				ID:     resp.ID,
+				Object: "chat.completion.chunk",
+				Model:  resp.Model,
+				Choices: []ChunkChoice{{
+					Index: choice.Index,
+					Delta: ChunkDelta{Content: *msg.Content},
+				}},
+			})
+		}
+		if len(msg.ToolCalls) > 0 {
+			toolCalls := make([]OpenAIToolCall, len(msg.ToolCalls))
+			for i, tc := range msg.ToolCalls {
+				idx := i
+				tc.Index = &idx
+				toolCalls[i] = tc
+			}
+			writeSSEChunk(w, flusher, ChatCompletionChunk{
+				ID:     resp.ID,
+				Object: "chat.completion.chunk",
+				Model:  resp.Model,
+				Choices: []ChunkChoice{{
+					Index: choice.Index,
+					Delta: ChunkDelta{ToolCalls: toolCalls},
+				}},
+			})
+		}
+
+		finishReason := choice.FinishReason
+		writeSSEChunk(w, flusher, ChatCompletionChunk{
+			ID:     resp.ID,
+			Object: "chat.completion.chunk",
+			Model:  resp.Model,
+			Choices: []ChunkChoice{{
+				Index:        choice.Index,
+				Delta:        ChunkDelta{},
+				FinishReason: &finishReason,
+			}},
+		})
+	}
+
+	if includeUsage {
+		usage := resp.Usage
+		writeSSEChunk(w, flusher, ChatCompletionChunk{
+			ID:      resp.ID,
+			Object:  "chat.completion.chunk",
+			Model:   resp.Model,
+			Choices: []ChunkChoice{},
+			Usage:   &usage,
+		})
+	}
+	fmt.Fprint(w, "data: [DONE]\n\n")
+	flusher.Flush()
+}
+
+// ─── Backends ───
+
+// memoryResponseCache is a bounded in-process cache. When full, the oldest
+// inserted entry is evicted.
+type memoryResponseCache struct {
+	mu         sync.Mutex
+	maxEntries int
+	order      *list.List // of *service.ResponseCacheEntry, oldest first
+	entries    map[string]*list.Element
+}
+
+func newMemoryResponseCache(maxEntries int) *memoryResponseCache {
+	return &memoryResponseCache{
+		maxEntries: maxEntries,
+		order:      list.New(),
+		entries:    make(map[string]*list.Element),
+	}
+}
+
+func (c *memoryResponseCache) get(_ context.Context, key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	e := el.Value.(*service.ResponseCacheEntry)
+	if time.Now().After(e.ExpiresAt) {
+		c.order.Remove(el)
+		delete(c.entries, key)
+		return nil, false
+	}
+	return e.Body, true
+}
+
+func (c *memoryResponseCache) put(_ context.Context, entry service.ResponseCacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[entry.Key]; ok {
+		c.order.Remove(el)
+		delete(c.entries, entry.Key)
+	}
+	for c.maxEntries > 0 && len(c.entries) >= c.maxEntries {
+		oldest := c.order.Front()
+		if oldest == nil {
+			break
+		}
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*service.ResponseCacheEntry).Key)
+	}
+	c.entries[entry.Key] = c.order.PushBack(&entry)
+}
+
+func (c *memoryResponseCache) sweep(_ context.Context) int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	var removed int64
+	for key, el := range c.entries {
+		if now.After(el.Value.(*service.ResponseCacheEntry).ExpiresAt) {
+			c.order.Remove(el)
+			delete(c.entries, key)
+			removed++
+		}
+	}
+	return removed
+}
+
+func (c *memoryResponseCache) listScope(_ context.Context, scope string, limit int) []service.ResponseCacheEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	var out []service.ResponseCacheEntry
+	for el := c.order.Back(); el != nil; el = el.Prev() {
+		e := el.Value.(*service.ResponseCacheEntry)
+		if e.Scope != scope || len(e.Embedding) == 0 || now.After(e.ExpiresAt) {
+			continue
+		}
+		out = append(out, *e)
+		if limit > 0 && len(out) >= limit {
+			break
+		}
+	}
+	return out
+}
+
+func (c *memoryResponseCache) purge(_ context.Context, tokenID string) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var removed int64
+	for key, el := range c.entries {
+		if tokenID != "" && el.Value.(*service.ResponseCacheEntry).TokenID != tokenID {
+			continue
+		}
+		c.order.Remove(el)
+		delete(c.entries, key)
+		removed++
+	}
+	return removed, nil
+}
+
+// dbResponseCache persists entries through the ResponseCacheStorer so
+// every instance behind a load balancer shares the cache. Store errors
+// degrade to a miss.
+type dbResponseCache struct {
+	store service.ResponseCacheStorer
+}
+
+func (c *dbResponseCache) get(ctx context.Context, key string) ([]byte, bool) {
+	e, err := c.store.GetResponseCache(ctx, key)
+	if err != nil {
+		slog.Warn("response cache: lookup failed", "error", err.Error())
+		return nil, false
+	}
+	if e == nil {
+		return nil, false
+	}
+	return e.Body, true
+}
+
+func (c *dbResponseCache) put(ctx context.Context, entry service.ResponseCacheEntry) {
+	go func() {
+		if err := c.store.PutResponseCache(ctx, entry); err != nil {
+			slog.Warn("response cache: store failed", "error", err.Error())
+		}
+	}()
+}
+
+func (c *dbResponseCache) sweep(ctx context.Context) int64 {
+	n, err := c.store.DeleteResponseCacheBefore(ctx, time.Now())
+	if err != nil {
+		slog.Debug("response cache: sweep failed", "error", err.Error())
+		return 0
+	}
+	return n
+}
+
+func (c *dbResponseCache) listScope(ctx context.Context, scope string, limit int) []service.ResponseCacheEntry {
+	entries, err := c.store.ListResponseCacheByScope(ctx, scope, limit)
+	if err != nil {
+		slog.Warn("response cache: semantic lookup failed", "error", err.Error())
+		return nil
+	}
+	return entries
+}
+
+func (c *dbResponseCache) purge(ctx context.Context, tokenID string) (int64, error) {
+	return c.store.PurgeResponseCache(ctx, tokenID)
+}
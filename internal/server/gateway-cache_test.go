@@ -0,0 +1,378 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rakunlabs/at/internal/service"
+	"github.com/worldline-go/types"
+)
+
+type countingChatProvider struct {
+	calls int
+}
+
+func (p *countingChatProvider) Chat(context.Context, string, []service.Message, []service.Tool, *service.ChatOptions) (*service.LLMResponse, error) {
+	p.calls++
+	return &service.LLMResponse{
+		Content:  "cached answer",
+		Finished: true,
+		Usage:    service.Usage{PromptTokens: 3, CompletionTokens: 2},
+	}, nil
+}
+
+// semanticChatProvider answers chats and embeds text onto two axes: texts
+// mentioning "password" and everything else.
+type semanticChatProvider struct {
+	countingChatProvider
+	embedCalls int
+}
+
+func (p *semanticChatProvider) CreateEmbedding(_ context.Context, req service.EmbeddingRequest) (*service.EmbeddingResponse, error) {
+	p.embedCalls++
+	vecs := make([][]float64, len(req.Input))
+	for i, in := range req.Input {
+		if strings.Contains(strings.ToLower(in), "password") {
+			vecs[i] = []float64{1, 0.1}
+		} else {
+			vecs[i] = []float64{0, 1}
+		}
+	}
+	return &service.EmbeddingResponse{Embeddings: vecs, Model: req.Model}, nil
+}
+
+func newResponseCacheTestServer(provider service.LLMProvider, cacheEnabled bool) *Server {
+	return &Server{
+		providers: map[string]ProviderInfo{
+			"openai": {provider: provider, providerType: "openai"},
+		},
+		tokenStore: gatewayTestToken("test-token", service.APIToken{
+			ID:                   "tok-1",
+			AllowedProvidersMode: service.AccessModeAll,
+			AllowedModelsMode:    service.AccessModeAll,
+			CacheEnabled:         cacheEnabled,
+		}),
+		responseCache: newResponseCache(nil, nil),
+	}
+}
+
+func doCachedChat(s *Server, body string, header map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/gateway/v1/chat/completions", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer test-token")
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	rec := httptest.NewRecorder()
+	s.ChatCompletions(rec, req)
+	return rec
+}
+
+func TestChatCompletionsResponseCacheHit(t *testing.T) {
+	provider := &countingChatProvider{}
+	s := newResponseCacheTestServer(provider, true)
+
+	first := doCachedChat(s, `{"model":"openai/gpt-4o","messages":[{"role":"user","content":"hi"}],"temperature":0}`, nil)
+	if first.Code != http.StatusOK {
+		t.Fatalf("first status = %d; body=%s", first.Code, first.Body.String())
+	}
+	if got := first.Header().Get("x-at-cache"); got != "miss" {
+		t.Fatalf("first x-at-cache = %q, want miss", got)
+	}
+
+	// Same request with different key order, a user field, and streaming
+	// transport fields stripped — still the same cache entry.
+	second := doCachedChat(s, `{"temperature":0,"user":"u-2","messages":[{"role":"user","content":"hi"}],"model":"openai/gpt-4o"}`, nil)
+	if second.Code != http.StatusOK {
+		t.Fatalf("second status = %d; body=%s", second.Code, second.Body.String())
+	}
+	if got := second.Header().Get("x-at-cache"); got != "hit" {
+		t.Fatalf("second x-at-cache = %q, want hit", got)
+	}
+	if provider.calls != 1 {
+		t.Fatalf("provider calls = %d, want 1", provider.calls)
+	}
+	if second.Body.String() != first.Body.String() {
+		t.Errorf("cached body differs:\nfirst=%s\nsecond=%s", first.Body.String(), second.Body.String())
+	}
+
+	// A streaming request replays the cached answer as SSE.
+	stream := doCachedChat(s, `{"model":"openai/gpt-4o","messages":[{"role":"user","content":"hi"}],"temperature":0,"stream":true}`, nil)
+	if got := stream.Header().Get("x-at-cache"); got != "hit" {
+		t.Fatalf("stream x-at-cache = %q, want hit", got)
+	}
+	if !strings.Contains(stream.Body.String(), "cached answer") || !strings.Contains(stream.Body.String(), "data: [DONE]") {
+		t.Errorf("stream body = %s", stream.Body.String())
+	}
+	if provider.calls != 1 {
+		t.Fatalf("provider calls after stream = %d, want 1", provider.calls)
+	}
+
+	// Hedging only changes how the answer is fetched, not the answer.
+	hedged := doCachedChat(s, `{"model":"openai/gpt-4o","messages":[{"role":"user","content":"hi"}],"temperature":0,"at_fallbacks":["openai/gpt-4o-mini"],"at_hedge":{"delay_ms":50}}`, nil)
+	if got := hedged.Header().Get("x-at-cache"); got != "hit" {
+		t.Fatalf("hedged x-at-cache = %q, want hit; body=%s", got, hedged.Body.String())
+	}
+	if provider.calls != 1 {
+		t.Fatalf("provider calls after hedged = %d, want 1", provider.calls)
+	}
+
+	// A different generation param is a different key.
+	other := doCachedChat(s, `{"model":"openai/gpt-4o","messages":[{"role":"user","content":"hi"}],"temperature":1}`, nil)
+	if got := other.Header().Get("x-at-cache"); got != "miss" {
+		t.Fatalf("other x-at-cache = %q, want miss", got)
+	}
+	if provider.calls != 2 {
+		t.Fatalf("provider calls = %d, want 2", provider.calls)
+	}
+}
+
+func TestChatCompletionsResponseCacheControl(t *testing.T) {
+	provider := &countingChatProvider{}
+	s := newResponseCacheTestServer(provider, true)
+	body := `{"model":"openai/gpt-4o","messages":[{"role":"user","content":"hi"}]}`
+
+	// no-store neither reads nor writes.
+	rec := doCachedChat(s, body, map[string]string{"Cache-Control": "no-store"})
+	if got := rec.Header().Get("x-at-cache"); got != "" {
+		t.Fatalf("no-store x-at-cache = %q, want empty", got)
+	}
+	rec = doCachedChat(s, body, nil)
+	if got := rec.Header().Get("x-at-cache"); got != "miss" {
+		t.Fatalf("after no-store x-at-cache = %q, want miss", got)
+	}
+
+	// no-cache bypasses the lookup but refreshes the entry.
+	rec = doCachedChat(s, body, map[string]string{"Cache-Control": "no-cache"})
+	if got := rec.Header().Get("x-at-cache"); got != "miss" {
+		t.Fatalf("no-cache x-at-cache = %q, want miss", got)
+	}
+	rec = doCachedChat(s, body, nil)
+	if got := rec.Header().Get("x-at-cache"); got != "hit" {
+		t.Fatalf("after no-cache x-at-cache = %q, want hit", got)
+	}
+	if provider.calls != 3 {
+		t.Fatalf("provider calls = %d, want 3", provider.calls)
+	}
+}
+
+func TestChatCompletionsResponseCacheDisabledForToken(t *testing.T) {
+	provider := &countingChatProvider{}
+	s := newResponseCacheTestServer(provider, false)
+	body := `{"model":"openai/gpt-4o","messages":[{"role":"user","content":"hi"}]}`
+
+	for range 2 {
+		rec := doCachedChat(s, body, nil)
+		if got := rec.Header().Get("x-at-cache"); got != "" {
+			t.Fatalf("x-at-cache = %q, want empty", got)
+		}
+	}
+	if provider.calls != 2 {
+		t.Fatalf("provider calls = %d, want 2", provider.calls)
+	}
+}
+
+func TestEmbeddingsResponseCacheHit(t *testing.T) {
+	provider := &embeddingCaptureProvider{}
+	s := newResponseCacheTestServer(provider, true)
+
+	do := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/gateway/v1/embeddings", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer test-token")
+		rec := httptest.NewRecorder()
+		s.Embeddings(rec, req)
+		return rec
+	}
+
+	first := do(`{"model":"openai/text-embedding-3-small","input":["hello"]}`)
+	if got := first.Header().Get("x-at-cache"); got != "miss" {
+		t.Fatalf("first x-at-cache = %q, want miss", got)
+	}
+	provider.req = service.EmbeddingRequest{}
+
+	second := do(`{"input":["hello"],"model":"openai/text-embedding-3-small"}`)
+	if got := second.Header().Get("x-at-cache"); got != "hit" {
+		t.Fatalf("second x-at-cache = %q, want hit", got)
+	}
+	if provider.req.Model != "" {
+		t.Errorf("provider called on cache hit: %+v", provider.req)
+	}
+	if second.Body.String() != first.Body.String() {
+		t.Errorf("cached body differs:\nfirst=%s\nsecond=%s", first.Body.String(), second.Body.String())
+	}
+}
+
+func TestChatCompletionsSemanticCache(t *testing.T) {
+	provider := &semanticChatProvider{}
+	s := newResponseCacheTestServer(provider, true)
+	s.responseCache.semanticModel = "openai/text-embedding-3-small"
+	s.tokenStore = gatewayTestToken("test-token", service.APIToken{
+		ID:                   "tok-1",
+		AllowedProvidersMode: service.AccessModeAll,
+		AllowedModelsMode:    service.AccessModeAll,
+		CacheEnabled:         true,
+		SemanticThreshold:    types.NewNull(0.9),
+	})
+
+	first := doCachedChat(s, `{"model":"openai/gpt-4o","messages":[{"role":"system","content":"support bot"},{"role":"user","content":"How do I reset my password?"}]}`, nil)
+	if got := first.Header().Get("x-at-cache"); got != "miss" {
+		t.Fatalf("first x-at-cache = %q, want miss", got)
+	}
+
+	near := doCachedChat(s, `{"model":"openai/gpt-4o","messages":[{"role":"system","content":"support bot"},{"role":"user","content":"password reset please"}]}`, nil)
+	if got := near.Header().Get("x-at-cache"); got != "semantic" {
+		t.Fatalf("near x-at-cache = %q, want semantic", got)
+	}
+	if near.Body.String() != first.Body.String() {
+		t.Errorf("semantic body differs:\nfirst=%s\nnear=%s", first.Body.String(), near.Body.String())
+	}
+
+	unrelated := doCachedChat(s, `{"model":"openai/gpt-4o","messages":[{"role":"system","content":"support bot"},{"role":"user","content":"What are your opening hours?"}]}`, nil)
+	if got := unrelated.Header().Get("x-at-cache"); got != "miss" {
+		t.Fatalf("unrelated x-at-cache = %q, want miss", got)
+	}
+
+	// A different system prompt is a different scope.
+	otherScope := doCachedChat(s, `{"model":"openai/gpt-4o","messages":[{"role":"system","content":"sales bot"},{"role":"user","content":"password reset please"}]}`, nil)
+	if got := otherScope.Header().Get("x-at-cache"); got != "miss" {
+		t.Fatalf("other scope x-at-cache = %q, want miss", got)
+	}
+
+	if provider.calls != 3 {
+		t.Fatalf("provider chat calls = %d, want 3", provider.calls)
+	}
+	if provider.embedCalls != 4 {
+		t.Fatalf("embedding calls = %d, want 4", provider.embedCalls)
+	}
+}
+
+func TestSemanticCacheScopesByResponseShape(t *testing.T) {
+	provider := &semanticChatProvider{}
+	s := newResponseCacheTestServer(provider, true)
+	s.responseCache.semanticModel = "openai/text-embedding-3-small"
+	s.tokenStore = gatewayTestToken("test-token", service.APIToken{
+		ID:                   "tok-1",
+		AllowedProvidersMode: service.AccessModeAll,
+		AllowedModelsMode:    service.AccessModeAll,
+		CacheEnabled:         true,
+		SemanticThreshold:    types.NewNull(0.9),
+	})
+
+	doCachedChat(s, `{"model":"openai/gpt-4o","messages":[{"role":"user","content":"How do I reset my password?"}]}`, nil)
+
+	for name, extra := range map[string]string{
+		"response_format": `"response_format":{"type":"json_object"},`,
+		"tools":           `"tools":[{"type":"function","function":{"name":"reset","parameters":{"type":"object"}}}],`,
+		"tool_choice":     `"tool_choice":"required",`,
+		"n":               `"n":3,`,
+	} {
+		rec := doCachedChat(s, `{"model":"openai/gpt-4o",`+extra+`"messages":[{"role":"user","content":"password reset please"}]}`, nil)
+		if got := rec.Header().Get("x-at-cache"); got == "semantic" {
+			t.Errorf("%s: near-duplicate with a different shape hit the semantic cache", name)
+		}
+	}
+}
+
+func TestPurgeResponseCacheAPI(t *testing.T) {
+	provider := &countingChatProvider{}
+	s := newResponseCacheTestServer(provider, true)
+	body := `{"model":"openai/gpt-4o","messages":[{"role":"user","content":"hi"}]}`
+	doCachedChat(s, body, nil)
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/v1/response-cache?token_id=other", nil)
+	rec := httptest.NewRecorder()
+	s.PurgeResponseCacheAPI(rec, req)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"deleted":0`) {
+		t.Fatalf("purge other token: status=%d body=%s", rec.Code, rec.Body.String())
+	}
+	if got := doCachedChat(s, body, nil).Header().Get("x-at-cache"); got != "hit" {
+		t.Fatalf("x-at-cache after unrelated purge = %q, want hit", got)
+	}
+
+	req = httptest.NewRequest(http.MethodDelete, "/api/v1/response-cache?token_id=tok-1", nil)
+	rec = httptest.NewRecorder()
+	s.PurgeResponseCacheAPI(rec, req)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"deleted":1`) {
+		t.Fatalf("purge token: status=%d body=%s", rec.Code, rec.Body.String())
+	}
+	if got := doCachedChat(s, body, nil).Header().Get("x-at-cache"); got != "miss" {
+		t.Fatalf("x-at-cache after purge = %q, want miss", got)
+	}
+}
+
+func TestCosineSimilarity(t *testing.T) {
+	if got := cosineSimilarity([]float64{1, 0}, []float64{2, 0}); got < 0.999 {
+		t.Errorf("parallel = %v, want 1", got)
+	}
+	if got := cosineSimilarity([]float64{1, 0}, []float64{0, 1}); got != 0 {
+		t.Errorf("orthogonal = %v, want 0", got)
+	}
+	if got := cosineSimilarity([]float64{1}, []float64{1, 0}); got != 0 {
+		t.Errorf("length mismatch = %v, want 0", got)
+	}
+}
+
+func TestMemoryResponseCacheEvictsAndExpires(t *testing.T) {
+	c := newMemoryResponseCache(2)
+	ctx := context.Background()
+	future := time.Now().Add(time.Hour)
+
+	c.put(ctx, service.ResponseCacheEntry{Key: "a", Body: []byte("1"), ExpiresAt: future})
+	c.put(ctx, service.ResponseCacheEntry{Key: "b", Body: []byte("2"), ExpiresAt: future})
+	c.put(ctx, service.ResponseCacheEntry{Key: "c", Body: []byte("3"), ExpiresAt: future})
+
+	if _, ok := c.get(ctx, "a"); ok {
+		t.Error("oldest entry should have been evicted")
+	}
+	if got, ok := c.get(ctx, "c"); !ok || string(got) != "3" {
+		t.Errorf("get(c) = %q, %v", got, ok)
+	}
+
+	c.put(ctx, service.ResponseCacheEntry{Key: "b", Body: []byte("2"), ExpiresAt: time.Now().Add(-time.Second)})
+	if _, ok := c.get(ctx, "b"); ok {
+		t.Error("expired entry should miss")
+	}
+	c.put(ctx, service.ResponseCacheEntry{Key: "d", Body: []byte("4"), ExpiresAt: time.Now().Add(-time.Second)})
+	if n := c.sweep(ctx); n != 1 {
+		t.Errorf("sweep removed %d, want 1", n)
+	}
+}
+
+func TestChatCompletionsCacheRespectsSpendLimit(t *testing.T) {
+	provider := &semanticChatProvider{}
+	s := newResponseCacheTestServer(provider, true)
+	s.responseCache.semanticModel = "openai/text-embedding-3-small"
+	costs := &budgetCostEventStore{}
+	s.costEventStore = costs
+	s.tokenStore = gatewayTestToken("test-token", service.APIToken{
+		ID:                   "tok-1",
+		AllowedProvidersMode: service.AccessModeAll,
+		AllowedModelsMode:    service.AccessModeAll,
+		CacheEnabled:         true,
+		SemanticThreshold:    types.NewNull(0.9),
+		SpendLimitCents:      types.NewNull(100.0),
+		CreatedAt:            types.NewTime(time.Now().UTC().Add(-time.Hour)),
+	})
+
+	body := `{"model":"openai/gpt-4o","messages":[{"role":"user","content":"How do I reset my password?"}]}`
+	if rec := doCachedChat(s, body, nil); rec.Code != http.StatusOK {
+		t.Fatalf("first status = %d; body=%s", rec.Code, rec.Body.String())
+	}
+	embedCalls := provider.embedCalls
+
+	// Once over the limit, neither the exact entry nor the semantic probe
+	// is used.
+	costs.spend = 100
+	for _, b := range []string{body, `{"model":"openai/gpt-4o","messages":[{"role":"user","content":"password reset please"}]}`} {
+		rec := doCachedChat(s, b, nil)
+		if rec.Code != http.StatusTooManyRequests {
+			t.Fatalf("over-limit status = %d; body=%s", rec.Code, rec.Body.String())
+		}
+	}
+	if provider.embedCalls != embedCalls {
+		t.Fatalf("embedding calls = %d, want %d", provider.embedCalls, embedCalls)
+	}
+}
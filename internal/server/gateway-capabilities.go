@@ -0,0 +1,185 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/rakunlabs/at/internal/service"
+	"github.com/rakunlabs/at/internal/service/llm/common"
+)
+
+// ─── Capability checks on gateway requests ───
+//
+// Before a translated request goes upstream it is checked against the
+// target model's capability record (model-capabilities.go). Features the
+// request can do without are adapted: max tokens are clamped to the
+// model's output limit, reasoning options are dropped, json_schema is
+// downgraded to json_object and streaming is emulated from one Chat call.
+// Content the model cannot read (images, audio), tools (unless the
+// provider emulates them) and prompts over the context window are
+// rejected with a 400 naming the capability. The prompt size is a local
+// estimate, so it is only rejected once it exceeds the window by
+// contextWindowMargin; closer calls are left to the provider.
+// Unknown capabilities never reject.
+
+// contextWindowMargin is how far the local token estimate may exceed a
+// model's context window before the request is rejected.
+const contextWindowMargin = 1.1
+
+// capabilityError is a request the target model cannot serve. It maps to
+// a 400 in classifyGatewayError and does not trigger fallbacks.
+type capabilityError struct {
+	param   string
+	code    string
+	message string
+}
+
+func (e *capabilityError) Error() string { return e.message }
+
+// chatOnlyProvider hides a provider's ChatStream so the streaming paths
+// fake-stream from a single Chat call.
+type chatOnlyProvider struct {
+	service.LLMProvider
+}
+
+// adaptToModelCapabilities validates messages/tools against the target
+// model and adapts opts (and, for stream requests whose model cannot
+// stream, target's provider) in place. opts must already be the
+// per-attempt clone.
+func (s *Server) adaptToModelCapabilities(
+	ctx context.Context,
+	target *chatCallTarget,
+	messages []service.Message,
+	tools []service.Tool,
+	opts *service.ChatOptions,
+	stream bool,
+) error {
+	mc, ok := s.modelCapability(ctx, target.providerKey, target.actualModel)
+	if !ok {
+		return nil
+	}
+
+	if len(tools) > 0 && isFalse(mc.Tools) && !target.info.emulatesTools(target.actualModel) {
+		return &capabilityError{
+			param:   "tools",
+			code:    "unsupported_tools",
+			message: fmt.Sprintf("model %q does not support tool calling", target.fullModel),
+		}
+	}
+
+	images, audio := messageMediaKinds(messages)
+	if images && isFalse(mc.Vision) {
+		return &capabilityError{
+			param:   "messages",
+			code:    "unsupported_content",
+			message: fmt.Sprintf("model %q does not accept image input", target.fullModel),
+		}
+	}
+	if audio && isFalse(mc.Audio) {
+		return &capabilityError{
+			param:   "messages",
+			code:    "unsupported_content",
+			message: fmt.Sprintf("model %q does not accept audio input", target.fullModel),
+		}
+	}
+
+	if mc.ContextWindow > 0 {
+		if n := common.CountTokensLocal(messages, tools); float64(n) > float64(mc.ContextWindow)*contextWindowMargin {
+			return &capabilityError{
+				param: "messages",
+				code:  "context_length_exceeded",
+				message: fmt.Sprintf("model %q has a context window of %d tokens, but the request is about %d tokens",
+					target.fullModel, mc.ContextWindow, n),
+			}
+		}
+	}
+
+	if opts != nil {
+		if limit := mc.MaxOutputTokens; limit > 0 {
+			if opts.MaxTokens != nil && *opts.MaxTokens > limit {
+				opts.MaxTokens = &limit
+			}
+			if opts.MaxCompletionTokens != nil && *opts.MaxCompletionTokens > limit {
+				opts.MaxCompletionTokens = &limit
+			}
+		}
+		if isFalse(mc.Reasoning) {
+			opts.ReasoningEffort = ""
+			opts.Thinking = nil
+		}
+		if isFalse(mc.JSONSchema) && opts.ResponseFormat["type"] == "json_schema" {
+			opts.ResponseFormat = map[string]any{"type": "json_object"}
+		}
+	}
+
+	if stream && isFalse(mc.Streaming) {
+		if _, ok := target.info.provider.(chatOnlyProvider); !ok {
+			target.info.provider = chatOnlyProvider{target.info.provider}
+		}
+		slog.Debug("model does not stream; emulating from one chat call",
+			"provider", target.providerKey, "model", target.actualModel)
+	}
+
+	return nil
+}
+
+func isFalse(b *bool) bool { return b != nil && !*b }
+
+// messageMediaKinds reports whether the translated messages carry image
+// or audio input, in either the content-block form (Anthropic, Bedrock,
+// MiniMax) or the OpenAI content-part form kept for other providers.
+func messageMediaKinds(messages []service.Message) (images, audio bool) {
+	for _, m := range messages {
+		var content any = m.Content
+		if raw, ok := m.Content.(map[string]any); ok {
+			content = raw["content"]
+		}
+
+		switch c := content.(type) {
+		case []service.ContentBlock:
+			for _, b := range c {
+				switch b.Type {
+				case "image":
+					images = true
+				case "audio":
+					audio = true
+				}
+			}
+		case []any:
+			for _, p := range c {
+				part, _ := p.(map[string]any)
+				switch part["type"] {
+				case "image_url", "input_image":
+					images = true
+				case "input_audio":
+					audio = true
+				}
+			}
+		}
+	}
+	return images, audio
+}
+
+// applyModelCapabilityData copies the known capabilities onto a
+// /v1/models entry.
+func applyModelCapabilityData(md *ModelData, mc service.ModelCapability) {
+	md.ContextWindow = mc.ContextWindow
+	md.MaxOutputTokens = mc.MaxOutputTokens
+	caps := make(map[string]bool)
+	for name, v := range map[string]*bool{
+		"vision":      mc.Vision,
+		"audio":       mc.Audio,
+		"tools":       mc.Tools,
+		"reasoning":   mc.Reasoning,
+		"json_schema": mc.JSONSchema,
+		"streaming":   mc.Streaming,
+	} {
+		if v != nil {
+			caps[name] = *v
+		}
+	}
+	if len(caps) > 0 {
+		md.Capabilities = caps
+	}
+}
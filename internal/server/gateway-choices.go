@@ -0,0 +1,241 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/rakunlabs/at/internal/service"
+	"github.com/rakunlabs/at/internal/service/llm/common"
+)
+
+// ─── n > 1 emulation ───
+//
+// OpenAI-compatible providers (service.NativeChoicesProvider) generate
+// several choices in one upstream call. For every other provider the
+// gateway fans out n parallel single-choice calls and assembles them into
+// one response with summed usage. Each call goes through the provider as
+// usual, so the provider's rate limiter and the 429 retry apply per call.
+// A native provider that answers with fewer choices than asked (an
+// OpenAI-compatible server ignoring `n`) is topped up the same way, up to
+// maxChatChoices. Native providers take any n and stream it; only
+// targets that fan out are held to maxChatChoices and non-streaming
+// (checkChoicesTargets).
+//
+// When the request sets a seed, fanned-out choice i uses seed+i so the
+// candidates differ. The whole request fails if any choice fails, but the
+// usage of the choices that finished is still recorded (choicesUsage).
+// Before fanning out, checkChoicesBudget makes sure n choices fit in the
+// token's remaining usage and spend limits.
+
+// maxChatChoices caps n on targets that fan out. Every emulated choice
+// is a full upstream call charged to the token.
+const maxChatChoices = 8
+
+// nativeChoices reports whether prov generates n choices in one call.
+func nativeChoices(prov service.LLMProvider) bool {
+	nc, ok := service.As[service.NativeChoicesProvider](prov)
+	return ok && nc.NativeChoices()
+}
+
+// checkChoicesTargets returns why n cannot be served by one of targets,
+// or "" when it can. Targets that failed validation are skipped.
+func checkChoicesTargets(n *int, stream bool, targets ...chatCallTarget) string {
+	if n == nil || *n <= 1 {
+		return ""
+	}
+	for _, t := range targets {
+		if t.err != nil || nativeChoices(t.info.provider) {
+			continue
+		}
+		// The streaming relay cannot interleave parallel calls.
+		if stream {
+			return fmt.Sprintf("n > 1 is not supported with stream for provider %q", t.providerKey)
+		}
+		if *n > maxChatChoices {
+			return fmt.Sprintf("n must be at most %d for provider %q", maxChatChoices, t.providerKey)
+		}
+	}
+	return ""
+}
+
+// choicesError is a failed chatChoices call that still consumed usage on
+// the choices that finished before the failure.
+type choicesError struct {
+	err   error
+	usage service.Usage
+}
+
+func (e *choicesError) Error() string { return e.err.Error() }
+func (e *choicesError) Unwrap() error { return e.err }
+
+// choicesUsage returns the usage a failed chatChoices call consumed.
+func choicesUsage(err error) service.Usage {
+	var ce *choicesError
+	if errors.As(err, &ce) {
+		return ce.usage
+	}
+	return service.Usage{}
+}
+
+// requestedChoices returns the n the caller asked for (at least 1).
+func requestedChoices(opts *service.ChatOptions) int {
+	if opts == nil || opts.N == nil || *opts.N < 1 {
+		return 1
+	}
+	return *opts.N
+}
+
+// chatChoices produces the requested number of choices from prov. call
+// makes one upstream Chat call with the given options.
+func chatChoices(
+	ctx context.Context,
+	prov service.LLMProvider,
+	opts *service.ChatOptions,
+	call func(ctx context.Context, opts *service.ChatOptions) (*service.LLMResponse, error),
+) (*service.LLMResponse, error) {
+	n := requestedChoices(opts)
+	if n == 1 {
+		return call(ctx, opts)
+	}
+
+	if nativeChoices(prov) {
+		resp, err := call(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+		have := 1 + len(resp.ExtraChoices)
+		if have >= n || n > maxChatChoices {
+			resp.ExtraChoices = resp.ExtraChoices[:min(have, n)-1]
+			return resp, nil
+		}
+		more, err := fanOutChoices(ctx, n-have, have, opts, call)
+		if err != nil {
+			return nil, &choicesError{err: err, usage: resp.Usage.Add(choicesUsage(err))}
+		}
+		return mergeChoices(append([]*service.LLMResponse{resp}, more...)), nil
+	}
+
+	resps, err := fanOutChoices(ctx, n, 0, opts, call)
+	if err != nil {
+		return nil, err
+	}
+	return mergeChoices(resps), nil
+}
+
+// fanOutChoices runs count single-choice calls in parallel. offset is the
+// index of the first choice, used to vary the seed. The first failure
+// cancels the remaining calls and is returned as a *choicesError with the
+// usage of the calls that succeeded.
+func fanOutChoices(
+	ctx context.Context,
+	count, offset int,
+	opts *service.ChatOptions,
+	call func(ctx context.Context, opts *service.ChatOptions) (*service.LLMResponse, error),
+) ([]*service.LLMResponse, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+		resps    = make([]*service.LLMResponse, count)
+	)
+	for i := range count {
+		single := cloneChatOptions(opts)
+		single.N = nil
+		if opts.Seed != nil {
+			seed := *opts.Seed + offset + i
+			single.Seed = &seed
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := call(ctx, single)
+			if err != nil {
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = err
+					cancel()
+				}
+				mu.Unlock()
+				return
+			}
+			resps[i] = resp
+		}()
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		var usage service.Usage
+		for _, r := range resps {
+			if r != nil {
+				usage = usage.Add(r.Usage)
+			}
+		}
+		return nil, &choicesError{err: firstErr, usage: usage}
+	}
+	return resps, nil
+}
+
+// checkChoicesBudget rejects an n > 1 request whose choices would take
+// the token past its usage or spend limit. Each choice is estimated from
+// the local prompt size plus max_tokens and priced for target;
+// checkTokenLimits only compares what is already spent.
+func (s *Server) checkChoicesBudget(ctx context.Context, auth *authResult, target chatCallTarget, messages []service.Message, tools []service.Tool, opts *service.ChatOptions) (string, error) {
+	n := requestedChoices(opts)
+	if n == 1 || auth == nil || auth.token == nil || auth.token.ID == "" {
+		return "", nil
+	}
+	token := auth.token
+
+	per := service.Usage{PromptTokens: common.CountTokensLocal(messages, tools)}
+	switch {
+	case opts.MaxCompletionTokens != nil:
+		per.CompletionTokens = *opts.MaxCompletionTokens
+	case opts.MaxTokens != nil:
+		per.CompletionTokens = *opts.MaxTokens
+	}
+
+	if token.TotalTokenLimit.Valid && token.TotalTokenLimit.V > 0 && s.tokenUsageStore != nil {
+		total, err := s.tokenUsageStore.GetTokenTotalUsage(ctx, token.ID)
+		if err != nil {
+			return "", fmt.Errorf("get total usage: %w", err)
+		}
+		need := int64(n) * int64(per.TotalTokenCount())
+		if remaining := token.TotalTokenLimit.V - total; need > remaining {
+			return fmt.Sprintf("token usage limit exceeded: n=%d needs about %d tokens, %d remain", n, need, max(remaining, 0)), nil
+		}
+	}
+
+	if token.SpendLimitCents.Valid && token.SpendLimitCents.V > 0 && s.costEventStore != nil {
+		spend, err := s.costEventStore.GetCostByAgentSince(ctx, "gateway:"+token.ID, tokenBudgetWindowStart(token))
+		if err != nil {
+			return "", fmt.Errorf("get token spend: %w", err)
+		}
+		need := float64(n) * s.estimateGatewayUsageCostCents(ctx, target.providerKey, target.actualModel, target.fullModel, per)
+		if remaining := token.SpendLimitCents.V - spend; need > remaining {
+			return fmt.Sprintf("token spend limit exceeded: n=%d needs about %.2f cents, %.2f remain", n, need, max(remaining, 0)), nil
+		}
+	}
+
+	return "", nil
+}
+
+// mergeChoices folds resps into one response: resps[0] is choice 0, the
+// rest (and their own extra choices) follow in order. Usage is summed.
+func mergeChoices(resps []*service.LLMResponse) *service.LLMResponse {
+	out := *resps[0]
+	out.ExtraChoices = append([]service.LLMResponse(nil), resps[0].ExtraChoices...)
+	for _, r := range resps[1:] {
+		out.Usage = out.Usage.Add(r.Usage)
+		choice := *r
+		choice.ExtraChoices, choice.Usage, choice.Header = nil, service.Usage{}, nil
+		out.ExtraChoices = append(out.ExtraChoices, choice)
+		out.ExtraChoices = append(out.ExtraChoices, r.ExtraChoices...)
+	}
+	return &out
+}
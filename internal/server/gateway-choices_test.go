@@ -0,0 +1,253 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rakunlabs/at/internal/service"
+	"github.com/worldline-go/types"
+)
+
+// choicesTestProvider answers every call with its own numbered choice.
+// With native set it honours N by returning nativeChoices choices.
+type choicesTestProvider struct {
+	native        bool
+	nativeChoices int
+	failOn        int // 1-based call number that fails; 0 never
+
+	mu    sync.Mutex
+	calls int
+	ns    []int
+	seeds []int
+}
+
+func (p *choicesTestProvider) Chat(_ context.Context, _ string, _ []service.Message, _ []service.Tool, opts *service.ChatOptions) (*service.LLMResponse, error) {
+	p.mu.Lock()
+	p.calls++
+	call := p.calls
+	n := 1
+	if opts == nil {
+		opts = &service.ChatOptions{}
+	}
+	if opts.N != nil {
+		n = *opts.N
+	}
+	p.ns = append(p.ns, n)
+	if opts.Seed != nil {
+		p.seeds = append(p.seeds, *opts.Seed)
+	}
+	p.mu.Unlock()
+
+	if call == p.failOn {
+		return nil, errors.New("upstream blew up")
+	}
+	resp := &service.LLMResponse{
+		Content:  fmt.Sprintf("answer %d", call),
+		Finished: true,
+		Usage:    service.Usage{PromptTokens: 10, CompletionTokens: 2},
+	}
+	if p.native && n > 1 {
+		for i := 1; i < p.nativeChoices; i++ {
+			resp.ExtraChoices = append(resp.ExtraChoices, service.LLMResponse{Content: fmt.Sprintf("native %d", i), Finished: true})
+		}
+	}
+	return resp, nil
+}
+
+func (p *choicesTestProvider) NativeChoices() bool { return p.native }
+
+func newChoicesTestServer(provider service.LLMProvider) *Server {
+	return &Server{
+		providers: map[string]ProviderInfo{
+			"p": {provider: provider, providerType: "anthropic"},
+		},
+		tokenStore: gatewayTestToken("test-token", service.APIToken{
+			ID:                   "tok-1",
+			AllowedProvidersMode: service.AccessModeAll,
+			AllowedModelsMode:    service.AccessModeAll,
+		}),
+	}
+}
+
+func decodeChatChoices(t *testing.T, body []byte) ChatCompletionResponse {
+	t.Helper()
+	var resp ChatCompletionResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		t.Fatalf("decode: %v; body=%s", err, body)
+	}
+	return resp
+}
+
+func TestChatCompletionsEmulatesN(t *testing.T) {
+	provider := &choicesTestProvider{}
+	s := newChoicesTestServer(provider)
+
+	rec := doCapabilityChat(s, `{"model":"p/m","n":3,"seed":7,"messages":[{"role":"user","content":"hi"}]}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d; body=%s", rec.Code, rec.Body.String())
+	}
+	resp := decodeChatChoices(t, rec.Body.Bytes())
+
+	if len(resp.Choices) != 3 {
+		t.Fatalf("choices = %d, want 3", len(resp.Choices))
+	}
+	seen := map[string]bool{}
+	for i, c := range resp.Choices {
+		if c.Index != i || c.Message.Content == nil {
+			t.Errorf("choice %d = %+v", i, c)
+			continue
+		}
+		seen[*c.Message.Content] = true
+	}
+	if len(seen) != 3 {
+		t.Errorf("choices are not distinct calls: %v", seen)
+	}
+	if resp.Usage.PromptTokens != 30 || resp.Usage.CompletionTokens != 6 {
+		t.Errorf("usage = %+v, want summed", resp.Usage)
+	}
+
+	sort.Ints(provider.seeds)
+	if fmt.Sprint(provider.ns) != "[1 1 1]" || fmt.Sprint(provider.seeds) != "[7 8 9]" {
+		t.Errorf("upstream n = %v, seeds = %v", provider.ns, provider.seeds)
+	}
+}
+
+func TestChatCompletionsNativeN(t *testing.T) {
+	tests := []struct {
+		name          string
+		nativeChoices int
+		wantCalls     int
+	}{
+		{"all choices native", 3, 1},
+		{"upstream ignored n", 1, 3},
+		{"upstream returned some", 2, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := &choicesTestProvider{native: true, nativeChoices: tt.nativeChoices}
+			s := newChoicesTestServer(provider)
+
+			rec := doCapabilityChat(s, `{"model":"p/m","n":3,"messages":[{"role":"user","content":"hi"}]}`)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d; body=%s", rec.Code, rec.Body.String())
+			}
+			resp := decodeChatChoices(t, rec.Body.Bytes())
+			if len(resp.Choices) != 3 {
+				t.Errorf("choices = %d, want 3", len(resp.Choices))
+			}
+			if provider.calls != tt.wantCalls || provider.ns[0] != 3 {
+				t.Errorf("calls = %d (n = %v), want %d", provider.calls, provider.ns, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestChatCompletionsNativeNPassesThrough(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"above the fan-out cap", `{"model":"p/m","n":16,"messages":[{"role":"user","content":"hi"}]}`},
+		{"stream", `{"model":"p/m","n":2,"stream":true,"messages":[{"role":"user","content":"hi"}]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := &choicesTestProvider{native: true, nativeChoices: 16}
+			s := newChoicesTestServer(provider)
+
+			rec := doCapabilityChat(s, tt.body)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d; body=%s", rec.Code, rec.Body.String())
+			}
+			if provider.calls != 1 || provider.ns[0] <= 1 {
+				t.Errorf("calls = %d (n = %v), want n passed upstream once", provider.calls, provider.ns)
+			}
+		})
+	}
+
+	t.Run("batch", func(t *testing.T) {
+		provider := &choicesTestProvider{native: true, nativeChoices: 16}
+		s := newChoicesTestServer(provider)
+		auth := &authResult{token: &service.APIToken{ID: "tok-1", AllowedProvidersMode: service.AccessModeAll, AllowedModelsMode: service.AccessModeAll}}
+
+		status, body, _ := s.executeBatchRequest(context.Background(), auth, batchRequestLine{
+			Body: json.RawMessage(`{"model":"p/m","n":16,"messages":[{"role":"user","content":"hi"}]}`),
+		})
+		if status != http.StatusOK || provider.calls != 1 {
+			t.Fatalf("status = %d, calls = %d; body=%+v", status, provider.calls, body)
+		}
+	})
+}
+
+func TestChatCompletionsEmulatedNFailure(t *testing.T) {
+	s := newChoicesTestServer(&choicesTestProvider{failOn: 2})
+
+	rec := doCapabilityChat(s, `{"model":"p/m","n":3,"messages":[{"role":"user","content":"hi"}]}`)
+	if rec.Code < 500 {
+		t.Fatalf("status = %d, want an upstream error; body=%s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestChatCompletionsEmulatedNFailureRecordsUsage(t *testing.T) {
+	s := newChoicesTestServer(&choicesTestProvider{failOn: 3})
+	usage := &countingUsageStore{}
+	s.tokenUsageStore = usage
+
+	// Calls 1 and 2 finish (12 tokens each) before call 3 fails.
+	rec := doCapabilityChat(s, `{"model":"p/m","n":3,"messages":[{"role":"user","content":"hi"}]}`)
+	if rec.Code < 500 {
+		t.Fatalf("status = %d, want an upstream error; body=%s", rec.Code, rec.Body.String())
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for usage.total.Load() != 24 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got := usage.total.Load(); got != 24 {
+		t.Fatalf("recorded usage = %d tokens, want 24", got)
+	}
+}
+
+func TestChatCompletionsChecksBudgetAgainstN(t *testing.T) {
+	provider := &choicesTestProvider{}
+	s := newChoicesTestServer(provider)
+	s.tokenStore = gatewayTestToken("test-token", service.APIToken{
+		ID:                   "tok-1",
+		AllowedProvidersMode: service.AccessModeAll,
+		AllowedModelsMode:    service.AccessModeAll,
+		TotalTokenLimit:      types.NewNull(int64(1000)),
+	})
+	s.tokenUsageStore = &budgetTokenUsageStore{total: 500}
+
+	// One choice fits in the 500 tokens left; eight do not.
+	rec := doCapabilityChat(s, `{"model":"p/m","n":1,"max_tokens":100,"messages":[{"role":"user","content":"hi"}]}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("n=1: status = %d; body=%s", rec.Code, rec.Body.String())
+	}
+	rec = doCapabilityChat(s, `{"model":"p/m","n":8,"max_tokens":100,"messages":[{"role":"user","content":"hi"}]}`)
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("n=8: status = %d, want 429; body=%s", rec.Code, rec.Body.String())
+	}
+	if provider.calls != 1 {
+		t.Errorf("upstream calls = %d, want only the n=1 request", provider.calls)
+	}
+}
+
+func TestChatCompletionsRejectsInvalidN(t *testing.T) {
+	s := newChoicesTestServer(&choicesTestProvider{})
+
+	for _, body := range []string{
+		`{"model":"p/m","n":2,"stream":true,"messages":[{"role":"user","content":"hi"}]}`,
+		`{"model":"p/m","n":9,"messages":[{"role":"user","content":"hi"}]}`,
+	} {
+		if rec := doCapabilityChat(s, body); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", body, rec.Code)
+		}
+	}
+}
@@ -1,10 +1,12 @@
 package server
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/binary"
 	"encoding/json"
 	"fmt"
+	"io"
 	"log/slog"
 	"math"
 	"net/http"
@@ -63,8 +65,10 @@ func (s *Server) Embeddings(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Keep the raw body: the response cache keys on it.
+	rawBody, _ := io.ReadAll(r.Body)
 	var req embeddingsRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	if err := json.Unmarshal(rawBody, &req); err != nil {
 		httpResponseJSON(w, map[string]any{
 			"error": map[string]any{
 				"message": fmt.Sprintf("invalid request body: %v", err),
@@ -144,6 +148,26 @@ func (s *Server) Embeddings(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// The token's guardrail input checks run on every input before it can
+	// reach the provider or be answered from the cache.
+	inputs, err = s.guardEmbeddingInputs(r.Context(), auth, req.Model, inputs)
+	if err != nil {
+		status, body := classifyGatewayError(err)
+		httpResponseJSON(w, body, status)
+		return
+	}
+
+	cacheMode := s.responseCacheModeFor(r, auth, service.ResponseCacheKindEmbeddings, rawBody)
+	if cacheMode.enabled() {
+		w.Header().Set("x-at-cache", "miss")
+		if cached, ok := s.lookupResponseCache(r.Context(), cacheMode); ok {
+			w.Header().Set("x-at-cache", "hit")
+			s.recordUsageAsync(r.Context(), auth, req.Model, service.Usage{}, 0, "cache_hit", "", "")
+			httpResponseJSONByte(w, cached, http.StatusOK)
+			return
+		}
+	}
+
 	info, ok := s.getProviderInfo(providerKey)
 	if !ok {
 		httpResponseJSON(w, map[string]any{
@@ -157,7 +181,7 @@ func (s *Server) Embeddings(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	embProvider, ok := info.provider.(service.EmbeddingProvider)
+	embProvider, ok := service.As[service.EmbeddingProvider](info.provider)
 	if !ok {
 		httpResponseJSON(w, map[string]any{
 			"error": map[string]any{
@@ -223,6 +247,11 @@ func (s *Server) Embeddings(w http.ResponseWriter, r *http.Request) {
 	}
 
 	s.recordUsageAsync(r.Context(), auth, req.Model, resp.Usage, latencyMs, "ok", "", "")
+	if cacheMode.enabled() {
+		if body, err := json.Marshal(out); err == nil {
+			s.storeResponseCache(r.Context(), cacheMode, service.ResponseCacheKindEmbeddings, req.Model, body, nil)
+		}
+	}
 	httpResponseJSON(w, out, http.StatusOK)
 }
 
@@ -236,6 +265,27 @@ func encodeEmbeddingBase64(embedding []float64) string {
 	return base64.StdEncoding.EncodeToString(raw)
 }
 
+// guardEmbeddingInputs runs the token's guardrail input checks on each
+// embedding input as a user message and returns the redacted inputs.
+func (s *Server) guardEmbeddingInputs(ctx context.Context, auth *authResult, fullModel string, inputs []string) ([]string, error) {
+	if !hasTokenGuardrails(auth) {
+		return inputs, nil
+	}
+	messages := make([]service.Message, len(inputs))
+	for i, in := range inputs {
+		messages[i] = service.Message{Role: "user", Content: in}
+	}
+	guarded, err := s.guardTokenInput(ctx, auth, "embeddings", fullModel, messages, nil)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]string, len(guarded))
+	for i, m := range guarded {
+		out[i], _ = m.Content.(string)
+	}
+	return out, nil
+}
+
 // parseEmbeddingsInput accepts either a single string, an array of strings,
 // an array of token-id arrays ([][]int), or a single token-id array ([]int).
 // We currently support text inputs only; token-id arrays return an error.
@@ -330,12 +330,14 @@ func (s *Server) chatCallChain(auth *authResult, primary string, fallbacks []str
 			out = append(out, chatCallTarget{fullModel: m, err: err})
 			continue
 		}
-		out = append(out, chatCallTarget{
+		target := chatCallTarget{
 			fullModel:   m,
 			providerKey: pKey,
 			actualModel: actual,
 			info:        info,
-		})
+		}
+		s.tokenGuardrails(auth, &target, "gateway")
+		out = append(out, target)
 	}
 	return out
 }
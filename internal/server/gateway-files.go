@@ -0,0 +1,252 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+
+	"github.com/rakunlabs/at/internal/service"
+)
+
+// ─── Files API (batch input/output) ───
+//
+// /gateway/v1/files keeps the JSONL files used by the Batch API: uploads
+// with purpose "batch" and the output/error files produced by finished
+// batches ("batch_output"). Files are scoped to the API token that
+// created them and expire after server.batch_file_ttl (30 days).
+
+const (
+	defaultBatchFileTTL = 30 * 24 * time.Hour
+
+	// maxBatchFileBytes caps uploads, matching OpenAI's batch input limit.
+	maxBatchFileBytes = 200 << 20
+
+	batchFilePurpose       = "batch"
+	batchOutputFilePurpose = "batch_output"
+)
+
+var errBatchesDisabled = errors.New("files and batches are not available on this gateway")
+
+// batchRequestAuth authenticates a Files/Batch API request, writing the
+// error response itself when that fails.
+func (s *Server) batchRequestAuth(w http.ResponseWriter, r *http.Request) (*authResult, bool) {
+	auth, authErr := s.authenticateRequest(r)
+	if authErr != "" {
+		httpResponseJSON(w, map[string]any{
+			"error": map[string]any{
+				"message": authErr,
+				"type":    "invalid_request_error",
+				"code":    "invalid_api_key",
+			},
+		}, http.StatusUnauthorized)
+		return nil, false
+	}
+	if s.batchStore == nil {
+		gatewayBadRequest(w, errBatchesDisabled.Error(), "", "")
+		return nil, false
+	}
+	return auth, true
+}
+
+// batchFileObject renders a file in the OpenAI file object shape.
+func batchFileObject(f *service.BatchFile) map[string]any {
+	return map[string]any{
+		"id":         f.ID,
+		"object":     "file",
+		"bytes":      f.Bytes,
+		"created_at": f.CreatedAt.Unix(),
+		"expires_at": f.ExpiresAt.Unix(),
+		"filename":   f.Filename,
+		"purpose":    f.Purpose,
+		"status":     "processed",
+	}
+}
+
+func (s *Server) batchFileExpiry(now time.Time) time.Time {
+	ttl := s.batchFileTTL
+	if ttl <= 0 {
+		ttl = defaultBatchFileTTL
+	}
+	return now.Add(ttl)
+}
+
+// getOwnedBatchFile loads a file and hides it when it belongs to another
+// token.
+func (s *Server) getOwnedBatchFile(r *http.Request, auth *authResult, id string) (*service.BatchFile, error) {
+	f, err := s.batchStore.GetBatchFile(r.Context(), id)
+	if err != nil {
+		return nil, err
+	}
+	if f == nil || f.TokenID != storedResponseTokenID(auth) {
+		return nil, nil
+	}
+	return f, nil
+}
+
+// UploadFile handles POST /gateway/v1/files (multipart: file, purpose).
+// Only purpose "batch" is accepted; every non-empty line must be a JSON
+// object.
+func (s *Server) UploadFile(w http.ResponseWriter, r *http.Request) {
+	auth, ok := s.batchRequestAuth(w, r)
+	if !ok {
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxBatchFileBytes+1<<20)
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		gatewayBadRequest(w, fmt.Sprintf("invalid multipart form: %v", err), "", "")
+		return
+	}
+	if purpose := r.FormValue("purpose"); purpose != batchFilePurpose {
+		gatewayBadRequest(w, fmt.Sprintf("purpose %q is not supported; only %q files can be uploaded", purpose, batchFilePurpose), "purpose", "")
+		return
+	}
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		gatewayBadRequest(w, "file is required", "file", "")
+		return
+	}
+	defer file.Close()
+
+	content, err := io.ReadAll(io.LimitReader(file, maxBatchFileBytes+1))
+	if err != nil {
+		gatewayBadRequest(w, fmt.Sprintf("read file: %v", err), "file", "")
+		return
+	}
+	if len(content) > maxBatchFileBytes {
+		gatewayBadRequest(w, fmt.Sprintf("file exceeds %d bytes", maxBatchFileBytes), "file", "")
+		return
+	}
+	for i, line := range bytes.Split(content, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		var obj map[string]any
+		if err := json.Unmarshal(line, &obj); err != nil {
+			gatewayBadRequest(w, fmt.Sprintf("line %d is not a JSON object: %v", i+1, err), "file", "invalid_jsonl")
+			return
+		}
+	}
+
+	now := time.Now()
+	f := service.BatchFile{
+		ID:        "file-" + ulid.Make().String(),
+		TokenID:   storedResponseTokenID(auth),
+		Filename:  header.Filename,
+		Purpose:   batchFilePurpose,
+		Bytes:     int64(len(content)),
+		Content:   content,
+		CreatedAt: now,
+		ExpiresAt: s.batchFileExpiry(now),
+	}
+	if err := s.batchStore.PutBatchFile(r.Context(), f); err != nil {
+		writeBatchServerError(w, err)
+		return
+	}
+
+	httpResponseJSON(w, batchFileObject(&f), http.StatusOK)
+}
+
+// ListFiles handles GET /gateway/v1/files (optional ?purpose=).
+func (s *Server) ListFiles(w http.ResponseWriter, r *http.Request) {
+	auth, ok := s.batchRequestAuth(w, r)
+	if !ok {
+		return
+	}
+
+	files, err := s.batchStore.ListBatchFiles(r.Context(), storedResponseTokenID(auth))
+	if err != nil {
+		writeBatchServerError(w, err)
+		return
+	}
+
+	purpose := r.URL.Query().Get("purpose")
+	data := make([]map[string]any, 0, len(files))
+	for i := range files {
+		if purpose != "" && files[i].Purpose != purpose {
+			continue
+		}
+		data = append(data, batchFileObject(&files[i]))
+	}
+	httpResponseJSON(w, map[string]any{"object": "list", "data": data, "has_more": false}, http.StatusOK)
+}
+
+// GetFile handles GET /gateway/v1/files/{id}.
+func (s *Server) GetFile(w http.ResponseWriter, r *http.Request) {
+	f, ok := s.batchFileRequest(w, r)
+	if !ok {
+		return
+	}
+	httpResponseJSON(w, batchFileObject(f), http.StatusOK)
+}
+
+// GetFileContent handles GET /gateway/v1/files/{id}/content.
+func (s *Server) GetFileContent(w http.ResponseWriter, r *http.Request) {
+	f, ok := s.batchFileRequest(w, r)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/jsonl")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", f.Filename))
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(f.Content)
+}
+
+// DeleteFile handles DELETE /gateway/v1/files/{id}.
+func (s *Server) DeleteFile(w http.ResponseWriter, r *http.Request) {
+	f, ok := s.batchFileRequest(w, r)
+	if !ok {
+		return
+	}
+	if err := s.batchStore.DeleteBatchFile(r.Context(), f.ID); err != nil {
+		writeBatchServerError(w, err)
+		return
+	}
+	httpResponseJSON(w, map[string]any{"id": f.ID, "object": "file", "deleted": true}, http.StatusOK)
+}
+
+// batchFileRequest authenticates a file request and loads the token's
+// file named by the {id} path value.
+func (s *Server) batchFileRequest(w http.ResponseWriter, r *http.Request) (*service.BatchFile, bool) {
+	auth, ok := s.batchRequestAuth(w, r)
+	if !ok {
+		return nil, false
+	}
+
+	id := r.PathValue("id")
+	f, err := s.getOwnedBatchFile(r, auth, id)
+	if err != nil {
+		writeBatchServerError(w, err)
+		return nil, false
+	}
+	if f == nil {
+		writeBatchNotFound(w, "File", id)
+		return nil, false
+	}
+	return f, true
+}
+
+func writeBatchNotFound(w http.ResponseWriter, kind, id string) {
+	httpResponseJSON(w, map[string]any{
+		"error": map[string]any{
+			"message": fmt.Sprintf("%s with id '%s' not found.", kind, id),
+			"type":    "invalid_request_error",
+		},
+	}, http.StatusNotFound)
+}
+
+func writeBatchServerError(w http.ResponseWriter, err error) {
+	httpResponseJSON(w, map[string]any{
+		"error": map[string]any{
+			"message": err.Error(),
+			"type":    "server_error",
+		},
+	}, http.StatusInternalServerError)
+}
@@ -0,0 +1,831 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+
+	"github.com/rakunlabs/at/internal/service"
+)
+
+// ─── Gemini generateContent API (inbound compatibility layer) ───
+//
+// POST /gateway/v1beta/models/{provider/model}:generateContent and
+// :streamGenerateContent accept Google GenAI requests and route them by
+// "provider/model" to any configured LLMProvider, so tools built on the
+// Google GenAI SDK can reach OpenAI, Anthropic, Bedrock, … models.
+//
+// Like /v1/messages, the request is translated into the chat.completions
+// message shape and then through buildProviderMessages.
+//
+// What we support:
+//   - `contents` with text, inlineData (images, PDFs, audio) and image
+//     fileData parts, functionCall / functionResponse parts
+//   - `systemInstruction`, `tools[].functionDeclarations`, `toolConfig`
+//   - `generationConfig`: maxOutputTokens, temperature, topP,
+//     stopSequences, seed, candidateCount, penalties, responseMimeType /
+//     responseSchema, thinkingConfig
+//   - Streaming as SSE (`?alt=sse`, what the SDKs use) or as a JSON array
+//   - Auth via `x-goog-api-key`, `?key=` or a bearer token
+//   - AT extensions: at_fallbacks, extra_body, timeout_ms
+//
+// What we do NOT support:
+//   - Built-in tools (googleSearch, codeExecution, …) and cachedContent
+//   - safetySettings (ignored; the gemini provider sets its own)
+
+// generateContentRequest is the Gemini generateContent request body.
+type generateContentRequest struct {
+	Contents          []geminiContent         `json:"contents"`
+	SystemInstruction *geminiContent          `json:"systemInstruction,omitempty"`
+	Tools             []geminiTool            `json:"tools,omitempty"`
+	ToolConfig        *geminiToolConfig       `json:"toolConfig,omitempty"`
+	GenerationConfig  *geminiGenerationConfig `json:"generationConfig,omitempty"`
+
+	// AT extensions
+	AtFallbacks []string       `json:"at_fallbacks,omitempty"`
+	ExtraBody   map[string]any `json:"extra_body,omitempty"`
+	TimeoutMs   int            `json:"timeout_ms,omitempty"`
+}
+
+type geminiContent struct {
+	Role  string       `json:"role,omitempty"` // "user" | "model"
+	Parts []geminiPart `json:"parts"`
+}
+
+type geminiPart struct {
+	Text             string                `json:"text,omitempty"`
+	Thought          bool                  `json:"thought,omitempty"`
+	ThoughtSignature string                `json:"thoughtSignature,omitempty"`
+	InlineData       *geminiBlob           `json:"inlineData,omitempty"`
+	FileData         *geminiFileData       `json:"fileData,omitempty"`
+	FunctionCall     *geminiFunctionCall   `json:"functionCall,omitempty"`
+	FunctionResponse *geminiFunctionResult `json:"functionResponse,omitempty"`
+}
+
+type geminiBlob struct {
+	MimeType string `json:"mimeType"`
+	Data     string `json:"data"` // base64
+}
+
+type geminiFileData struct {
+	MimeType string `json:"mimeType,omitempty"`
+	FileURI  string `json:"fileUri"`
+}
+
+type geminiFunctionCall struct {
+	ID   string         `json:"id,omitempty"`
+	Name string         `json:"name"`
+	Args map[string]any `json:"args,omitempty"`
+}
+
+type geminiFunctionResult struct {
+	ID       string         `json:"id,omitempty"`
+	Name     string         `json:"name"`
+	Response map[string]any `json:"response,omitempty"`
+}
+
+type geminiTool struct {
+	FunctionDeclarations []geminiFunctionDecl `json:"functionDeclarations,omitempty"`
+}
+
+type geminiFunctionDecl struct {
+	Name                 string         `json:"name"`
+	Description          string         `json:"description,omitempty"`
+	Parameters           map[string]any `json:"parameters,omitempty"`
+	ParametersJSONSchema map[string]any `json:"parametersJsonSchema,omitempty"`
+}
+
+type geminiToolConfig struct {
+	FunctionCallingConfig *struct {
+		Mode                 string   `json:"mode,omitempty"` // AUTO | ANY | NONE
+		AllowedFunctionNames []string `json:"allowedFunctionNames,omitempty"`
+	} `json:"functionCallingConfig,omitempty"`
+}
+
+type geminiGenerationConfig struct {
+	MaxOutputTokens    *int           `json:"maxOutputTokens,omitempty"`
+	Temperature        *float64       `json:"temperature,omitempty"`
+	TopP               *float64       `json:"topP,omitempty"`
+	StopSequences      []string       `json:"stopSequences,omitempty"`
+	Seed               *int           `json:"seed,omitempty"`
+	CandidateCount     *int           `json:"candidateCount,omitempty"`
+	PresencePenalty    *float64       `json:"presencePenalty,omitempty"`
+	FrequencyPenalty   *float64       `json:"frequencyPenalty,omitempty"`
+	ResponseMimeType   string         `json:"responseMimeType,omitempty"`
+	ResponseSchema     map[string]any `json:"responseSchema,omitempty"`
+	ResponseJSONSchema map[string]any `json:"responseJsonSchema,omitempty"`
+	ThinkingConfig     *struct {
+		ThinkingBudget  *int `json:"thinkingBudget,omitempty"`
+		IncludeThoughts bool `json:"includeThoughts,omitempty"`
+	} `json:"thinkingConfig,omitempty"`
+}
+
+// generateContentResponse is the Gemini generateContent response body (and
+// the shape of each streamed chunk).
+type generateContentResponse struct {
+	Candidates    []geminiCandidate    `json:"candidates"`
+	UsageMetadata *geminiUsageMetadata `json:"usageMetadata,omitempty"`
+	ModelVersion  string               `json:"modelVersion,omitempty"`
+	ResponseID    string               `json:"responseId,omitempty"`
+}
+
+type geminiCandidate struct {
+	Content      geminiContent `json:"content"`
+	FinishReason string        `json:"finishReason,omitempty"`
+	Index        int           `json:"index"`
+}
+
+type geminiUsageMetadata struct {
+	PromptTokenCount        int `json:"promptTokenCount"`
+	CandidatesTokenCount    int `json:"candidatesTokenCount"`
+	CachedContentTokenCount int `json:"cachedContentTokenCount,omitempty"`
+	ThoughtsTokenCount      int `json:"thoughtsTokenCount,omitempty"`
+	TotalTokenCount         int `json:"totalTokenCount"`
+}
+
+// GenerateContent handles POST /gateway/v1beta/models/*, dispatching on
+// the ":generateContent" / ":streamGenerateContent" method suffix.
+func (s *Server) GenerateContent(w http.ResponseWriter, r *http.Request) {
+	if key := r.URL.Query().Get("key"); key != "" && r.Header.Get("x-goog-api-key") == "" {
+		r.Header.Set("x-goog-api-key", key)
+	}
+	auth, authErr := s.authenticateRequest(r)
+	if authErr != "" {
+		writeGeminiError(w, http.StatusUnauthorized, authErr)
+		return
+	}
+
+	model, method, ok := parseGeminiModelPath(r.PathValue("*"))
+	if !ok {
+		writeGeminiError(w, http.StatusNotFound, fmt.Sprintf("unsupported path %q; expected models/{provider/model}:generateContent", r.URL.Path))
+		return
+	}
+	stream := method == "streamGenerateContent"
+	if !stream && method != "generateContent" {
+		writeGeminiError(w, http.StatusNotFound, fmt.Sprintf("method %q is not supported", method))
+		return
+	}
+
+	rawBody, _ := io.ReadAll(r.Body)
+	var req generateContentRequest
+	if err := json.Unmarshal(rawBody, &req); err != nil {
+		writeGeminiError(w, http.StatusBadRequest, fmt.Sprintf("invalid request body: %v", err))
+		return
+	}
+	traceID, sessionID := auditTraceInfo(r)
+
+	chain := s.chatCallChain(auth, model, req.AtFallbacks)
+	if first := chain[0]; first.err != nil {
+		writeGeminiError(w, messagesTargetErrorStatus(first.err), first.err.Error())
+		return
+	}
+	if err := s.rejectGatewayHooks(r.Context(), auth, chainModels(chain)...); err != nil {
+		writeGeminiGatewayError(w, err)
+		return
+	}
+
+	chatMsgs, err := geminiContentsToOpenAIMessages(req.SystemInstruction, req.Contents)
+	if err != nil {
+		writeGeminiError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	chatTools := geminiToolsToOpenAI(req.Tools)
+
+	if limitMessage, resetErr := s.checkTokenLimits(r.Context(), auth); resetErr != nil {
+		slog.Error("token limit check failed", "error", resetErr)
+	} else if limitMessage != "" {
+		writeGeminiError(w, http.StatusTooManyRequests, limitMessage)
+		return
+	}
+
+	callCtx, cancel := withRequestTimeout(r.Context(), req.TimeoutMs)
+	defer cancel()
+
+	baseOpts := generateContentRequestToChatOptions(&req)
+
+	if stream {
+		// Streaming path: no fallback, same as chat streaming.
+		target := chain[0]
+		messages, tools := s.buildProviderMessages(target.info.providerType, chatMsgs, chatTools)
+		opts := cloneChatOptions(baseOpts)
+		if err := s.adaptToModelCapabilities(callCtx, &target, messages, tools, opts, true); err != nil {
+			writeGeminiGatewayError(w, err)
+			return
+		}
+		audit := streamAuditCtx{
+			auth: auth, source: "generate_content", endpoint: r.URL.Path,
+			traceID: traceID, sessionID: sessionID,
+			requestBody: rawBody, requestedModel: model,
+		}
+		rs := r.WithContext(callCtx)
+		sse := r.URL.Query().Get("alt") == "sse"
+		s.relayChatStream(w, rs, auth, target, messages, tools, opts, audit,
+			writeGeminiGatewayError,
+			func(chunks <-chan service.StreamChunk) streamRelayResult {
+				return s.writeGeminiStream(w, target.fullModel, sse, chunks)
+			})
+		return
+	}
+
+	var (
+		lastErr      error
+		used         chatCallTarget
+		resp         *service.LLMResponse
+		totalLatency int64
+	)
+	for i, target := range chain {
+		if target.err != nil {
+			continue
+		}
+		messages, tools := s.buildProviderMessages(target.info.providerType, chatMsgs, chatTools)
+		opts := cloneChatOptions(baseOpts)
+		if err := s.adaptToModelCapabilities(callCtx, &target, messages, tools, opts, false); err != nil {
+			lastErr = err
+			continue
+		}
+
+		callStart := time.Now()
+		r2, err := callWithGatewayRetry(callCtx, target.providerKey, target.actualModel,
+			target.info.RetryAfterCap(),
+			func(ctx context.Context) (*service.LLMResponse, error) {
+				return target.info.provider.Chat(ctx, target.actualModel, messages, tools, opts)
+			})
+		totalLatency += time.Since(callStart).Milliseconds()
+		if err == nil {
+			resp = r2
+			used = target
+			break
+		}
+		lastErr = err
+		slog.Warn("generateContent provider call failed",
+			"attempt", i, "provider", target.providerKey, "model", target.actualModel, "error", err)
+		s.recordUsageAsync(r.Context(), auth, target.fullModel, service.Usage{}, totalLatency, "error", classifyHTTPError(err), err.Error())
+		s.recordLLMCallAsync(r.Context(), llmAuditParams{
+			auth: auth, source: "generate_content", endpoint: r.URL.Path,
+			traceID: traceID, sessionID: sessionID,
+			requestBody: rawBody, requestedModel: model, fullModel: target.fullModel,
+			latencyMs: totalLatency, status: "error",
+			errCode: classifyHTTPError(err), errMsg: err.Error(),
+		})
+		if !shouldFallback(err) {
+			break
+		}
+	}
+
+	if resp == nil {
+		writeGeminiGatewayError(w, lastErr)
+		return
+	}
+
+	if used.fullModel != model {
+		w.Header().Set("x-at-model-used", used.fullModel)
+	}
+	s.cacheThoughtSignatures(resp.ToolCalls)
+	out := buildGenerateContentResponse(used.fullModel, resp)
+	s.recordUsageAsync(r.Context(), auth, used.fullModel, resp.Usage, totalLatency, "ok", "", "")
+	if respBody, mErr := json.Marshal(out); mErr == nil {
+		s.recordLLMCallAsync(r.Context(), llmAuditParams{
+			auth: auth, source: "generate_content", endpoint: r.URL.Path,
+			traceID: traceID, sessionID: sessionID,
+			requestBody: rawBody, responseBody: respBody,
+			requestedModel: model, fullModel: used.fullModel,
+			usage: resp.Usage, latencyMs: totalLatency, status: "ok",
+			finishReason: normalizeFinishReason(resp),
+		})
+	}
+	httpResponseJSON(w, out, http.StatusOK)
+}
+
+// parseGeminiModelPath splits "provider/model:method" (the wildcard part
+// after /v1beta/models/) into the model ID and method name.
+func parseGeminiModelPath(p string) (model, method string, ok bool) {
+	if unescaped, err := url.PathUnescape(p); err == nil {
+		p = unescaped
+	}
+	p = strings.TrimPrefix(p, "/")
+	i := strings.LastIndex(p, ":")
+	if i <= 0 || i == len(p)-1 {
+		return "", "", false
+	}
+	return p[:i], p[i+1:], true
+}
+
+// writeGeminiStream relays chunks as generateContent responses, either as
+// SSE events (alt=sse) or as the elements of a streamed JSON array.
+func (s *Server) writeGeminiStream(w http.ResponseWriter, model string, sse bool, chunks <-chan service.StreamChunk) streamRelayResult {
+	res := streamRelayResult{id: ulid.Make().String()}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeGeminiError(w, http.StatusInternalServerError, "streaming not supported by this server")
+		res.err = fmt.Errorf("streaming not supported")
+		return res
+	}
+	if sse {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.Header().Set("X-Accel-Buffering", "no")
+	} else {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, "[")
+	}
+
+	wrote := false
+	emit := func(v any) {
+		buf, _ := json.Marshal(v)
+		if sse {
+			fmt.Fprintf(w, "data: %s\n\n", buf)
+		} else {
+			if wrote {
+				_, _ = io.WriteString(w, ",\n")
+			}
+			_, _ = w.Write(buf)
+		}
+		wrote = true
+		flusher.Flush()
+	}
+
+	var (
+		content   strings.Builder
+		reasoning strings.Builder
+		rawFinish string
+	)
+	for chunk := range chunks {
+		if chunk.Error != nil {
+			slog.Error("generateContent stream chunk error", "model", model, "error", chunk.Error)
+			emit(map[string]any{"error": geminiErrorStatus(http.StatusBadGateway, chunk.Error.Error())})
+			res.err = chunk.Error
+			break
+		}
+		if chunk.Usage != nil {
+			u := *chunk.Usage
+			res.usage = &u
+		}
+		if chunk.FinishReason != "" {
+			rawFinish = chunk.FinishReason
+		}
+		content.WriteString(chunk.Content)
+		reasoning.WriteString(chunk.ReasoningContent)
+		res.toolCalls = append(res.toolCalls, chunk.ToolCalls...)
+		s.cacheThoughtSignatures(chunk.ToolCalls)
+
+		parts := geminiResponseParts(chunk.ReasoningContent, chunk.Content, chunk.InlineImages, chunk.ToolCalls)
+		if len(parts) == 0 {
+			continue
+		}
+		emit(generateContentResponse{
+			Candidates:   []geminiCandidate{{Content: geminiContent{Role: "model", Parts: parts}}},
+			ModelVersion: model,
+			ResponseID:   res.id,
+		})
+	}
+
+	res.content, res.reasoning = content.String(), reasoning.String()
+	if res.err != nil {
+		res.finishReason = "error"
+	} else {
+		res.finishReason = mapStreamFinishReason(rawFinish, len(res.toolCalls) > 0)
+		emit(generateContentResponse{
+			Candidates: []geminiCandidate{{
+				Content:      geminiContent{Role: "model", Parts: []geminiPart{{Text: ""}}},
+				FinishReason: geminiFinishReason(res.finishReason),
+			}},
+			UsageMetadata: geminiUsageFromService(usageOrZero(res.usage)),
+			ModelVersion:  model,
+			ResponseID:    res.id,
+		})
+	}
+	if !sse {
+		_, _ = io.WriteString(w, "]")
+		flusher.Flush()
+	}
+	return res
+}
+
+// geminiContentsToOpenAIMessages translates systemInstruction + contents
+// into chat.completions messages. Gemini function calls may carry no ID,
+// so calls without one get a synthesized ID and function responses are
+// matched to calls by ID or, failing that, by name in call order.
+func geminiContentsToOpenAIMessages(system *geminiContent, contents []geminiContent) ([]OpenAIMessage, error) {
+	var out []OpenAIMessage
+
+	if system != nil {
+		var texts []string
+		for _, p := range system.Parts {
+			if p.Text != "" {
+				texts = append(texts, p.Text)
+			}
+		}
+		if len(texts) > 0 {
+			out = append(out, OpenAIMessage{Role: "system", Content: json.RawMessage(mustJSONString(strings.Join(texts, "\n")))})
+		}
+	}
+
+	pending := map[string][]string{} // function name → unanswered call IDs
+	callSeq := 0
+
+	for i, c := range contents {
+		switch c.Role {
+		case "model":
+			var (
+				text  strings.Builder
+				calls []OpenAIToolCall
+			)
+			for _, p := range c.Parts {
+				switch {
+				case p.FunctionCall != nil:
+					id := p.FunctionCall.ID
+					if id == "" {
+						callSeq++
+						id = fmt.Sprintf("call_%s_%d", p.FunctionCall.Name, callSeq)
+					}
+					pending[p.FunctionCall.Name] = append(pending[p.FunctionCall.Name], id)
+					args := p.FunctionCall.Args
+					if args == nil {
+						args = map[string]any{}
+					}
+					argsJSON, _ := json.Marshal(args)
+					calls = append(calls, OpenAIToolCall{
+						ID:               id,
+						Type:             "function",
+						ThoughtSignature: p.ThoughtSignature,
+						Function:         OpenAIFunctionCall{Name: p.FunctionCall.Name, Arguments: string(argsJSON)},
+					})
+				case p.Thought:
+					// Thought summaries are not replayed upstream.
+				case p.Text != "":
+					text.WriteString(p.Text)
+				}
+			}
+			out = append(out, OpenAIMessage{
+				Role:      "assistant",
+				Content:   json.RawMessage(mustJSONString(text.String())),
+				ToolCalls: calls,
+			})
+
+		case "user", "":
+			var parts []map[string]any
+			for _, p := range c.Parts {
+				switch {
+				case p.FunctionResponse != nil:
+					id := p.FunctionResponse.ID
+					if ids := pending[p.FunctionResponse.Name]; len(ids) > 0 {
+						if id == "" {
+							id = ids[0]
+						}
+						pending[p.FunctionResponse.Name] = removeString(ids, id)
+					}
+					if id == "" {
+						return nil, fmt.Errorf("contents[%d]: functionResponse %q has no matching functionCall", i, p.FunctionResponse.Name)
+					}
+					result, _ := json.Marshal(p.FunctionResponse.Response)
+					out = append(out, OpenAIMessage{
+						Role:       "tool",
+						ToolCallID: id,
+						Name:       p.FunctionResponse.Name,
+						Content:    json.RawMessage(mustJSONString(string(result))),
+					})
+				case p.InlineData != nil:
+					part, err := geminiBlobToOpenAIPart(p.InlineData)
+					if err != nil {
+						return nil, fmt.Errorf("contents[%d]: %w", i, err)
+					}
+					parts = append(parts, part)
+				case p.FileData != nil:
+					if !strings.HasPrefix(p.FileData.MimeType, "image/") && p.FileData.MimeType != "" {
+						return nil, fmt.Errorf("contents[%d]: fileData with mimeType %q is not supported", i, p.FileData.MimeType)
+					}
+					parts = append(parts, map[string]any{
+						"type":      "image_url",
+						"image_url": map[string]any{"url": p.FileData.FileURI},
+					})
+				case p.Text != "":
+					parts = append(parts, map[string]any{"type": "text", "text": p.Text})
+				}
+			}
+			if len(parts) > 0 {
+				buf, err := json.Marshal(parts)
+				if err != nil {
+					return nil, err
+				}
+				out = append(out, OpenAIMessage{Role: "user", Content: buf})
+			}
+
+		default:
+			return nil, fmt.Errorf("contents[%d]: unsupported role %q", i, c.Role)
+		}
+	}
+
+	return out, nil
+}
+
+// geminiBlobToOpenAIPart maps inlineData onto the chat.completions content
+// part for its media type.
+func geminiBlobToOpenAIPart(b *geminiBlob) (map[string]any, error) {
+	switch {
+	case strings.HasPrefix(b.MimeType, "image/"):
+		return map[string]any{
+			"type":      "image_url",
+			"image_url": map[string]any{"url": "data:" + b.MimeType + ";base64," + b.Data},
+		}, nil
+	case strings.HasPrefix(b.MimeType, "audio/"):
+		return map[string]any{
+			"type":        "input_audio",
+			"input_audio": map[string]any{"data": b.Data, "format": strings.TrimPrefix(b.MimeType, "audio/")},
+		}, nil
+	case b.MimeType == "application/pdf" || strings.HasPrefix(b.MimeType, "text/") ||
+		strings.HasPrefix(b.MimeType, "video/"):
+		return map[string]any{
+			"type": "file",
+			"file": map[string]any{"file_data": map[string]any{"mime_type": b.MimeType, "data": b.Data}},
+		}, nil
+	}
+	return nil, fmt.Errorf("inlineData with mimeType %q is not supported", b.MimeType)
+}
+
+func removeString(list []string, s string) []string {
+	for i, v := range list {
+		if v == s {
+			return append(list[:i:i], list[i+1:]...)
+		}
+	}
+	return list
+}
+
+// geminiToolsToOpenAI converts functionDeclarations to chat.completions
+// tools. Gemini's OpenAPI-style schemas use upper-case type names, which
+// are lowered so JSON-Schema providers accept them.
+func geminiToolsToOpenAI(tools []geminiTool) []OpenAITool {
+	var out []OpenAITool
+	for _, t := range tools {
+		for _, fd := range t.FunctionDeclarations {
+			params := fd.ParametersJSONSchema
+			if params == nil {
+				params = fd.Parameters
+			}
+			out = append(out, OpenAITool{
+				Type: "function",
+				Function: OpenAIFunction{
+					Name:        fd.Name,
+					Description: fd.Description,
+					Parameters:  lowerSchemaTypes(params),
+				},
+			})
+		}
+	}
+	return out
+}
+
+// lowerSchemaTypes returns a copy of schema with every "type" string
+// lower-cased ("OBJECT" → "object").
+func lowerSchemaTypes(schema map[string]any) map[string]any {
+	if schema == nil {
+		return nil
+	}
+	out := make(map[string]any, len(schema))
+	for k, v := range schema {
+		switch vv := v.(type) {
+		case string:
+			if k == "type" {
+				vv = strings.ToLower(vv)
+			}
+			out[k] = vv
+		case map[string]any:
+			out[k] = lowerSchemaTypes(vv)
+		case []any:
+			items := make([]any, len(vv))
+			for i, item := range vv {
+				if m, ok := item.(map[string]any); ok {
+					items[i] = lowerSchemaTypes(m)
+				} else {
+					items[i] = item
+				}
+			}
+			out[k] = items
+		default:
+			out[k] = v
+		}
+	}
+	return out
+}
+
+// generateContentRequestToChatOptions builds ChatOptions from
+// generationConfig and toolConfig. Returns nil when nothing was set.
+func generateContentRequestToChatOptions(req *generateContentRequest) *service.ChatOptions {
+	opts := &service.ChatOptions{}
+	hasAny := false
+
+	if gc := req.GenerationConfig; gc != nil {
+		if gc.MaxOutputTokens != nil {
+			opts.MaxTokens = gc.MaxOutputTokens
+			hasAny = true
+		}
+		if gc.Temperature != nil {
+			opts.Temperature = gc.Temperature
+			hasAny = true
+		}
+		if gc.TopP != nil {
+			opts.TopP = gc.TopP
+			hasAny = true
+		}
+		if len(gc.StopSequences) > 0 {
+			opts.Stop = gc.StopSequences
+			hasAny = true
+		}
+		if gc.Seed != nil {
+			opts.Seed = gc.Seed
+			hasAny = true
+		}
+		if gc.CandidateCount != nil {
+			opts.N = gc.CandidateCount
+			hasAny = true
+		}
+		if gc.PresencePenalty != nil {
+			opts.PresencePenalty = gc.PresencePenalty
+			hasAny = true
+		}
+		if gc.FrequencyPenalty != nil {
+			opts.FrequencyPenalty = gc.FrequencyPenalty
+			hasAny = true
+		}
+		if gc.ResponseMimeType == "application/json" {
+			schema := gc.ResponseJSONSchema
+			if schema == nil {
+				schema = lowerSchemaTypes(gc.ResponseSchema)
+			}
+			if schema != nil {
+				opts.ResponseFormat = map[string]any{
+					"type":        "json_schema",
+					"json_schema": map[string]any{"name": "response", "schema": schema},
+				}
+			} else {
+				opts.ResponseFormat = map[string]any{"type": "json_object"}
+			}
+			hasAny = true
+		}
+		if tc := gc.ThinkingConfig; tc != nil && tc.ThinkingBudget != nil && *tc.ThinkingBudget != 0 {
+			budget := *tc.ThinkingBudget
+			if budget < 0 {
+				budget = 0 // dynamic: let the provider pick
+			}
+			opts.Thinking = &service.ThinkingConfig{Type: "enabled", BudgetTokens: budget}
+			hasAny = true
+		}
+	}
+
+	if req.ToolConfig != nil && req.ToolConfig.FunctionCallingConfig != nil {
+		fcc := req.ToolConfig.FunctionCallingConfig
+		switch strings.ToUpper(fcc.Mode) {
+		case "AUTO":
+			opts.ToolChoice = "auto"
+		case "NONE":
+			opts.ToolChoice = "none"
+		case "ANY":
+			if len(fcc.AllowedFunctionNames) == 1 {
+				opts.ToolChoice = map[string]any{
+					"type":     "function",
+					"function": map[string]any{"name": fcc.AllowedFunctionNames[0]},
+				}
+			} else {
+				opts.ToolChoice = "required"
+			}
+		}
+		hasAny = hasAny || opts.ToolChoice != nil
+	}
+
+	if len(req.ExtraBody) > 0 {
+		opts.ExtraBody = req.ExtraBody
+		hasAny = true
+	}
+
+	if !hasAny {
+		return nil
+	}
+	return opts
+}
+
+// buildGenerateContentResponse maps an internal LLMResponse to the Gemini
+// generateContent response shape.
+func buildGenerateContentResponse(model string, resp *service.LLMResponse) *generateContentResponse {
+	parts := geminiResponseParts(resp.ReasoningContent, resp.Content, resp.InlineImages, resp.ToolCalls)
+	if len(parts) == 0 {
+		parts = []geminiPart{{Text: ""}}
+	}
+	return &generateContentResponse{
+		Candidates: []geminiCandidate{{
+			Content:      geminiContent{Role: "model", Parts: parts},
+			FinishReason: geminiFinishReason(normalizeFinishReason(resp)),
+		}},
+		UsageMetadata: geminiUsageFromService(resp.Usage),
+		ModelVersion:  model,
+		ResponseID:    ulid.Make().String(),
+	}
+}
+
+// geminiResponseParts renders one assistant turn (or stream delta) as
+// Gemini parts: thought summary, text, inline images, function calls.
+func geminiResponseParts(reasoning, text string, images []service.InlineImage, toolCalls []service.ToolCall) []geminiPart {
+	var parts []geminiPart
+	if reasoning != "" {
+		parts = append(parts, geminiPart{Text: reasoning, Thought: true})
+	}
+	if text != "" {
+		parts = append(parts, geminiPart{Text: text})
+	}
+	for _, img := range images {
+		parts = append(parts, geminiPart{InlineData: &geminiBlob{MimeType: img.MimeType, Data: img.Data}})
+	}
+	for _, tc := range toolCalls {
+		args := tc.Arguments
+		if args == nil {
+			args = map[string]any{}
+		}
+		parts = append(parts, geminiPart{
+			FunctionCall:     &geminiFunctionCall{ID: tc.ID, Name: tc.Name, Args: args},
+			ThoughtSignature: tc.ThoughtSignature,
+		})
+	}
+	return parts
+}
+
+// geminiFinishReason maps an OpenAI-vocabulary finish reason onto Gemini's
+// finishReason enum. Gemini reports function calls as STOP.
+func geminiFinishReason(finishReason string) string {
+	switch finishReason {
+	case "length":
+		return "MAX_TOKENS"
+	case "content_filter":
+		return "SAFETY"
+	default:
+		return "STOP"
+	}
+}
+
+// geminiUsageFromService is the inverse of the gemini provider's usage
+// normalisation: prompt counts include cached tokens and candidate counts
+// exclude thinking tokens.
+func geminiUsageFromService(u service.Usage) *geminiUsageMetadata {
+	candidates := u.CompletionTokens - u.ReasoningTokens
+	if candidates < 0 {
+		candidates = 0
+	}
+	return &geminiUsageMetadata{
+		PromptTokenCount:        u.PromptTokens + u.CacheReadTokens + u.CacheWriteTokens,
+		CandidatesTokenCount:    candidates,
+		CachedContentTokenCount: u.CacheReadTokens,
+		ThoughtsTokenCount:      u.ReasoningTokens,
+		TotalTokenCount:         u.TotalTokenCount(),
+	}
+}
+
+// writeGeminiGatewayError converts an upstream failure into the Google
+// error envelope, keeping the status and Retry-After that
+// classifyGatewayError picked.
+func writeGeminiGatewayError(w http.ResponseWriter, err error) {
+	status, body := classifyGatewayError(err)
+	addGatewayRateLimitHeaders(w, err)
+	msg := err.Error()
+	if e, ok := body["error"].(map[string]any); ok {
+		if m, ok := e["message"].(string); ok {
+			msg = m
+		}
+	}
+	writeGeminiError(w, status, msg)
+}
+
+// writeGeminiError writes a Google API error envelope.
+func writeGeminiError(w http.ResponseWriter, status int, msg string) {
+	httpResponseJSON(w, map[string]any{"error": geminiErrorStatus(status, msg)}, status)
+}
+
+func geminiErrorStatus(status int, msg string) map[string]any {
+	code := "INTERNAL"
+	switch status {
+	case http.StatusBadRequest:
+		code = "INVALID_ARGUMENT"
+	case http.StatusUnauthorized:
+		code = "UNAUTHENTICATED"
+	case http.StatusForbidden:
+		code = "PERMISSION_DENIED"
+	case http.StatusNotFound:
+		code = "NOT_FOUND"
+	case http.StatusTooManyRequests, 529:
+		code = "RESOURCE_EXHAUSTED"
+	case http.StatusNotImplemented:
+		code = "UNIMPLEMENTED"
+	case http.StatusBadGateway, http.StatusServiceUnavailable:
+		code = "UNAVAILABLE"
+	case http.StatusGatewayTimeout:
+		code = "DEADLINE_EXCEEDED"
+	}
+	return map[string]any{"code": status, "message": msg, "status": code}
+}
@@ -0,0 +1,188 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rakunlabs/at/internal/service"
+)
+
+func doGenerateContent(s *Server, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.SetPathValue("*", strings.TrimPrefix(strings.SplitN(path, "?", 2)[0], "/gateway/v1beta/models/"))
+	rec := httptest.NewRecorder()
+	s.GenerateContent(rec, req)
+	return rec
+}
+
+func TestGeminiContentsToOpenAIMessages(t *testing.T) {
+	var req generateContentRequest
+	if err := json.Unmarshal([]byte(`{
+		"systemInstruction":{"parts":[{"text":"be brief"}]},
+		"contents":[
+			{"role":"user","parts":[{"text":"what is this?"},{"inlineData":{"mimeType":"image/png","data":"AAAA"}}]},
+			{"role":"model","parts":[{"text":"hmm","thought":true},{"functionCall":{"name":"lookup","args":{"q":"go"}},"thoughtSignature":"sig-1"}]},
+			{"role":"user","parts":[{"functionResponse":{"name":"lookup","response":{"result":"found"}}}]}
+		]
+	}`), &req); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := geminiContentsToOpenAIMessages(req.SystemInstruction, req.Contents)
+	if err != nil {
+		t.Fatalf("geminiContentsToOpenAIMessages: %v", err)
+	}
+	roles := make([]string, len(out))
+	for i, m := range out {
+		roles[i] = m.Role
+	}
+	if got := strings.Join(roles, ","); got != "system,user,assistant,tool" {
+		t.Fatalf("roles = %s", got)
+	}
+	if !strings.Contains(string(out[1].Content), `"url":"data:image/png;base64,AAAA"`) {
+		t.Errorf("image part = %s", out[1].Content)
+	}
+	call := out[2].ToolCalls
+	if extractContentString(out[2].Content) != "" || len(call) != 1 || call[0].ThoughtSignature != "sig-1" || call[0].Function.Arguments != `{"q":"go"}` {
+		t.Errorf("assistant = %+v", out[2])
+	}
+	if out[3].ToolCallID != call[0].ID || extractContentString(out[3].Content) != `{"result":"found"}` {
+		t.Errorf("tool result = %+v", out[3])
+	}
+
+	// A response with no preceding call cannot be paired.
+	if _, err := geminiContentsToOpenAIMessages(nil, req.Contents[2:]); err == nil {
+		t.Error("expected error for unmatched functionResponse")
+	}
+}
+
+func TestGenerateContentRoutesToProvider(t *testing.T) {
+	provider := &messagesCaptureProvider{resp: &service.LLMResponse{
+		Content:          "hello",
+		ReasoningContent: "pondering",
+		ToolCalls:        []service.ToolCall{{ID: "call_1", Name: "lookup", Arguments: map[string]any{"q": "go"}}},
+		FinishReason:     "tool_calls",
+		Usage:            service.Usage{PromptTokens: 12, CompletionTokens: 6, ReasoningTokens: 2, CacheReadTokens: 3},
+	}}
+	s := newMessagesTestServer(provider, "openai")
+
+	rec := doGenerateContent(s, "/gateway/v1beta/models/gemini/gpt-4o:generateContent?key=test-token", `{
+		"contents":[{"role":"user","parts":[{"text":"hi"}]}],
+		"tools":[{"functionDeclarations":[{"name":"lookup","description":"search","parameters":{"type":"OBJECT","properties":{"q":{"type":"STRING"}}}}]}],
+		"toolConfig":{"functionCallingConfig":{"mode":"ANY","allowedFunctionNames":["lookup"]}},
+		"generationConfig":{"maxOutputTokens":256,"temperature":0.2,"thinkingConfig":{"thinkingBudget":1024}}
+	}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d; body=%s", rec.Code, rec.Body.String())
+	}
+
+	if len(provider.tools) != 1 || provider.tools[0].InputSchema["type"] != "object" {
+		t.Errorf("tools = %+v", provider.tools)
+	}
+	if provider.opts == nil || *provider.opts.MaxTokens != 256 || *provider.opts.Temperature != 0.2 ||
+		provider.opts.Thinking == nil || provider.opts.Thinking.BudgetTokens != 1024 {
+		t.Errorf("opts = %+v", provider.opts)
+	}
+	if tc, ok := provider.opts.ToolChoice.(map[string]any); !ok || tc["type"] != "function" {
+		t.Errorf("tool choice = %+v", provider.opts.ToolChoice)
+	}
+
+	var resp generateContentResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(resp.Candidates) != 1 || resp.Candidates[0].FinishReason != "STOP" {
+		t.Fatalf("response = %s", rec.Body.String())
+	}
+	parts := resp.Candidates[0].Content.Parts
+	if len(parts) != 3 || !parts[0].Thought || parts[1].Text != "hello" || parts[2].FunctionCall == nil || parts[2].FunctionCall.Args["q"] != "go" {
+		t.Errorf("parts = %s", rec.Body.String())
+	}
+	u := resp.UsageMetadata
+	if u == nil || u.PromptTokenCount != 15 || u.CachedContentTokenCount != 3 || u.CandidatesTokenCount != 4 || u.ThoughtsTokenCount != 2 {
+		t.Errorf("usage = %+v", u)
+	}
+}
+
+func TestStreamGenerateContentSSE(t *testing.T) {
+	provider := &messagesCaptureProvider{}
+	s := newMessagesTestServer(provider, "openai")
+
+	rec := doGenerateContent(s, "/gateway/v1beta/models/gemini/gpt-4o:streamGenerateContent?alt=sse&key=test-token",
+		`{"contents":[{"role":"user","parts":[{"text":"hi"}]}]}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d; body=%s", rec.Code, rec.Body.String())
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("content type = %q", ct)
+	}
+
+	var chunks []generateContentResponse
+	for _, line := range strings.Split(rec.Body.String(), "\n") {
+		if data, ok := strings.CutPrefix(line, "data: "); ok {
+			var c generateContentResponse
+			if err := json.Unmarshal([]byte(data), &c); err != nil {
+				t.Fatalf("decode %s: %v", data, err)
+			}
+			chunks = append(chunks, c)
+		}
+	}
+	if len(chunks) != 5 {
+		t.Fatalf("got %d chunks: %s", len(chunks), rec.Body.String())
+	}
+	if !chunks[0].Candidates[0].Content.Parts[0].Thought || chunks[1].Candidates[0].Content.Parts[0].Text != "Hel" {
+		t.Errorf("chunks = %s", rec.Body.String())
+	}
+	if fc := chunks[3].Candidates[0].Content.Parts[0].FunctionCall; fc == nil || fc.Name != "lookup" {
+		t.Errorf("function call chunk = %+v", chunks[3])
+	}
+	last := chunks[4]
+	if last.Candidates[0].FinishReason != "STOP" || last.UsageMetadata == nil || last.UsageMetadata.CandidatesTokenCount != 5 {
+		t.Errorf("final chunk = %+v", last)
+	}
+}
+
+func TestStreamGenerateContentJSONArray(t *testing.T) {
+	s := newMessagesTestServer(&messagesCaptureProvider{}, "openai")
+
+	rec := doGenerateContent(s, "/gateway/v1beta/models/gemini/gpt-4o:streamGenerateContent?key=test-token",
+		`{"contents":[{"role":"user","parts":[{"text":"hi"}]}]}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d; body=%s", rec.Code, rec.Body.String())
+	}
+	var chunks []generateContentResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &chunks); err != nil {
+		t.Fatalf("body is not a JSON array: %v\n%s", err, rec.Body.String())
+	}
+	if len(chunks) != 5 {
+		t.Errorf("got %d chunks", len(chunks))
+	}
+}
+
+func TestGenerateContentErrorsUseGoogleEnvelope(t *testing.T) {
+	s := newMessagesTestServer(&messagesCaptureProvider{}, "openai")
+
+	rec := doGenerateContent(s, "/gateway/v1beta/models/gemini/gpt-4o:generateContent", `{}`)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("status = %d", rec.Code)
+	}
+
+	rec = doGenerateContent(s, "/gateway/v1beta/models/missing/model:generateContent?key=test-token",
+		`{"contents":[{"role":"user","parts":[{"text":"hi"}]}]}`)
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("status = %d", rec.Code)
+	}
+	var body struct {
+		Error struct {
+			Code   int    `json:"code"`
+			Status string `json:"status"`
+		} `json:"error"`
+	}
+	_ = json.Unmarshal(rec.Body.Bytes(), &body)
+	if body.Error.Code != http.StatusNotFound || body.Error.Status != "NOT_FOUND" {
+		t.Errorf("body = %s", rec.Body.String())
+	}
+}
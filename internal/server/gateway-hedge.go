@@ -0,0 +1,420 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/rakunlabs/at/internal/service"
+)
+
+// ─── Hedged requests (at_hedge) ───
+//
+// For latency-critical callers a slow answer is as bad as a failed one.
+// With `at_hedge: {"delay_ms": N}` the gateway sends the request to the
+// primary model and, when no first token has arrived after N ms, sends
+// the same request to the first usable at_fallbacks entry. Whichever
+// produces a first token first is used; the other attempt's context is
+// cancelled. A primary that fails before the delay starts the hedge
+// right away, so hedging also covers the plain fallback case.
+//
+// Unlike the fallback chain, hedging works for streaming requests too:
+// the race is decided before any SSE bytes are written. Attempts are not
+// wrapped in the 429 retry loop — waiting out a Retry-After defeats the
+// point — and fallbacks past the hedge target are not tried.
+//
+// Both attempts are recorded as observations of the same trace with
+// metadata {"hedge_role": "primary"|"hedge", "hedge_winner": bool}. The
+// losing attempt is recorded with status "cancelled" (or "error" when it
+// failed on its own).
+
+// defaultHedgeDelay applies when at_hedge omits delay_ms.
+const defaultHedgeDelay = 500 * time.Millisecond
+
+// Hedge attempt roles, in race order.
+const (
+	hedgeRolePrimary = "primary"
+	hedgeRoleHedge   = "hedge"
+)
+
+// delay returns the configured hedge delay.
+func (h *HedgeOptions) delay() time.Duration {
+	if h.DelayMs <= 0 {
+		return defaultHedgeDelay
+	}
+	return time.Duration(h.DelayMs) * time.Millisecond
+}
+
+// hedgeAttempt is one side of a hedged race: the target plus the request
+// translated and adapted for it.
+type hedgeAttempt struct {
+	role     string
+	target   chatCallTarget
+	messages []service.Message
+	tools    []service.Tool
+	opts     *service.ChatOptions
+}
+
+// hedgeTargetIndex returns the index in chain of the first usable
+// fallback, or -1 when there is none.
+func hedgeTargetIndex(chain []chatCallTarget) int {
+	for i := 1; i < len(chain); i++ {
+		if chain[i].err == nil {
+			return i
+		}
+	}
+	return -1
+}
+
+// prepareHedge translates the request for the primary and the hedge
+// target. ok is false when either target cannot serve the request as-is
+// (capability mismatch); the caller then takes the regular path.
+func (s *Server) prepareHedge(ctx context.Context, req *ChatCompletionRequest, chain []chatCallTarget, baseOpts *service.ChatOptions) ([2]hedgeAttempt, bool) {
+	var attempts [2]hedgeAttempt
+	idx := hedgeTargetIndex(chain)
+	if idx < 0 {
+		return attempts, false
+	}
+	for i, target := range []chatCallTarget{chain[0], chain[idx]} {
+		messages, tools := s.buildProviderMessages(target.info.providerType, req.Messages, req.Tools)
+		opts := cloneChatOptions(baseOpts)
+		if err := s.adaptToModelCapabilities(ctx, &target, messages, tools, opts, req.Stream); err != nil {
+			slog.Debug("gateway hedge: target cannot serve request, not hedging",
+				"model", target.fullModel, "error", err)
+			return attempts, false
+		}
+		role := hedgeRolePrimary
+		if i == 1 {
+			role = hedgeRoleHedge
+		}
+		attempts[i] = hedgeAttempt{role: role, target: target, messages: messages, tools: tools, opts: opts}
+	}
+	return attempts, true
+}
+
+// hedgeStats is the bookkeeping of one hedged race.
+type hedgeStats struct {
+	winner  int // index of the winning attempt; -1 when every started attempt failed
+	started [2]time.Time
+	latency [2]int64 // ms, for attempts that finished (won or failed)
+	errs    [2]error
+}
+
+// cancelled reports whether attempt i was started and then abandoned
+// because the other attempt won.
+func (h *hedgeStats) cancelled(i int) bool {
+	return !h.started[i].IsZero() && i != h.winner && h.errs[i] == nil
+}
+
+// lastErr returns the error to surface when no attempt won: the hedge's
+// when it ran, the primary's otherwise.
+func (h *hedgeStats) lastErr() error {
+	if h.errs[1] != nil {
+		return h.errs[1]
+	}
+	return h.errs[0]
+}
+
+// metadata is the observation metadata for attempt i.
+func (h *hedgeStats) metadata(attempts [2]hedgeAttempt, i int) map[string]any {
+	return map[string]any{"hedge_role": attempts[i].role, "hedge_winner": i == h.winner}
+}
+
+// hedgeOutcome is the result of runHedge.
+type hedgeOutcome[T any] struct {
+	hedgeStats
+	val T
+
+	// stop releases the winner's context. Call it once the result is no
+	// longer used (a stream has been fully relayed).
+	stop context.CancelFunc
+}
+
+type hedgeResult[T any] struct {
+	idx int
+	val T
+	err error
+}
+
+// runHedge starts attempt 0, then attempt 1 once delay passes without a
+// result or as soon as attempt 0 fails with a fallback-worthy error. The
+// first successful result wins and the other attempt's context is
+// cancelled; discard receives a success that arrives after the race is
+// decided so it can be released.
+func runHedge[T any](ctx context.Context, delay time.Duration, attempt func(ctx context.Context, i int) (T, error), discard func(T)) hedgeOutcome[T] {
+	out := hedgeOutcome[T]{hedgeStats: hedgeStats{winner: -1}}
+	results := make(chan hedgeResult[T], 2)
+
+	var cancels [2]context.CancelFunc
+	out.stop = func() {
+		for _, cancel := range cancels {
+			if cancel != nil {
+				cancel()
+			}
+		}
+	}
+
+	pending := 0
+	launch := func(i int) {
+		actx, cancel := context.WithCancel(ctx)
+		cancels[i] = cancel
+		out.started[i] = time.Now()
+		pending++
+		go func() {
+			v, err := attempt(actx, i)
+			results <- hedgeResult[T]{idx: i, val: v, err: err}
+		}()
+	}
+
+	launch(0)
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	for pending > 0 {
+		select {
+		case <-timer.C:
+			if out.started[1].IsZero() {
+				launch(1)
+			}
+		case res := <-results:
+			pending--
+			out.latency[res.idx] = time.Since(out.started[res.idx]).Milliseconds()
+			if res.err != nil {
+				out.errs[res.idx] = res.err
+				if res.idx == 0 && out.started[1].IsZero() && shouldFallback(res.err) {
+					launch(1)
+				}
+				continue
+			}
+
+			out.val, out.winner = res.val, res.idx
+			if other := 1 - res.idx; cancels[other] != nil {
+				cancels[other]()
+			}
+			if pending > 0 && discard != nil {
+				go func() {
+					if late := <-results; late.err == nil {
+						discard(late.val)
+					}
+				}()
+			}
+			return out
+		}
+	}
+	return out
+}
+
+// recordHedgeLosers records an observation for every started attempt
+// that did not win, plus a usage row for the ones that failed. base
+// carries the request-level audit fields.
+func (s *Server) recordHedgeLosers(ctx context.Context, base llmAuditParams, attempts [2]hedgeAttempt, h *hedgeStats) {
+	for i, a := range attempts {
+		if h.started[i].IsZero() || i == h.winner {
+			continue
+		}
+		p := base
+		p.fullModel = a.target.fullModel
+		p.metadata = h.metadata(attempts, i)
+		if h.cancelled(i) {
+			p.latencyMs = time.Since(h.started[i]).Milliseconds()
+			p.status = "cancelled"
+			p.errMsg = fmt.Sprintf("hedged request answered first by %s", attempts[1-i].target.fullModel)
+			s.recordLLMCallAsync(ctx, p)
+			continue
+		}
+
+		err := h.errs[i]
+		slog.Warn("gateway hedge: attempt failed",
+			"role", a.role, "provider", a.target.providerKey, "model", a.target.actualModel, "error", err)
+		p.latencyMs = h.latency[i]
+		p.status = "error"
+		p.errCode = classifyHTTPError(err)
+		p.errMsg = err.Error()
+		s.recordUsageAsync(ctx, base.auth, a.target.fullModel, choicesUsage(err), p.latencyMs, "error", p.errCode, p.errMsg)
+		s.recordLLMCallAsync(ctx, p)
+	}
+}
+
+// hedgedChat races the non-streaming chat call of both attempts.
+func (s *Server) hedgedChat(ctx context.Context, attempts [2]hedgeAttempt, delay time.Duration, base llmAuditParams) (*service.LLMResponse, hedgeStats) {
+	out := runHedge(ctx, delay, func(actx context.Context, i int) (*service.LLMResponse, error) {
+		a := attempts[i]
+		return chatChoices(actx, a.target.info.provider, a.opts, func(ctx context.Context, opts *service.ChatOptions) (*service.LLMResponse, error) {
+			return a.target.info.provider.Chat(ctx, a.target.actualModel, a.messages, a.tools, opts)
+		})
+	}, nil)
+	out.stop()
+	s.recordHedgeLosers(ctx, base, attempts, &out.hedgeStats)
+	return out.val, out.hedgeStats
+}
+
+// ─── Streaming ───
+
+// hedgedStream is an opened stream whose first token has arrived.
+type hedgedStream struct {
+	chunks <-chan service.StreamChunk
+	header http.Header
+}
+
+// openHedgedStream opens the attempt's stream and reads it up to the
+// first token. The returned channel replays what was read, then relays
+// the rest until ctx is cancelled. Providers without native streaming
+// answer with one chunk holding the whole response.
+func openHedgedStream(ctx context.Context, a hedgeAttempt) (hedgedStream, error) {
+	sp, ok := service.As[service.LLMStreamProvider](a.target.info.provider)
+	if !ok {
+		resp, err := a.target.info.provider.Chat(ctx, a.target.actualModel, a.messages, a.tools, a.opts)
+		if err != nil {
+			return hedgedStream{}, err
+		}
+		finish := resp.FinishReason
+		if finish == "" {
+			finish = "stop"
+		}
+		ch := make(chan service.StreamChunk, 1)
+		ch <- service.StreamChunk{
+			Content:          resp.Content,
+			ReasoningContent: resp.ReasoningContent,
+			InlineImages:     resp.InlineImages,
+			ToolCalls:        resp.ToolCalls,
+			FinishReason:     finish,
+			Usage:            &resp.Usage,
+		}
+		close(ch)
+		return hedgedStream{chunks: ch, header: resp.Header}, nil
+	}
+
+	chunks, header, err := sp.ChatStream(ctx, a.target.actualModel, a.messages, a.tools, a.opts)
+	if err != nil {
+		return hedgedStream{}, err
+	}
+
+	var prefix []service.StreamChunk
+	for {
+		select {
+		case <-ctx.Done():
+			go drainStream(chunks)
+			return hedgedStream{}, ctx.Err()
+		case chunk, ok := <-chunks:
+			if !ok {
+				return hedgedStream{chunks: replayStream(ctx, prefix, nil), header: header}, nil
+			}
+			if chunk.Error != nil {
+				go drainStream(chunks)
+				return hedgedStream{}, chunk.Error
+			}
+			prefix = append(prefix, chunk)
+			if chunk.Content != "" || chunk.ReasoningContent != "" || len(chunk.InlineImages) > 0 ||
+				len(chunk.ToolCalls) > 0 || chunk.FinishReason != "" {
+				return hedgedStream{chunks: replayStream(ctx, prefix, chunks), header: header}, nil
+			}
+		}
+	}
+}
+
+// replayStream sends prefix, then relays rest (when non-nil) until it
+// closes or ctx is cancelled.
+func replayStream(ctx context.Context, prefix []service.StreamChunk, rest <-chan service.StreamChunk) <-chan service.StreamChunk {
+	out := make(chan service.StreamChunk, 64)
+	go func() {
+		defer close(out)
+		for _, chunk := range prefix {
+			select {
+			case out <- chunk:
+			case <-ctx.Done():
+				if rest != nil {
+					drainStream(rest)
+				}
+				return
+			}
+		}
+		if rest == nil {
+			return
+		}
+		for chunk := range rest {
+			select {
+			case out <- chunk:
+			case <-ctx.Done():
+				drainStream(rest)
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// drainStream consumes a stream nobody reads anymore so the provider's
+// producer goroutine can exit.
+func drainStream(ch <-chan service.StreamChunk) {
+	for range ch {
+	}
+}
+
+// preparedStream serves an already opened stream through the
+// LLMStreamProvider interface so handleStreamingChat can relay it.
+type preparedStream struct {
+	hedgedStream
+}
+
+func (p preparedStream) Chat(context.Context, string, []service.Message, []service.Tool, *service.ChatOptions) (*service.LLMResponse, error) {
+	return nil, fmt.Errorf("%w: chat on a prepared stream", service.ErrUnsupportedOperation)
+}
+
+func (p preparedStream) ChatStream(context.Context, string, []service.Message, []service.Tool, *service.ChatOptions) (<-chan service.StreamChunk, http.Header, error) {
+	return p.chunks, p.header, nil
+}
+
+func (p preparedStream) Proxy(http.ResponseWriter, *http.Request, string) error {
+	return fmt.Errorf("%w: proxy on a prepared stream", service.ErrUnsupportedOperation)
+}
+
+// hedgedChatStream races both attempts up to their first token, then
+// relays the winner as SSE. Nothing is written to w before the race is
+// decided, so a double failure is still answered with a JSON error.
+// audit.onComplete is dropped when the hedge wins: answers from a
+// fallback model are not cached.
+func (s *Server) hedgedChatStream(
+	w http.ResponseWriter,
+	r *http.Request,
+	auth *authResult,
+	attempts [2]hedgeAttempt,
+	delay time.Duration,
+	streamOpts *StreamOptions,
+	audit streamAuditCtx,
+) {
+	out := runHedge(r.Context(), delay, func(actx context.Context, i int) (hedgedStream, error) {
+		return openHedgedStream(actx, attempts[i])
+	}, func(late hedgedStream) {
+		drainStream(late.chunks)
+	})
+	defer out.stop()
+
+	s.recordHedgeLosers(r.Context(), llmAuditParams{
+		auth: auth, source: audit.resolveSource(), endpoint: audit.endpoint,
+		traceID: audit.traceID, sessionID: audit.sessionID, userField: audit.userField,
+		requestBody: audit.requestBody, requestedModel: audit.requestedModel, streamed: true,
+	}, attempts, &out.hedgeStats)
+
+	if out.winner < 0 {
+		err := out.lastErr()
+		status, body := classifyGatewayError(err)
+		addGatewayRateLimitHeaders(w, err)
+		httpResponseJSON(w, body, status)
+		return
+	}
+
+	win := attempts[out.winner]
+	w.Header().Set("x-at-hedge-winner", win.role)
+	if win.target.fullModel != audit.requestedModel {
+		w.Header().Set("x-at-model-used", win.target.fullModel)
+		audit.onComplete = nil
+	}
+	audit.metadata = out.metadata(attempts, out.winner)
+	audit.startedAt = out.started[out.winner]
+
+	s.handleStreamingChat(w, r, auth, preparedStream{out.val}, 0,
+		win.target.providerKey, win.target.actualModel, win.target.fullModel,
+		win.messages, win.tools, streamOpts, win.opts, audit)
+}
@@ -0,0 +1,217 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rakunlabs/at/internal/service"
+)
+
+// hedgeTestProvider answers after delay (or fails with err) and reports
+// whether its call was cancelled.
+type hedgeTestProvider struct {
+	delay     time.Duration
+	content   string
+	err       error
+	cancelled chan struct{}
+}
+
+func newHedgeTestProvider(delay time.Duration, content string) *hedgeTestProvider {
+	return &hedgeTestProvider{delay: delay, content: content, cancelled: make(chan struct{}, 1)}
+}
+
+func (p *hedgeTestProvider) wait(ctx context.Context) error {
+	if p.err != nil {
+		return p.err
+	}
+	select {
+	case <-time.After(p.delay):
+		return nil
+	case <-ctx.Done():
+		p.cancelled <- struct{}{}
+		return ctx.Err()
+	}
+}
+
+func (p *hedgeTestProvider) Chat(ctx context.Context, _ string, _ []service.Message, _ []service.Tool, _ *service.ChatOptions) (*service.LLMResponse, error) {
+	if err := p.wait(ctx); err != nil {
+		return nil, err
+	}
+	return &service.LLMResponse{Content: p.content, Finished: true, Usage: service.Usage{PromptTokens: 3, CompletionTokens: 1}}, nil
+}
+
+func (p *hedgeTestProvider) ChatStream(ctx context.Context, _ string, _ []service.Message, _ []service.Tool, _ *service.ChatOptions) (<-chan service.StreamChunk, http.Header, error) {
+	if p.err != nil {
+		return nil, nil, p.err
+	}
+	ch := make(chan service.StreamChunk)
+	go func() {
+		defer close(ch)
+		if p.wait(ctx) != nil {
+			return
+		}
+		for _, c := range []service.StreamChunk{{Content: p.content}, {FinishReason: "stop", Usage: &service.Usage{PromptTokens: 3, CompletionTokens: 1}}} {
+			select {
+			case ch <- c:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch, nil, nil
+}
+
+func (p *hedgeTestProvider) Proxy(http.ResponseWriter, *http.Request, string) error { return nil }
+
+func newHedgeTestServer(slow, fast service.LLMProvider) (*Server, *fakeLLMCallStore) {
+	obs := &fakeLLMCallStore{}
+	s := &Server{
+		providers: map[string]ProviderInfo{
+			"slow": {provider: slow, providerType: "openai"},
+			"fast": {provider: fast, providerType: "openai"},
+		},
+		tokenStore: gatewayTestToken("test-token", service.APIToken{
+			ID:                   "tok-1",
+			AllowedProvidersMode: service.AccessModeAll,
+			AllowedModelsMode:    service.AccessModeAll,
+		}),
+		llmCallStore: obs,
+	}
+	return s, obs
+}
+
+// hedgeObservations maps hedge role to the recorded observation.
+func hedgeObservations(t *testing.T, obs *fakeLLMCallStore, n int) map[string]service.LLMCall {
+	t.Helper()
+	out := map[string]service.LLMCall{}
+	for _, o := range waitForObservations(t, obs, n) {
+		role, _ := o.Metadata["hedge_role"].(string)
+		out[role] = o
+	}
+	return out
+}
+
+func TestChatCompletionsHedgeWinsOverSlowPrimary(t *testing.T) {
+	slow := newHedgeTestProvider(5*time.Second, "slow answer")
+	fast := newHedgeTestProvider(0, "fast answer")
+	s, obs := newHedgeTestServer(slow, fast)
+
+	start := time.Now()
+	rec := doCapabilityChat(s, `{"model":"slow/m","at_fallbacks":["fast/m"],"at_hedge":{"delay_ms":20},
+		"messages":[{"role":"user","content":"hi"}]}`)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "fast answer") {
+		t.Fatalf("status = %d; body=%s", rec.Code, rec.Body.String())
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("hedged request took %v", elapsed)
+	}
+	if got := rec.Header().Get("x-at-hedge-winner"); got != hedgeRoleHedge {
+		t.Errorf("x-at-hedge-winner = %q", got)
+	}
+	if got := rec.Header().Get("x-at-model-used"); got != "fast/m" {
+		t.Errorf("x-at-model-used = %q", got)
+	}
+
+	select {
+	case <-slow.cancelled:
+	case <-time.After(2 * time.Second):
+		t.Error("losing primary was not cancelled")
+	}
+
+	calls := hedgeObservations(t, obs, 2)
+	primary, hedge := calls[hedgeRolePrimary], calls[hedgeRoleHedge]
+	if primary.TraceID == "" || primary.TraceID != hedge.TraceID {
+		t.Errorf("trace ids = %q / %q, want one shared trace", primary.TraceID, hedge.TraceID)
+	}
+	if primary.Status != "cancelled" || primary.Metadata["hedge_winner"] != false {
+		t.Errorf("primary = %s %v", primary.Status, primary.Metadata)
+	}
+	if hedge.Status != "ok" || hedge.Metadata["hedge_winner"] != true || hedge.Model != "m" || hedge.Provider != "fast" {
+		t.Errorf("hedge = %s %s/%s %v", hedge.Status, hedge.Provider, hedge.Model, hedge.Metadata)
+	}
+}
+
+func TestChatCompletionsHedgeNotStartedWhenPrimaryIsFast(t *testing.T) {
+	primary := newHedgeTestProvider(0, "primary answer")
+	backup := newHedgeTestProvider(0, "backup answer")
+	s, obs := newHedgeTestServer(primary, backup)
+
+	rec := doCapabilityChat(s, `{"model":"slow/m","at_fallbacks":["fast/m"],"at_hedge":{"delay_ms":2000},
+		"messages":[{"role":"user","content":"hi"}]}`)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "primary answer") {
+		t.Fatalf("status = %d; body=%s", rec.Code, rec.Body.String())
+	}
+	if got := rec.Header().Get("x-at-hedge-winner"); got != hedgeRolePrimary {
+		t.Errorf("x-at-hedge-winner = %q", got)
+	}
+
+	calls := waitForObservations(t, obs, 1)
+	time.Sleep(50 * time.Millisecond)
+	if calls = obs.snapshot(); len(calls) != 1 || calls[0].Metadata["hedge_winner"] != true {
+		t.Errorf("observations = %+v", calls)
+	}
+}
+
+func TestChatCompletionsHedgeStartsEarlyOnPrimaryFailure(t *testing.T) {
+	failing := newHedgeTestProvider(0, "")
+	failing.err = errors.New("upstream blew up")
+	backup := newHedgeTestProvider(0, "backup answer")
+	s, obs := newHedgeTestServer(failing, backup)
+
+	start := time.Now()
+	rec := doCapabilityChat(s, `{"model":"slow/m","at_fallbacks":["fast/m"],"at_hedge":{"delay_ms":10000},
+		"messages":[{"role":"user","content":"hi"}]}`)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "backup answer") {
+		t.Fatalf("status = %d; body=%s", rec.Code, rec.Body.String())
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("hedge waited for the delay after the primary failed (%v)", elapsed)
+	}
+
+	calls := hedgeObservations(t, obs, 2)
+	if calls[hedgeRolePrimary].Status != "error" || calls[hedgeRoleHedge].Status != "ok" {
+		t.Errorf("statuses = %s / %s", calls[hedgeRolePrimary].Status, calls[hedgeRoleHedge].Status)
+	}
+}
+
+func TestChatCompletionsHedgeStream(t *testing.T) {
+	slow := newHedgeTestProvider(5*time.Second, "slow answer")
+	fast := newHedgeTestProvider(0, "fast answer")
+	s, obs := newHedgeTestServer(slow, fast)
+
+	rec := doCapabilityChat(s, `{"model":"slow/m","stream":true,"at_fallbacks":["fast/m"],"at_hedge":{"delay_ms":20},
+		"messages":[{"role":"user","content":"hi"}]}`)
+	body := rec.Body.String()
+	if rec.Code != http.StatusOK || !strings.Contains(body, "fast answer") || !strings.Contains(body, "data: [DONE]") {
+		t.Fatalf("status = %d; body=%s", rec.Code, body)
+	}
+	if strings.Contains(body, "slow answer") {
+		t.Errorf("loser leaked into the stream: %s", body)
+	}
+	if got := rec.Header().Get("x-at-hedge-winner"); got != hedgeRoleHedge {
+		t.Errorf("x-at-hedge-winner = %q", got)
+	}
+
+	calls := hedgeObservations(t, obs, 2)
+	hedge := calls[hedgeRoleHedge]
+	if !hedge.Streamed || hedge.Status != "ok" || hedge.Metadata["hedge_winner"] != true {
+		t.Errorf("hedge = %+v", hedge)
+	}
+	if calls[hedgeRolePrimary].Status != "cancelled" || calls[hedgeRolePrimary].TraceID != hedge.TraceID {
+		t.Errorf("primary = %+v", calls[hedgeRolePrimary])
+	}
+}
+
+func TestChatCompletionsHedgeRequiresFallback(t *testing.T) {
+	s, _ := newHedgeTestServer(newHedgeTestProvider(0, "a"), newHedgeTestProvider(0, "b"))
+
+	rec := doCapabilityChat(s, `{"model":"slow/m","at_fallbacks":["missing/m"],"at_hedge":{},
+		"messages":[{"role":"user","content":"hi"}]}`)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d; body=%s", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,764 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/rakunlabs/at/internal/service"
+	"github.com/rakunlabs/at/internal/service/workflow"
+	"github.com/rakunlabs/query"
+)
+
+// ─── Gateway Hooks ───
+//
+// Gateway hooks (service.GatewayHook) are JavaScript snippets run inline by
+// /gateway/v1/chat/completions and by the lines of a batch. A script
+// defines onRequest(req, ctx) and/or onResponse(resp, req, ctx). The body
+// is handed over as a plain JS object (a JSON copy); the hook mutates it in
+// place or returns a replacement.
+//
+// Request hooks run after authentication and before routing: the token's
+// hooks first, then the hooks of the provider named by the (possibly
+// rewritten) model. Response hooks run on non-streaming answers in the
+// reverse order; a streaming request that one of them would see is
+// rejected. Each invocation gets a fresh sandboxed VM (no HTTP, no
+// variables) and is interrupted when it exceeds its time limit. A failing
+// hook is logged and skipped unless its on_error is "fail".
+//
+// Endpoints with other wire formats (/v1/messages, Gemini generateContent,
+// /v1/responses) cannot hand their bodies to hooks, so they reject calls
+// that the token's or the provider's hooks would see rather than skip
+// them.
+
+const (
+	// gatewayHookCacheTTL bounds how long a compiled hook is reused before
+	// the store is read again. Updates and deletes through the API evict
+	// at once.
+	gatewayHookCacheTTL = 30 * time.Second
+
+	defaultGatewayHookTimeout = 100 * time.Millisecond
+	maxGatewayHookTimeout     = 2 * time.Second
+
+	// gatewayHookMaxCallStack guards against runaway recursion, which
+	// would otherwise grow the Go stack until the time limit hits.
+	gatewayHookMaxCallStack = 1024
+)
+
+// gatewayHookError is returned when a hook with on_error "fail" throws or
+// times out. classifyGatewayError maps it to a 400.
+type gatewayHookError struct {
+	hook    string
+	stage   string // "request" | "response"
+	message string
+
+	// unsupported marks a hook the endpoint or stream mode cannot run.
+	unsupported bool
+}
+
+func (e *gatewayHookError) Error() string {
+	return fmt.Sprintf("gateway hook %q (%s): %s", e.hook, e.stage, e.message)
+}
+
+// compiledGatewayHook is a hook with its script parsed once. goja programs
+// are immutable and safe to run on many runtimes concurrently.
+type compiledGatewayHook struct {
+	hook     service.GatewayHook
+	program  *goja.Program
+	loadedAt time.Time
+
+	// defined caches definesGatewayHook results by function name.
+	defined sync.Map
+}
+
+func (h *compiledGatewayHook) timeout() time.Duration {
+	if h.hook.TimeoutMs <= 0 {
+		return defaultGatewayHookTimeout
+	}
+	return min(time.Duration(h.hook.TimeoutMs)*time.Millisecond, maxGatewayHookTimeout)
+}
+
+func compileGatewayHook(h service.GatewayHook) (*compiledGatewayHook, error) {
+	program, err := goja.Compile(h.Name, h.Script, false)
+	if err != nil {
+		return nil, fmt.Errorf("gateway hook %q: %w", h.Name, err)
+	}
+	return &compiledGatewayHook{hook: h, program: program, loadedAt: time.Now()}, nil
+}
+
+// validateGatewayHook returns a problem description, or "" when the hook
+// is valid.
+func validateGatewayHook(h service.GatewayHook) string {
+	if strings.TrimSpace(h.Name) == "" {
+		return "name is required"
+	}
+	if strings.TrimSpace(h.Script) == "" {
+		return "script is required"
+	}
+	if h.TimeoutMs < 0 {
+		return "timeout_ms must not be negative"
+	}
+	switch h.OnError {
+	case "", service.GatewayHookOnErrorSkip, service.GatewayHookOnErrorFail:
+	default:
+		return fmt.Sprintf("on_error must be %q or %q", service.GatewayHookOnErrorSkip, service.GatewayHookOnErrorFail)
+	}
+	if _, err := compileGatewayHook(h); err != nil {
+		return err.Error()
+	}
+	return ""
+}
+
+// loadGatewayHooks returns the enabled hooks for ids, in order. Missing
+// hooks are skipped; scripts that no longer compile are logged and skipped.
+func (s *Server) loadGatewayHooks(ctx context.Context, ids []string) ([]*compiledGatewayHook, error) {
+	if s.gatewayHookStore == nil || len(ids) == 0 {
+		return nil, nil
+	}
+
+	out := make([]*compiledGatewayHook, 0, len(ids))
+	for _, id := range ids {
+		if v, ok := s.gatewayHookCache.Load(id); ok {
+			if h := v.(*compiledGatewayHook); time.Since(h.loadedAt) < gatewayHookCacheTTL {
+				if h.hook.Enabled {
+					out = append(out, h)
+				}
+				continue
+			}
+		}
+
+		rec, err := s.gatewayHookStore.GetGatewayHook(ctx, id)
+		if err != nil {
+			return nil, fmt.Errorf("load gateway hook %q: %w", id, err)
+		}
+		if rec == nil {
+			slog.Warn("gateway hook not found; skipping", "id", id)
+			continue
+		}
+		h, err := compileGatewayHook(*rec)
+		if err != nil {
+			slog.Error("gateway hook does not compile; skipping", "id", id, "error", err)
+			continue
+		}
+		s.gatewayHookCache.Store(id, h)
+		if h.hook.Enabled {
+			out = append(out, h)
+		}
+	}
+
+	return out, nil
+}
+
+// gatewayHookContext is the ctx argument passed to every hook function.
+func gatewayHookContext(auth *authResult, h *compiledGatewayHook, stage, model string) map[string]any {
+	hctx := map[string]any{
+		"hook":  h.hook.Name,
+		"stage": stage,
+		"model": model,
+	}
+	if providerKey, _, err := parseModelID(model); err == nil {
+		hctx["provider"] = providerKey
+	}
+	if auth != nil && auth.token != nil {
+		hctx["token_id"] = auth.token.ID
+		hctx["token_name"] = auth.token.Name
+	}
+	return hctx
+}
+
+// startGatewayHook runs the script's top level in a fresh sandboxed VM
+// that is interrupted when the hook's time limit or ctx ends. stop must be
+// called once the VM is no longer used.
+func startGatewayHook(ctx context.Context, h *compiledGatewayHook) (vm *goja.Runtime, stop func(), err error) {
+	vm = goja.New()
+	vm.SetMaxCallStackSize(gatewayHookMaxCallStack)
+
+	timer := time.AfterFunc(h.timeout(), func() {
+		vm.Interrupt(fmt.Sprintf("time limit of %s exceeded", h.timeout()))
+	})
+	unwatch := context.AfterFunc(ctx, func() { vm.Interrupt(ctx.Err()) })
+	stop = func() {
+		timer.Stop()
+		unwatch()
+	}
+
+	if err := workflow.SetupGojaSandbox(vm, nil); err != nil {
+		return nil, stop, err
+	}
+	if _, err := vm.RunProgram(h.program); err != nil {
+		return nil, stop, gatewayHookScriptError(err)
+	}
+	return vm, stop, nil
+}
+
+// definesGatewayHook reports whether h's script defines fn. A script whose
+// top level fails counts as defining it, so callers err on the side of
+// refusing what they cannot run.
+func definesGatewayHook(ctx context.Context, h *compiledGatewayHook, fn string) (defined bool) {
+	if v, ok := h.defined.Load(fn); ok {
+		return v.(bool)
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			defined = true
+		}
+		h.defined.Store(fn, defined)
+	}()
+
+	vm, stop, err := startGatewayHook(ctx, h)
+	defer stop()
+	if err != nil {
+		return true
+	}
+	_, ok := goja.AssertFunction(vm.Get(fn))
+	return ok
+}
+
+// runGatewayHook calls fn(body, extra..., hctx) in a fresh VM and returns
+// the resulting body as JSON. ran is false when the script does not define
+// fn. Any panic or interrupt is returned as an error.
+func runGatewayHook(ctx context.Context, h *compiledGatewayHook, fn string, body []byte, extra []byte, hctx map[string]any) (out []byte, ran bool, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			out, ran, err = nil, true, fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	vm, stop, err := startGatewayHook(ctx, h)
+	defer stop()
+	if err != nil {
+		return nil, true, err
+	}
+
+	call, ok := goja.AssertFunction(vm.Get(fn))
+	if !ok {
+		return nil, false, nil
+	}
+
+	arg, err := gojaFromJSON(vm, body)
+	if err != nil {
+		return nil, true, err
+	}
+	args := []goja.Value{arg}
+	if extra != nil {
+		v, err := gojaFromJSON(vm, extra)
+		if err != nil {
+			return nil, true, err
+		}
+		args = append(args, v)
+	}
+	args = append(args, vm.ToValue(hctx))
+
+	result, err := call(goja.Undefined(), args...)
+	if err != nil {
+		return nil, true, gatewayHookScriptError(err)
+	}
+	// A hook that returns nothing keeps its (possibly mutated) argument.
+	if result == nil || goja.IsUndefined(result) || goja.IsNull(result) {
+		result = arg
+	}
+
+	out, err = gojaToJSON(vm, result)
+	if err != nil {
+		return nil, true, err
+	}
+	return out, true, nil
+}
+
+// gatewayHookScriptError strips goja's stack noise from thrown errors.
+func gatewayHookScriptError(err error) error {
+	var interrupted *goja.InterruptedError
+	if errors.As(err, &interrupted) {
+		return fmt.Errorf("interrupted: %v", interrupted.Value())
+	}
+	var exception *goja.Exception
+	if errors.As(err, &exception) {
+		return errors.New(exception.Value().String())
+	}
+	return err
+}
+
+func gojaFromJSON(vm *goja.Runtime, data []byte) (goja.Value, error) {
+	parse, ok := goja.AssertFunction(vm.Get("JSON").ToObject(vm).Get("parse"))
+	if !ok {
+		return nil, errors.New("JSON.parse is not available")
+	}
+	return parse(goja.Undefined(), vm.ToValue(string(data)))
+}
+
+func gojaToJSON(vm *goja.Runtime, v goja.Value) ([]byte, error) {
+	stringify, ok := goja.AssertFunction(vm.Get("JSON").ToObject(vm).Get("stringify"))
+	if !ok {
+		return nil, errors.New("JSON.stringify is not available")
+	}
+	s, err := stringify(goja.Undefined(), v)
+	if err != nil {
+		return nil, gatewayHookScriptError(err)
+	}
+	if goja.IsUndefined(s) {
+		return nil, errors.New("hook result is not JSON-serialisable")
+	}
+	return []byte(s.String()), nil
+}
+
+// ─── Gateway wiring ───
+
+// tokenGatewayHookIDs returns the hook IDs attached to the caller's token.
+func tokenGatewayHookIDs(auth *authResult) []string {
+	if auth == nil || auth.token == nil {
+		return nil
+	}
+	return auth.token.HookIDs
+}
+
+// applyRequestHooks runs the token hooks and then the hooks of the target
+// provider on req. It returns every hook that ran, so the response stage
+// can replay them, and whether req was changed.
+func (s *Server) applyRequestHooks(ctx context.Context, auth *authResult, req *ChatCompletionRequest) ([]*compiledGatewayHook, bool, error) {
+	if s.gatewayHookStore == nil {
+		return nil, false, nil
+	}
+
+	tokenHooks, err := s.loadGatewayHooks(ctx, tokenGatewayHookIDs(auth))
+	if err != nil {
+		return nil, false, err
+	}
+	applied, changed, err := s.runRequestHooks(ctx, auth, tokenHooks, req)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// Provider hooks follow the model the token hooks settled on.
+	var providerHooks []*compiledGatewayHook
+	if providerKey, _, perr := parseModelID(req.Model); perr == nil {
+		if info, ok := s.getProviderInfo(providerKey); ok && len(info.hookIDs) > 0 {
+			providerHooks, err = s.loadGatewayHooks(ctx, info.hookIDs)
+			if err != nil {
+				return nil, false, err
+			}
+		}
+	}
+	more, changed2, err := s.runRequestHooks(ctx, auth, providerHooks, req)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return append(applied, more...), changed || changed2, nil
+}
+
+func (s *Server) runRequestHooks(ctx context.Context, auth *authResult, hooks []*compiledGatewayHook, req *ChatCompletionRequest) ([]*compiledGatewayHook, bool, error) {
+	var (
+		applied []*compiledGatewayHook
+		changed bool
+	)
+	for _, h := range hooks {
+		applied = append(applied, h)
+
+		body, err := json.Marshal(req)
+		if err != nil {
+			return nil, false, err
+		}
+		out, ran, err := runGatewayHook(ctx, h, "onRequest", body, nil, gatewayHookContext(auth, h, "request", req.Model))
+		if err == nil && ran {
+			var next ChatCompletionRequest
+			if err = json.Unmarshal(out, &next); err == nil {
+				*req = next
+				changed = true
+			}
+		}
+		if err != nil {
+			if herr := gatewayHookFailure(h, "request", err); herr != nil {
+				return nil, false, herr
+			}
+		}
+	}
+
+	return applied, changed, nil
+}
+
+// applyResponseHooks runs onResponse of the hooks that saw the request, in
+// reverse order, on a non-streaming answer.
+func (s *Server) applyResponseHooks(ctx context.Context, auth *authResult, hooks []*compiledGatewayHook, req *ChatCompletionRequest, resp *ChatCompletionResponse) (*ChatCompletionResponse, error) {
+	if len(hooks) == 0 {
+		return resp, nil
+	}
+
+	reqBody, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	for _, h := range slices.Backward(hooks) {
+		body, err := json.Marshal(resp)
+		if err != nil {
+			return nil, err
+		}
+		out, ran, err := runGatewayHook(ctx, h, "onResponse", body, reqBody, gatewayHookContext(auth, h, "response", resp.Model))
+		if err == nil && ran {
+			var next ChatCompletionResponse
+			if err = json.Unmarshal(out, &next); err == nil {
+				resp = &next
+			}
+		}
+		if err != nil {
+			if herr := gatewayHookFailure(h, "response", err); herr != nil {
+				return nil, herr
+			}
+		}
+	}
+
+	return resp, nil
+}
+
+// gatewayHookFailure logs a hook error and returns a *gatewayHookError when
+// the hook is configured to fail the request.
+func gatewayHookFailure(h *compiledGatewayHook, stage string, err error) error {
+	if h.hook.OnError == service.GatewayHookOnErrorFail {
+		return &gatewayHookError{hook: h.hook.Name, stage: stage, message: err.Error()}
+	}
+	slog.Warn("gateway hook failed; skipping", "hook", h.hook.Name, "stage", stage, "error", err)
+	return nil
+}
+
+// rejectStreamingHooks fails a streaming request when one of the hooks that
+// saw it defines onResponse, which only runs on complete answers.
+func rejectStreamingHooks(ctx context.Context, hooks []*compiledGatewayHook) error {
+	for _, h := range hooks {
+		if definesGatewayHook(ctx, h, "onResponse") {
+			return &gatewayHookError{
+				hook:        h.hook.Name,
+				stage:       "response",
+				message:     "onResponse does not run on streaming requests; send stream=false",
+				unsupported: true,
+			}
+		}
+	}
+	return nil
+}
+
+// rejectGatewayHooks fails a request on an endpoint that cannot run hooks
+// when the token or the provider of any of models has one enabled.
+func (s *Server) rejectGatewayHooks(ctx context.Context, auth *authResult, models ...string) error {
+	if s.gatewayHookStore == nil {
+		return nil
+	}
+
+	ids := slices.Clone(tokenGatewayHookIDs(auth))
+	for _, model := range models {
+		if providerKey, _, err := parseModelID(model); err == nil {
+			if info, ok := s.getProviderInfo(providerKey); ok {
+				ids = append(ids, info.hookIDs...)
+			}
+		}
+	}
+	hooks, err := s.loadGatewayHooks(ctx, ids)
+	if err != nil {
+		return err
+	}
+	if len(hooks) > 0 {
+		return &gatewayHookError{
+			hook:        hooks[0].hook.Name,
+			stage:       "request",
+			message:     "gateway hooks only run on /v1/chat/completions; use that endpoint for this token or model",
+			unsupported: true,
+		}
+	}
+	return nil
+}
+
+// chainModels lists the models of a call chain for rejectGatewayHooks.
+func chainModels(chain []chatCallTarget) []string {
+	models := make([]string, 0, len(chain))
+	for _, t := range chain {
+		models = append(models, t.fullModel)
+	}
+	return models
+}
+
+// ─── Gateway Hook CRUD API ───
+
+// ListGatewayHooksAPI handles GET /api/v1/gateway-hooks.
+func (s *Server) ListGatewayHooksAPI(w http.ResponseWriter, r *http.Request) {
+	if s.gatewayHookStore == nil {
+		httpResponse(w, "store not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	q, err := query.Parse(r.URL.RawQuery)
+	if err != nil {
+		httpResponse(w, fmt.Sprintf("invalid query: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	records, err := s.gatewayHookStore.ListGatewayHooks(r.Context(), q)
+	if err != nil {
+		slog.Error("list gateway hooks failed", "error", err)
+		httpResponse(w, fmt.Sprintf("failed to list gateway hooks: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	if records == nil {
+		records = &service.ListResult[service.GatewayHook]{Data: []service.GatewayHook{}}
+	}
+
+	httpResponseJSON(w, records, http.StatusOK)
+}
+
+// GetGatewayHookAPI handles GET /api/v1/gateway-hooks/{id}.
+func (s *Server) GetGatewayHookAPI(w http.ResponseWriter, r *http.Request) {
+	if s.gatewayHookStore == nil {
+		httpResponse(w, "store not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	id := r.PathValue("id")
+	if id == "" {
+		httpResponse(w, "gateway hook id is required", http.StatusBadRequest)
+		return
+	}
+
+	record, err := s.gatewayHookStore.GetGatewayHook(r.Context(), id)
+	if err != nil {
+		slog.Error("get gateway hook failed", "id", id, "error", err)
+		httpResponse(w, fmt.Sprintf("failed to get gateway hook: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	if record == nil {
+		httpResponse(w, fmt.Sprintf("gateway hook %q not found", id), http.StatusNotFound)
+		return
+	}
+
+	httpResponseJSON(w, record, http.StatusOK)
+}
+
+// CreateGatewayHookAPI handles POST /api/v1/gateway-hooks.
+func (s *Server) CreateGatewayHookAPI(w http.ResponseWriter, r *http.Request) {
+	if s.gatewayHookStore == nil {
+		httpResponse(w, "store not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	var req service.GatewayHook
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpResponse(w, fmt.Sprintf("invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if problem := validateGatewayHook(req); problem != "" {
+		httpResponse(w, problem, http.StatusBadRequest)
+		return
+	}
+
+	userEmail := s.getUserEmail(r)
+	req.CreatedBy = userEmail
+	req.UpdatedBy = userEmail
+
+	record, err := s.gatewayHookStore.CreateGatewayHook(r.Context(), req)
+	if err != nil {
+		if isUniqueViolation(err) {
+			httpResponse(w, fmt.Sprintf("gateway hook %q already exists", req.Name), http.StatusConflict)
+			return
+		}
+		slog.Error("create gateway hook failed", "name", req.Name, "error", err)
+		httpResponse(w, fmt.Sprintf("failed to create gateway hook: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	httpResponseJSON(w, record, http.StatusCreated)
+}
+
+// UpdateGatewayHookAPI handles PUT /api/v1/gateway-hooks/{id}.
+func (s *Server) UpdateGatewayHookAPI(w http.ResponseWriter, r *http.Request) {
+	if s.gatewayHookStore == nil {
+		httpResponse(w, "store not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	id := r.PathValue("id")
+	if id == "" {
+		httpResponse(w, "gateway hook id is required", http.StatusBadRequest)
+		return
+	}
+
+	var req service.GatewayHook
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpResponse(w, fmt.Sprintf("invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if problem := validateGatewayHook(req); problem != "" {
+		httpResponse(w, problem, http.StatusBadRequest)
+		return
+	}
+
+	req.UpdatedBy = s.getUserEmail(r)
+
+	record, err := s.gatewayHookStore.UpdateGatewayHook(r.Context(), id, req)
+	if err != nil {
+		slog.Error("update gateway hook failed", "id", id, "error", err)
+		httpResponse(w, fmt.Sprintf("failed to update gateway hook: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	if record == nil {
+		httpResponse(w, fmt.Sprintf("gateway hook %q not found", id), http.StatusNotFound)
+		return
+	}
+
+	s.gatewayHookCache.Delete(id)
+
+	httpResponseJSON(w, record, http.StatusOK)
+}
+
+// DeleteGatewayHookAPI handles DELETE /api/v1/gateway-hooks/{id}.
+func (s *Server) DeleteGatewayHookAPI(w http.ResponseWriter, r *http.Request) {
+	if s.gatewayHookStore == nil {
+		httpResponse(w, "store not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	id := r.PathValue("id")
+	if id == "" {
+		httpResponse(w, "gateway hook id is required", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.gatewayHookStore.DeleteGatewayHook(r.Context(), id); err != nil {
+		slog.Error("delete gateway hook failed", "id", id, "error", err)
+		httpResponse(w, fmt.Sprintf("failed to delete gateway hook: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	s.gatewayHookCache.Delete(id)
+
+	httpResponseJSON(w, map[string]string{"status": "deleted"}, http.StatusOK)
+}
+
+// gatewayHookTestRequest is the body of POST /api/v1/gateway-hooks/test.
+// Either HookID (a stored hook) or Script (an unsaved draft) is required.
+type gatewayHookTestRequest struct {
+	HookID    string          `json:"hook_id,omitempty"`
+	Script    string          `json:"script,omitempty"`
+	TimeoutMs int             `json:"timeout_ms,omitempty"`
+	Request   json.RawMessage `json:"request"`
+	Response  json.RawMessage `json:"response,omitempty"`
+}
+
+// gatewayHookTestResult reports what each stage produced. A stage the
+// script does not define is reported as not ran and echoes its input.
+type gatewayHookTestResult struct {
+	Request         json.RawMessage `json:"request"`
+	RequestRan      bool            `json:"request_ran"`
+	RequestError    string          `json:"request_error,omitempty"`
+	RequestMs       int64           `json:"request_ms"`
+	Response        json.RawMessage `json:"response,omitempty"`
+	ResponseRan     bool            `json:"response_ran"`
+	ResponseError   string          `json:"response_error,omitempty"`
+	ResponseMs      int64           `json:"response_ms"`
+	ValidationError string          `json:"validation_error,omitempty"`
+}
+
+// TestGatewayHookAPI handles POST /api/v1/gateway-hooks/test. It dry-runs a
+// hook against a sample request (and optional response) without calling
+// any provider. Results are round-tripped through the gateway's request
+// and response types, so fields the gateway would drop are dropped here.
+func (s *Server) TestGatewayHookAPI(w http.ResponseWriter, r *http.Request) {
+	var req gatewayHookTestRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpResponse(w, fmt.Sprintf("invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+	if len(req.Request) == 0 {
+		httpResponse(w, "request is required", http.StatusBadRequest)
+		return
+	}
+
+	hook := service.GatewayHook{Name: "draft", Script: req.Script, TimeoutMs: req.TimeoutMs, Enabled: true}
+	if req.HookID != "" {
+		if s.gatewayHookStore == nil {
+			httpResponse(w, "store not configured", http.StatusServiceUnavailable)
+			return
+		}
+		rec, err := s.gatewayHookStore.GetGatewayHook(r.Context(), req.HookID)
+		if err != nil {
+			httpResponse(w, fmt.Sprintf("failed to get gateway hook: %v", err), http.StatusInternalServerError)
+			return
+		}
+		if rec == nil {
+			httpResponse(w, fmt.Sprintf("gateway hook %q not found", req.HookID), http.StatusNotFound)
+			return
+		}
+		hook = *rec
+	}
+	if strings.TrimSpace(hook.Script) == "" {
+		httpResponse(w, "hook_id or script is required", http.StatusBadRequest)
+		return
+	}
+
+	h, err := compileGatewayHook(hook)
+	if err != nil {
+		httpResponse(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var chatReq ChatCompletionRequest
+	if err := json.Unmarshal(req.Request, &chatReq); err != nil {
+		httpResponse(w, fmt.Sprintf("invalid sample request: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	result := gatewayHookTestResult{Request: req.Request}
+
+	reqBody, _ := json.Marshal(chatReq)
+	start := time.Now()
+	out, ran, err := runGatewayHook(r.Context(), h, "onRequest", reqBody, nil, gatewayHookContext(nil, h, "request", chatReq.Model))
+	result.RequestMs = time.Since(start).Milliseconds()
+	result.RequestRan = ran
+	if err != nil {
+		result.RequestError = err.Error()
+	} else if ran {
+		var next ChatCompletionRequest
+		if err := json.Unmarshal(out, &next); err != nil {
+			result.ValidationError = fmt.Sprintf("request: %v", err)
+		} else {
+			chatReq = next
+			result.Request, _ = json.Marshal(chatReq)
+		}
+	}
+
+	if len(req.Response) > 0 {
+		var chatResp ChatCompletionResponse
+		if err := json.Unmarshal(req.Response, &chatResp); err != nil {
+			httpResponse(w, fmt.Sprintf("invalid sample response: %v", err), http.StatusBadRequest)
+			return
+		}
+		result.Response = req.Response
+
+		respBody, _ := json.Marshal(chatResp)
+		reqBody, _ = json.Marshal(chatReq)
+		start = time.Now()
+		out, ran, err = runGatewayHook(r.Context(), h, "onResponse", respBody, reqBody, gatewayHookContext(nil, h, "response", chatResp.Model))
+		result.ResponseMs = time.Since(start).Milliseconds()
+		result.ResponseRan = ran
+		if err != nil {
+			result.ResponseError = err.Error()
+		} else if ran {
+			var next ChatCompletionResponse
+			if err := json.Unmarshal(out, &next); err != nil {
+				result.ValidationError = fmt.Sprintf("response: %v", err)
+			} else {
+				result.Response, _ = json.Marshal(next)
+			}
+		}
+	}
+
+	httpResponseJSON(w, result, http.StatusOK)
+}
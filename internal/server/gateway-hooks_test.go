@@ -0,0 +1,307 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rakunlabs/at/internal/service"
+	"github.com/rakunlabs/query"
+)
+
+// memoryGatewayHookStore is an in-memory GatewayHookStorer.
+type memoryGatewayHookStore struct {
+	items map[string]service.GatewayHook
+}
+
+func newMemoryGatewayHookStore(items ...service.GatewayHook) *memoryGatewayHookStore {
+	st := &memoryGatewayHookStore{items: map[string]service.GatewayHook{}}
+	for _, h := range items {
+		st.items[h.ID] = h
+	}
+	return st
+}
+
+func (m *memoryGatewayHookStore) ListGatewayHooks(context.Context, *query.Query) (*service.ListResult[service.GatewayHook], error) {
+	out := &service.ListResult[service.GatewayHook]{}
+	for _, h := range m.items {
+		out.Data = append(out.Data, h)
+	}
+	return out, nil
+}
+
+func (m *memoryGatewayHookStore) GetGatewayHook(_ context.Context, id string) (*service.GatewayHook, error) {
+	h, ok := m.items[id]
+	if !ok {
+		return nil, nil
+	}
+	return &h, nil
+}
+
+func (m *memoryGatewayHookStore) CreateGatewayHook(_ context.Context, h service.GatewayHook) (*service.GatewayHook, error) {
+	h.ID = h.Name
+	m.items[h.ID] = h
+	return &h, nil
+}
+
+func (m *memoryGatewayHookStore) UpdateGatewayHook(_ context.Context, id string, h service.GatewayHook) (*service.GatewayHook, error) {
+	if _, ok := m.items[id]; !ok {
+		return nil, nil
+	}
+	h.ID = id
+	m.items[id] = h
+	return &h, nil
+}
+
+func (m *memoryGatewayHookStore) DeleteGatewayHook(_ context.Context, id string) error {
+	delete(m.items, id)
+	return nil
+}
+
+// newGatewayHookTestServer attaches tokenHooks to the test token and
+// providerHooks to the "gemini" provider.
+func newGatewayHookTestServer(provider service.LLMProvider, tokenHooks, providerHooks []string, hooks ...service.GatewayHook) *Server {
+	return &Server{
+		providers: map[string]ProviderInfo{
+			"gemini": {provider: provider, providerType: "openai", hookIDs: providerHooks},
+		},
+		tokenStore: gatewayTestToken("test-token", service.APIToken{
+			ID:                   "tok-1",
+			Name:                 "team-a",
+			AllowedProvidersMode: service.AccessModeAll,
+			AllowedModelsMode:    service.AccessModeAll,
+			HookIDs:              tokenHooks,
+		}),
+		gatewayHookStore: newMemoryGatewayHookStore(hooks...),
+	}
+}
+
+func TestChatCompletionsGatewayHooksRewriteRequest(t *testing.T) {
+	provider := &messagesCaptureProvider{resp: &service.LLMResponse{Content: "ok", Finished: true}}
+	s := newGatewayHookTestServer(provider, []string{"inject"}, []string{"strip"},
+		service.GatewayHook{ID: "inject", Name: "inject", Enabled: true, Script: `
+			function onRequest(req, ctx) {
+				req.messages.unshift({role: "system", content: "You work for " + ctx.token_name});
+				req.model = "gemini/m";
+			}`},
+		service.GatewayHook{ID: "strip", Name: "strip", Enabled: true, Script: `
+			function onRequest(req, ctx) {
+				if (ctx.provider !== "gemini") throw new Error("wrong provider " + ctx.provider);
+				delete req.temperature;
+				return req;
+			}`},
+	)
+
+	rec := doCapabilityChat(s, `{"model":"other/x","temperature":0.2,"messages":[{"role":"user","content":"hi"}]}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d; body=%s", rec.Code, rec.Body.String())
+	}
+	raw, _ := json.Marshal(provider.messages)
+	if len(provider.messages) != 2 || provider.messages[0].Role != "system" || !strings.Contains(string(raw), "You work for team-a") {
+		t.Fatalf("system prompt not injected: %s", raw)
+	}
+	if provider.opts != nil && provider.opts.Temperature != nil {
+		t.Errorf("temperature = %v, want stripped by the provider hook", *provider.opts.Temperature)
+	}
+}
+
+func TestChatCompletionsGatewayHooksRewriteResponse(t *testing.T) {
+	provider := &messagesCaptureProvider{resp: &service.LLMResponse{Content: "hello", Finished: true}}
+	s := newGatewayHookTestServer(provider, []string{"upper"}, nil,
+		service.GatewayHook{ID: "upper", Name: "upper", Enabled: true, Script: `
+			function onResponse(resp, req, ctx) {
+				resp.choices[0].message.content = resp.choices[0].message.content.toUpperCase() + " (" + req.model + ")";
+			}`},
+	)
+
+	rec := doCapabilityChat(s, `{"model":"gemini/m","messages":[{"role":"user","content":"hi"}]}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d; body=%s", rec.Code, rec.Body.String())
+	}
+	var resp ChatCompletionResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if got := resp.Choices[0].Message.Content; got == nil || *got != "HELLO (gemini/m)" {
+		t.Errorf("content = %v, want HELLO (gemini/m)", got)
+	}
+}
+
+func TestChatCompletionsGatewayHookErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		script   string
+		onError  string
+		timeout  int
+		wantCode int
+	}{
+		{"throw skipped", `function onRequest(req) { throw new Error("nope"); }`, "", 0, http.StatusOK},
+		{"throw fails", `function onRequest(req) { throw new Error("nope"); }`, service.GatewayHookOnErrorFail, 0, http.StatusBadRequest},
+		{"top-level error", `undefinedFunction();`, service.GatewayHookOnErrorFail, 0, http.StatusBadRequest},
+		{"endless loop skipped", `function onRequest(req) { for (;;) {} }`, "", 20, http.StatusOK},
+		{"endless loop fails", `function onRequest(req) { for (;;) {} }`, service.GatewayHookOnErrorFail, 20, http.StatusBadRequest},
+		{"recursion fails", `function f() { return f(); } function onRequest(req) { f(); }`, service.GatewayHookOnErrorFail, 0, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := &messagesCaptureProvider{resp: &service.LLMResponse{Content: "ok", Finished: true}}
+			s := newGatewayHookTestServer(provider, []string{"h"}, nil, service.GatewayHook{
+				ID: "h", Name: "h", Enabled: true, Script: tt.script, OnError: tt.onError, TimeoutMs: tt.timeout,
+			})
+
+			start := time.Now()
+			rec := doCapabilityChat(s, `{"model":"gemini/m","messages":[{"role":"user","content":"hi"}]}`)
+			if elapsed := time.Since(start); elapsed > time.Second {
+				t.Errorf("request took %s; the hook was not interrupted", elapsed)
+			}
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d; body=%s", rec.Code, tt.wantCode, rec.Body.String())
+			}
+			if tt.wantCode == http.StatusOK {
+				if len(provider.messages) != 1 || provider.messages[0].Role != "user" {
+					t.Errorf("failed hook changed the request: %+v", provider.messages)
+				}
+				return
+			}
+			if code := decodeGatewayErrorCode(t, rec.Body.Bytes()); code != "gateway_hook_failed" {
+				t.Errorf("code = %q, want gateway_hook_failed", code)
+			}
+			if provider.messages != nil {
+				t.Error("provider was called after a failing hook")
+			}
+		})
+	}
+}
+
+func TestChatCompletionsGatewayHookDisabledIsSkipped(t *testing.T) {
+	provider := &messagesCaptureProvider{resp: &service.LLMResponse{Content: "ok", Finished: true}}
+	s := newGatewayHookTestServer(provider, []string{"h"}, nil, service.GatewayHook{
+		ID: "h", Name: "h", Enabled: false, OnError: service.GatewayHookOnErrorFail,
+		Script: `function onRequest(req) { throw new Error("should not run"); }`,
+	})
+
+	rec := doCapabilityChat(s, `{"model":"gemini/m","messages":[{"role":"user","content":"hi"}]}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d; body=%s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestGatewayHookSandboxHasNoHTTP(t *testing.T) {
+	h, err := compileGatewayHook(service.GatewayHook{Name: "h", Script: `
+		function onRequest(req) { req.user = typeof httpGet + "/" + typeof getVar + "/" + typeof btoa; }`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, ran, err := runGatewayHook(context.Background(), h, "onRequest", []byte(`{"model":"a/b"}`), nil, map[string]any{})
+	if err != nil || !ran {
+		t.Fatalf("run: ran=%v err=%v", ran, err)
+	}
+	if !strings.Contains(string(out), `"user":"undefined/undefined/function"`) {
+		t.Errorf("out = %s", out)
+	}
+}
+
+func TestValidateGatewayHook(t *testing.T) {
+	valid := service.GatewayHook{Name: "h", Script: `function onRequest(req) {}`}
+	if p := validateGatewayHook(valid); p != "" {
+		t.Fatalf("valid hook rejected: %s", p)
+	}
+
+	for name, h := range map[string]service.GatewayHook{
+		"no name":    {Script: valid.Script},
+		"no script":  {Name: "h"},
+		"syntax":     {Name: "h", Script: `function onRequest(req {`},
+		"on_error":   {Name: "h", Script: valid.Script, OnError: "explode"},
+		"timeout_ms": {Name: "h", Script: valid.Script, TimeoutMs: -1},
+	} {
+		if validateGatewayHook(h) == "" {
+			t.Errorf("%s: expected a validation problem", name)
+		}
+	}
+}
+
+func TestTestGatewayHookAPI(t *testing.T) {
+	s := &Server{}
+	body := `{
+		"script": "function onRequest(req) { req.model = 'b/c'; } function onResponse(resp) { resp.id = 'hooked'; }",
+		"request": {"model":"a/b","messages":[{"role":"user","content":"hi"}]},
+		"response": {"id":"chatcmpl-1","object":"chat.completion","model":"b/c","choices":[]}
+	}`
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/gateway-hooks/test", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	s.TestGatewayHookAPI(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d; body=%s", rec.Code, rec.Body.String())
+	}
+
+	var result gatewayHookTestResult
+	if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if !result.RequestRan || !result.ResponseRan || result.RequestError != "" || result.ResponseError != "" {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if !strings.Contains(string(result.Request), `"model":"b/c"`) {
+		t.Errorf("request = %s", result.Request)
+	}
+	if !strings.Contains(string(result.Response), `"id":"hooked"`) {
+		t.Errorf("response = %s", result.Response)
+	}
+}
+
+func TestChatCompletionsStreamRejectsResponseHooks(t *testing.T) {
+	provider := &messagesCaptureProvider{resp: &service.LLMResponse{Content: "ok", Finished: true}}
+	s := newGatewayHookTestServer(provider, []string{"tag"}, []string{"upper"},
+		service.GatewayHook{ID: "tag", Name: "tag", Enabled: true, Script: `
+			function onRequest(req) { req.user = "tagged"; }`},
+		service.GatewayHook{ID: "upper", Name: "upper", Enabled: true, Script: `
+			function onResponse(resp) { return resp; }`},
+	)
+
+	rec := doCapabilityChat(s, `{"model":"gemini/m","stream":true,"messages":[{"role":"user","content":"hi"}]}`)
+	if rec.Code != http.StatusBadRequest || decodeGatewayErrorCode(t, rec.Body.Bytes()) != "gateway_hook_unsupported" {
+		t.Fatalf("status = %d; body=%s", rec.Code, rec.Body.String())
+	}
+
+	// Request-only hooks still run on streams.
+	s.providers["gemini"] = ProviderInfo{provider: provider, providerType: "openai"}
+	rec = doCapabilityChat(s, `{"model":"gemini/m","stream":true,"messages":[{"role":"user","content":"hi"}]}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("request-only hook stream status = %d; body=%s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestGatewayHooksRejectedOnOtherEndpoints(t *testing.T) {
+	provider := &messagesCaptureProvider{resp: &service.LLMResponse{Content: "ok", Finished: true}}
+	hook := service.GatewayHook{ID: "tag", Name: "tag", Enabled: true, Script: `
+		function onRequest(req) { req.user = "tagged"; }`}
+
+	for name, s := range map[string]*Server{
+		"token":    newGatewayHookTestServer(provider, []string{"tag"}, nil, hook),
+		"provider": newGatewayHookTestServer(provider, nil, []string{"tag"}, hook),
+	} {
+		t.Run(name, func(t *testing.T) {
+			rec := doMessages(s, "/gateway/v1/messages", `{"model":"gemini/m","max_tokens":16,"messages":[{"role":"user","content":"hi"}]}`)
+			if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "gateway hooks only run on /v1/chat/completions") {
+				t.Errorf("messages status = %d; body=%s", rec.Code, rec.Body.String())
+			}
+
+			rec = doGenerateContent(s, "/gateway/v1beta/models/gemini/m:generateContent?key=test-token", `{"contents":[{"role":"user","parts":[{"text":"hi"}]}]}`)
+			if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "gateway hooks only run on /v1/chat/completions") {
+				t.Errorf("generateContent status = %d; body=%s", rec.Code, rec.Body.String())
+			}
+
+			rec = doResponsesRequest(s, http.MethodPost, "/gateway/v1/responses", `{"model":"gemini/m","input":"hi"}`)
+			if rec.Code != http.StatusBadRequest || decodeGatewayErrorCode(t, rec.Body.Bytes()) != "gateway_hook_unsupported" {
+				t.Errorf("responses status = %d; body=%s", rec.Code, rec.Body.String())
+			}
+		})
+	}
+	if provider.messages != nil {
+		t.Errorf("provider was called: %+v", provider.messages)
+	}
+}
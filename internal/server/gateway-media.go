@@ -7,6 +7,7 @@ import (
 	"io"
 	"log/slog"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/rakunlabs/at/internal/service"
@@ -43,6 +44,10 @@ type imagesUsage struct {
 	TotalTokens int `json:"total_tokens,omitempty"`
 }
 
+// maxImages caps n on the image endpoints, as OpenAI does. Providers
+// without native n (Gemini) make one upstream call per image.
+const maxImages = 10
+
 // Images handles POST /gateway/v1/images/generations.
 func (s *Server) Images(w http.ResponseWriter, r *http.Request) {
 	auth, providerKey, actualModel, fullModel, info, ok := s.resolveMediaProvider(w, r)
@@ -59,8 +64,12 @@ func (s *Server) Images(w http.ResponseWriter, r *http.Request) {
 		gatewayBadRequest(w, "prompt is required", "prompt", "")
 		return
 	}
+	if req.N > maxImages {
+		gatewayBadRequest(w, fmt.Sprintf("n must be at most %d", maxImages), "n", "")
+		return
+	}
 
-	imgProvider, ok := info.provider.(service.ImageProvider)
+	imgProvider, ok := service.As[service.ImageProvider](info.provider)
 	if !ok {
 		httpResponseJSON(w, map[string]any{
 			"error": map[string]any{
@@ -91,6 +100,13 @@ func (s *Server) Images(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	s.recordUsageAsync(r.Context(), auth, fullModel, resp.Usage, latencyMs, "ok", "", "")
+	httpResponseJSON(w, newImagesGenResponse(resp), http.StatusOK)
+}
+
+// newImagesGenResponse converts a provider result to the OpenAI images
+// response shared by generations, edits and variations.
+func newImagesGenResponse(resp *service.ImageResponse) imagesGenResponse {
 	data := make([]imagesGenDatum, 0, len(resp.Images))
 	for _, img := range resp.Images {
 		data = append(data, imagesGenDatum{
@@ -106,9 +122,198 @@ func (s *Server) Images(w http.ResponseWriter, r *http.Request) {
 	if resp.Usage.TotalTokens > 0 || resp.Usage.PromptTokens > 0 {
 		out.Usage = &imagesUsage{TotalTokens: resp.Usage.TotalTokenCount()}
 	}
+	return out
+}
+
+// ─── Image edits (POST /gateway/v1/images/edits) ───
+
+// ImageEdits handles POST /gateway/v1/images/edits. Accepts
+// multipart/form-data with fields: model, prompt, image (or image[], one or
+// more), mask?, n?, size?, quality?. Transparent areas of the mask mark the
+// region to repaint.
+func (s *Server) ImageEdits(w http.ResponseWriter, r *http.Request) {
+	auth, providerKey, actualModel, fullModel, info, ok := s.resolveMultipartMediaProvider(w, r)
+	if !ok {
+		return
+	}
+
+	prompt := r.FormValue("prompt")
+	if prompt == "" {
+		gatewayBadRequest(w, "prompt is required", "prompt", "")
+		return
+	}
+	images, err := formImages(r, "image", "image[]")
+	if err != nil {
+		gatewayBadRequest(w, err.Error(), "image", "")
+		return
+	}
+	if len(images) == 0 {
+		gatewayBadRequest(w, "image field is required", "image", "")
+		return
+	}
+	masks, err := formImages(r, "mask")
+	if err != nil {
+		gatewayBadRequest(w, err.Error(), "mask", "")
+		return
+	}
+	n, err := formInt(r, "n")
+	if err != nil {
+		gatewayBadRequest(w, err.Error(), "n", "")
+		return
+	}
+	if n > maxImages {
+		gatewayBadRequest(w, fmt.Sprintf("n must be at most %d", maxImages), "n", "")
+		return
+	}
+
+	imgProvider, ok := service.As[service.ImageProvider](info.provider)
+	if !ok {
+		unsupportedMediaOperation(w, providerKey, "image edits")
+		return
+	}
+
+	req := service.ImageEditRequest{
+		Images:  images,
+		Prompt:  prompt,
+		Model:   actualModel,
+		N:       n,
+		Size:    r.FormValue("size"),
+		Quality: r.FormValue("quality"),
+	}
+	if len(masks) > 0 {
+		req.Mask = &masks[0]
+	}
+
+	callStart := time.Now()
+	resp, err := imgProvider.EditImage(r.Context(), req)
+	latencyMs := time.Since(callStart).Milliseconds()
+	if err != nil {
+		slog.Error("image edit failed", "provider", providerKey, "error", err)
+		s.recordUsageAsync(r.Context(), auth, fullModel, service.Usage{}, latencyMs, "error", classifyHTTPError(err), err.Error())
+		status, body := classifyGatewayError(err)
+		addGatewayRateLimitHeaders(w, err)
+		httpResponseJSON(w, body, status)
+		return
+	}
 
 	s.recordUsageAsync(r.Context(), auth, fullModel, resp.Usage, latencyMs, "ok", "", "")
-	httpResponseJSON(w, out, http.StatusOK)
+	httpResponseJSON(w, newImagesGenResponse(resp), http.StatusOK)
+}
+
+// ─── Image variations (POST /gateway/v1/images/variations) ───
+
+// ImageVariations handles POST /gateway/v1/images/variations. Accepts
+// multipart/form-data with fields: model, image, n?, size?.
+func (s *Server) ImageVariations(w http.ResponseWriter, r *http.Request) {
+	auth, providerKey, actualModel, fullModel, info, ok := s.resolveMultipartMediaProvider(w, r)
+	if !ok {
+		return
+	}
+
+	images, err := formImages(r, "image")
+	if err != nil {
+		gatewayBadRequest(w, err.Error(), "image", "")
+		return
+	}
+	if len(images) == 0 {
+		gatewayBadRequest(w, "image field is required", "image", "")
+		return
+	}
+	n, err := formInt(r, "n")
+	if err != nil {
+		gatewayBadRequest(w, err.Error(), "n", "")
+		return
+	}
+	if n > maxImages {
+		gatewayBadRequest(w, fmt.Sprintf("n must be at most %d", maxImages), "n", "")
+		return
+	}
+
+	imgProvider, ok := service.As[service.ImageProvider](info.provider)
+	if !ok {
+		unsupportedMediaOperation(w, providerKey, "image variations")
+		return
+	}
+
+	callStart := time.Now()
+	resp, err := imgProvider.CreateImageVariation(r.Context(), service.ImageVariationRequest{
+		Image: images[0],
+		Model: actualModel,
+		N:     n,
+		Size:  r.FormValue("size"),
+	})
+	latencyMs := time.Since(callStart).Milliseconds()
+	if err != nil {
+		slog.Error("image variation failed", "provider", providerKey, "error", err)
+		s.recordUsageAsync(r.Context(), auth, fullModel, service.Usage{}, latencyMs, "error", classifyHTTPError(err), err.Error())
+		status, body := classifyGatewayError(err)
+		addGatewayRateLimitHeaders(w, err)
+		httpResponseJSON(w, body, status)
+		return
+	}
+
+	s.recordUsageAsync(r.Context(), auth, fullModel, resp.Usage, latencyMs, "ok", "", "")
+	httpResponseJSON(w, newImagesGenResponse(resp), http.StatusOK)
+}
+
+// unsupportedMediaOperation writes the 501 for a provider that does not
+// implement the media interface at all. Providers that do but lack the
+// endpoint return service.ErrUnsupportedOperation, which
+// classifyGatewayError maps to the same response.
+func unsupportedMediaOperation(w http.ResponseWriter, providerKey, operation string) {
+	httpResponseJSON(w, map[string]any{
+		"error": map[string]any{
+			"message": fmt.Sprintf("provider %q does not support %s", providerKey, operation),
+			"type":    "invalid_request_error",
+			"code":    "unsupported_operation",
+		},
+	}, http.StatusNotImplemented)
+}
+
+// formImages reads every file uploaded under the given multipart fields.
+// The content type falls back to sniffing when the client sent none.
+func formImages(r *http.Request, fields ...string) ([]service.ImageInput, error) {
+	var images []service.ImageInput
+	for _, field := range fields {
+		for _, header := range r.MultipartForm.File[field] {
+			file, err := header.Open()
+			if err != nil {
+				return nil, fmt.Errorf("failed to open uploaded %s: %w", field, err)
+			}
+			data, err := io.ReadAll(file)
+			file.Close()
+			if err != nil {
+				return nil, fmt.Errorf("failed to read uploaded %s: %w", field, err)
+			}
+			if len(data) == 0 {
+				return nil, fmt.Errorf("uploaded %s is empty", field)
+			}
+
+			contentType := header.Header.Get("Content-Type")
+			if contentType == "" || contentType == "application/octet-stream" {
+				contentType = http.DetectContentType(data)
+			}
+			images = append(images, service.ImageInput{
+				Base64:      base64.StdEncoding.EncodeToString(data),
+				ContentType: contentType,
+				Filename:    header.Filename,
+			})
+		}
+	}
+	return images, nil
+}
+
+// formInt parses an optional integer form field; missing means 0.
+func formInt(r *http.Request, field string) (int, error) {
+	v := r.FormValue(field)
+	if v == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%s must be a non-negative integer", field)
+	}
+	return n, nil
 }
 
 // ─── Audio TTS (POST /gateway/v1/audio/speech) ───
@@ -140,7 +345,7 @@ func (s *Server) AudioSpeech(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	audioProvider, ok := info.provider.(service.AudioProvider)
+	audioProvider, ok := service.As[service.AudioProvider](info.provider)
 	if !ok {
 		httpResponseJSON(w, map[string]any{
 			"error": map[string]any{
@@ -203,73 +408,12 @@ func (s *Server) AudioSpeech(w http.ResponseWriter, r *http.Request) {
 // Accepts multipart/form-data with fields: file, model, language?, prompt?,
 // response_format? (json / verbose_json / text / srt / vtt — default verbose_json).
 func (s *Server) AudioTranscriptions(w http.ResponseWriter, r *http.Request) {
-	auth, authErr := s.authenticateRequest(r)
-	if authErr != "" {
-		httpResponseJSON(w, map[string]any{
-			"error": map[string]any{
-				"message": authErr,
-				"type":    "invalid_request_error",
-				"code":    "invalid_api_key",
-			},
-		}, http.StatusUnauthorized)
-		return
-	}
-
-	// Parse multipart. 64 MB cap matches OpenAI's 25 MB plus headroom.
-	if err := r.ParseMultipartForm(64 << 20); err != nil {
-		gatewayBadRequest(w, fmt.Sprintf("failed to parse multipart form: %v", err), "", "")
-		return
-	}
-
-	model := r.FormValue("model")
-	if model == "" {
-		gatewayBadRequest(w, "model field is required", "model", "")
-		return
-	}
-
-	providerKey, actualModel, err := parseModelID(model)
-	if err != nil {
-		gatewayBadRequest(w, err.Error(), "model", "model_not_found")
-		return
-	}
-	if !auth.isModelAllowed(providerKey, model) {
-		httpResponseJSON(w, map[string]any{
-			"error": map[string]any{
-				"message": fmt.Sprintf("token does not have access to model %q", model),
-				"type":    "invalid_request_error",
-				"param":   "model",
-				"code":    "model_not_found",
-			},
-		}, http.StatusForbidden)
-		return
-	}
-	if limitMessage, resetErr := s.checkTokenLimits(r.Context(), auth); resetErr != nil {
-		slog.Error("token limit check failed", "error", resetErr)
-	} else if limitMessage != "" {
-		httpResponseJSON(w, map[string]any{
-			"error": map[string]any{
-				"message": limitMessage,
-				"type":    "tokens",
-				"code":    "rate_limit_exceeded",
-			},
-		}, http.StatusTooManyRequests)
-		return
-	}
-
-	info, ok := s.getProviderInfo(providerKey)
+	auth, providerKey, actualModel, model, info, ok := s.resolveMultipartMediaProvider(w, r)
 	if !ok {
-		httpResponseJSON(w, map[string]any{
-			"error": map[string]any{
-				"message": fmt.Sprintf("provider %q not found", providerKey),
-				"type":    "invalid_request_error",
-				"param":   "model",
-				"code":    "model_not_found",
-			},
-		}, http.StatusNotFound)
 		return
 	}
 
-	audioProvider, ok := info.provider.(service.AudioProvider)
+	audioProvider, ok := service.As[service.AudioProvider](info.provider)
 	if !ok {
 		httpResponseJSON(w, map[string]any{
 			"error": map[string]any{
@@ -404,7 +548,7 @@ func (s *Server) Moderations(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	modProvider, ok := info.provider.(service.ModerationProvider)
+	modProvider, ok := service.As[service.ModerationProvider](info.provider)
 	if !ok {
 		httpResponseJSON(w, map[string]any{
 			"error": map[string]any{
@@ -467,7 +611,7 @@ func (s *Server) Rerank(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	reranker, ok := info.provider.(service.RerankProvider)
+	reranker, ok := service.As[service.RerankProvider](info.provider)
 	if !ok {
 		httpResponseJSON(w, map[string]any{
 			"error": map[string]any{
@@ -581,6 +725,42 @@ func (s *Server) resolveMediaProvider(w http.ResponseWriter, r *http.Request) (
 		gatewayBadRequest(w, fmt.Sprintf("failed to read request body: %v", perr), "", "")
 		return
 	}
+
+	return s.resolveMediaModel(w, r, authR, model)
+}
+
+// resolveMultipartMediaProvider is resolveMediaProvider for multipart/form-data
+// endpoints: it parses the form (leaving it on r.MultipartForm) and reads
+// the model from the `model` field.
+func (s *Server) resolveMultipartMediaProvider(w http.ResponseWriter, r *http.Request) (
+	auth *authResult, providerKey, actualModel, fullModel string, info ProviderInfo, ok bool,
+) {
+	authR, authErr := s.authenticateRequest(r)
+	if authErr != "" {
+		httpResponseJSON(w, map[string]any{
+			"error": map[string]any{
+				"message": authErr,
+				"type":    "invalid_request_error",
+				"code":    "invalid_api_key",
+			},
+		}, http.StatusUnauthorized)
+		return
+	}
+
+	// 64 MB cap covers OpenAI's 25 MB audio and 50 MB image limits.
+	if err := r.ParseMultipartForm(64 << 20); err != nil {
+		gatewayBadRequest(w, fmt.Sprintf("failed to parse multipart form: %v", err), "", "")
+		return
+	}
+
+	return s.resolveMediaModel(w, r, authR, r.FormValue("model"))
+}
+
+// resolveMediaModel checks that the authenticated token may use model and
+// resolves its provider. Shared tail of the resolve*MediaProvider helpers.
+func (s *Server) resolveMediaModel(w http.ResponseWriter, r *http.Request, authR *authResult, model string) (
+	auth *authResult, providerKey, actualModel, fullModel string, info ProviderInfo, ok bool,
+) {
 	if model == "" {
 		gatewayBadRequest(w, "model field is required", "model", "")
 		return
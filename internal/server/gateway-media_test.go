@@ -1,8 +1,18 @@
 package server
 
 import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
+
+	"github.com/rakunlabs/at/internal/service"
 )
 
 func TestUnsupportedAudioTranscriptionMessageChatGPT(t *testing.T) {
@@ -28,3 +38,170 @@ func TestUnsupportedAudioTranscriptionMessageGeneric(t *testing.T) {
 		t.Errorf("message = %q, want %q", got, want)
 	}
 }
+
+// imageTestProvider records the edit and variation requests it receives.
+type imageTestProvider struct {
+	choicesTestProvider
+
+	edit      *service.ImageEditRequest
+	variation *service.ImageVariationRequest
+	err       error
+}
+
+func (p *imageTestProvider) GenerateImage(_ context.Context, _ service.ImageGenerateRequest) (*service.ImageResponse, error) {
+	return nil, p.err
+}
+
+func (p *imageTestProvider) EditImage(_ context.Context, req service.ImageEditRequest) (*service.ImageResponse, error) {
+	p.edit = &req
+	if p.err != nil {
+		return nil, p.err
+	}
+	return &service.ImageResponse{
+		Images: []service.GeneratedImage{{Base64: "RURJVA=="}},
+		Usage:  service.Usage{PromptTokens: 40, CompletionTokens: 10},
+	}, nil
+}
+
+func (p *imageTestProvider) CreateImageVariation(_ context.Context, req service.ImageVariationRequest) (*service.ImageResponse, error) {
+	p.variation = &req
+	if p.err != nil {
+		return nil, p.err
+	}
+	return &service.ImageResponse{Images: []service.GeneratedImage{{URL: "https://example.com/v.png"}}}, nil
+}
+
+// multipartImageRequest builds a multipart request with the given fields and
+// one PNG file per entry in files (field name → count).
+func multipartImageRequest(t *testing.T, path string, fields map[string]string, files map[string]int) *http.Request {
+	t.Helper()
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+	for k, v := range fields {
+		_ = writer.WriteField(k, v)
+	}
+	for field, count := range files {
+		for i := range count {
+			part, err := writer.CreateFormFile(field, fmt.Sprintf("%s-%d.png", strings.Trim(field, "[]"), i))
+			if err != nil {
+				t.Fatalf("CreateFormFile: %v", err)
+			}
+			_, _ = part.Write([]byte("\x89PNG\r\n\x1a\n" + field))
+		}
+	}
+	_ = writer.Close()
+
+	req := httptest.NewRequest(http.MethodPost, path, &buf)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer test-token")
+	return req
+}
+
+func TestImageEdits(t *testing.T) {
+	provider := &imageTestProvider{}
+	s := newChoicesTestServer(provider)
+
+	rec := httptest.NewRecorder()
+	s.ImageEdits(rec, multipartImageRequest(t, "/gateway/v1/images/edits",
+		map[string]string{"model": "p/gpt-image-1", "prompt": "add a hat", "n": "2", "size": "1024x1024"},
+		map[string]int{"image[]": 2, "mask": 1},
+	))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d; body=%s", rec.Code, rec.Body.String())
+	}
+
+	req := provider.edit
+	if req == nil || req.Model != "gpt-image-1" || req.Prompt != "add a hat" || req.N != 2 || req.Size != "1024x1024" {
+		t.Fatalf("edit request = %+v", req)
+	}
+	if len(req.Images) != 2 || req.Mask == nil || req.Mask.ContentType != "image/png" {
+		t.Fatalf("images = %d, mask = %+v", len(req.Images), req.Mask)
+	}
+	if mask, _ := base64.StdEncoding.DecodeString(req.Mask.Base64); !strings.HasSuffix(string(mask), "mask") {
+		t.Errorf("mask data = %q", mask)
+	}
+
+	var resp imagesGenResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(resp.Data) != 1 || resp.Data[0].B64JSON != "RURJVA==" || resp.Usage == nil || resp.Usage.TotalTokens != 50 {
+		t.Errorf("response = %s", rec.Body.String())
+	}
+}
+
+func TestImageEditsValidation(t *testing.T) {
+	s := newChoicesTestServer(&imageTestProvider{})
+
+	tests := []struct {
+		name   string
+		fields map[string]string
+		files  map[string]int
+	}{
+		{"missing prompt", map[string]string{"model": "p/m"}, map[string]int{"image": 1}},
+		{"missing image", map[string]string{"model": "p/m", "prompt": "x"}, nil},
+		{"bad n", map[string]string{"model": "p/m", "prompt": "x", "n": "two"}, map[string]int{"image": 1}},
+		{"n above cap", map[string]string{"model": "p/m", "prompt": "x", "n": "11"}, map[string]int{"image": 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			s.ImageEdits(rec, multipartImageRequest(t, "/gateway/v1/images/edits", tt.fields, tt.files))
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want 400; body=%s", rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestImageVariations(t *testing.T) {
+	provider := &imageTestProvider{}
+	s := newChoicesTestServer(provider)
+
+	rec := httptest.NewRecorder()
+	s.ImageVariations(rec, multipartImageRequest(t, "/gateway/v1/images/variations",
+		map[string]string{"model": "p/dall-e-2"},
+		map[string]int{"image": 1},
+	))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d; body=%s", rec.Code, rec.Body.String())
+	}
+	if req := provider.variation; req == nil || req.Model != "dall-e-2" || req.Image.Filename != "image-0.png" {
+		t.Fatalf("variation request = %+v", req)
+	}
+	if !strings.Contains(rec.Body.String(), "https://example.com/v.png") {
+		t.Errorf("body = %s", rec.Body.String())
+	}
+	rec = httptest.NewRecorder()
+	s.ImageVariations(rec, multipartImageRequest(t, "/gateway/v1/images/variations",
+		map[string]string{"model": "p/dall-e-2", "n": "11"},
+		map[string]int{"image": 1},
+	))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("n above cap: status = %d, want 400; body=%s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestImageEditsUnsupported(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider service.LLMProvider
+	}{
+		{"not an image provider", &choicesTestProvider{}},
+		{"provider without edits", &imageTestProvider{err: fmt.Errorf("no edits: %w", service.ErrUnsupportedOperation)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newChoicesTestServer(tt.provider)
+
+			rec := httptest.NewRecorder()
+			s.ImageEdits(rec, multipartImageRequest(t, "/gateway/v1/images/edits",
+				map[string]string{"model": "p/m", "prompt": "x"},
+				map[string]int{"image": 1},
+			))
+			if rec.Code != http.StatusNotImplemented || !strings.Contains(rec.Body.String(), "unsupported_operation") {
+				t.Errorf("status = %d, want 501; body=%s", rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
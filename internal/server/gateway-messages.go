@@ -0,0 +1,1016 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+
+	"github.com/rakunlabs/at/internal/service"
+)
+
+// ─── Anthropic Messages API (inbound compatibility layer) ───
+//
+// POST /gateway/v1/messages accepts an Anthropic Messages request and
+// routes it by "provider/model" to any configured LLMProvider, so
+// Anthropic-native clients (Claude Code, the anthropic SDKs) can talk to
+// Gemini, OpenAI, Bedrock, … models through the gateway.
+//
+// The request is translated into the chat.completions message shape and
+// then through buildProviderMessages, exactly like /v1/chat/completions,
+// so every provider sees the representation it already understands.
+//
+// What we support:
+//   - `system` as a string or text blocks
+//   - text, image (base64 / url) and document (base64) content blocks
+//   - tool_use / tool_result blocks and custom tools with tool_choice
+//   - `thinking` config; thinking output is returned as a thinking block
+//   - `max_tokens`, `temperature`, `top_p`, `stop_sequences`,
+//     `metadata.user_id`
+//   - Streaming with the native event sequence (message_start,
+//     content_block_start/delta/stop, message_delta, message_stop)
+//   - POST /gateway/v1/messages/count_tokens (estimated)
+//   - AT extensions: at_fallbacks, extra_body, mock_response, timeout_ms
+//
+// Signed thinking blocks from earlier turns are replayed only to Claude
+// targets (anthropic, vertex-anthropic, Claude on bedrock), which require
+// them in extended-thinking tool loops. Signatures only verify against
+// the model that produced them, so other targets get the turn without
+// them (Gemini thought signatures are restored from the server-side
+// cache keyed by tool_use ID).
+//
+// What we do NOT support:
+//   - Anthropic server tools (web_search, bash, text_editor, …); only
+//     custom tools are forwarded.
+//   - `top_k` and `cache_control` (use extra_body for provider-native
+//     fields).
+
+// messagesRequest is the Anthropic Messages API request body.
+type messagesRequest struct {
+	Model         string              `json:"model"`
+	Messages      []messagesInput     `json:"messages"`
+	System        json.RawMessage     `json:"system,omitempty"` // string or []text block
+	MaxTokens     *int                `json:"max_tokens,omitempty"`
+	Temperature   *float64            `json:"temperature,omitempty"`
+	TopP          *float64            `json:"top_p,omitempty"`
+	StopSequences []string            `json:"stop_sequences,omitempty"`
+	Stream        bool                `json:"stream,omitempty"`
+	Tools         []messagesTool      `json:"tools,omitempty"`
+	ToolChoice    *messagesToolChoice `json:"tool_choice,omitempty"`
+	Thinking      *ThinkingReq        `json:"thinking,omitempty"`
+	Metadata      *messagesMetadata   `json:"metadata,omitempty"`
+
+	// AT extensions
+	AtFallbacks  []string       `json:"at_fallbacks,omitempty"`
+	ExtraBody    map[string]any `json:"extra_body,omitempty"`
+	MockResponse string         `json:"mock_response,omitempty"`
+	TimeoutMs    int            `json:"timeout_ms,omitempty"`
+}
+
+type messagesInput struct {
+	Role    string          `json:"role"`
+	Content json.RawMessage `json:"content"` // string or []content block
+}
+
+// messagesBlock is an inbound content block. Only the fields of the block
+// types we translate are decoded.
+type messagesBlock struct {
+	Type string `json:"type"`
+
+	// text
+	Text string `json:"text,omitempty"`
+
+	// image / document
+	Source *service.MediaSource `json:"source,omitempty"`
+
+	// thinking / redacted_thinking
+	Thinking  string `json:"thinking,omitempty"`
+	Signature string `json:"signature,omitempty"`
+	Data      string `json:"data,omitempty"`
+
+	// tool_use
+	ID    string          `json:"id,omitempty"`
+	Name  string          `json:"name,omitempty"`
+	Input json.RawMessage `json:"input,omitempty"`
+
+	// tool_result
+	ToolUseID string          `json:"tool_use_id,omitempty"`
+	Content   json.RawMessage `json:"content,omitempty"` // string or []block
+	IsError   bool            `json:"is_error,omitempty"`
+}
+
+type messagesTool struct {
+	Type        string         `json:"type,omitempty"` // "" | "custom" | server tool versions
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	InputSchema map[string]any `json:"input_schema,omitempty"`
+}
+
+type messagesToolChoice struct {
+	Type                   string `json:"type"` // "auto" | "any" | "tool" | "none"
+	Name                   string `json:"name,omitempty"`
+	DisableParallelToolUse bool   `json:"disable_parallel_tool_use,omitempty"`
+}
+
+type messagesMetadata struct {
+	UserID string `json:"user_id,omitempty"`
+}
+
+// messagesResponse is the Anthropic Messages API response body.
+type messagesResponse struct {
+	ID           string             `json:"id"`
+	Type         string             `json:"type"` // "message"
+	Role         string             `json:"role"` // "assistant"
+	Model        string             `json:"model"`
+	Content      []messagesOutBlock `json:"content"`
+	StopReason   string             `json:"stop_reason"`
+	StopSequence *string            `json:"stop_sequence"`
+	Usage        messagesUsage      `json:"usage"`
+}
+
+type messagesOutBlock struct {
+	Type      string         `json:"type"` // "text" | "thinking" | "tool_use"
+	Text      *string        `json:"text,omitempty"`
+	Thinking  *string        `json:"thinking,omitempty"`
+	Signature *string        `json:"signature,omitempty"`
+	ID        string         `json:"id,omitempty"`
+	Name      string         `json:"name,omitempty"`
+	Input     map[string]any `json:"input,omitempty"`
+}
+
+type messagesUsage struct {
+	InputTokens              int `json:"input_tokens"`
+	OutputTokens             int `json:"output_tokens"`
+	CacheCreationInputTokens int `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int `json:"cache_read_input_tokens"`
+}
+
+// Messages handles POST /gateway/v1/messages.
+func (s *Server) Messages(w http.ResponseWriter, r *http.Request) {
+	auth, authErr := s.authenticateRequest(r)
+	if authErr != "" {
+		writeMessagesError(w, http.StatusUnauthorized, authErr)
+		return
+	}
+
+	rawBody, _ := io.ReadAll(r.Body)
+	var req messagesRequest
+	if err := json.Unmarshal(rawBody, &req); err != nil {
+		writeMessagesError(w, http.StatusBadRequest, fmt.Sprintf("invalid request body: %v", err))
+		return
+	}
+	traceID, sessionID := auditTraceInfo(r)
+
+	if req.MockResponse != "" {
+		w.Header().Set("x-at-mock-response", "true")
+		mock := &service.LLMResponse{
+			Content:      req.MockResponse,
+			Finished:     true,
+			FinishReason: "stop",
+			Usage:        service.Usage{CompletionTokens: len(req.MockResponse) / 4},
+		}
+		if req.Stream {
+			s.writeMessagesStream(w, r, req.Model, llmResponseChunks(mock))
+			return
+		}
+		httpResponseJSON(w, buildMessagesResponse(req.Model, mock), http.StatusOK)
+		return
+	}
+
+	chain := s.chatCallChain(auth, req.Model, req.AtFallbacks)
+	if len(chain) == 0 || req.Model == "" {
+		writeMessagesError(w, http.StatusBadRequest, "model field is required")
+		return
+	}
+	if first := chain[0]; first.err != nil {
+		writeMessagesError(w, messagesTargetErrorStatus(first.err), first.err.Error())
+		return
+	}
+	if err := s.rejectGatewayHooks(r.Context(), auth, chainModels(chain)...); err != nil {
+		writeMessagesGatewayError(w, err)
+		return
+	}
+
+	chatMsgs, err := messagesToOpenAIMessages(req.System, req.Messages)
+	if err != nil {
+		writeMessagesError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	chatTools := messagesToolsToOpenAI(req.Tools)
+
+	if limitMessage, resetErr := s.checkTokenLimits(r.Context(), auth); resetErr != nil {
+		slog.Error("token limit check failed", "error", resetErr)
+	} else if limitMessage != "" {
+		writeMessagesError(w, http.StatusTooManyRequests, limitMessage)
+		return
+	}
+
+	callCtx, cancel := withRequestTimeout(r.Context(), req.TimeoutMs)
+	defer cancel()
+
+	baseOpts := messagesRequestToChatOptions(&req)
+	userField := ""
+	if req.Metadata != nil {
+		userField = req.Metadata.UserID
+	}
+
+	if req.Stream {
+		// Streaming path: no fallback, same as chat streaming.
+		target := chain[0]
+		messages, tools := s.buildProviderMessages(target.info.providerType, messagesForTarget(chatMsgs, target), chatTools)
+		opts := cloneChatOptions(baseOpts)
+		if err := s.adaptToModelCapabilities(callCtx, &target, messages, tools, opts, true); err != nil {
+			writeMessagesGatewayError(w, err)
+			return
+		}
+		audit := streamAuditCtx{
+			auth: auth, source: "messages", endpoint: r.URL.Path,
+			traceID: traceID, sessionID: sessionID, userField: userField,
+			requestBody: rawBody, requestedModel: req.Model,
+		}
+		rs := r.WithContext(callCtx)
+		s.relayChatStream(w, rs, auth, target, messages, tools, opts, audit,
+			writeMessagesGatewayError,
+			func(chunks <-chan service.StreamChunk) streamRelayResult {
+				return s.writeMessagesStream(w, rs, target.fullModel, chunks)
+			})
+		return
+	}
+
+	var (
+		lastErr      error
+		used         chatCallTarget
+		resp         *service.LLMResponse
+		totalLatency int64
+	)
+	for i, target := range chain {
+		if target.err != nil {
+			continue
+		}
+		messages, tools := s.buildProviderMessages(target.info.providerType, messagesForTarget(chatMsgs, target), chatTools)
+		opts := cloneChatOptions(baseOpts)
+		if err := s.adaptToModelCapabilities(callCtx, &target, messages, tools, opts, false); err != nil {
+			lastErr = err
+			continue
+		}
+
+		callStart := time.Now()
+		r2, err := callWithGatewayRetry(callCtx, target.providerKey, target.actualModel,
+			target.info.RetryAfterCap(),
+			func(ctx context.Context) (*service.LLMResponse, error) {
+				return target.info.provider.Chat(ctx, target.actualModel, messages, tools, opts)
+			})
+		totalLatency += time.Since(callStart).Milliseconds()
+		if err == nil {
+			resp = r2
+			used = target
+			break
+		}
+		lastErr = err
+		slog.Warn("messages provider call failed",
+			"attempt", i, "provider", target.providerKey, "model", target.actualModel, "error", err)
+		s.recordUsageAsync(r.Context(), auth, target.fullModel, service.Usage{}, totalLatency, "error", classifyHTTPError(err), err.Error())
+		s.recordLLMCallAsync(r.Context(), llmAuditParams{
+			auth: auth, source: "messages", endpoint: r.URL.Path,
+			traceID: traceID, sessionID: sessionID, userField: userField,
+			requestBody: rawBody, requestedModel: req.Model, fullModel: target.fullModel,
+			latencyMs: totalLatency, status: "error",
+			errCode: classifyHTTPError(err), errMsg: err.Error(),
+		})
+		if !shouldFallback(err) {
+			break
+		}
+	}
+
+	if resp == nil {
+		writeMessagesGatewayError(w, lastErr)
+		return
+	}
+
+	if used.fullModel != req.Model {
+		w.Header().Set("x-at-model-used", used.fullModel)
+	}
+	s.cacheThoughtSignatures(resp.ToolCalls)
+	out := buildMessagesResponse(used.fullModel, resp)
+	s.recordUsageAsync(r.Context(), auth, used.fullModel, resp.Usage, totalLatency, "ok", "", "")
+	if respBody, mErr := json.Marshal(out); mErr == nil {
+		s.recordLLMCallAsync(r.Context(), llmAuditParams{
+			auth: auth, source: "messages", endpoint: r.URL.Path,
+			traceID: traceID, sessionID: sessionID, userField: userField,
+			requestBody: rawBody, responseBody: respBody,
+			requestedModel: req.Model, fullModel: used.fullModel,
+			usage: resp.Usage, latencyMs: totalLatency, status: "ok",
+			finishReason: normalizeFinishReason(resp),
+		})
+	}
+	httpResponseJSON(w, out, http.StatusOK)
+}
+
+// MessagesCountTokens handles POST /gateway/v1/messages/count_tokens. The
+// translated request is counted like /gateway/v1/tokenize: natively when
+// the target provider supports it, otherwise with the local estimate.
+func (s *Server) MessagesCountTokens(w http.ResponseWriter, r *http.Request) {
+	auth, authErr := s.authenticateRequest(r)
+	if authErr != "" {
+		writeMessagesError(w, http.StatusUnauthorized, authErr)
+		return
+	}
+
+	var req messagesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeMessagesError(w, http.StatusBadRequest, fmt.Sprintf("invalid request body: %v", err))
+		return
+	}
+
+	providerKey, actualModel, info, err := s.resolveModel(auth, req.Model)
+	if err != nil {
+		writeMessagesError(w, messagesTargetErrorStatus(err), err.Error())
+		return
+	}
+
+	chatMsgs, err := messagesToOpenAIMessages(req.System, req.Messages)
+	if err != nil {
+		writeMessagesError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	target := chatCallTarget{providerKey: providerKey, actualModel: actualModel, info: info}
+	messages, tools := s.buildProviderMessages(info.providerType, messagesForTarget(chatMsgs, target), messagesToolsToOpenAI(req.Tools))
+	n, method, err := s.countInputTokens(r.Context(), auth, providerKey, actualModel, info, messages, tools)
+	if err != nil {
+		writeMessagesGatewayError(w, err)
+		return
+	}
+	w.Header().Set("X-Token-Count-Method", method)
+	httpResponseJSON(w, map[string]any{"input_tokens": n}, http.StatusOK)
+}
+
+// relayChatStream opens the upstream stream (or falls back to a single
+// Chat call for providers without streaming), hands the chunks to relay,
+// which writes them in the client's wire format, and records usage and
+// the audit entry. Open failures go through writeErr before anything has
+// been written, so they keep an upstream-faithful status code.
+func (s *Server) relayChatStream(
+	w http.ResponseWriter,
+	r *http.Request,
+	auth *authResult,
+	target chatCallTarget,
+	messages []service.Message,
+	tools []service.Tool,
+	opts *service.ChatOptions,
+	audit streamAuditCtx,
+	writeErr func(http.ResponseWriter, error),
+	relay func(<-chan service.StreamChunk) streamRelayResult,
+) {
+	callStart := time.Now()
+
+	var (
+		chunks <-chan service.StreamChunk
+		err    error
+	)
+	if sp, ok := service.As[service.LLMStreamProvider](target.info.provider); ok {
+		chunks, err = callWithGatewayRetry(r.Context(), target.providerKey, target.actualModel,
+			target.info.RetryAfterCap(),
+			func(ctx context.Context) (<-chan service.StreamChunk, error) {
+				ch, _, e := sp.ChatStream(ctx, target.actualModel, messages, tools, opts)
+				return ch, e
+			})
+	} else {
+		var resp *service.LLMResponse
+		resp, err = callWithGatewayRetry(r.Context(), target.providerKey, target.actualModel,
+			target.info.RetryAfterCap(),
+			func(ctx context.Context) (*service.LLMResponse, error) {
+				return target.info.provider.Chat(ctx, target.actualModel, messages, tools, opts)
+			})
+		if err == nil {
+			chunks = llmResponseChunks(resp)
+		}
+	}
+	if err != nil {
+		latency := time.Since(callStart).Milliseconds()
+		s.recordUsageAsync(r.Context(), auth, target.fullModel, service.Usage{}, latency, "error", classifyHTTPError(err), err.Error())
+		s.recordLLMCallAsync(r.Context(), llmAuditParams{
+			auth: auth, source: audit.resolveSource(), endpoint: audit.endpoint,
+			traceID: audit.traceID, sessionID: audit.sessionID, userField: audit.userField,
+			requestBody: audit.requestBody, requestedModel: audit.requestedModel, fullModel: target.fullModel,
+			latencyMs: latency, streamed: true, status: "error",
+			errCode: classifyHTTPError(err), errMsg: err.Error(),
+		})
+		writeErr(w, err)
+		return
+	}
+
+	res := relay(chunks)
+	latency := time.Since(callStart).Milliseconds()
+
+	status, errCode, errMsg := "ok", "", ""
+	if res.err != nil {
+		status, errCode, errMsg = "error", "provider_error", res.err.Error()
+	} else {
+		s.recordUsageAsync(r.Context(), auth, target.fullModel, usageOrZero(res.usage), latency, "ok", "", "")
+	}
+	s.recordLLMCallAsync(r.Context(), llmAuditParams{
+		auth: auth, source: audit.resolveSource(), endpoint: audit.endpoint,
+		traceID: audit.traceID, sessionID: audit.sessionID, userField: audit.userField,
+		requestBody: audit.requestBody, requestedModel: audit.requestedModel, fullModel: target.fullModel,
+		responseBody: streamAuditResponseBody(res.id, target.fullModel, res.content, res.reasoning, res.toolCalls, res.finishReason, res.usage),
+		usage:        usageOrZero(res.usage), latencyMs: latency, streamed: true,
+		status: status, errCode: errCode, errMsg: errMsg, finishReason: res.finishReason,
+	})
+}
+
+// streamRelayResult is the assistant turn reconstructed from a relayed
+// stream, used for usage accounting and the audit log.
+type streamRelayResult struct {
+	id           string
+	content      string
+	reasoning    string
+	toolCalls    []service.ToolCall
+	finishReason string
+	usage        *service.Usage
+	err          error
+}
+
+// writeMessagesStream relays chunks as Anthropic Messages SSE events. Text,
+// thinking and tool calls each become their own content block; a new
+// block is opened whenever the kind of delta changes.
+func (s *Server) writeMessagesStream(w http.ResponseWriter, r *http.Request, model string, chunks <-chan service.StreamChunk) streamRelayResult {
+	res := streamRelayResult{id: "msg_" + ulid.Make().String()}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeMessagesError(w, http.StatusInternalServerError, "streaming not supported by this server")
+		res.err = fmt.Errorf("streaming not supported")
+		return res
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+
+	emit := func(eventType string, data map[string]any) {
+		data["type"] = eventType
+		buf, _ := json.Marshal(data)
+		fmt.Fprintf(w, "event: %s\ndata: %s\n\n", eventType, buf)
+		flusher.Flush()
+	}
+
+	emit("message_start", map[string]any{
+		"message": map[string]any{
+			"id":            res.id,
+			"type":          "message",
+			"role":          "assistant",
+			"model":         model,
+			"content":       []any{},
+			"stop_reason":   nil,
+			"stop_sequence": nil,
+			"usage":         messagesUsage{},
+		},
+	})
+
+	var (
+		content   strings.Builder
+		reasoning strings.Builder
+		index     int
+		openKind  string
+		rawFinish string
+	)
+	closeBlock := func() {
+		if openKind == "" {
+			return
+		}
+		emit("content_block_stop", map[string]any{"index": index})
+		index++
+		openKind = ""
+	}
+	openBlock := func(kind string, block map[string]any) {
+		closeBlock()
+		emit("content_block_start", map[string]any{"index": index, "content_block": block})
+		openKind = kind
+	}
+
+	for chunk := range chunks {
+		if chunk.Error != nil {
+			slog.Error("messages stream chunk error", "model", model, "error", chunk.Error)
+			emit("error", map[string]any{
+				"error": map[string]any{"type": "api_error", "message": chunk.Error.Error()},
+			})
+			res.err = chunk.Error
+			break
+		}
+		if chunk.Usage != nil {
+			u := *chunk.Usage
+			res.usage = &u
+		}
+		if chunk.FinishReason != "" {
+			rawFinish = chunk.FinishReason
+		}
+		if chunk.ReasoningContent != "" {
+			if openKind != "thinking" {
+				openBlock("thinking", map[string]any{"type": "thinking", "thinking": ""})
+			}
+			reasoning.WriteString(chunk.ReasoningContent)
+			emit("content_block_delta", map[string]any{
+				"index": index,
+				"delta": map[string]any{"type": "thinking_delta", "thinking": chunk.ReasoningContent},
+			})
+		}
+		if chunk.ReasoningSignature != "" && openKind == "thinking" {
+			emit("content_block_delta", map[string]any{
+				"index": index,
+				"delta": map[string]any{"type": "signature_delta", "signature": chunk.ReasoningSignature},
+			})
+		}
+		if chunk.Content != "" {
+			if openKind != "text" {
+				openBlock("text", map[string]any{"type": "text", "text": ""})
+			}
+			content.WriteString(chunk.Content)
+			emit("content_block_delta", map[string]any{
+				"index": index,
+				"delta": map[string]any{"type": "text_delta", "text": chunk.Content},
+			})
+		}
+		for _, tc := range chunk.ToolCalls {
+			s.cacheThoughtSignatures([]service.ToolCall{tc})
+			res.toolCalls = append(res.toolCalls, tc)
+			openBlock("tool_use", map[string]any{
+				"type":  "tool_use",
+				"id":    tc.ID,
+				"name":  tc.Name,
+				"input": map[string]any{},
+			})
+			args := tc.Arguments
+			if args == nil {
+				args = map[string]any{}
+			}
+			argsJSON, _ := json.Marshal(args)
+			emit("content_block_delta", map[string]any{
+				"index": index,
+				"delta": map[string]any{"type": "input_json_delta", "partial_json": string(argsJSON)},
+			})
+			closeBlock()
+		}
+	}
+	if res.err != nil {
+		res.content, res.reasoning, res.finishReason = content.String(), reasoning.String(), "error"
+		return res
+	}
+	closeBlock()
+
+	res.content, res.reasoning = content.String(), reasoning.String()
+	res.finishReason = mapStreamFinishReason(rawFinish, len(res.toolCalls) > 0)
+	usage := messagesUsageFromService(usageOrZero(res.usage))
+	emit("message_delta", map[string]any{
+		"delta": map[string]any{
+			"stop_reason":   messagesStopReason(res.finishReason),
+			"stop_sequence": nil,
+		},
+		"usage": usage,
+	})
+	emit("message_stop", map[string]any{})
+
+	if r.Context().Err() != nil {
+		res.err = r.Context().Err()
+	}
+	return res
+}
+
+// llmResponseChunks replays a complete response as a closed stream so
+// non-streaming providers share the streaming writer.
+func llmResponseChunks(resp *service.LLMResponse) <-chan service.StreamChunk {
+	ch := make(chan service.StreamChunk, 1)
+	u := resp.Usage
+	ch <- service.StreamChunk{
+		Content:            resp.Content,
+		ReasoningContent:   resp.ReasoningContent,
+		ReasoningSignature: resp.ReasoningSignature,
+		ToolCalls:          resp.ToolCalls,
+		FinishReason:       normalizeFinishReason(resp),
+		Usage:              &u,
+	}
+	close(ch)
+	return ch
+}
+
+// messagesToOpenAIMessages translates the Anthropic system + messages into
+// the chat.completions messages array our translators understand.
+// tool_result blocks become role "tool" messages placed before any other
+// content of the same user turn, mirroring Anthropic's ordering rule.
+func messagesToOpenAIMessages(system json.RawMessage, msgs []messagesInput) ([]OpenAIMessage, error) {
+	var out []OpenAIMessage
+
+	if sys, err := messagesTextContent(system); err != nil {
+		return nil, fmt.Errorf("system: %w", err)
+	} else if sys != "" {
+		out = append(out, OpenAIMessage{Role: "system", Content: json.RawMessage(mustJSONString(sys))})
+	}
+
+	for i, m := range msgs {
+		if m.Role != "user" && m.Role != "assistant" {
+			return nil, fmt.Errorf("messages[%d]: unsupported role %q", i, m.Role)
+		}
+
+		var text string
+		if err := json.Unmarshal(m.Content, &text); err == nil {
+			out = append(out, OpenAIMessage{Role: m.Role, Content: json.RawMessage(mustJSONString(text))})
+			continue
+		}
+
+		var blocks []messagesBlock
+		if err := json.Unmarshal(m.Content, &blocks); err != nil {
+			return nil, fmt.Errorf("messages[%d]: content must be a string or an array of content blocks", i)
+		}
+
+		if m.Role == "assistant" {
+			msg, err := messagesAssistantToOpenAI(blocks)
+			if err != nil {
+				return nil, fmt.Errorf("messages[%d]: %w", i, err)
+			}
+			out = append(out, msg)
+			continue
+		}
+
+		var parts []map[string]any
+		for _, b := range blocks {
+			switch b.Type {
+			case "text":
+				parts = append(parts, map[string]any{"type": "text", "text": b.Text})
+			case "image":
+				if url := messagesMediaURL(b.Source); url != "" {
+					parts = append(parts, map[string]any{
+						"type":      "image_url",
+						"image_url": map[string]any{"url": url},
+					})
+				}
+			case "document":
+				if b.Source == nil {
+					continue
+				}
+				switch b.Source.Type {
+				case "base64":
+					parts = append(parts, map[string]any{
+						"type": "file",
+						"file": map[string]any{
+							"file_data": map[string]any{"mime_type": b.Source.MediaType, "data": b.Source.Data},
+						},
+					})
+				case "text":
+					parts = append(parts, map[string]any{"type": "text", "text": b.Source.Data})
+				}
+			case "tool_result":
+				result, err := messagesTextContent(b.Content)
+				if err != nil {
+					return nil, fmt.Errorf("messages[%d]: tool_result %q: %w", i, b.ToolUseID, err)
+				}
+				if b.IsError && result == "" {
+					result = "error"
+				}
+				out = append(out, OpenAIMessage{
+					Role:       "tool",
+					ToolCallID: b.ToolUseID,
+					Content:    json.RawMessage(mustJSONString(result)),
+				})
+			case "thinking", "redacted_thinking":
+				// Not valid in user turns; ignore.
+			default:
+				return nil, fmt.Errorf("messages[%d]: unsupported content block type %q", i, b.Type)
+			}
+		}
+		if len(parts) > 0 {
+			buf, err := json.Marshal(parts)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, OpenAIMessage{Role: "user", Content: buf})
+		}
+	}
+
+	return out, nil
+}
+
+// messagesAssistantToOpenAI folds an assistant turn's blocks into one
+// chat.completions assistant message. Signed thinking blocks are kept
+// aside in Thinking; unsigned ones cannot be replayed and are dropped.
+func messagesAssistantToOpenAI(blocks []messagesBlock) (OpenAIMessage, error) {
+	var (
+		text     strings.Builder
+		calls    []OpenAIToolCall
+		thinking []service.ContentBlock
+	)
+	for _, b := range blocks {
+		switch b.Type {
+		case "text":
+			text.WriteString(b.Text)
+		case "tool_use":
+			args := string(b.Input)
+			if args == "" || args == "null" {
+				args = "{}"
+			}
+			calls = append(calls, OpenAIToolCall{
+				ID:   b.ID,
+				Type: "function",
+				Function: OpenAIFunctionCall{
+					Name:      b.Name,
+					Arguments: args,
+				},
+			})
+		case "thinking":
+			if b.Signature != "" {
+				thinking = append(thinking, service.ContentBlock{Type: "thinking", Thinking: b.Thinking, Signature: b.Signature})
+			}
+		case "redacted_thinking":
+			if b.Data != "" {
+				thinking = append(thinking, service.ContentBlock{Type: "redacted_thinking", Data: b.Data})
+			}
+		default:
+			return OpenAIMessage{}, fmt.Errorf("unsupported assistant content block type %q", b.Type)
+		}
+	}
+	return OpenAIMessage{
+		Role:      "assistant",
+		Content:   json.RawMessage(mustJSONString(text.String())),
+		ToolCalls: calls,
+		Thinking:  thinking,
+	}, nil
+}
+
+// keepsThinking reports whether a target accepts signed Claude thinking
+// blocks on replayed assistant turns.
+func keepsThinking(target chatCallTarget) bool {
+	switch target.info.providerType {
+	case "anthropic", "vertex-anthropic":
+		return true
+	case "bedrock":
+		model := strings.ToLower(target.actualModel)
+		return strings.Contains(model, "anthropic") || strings.Contains(model, "claude")
+	}
+	return false
+}
+
+// messagesForTarget returns msgs without thinking blocks unless target
+// is a Claude model that needs them.
+func messagesForTarget(msgs []OpenAIMessage, target chatCallTarget) []OpenAIMessage {
+	if keepsThinking(target) {
+		return msgs
+	}
+	out := make([]OpenAIMessage, len(msgs))
+	for i, m := range msgs {
+		m.Thinking = nil
+		out[i] = m
+	}
+	return out
+}
+
+// messagesTextContent flattens a string-or-blocks value (system prompt,
+// tool_result content) to text. Non-text blocks are skipped.
+func messagesTextContent(raw json.RawMessage) (string, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return "", nil
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s, nil
+	}
+	var blocks []messagesBlock
+	if err := json.Unmarshal(raw, &blocks); err != nil {
+		return "", fmt.Errorf("must be a string or an array of content blocks")
+	}
+	texts := make([]string, 0, len(blocks))
+	for _, b := range blocks {
+		if b.Type == "text" {
+			texts = append(texts, b.Text)
+		}
+	}
+	return strings.Join(texts, "\n"), nil
+}
+
+// messagesMediaURL renders an Anthropic media source as the URL form used
+// by chat.completions image_url parts (a data URL for base64 sources).
+func messagesMediaURL(src *service.MediaSource) string {
+	if src == nil {
+		return ""
+	}
+	switch src.Type {
+	case "base64":
+		if src.Data == "" {
+			return ""
+		}
+		return "data:" + src.MediaType + ";base64," + src.Data
+	case "url":
+		return src.URL
+	}
+	return ""
+}
+
+// messagesToolsToOpenAI converts custom tools to the chat.completions tool
+// shape. Anthropic server tools (typed, versioned) have no equivalent on
+// other providers and are skipped.
+func messagesToolsToOpenAI(tools []messagesTool) []OpenAITool {
+	out := make([]OpenAITool, 0, len(tools))
+	for _, t := range tools {
+		if t.Type != "" && t.Type != "custom" {
+			continue
+		}
+		out = append(out, OpenAITool{
+			Type: "function",
+			Function: OpenAIFunction{
+				Name:        t.Name,
+				Description: t.Description,
+				Parameters:  t.InputSchema,
+			},
+		})
+	}
+	return out
+}
+
+// messagesRequestToChatOptions builds ChatOptions from the Messages API
+// request shape. Returns nil when no generation parameter was set.
+func messagesRequestToChatOptions(req *messagesRequest) *service.ChatOptions {
+	opts := &service.ChatOptions{}
+	hasAny := false
+
+	if req.MaxTokens != nil {
+		opts.MaxTokens = req.MaxTokens
+		hasAny = true
+	}
+	if req.Temperature != nil {
+		opts.Temperature = req.Temperature
+		hasAny = true
+	}
+	if req.TopP != nil {
+		opts.TopP = req.TopP
+		hasAny = true
+	}
+	if len(req.StopSequences) > 0 {
+		opts.Stop = req.StopSequences
+		hasAny = true
+	}
+	if req.Thinking != nil && req.Thinking.Type == "enabled" {
+		opts.Thinking = &service.ThinkingConfig{
+			Type:         req.Thinking.Type,
+			BudgetTokens: req.Thinking.BudgetTokens,
+		}
+		hasAny = true
+	}
+	if tc := req.ToolChoice; tc != nil {
+		switch tc.Type {
+		case "auto":
+			opts.ToolChoice = "auto"
+		case "any":
+			opts.ToolChoice = "required"
+		case "none":
+			opts.ToolChoice = "none"
+		case "tool":
+			opts.ToolChoice = map[string]any{
+				"type":     "function",
+				"function": map[string]any{"name": tc.Name},
+			}
+		}
+		if tc.DisableParallelToolUse {
+			parallel := false
+			opts.ParallelToolCalls = &parallel
+		}
+		hasAny = hasAny || opts.ToolChoice != nil || opts.ParallelToolCalls != nil
+	}
+	if req.Metadata != nil && req.Metadata.UserID != "" {
+		opts.User = req.Metadata.UserID
+		hasAny = true
+	}
+	if len(req.ExtraBody) > 0 {
+		opts.ExtraBody = req.ExtraBody
+		hasAny = true
+	}
+
+	if !hasAny {
+		return nil
+	}
+	return opts
+}
+
+// buildMessagesResponse maps an internal LLMResponse to the Anthropic
+// Messages response shape.
+func buildMessagesResponse(model string, resp *service.LLMResponse) *messagesResponse {
+	out := &messagesResponse{
+		ID:         "msg_" + ulid.Make().String(),
+		Type:       "message",
+		Role:       "assistant",
+		Model:      model,
+		Content:    []messagesOutBlock{},
+		StopReason: messagesStopReason(normalizeFinishReason(resp)),
+		Usage:      messagesUsageFromService(resp.Usage),
+	}
+
+	if resp.ReasoningContent != "" {
+		thinking, signature := resp.ReasoningContent, resp.ReasoningSignature
+		out.Content = append(out.Content, messagesOutBlock{Type: "thinking", Thinking: &thinking, Signature: &signature})
+	}
+	if resp.Content != "" {
+		text := resp.Content
+		out.Content = append(out.Content, messagesOutBlock{Type: "text", Text: &text})
+	}
+	for _, tc := range resp.ToolCalls {
+		input := tc.Arguments
+		if input == nil {
+			input = map[string]any{}
+		}
+		out.Content = append(out.Content, messagesOutBlock{
+			Type:  "tool_use",
+			ID:    tc.ID,
+			Name:  tc.Name,
+			Input: input,
+		})
+	}
+
+	return out
+}
+
+// messagesStopReason maps an OpenAI-vocabulary finish reason onto the
+// Anthropic stop_reason values.
+func messagesStopReason(finishReason string) string {
+	switch finishReason {
+	case "length":
+		return "max_tokens"
+	case "tool_calls", "function_call":
+		return "tool_use"
+	case "content_filter":
+		return "refusal"
+	default:
+		return "end_turn"
+	}
+}
+
+func messagesUsageFromService(u service.Usage) messagesUsage {
+	return messagesUsage{
+		InputTokens:              u.PromptTokens,
+		OutputTokens:             u.CompletionTokens,
+		CacheCreationInputTokens: u.CacheWriteTokens,
+		CacheReadInputTokens:     u.CacheReadTokens,
+	}
+}
+
+// messagesTargetErrorStatus picks the HTTP status for a model that failed
+// chain validation, matching ChatCompletions.
+func messagesTargetErrorStatus(err error) int {
+	switch {
+	case strings.Contains(err.Error(), "not have access"):
+		return http.StatusForbidden
+	case strings.Contains(err.Error(), "not found"), strings.Contains(err.Error(), "not available"):
+		return http.StatusNotFound
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+// writeMessagesGatewayError converts an upstream failure into the
+// Anthropic error envelope, keeping the status and Retry-After that
+// classifyGatewayError picked.
+func writeMessagesGatewayError(w http.ResponseWriter, err error) {
+	status, body := classifyGatewayError(err)
+	addGatewayRateLimitHeaders(w, err)
+	msg := err.Error()
+	if e, ok := body["error"].(map[string]any); ok {
+		if m, ok := e["message"].(string); ok {
+			msg = m
+		}
+	}
+	writeMessagesError(w, status, msg)
+}
+
+// writeMessagesError writes an Anthropic-style error envelope.
+func writeMessagesError(w http.ResponseWriter, status int, msg string) {
+	httpResponseJSON(w, map[string]any{
+		"type": "error",
+		"error": map[string]any{
+			"type":    messagesErrorType(status),
+			"message": msg,
+		},
+	}, status)
+}
+
+func messagesErrorType(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return "invalid_request_error"
+	case http.StatusUnauthorized:
+		return "authentication_error"
+	case http.StatusForbidden:
+		return "permission_error"
+	case http.StatusNotFound:
+		return "not_found_error"
+	case http.StatusRequestEntityTooLarge:
+		return "request_too_large"
+	case http.StatusTooManyRequests:
+		return "rate_limit_error"
+	case 529:
+		return "overloaded_error"
+	default:
+		return "api_error"
+	}
+}
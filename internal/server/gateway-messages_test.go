@@ -0,0 +1,296 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rakunlabs/at/internal/service"
+)
+
+// messagesCaptureProvider records the translated request and answers with
+// a fixed response; ChatStream replays it as chunks.
+type messagesCaptureProvider struct {
+	messages []service.Message
+	tools    []service.Tool
+	opts     *service.ChatOptions
+	resp     *service.LLMResponse
+}
+
+func (p *messagesCaptureProvider) Chat(_ context.Context, _ string, messages []service.Message, tools []service.Tool, opts *service.ChatOptions) (*service.LLMResponse, error) {
+	p.messages, p.tools, p.opts = messages, tools, opts
+	return p.resp, nil
+}
+
+func (p *messagesCaptureProvider) ChatStream(_ context.Context, _ string, messages []service.Message, tools []service.Tool, opts *service.ChatOptions) (<-chan service.StreamChunk, http.Header, error) {
+	p.messages, p.tools, p.opts = messages, tools, opts
+	ch := make(chan service.StreamChunk, 4)
+	ch <- service.StreamChunk{ReasoningContent: "thinking..."}
+	ch <- service.StreamChunk{Content: "Hel"}
+	ch <- service.StreamChunk{Content: "lo"}
+	ch <- service.StreamChunk{
+		ToolCalls:    []service.ToolCall{{ID: "call_1", Name: "lookup", Arguments: map[string]any{"q": "go"}}},
+		FinishReason: "tool_calls",
+		Usage:        &service.Usage{PromptTokens: 10, CompletionTokens: 5},
+	}
+	close(ch)
+	return ch, nil, nil
+}
+
+func (p *messagesCaptureProvider) Proxy(http.ResponseWriter, *http.Request, string) error {
+	return nil
+}
+
+func newMessagesTestServer(provider service.LLMProvider, providerType string) *Server {
+	return &Server{
+		providers: map[string]ProviderInfo{
+			"gemini": {provider: provider, providerType: providerType},
+		},
+		tokenStore: gatewayTestToken("test-token", service.APIToken{
+			ID:                   "tok-1",
+			AllowedProvidersMode: service.AccessModeAll,
+			AllowedModelsMode:    service.AccessModeAll,
+		}),
+	}
+}
+
+func doMessages(s *Server, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set("x-api-key", "test-token")
+	req.Header.Set("anthropic-version", "2023-06-01")
+	rec := httptest.NewRecorder()
+	if strings.HasSuffix(path, "/count_tokens") {
+		s.MessagesCountTokens(rec, req)
+	} else {
+		s.Messages(rec, req)
+	}
+	return rec
+}
+
+func TestMessagesToOpenAIMessages(t *testing.T) {
+	system := json.RawMessage(`[{"type":"text","text":"be brief"}]`)
+	var msgs []messagesInput
+	if err := json.Unmarshal([]byte(`[
+		{"role":"user","content":[{"type":"text","text":"what is this?"},{"type":"image","source":{"type":"base64","media_type":"image/png","data":"AAAA"}}]},
+		{"role":"assistant","content":[{"type":"thinking","thinking":"hmm","signature":"sig"},{"type":"text","text":"let me look"},{"type":"tool_use","id":"toolu_1","name":"lookup","input":{"q":"go"}}]},
+		{"role":"user","content":[{"type":"tool_result","tool_use_id":"toolu_1","content":[{"type":"text","text":"found"}]},{"type":"text","text":"thanks"}]}
+	]`), &msgs); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := messagesToOpenAIMessages(system, msgs)
+	if err != nil {
+		t.Fatalf("messagesToOpenAIMessages: %v", err)
+	}
+	roles := make([]string, len(out))
+	for i, m := range out {
+		roles[i] = m.Role
+	}
+	if got := strings.Join(roles, ","); got != "system,user,assistant,tool,user" {
+		t.Fatalf("roles = %s", got)
+	}
+	if !strings.Contains(string(out[1].Content), `"url":"data:image/png;base64,AAAA"`) {
+		t.Errorf("image part = %s", out[1].Content)
+	}
+	if extractContentString(out[2].Content) != "let me look" || len(out[2].ToolCalls) != 1 ||
+		out[2].ToolCalls[0].ID != "toolu_1" || out[2].ToolCalls[0].Function.Arguments != `{"q":"go"}` {
+		t.Errorf("assistant = %+v", out[2])
+	}
+	if out[3].ToolCallID != "toolu_1" || extractContentString(out[3].Content) != "found" {
+		t.Errorf("tool result = %+v", out[3])
+	}
+}
+
+func TestMessagesRoutesToNonAnthropicProvider(t *testing.T) {
+	provider := &messagesCaptureProvider{resp: &service.LLMResponse{
+		Content:          "hello",
+		ReasoningContent: "pondering",
+		ToolCalls:        []service.ToolCall{{ID: "call_1", Name: "lookup", Arguments: map[string]any{"q": "go"}}},
+		FinishReason:     "tool_calls",
+		Usage:            service.Usage{PromptTokens: 12, CompletionTokens: 4, CacheReadTokens: 3},
+	}}
+	s := newMessagesTestServer(provider, "gemini")
+
+	rec := doMessages(s, "/gateway/v1/messages", `{
+		"model":"gemini/gemini-2.5-pro","max_tokens":256,
+		"system":"be brief",
+		"messages":[{"role":"user","content":"hi"}],
+		"tools":[{"name":"lookup","description":"search","input_schema":{"type":"object"}},{"type":"web_search_20250305","name":"web_search"}],
+		"tool_choice":{"type":"any","disable_parallel_tool_use":true},
+		"thinking":{"type":"enabled","budget_tokens":1024}
+	}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d; body=%s", rec.Code, rec.Body.String())
+	}
+
+	if len(provider.tools) != 1 || provider.tools[0].Name != "lookup" {
+		t.Errorf("tools = %+v", provider.tools)
+	}
+	if provider.opts == nil || *provider.opts.MaxTokens != 256 || provider.opts.ToolChoice != "required" ||
+		provider.opts.ParallelToolCalls == nil || *provider.opts.ParallelToolCalls ||
+		provider.opts.Thinking == nil || provider.opts.Thinking.BudgetTokens != 1024 {
+		t.Errorf("opts = %+v", provider.opts)
+	}
+	// OpenAI-compatible providers receive the chat.completions map shape.
+	if len(provider.messages) != 2 || provider.messages[0].Role != "system" {
+		t.Errorf("messages = %+v", provider.messages)
+	}
+
+	var resp messagesResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp.Type != "message" || resp.StopReason != "tool_use" || len(resp.Content) != 3 {
+		t.Fatalf("response = %s", rec.Body.String())
+	}
+	if resp.Content[0].Type != "thinking" || resp.Content[1].Type != "text" || resp.Content[2].Type != "tool_use" || resp.Content[2].Input["q"] != "go" {
+		t.Errorf("content = %s", rec.Body.String())
+	}
+	if resp.Usage.InputTokens != 12 || resp.Usage.OutputTokens != 4 || resp.Usage.CacheReadInputTokens != 3 {
+		t.Errorf("usage = %+v", resp.Usage)
+	}
+}
+
+func TestMessagesAnthropicProviderGetsContentBlocks(t *testing.T) {
+	provider := &messagesCaptureProvider{resp: &service.LLMResponse{Content: "ok", Finished: true}}
+	s := newMessagesTestServer(provider, "bedrock")
+
+	rec := doMessages(s, "/gateway/v1/messages", `{
+		"model":"gemini/claude","max_tokens":64,
+		"messages":[
+			{"role":"user","content":"look up go"},
+			{"role":"assistant","content":[{"type":"tool_use","id":"toolu_1","name":"lookup","input":{"q":"go"}}]},
+			{"role":"user","content":[{"type":"tool_result","tool_use_id":"toolu_1","content":"found"}]}
+		]
+	}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d; body=%s", rec.Code, rec.Body.String())
+	}
+	if len(provider.messages) != 3 {
+		t.Fatalf("messages = %+v", provider.messages)
+	}
+	blocks, ok := provider.messages[2].Content.([]service.ContentBlock)
+	if !ok || blocks[0].Type != "tool_result" || blocks[0].ToolUseID != "toolu_1" || blocks[0].Content != "found" {
+		t.Errorf("tool result = %+v", provider.messages[2])
+	}
+}
+
+func TestMessagesReplaysSignedThinkingToClaude(t *testing.T) {
+	body := `{
+		"model":"gemini/%s","max_tokens":64,
+		"thinking":{"type":"enabled","budget_tokens":1024},
+		"messages":[
+			{"role":"user","content":"look up go"},
+			{"role":"assistant","content":[
+				{"type":"thinking","thinking":"need a lookup","signature":"sig-1"},
+				{"type":"redacted_thinking","data":"opaque"},
+				{"type":"tool_use","id":"toolu_1","name":"lookup","input":{"q":"go"}}
+			]},
+			{"role":"user","content":[{"type":"tool_result","tool_use_id":"toolu_1","content":"found"}]}
+		]
+	}`
+
+	for _, tc := range []struct {
+		providerType, model string
+		keep                bool
+	}{
+		{"anthropic", "claude-sonnet-4-5", true},
+		{"bedrock", "anthropic.claude-sonnet-4-5", true},
+		{"bedrock", "meta.llama3-70b", false},
+		{"gemini", "gemini-2.5-pro", false},
+	} {
+		t.Run(tc.providerType+"/"+tc.model, func(t *testing.T) {
+			provider := &messagesCaptureProvider{resp: &service.LLMResponse{
+				Content: "done", ReasoningContent: "all good", ReasoningSignature: "sig-2", Finished: true,
+			}}
+			s := newMessagesTestServer(provider, tc.providerType)
+
+			rec := doMessages(s, "/gateway/v1/messages", fmt.Sprintf(body, tc.model))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d; body=%s", rec.Code, rec.Body.String())
+			}
+			sent, _ := json.Marshal(provider.messages)
+			if got := strings.Contains(string(sent), "sig-1"); got != tc.keep {
+				t.Fatalf("thinking forwarded = %v, want %v: %s", got, tc.keep, sent)
+			}
+			if tc.keep {
+				blocks, _ := provider.messages[1].Content.([]service.ContentBlock)
+				if len(blocks) != 3 || blocks[0].Type != "thinking" || blocks[0].Signature != "sig-1" ||
+					blocks[1].Type != "redacted_thinking" || blocks[1].Data != "opaque" || blocks[2].Type != "tool_use" {
+					t.Errorf("assistant blocks = %+v", blocks)
+				}
+			}
+			if !strings.Contains(rec.Body.String(), `"signature":"sig-2"`) {
+				t.Errorf("response thinking signature missing: %s", rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestMessagesStreamingEvents(t *testing.T) {
+	provider := &messagesCaptureProvider{}
+	s := newMessagesTestServer(provider, "gemini")
+
+	rec := doMessages(s, "/gateway/v1/messages", `{"model":"gemini/gemini-2.5-pro","max_tokens":64,"stream":true,"messages":[{"role":"user","content":"hi"}]}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d; body=%s", rec.Code, rec.Body.String())
+	}
+
+	var events []string
+	var deltas []map[string]any
+	for _, line := range strings.Split(rec.Body.String(), "\n") {
+		if ev, ok := strings.CutPrefix(line, "event: "); ok {
+			events = append(events, ev)
+		}
+		if data, ok := strings.CutPrefix(line, "data: "); ok && strings.Contains(data, `"content_block_delta"`) {
+			var d map[string]any
+			_ = json.Unmarshal([]byte(data), &d)
+			deltas = append(deltas, d["delta"].(map[string]any))
+		}
+	}
+	want := "message_start,content_block_start,content_block_delta,content_block_stop," +
+		"content_block_start,content_block_delta,content_block_delta,content_block_stop," +
+		"content_block_start,content_block_delta,content_block_stop,message_delta,message_stop"
+	if got := strings.Join(events, ","); got != want {
+		t.Fatalf("events =\n%s\nwant\n%s", got, want)
+	}
+	if deltas[0]["type"] != "thinking_delta" || deltas[1]["type"] != "text_delta" || deltas[3]["partial_json"] != `{"q":"go"}` {
+		t.Errorf("deltas = %+v", deltas)
+	}
+	if !strings.Contains(rec.Body.String(), `"stop_reason":"tool_use"`) || !strings.Contains(rec.Body.String(), `"output_tokens":5`) {
+		t.Errorf("message_delta missing stop reason/usage: %s", rec.Body.String())
+	}
+}
+
+func TestMessagesErrorsUseAnthropicEnvelope(t *testing.T) {
+	s := newMessagesTestServer(&messagesCaptureProvider{}, "gemini")
+
+	rec := doMessages(s, "/gateway/v1/messages", `{"model":"missing/model","max_tokens":1,"messages":[{"role":"user","content":"hi"}]}`)
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("status = %d", rec.Code)
+	}
+	var body map[string]any
+	_ = json.Unmarshal(rec.Body.Bytes(), &body)
+	if body["type"] != "error" || body["error"].(map[string]any)["type"] != "not_found_error" {
+		t.Errorf("body = %s", rec.Body.String())
+	}
+}
+
+func TestMessagesCountTokens(t *testing.T) {
+	s := newMessagesTestServer(&messagesCaptureProvider{}, "gemini")
+
+	rec := doMessages(s, "/gateway/v1/messages/count_tokens", `{"model":"gemini/gemini-2.5-pro","system":"be brief","messages":[{"role":"user","content":"`+strings.Repeat("word ", 100)+`"}]}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d; body=%s", rec.Code, rec.Body.String())
+	}
+	var body struct {
+		InputTokens int `json:"input_tokens"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || body.InputTokens < 100 {
+		t.Fatalf("body = %s, err=%v", rec.Body.String(), err)
+	}
+}
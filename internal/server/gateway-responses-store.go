@@ -0,0 +1,361 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+
+	"github.com/rakunlabs/at/internal/service"
+)
+
+// ─── Stored Responses (previous_response_id) ───
+//
+// Responses created with `store` (the default, as in OpenAI's API) are
+// persisted with a TTL. Each stored response keeps only the input items
+// of its own request plus a link to the response it continued from, so a
+// request with `previous_response_id` rebuilds the conversation by walking
+// that chain: every earlier turn's input items followed by its output
+// items, then the new input. Instructions are not carried over between
+// turns, matching OpenAI.
+//
+// Stored responses are scoped to the API token that created them: other
+// tokens get 404 from previous_response_id and from
+// GET/DELETE /gateway/v1/responses/{id} and .../input_items.
+
+const (
+	defaultStoredResponseTTL = 30 * 24 * time.Hour
+
+	// storedResponseJanitorInterval is how often expired responses are swept.
+	storedResponseJanitorInterval = 1 * time.Hour
+
+	// maxStoredResponseChain caps how many previous responses are walked
+	// when rebuilding a conversation.
+	maxStoredResponseChain = 500
+)
+
+var (
+	errStoredResponsesDisabled = errors.New("stored responses are not available on this gateway; include the full conversation in the input")
+	errStoredResponseNotFound  = errors.New("stored response not found")
+)
+
+// storedResponseTokenID is the owner recorded on stored responses; ""
+// for unrestricted access.
+func storedResponseTokenID(auth *authResult) string {
+	if auth == nil || auth.token == nil {
+		return ""
+	}
+	return auth.token.ID
+}
+
+// getOwnedStoredResponse loads a stored response and hides it when it
+// belongs to another token.
+func (s *Server) getOwnedStoredResponse(ctx context.Context, auth *authResult, id string) (*service.StoredResponse, error) {
+	if s.responseStore == nil {
+		return nil, errStoredResponsesDisabled
+	}
+	stored, err := s.responseStore.GetStoredResponse(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if stored == nil || stored.TokenID != storedResponseTokenID(auth) {
+		return nil, errStoredResponseNotFound
+	}
+	return stored, nil
+}
+
+// responsesInputItems normalizes the request `input` into an array of
+// input items: a bare string becomes one user message, shorthand
+// {role, content} objects get type "message", and message items without
+// an id are assigned one so they can be listed and paged.
+func responsesInputItems(raw json.RawMessage) ([]map[string]any, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return []map[string]any{{
+			"id":      "msg_" + ulid.Make().String(),
+			"type":    "message",
+			"role":    "user",
+			"content": []any{map[string]any{"type": "input_text", "text": text}},
+		}}, nil
+	}
+
+	var items []map[string]any
+	if err := json.Unmarshal(raw, &items); err != nil {
+		return nil, fmt.Errorf("input must be a string or an array of input items: %v", err)
+	}
+	for _, item := range items {
+		if t, _ := item["type"].(string); t == "" {
+			if role, _ := item["role"].(string); role == "" {
+				continue
+			}
+			item["type"] = "message"
+		}
+		if item["type"] == "message" {
+			if id, _ := item["id"].(string); id == "" {
+				item["id"] = "msg_" + ulid.Make().String()
+			}
+		}
+	}
+	return items, nil
+}
+
+// storedResponseHistory rebuilds the conversation that ends with the
+// response previousID: input and output items of every turn, oldest
+// first. A chain broken by an expired or deleted earlier turn is cut
+// there; an unknown previousID is an error.
+func (s *Server) storedResponseHistory(ctx context.Context, auth *authResult, previousID string) ([]map[string]any, error) {
+	var chain []*service.StoredResponse
+	for id := previousID; id != "" && len(chain) < maxStoredResponseChain; {
+		stored, err := s.getOwnedStoredResponse(ctx, auth, id)
+		if errors.Is(err, errStoredResponseNotFound) && len(chain) > 0 {
+			slog.Warn("responses: conversation chain truncated", "missing_response_id", id, "previous_response_id", previousID)
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, stored)
+		id = stored.PreviousResponseID
+	}
+
+	var items []map[string]any
+	for i := len(chain) - 1; i >= 0; i-- {
+		var input []map[string]any
+		if err := json.Unmarshal(chain[i].InputItems, &input); err != nil {
+			return nil, fmt.Errorf("decode stored input items of %s: %w", chain[i].ID, err)
+		}
+		var body struct {
+			Output []map[string]any `json:"output"`
+		}
+		if err := json.Unmarshal(chain[i].Body, &body); err != nil {
+			return nil, fmt.Errorf("decode stored response %s: %w", chain[i].ID, err)
+		}
+		items = append(items, input...)
+		items = append(items, body.Output...)
+	}
+	return items, nil
+}
+
+// storeResponse persists a completed response unless the request opted
+// out with `store: false`. Failures are logged; the client already has
+// its response.
+func (s *Server) storeResponse(ctx context.Context, auth *authResult, req *responsesRequest, inputItems []map[string]any, resp *responsesResponse) {
+	if s.responseStore == nil || (req.Store != nil && !*req.Store) {
+		return
+	}
+	if inputItems == nil {
+		inputItems = []map[string]any{}
+	}
+	input, err := json.Marshal(inputItems)
+	if err != nil {
+		slog.Warn("responses: encode input items failed", "error", err.Error())
+		return
+	}
+	body, err := json.Marshal(resp)
+	if err != nil {
+		slog.Warn("responses: encode response failed", "error", err.Error())
+		return
+	}
+
+	ttl := s.storedResponseTTL
+	if ttl <= 0 {
+		ttl = defaultStoredResponseTTL
+	}
+	now := time.Now()
+	if err := s.responseStore.PutStoredResponse(ctx, service.StoredResponse{
+		ID:                 resp.ID,
+		TokenID:            storedResponseTokenID(auth),
+		PreviousResponseID: req.PreviousResponseID,
+		Model:              resp.Model,
+		InputItems:         input,
+		Body:               body,
+		CreatedAt:          now,
+		ExpiresAt:          now.Add(ttl),
+	}); err != nil {
+		slog.Warn("responses: store failed", "response_id", resp.ID, "error", err.Error())
+	}
+}
+
+// startStoredResponseJanitor periodically drops expired stored responses.
+func (s *Server) startStoredResponseJanitor(ctx context.Context) {
+	if s.responseStore == nil {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(storedResponseJanitorInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				n, err := s.responseStore.DeleteStoredResponsesBefore(ctx, time.Now())
+				if err != nil {
+					slog.Debug("stored_response_janitor: sweep failed", "error", err.Error())
+				} else if n > 0 {
+					slog.Debug("stored_response_janitor: swept responses", "removed", n)
+				}
+			}
+		}
+	}()
+}
+
+// GetResponse handles GET /gateway/v1/responses/{id}.
+func (s *Server) GetResponse(w http.ResponseWriter, r *http.Request) {
+	stored, ok := s.storedResponseRequest(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(stored.Body)
+}
+
+// DeleteResponse handles DELETE /gateway/v1/responses/{id}.
+func (s *Server) DeleteResponse(w http.ResponseWriter, r *http.Request) {
+	stored, ok := s.storedResponseRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if err := s.responseStore.DeleteStoredResponse(r.Context(), stored.ID); err != nil {
+		httpResponseJSON(w, map[string]any{
+			"error": map[string]any{
+				"message": err.Error(),
+				"type":    "server_error",
+			},
+		}, http.StatusInternalServerError)
+		return
+	}
+
+	httpResponseJSON(w, map[string]any{
+		"id":      stored.ID,
+		"object":  "response",
+		"deleted": true,
+	}, http.StatusOK)
+}
+
+// ListResponseInputItems handles GET /gateway/v1/responses/{id}/input_items
+// with OpenAI's list parameters: order (asc|desc, default desc), limit
+// (1-100, default 20) and after (item id cursor).
+func (s *Server) ListResponseInputItems(w http.ResponseWriter, r *http.Request) {
+	stored, ok := s.storedResponseRequest(w, r)
+	if !ok {
+		return
+	}
+
+	var items []map[string]any
+	if err := json.Unmarshal(stored.InputItems, &items); err != nil {
+		httpResponseJSON(w, map[string]any{
+			"error": map[string]any{
+				"message": fmt.Sprintf("decode stored input items: %v", err),
+				"type":    "server_error",
+			},
+		}, http.StatusInternalServerError)
+		return
+	}
+
+	q := r.URL.Query()
+	if q.Get("order") != "asc" {
+		for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+			items[i], items[j] = items[j], items[i]
+		}
+	}
+	if after := q.Get("after"); after != "" {
+		for i, item := range items {
+			if id, _ := item["id"].(string); id == after {
+				items = items[i+1:]
+				break
+			}
+		}
+	}
+	limit := 20
+	if v, err := strconv.Atoi(q.Get("limit")); err == nil && v > 0 {
+		limit = min(v, 100)
+	}
+	hasMore := len(items) > limit
+	if hasMore {
+		items = items[:limit]
+	}
+
+	list := map[string]any{
+		"object":   "list",
+		"data":     items,
+		"has_more": hasMore,
+		"first_id": nil,
+		"last_id":  nil,
+	}
+	if len(items) > 0 {
+		list["first_id"] = items[0]["id"]
+		list["last_id"] = items[len(items)-1]["id"]
+	} else {
+		list["data"] = []any{}
+	}
+	httpResponseJSON(w, list, http.StatusOK)
+}
+
+// storedResponseRequest authenticates a retrieval request and loads the
+// token's stored response named by the {id} path value, writing the error
+// response itself when that fails.
+func (s *Server) storedResponseRequest(w http.ResponseWriter, r *http.Request) (*service.StoredResponse, bool) {
+	auth, authErr := s.authenticateRequest(r)
+	if authErr != "" {
+		httpResponseJSON(w, map[string]any{
+			"error": map[string]any{
+				"message": authErr,
+				"type":    "invalid_request_error",
+				"code":    "invalid_api_key",
+			},
+		}, http.StatusUnauthorized)
+		return nil, false
+	}
+
+	id := r.PathValue("id")
+	stored, err := s.getOwnedStoredResponse(r.Context(), auth, id)
+	if err != nil {
+		writeStoredResponseError(w, id, "", err)
+		return nil, false
+	}
+	return stored, true
+}
+
+// writeStoredResponseError maps stored-response lookup failures onto
+// OpenAI error responses.
+func writeStoredResponseError(w http.ResponseWriter, id, param string, err error) {
+	errBody := map[string]any{
+		"message": err.Error(),
+		"type":    "invalid_request_error",
+	}
+	if param != "" {
+		errBody["param"] = param
+	}
+
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, errStoredResponseNotFound):
+		status = http.StatusNotFound
+		errBody["message"] = fmt.Sprintf("Response with id '%s' not found.", id)
+		if param == "previous_response_id" {
+			status = http.StatusBadRequest
+			errBody["message"] = fmt.Sprintf("Previous response with id '%s' not found.", id)
+			errBody["code"] = "previous_response_not_found"
+		}
+	case errors.Is(err, errStoredResponsesDisabled):
+		status = http.StatusBadRequest
+	default:
+		errBody["type"] = "server_error"
+	}
+	httpResponseJSON(w, map[string]any{"error": errBody}, status)
+}
@@ -0,0 +1,191 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rakunlabs/at/internal/service"
+)
+
+// memoryStoredResponses is an in-memory StoredResponseStorer for tests.
+type memoryStoredResponses struct {
+	mu    sync.Mutex
+	items map[string]service.StoredResponse
+}
+
+func (m *memoryStoredResponses) GetStoredResponse(_ context.Context, id string) (*service.StoredResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r, ok := m.items[id]
+	if !ok || time.Now().After(r.ExpiresAt) {
+		return nil, nil
+	}
+	return &r, nil
+}
+
+func (m *memoryStoredResponses) PutStoredResponse(_ context.Context, resp service.StoredResponse) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.items == nil {
+		m.items = map[string]service.StoredResponse{}
+	}
+	m.items[resp.ID] = resp
+	return nil
+}
+
+func (m *memoryStoredResponses) DeleteStoredResponse(_ context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.items, id)
+	return nil
+}
+
+func (m *memoryStoredResponses) DeleteStoredResponsesBefore(context.Context, time.Time) (int64, error) {
+	return 0, nil
+}
+
+func doResponsesRequest(s *Server, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer test-token")
+	id, _, _ := strings.Cut(strings.TrimPrefix(req.URL.Path, "/gateway/v1/responses/"), "/")
+	req.SetPathValue("id", id)
+	rec := httptest.NewRecorder()
+	switch {
+	case method == http.MethodPost:
+		s.Responses(rec, req)
+	case method == http.MethodDelete:
+		s.DeleteResponse(rec, req)
+	case strings.HasSuffix(req.URL.Path, "/input_items"):
+		s.ListResponseInputItems(rec, req)
+	default:
+		s.GetResponse(rec, req)
+	}
+	return rec
+}
+
+func TestResponsesPreviousResponseIDRebuildsConversation(t *testing.T) {
+	provider := &messagesCaptureProvider{resp: &service.LLMResponse{Content: "hello there", Finished: true}}
+	s := newMessagesTestServer(provider, "openai")
+	s.responseStore = &memoryStoredResponses{}
+
+	rec := doResponsesRequest(s, http.MethodPost, "/gateway/v1/responses", `{"model":"gemini/gpt-4o","instructions":"be brief","input":"hi"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("turn 1 status = %d; body=%s", rec.Code, rec.Body.String())
+	}
+	var first responsesResponse
+	_ = json.Unmarshal(rec.Body.Bytes(), &first)
+
+	rec = doResponsesRequest(s, http.MethodPost, "/gateway/v1/responses",
+		`{"model":"gemini/gpt-4o","previous_response_id":"`+first.ID+`","input":[{"role":"user","content":"and again?"}]}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("turn 2 status = %d; body=%s", rec.Code, rec.Body.String())
+	}
+	var second responsesResponse
+	_ = json.Unmarshal(rec.Body.Bytes(), &second)
+	if second.PreviousResponseID != first.ID {
+		t.Errorf("previous_response_id = %q", second.PreviousResponseID)
+	}
+
+	// Instructions are not carried over: user, assistant, user.
+	roles := make([]string, len(provider.messages))
+	for i, m := range provider.messages {
+		roles[i] = m.Role
+	}
+	if got := strings.Join(roles, ","); got != "user,assistant,user" {
+		t.Fatalf("roles = %s; messages=%+v", got, provider.messages)
+	}
+	if !strings.Contains(fmt.Sprint(provider.messages[1].Content), "hello there") {
+		t.Errorf("assistant turn = %+v", provider.messages[1])
+	}
+
+	// Turn 3 chains through both stored responses.
+	rec = doResponsesRequest(s, http.MethodPost, "/gateway/v1/responses",
+		`{"model":"gemini/gpt-4o","previous_response_id":"`+second.ID+`","input":"third"}`)
+	if rec.Code != http.StatusOK || len(provider.messages) != 5 {
+		t.Fatalf("turn 3 status = %d, messages = %d", rec.Code, len(provider.messages))
+	}
+
+	rec = doResponsesRequest(s, http.MethodPost, "/gateway/v1/responses", `{"model":"gemini/gpt-4o","previous_response_id":"resp_missing","input":"x"}`)
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "previous_response_not_found") {
+		t.Errorf("missing previous: status = %d; body=%s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestResponsesStoreFalseAndStreamingStore(t *testing.T) {
+	store := &memoryStoredResponses{}
+	s := newMessagesTestServer(&messagesCaptureProvider{resp: &service.LLMResponse{Content: "ok"}}, "openai")
+	s.responseStore = store
+
+	rec := doResponsesRequest(s, http.MethodPost, "/gateway/v1/responses", `{"model":"gemini/gpt-4o","input":"hi","store":false}`)
+	if rec.Code != http.StatusOK || len(store.items) != 0 {
+		t.Fatalf("store:false status = %d, stored = %d", rec.Code, len(store.items))
+	}
+
+	rec = doResponsesRequest(s, http.MethodPost, "/gateway/v1/responses", `{"model":"gemini/gpt-4o","input":"hi","stream":true}`)
+	if rec.Code != http.StatusOK || len(store.items) != 1 {
+		t.Fatalf("stream status = %d, stored = %d", rec.Code, len(store.items))
+	}
+	for _, stored := range store.items {
+		var body responsesResponse
+		if err := json.Unmarshal(stored.Body, &body); err != nil || body.Status != "completed" || len(body.Output) != 3 {
+			t.Errorf("stored streamed response = %s", stored.Body)
+		}
+		if !strings.Contains(rec.Body.String(), stored.ID) {
+			t.Errorf("stored id %s not in stream", stored.ID)
+		}
+	}
+}
+
+func TestStoredResponseRetrievalEndpoints(t *testing.T) {
+	s := newMessagesTestServer(&messagesCaptureProvider{resp: &service.LLMResponse{Content: "ok"}}, "openai")
+	store := &memoryStoredResponses{}
+	s.responseStore = store
+
+	rec := doResponsesRequest(s, http.MethodPost, "/gateway/v1/responses",
+		`{"model":"gemini/gpt-4o","input":[{"role":"user","content":"one"},{"role":"user","content":"two"},{"role":"user","content":"three"}]}`)
+	var created responsesResponse
+	_ = json.Unmarshal(rec.Body.Bytes(), &created)
+
+	rec = doResponsesRequest(s, http.MethodGet, "/gateway/v1/responses/"+created.ID, "")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), created.ID) {
+		t.Fatalf("get status = %d; body=%s", rec.Code, rec.Body.String())
+	}
+
+	rec = doResponsesRequest(s, http.MethodGet, "/gateway/v1/responses/"+created.ID+"/input_items?order=asc&limit=2", "")
+	var list struct {
+		Data    []map[string]any `json:"data"`
+		HasMore bool             `json:"has_more"`
+		LastID  string           `json:"last_id"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil || len(list.Data) != 2 || !list.HasMore || list.Data[0]["content"] != "one" {
+		t.Fatalf("input_items = %s", rec.Body.String())
+	}
+	rec = doResponsesRequest(s, http.MethodGet, "/gateway/v1/responses/"+created.ID+"/input_items?order=asc&after="+list.LastID, "")
+	_ = json.Unmarshal(rec.Body.Bytes(), &list)
+	if len(list.Data) != 1 || list.HasMore || list.Data[0]["content"] != "three" {
+		t.Fatalf("input_items page 2 = %s", rec.Body.String())
+	}
+
+	// Responses created by another token are invisible.
+	_ = store.PutStoredResponse(context.Background(), service.StoredResponse{
+		ID: "resp_other", TokenID: "tok-other", Body: []byte(`{}`), ExpiresAt: time.Now().Add(time.Hour),
+	})
+	if rec := doResponsesRequest(s, http.MethodGet, "/gateway/v1/responses/resp_other", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("other token's response status = %d", rec.Code)
+	}
+
+	rec = doResponsesRequest(s, http.MethodDelete, "/gateway/v1/responses/"+created.ID, "")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"deleted":true`) {
+		t.Fatalf("delete status = %d; body=%s", rec.Code, rec.Body.String())
+	}
+	if rec := doResponsesRequest(s, http.MethodGet, "/gateway/v1/responses/"+created.ID, ""); rec.Code != http.StatusNotFound {
+		t.Errorf("deleted response status = %d", rec.Code)
+	}
+}
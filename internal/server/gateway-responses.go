@@ -6,7 +6,9 @@ import (
 	"fmt"
 	"io"
 	"log/slog"
+	"mime"
 	"net/http"
+	"path"
 	"strings"
 	"time"
 
@@ -17,13 +19,15 @@ import (
 
 // ─── OpenAI Responses API (minimal compatibility layer) ───
 //
-// This is a best-effort, non-streaming implementation of the OpenAI
-// Responses API (POST /v1/responses). The Responses API is a superset
-// of chat.completions with new ergonomics (typed output items,
+// This is a best-effort implementation of the OpenAI Responses API
+// (POST /v1/responses). The Responses API is a superset of
+// chat.completions with new ergonomics (typed output items,
 // reasoning summaries, conversation state via `previous_response_id`).
 //
 // What we support today:
-//   - String or array-of-input-items `input` field
+//   - String or array-of-input-items `input` field, including
+//     `input_image` (URL or data URL) and `input_file` (base64 or URL)
+//     content parts
 //   - `instructions` (treated as a system message prefix)
 //   - `tools` (function tools only) and `tool_choice`
 //   - `temperature`, `top_p`, `max_output_tokens`, `parallel_tool_calls`,
@@ -31,15 +35,14 @@ import (
 //   - Non-streaming response with `output[]` items: `message` (assistant
 //     text), `reasoning` (summary), `function_call` items, and a
 //     populated `usage` object
+//   - `stream: true` — SSE events with output text, reasoning summary and
+//     function-call argument deltas (see writeResponsesStream)
+//   - `store` / `previous_response_id` and GET/DELETE of stored responses
+//     (see gateway-responses-store.go)
 //
 // What we do NOT support (yet):
-//   - Streaming (returns 400)
-//   - `previous_response_id` (conversation state — would require
-//     server-side storage)
 //   - `web_search`, `file_search`, `code_interpreter`, `computer_use`
 //     built-in tools (only `type:"function"` tools are honoured)
-//   - `store: true` semantics (responses are not persisted server-side)
-//   - Image input rendering via `input_image` items (text only here)
 //
 // Callers using the new OpenAI Responses API can therefore point their
 // SDK at this gateway and receive Responses-shaped JSON back; advanced
@@ -89,15 +92,21 @@ type responsesTextFormat struct {
 
 // responsesResponse is the OpenAI Responses API response body.
 type responsesResponse struct {
-	ID                string             `json:"id"`
-	Object            string             `json:"object"` // "response"
-	CreatedAt         int64              `json:"created_at"`
-	Status            string             `json:"status"` // "completed" | "incomplete" | "failed"
-	Model             string             `json:"model"`
-	Output            []responsesOutItem `json:"output"`
-	Usage             responsesUsage     `json:"usage"`
-	Metadata          map[string]any     `json:"metadata,omitempty"`
-	ParallelToolCalls *bool              `json:"parallel_tool_calls,omitempty"`
+	ID                 string                      `json:"id"`
+	Object             string                      `json:"object"` // "response"
+	CreatedAt          int64                       `json:"created_at"`
+	Status             string                      `json:"status"` // "completed" | "incomplete" | "failed"
+	IncompleteDetails  *responsesIncompleteDetails `json:"incomplete_details,omitempty"`
+	Model              string                      `json:"model"`
+	PreviousResponseID string                      `json:"previous_response_id,omitempty"`
+	Output             []responsesOutItem          `json:"output"`
+	Usage              responsesUsage              `json:"usage"`
+	Metadata           map[string]any              `json:"metadata,omitempty"`
+	ParallelToolCalls  *bool                       `json:"parallel_tool_calls,omitempty"`
+}
+
+type responsesIncompleteDetails struct {
+	Reason string `json:"reason"` // "max_output_tokens" | "content_filter"
 }
 
 // responsesOutItem is a single element of the response.output[] array.
@@ -176,17 +185,6 @@ func (s *Server) Responses(w http.ResponseWriter, r *http.Request) {
 	}
 	traceID, sessionID := auditTraceInfo(r)
 
-	if req.PreviousResponseID != "" {
-		httpResponseJSON(w, map[string]any{
-			"error": map[string]any{
-				"message": "previous_response_id is not supported by this gateway; include the full conversation in the input",
-				"type":    "invalid_request_error",
-				"param":   "previous_response_id",
-			},
-		}, http.StatusBadRequest)
-		return
-	}
-
 	// Mock-response short-circuit.
 	if req.MockResponse != "" {
 		mockResp := buildMockResponsesResponse(req.Model, req.Metadata, req.ParallelToolCalls, req.MockResponse)
@@ -220,6 +218,12 @@ func (s *Server) Responses(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := s.rejectGatewayHooks(r.Context(), auth, req.Model); err != nil {
+		status, body := classifyGatewayError(err)
+		httpResponseJSON(w, body, status)
+		return
+	}
+
 	if limitMessage, resetErr := s.checkTokenLimits(r.Context(), auth); resetErr != nil {
 		slog.Error("token limit check failed", "error", resetErr)
 	} else if limitMessage != "" {
@@ -246,8 +250,35 @@ func (s *Server) Responses(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Normalise this turn's input items (stored with the response) and
+	// prepend the conversation rebuilt from previous_response_id.
+	inputItems, err := responsesInputItems(req.Input)
+	if err != nil {
+		httpResponseJSON(w, map[string]any{
+			"error": map[string]any{
+				"message": err.Error(),
+				"type":    "invalid_request_error",
+				"param":   "input",
+			},
+		}, http.StatusBadRequest)
+		return
+	}
+	conversation := inputItems
+	if req.PreviousResponseID != "" {
+		history, hErr := s.storedResponseHistory(r.Context(), auth, req.PreviousResponseID)
+		if hErr != nil {
+			writeStoredResponseError(w, req.PreviousResponseID, "previous_response_id", hErr)
+			return
+		}
+		conversation = append(history, inputItems...)
+	}
+	var conversationRaw json.RawMessage
+	if len(conversation) > 0 {
+		conversationRaw, _ = json.Marshal(conversation)
+	}
+
 	// Translate Responses input → chat.completions messages.
-	chatMsgs, err := responsesInputToOpenAIMessages(req.Input, req.Instructions)
+	chatMsgs, err := responsesInputToOpenAIMessages(conversationRaw, req.Instructions)
 	if err != nil {
 		httpResponseJSON(w, map[string]any{
 			"error": map[string]any{
@@ -269,8 +300,41 @@ func (s *Server) Responses(w http.ResponseWriter, r *http.Request) {
 
 	if req.Stream {
 		// Streaming: no fallback (same constraint as chat streaming).
+		// Providers without ChatStream are fake-streamed from one Chat call.
+		target := chatCallTarget{fullModel: req.Model, providerKey: providerKey, actualModel: actualModel, info: info}
+		s.tokenGuardrails(auth, &target, "responses")
 		sMessages, _ := s.buildProviderMessages(info.providerType, chatMsgs, nil)
-		s.handleStreamingResponses(w, r.WithContext(callCtx), auth, info, providerKey, actualModel, req.Model, req.Metadata, req.ParallelToolCalls, sMessages, tools, cloneChatOptions(baseOpts))
+		opts := cloneChatOptions(baseOpts)
+		if err := s.adaptToModelCapabilities(callCtx, &target, sMessages, tools, opts, true); err != nil {
+			status, body := classifyGatewayError(err)
+			httpResponseJSON(w, body, status)
+			return
+		}
+		audit := streamAuditCtx{
+			auth: auth, source: "responses", endpoint: r.URL.Path,
+			traceID: traceID, sessionID: sessionID, userField: req.User,
+			requestBody: rawBody, requestedModel: req.Model,
+		}
+		s.relayChatStream(w, r.WithContext(callCtx), auth, target, sMessages, tools, opts, audit,
+			func(w http.ResponseWriter, err error) {
+				status, body := classifyGatewayError(err)
+				addGatewayRateLimitHeaders(w, err)
+				httpResponseJSON(w, body, status)
+			},
+			func(chunks <-chan service.StreamChunk) streamRelayResult {
+				resp := &responsesResponse{
+					ID:                 "resp_" + ulid.Make().String(),
+					Object:             "response",
+					CreatedAt:          time.Now().Unix(),
+					Model:              req.Model,
+					PreviousResponseID: req.PreviousResponseID,
+					Metadata:           req.Metadata,
+					ParallelToolCalls:  req.ParallelToolCalls,
+				}
+				return s.writeResponsesStream(w, resp, chunks, func(done *responsesResponse) {
+					s.storeResponse(r.Context(), auth, &req, inputItems, done)
+				})
+			})
 		return
 	}
 
@@ -293,6 +357,9 @@ func (s *Server) Responses(w http.ResponseWriter, r *http.Request) {
 			fullModel: m, providerKey: pKey, actualModel: actual, info: fInfo,
 		})
 	}
+	for i := range chain {
+		s.tokenGuardrails(auth, &chain[i], "responses")
+	}
 
 	var (
 		lastErr      error
@@ -305,6 +372,10 @@ func (s *Server) Responses(w http.ResponseWriter, r *http.Request) {
 		// can change (e.g. fallback from openai → anthropic).
 		tMessages, _ := s.buildProviderMessages(target.info.providerType, chatMsgs, nil)
 		opts := cloneChatOptions(baseOpts)
+		if err := s.adaptToModelCapabilities(callCtx, &target, tMessages, tools, opts, false); err != nil {
+			lastErr = err
+			continue
+		}
 
 		callStart := time.Now()
 		r2, err := callWithGatewayRetry(callCtx, target.providerKey, target.actualModel,
@@ -346,6 +417,8 @@ func (s *Server) Responses(w http.ResponseWriter, r *http.Request) {
 	}
 	s.cacheThoughtSignatures(resp.ToolCalls)
 	out := buildResponsesResponse(used.fullModel, req.Metadata, req.ParallelToolCalls, resp)
+	out.PreviousResponseID = req.PreviousResponseID
+	s.storeResponse(r.Context(), auth, &req, inputItems, out)
 	s.recordUsageAsync(r.Context(), auth, used.fullModel, resp.Usage, totalLatency, "ok", "", "")
 	if respBody, mErr := json.Marshal(out); mErr == nil {
 		s.recordLLMCallAsync(r.Context(), llmAuditParams{
@@ -360,33 +433,32 @@ func (s *Server) Responses(w http.ResponseWriter, r *http.Request) {
 	httpResponseJSON(w, out, http.StatusOK)
 }
 
-// handleStreamingResponses emits OpenAI Responses-API SSE events derived
-// from a single Chat or ChatStream upstream call.
+// writeResponsesStream relays chunks as OpenAI Responses-API SSE events.
+// Each kind of delta becomes its own output item, opened when the delta
+// kind changes and closed before the next item starts:
+//   - reasoning: response.reasoning_summary_part.added,
+//     response.reasoning_summary_text.delta / .done, …part.done
+//   - message: response.content_part.added, response.output_text.delta /
+//     .done, response.content_part.done
+//   - function_call: response.function_call_arguments.delta / .done
 //
-// Event types emitted (subset of OpenAI's Responses streaming protocol):
-//   - response.created
-//   - response.output_item.added (message item)
-//   - response.output_text.delta (per content chunk)
-//   - response.output_text.done
-//   - response.output_item.added (function_call item) — for tool calls
-//   - response.function_call_arguments.delta
-//   - response.output_item.done
-//   - response.completed
-//
-// We do NOT support reasoning streaming (reasoning items are emitted as
-// completed items at the end if reasoning content was returned).
-func (s *Server) handleStreamingResponses(
+// every item framed by response.output_item.added / .done, and the whole
+// stream framed by response.created / response.in_progress and
+// response.completed (or response.incomplete / response.failed) carrying
+// the assembled response with usage. onComplete runs on the final
+// response just before that last event, so a stored response is visible
+// as soon as the client sees the stream end.
+func (s *Server) writeResponsesStream(
 	w http.ResponseWriter,
-	r *http.Request,
-	auth *authResult,
-	info ProviderInfo,
-	providerKey, actualModel, fullModel string,
-	metadata map[string]any,
-	parallelToolCalls *bool,
-	messages []service.Message,
-	tools []service.Tool,
-	opts *service.ChatOptions,
-) {
+	resp *responsesResponse,
+	chunks <-chan service.StreamChunk,
+	onComplete func(*responsesResponse),
+) streamRelayResult {
+	model := resp.Model
+	resp.Status = "in_progress"
+	resp.Output = []responsesOutItem{}
+	res := streamRelayResult{id: resp.ID}
+
 	flusher, ok := w.(http.Flusher)
 	if !ok {
 		httpResponseJSON(w, map[string]any{
@@ -395,314 +467,198 @@ func (s *Server) handleStreamingResponses(
 				"type":    "server_error",
 			},
 		}, http.StatusInternalServerError)
-		return
+		res.err = fmt.Errorf("streaming not supported")
+		return res
 	}
 	w.Header().Set("Content-Type", "text/event-stream")
 	w.Header().Set("Cache-Control", "no-cache")
 	w.Header().Set("Connection", "keep-alive")
 	w.Header().Set("X-Accel-Buffering", "no")
 
-	respID := "resp_" + generateChatID()
-	createdAt := time.Now().Unix()
-
-	emit := func(eventType string, data any) {
+	seq := 0
+	emit := func(eventType string, data map[string]any) {
+		data["type"] = eventType
+		data["sequence_number"] = seq
+		seq++
 		buf, _ := json.Marshal(data)
 		fmt.Fprintf(w, "event: %s\ndata: %s\n\n", eventType, buf)
 		flusher.Flush()
 	}
 
-	// 1. response.created
-	emit("response.created", map[string]any{
-		"type": "response.created",
-		"response": map[string]any{
-			"id":                  respID,
-			"object":              "response",
-			"created_at":          createdAt,
-			"status":              "in_progress",
-			"model":               fullModel,
-			"output":              []any{},
-			"metadata":            metadata,
-			"parallel_tool_calls": parallelToolCalls,
-		},
-	})
+	emit("response.created", map[string]any{"response": resp})
+	emit("response.in_progress", map[string]any{"response": resp})
 
-	// Try true streaming first.
-	callStart := time.Now()
 	var (
-		usage           *service.Usage
-		messageItemID   = "msg_" + generateChatID()
-		messageStarted  bool
-		fullText        strings.Builder
-		reasoningText   strings.Builder
-		toolCallItems   = make(map[string]*responsesOutItem) // by tool_call ID
-		toolCallOrder   []string
-		reasoningByCall = make(map[string]responsesOutItem)
+		openKind  string // "" | "reasoning" | "message"
+		openIdx   int
+		openText  strings.Builder
+		content   strings.Builder
+		reasoning strings.Builder
+		rawFinish string
 	)
 
-	emitMessageStart := func() {
-		if messageStarted {
+	openItem := func(item responsesOutItem) {
+		resp.Output = append(resp.Output, item)
+		openIdx = len(resp.Output) - 1
+		emit("response.output_item.added", map[string]any{"output_index": openIdx, "item": item})
+	}
+	closeItem := func() {
+		if openKind == "" {
 			return
 		}
-		emit("response.output_item.added", map[string]any{
-			"type":         "response.output_item.added",
-			"output_index": 0,
-			"item": map[string]any{
-				"id":      messageItemID,
-				"type":    "message",
-				"status":  "in_progress",
-				"role":    "assistant",
-				"content": []any{},
-			},
-		})
-		messageStarted = true
+		item := &resp.Output[openIdx]
+		text := openText.String()
+		switch openKind {
+		case "reasoning":
+			part := responsesReasoningSummary{Type: "summary_text", Text: text}
+			item.Summary = []responsesReasoningSummary{part}
+			emit("response.reasoning_summary_text.done", map[string]any{
+				"item_id": item.ID, "output_index": openIdx, "summary_index": 0, "text": text,
+			})
+			emit("response.reasoning_summary_part.done", map[string]any{
+				"item_id": item.ID, "output_index": openIdx, "summary_index": 0, "part": part,
+			})
+		case "message":
+			part := responsesOutContent{Type: "output_text", Text: text, Annotations: []responsesAnnotation{}}
+			item.Content = []responsesOutContent{part}
+			emit("response.output_text.done", map[string]any{
+				"item_id": item.ID, "output_index": openIdx, "content_index": 0, "text": text,
+			})
+			emit("response.content_part.done", map[string]any{
+				"item_id": item.ID, "output_index": openIdx, "content_index": 0, "part": part,
+			})
+		}
+		item.Status = "completed"
+		emit("response.output_item.done", map[string]any{"output_index": openIdx, "item": *item})
+		openKind = ""
+		openText.Reset()
 	}
-
-	emitDelta := func(text string) {
-		if text == "" {
+	startItem := func(kind string) {
+		if openKind == kind {
 			return
 		}
-		emitMessageStart()
-		fullText.WriteString(text)
-		emit("response.output_text.delta", map[string]any{
-			"type":          "response.output_text.delta",
-			"item_id":       messageItemID,
-			"output_index":  0,
-			"content_index": 0,
-			"delta":         text,
+		closeItem()
+		openKind = kind
+		if kind == "reasoning" {
+			item := responsesOutItem{ID: "rs_" + ulid.Make().String(), Type: "reasoning", Summary: []responsesReasoningSummary{}}
+			openItem(item)
+			emit("response.reasoning_summary_part.added", map[string]any{
+				"item_id": item.ID, "output_index": openIdx, "summary_index": 0,
+				"part": responsesReasoningSummary{Type: "summary_text", Text: ""},
+			})
+			return
+		}
+		item := responsesOutItem{
+			ID: "msg_" + ulid.Make().String(), Type: "message", Status: "in_progress",
+			Role: "assistant", Content: []responsesOutContent{},
+		}
+		openItem(item)
+		emit("response.content_part.added", map[string]any{
+			"item_id": item.ID, "output_index": openIdx, "content_index": 0,
+			"part": responsesOutContent{Type: "output_text", Text: "", Annotations: []responsesAnnotation{}},
 		})
 	}
 
-	if sp, ok := info.provider.(service.LLMStreamProvider); ok {
-		chunks, _, err := sp.ChatStream(r.Context(), actualModel, messages, tools, opts)
-		if err != nil {
-			emit("response.failed", map[string]any{
-				"type":  "response.failed",
-				"error": map[string]any{"message": err.Error(), "type": "server_error"},
-			})
-			s.recordUsageAsync(r.Context(), auth, fullModel, service.Usage{}, time.Since(callStart).Milliseconds(), "error", classifyHTTPError(err), err.Error())
-			return
+	for chunk := range chunks {
+		if chunk.Error != nil {
+			slog.Error("responses stream chunk error", "model", model, "error", chunk.Error)
+			res.err = chunk.Error
+			break
 		}
-		for chunk := range chunks {
-			if chunk.Error != nil {
-				emit("response.failed", map[string]any{
-					"type":  "response.failed",
-					"error": map[string]any{"message": chunk.Error.Error(), "type": "server_error"},
-				})
-				return
-			}
-			if chunk.Usage != nil {
-				u := *chunk.Usage
-				usage = &u
-			}
-			emitDelta(chunk.Content)
-			if chunk.ReasoningContent != "" {
-				reasoningText.WriteString(chunk.ReasoningContent)
-			}
-			for _, tc := range chunk.ToolCalls {
-				s.cacheThoughtSignatures([]service.ToolCall{tc})
-				if reasoningItem, ok := responsesReasoningItemFromSignature(tc.ThoughtSignature); ok {
-					reasoningByCall[tc.ID] = reasoningItem
-				}
-				item, exists := toolCallItems[tc.ID]
-				if !exists {
-					item = &responsesOutItem{
-						ID:     "fc_" + generateChatID(),
-						Type:   "function_call",
-						Status: "in_progress",
-						CallID: tc.ID,
-						Name:   tc.Name,
-					}
-					toolCallItems[tc.ID] = item
-					toolCallOrder = append(toolCallOrder, tc.ID)
-					emit("response.output_item.added", map[string]any{
-						"type":         "response.output_item.added",
-						"output_index": len(toolCallOrder),
-						"item": map[string]any{
-							"id":      item.ID,
-							"type":    "function_call",
-							"status":  "in_progress",
-							"call_id": tc.ID,
-							"name":    tc.Name,
-						},
-					})
-				}
-				argsJSON, _ := json.Marshal(tc.Arguments)
-				item.Arguments = string(argsJSON)
-				emit("response.function_call_arguments.delta", map[string]any{
-					"type":         "response.function_call_arguments.delta",
-					"item_id":      item.ID,
-					"output_index": indexOf(toolCallOrder, tc.ID) + 1,
-					"delta":        string(argsJSON),
-				})
-			}
+		if chunk.Usage != nil {
+			u := *chunk.Usage
+			res.usage = &u
 		}
-	} else {
-		// Provider doesn't support streaming — fall back to one Chat call
-		// and emit the whole result as a single delta.
-		resp, err := info.provider.Chat(r.Context(), actualModel, messages, tools, opts)
-		if err != nil {
-			emit("response.failed", map[string]any{
-				"type":  "response.failed",
-				"error": map[string]any{"message": err.Error(), "type": "server_error"},
+		if chunk.FinishReason != "" {
+			rawFinish = chunk.FinishReason
+		}
+
+		if chunk.ReasoningContent != "" {
+			startItem("reasoning")
+			openText.WriteString(chunk.ReasoningContent)
+			reasoning.WriteString(chunk.ReasoningContent)
+			emit("response.reasoning_summary_text.delta", map[string]any{
+				"item_id": resp.Output[openIdx].ID, "output_index": openIdx, "summary_index": 0,
+				"delta": chunk.ReasoningContent,
 			})
-			s.recordUsageAsync(r.Context(), auth, fullModel, service.Usage{}, time.Since(callStart).Milliseconds(), "error", classifyHTTPError(err), err.Error())
-			return
 		}
-		if resp.ReasoningContent != "" {
-			reasoningText.WriteString(resp.ReasoningContent)
+		if chunk.Content != "" {
+			startItem("message")
+			openText.WriteString(chunk.Content)
+			content.WriteString(chunk.Content)
+			emit("response.output_text.delta", map[string]any{
+				"item_id": resp.Output[openIdx].ID, "output_index": openIdx, "content_index": 0,
+				"delta": chunk.Content,
+			})
 		}
-		emitDelta(resp.Content)
-		for _, tc := range resp.ToolCalls {
-			if reasoningItem, ok := responsesReasoningItemFromSignature(tc.ThoughtSignature); ok {
-				reasoningByCall[tc.ID] = reasoningItem
-			}
-			item := &responsesOutItem{
-				ID:     "fc_" + generateChatID(),
-				Type:   "function_call",
-				Status: "completed",
-				CallID: tc.ID,
-				Name:   tc.Name,
+
+		for _, tc := range chunk.ToolCalls {
+			s.cacheThoughtSignatures([]service.ToolCall{tc})
+			res.toolCalls = append(res.toolCalls, tc)
+
+			// Encrypted reasoning rides on the thought signature. Attach it
+			// to the reasoning item streamed just before this call, or emit
+			// it as its own item so clients can replay it next turn.
+			if rsItem, ok := responsesReasoningItemFromSignature(tc.ThoughtSignature); ok {
+				if openKind == "reasoning" {
+					resp.Output[openIdx].EncryptedContent = rsItem.EncryptedContent
+				} else {
+					closeItem()
+					resp.Output = append(resp.Output, rsItem)
+					idx := len(resp.Output) - 1
+					emit("response.output_item.added", map[string]any{"output_index": idx, "item": rsItem})
+					emit("response.output_item.done", map[string]any{"output_index": idx, "item": rsItem})
+				}
 			}
+			closeItem()
+
 			argsJSON, _ := json.Marshal(tc.Arguments)
-			item.Arguments = string(argsJSON)
-			toolCallItems[tc.ID] = item
-			toolCallOrder = append(toolCallOrder, tc.ID)
-			emit("response.output_item.added", map[string]any{
-				"type":         "response.output_item.added",
-				"output_index": len(toolCallOrder),
-				"item": map[string]any{
-					"id":        item.ID,
-					"type":      "function_call",
-					"status":    "in_progress",
-					"call_id":   tc.ID,
-					"name":      tc.Name,
-					"arguments": "",
-				},
-			})
+			if tc.Arguments == nil {
+				argsJSON = []byte("{}")
+			}
+			item := responsesOutItem{
+				ID: "fc_" + ulid.Make().String(), Type: "function_call", Status: "in_progress",
+				CallID: tc.ID, Name: tc.Name,
+			}
+			openItem(item)
 			emit("response.function_call_arguments.delta", map[string]any{
-				"type":         "response.function_call_arguments.delta",
-				"item_id":      item.ID,
-				"output_index": len(toolCallOrder),
-				"delta":        string(argsJSON),
+				"item_id": item.ID, "output_index": openIdx, "delta": string(argsJSON),
 			})
+			emit("response.function_call_arguments.done", map[string]any{
+				"item_id": item.ID, "output_index": openIdx, "arguments": string(argsJSON),
+			})
+			item.Status = "completed"
+			item.Arguments = string(argsJSON)
+			resp.Output[openIdx] = item
+			emit("response.output_item.done", map[string]any{"output_index": openIdx, "item": item})
 		}
-		if usage == nil {
-			u := resp.Usage
-			usage = &u
-		}
-		s.cacheThoughtSignatures(resp.ToolCalls)
-	}
-
-	// Close out the message item with done event (if started).
-	if messageStarted {
-		emit("response.output_text.done", map[string]any{
-			"type":          "response.output_text.done",
-			"item_id":       messageItemID,
-			"output_index":  0,
-			"content_index": 0,
-			"text":          fullText.String(),
-		})
-		emit("response.output_item.done", map[string]any{
-			"type":         "response.output_item.done",
-			"output_index": 0,
-			"item": map[string]any{
-				"id":     messageItemID,
-				"type":   "message",
-				"status": "completed",
-				"role":   "assistant",
-				"content": []any{map[string]any{
-					"type": "output_text",
-					"text": fullText.String(),
-				}},
-			},
-		})
 	}
-
-	// Close out tool-call items.
-	for i, tcID := range toolCallOrder {
-		item := toolCallItems[tcID]
-		emit("response.output_item.done", map[string]any{
-			"type":         "response.output_item.done",
-			"output_index": i + 1,
-			"item": map[string]any{
-				"id":        item.ID,
-				"type":      "function_call",
-				"status":    "completed",
-				"call_id":   item.CallID,
-				"name":      item.Name,
-				"arguments": item.Arguments,
-			},
-		})
-	}
-
-	// Final response.completed
-	output := make([]responsesOutItem, 0, 1+len(reasoningByCall)+len(toolCallOrder))
-	if len(reasoningByCall) == 0 && reasoningText.Len() > 0 {
-		output = append(output, responsesOutItem{
-			ID:   "rs_" + generateChatID(),
-			Type: "reasoning",
-			Summary: []responsesReasoningSummary{{
-				Type: "summary_text",
-				Text: reasoningText.String(),
-			}},
-		})
-	}
-	if messageStarted {
-		text := fullText.String()
-		output = append(output, responsesOutItem{
-			ID:     messageItemID,
-			Type:   "message",
-			Status: "completed",
-			Role:   "assistant",
-			Content: []responsesOutContent{{
-				Type: "output_text",
-				Text: text,
-			}},
-		})
-	}
-	reasoningSummaryAttached := false
-	for _, tcID := range toolCallOrder {
-		if reasoningItem, ok := reasoningByCall[tcID]; ok {
-			if !reasoningSummaryAttached && reasoningText.Len() > 0 && len(reasoningItem.Summary) == 0 {
-				reasoningItem.Summary = []responsesReasoningSummary{{
-					Type: "summary_text",
-					Text: reasoningText.String(),
-				}}
-			}
-			reasoningSummaryAttached = true
-			output = append(output, reasoningItem)
-		}
-		output = append(output, *toolCallItems[tcID])
+	closeItem()
+
+	res.content, res.reasoning = content.String(), reasoning.String()
+	if res.err != nil {
+		res.finishReason = "error"
+		resp.Status = "failed"
+		emit("response.failed", map[string]any{"response": map[string]any{
+			"id": resp.ID, "object": "response", "created_at": resp.CreatedAt,
+			"status": "failed", "model": model, "output": resp.Output,
+			"error": map[string]any{"code": "server_error", "message": res.err.Error()},
+		}})
+		return res
 	}
 
-	finalResp := map[string]any{
-		"id":                  respID,
-		"object":              "response",
-		"created_at":          createdAt,
-		"status":              "completed",
-		"model":               fullModel,
-		"output":              output,
-		"metadata":            metadata,
-		"parallel_tool_calls": parallelToolCalls,
+	res.finishReason = mapStreamFinishReason(rawFinish, len(res.toolCalls) > 0)
+	resp.Status, resp.IncompleteDetails = responsesStatus(res.finishReason)
+	resp.Usage = responsesUsageFromService(usageOrZero(res.usage))
+	if onComplete != nil {
+		onComplete(resp)
 	}
-	if usage != nil {
-		finalResp["usage"] = responsesUsage{
-			InputTokens:  usage.TotalInputTokens(),
-			OutputTokens: usage.CompletionTokens,
-			TotalTokens:  usage.TotalTokenCount(),
-			InputTokensDetails: responsesInputTokensDetails{
-				CachedTokens: usage.CacheReadTokens,
-			},
-			OutputTokensDetails: responsesOutputTokensDetails{
-				ReasoningTokens: usage.ReasoningTokens,
-			},
-		}
-		s.recordUsageAsync(r.Context(), auth, fullModel, *usage, time.Since(callStart).Milliseconds(), "ok", "", "")
+	if resp.Status == "incomplete" {
+		emit("response.incomplete", map[string]any{"response": resp})
+	} else {
+		emit("response.completed", map[string]any{"response": resp})
 	}
-	emit("response.completed", map[string]any{
-		"type":     "response.completed",
-		"response": finalResp,
-	})
+	return res
 }
 
 func responsesReasoningItemFromSignature(signature string) (responsesOutItem, bool) {
@@ -719,16 +675,6 @@ func responsesReasoningItemFromSignature(signature string) (responsesOutItem, bo
 	return item, true
 }
 
-// indexOf returns the position of s in slice, or -1 if absent.
-func indexOf(slice []string, s string) int {
-	for i, v := range slice {
-		if v == s {
-			return i
-		}
-	}
-	return -1
-}
-
 // buildMockResponsesResponse mirrors buildMockChatResponse for the Responses API shape.
 func buildMockResponsesResponse(model string, metadata map[string]any, parallelToolCalls *bool, content string) *responsesResponse {
 	return &responsesResponse{
@@ -902,14 +848,25 @@ func responsesMessageItemToOpenAI(item map[string]any) (OpenAIMessage, error) {
 					}
 				}
 				if url == "" {
+					if fileID, _ := p["file_id"].(string); fileID != "" {
+						return OpenAIMessage{}, fmt.Errorf("input_image: file_id is not supported; send image_url as a URL or data URL")
+					}
 					continue
 				}
+				imageURL := map[string]any{"url": url}
+				if detail, _ := p["detail"].(string); detail != "" {
+					imageURL["detail"] = detail
+				}
 				parts = append(parts, map[string]any{
 					"type":      "image_url",
-					"image_url": map[string]any{"url": url},
+					"image_url": imageURL,
 				})
 			case "input_file":
-				parts = append(parts, p)
+				part, err := responsesInputFileToOpenAI(p)
+				if err != nil {
+					return OpenAIMessage{}, err
+				}
+				parts = append(parts, part)
 			default:
 				parts = append(parts, p)
 			}
@@ -928,6 +885,45 @@ func responsesMessageItemToOpenAI(item map[string]any) (OpenAIMessage, error) {
 	}
 }
 
+// responsesInputFileToOpenAI converts an input_file part into the
+// chat.completions file part that convertOpenAIContentToAnthropic and the
+// Gemini translator understand. file_data may be a data URL or bare
+// base64 (media type then comes from the filename, defaulting to PDF);
+// file_url is forwarded as a URL document.
+func responsesInputFileToOpenAI(p map[string]any) (map[string]any, error) {
+	filename, _ := p["filename"].(string)
+	file := map[string]any{}
+	if filename != "" {
+		file["filename"] = filename
+	}
+
+	if raw, _ := p["file_data"].(string); raw != "" {
+		mimeType, data := parseDataURL(raw)
+		if data == "" {
+			data = raw
+		}
+		if mimeType == "" {
+			mimeType = mime.TypeByExtension(strings.ToLower(path.Ext(filename)))
+			mimeType, _, _ = strings.Cut(mimeType, ";")
+		}
+		if mimeType == "" {
+			mimeType = "application/pdf"
+		}
+		file["file_data"] = map[string]any{"mime_type": mimeType, "data": data}
+		return map[string]any{"type": "file", "file": file}, nil
+	}
+
+	if url, _ := p["file_url"].(string); url != "" {
+		file["file_url"] = url
+		return map[string]any{"type": "file", "file": file}, nil
+	}
+
+	if _, ok := p["file_id"]; ok {
+		return nil, fmt.Errorf("input_file: file_id is not supported; send file_data or file_url")
+	}
+	return nil, fmt.Errorf("input_file: one of file_data or file_url is required")
+}
+
 // responsesRequestToChatOptions builds ChatOptions from the Responses API
 // request shape (different field names from chat.completions).
 func responsesRequestToChatOptions(req *responsesRequest) *service.ChatOptions {
@@ -1014,14 +1010,7 @@ func buildResponsesResponse(model string, metadata map[string]any, parallelToolC
 	}
 
 	// Status: incomplete when stopped for "length"; failed not derivable here.
-	switch normalizeFinishReason(resp) {
-	case "length":
-		out.Status = "incomplete"
-	case "content_filter":
-		out.Status = "incomplete"
-	default:
-		out.Status = "completed"
-	}
+	out.Status, out.IncompleteDetails = responsesStatus(normalizeFinishReason(resp))
 
 	// Reasoning summary (when the provider returned thinking text).
 	hasEncryptedReasoning := false
@@ -1077,19 +1066,36 @@ func buildResponsesResponse(model string, metadata map[string]any, parallelToolC
 		})
 	}
 
-	out.Usage = responsesUsage{
-		InputTokens:  resp.Usage.TotalInputTokens(),
-		OutputTokens: resp.Usage.CompletionTokens,
-		TotalTokens:  resp.Usage.TotalTokenCount(),
+	out.Usage = responsesUsageFromService(resp.Usage)
+
+	return out
+}
+
+// responsesStatus maps a normalised finish reason onto the Responses
+// status and incomplete_details pair.
+func responsesStatus(finishReason string) (string, *responsesIncompleteDetails) {
+	switch finishReason {
+	case "length":
+		return "incomplete", &responsesIncompleteDetails{Reason: "max_output_tokens"}
+	case "content_filter":
+		return "incomplete", &responsesIncompleteDetails{Reason: "content_filter"}
+	default:
+		return "completed", nil
+	}
+}
+
+func responsesUsageFromService(u service.Usage) responsesUsage {
+	return responsesUsage{
+		InputTokens:  u.TotalInputTokens(),
+		OutputTokens: u.CompletionTokens,
+		TotalTokens:  u.TotalTokenCount(),
 		InputTokensDetails: responsesInputTokensDetails{
-			CachedTokens: resp.Usage.CacheReadTokens,
+			CachedTokens: u.CacheReadTokens,
 		},
 		OutputTokensDetails: responsesOutputTokensDetails{
-			ReasoningTokens: resp.Usage.ReasoningTokens,
+			ReasoningTokens: u.ReasoningTokens,
 		},
 	}
-
-	return out
 }
 
 // mustJSONString JSON-encodes a string and returns the encoded form.
@@ -2,6 +2,9 @@ package server
 
 import (
 	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/rakunlabs/at/internal/service"
@@ -74,3 +77,148 @@ func TestResponsesRoundTripPreservesMultipleReasoningToolPairs(t *testing.T) {
 		t.Fatalf("tool calls were reordered: %#v", messages)
 	}
 }
+
+// responsesStreamEvents posts a streaming Responses request and returns
+// the SSE event names and payloads in order.
+func responsesStreamEvents(t *testing.T, s *Server, body string) ([]string, []map[string]any) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/gateway/v1/responses", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer test-token")
+	rec := httptest.NewRecorder()
+	s.Responses(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d; body=%s", rec.Code, rec.Body.String())
+	}
+
+	var (
+		names []string
+		data  []map[string]any
+	)
+	for _, line := range strings.Split(rec.Body.String(), "\n") {
+		if ev, ok := strings.CutPrefix(line, "event: "); ok {
+			names = append(names, ev)
+		}
+		if raw, ok := strings.CutPrefix(line, "data: "); ok {
+			var d map[string]any
+			if err := json.Unmarshal([]byte(raw), &d); err != nil {
+				t.Fatalf("decode %s: %v", raw, err)
+			}
+			data = append(data, d)
+		}
+	}
+	return names, data
+}
+
+func TestResponsesStreamingEvents(t *testing.T) {
+	s := newMessagesTestServer(&messagesCaptureProvider{}, "openai")
+
+	names, data := responsesStreamEvents(t, s, `{"model":"gemini/gpt-4o","input":"hi","stream":true}`)
+	want := "response.created,response.in_progress," +
+		"response.output_item.added,response.reasoning_summary_part.added,response.reasoning_summary_text.delta," +
+		"response.reasoning_summary_text.done,response.reasoning_summary_part.done,response.output_item.done," +
+		"response.output_item.added,response.content_part.added,response.output_text.delta,response.output_text.delta," +
+		"response.output_text.done,response.content_part.done,response.output_item.done," +
+		"response.output_item.added,response.function_call_arguments.delta,response.function_call_arguments.done,response.output_item.done," +
+		"response.completed"
+	if got := strings.Join(names, ","); got != want {
+		t.Fatalf("events =\n%s\nwant\n%s", got, want)
+	}
+	for i, d := range data {
+		if d["sequence_number"] != float64(i) {
+			t.Fatalf("event %d sequence_number = %v", i, d["sequence_number"])
+		}
+	}
+	if data[16]["delta"] != `{"q":"go"}` || data[16]["output_index"] != float64(2) {
+		t.Errorf("arguments delta = %+v", data[16])
+	}
+
+	final := data[len(data)-1]["response"].(map[string]any)
+	output := final["output"].([]any)
+	if final["status"] != "completed" || len(output) != 3 {
+		t.Fatalf("final response = %+v", final)
+	}
+	msg := output[1].(map[string]any)["content"].([]any)[0].(map[string]any)
+	if msg["text"] != "Hello" {
+		t.Errorf("message = %+v", msg)
+	}
+	usage := final["usage"].(map[string]any)
+	if usage["input_tokens"] != float64(10) || usage["output_tokens"] != float64(5) {
+		t.Errorf("usage = %+v", usage)
+	}
+}
+
+func TestResponsesStreamingFakeStreamsChatOnlyProvider(t *testing.T) {
+	s := newMessagesTestServer(&countingChatProvider{}, "openai")
+
+	names, data := responsesStreamEvents(t, s, `{"model":"gemini/gpt-4o","input":"hi","stream":true}`)
+	if names[0] != "response.created" || names[len(names)-1] != "response.completed" {
+		t.Fatalf("events = %v", names)
+	}
+	var text strings.Builder
+	for i, name := range names {
+		if name == "response.output_text.delta" {
+			text.WriteString(data[i]["delta"].(string))
+		}
+	}
+	if text.String() != "cached answer" {
+		t.Errorf("streamed text = %q", text.String())
+	}
+}
+
+func TestResponsesImageAndFileInputReachProviderAsContentBlocks(t *testing.T) {
+	provider := &messagesCaptureProvider{resp: &service.LLMResponse{Content: "ok"}}
+	s := newMessagesTestServer(provider, "anthropic")
+
+	rec := doResponsesRequest(s, http.MethodPost, "/gateway/v1/responses", `{"model":"gemini/claude","input":[{"role":"user","content":[
+		{"type":"input_text","text":"compare these"},
+		{"type":"input_image","image_url":"https://example.com/cat.png"},
+		{"type":"input_image","image_url":"data:image/jpeg;base64,AAAA","detail":"high"},
+		{"type":"input_file","filename":"report.pdf","file_data":"data:application/pdf;base64,JVBERi0="},
+		{"type":"input_file","file_url":"https://example.com/spec.pdf"}
+	]}]}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d; body=%s", rec.Code, rec.Body.String())
+	}
+	if len(provider.messages) != 1 {
+		t.Fatalf("messages = %+v", provider.messages)
+	}
+	blocks, ok := provider.messages[0].Content.([]service.ContentBlock)
+	if !ok || len(blocks) != 5 {
+		t.Fatalf("content = %#v", provider.messages[0].Content)
+	}
+	want := []struct{ typ, source, value string }{
+		{"image", "url", "https://example.com/cat.png"},
+		{"image", "base64", "AAAA"},
+		{"document", "base64", "JVBERi0="},
+		{"document", "url", "https://example.com/spec.pdf"},
+	}
+	for i, w := range want {
+		b := blocks[i+1]
+		if b.Type != w.typ || b.Source == nil || b.Source.Type != w.source || (b.Source.Data != w.value && b.Source.URL != w.value) {
+			t.Errorf("block %d = %+v source=%+v", i+1, b, b.Source)
+		}
+	}
+	if blocks[3].Source.MediaType != "application/pdf" {
+		t.Errorf("document media type = %q", blocks[3].Source.MediaType)
+	}
+}
+
+func TestResponsesInputFileToOpenAI(t *testing.T) {
+	part, err := responsesInputFileToOpenAI(map[string]any{"filename": "notes.txt", "file_data": "aGk="})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := part["file"].(map[string]any)["file_data"].(map[string]any)
+	if data["mime_type"] != "text/plain" || data["data"] != "aGk=" {
+		t.Errorf("file_data = %+v", data)
+	}
+
+	part, _ = responsesInputFileToOpenAI(map[string]any{"file_data": "JVBERi0="})
+	if data := part["file"].(map[string]any)["file_data"].(map[string]any); data["mime_type"] != "application/pdf" {
+		t.Errorf("default mime = %+v", data)
+	}
+
+	if _, err := responsesInputFileToOpenAI(map[string]any{"file_id": "file-abc"}); err == nil || !strings.Contains(err.Error(), "file_id") {
+		t.Errorf("file_id error = %v", err)
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"github.com/rakunlabs/at/internal/service"
+	"github.com/rakunlabs/at/internal/service/tracing"
 )
 
 // gatewayRetryAttempts is the number of upstream calls we'll attempt
@@ -101,8 +102,10 @@ func callWithGatewayRetry[T any](
 		result  T
 	)
 	for attempt := 0; attempt < gatewayRetryAttempts; attempt++ {
+		spanCtx, span := tracing.StartProviderSpan(ctx, provider, model, attempt+1)
 		var err error
-		result, err = fn(ctx)
+		result, err = fn(spanCtx)
+		tracing.EndProviderSpan(span, result, err)
 		if err == nil {
 			return result, nil
 		}
@@ -196,6 +199,49 @@ func classifyGatewayError(err error) (int, map[string]any) {
 		return status, body
 	}
 
+	var ge *guardrailError
+	if errors.As(err, &ge) {
+		param := "messages"
+		if ge.stage == "output" {
+			param = ""
+		}
+		return http.StatusBadRequest, map[string]any{
+			"error": map[string]any{
+				"message": ge.message,
+				"type":    "invalid_request_error",
+				"param":   param,
+				"code":    "guardrail_violation",
+			},
+		}
+	}
+
+	var he *gatewayHookError
+	if errors.As(err, &he) {
+		code := "gateway_hook_failed"
+		if he.unsupported {
+			code = "gateway_hook_unsupported"
+		}
+		return http.StatusBadRequest, map[string]any{
+			"error": map[string]any{
+				"message": he.Error(),
+				"type":    "invalid_request_error",
+				"code":    code,
+			},
+		}
+	}
+
+	var ce *capabilityError
+	if errors.As(err, &ce) {
+		return http.StatusBadRequest, map[string]any{
+			"error": map[string]any{
+				"message": ce.message,
+				"type":    "invalid_request_error",
+				"param":   ce.param,
+				"code":    ce.code,
+			},
+		}
+	}
+
 	if errors.Is(err, service.ErrUnsupportedOperation) {
 		return http.StatusNotImplemented, map[string]any{
 			"error": map[string]any{
@@ -0,0 +1,130 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/rakunlabs/at/internal/service"
+	"github.com/rakunlabs/at/internal/service/llm/common"
+)
+
+// ─── Token counting (POST /gateway/v1/tokenize) ───
+//
+// Counts the input tokens of a chat request without sending it. Providers
+// with a native counting API (service.TokenCountProvider: Anthropic
+// count_tokens, Gemini countTokens) are asked directly, after the token's
+// guardrail input checks; everything else, and any native call that
+// fails, uses common.CountTokensLocal. The
+// method used is reported in the body and the X-Token-Count-Method
+// header (which /v1/messages/count_tokens also sets).
+
+const (
+	tokenCountMethodNative   = "native"
+	tokenCountMethodEstimate = "estimate"
+)
+
+type tokenizeRequest struct {
+	Model    string          `json:"model"`
+	Messages []OpenAIMessage `json:"messages,omitempty"`
+	Tools    []OpenAITool    `json:"tools,omitempty"`
+
+	// Input is plain text counted as a single user message; it is
+	// appended after Messages when both are set.
+	Input string `json:"input,omitempty"`
+}
+
+// Tokenize handles POST /gateway/v1/tokenize.
+func (s *Server) Tokenize(w http.ResponseWriter, r *http.Request) {
+	auth, authErr := s.authenticateRequest(r)
+	if authErr != "" {
+		httpResponseJSON(w, map[string]any{
+			"error": map[string]any{
+				"message": authErr,
+				"type":    "invalid_request_error",
+				"code":    "invalid_api_key",
+			},
+		}, http.StatusUnauthorized)
+		return
+	}
+
+	var req tokenizeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		gatewayBadRequest(w, fmt.Sprintf("invalid request body: %v", err), "", "")
+		return
+	}
+	if req.Model == "" {
+		gatewayBadRequest(w, "model field is required", "model", "")
+		return
+	}
+	if req.Input != "" {
+		content, _ := json.Marshal(req.Input)
+		req.Messages = append(req.Messages, OpenAIMessage{Role: "user", Content: content})
+	}
+	if len(req.Messages) == 0 {
+		gatewayBadRequest(w, "messages or input is required", "messages", "")
+		return
+	}
+
+	providerKey, actualModel, info, err := s.resolveModel(auth, req.Model)
+	if err != nil {
+		httpResponseJSON(w, map[string]any{
+			"error": map[string]any{
+				"message": err.Error(),
+				"type":    "invalid_request_error",
+				"param":   "model",
+				"code":    "model_not_found",
+			},
+		}, messagesTargetErrorStatus(err))
+		return
+	}
+
+	messages, tools := s.buildProviderMessages(info.providerType, req.Messages, req.Tools)
+	n, method, err := s.countInputTokens(r.Context(), auth, providerKey, actualModel, info, messages, tools)
+	if err != nil {
+		status, body := classifyGatewayError(err)
+		httpResponseJSON(w, body, status)
+		return
+	}
+
+	w.Header().Set("X-Token-Count-Method", method)
+	httpResponseJSON(w, map[string]any{
+		"object":       "tokenize",
+		"model":        req.Model,
+		"input_tokens": n,
+		"method":       method,
+	}, http.StatusOK)
+}
+
+// countInputTokens counts with the provider's native API when it has one
+// and falls back to the local estimate otherwise. Messages only reach the
+// native API after the token's guardrail input checks; a blocked request
+// is returned as an error.
+func (s *Server) countInputTokens(
+	ctx context.Context,
+	auth *authResult,
+	providerKey, actualModel string,
+	info ProviderInfo,
+	messages []service.Message,
+	tools []service.Tool,
+) (int, string, error) {
+	if tc, ok := service.As[service.TokenCountProvider](info.provider); ok {
+		guarded, err := s.guardTokenInput(ctx, auth, "tokenize", providerKey+"/"+actualModel, messages, tools)
+		if err != nil {
+			return 0, "", err
+		}
+		n, err := tc.CountTokens(ctx, actualModel, guarded, tools)
+		if err == nil {
+			return n, tokenCountMethodNative, nil
+		}
+		if !errors.Is(err, service.ErrTokenCountUnsupported) {
+			slog.Warn("native token count failed, using local estimate",
+				"provider", providerKey, "model", actualModel, "error", err)
+		}
+	}
+
+	return common.CountTokensLocal(messages, tools), tokenCountMethodEstimate, nil
+}
@@ -0,0 +1,105 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rakunlabs/at/internal/service"
+)
+
+// tokenCountingProvider answers CountTokens with a fixed count or error
+// and records the request it was asked to count.
+type tokenCountingProvider struct {
+	messagesCaptureProvider
+	count int
+	err   error
+	model string
+}
+
+func (p *tokenCountingProvider) CountTokens(_ context.Context, model string, messages []service.Message, tools []service.Tool) (int, error) {
+	p.model, p.messages, p.tools = model, messages, tools
+	return p.count, p.err
+}
+
+func doTokenize(s *Server, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/gateway/v1/tokenize", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer test-token")
+	rec := httptest.NewRecorder()
+	s.Tokenize(rec, req)
+	return rec
+}
+
+func TestTokenizeReportsCountingMethod(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider service.LLMProvider
+		method   string
+	}{
+		{"native", &tokenCountingProvider{count: 1234}, tokenCountMethodNative},
+		{"native failure falls back", &tokenCountingProvider{err: errors.New("upstream down")}, tokenCountMethodEstimate},
+		{"unsupported auth", &tokenCountingProvider{err: service.ErrTokenCountUnsupported}, tokenCountMethodEstimate},
+		{"no native API", &messagesCaptureProvider{}, tokenCountMethodEstimate},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newMessagesTestServer(tt.provider, "gemini")
+			rec := doTokenize(s, `{"model":"gemini/gemini-2.5-pro","messages":[{"role":"system","content":"be brief"}],"input":"`+strings.Repeat("word ", 100)+`"}`)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d; body=%s", rec.Code, rec.Body.String())
+			}
+			var body struct {
+				InputTokens int    `json:"input_tokens"`
+				Method      string `json:"method"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if body.Method != tt.method || rec.Header().Get("X-Token-Count-Method") != tt.method {
+				t.Fatalf("method = %q (header %q), want %q", body.Method, rec.Header().Get("X-Token-Count-Method"), tt.method)
+			}
+			switch {
+			case tt.method == tokenCountMethodNative && body.InputTokens != 1234:
+				t.Fatalf("input_tokens = %d, want native count", body.InputTokens)
+			case tt.method == tokenCountMethodEstimate && body.InputTokens < 100:
+				t.Fatalf("input_tokens = %d, want estimate >= 100", body.InputTokens)
+			}
+
+			if p, ok := tt.provider.(*tokenCountingProvider); ok {
+				if p.model != "gemini-2.5-pro" || len(p.messages) != 2 || p.messages[1].Role != "user" {
+					t.Fatalf("counted model=%q messages=%+v", p.model, p.messages)
+				}
+			}
+		})
+	}
+}
+
+func TestTokenizeValidatesRequest(t *testing.T) {
+	s := newMessagesTestServer(&messagesCaptureProvider{}, "gemini")
+
+	if rec := doTokenize(s, `{"model":"gemini/gemini-2.5-pro"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("no messages: status = %d", rec.Code)
+	}
+	if rec := doTokenize(s, `{"input":"hi"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("no model: status = %d", rec.Code)
+	}
+	if rec := doTokenize(s, `{"model":"missing/x","input":"hi"}`); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown provider: status = %d", rec.Code)
+	}
+}
+
+func TestMessagesCountTokensUsesNativeCount(t *testing.T) {
+	s := newMessagesTestServer(&tokenCountingProvider{count: 77}, "anthropic")
+
+	rec := doMessages(s, "/gateway/v1/messages/count_tokens", `{"model":"gemini/claude-sonnet-4-5","system":"be brief","messages":[{"role":"user","content":"hi"}]}`)
+	if rec.Code != http.StatusOK || rec.Header().Get("X-Token-Count-Method") != tokenCountMethodNative {
+		t.Fatalf("status = %d, method = %q; body=%s", rec.Code, rec.Header().Get("X-Token-Count-Method"), rec.Body.String())
+	}
+	if !strings.Contains(rec.Body.String(), `"input_tokens":77`) {
+		t.Fatalf("body = %s", rec.Body.String())
+	}
+}
@@ -0,0 +1,735 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+
+	"github.com/rakunlabs/at/internal/service"
+)
+
+// ─── Videos API ───
+//
+// /gateway/v1/videos submits a video generation job to a provider that
+// implements service.VideoProvider (OpenAI's Sora models) and tracks it
+// in the store. A background poller follows every unfinished job, also
+// after a restart. When a job completes the video is downloaded into the
+// workspace (<workspace_root>/videos/<id>.mp4) and a cost event is
+// recorded at the model's price_per_second. Jobs are scoped to the API
+// token that created them.
+//
+// Several instances may poll the same job. Every update after creation
+// is conditional on the job not being finished yet, so exactly one
+// instance completes, fails or cancels it and bills it once. Because
+// cost lands only when a job finishes, CreateVideo counts the running
+// jobs of the token against its spend limit at their estimated cost.
+
+var errVideosDisabled = errors.New("video generation is not available on this gateway")
+
+var (
+	// videoPollInterval is how often unfinished video jobs are polled.
+	videoPollInterval = 10 * time.Second
+
+	// videoJobTimeout fails jobs the provider has not finished in time.
+	videoJobTimeout = 24 * time.Hour
+)
+
+// defaultVideoSeconds is the clip length OpenAI uses when a request sets
+// none. It prices jobs whose length is not known yet.
+const defaultVideoSeconds = 4
+
+// createVideoRequest mirrors the OpenAI video creation request. Seconds
+// is documented as a string ("8") but numbers are accepted too.
+type createVideoRequest struct {
+	Model   string       `json:"model"`
+	Prompt  string       `json:"prompt"`
+	Seconds videoSeconds `json:"seconds,omitempty"`
+	Size    string       `json:"size,omitempty"`
+}
+
+type videoSeconds int
+
+func (v *videoSeconds) UnmarshalJSON(b []byte) error {
+	raw := strings.Trim(string(b), `"`)
+	if raw == "" || raw == "null" {
+		*v = 0
+		return nil
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < 0 {
+		return fmt.Errorf("seconds must be a non-negative integer")
+	}
+	*v = videoSeconds(n)
+	return nil
+}
+
+// videoAuth rebuilds the auth context a video job is billed to.
+func videoAuth(v *service.VideoGeneration) *authResult {
+	if v.TokenID == "" {
+		return &authResult{}
+	}
+	return &authResult{token: &service.APIToken{ID: v.TokenID, Name: v.TokenName}}
+}
+
+// videoObject renders a job in the OpenAI video object shape.
+func videoObject(v *service.VideoGeneration) map[string]any {
+	var completedAt any
+	if v.CompletedAt != nil {
+		completedAt = v.CompletedAt.Unix()
+	}
+	var errObj any
+	if v.ErrorMessage != "" {
+		errObj = map[string]any{"code": "video_generation_failed", "message": v.ErrorMessage}
+	}
+	return map[string]any{
+		"id":           v.ID,
+		"object":       "video",
+		"model":        v.ProviderKey + "/" + v.Model,
+		"status":       v.Status,
+		"progress":     v.Progress,
+		"prompt":       v.Prompt,
+		"seconds":      strconv.Itoa(v.Seconds),
+		"size":         v.Size,
+		"created_at":   v.CreatedAt.Unix(),
+		"completed_at": completedAt,
+		"error":        errObj,
+	}
+}
+
+// applyVideoJob copies the provider's view of a job onto the record.
+func applyVideoJob(v *service.VideoGeneration, job *service.VideoJob) {
+	if job.Status != "" {
+		v.Status = job.Status
+	}
+	if job.Progress > v.Progress {
+		v.Progress = job.Progress
+	}
+	if job.Seconds > 0 {
+		v.Seconds = job.Seconds
+	}
+	if job.Size != "" {
+		v.Size = job.Size
+	}
+	if job.Error != "" {
+		v.ErrorMessage = job.Error
+	}
+}
+
+// CreateVideo handles POST /gateway/v1/videos. Accepts a JSON body
+// (model, prompt, seconds?, size?) or multipart/form-data with the same
+// fields plus an optional input_reference image.
+func (s *Server) CreateVideo(w http.ResponseWriter, r *http.Request) {
+	var (
+		auth                                *authResult
+		providerKey, actualModel, fullModel string
+		info                                ProviderInfo
+		ok                                  bool
+		req                                 createVideoRequest
+		inputImage                          *service.ImageInput
+	)
+
+	isMultipart := strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data")
+	if isMultipart {
+		auth, providerKey, actualModel, fullModel, info, ok = s.resolveMultipartMediaProvider(w, r)
+	} else {
+		auth, providerKey, actualModel, fullModel, info, ok = s.resolveMediaProvider(w, r)
+	}
+	if !ok {
+		return
+	}
+	if s.videoStore == nil {
+		gatewayBadRequest(w, errVideosDisabled.Error(), "", "")
+		return
+	}
+
+	if isMultipart {
+		seconds, err := formInt(r, "seconds")
+		if err != nil {
+			gatewayBadRequest(w, err.Error(), "seconds", "")
+			return
+		}
+		images, err := formImages(r, "input_reference")
+		if err != nil {
+			gatewayBadRequest(w, err.Error(), "input_reference", "")
+			return
+		}
+		if len(images) > 0 {
+			inputImage = &images[0]
+		}
+		req.Prompt = r.FormValue("prompt")
+		req.Seconds = videoSeconds(seconds)
+		req.Size = r.FormValue("size")
+	} else if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		gatewayBadRequest(w, fmt.Sprintf("invalid request body: %v", err), "", "")
+		return
+	}
+	if req.Prompt == "" {
+		gatewayBadRequest(w, "prompt is required", "prompt", "")
+		return
+	}
+
+	vp, ok := service.As[service.VideoProvider](info.provider)
+	if !ok {
+		unsupportedMediaOperation(w, providerKey, "video generation")
+		return
+	}
+
+	probe := service.VideoGeneration{ProviderKey: providerKey, Model: actualModel, Seconds: int(req.Seconds)}
+	if limitMessage, err := s.checkVideoBudget(r.Context(), auth, &probe); err != nil {
+		slog.Error("token limit check failed", "error", err)
+	} else if limitMessage != "" {
+		httpResponseJSON(w, map[string]any{
+			"error": map[string]any{
+				"message": limitMessage,
+				"type":    "tokens",
+				"code":    "rate_limit_exceeded",
+			},
+		}, http.StatusTooManyRequests)
+		return
+	}
+
+	callStart := time.Now()
+	job, err := vp.SubmitVideo(r.Context(), service.VideoGenerateRequest{
+		Prompt:     req.Prompt,
+		Model:      actualModel,
+		Seconds:    int(req.Seconds),
+		Size:       req.Size,
+		InputImage: inputImage,
+	})
+	latencyMs := time.Since(callStart).Milliseconds()
+	if err != nil {
+		slog.Error("video submission failed", "provider", providerKey, "error", err)
+		s.recordUsageAsync(r.Context(), auth, fullModel, service.Usage{}, latencyMs, "error", classifyHTTPError(err), err.Error())
+		status, body := classifyGatewayError(err)
+		addGatewayRateLimitHeaders(w, err)
+		httpResponseJSON(w, body, status)
+		return
+	}
+
+	now := time.Now()
+	v := service.VideoGeneration{
+		ID:          "video_" + ulid.Make().String(),
+		TokenID:     storedResponseTokenID(auth),
+		ProviderKey: providerKey,
+		Model:       actualModel,
+		UpstreamID:  job.ID,
+		Prompt:      req.Prompt,
+		Seconds:     int(req.Seconds),
+		Size:        req.Size,
+		Status:      service.VideoStatusQueued,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if auth.token != nil {
+		v.TokenName = auth.token.Name
+	}
+	applyVideoJob(&v, job)
+	if err := s.videoStore.PutVideoGeneration(r.Context(), v); err != nil {
+		// Nobody would poll or pay for the job; stop it upstream.
+		if cerr := vp.CancelVideo(context.WithoutCancel(r.Context()), job.ID); cerr != nil {
+			slog.Warn("videos: cancel untracked job failed", "provider", providerKey, "upstream_id", job.ID, "error", cerr.Error())
+		}
+		writeBatchServerError(w, err)
+		return
+	}
+
+	s.startVideo(v.ID)
+	httpResponseJSON(w, videoObject(&v), http.StatusOK)
+}
+
+// GetVideo handles GET /gateway/v1/videos/{id}.
+func (s *Server) GetVideo(w http.ResponseWriter, r *http.Request) {
+	v, ok := s.videoJobRequest(w, r)
+	if !ok {
+		return
+	}
+	httpResponseJSON(w, videoObject(v), http.StatusOK)
+}
+
+// ListVideos handles GET /gateway/v1/videos with OpenAI's after/limit
+// (1-100, default 20) cursor parameters.
+func (s *Server) ListVideos(w http.ResponseWriter, r *http.Request) {
+	auth, ok := s.videoRequestAuth(w, r)
+	if !ok {
+		return
+	}
+
+	videos, err := s.videoStore.ListVideoGenerations(r.Context(), storedResponseTokenID(auth))
+	if err != nil {
+		writeBatchServerError(w, err)
+		return
+	}
+
+	q := r.URL.Query()
+	if after := q.Get("after"); after != "" {
+		for i := range videos {
+			if videos[i].ID == after {
+				videos = videos[i+1:]
+				break
+			}
+		}
+	}
+	limit := 20
+	if v, err := strconv.Atoi(q.Get("limit")); err == nil && v > 0 {
+		limit = min(v, 100)
+	}
+	hasMore := len(videos) > limit
+	if hasMore {
+		videos = videos[:limit]
+	}
+
+	data := make([]map[string]any, 0, len(videos))
+	for i := range videos {
+		data = append(data, videoObject(&videos[i]))
+	}
+	list := map[string]any{"object": "list", "data": data, "has_more": hasMore, "first_id": nil, "last_id": nil}
+	if len(videos) > 0 {
+		list["first_id"] = videos[0].ID
+		list["last_id"] = videos[len(videos)-1].ID
+	}
+	httpResponseJSON(w, list, http.StatusOK)
+}
+
+// GetVideoContent handles GET /gateway/v1/videos/{id}/content. The
+// downloaded file is served from the workspace; when the download failed
+// the video is streamed from the provider instead.
+func (s *Server) GetVideoContent(w http.ResponseWriter, r *http.Request) {
+	v, ok := s.videoJobRequest(w, r)
+	if !ok {
+		return
+	}
+	if v.Status != service.VideoStatusCompleted {
+		gatewayBadRequest(w, fmt.Sprintf("video is not ready (status %q)", v.Status), "", "video_not_ready")
+		return
+	}
+
+	if v.FilePath != "" {
+		if f, err := os.Open(v.FilePath); err == nil {
+			defer f.Close()
+			var modTime time.Time
+			if v.CompletedAt != nil {
+				modTime = *v.CompletedAt
+			}
+			w.Header().Set("Content-Type", v.ContentType)
+			http.ServeContent(w, r, filepath.Base(v.FilePath), modTime, f)
+			return
+		}
+	}
+
+	vp, err := s.videoProvider(v.ProviderKey)
+	if err != nil {
+		status, body := classifyGatewayError(err)
+		httpResponseJSON(w, body, status)
+		return
+	}
+	body, contentType, err := vp.DownloadVideo(r.Context(), v.UpstreamID)
+	if err != nil {
+		status, errBody := classifyGatewayError(err)
+		addGatewayRateLimitHeaders(w, err)
+		httpResponseJSON(w, errBody, status)
+		return
+	}
+	defer body.Close()
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusOK)
+	_, _ = io.Copy(w, body)
+}
+
+// CancelVideo handles DELETE /gateway/v1/videos/{id}. A queued or running
+// job is cancelled upstream and ends as "cancelled"; finished jobs cannot
+// be cancelled.
+func (s *Server) CancelVideo(w http.ResponseWriter, r *http.Request) {
+	v, ok := s.videoJobRequest(w, r)
+	if !ok {
+		return
+	}
+	if service.VideoStatusTerminal(v.Status) {
+		gatewayBadRequest(w, fmt.Sprintf("cannot cancel a video with status %q", v.Status), "", "")
+		return
+	}
+
+	if cancel, ok := s.videoRuns.Load(v.ID); ok {
+		cancel.(context.CancelFunc)()
+	}
+	if vp, err := s.videoProvider(v.ProviderKey); err == nil {
+		if err := vp.CancelVideo(r.Context(), v.UpstreamID); err != nil {
+			slog.Warn("videos: upstream cancel failed", "video_id", v.ID, "upstream_id", v.UpstreamID, "error", err.Error())
+		}
+	}
+
+	v.Status = service.VideoStatusCancelled
+	v.UpdatedAt = time.Now()
+	updated, err := s.videoStore.UpdateVideoGeneration(r.Context(), *v)
+	if err != nil {
+		writeBatchServerError(w, err)
+		return
+	}
+	if !updated {
+		// A poller finished the job in the meantime.
+		status := "finished"
+		if cur, err := s.videoStore.GetVideoGeneration(r.Context(), v.ID); err == nil && cur != nil {
+			status = cur.Status
+		}
+		gatewayBadRequest(w, fmt.Sprintf("cannot cancel a video with status %q", status), "", "")
+		return
+	}
+
+	httpResponseJSON(w, videoObject(v), http.StatusOK)
+}
+
+// checkVideoBudget rejects a video job that could take the token past its
+// spend limit. Video cost is recorded only when a job finishes, so the
+// new job and the token's running jobs count at their estimated cost on
+// top of what is already spent.
+func (s *Server) checkVideoBudget(ctx context.Context, auth *authResult, v *service.VideoGeneration) (string, error) {
+	if auth == nil || auth.token == nil || auth.token.ID == "" || s.costEventStore == nil {
+		return "", nil
+	}
+	token := auth.token
+	if !token.SpendLimitCents.Valid || token.SpendLimitCents.V <= 0 {
+		return "", nil
+	}
+
+	spend, err := s.costEventStore.GetCostByAgentSince(ctx, "gateway:"+token.ID, tokenBudgetWindowStart(token))
+	if err != nil {
+		return "", fmt.Errorf("get token spend: %w", err)
+	}
+	running, err := s.videoStore.ListVideoGenerations(ctx, token.ID)
+	if err != nil {
+		return "", fmt.Errorf("list token videos: %w", err)
+	}
+
+	reserved := s.estimateVideoReservationCents(ctx, v)
+	for i := range running {
+		if !service.VideoStatusTerminal(running[i].Status) {
+			reserved += s.estimateVideoReservationCents(ctx, &running[i])
+		}
+	}
+	if spend+reserved > token.SpendLimitCents.V {
+		return fmt.Sprintf("token spend limit exceeded: %.2f cents spent, this and the running videos may cost %.2f more", spend, reserved), nil
+	}
+	return "", nil
+}
+
+// estimateVideoReservationCents prices a job that has not finished,
+// assuming the default length when it is not known yet.
+func (s *Server) estimateVideoReservationCents(ctx context.Context, v *service.VideoGeneration) float64 {
+	est := *v
+	if est.Seconds <= 0 {
+		est.Seconds = defaultVideoSeconds
+	}
+	return s.estimateGatewayVideoCostCents(ctx, &est)
+}
+
+// videoRequestAuth authenticates a Videos API request, writing the error
+// response itself when that fails.
+func (s *Server) videoRequestAuth(w http.ResponseWriter, r *http.Request) (*authResult, bool) {
+	auth, authErr := s.authenticateRequest(r)
+	if authErr != "" {
+		httpResponseJSON(w, map[string]any{
+			"error": map[string]any{
+				"message": authErr,
+				"type":    "invalid_request_error",
+				"code":    "invalid_api_key",
+			},
+		}, http.StatusUnauthorized)
+		return nil, false
+	}
+	if s.videoStore == nil {
+		gatewayBadRequest(w, errVideosDisabled.Error(), "", "")
+		return nil, false
+	}
+	return auth, true
+}
+
+// videoJobRequest authenticates a video request and loads the token's
+// job named by the {id} path value.
+func (s *Server) videoJobRequest(w http.ResponseWriter, r *http.Request) (*service.VideoGeneration, bool) {
+	auth, ok := s.videoRequestAuth(w, r)
+	if !ok {
+		return nil, false
+	}
+
+	id := r.PathValue("id")
+	v, err := s.videoStore.GetVideoGeneration(r.Context(), id)
+	if err != nil {
+		writeBatchServerError(w, err)
+		return nil, false
+	}
+	if v == nil || v.TokenID != storedResponseTokenID(auth) {
+		writeBatchNotFound(w, "Video", id)
+		return nil, false
+	}
+	return v, true
+}
+
+// videoProvider returns the VideoProvider configured under providerKey.
+func (s *Server) videoProvider(providerKey string) (service.VideoProvider, error) {
+	info, ok := s.getProviderInfo(providerKey)
+	if !ok {
+		return nil, fmt.Errorf("provider %q not found", providerKey)
+	}
+	vp, ok := service.As[service.VideoProvider](info.provider)
+	if !ok {
+		return nil, fmt.Errorf("provider %q: video generation: %w", providerKey, service.ErrUnsupportedOperation)
+	}
+	return vp, nil
+}
+
+// ─── Polling ───
+
+// startVideoWorkers resumes polling of unfinished video jobs.
+func (s *Server) startVideoWorkers(ctx context.Context) {
+	if s.videoStore == nil {
+		return
+	}
+
+	videos, err := s.videoStore.ListVideoGenerationsByStatus(ctx, service.VideoStatusQueued, service.VideoStatusInProgress)
+	if err != nil {
+		slog.Error("videos: list unfinished jobs failed", "error", err.Error())
+	}
+	for _, v := range videos {
+		s.startVideo(v.ID)
+	}
+}
+
+// startVideo polls a job in the background unless it is already being
+// polled on this instance.
+func (s *Server) startVideo(id string) {
+	parent := s.ctx
+	if parent == nil {
+		parent = context.Background()
+	}
+	ctx, cancel := context.WithCancel(parent)
+	if _, running := s.videoRuns.LoadOrStore(id, cancel); running {
+		cancel()
+		return
+	}
+
+	go func() {
+		defer func() {
+			s.videoRuns.Delete(id)
+			cancel()
+		}()
+		s.runVideo(ctx, id)
+	}()
+}
+
+// runVideo polls a job until it reaches a terminal status. ctx is
+// cancelled by CancelVideo or on shutdown; an unfinished job is resumed
+// on the next start.
+func (s *Server) runVideo(ctx context.Context, id string) {
+	ticker := time.NewTicker(videoPollInterval)
+	defer ticker.Stop()
+	for {
+		done, err := s.syncVideo(ctx, id)
+		if err != nil && ctx.Err() == nil {
+			slog.Warn("videos: poll failed", "video_id", id, "error", err.Error())
+		}
+		if done {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// syncVideo polls the provider once and stores the job's progress. It
+// reports true once the job is finished.
+func (s *Server) syncVideo(ctx context.Context, id string) (bool, error) {
+	// Reload on every poll: the job may have been cancelled elsewhere.
+	v, err := s.videoStore.GetVideoGeneration(ctx, id)
+	if err != nil {
+		return false, err
+	}
+	if v == nil || service.VideoStatusTerminal(v.Status) {
+		return true, nil
+	}
+
+	vp, err := s.videoProvider(v.ProviderKey)
+	if err != nil {
+		return true, s.failVideo(ctx, v, err.Error())
+	}
+
+	job, err := vp.GetVideo(ctx, v.UpstreamID)
+	if err != nil {
+		if time.Since(v.CreatedAt) > videoJobTimeout {
+			return true, s.failVideo(ctx, v, fmt.Sprintf("polling failed: %v", err))
+		}
+		return false, err
+	}
+	applyVideoJob(v, job)
+
+	switch v.Status {
+	case service.VideoStatusCompleted:
+		return true, s.completeVideo(ctx, v, vp)
+	case service.VideoStatusFailed:
+		if v.ErrorMessage == "" {
+			v.ErrorMessage = "video generation failed"
+		}
+		return true, s.failVideo(ctx, v, v.ErrorMessage)
+	case service.VideoStatusCancelled:
+		v.UpdatedAt = time.Now()
+		_, err := s.videoStore.UpdateVideoGeneration(ctx, *v)
+		return true, err
+	}
+
+	if time.Since(v.CreatedAt) > videoJobTimeout {
+		if err := vp.CancelVideo(ctx, v.UpstreamID); err != nil {
+			slog.Warn("videos: upstream cancel failed", "video_id", v.ID, "upstream_id", v.UpstreamID, "error", err.Error())
+		}
+		return true, s.failVideo(ctx, v, "video generation timed out")
+	}
+
+	v.UpdatedAt = time.Now()
+	updated, err := s.videoStore.UpdateVideoGeneration(ctx, *v)
+	if err != nil {
+		return false, err
+	}
+	// Not updated: another instance finished the job since the reload.
+	return !updated, nil
+}
+
+// completeVideo downloads a finished job into the workspace, stores it
+// and records its cost. A failed download leaves the job completed;
+// GetVideoContent then streams the video from the provider. The cost is
+// recorded only by the instance whose update finished the job.
+func (s *Server) completeVideo(ctx context.Context, v *service.VideoGeneration, vp service.VideoProvider) error {
+	if err := s.downloadVideo(ctx, v, vp); err != nil {
+		slog.Warn("videos: download failed", "video_id", v.ID, "upstream_id", v.UpstreamID, "error", err.Error())
+	}
+
+	now := time.Now()
+	v.Progress = 100
+	v.CostCents = s.estimateGatewayVideoCostCents(ctx, v)
+	v.CompletedAt = &now
+	v.UpdatedAt = now
+	updated, err := s.videoStore.UpdateVideoGeneration(ctx, *v)
+	if err != nil || !updated {
+		return err
+	}
+
+	s.recordVideoUsage(ctx, v, "ok", "")
+	return nil
+}
+
+// failVideo marks an unfinished job failed and records the failure, like
+// completeVideo only when this instance finished the job.
+func (s *Server) failVideo(ctx context.Context, v *service.VideoGeneration, msg string) error {
+	v.Status = service.VideoStatusFailed
+	v.ErrorMessage = msg
+	v.UpdatedAt = time.Now()
+	updated, err := s.videoStore.UpdateVideoGeneration(ctx, *v)
+	if err != nil || !updated {
+		return err
+	}
+
+	s.recordVideoUsage(ctx, v, "error", msg)
+	return nil
+}
+
+// downloadVideo saves a finished video under <workspace_root>/videos.
+func (s *Server) downloadVideo(ctx context.Context, v *service.VideoGeneration, vp service.VideoProvider) error {
+	body, contentType, err := vp.DownloadVideo(ctx, v.UpstreamID)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	dir := filepath.Join(s.taskWorkspaceBase(), "videos")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("create video dir: %w", err)
+	}
+	path := filepath.Join(dir, v.ID+videoExtension(contentType))
+
+	// Write to a unique temporary name so a partial file is never served,
+	// also when another instance downloads the same job.
+	f, err := os.CreateTemp(dir, v.ID+"-*.part")
+	if err != nil {
+		return fmt.Errorf("create video file: %w", err)
+	}
+	tmp := f.Name()
+	n, err := io.Copy(f, body)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("save video: %w", err)
+	}
+
+	v.FilePath = path
+	v.ContentType = contentType
+	v.Bytes = n
+	return nil
+}
+
+// videoExtension maps a video content type to a file extension.
+func videoExtension(contentType string) string {
+	switch {
+	case strings.Contains(contentType, "webm"):
+		return ".webm"
+	case strings.Contains(contentType, "quicktime"):
+		return ".mov"
+	default:
+		return ".mp4"
+	}
+}
+
+// recordVideoUsage records a finished job in the gateway metrics and as
+// a cost event, like recordUsageAsync does for token-billed calls. The
+// latency is the job's wall-clock duration.
+func (s *Server) recordVideoUsage(ctx context.Context, v *service.VideoGeneration, status, errMsg string) {
+	auth := videoAuth(v)
+	fullModel := v.ProviderKey + "/" + v.Model
+	latencyMs := time.Since(v.CreatedAt).Milliseconds()
+
+	observeGatewayRequest(auth, fullModel, service.Usage{}, v.CostCents, latencyMs, status)
+
+	if v.TokenID == "" || s.costEventStore == nil {
+		return // config token or unrestricted — no tracking
+	}
+	billingCode := ""
+	if v.TokenName != "" {
+		billingCode = "token:" + v.TokenName
+	}
+	errCode := ""
+	if status == "error" {
+		errCode = "video_generation_failed"
+	}
+
+	event := service.CostEvent{
+		AgentID:      "gateway:" + v.TokenID, // gateway calls have no agent; tag with token ID
+		Provider:     v.ProviderKey,
+		Model:        v.Model,
+		BillingCode:  billingCode,
+		CostCents:    v.CostCents,
+		LatencyMs:    latencyMs,
+		Status:       status,
+		ErrorCode:    errCode,
+		ErrorMessage: errMsg,
+	}
+	videoID := v.ID
+	go func() {
+		if err := s.costEventStore.RecordCostEvent(context.WithoutCancel(ctx), event); err != nil {
+			slog.Error("failed to record video cost event", "video_id", videoID, "error", err)
+		}
+	}()
+}
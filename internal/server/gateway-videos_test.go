@@ -0,0 +1,432 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rakunlabs/at/internal/service"
+	"github.com/rakunlabs/at/internal/service/loopgov"
+	"github.com/worldline-go/types"
+)
+
+// memoryVideoStore is an in-memory VideoGenerationStorer for tests.
+type memoryVideoStore struct {
+	mu     sync.Mutex
+	videos map[string]service.VideoGeneration
+}
+
+func newMemoryVideoStore() *memoryVideoStore {
+	return &memoryVideoStore{videos: map[string]service.VideoGeneration{}}
+}
+
+func (m *memoryVideoStore) GetVideoGeneration(_ context.Context, id string) (*service.VideoGeneration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v, ok := m.videos[id]
+	if !ok {
+		return nil, nil
+	}
+	return &v, nil
+}
+
+func (m *memoryVideoStore) ListVideoGenerations(_ context.Context, tokenID string) ([]service.VideoGeneration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []service.VideoGeneration
+	for _, v := range m.videos {
+		if v.TokenID == tokenID {
+			out = append(out, v)
+		}
+	}
+	return out, nil
+}
+
+func (m *memoryVideoStore) ListVideoGenerationsByStatus(_ context.Context, statuses ...string) ([]service.VideoGeneration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []service.VideoGeneration
+	for _, v := range m.videos {
+		for _, st := range statuses {
+			if v.Status == st {
+				out = append(out, v)
+			}
+		}
+	}
+	return out, nil
+}
+
+func (m *memoryVideoStore) PutVideoGeneration(_ context.Context, v service.VideoGeneration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.videos[v.ID] = v
+	return nil
+}
+
+func (m *memoryVideoStore) UpdateVideoGeneration(_ context.Context, v service.VideoGeneration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cur, ok := m.videos[v.ID]
+	if !ok || service.VideoStatusTerminal(cur.Status) {
+		return false, nil
+	}
+	m.videos[v.ID] = v
+	return true, nil
+}
+
+// videoTestProvider runs one job that reports in_progress for the given
+// number of polls, then completes (or stays running when polls < 0).
+type videoTestProvider struct {
+	choicesTestProvider
+
+	mu        sync.Mutex
+	polls     int
+	submitted *service.VideoGenerateRequest
+	cancelled []string
+}
+
+func (p *videoTestProvider) SubmitVideo(_ context.Context, req service.VideoGenerateRequest) (*service.VideoJob, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.submitted = &req
+	return &service.VideoJob{ID: "up_1", Status: service.VideoStatusQueued, Seconds: req.Seconds, Size: req.Size}, nil
+}
+
+func (p *videoTestProvider) GetVideo(_ context.Context, id string) (*service.VideoJob, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.polls != 0 {
+		if p.polls > 0 {
+			p.polls--
+		}
+		return &service.VideoJob{ID: id, Status: service.VideoStatusInProgress, Progress: 50}, nil
+	}
+	return &service.VideoJob{ID: id, Status: service.VideoStatusCompleted, Progress: 100}, nil
+}
+
+func (p *videoTestProvider) CancelVideo(_ context.Context, id string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.cancelled = append(p.cancelled, id)
+	return nil
+}
+
+func (p *videoTestProvider) DownloadVideo(context.Context, string) (io.ReadCloser, string, error) {
+	return io.NopCloser(strings.NewReader("fake mp4")), "video/mp4", nil
+}
+
+// videoCostStore records cost events.
+type videoCostStore struct {
+	service.CostEventStorer
+
+	mu     sync.Mutex
+	events []service.CostEvent
+}
+
+func (s *videoCostStore) RecordCostEvent(_ context.Context, event service.CostEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, event)
+	return nil
+}
+
+func (s *videoCostStore) recorded() []service.CostEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]service.CostEvent(nil), s.events...)
+}
+
+func newVideoTestServer(t *testing.T, provider service.LLMProvider) *Server {
+	t.Helper()
+	poll := videoPollInterval
+	videoPollInterval = 5 * time.Millisecond
+	t.Cleanup(func() { videoPollInterval = poll })
+
+	s := newChoicesTestServer(provider)
+	s.videoStore = newMemoryVideoStore()
+	s.loopGov = loopgov.New(loopgov.Config{WorkspaceRoot: t.TempDir()}, nil)
+
+	// Stop the pollers before the interval is restored.
+	ctx, cancel := context.WithCancel(context.Background())
+	s.ctx = ctx
+	t.Cleanup(func() {
+		cancel()
+		for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+			running := false
+			s.videoRuns.Range(func(any, any) bool {
+				running = true
+				return false
+			})
+			if !running {
+				return
+			}
+		}
+	})
+	return s
+}
+
+func doVideoRequest(s *Server, method, path string, body io.Reader, contentType string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, body)
+	req.Header.Set("Authorization", "Bearer test-token")
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	id, sub, _ := strings.Cut(strings.TrimPrefix(req.URL.Path, "/gateway/v1/videos"), "/")
+	if id == "" {
+		id, sub, _ = strings.Cut(sub, "/")
+	}
+	req.SetPathValue("id", id)
+	rec := httptest.NewRecorder()
+	switch {
+	case method == http.MethodPost:
+		s.CreateVideo(rec, req)
+	case method == http.MethodDelete:
+		s.CancelVideo(rec, req)
+	case id == "":
+		s.ListVideos(rec, req)
+	case sub == "content":
+		s.GetVideoContent(rec, req)
+	default:
+		s.GetVideo(rec, req)
+	}
+	return rec
+}
+
+// waitVideo polls until the job reaches a final status.
+func waitVideo(t *testing.T, s *Server, id string) map[string]any {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		rec := doVideoRequest(s, http.MethodGet, "/gateway/v1/videos/"+id, nil, "")
+		var v map[string]any
+		_ = json.Unmarshal(rec.Body.Bytes(), &v)
+		if service.VideoStatusTerminal(v["status"].(string)) {
+			return v
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("video %s did not finish", id)
+	return nil
+}
+
+func TestVideoJobCompletesWithDownloadAndCost(t *testing.T) {
+	provider := &videoTestProvider{polls: 2}
+	s := newVideoTestServer(t, provider)
+	s.agentBudgetStore = &pricingTestBudgetStore{pricing: []service.ModelPricing{
+		{ProviderKey: "p", Model: "sora-2", PricePerSecond: 0.1},
+	}}
+	costs := &videoCostStore{}
+	s.costEventStore = costs
+
+	rec := doVideoRequest(s, http.MethodPost, "/gateway/v1/videos",
+		strings.NewReader(`{"model":"p/sora-2","prompt":"a cat","seconds":"4","size":"1280x720"}`), "application/json")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("create status = %d; body=%s", rec.Code, rec.Body.String())
+	}
+	var created map[string]any
+	_ = json.Unmarshal(rec.Body.Bytes(), &created)
+	if created["object"] != "video" || created["status"] != "queued" || created["seconds"] != "4" || created["model"] != "p/sora-2" {
+		t.Fatalf("created = %+v", created)
+	}
+	if provider.submitted.Model != "sora-2" || provider.submitted.Seconds != 4 {
+		t.Errorf("submitted = %+v", provider.submitted)
+	}
+
+	id := created["id"].(string)
+	final := waitVideo(t, s, id)
+	if final["status"] != "completed" || final["progress"] != float64(100) || final["completed_at"] == nil {
+		t.Fatalf("final = %+v", final)
+	}
+
+	stored, _ := s.videoStore.GetVideoGeneration(context.Background(), id)
+	if data, err := os.ReadFile(stored.FilePath); err != nil || string(data) != "fake mp4" {
+		t.Fatalf("downloaded file %q = %q, err=%v", stored.FilePath, data, err)
+	}
+	if stored.CostCents != 40 || stored.Bytes != int64(len("fake mp4")) {
+		t.Errorf("stored = %+v", stored)
+	}
+
+	rec = doVideoRequest(s, http.MethodGet, "/gateway/v1/videos/"+id+"/content", nil, "")
+	if rec.Code != http.StatusOK || rec.Body.String() != "fake mp4" || rec.Header().Get("Content-Type") != "video/mp4" {
+		t.Fatalf("content status = %d, type %q, body=%q", rec.Code, rec.Header().Get("Content-Type"), rec.Body.String())
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for len(costs.recorded()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	events := costs.recorded()
+	if len(events) != 1 || events[0].CostCents != 40 || events[0].AgentID != "gateway:tok-1" || events[0].Provider != "p" || events[0].Status != "ok" {
+		t.Fatalf("cost events = %+v", events)
+	}
+
+	rec = doVideoRequest(s, http.MethodGet, "/gateway/v1/videos", nil, "")
+	var list struct {
+		Data []map[string]any `json:"data"`
+	}
+	_ = json.Unmarshal(rec.Body.Bytes(), &list)
+	if rec.Code != http.StatusOK || len(list.Data) != 1 || list.Data[0]["id"] != id {
+		t.Fatalf("list status = %d; body=%s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestVideoCancel(t *testing.T) {
+	provider := &videoTestProvider{polls: -1}
+	s := newVideoTestServer(t, provider)
+
+	rec := doVideoRequest(s, http.MethodPost, "/gateway/v1/videos", strings.NewReader(`{"model":"p/sora-2","prompt":"a cat"}`), "application/json")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("create status = %d; body=%s", rec.Code, rec.Body.String())
+	}
+	var created map[string]any
+	_ = json.Unmarshal(rec.Body.Bytes(), &created)
+	id := created["id"].(string)
+
+	rec = doVideoRequest(s, http.MethodGet, "/gateway/v1/videos/"+id+"/content", nil, "")
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("content before completion status = %d; body=%s", rec.Code, rec.Body.String())
+	}
+
+	rec = doVideoRequest(s, http.MethodDelete, "/gateway/v1/videos/"+id, nil, "")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"status":"cancelled"`) {
+		t.Fatalf("cancel status = %d; body=%s", rec.Code, rec.Body.String())
+	}
+	provider.mu.Lock()
+	cancelled := provider.cancelled
+	provider.mu.Unlock()
+	if len(cancelled) != 1 || cancelled[0] != "up_1" {
+		t.Errorf("upstream cancels = %v", cancelled)
+	}
+
+	if final := waitVideo(t, s, id); final["status"] != "cancelled" {
+		t.Errorf("final = %+v", final)
+	}
+	rec = doVideoRequest(s, http.MethodDelete, "/gateway/v1/videos/"+id, nil, "")
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("second cancel status = %d; body=%s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestVideoCompletionBillsOnce(t *testing.T) {
+	provider := &videoTestProvider{}
+	s := newVideoTestServer(t, provider)
+	s.agentBudgetStore = &pricingTestBudgetStore{pricing: []service.ModelPricing{
+		{ProviderKey: "p", Model: "sora-2", PricePerSecond: 0.1},
+	}}
+	costs := &videoCostStore{}
+	s.costEventStore = costs
+
+	ctx := context.Background()
+	v := service.VideoGeneration{ID: "video_1", TokenID: "tok-1", ProviderKey: "p", Model: "sora-2", UpstreamID: "up_1", Seconds: 4, Status: service.VideoStatusInProgress}
+	if err := s.videoStore.PutVideoGeneration(ctx, v); err != nil {
+		t.Fatal(err)
+	}
+
+	// Two instances loaded the job before either finished it.
+	for range 2 {
+		polled := v
+		polled.Status = service.VideoStatusCompleted
+		if err := s.completeVideo(ctx, &polled, provider); err != nil {
+			t.Fatalf("completeVideo: %v", err)
+		}
+	}
+	late := v
+	if err := s.failVideo(ctx, &late, "timed out"); err != nil {
+		t.Fatalf("failVideo: %v", err)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	if events := costs.recorded(); len(events) != 1 || events[0].Status != "ok" {
+		t.Fatalf("cost events = %+v, want one completion", events)
+	}
+	if stored, _ := s.videoStore.GetVideoGeneration(ctx, "video_1"); stored.Status != service.VideoStatusCompleted {
+		t.Errorf("status = %q, want completed", stored.Status)
+	}
+}
+
+func TestCreateVideoReservesRunningJobsAgainstSpendLimit(t *testing.T) {
+	s := newVideoTestServer(t, &videoTestProvider{polls: -1})
+	s.tokenStore = gatewayTestToken("test-token", service.APIToken{
+		ID:                   "tok-1",
+		AllowedProvidersMode: service.AccessModeAll,
+		AllowedModelsMode:    service.AccessModeAll,
+		SpendLimitCents:      types.NewNull(100.0),
+	})
+	s.agentBudgetStore = &pricingTestBudgetStore{pricing: []service.ModelPricing{
+		{ProviderKey: "p", Model: "sora-2", PricePerSecond: 0.1},
+	}}
+	s.costEventStore = &budgetCostEventStore{}
+
+	// Nothing is spent yet, but the first 80-cent job is still running.
+	body := `{"model":"p/sora-2","prompt":"a cat","seconds":"8"}`
+	if rec := doVideoRequest(s, http.MethodPost, "/gateway/v1/videos", strings.NewReader(body), "application/json"); rec.Code != http.StatusOK {
+		t.Fatalf("first job status = %d; body=%s", rec.Code, rec.Body.String())
+	}
+	rec := doVideoRequest(s, http.MethodPost, "/gateway/v1/videos", strings.NewReader(body), "application/json")
+	if rec.Code != http.StatusTooManyRequests || !strings.Contains(rec.Body.String(), "spend limit") {
+		t.Fatalf("second job status = %d, want 429; body=%s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestCreateVideoMultipartInputReference(t *testing.T) {
+	provider := &videoTestProvider{}
+	s := newVideoTestServer(t, provider)
+
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	_ = mw.WriteField("model", "p/sora-2")
+	_ = mw.WriteField("prompt", "animate this")
+	_ = mw.WriteField("seconds", "8")
+	part, _ := mw.CreateFormFile("input_reference", "frame.png")
+	_, _ = part.Write([]byte("\x89PNG\r\n\x1a\nfake"))
+	_ = mw.Close()
+
+	rec := doVideoRequest(s, http.MethodPost, "/gateway/v1/videos", &buf, mw.FormDataContentType())
+	if rec.Code != http.StatusOK {
+		t.Fatalf("create status = %d; body=%s", rec.Code, rec.Body.String())
+	}
+	req := provider.submitted
+	if req.Prompt != "animate this" || req.Seconds != 8 || req.InputImage == nil || req.InputImage.ContentType != "image/png" {
+		t.Errorf("submitted = %+v", req)
+	}
+}
+
+func TestCreateVideoErrors(t *testing.T) {
+	t.Run("unsupported provider", func(t *testing.T) {
+		s := newVideoTestServer(t, &choicesTestProvider{})
+		rec := doVideoRequest(s, http.MethodPost, "/gateway/v1/videos", strings.NewReader(`{"model":"p/m","prompt":"x"}`), "application/json")
+		if rec.Code != http.StatusNotImplemented {
+			t.Errorf("status = %d; body=%s", rec.Code, rec.Body.String())
+		}
+	})
+	t.Run("bad seconds", func(t *testing.T) {
+		s := newVideoTestServer(t, &videoTestProvider{})
+		rec := doVideoRequest(s, http.MethodPost, "/gateway/v1/videos", strings.NewReader(`{"model":"p/m","prompt":"x","seconds":"long"}`), "application/json")
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("status = %d; body=%s", rec.Code, rec.Body.String())
+		}
+	})
+	t.Run("unknown video", func(t *testing.T) {
+		s := newVideoTestServer(t, &videoTestProvider{})
+		rec := doVideoRequest(s, http.MethodGet, "/gateway/v1/videos/video_missing", nil, "")
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("status = %d; body=%s", rec.Code, rec.Body.String())
+		}
+	})
+	t.Run("disabled", func(t *testing.T) {
+		s := newChoicesTestServer(&videoTestProvider{})
+		rec := doVideoRequest(s, http.MethodGet, "/gateway/v1/videos", nil, "")
+		if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), errVideosDisabled.Error()) {
+			t.Errorf("status = %d; body=%s", rec.Code, rec.Body.String())
+		}
+	})
+}
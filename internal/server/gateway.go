@@ -17,6 +17,7 @@ import (
 	str2duration "github.com/xhit/go-str2duration/v2"
 
 	"github.com/rakunlabs/at/internal/service"
+	"github.com/rakunlabs/at/internal/service/metrics"
 )
 
 // tokenLastUsedThreshold is the minimum interval between DB writes for a
@@ -81,8 +82,8 @@ func (a *authResult) isModelAllowed(providerKey, fullModelID string) bool {
 // provider based on the model prefix (e.g., "anthropic/claude-haiku-4-5"),
 // and returns an OpenAI-compatible response.
 //
-// Supports AT extensions: at_fallbacks, extra_body, mock_response, timeout_ms,
-// and the Idempotency-Key header (litellm-style ergonomics).
+// Supports AT extensions: at_fallbacks, at_hedge, extra_body, mock_response,
+// timeout_ms, and the Idempotency-Key header (litellm-style ergonomics).
 func (s *Server) ChatCompletions(w http.ResponseWriter, r *http.Request) {
 	// Auth check
 	auth, authErr := s.authenticateRequest(r)
@@ -141,6 +142,24 @@ func (s *Server) ChatCompletions(w http.ResponseWriter, r *http.Request) {
 	// Trace/session correlation for the audit log.
 	traceID, sessionID := auditTraceInfo(r)
 
+	// Gateway hooks may rewrite the request before routing. The audit log
+	// and the response cache key see the rewritten body.
+	hooks, hooked, err := s.applyRequestHooks(r.Context(), auth, &req)
+	if err == nil && req.Stream {
+		err = rejectStreamingHooks(r.Context(), hooks)
+	}
+	if err != nil {
+		status, body := classifyGatewayError(err)
+		httpResponseJSON(respW, body, status)
+		s.maybeStoreIdempotent(idempKey, cap, w)
+		return
+	}
+	if hooked {
+		if b, err := json.Marshal(req); err == nil {
+			rawBody = b
+		}
+	}
+
 	// B3: mock_response short-circuit. No provider lookup, no auth model
 	// access checks beyond the basic token guard — the whole point of
 	// mock mode is to be free.
@@ -194,8 +213,40 @@ func (s *Server) ChatCompletions(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Token budget checks once (DB tokens only).
-	if limitMessage, resetErr := s.checkTokenLimits(r.Context(), auth); resetErr != nil {
+	// n > 1 is emulated with parallel calls for providers that lack it.
+	if msg := checkChoicesTargets(req.N, req.Stream, chain...); msg != "" {
+		httpResponseJSON(respW, map[string]any{
+			"error": map[string]any{
+				"message": msg,
+				"type":    "invalid_request_error",
+				"param":   "n",
+			},
+		}, http.StatusBadRequest)
+		s.maybeStoreIdempotent(idempKey, cap, w)
+		return
+	}
+
+	// at_hedge races the primary against the first usable fallback.
+	if req.AtHedge != nil && hedgeTargetIndex(chain) < 0 {
+		httpResponseJSON(respW, map[string]any{
+			"error": map[string]any{
+				"message": "at_hedge requires at least one usable at_fallbacks model",
+				"type":    "invalid_request_error",
+				"param":   "at_hedge",
+			},
+		}, http.StatusBadRequest)
+		s.maybeStoreIdempotent(idempKey, cap, w)
+		return
+	}
+
+	// Token budget checks once (DB tokens only), before any cache lookup.
+	// n > 1 must also fit every choice in what is left.
+	limitMessage, resetErr := s.checkTokenLimits(r.Context(), auth)
+	if resetErr == nil && limitMessage == "" {
+		messages, tools := s.buildProviderMessages(chain[0].info.providerType, req.Messages, req.Tools)
+		limitMessage, resetErr = s.checkChoicesBudget(r.Context(), auth, chain[0], messages, tools, buildChatOptions(&req))
+	}
+	if resetErr != nil {
 		slog.Error("token limit check failed", "error", resetErr)
 	} else if limitMessage != "" {
 		httpResponseJSON(respW, map[string]any{
@@ -209,6 +260,25 @@ func (s *Server) ChatCompletions(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Response cache: replay an identical earlier answer. It runs after the
+	// budget check because the semantic probe is a paid embedding call.
+	cacheMode := s.responseCacheModeFor(r, auth, service.ResponseCacheKindChat, rawBody)
+	var semProbe *semanticCacheProbe
+	if cacheMode.enabled() {
+		respW.Header().Set("x-at-cache", "miss")
+		if cached, ok := s.lookupResponseCache(r.Context(), cacheMode); ok && s.serveCachedChat(respW, r, auth, &req, cached, "hit") {
+			s.maybeStoreIdempotent(idempKey, cap, w)
+			return
+		}
+		// Semantic layer: near-duplicate of an earlier prompt in the same
+		// scope. The probe is kept so a fresh answer is indexed too.
+		semProbe = s.semanticCacheProbeFor(r.Context(), auth, cacheMode, &req)
+		if cached, ok := s.lookupSemanticCache(r.Context(), cacheMode, semProbe); ok && s.serveCachedChat(respW, r, auth, &req, cached, "semantic") {
+			s.maybeStoreIdempotent(idempKey, cap, w)
+			return
+		}
+	}
+
 	// B6: timeout — applied uniformly across all fallback attempts.
 	callCtx, cancel := withRequestTimeout(r.Context(), req.TimeoutMs)
 	defer cancel()
@@ -217,16 +287,51 @@ func (s *Server) ChatCompletions(w http.ResponseWriter, r *http.Request) {
 	// attempt to avoid cross-attempt mutation.
 	baseOpts := buildChatOptions(&req)
 
+	// Both hedge attempts are recorded under one trace.
+	var hedge [2]hedgeAttempt
+	hedged := false
+	if req.AtHedge != nil {
+		hedge, hedged = s.prepareHedge(callCtx, &req, chain, baseOpts)
+		if hedged && traceID == "" {
+			traceID = traceIDFor(r.Context())
+		}
+	}
+
 	if req.Stream {
+		if hedged {
+			audit := streamAuditCtx{
+				auth: auth, endpoint: r.URL.Path,
+				traceID: traceID, sessionID: sessionID, userField: req.User,
+				requestBody: rawBody, requestedModel: req.Model,
+			}
+			if cacheMode.enabled() {
+				audit.onComplete = func(respBody []byte) {
+					s.storeResponseCache(r.Context(), cacheMode, service.ResponseCacheKindChat, req.Model, respBody, semProbe)
+				}
+			}
+			s.hedgedChatStream(w, r.WithContext(callCtx), auth, hedge, req.AtHedge.delay(), req.StreamOptions, audit)
+			return
+		}
+
 		// Streaming path: no fallback. Use the primary only.
 		target := chain[0]
 		messages, tools := s.buildProviderMessages(target.info.providerType, req.Messages, req.Tools)
 		opts := cloneChatOptions(baseOpts)
+		if err := s.adaptToModelCapabilities(callCtx, &target, messages, tools, opts, true); err != nil {
+			status, body := classifyGatewayError(err)
+			httpResponseJSON(w, body, status)
+			return
+		}
 		audit := streamAuditCtx{
 			auth: auth, endpoint: r.URL.Path,
 			traceID: traceID, sessionID: sessionID, userField: req.User,
 			requestBody: rawBody, requestedModel: req.Model,
 		}
+		if cacheMode.enabled() {
+			audit.onComplete = func(respBody []byte) {
+				s.storeResponseCache(r.Context(), cacheMode, service.ResponseCacheKindChat, target.fullModel, respBody, semProbe)
+			}
+		}
 		s.handleStreamingChat(w, r.WithContext(callCtx), auth, target.info.provider, target.info.RetryAfterCap(),
 			target.providerKey, target.actualModel, target.fullModel, messages, tools, req.StreamOptions, opts, audit)
 		return
@@ -238,39 +343,64 @@ func (s *Server) ChatCompletions(w http.ResponseWriter, r *http.Request) {
 		used         chatCallTarget
 		resp         *service.LLMResponse
 		totalLatency int64
+		hedgeMeta    map[string]any
 	)
-	for i, target := range chain {
-		if target.err != nil {
-			continue
-		}
-		messages, tools := s.buildProviderMessages(target.info.providerType, req.Messages, req.Tools)
-		opts := cloneChatOptions(baseOpts)
-
-		callStart := time.Now()
-		r2, err := callWithGatewayRetry(callCtx, target.providerKey, target.actualModel,
-			target.info.RetryAfterCap(),
-			func(ctx context.Context) (*service.LLMResponse, error) {
-				return target.info.provider.Chat(ctx, target.actualModel, messages, tools, opts)
-			})
-		totalLatency += time.Since(callStart).Milliseconds()
-		if err == nil {
-			resp = r2
-			used = target
-			break
-		}
-		lastErr = err
-		slog.Warn("provider chat failed",
-			"attempt", i, "provider", target.providerKey, "model", target.actualModel, "error", err)
-		s.recordUsageAsync(r.Context(), auth, target.fullModel, service.Usage{}, totalLatency, "error", classifyHTTPError(err), err.Error())
-		s.recordLLMCallAsync(r.Context(), llmAuditParams{
+	if hedged {
+		var stats hedgeStats
+		resp, stats = s.hedgedChat(callCtx, hedge, req.AtHedge.delay(), llmAuditParams{
 			auth: auth, source: "gateway", endpoint: r.URL.Path,
 			traceID: traceID, sessionID: sessionID, userField: req.User,
-			requestBody: rawBody, requestedModel: req.Model, fullModel: target.fullModel,
-			latencyMs: totalLatency, status: "error",
-			errCode: classifyHTTPError(err), errMsg: err.Error(),
+			requestBody: rawBody, requestedModel: req.Model,
 		})
-		if !shouldFallback(err) {
-			break
+		if stats.winner >= 0 {
+			used = hedge[stats.winner].target
+			totalLatency = stats.latency[stats.winner]
+			hedgeMeta = stats.metadata(hedge, stats.winner)
+			respW.Header().Set("x-at-hedge-winner", hedge[stats.winner].role)
+		}
+		lastErr = stats.lastErr()
+	} else {
+		for i, target := range chain {
+			if target.err != nil {
+				continue
+			}
+			messages, tools := s.buildProviderMessages(target.info.providerType, req.Messages, req.Tools)
+			opts := cloneChatOptions(baseOpts)
+			if err := s.adaptToModelCapabilities(callCtx, &target, messages, tools, opts, false); err != nil {
+				// A fallback that can serve the request may still succeed.
+				lastErr = err
+				continue
+			}
+
+			callStart := time.Now()
+			r2, err := chatChoices(callCtx, target.info.provider, opts,
+				func(ctx context.Context, opts *service.ChatOptions) (*service.LLMResponse, error) {
+					return callWithGatewayRetry(ctx, target.providerKey, target.actualModel,
+						target.info.RetryAfterCap(),
+						func(ctx context.Context) (*service.LLMResponse, error) {
+							return target.info.provider.Chat(ctx, target.actualModel, messages, tools, opts)
+						})
+				})
+			totalLatency += time.Since(callStart).Milliseconds()
+			if err == nil {
+				resp = r2
+				used = target
+				break
+			}
+			lastErr = err
+			slog.Warn("provider chat failed",
+				"attempt", i, "provider", target.providerKey, "model", target.actualModel, "error", err)
+			s.recordUsageAsync(r.Context(), auth, target.fullModel, choicesUsage(err), totalLatency, "error", classifyHTTPError(err), err.Error())
+			s.recordLLMCallAsync(r.Context(), llmAuditParams{
+				auth: auth, source: "gateway", endpoint: r.URL.Path,
+				traceID: traceID, sessionID: sessionID, userField: req.User,
+				requestBody: rawBody, requestedModel: req.Model, fullModel: target.fullModel,
+				latencyMs: totalLatency, status: "error",
+				errCode: classifyHTTPError(err), errMsg: err.Error(),
+			})
+			if !shouldFallback(err) {
+				break
+			}
 		}
 	}
 
@@ -300,14 +430,26 @@ func (s *Server) ChatCompletions(w http.ResponseWriter, r *http.Request) {
 	}
 
 	s.recordUsageAsync(r.Context(), auth, used.fullModel, resp.Usage, totalLatency, "ok", "", "")
+	chatResp, err = s.applyResponseHooks(r.Context(), auth, hooks, &req, chatResp)
+	if err != nil {
+		status, body := classifyGatewayError(err)
+		httpResponseJSON(respW, body, status)
+		s.maybeStoreIdempotent(idempKey, cap, w)
+		return
+	}
 	if respBody, err := json.Marshal(chatResp); err == nil {
+		// Answers from a fallback model are not cached: the next identical
+		// request should try the primary again.
+		if used.fullModel == req.Model {
+			s.storeResponseCache(r.Context(), cacheMode, service.ResponseCacheKindChat, used.fullModel, respBody, semProbe)
+		}
 		s.recordLLMCallAsync(r.Context(), llmAuditParams{
 			auth: auth, source: "gateway", endpoint: r.URL.Path,
 			traceID: traceID, sessionID: sessionID, userField: req.User,
 			requestBody: rawBody, responseBody: respBody,
 			requestedModel: req.Model, fullModel: used.fullModel,
 			usage: resp.Usage, latencyMs: totalLatency, status: "ok",
-			finishReason: chatRespFinishReason(chatResp),
+			finishReason: chatRespFinishReason(chatResp), metadata: hedgeMeta,
 		})
 	}
 	httpResponseJSON(respW, chatResp, http.StatusOK)
@@ -319,7 +461,7 @@ func (s *Server) ChatCompletions(w http.ResponseWriter, r *http.Request) {
 func (s *Server) buildProviderMessages(providerType string, msgs []OpenAIMessage, tools []OpenAITool) ([]service.Message, []service.Tool) {
 	tt := translateOpenAITools(tools)
 	switch providerType {
-	case "anthropic", "minimax", "bedrock":
+	case "anthropic", "vertex-anthropic", "minimax", "bedrock":
 		// Bedrock's Converse API uses an Anthropic-style content-block
 		// shape, so we reuse the same translator. The bedrock adapter
 		// converts service.ContentBlock to Converse blocks internally.
@@ -458,9 +600,9 @@ func (s *Server) ProxyRequest(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Forward request
-	if sender, ok := info.provider.(interface {
+	if sender, ok := service.As[interface {
 		Proxy(w http.ResponseWriter, r *http.Request, path string) error
-	}); ok {
+	}](info.provider); ok {
 		if err := sender.Proxy(w, r, proxyPath); err != nil {
 			slog.Error("proxy request failed", "provider", providerKey, "path", proxyPath, "error", err)
 			httpResponseJSON(w, map[string]any{
@@ -499,6 +641,8 @@ func (s *Server) ListModels(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	capabilities := s.modelCapabilities(r.Context())
+
 	var models []ModelData
 	s.providerMu.RLock()
 	for key, info := range s.providers {
@@ -510,11 +654,15 @@ func (s *Server) ListModels(w http.ResponseWriter, r *http.Request) {
 			seen[m] = true
 			fullID := key + "/" + m
 			if auth.isModelAllowed(key, fullID) {
-				models = append(models, ModelData{
+				md := ModelData{
 					ID:      fullID,
 					Object:  "model",
 					OwnedBy: key,
-				})
+				}
+				if mc, ok := capabilities[modelCapabilityKey(key, m)]; ok {
+					applyModelCapabilityData(&md, mc)
+				}
+				models = append(models, md)
 			}
 		}
 
@@ -744,7 +892,7 @@ func (s *Server) handleStreamingChat(
 	includeUsage := streamOpts != nil && streamOpts.IncludeUsage
 
 	// Try true streaming if the provider supports it.
-	if sp, ok := provider.(service.LLMStreamProvider); ok {
+	if sp, ok := service.As[service.LLMStreamProvider](provider); ok {
 		slog.Debug("streaming via provider", "provider", providerKey, "model", actualModel)
 
 		// Retry the upstream connect on 429/529. The retry only applies
@@ -754,6 +902,9 @@ func (s *Server) handleStreamingChat(
 		// practice, upstream returns 429/529 on the initial response,
 		// not in the middle of a token stream.)
 		streamStart := time.Now()
+		if !audit.startedAt.IsZero() {
+			streamStart = audit.startedAt
+		}
 		type streamOpenResult struct {
 			chunks  <-chan service.StreamChunk
 			headers http.Header
@@ -771,7 +922,7 @@ func (s *Server) handleStreamingChat(
 			s.recordLLMCallAsync(r.Context(), llmAuditParams{
 				auth: auth, source: audit.resolveSource(), endpoint: audit.endpoint,
 				traceID: audit.traceID, sessionID: audit.sessionID, userField: audit.userField,
-				requestBody: audit.requestBody, requestedModel: audit.requestedModel, fullModel: fullModel,
+				requestBody: audit.requestBody, requestedModel: audit.requestedModel, fullModel: fullModel, metadata: audit.metadata,
 				latencyMs: time.Since(streamStart).Milliseconds(), streamed: true, status: "error",
 				errCode: classifyHTTPError(err), errMsg: err.Error(),
 			})
@@ -824,7 +975,7 @@ func (s *Server) handleStreamingChat(
 				s.recordLLMCallAsync(r.Context(), llmAuditParams{
 					auth: auth, source: audit.resolveSource(), endpoint: audit.endpoint,
 					traceID: audit.traceID, sessionID: audit.sessionID, userField: audit.userField,
-					requestBody: audit.requestBody, requestedModel: audit.requestedModel, fullModel: fullModel,
+					requestBody: audit.requestBody, requestedModel: audit.requestedModel, fullModel: fullModel, metadata: audit.metadata,
 					responseBody: streamAuditResponseBody(chatID, fullModel, auditContent.String(), auditReasoning.String(), auditToolCalls, "error", streamUsage),
 					usage:        usageOrZero(streamUsage), latencyMs: time.Since(streamStart).Milliseconds(), ttftMs: ttftMs,
 					streamed: true, status: "error", errCode: "provider_error", errMsg: fmt.Sprintf("%v", chunk.Error),
@@ -938,14 +1089,18 @@ func (s *Server) handleStreamingChat(
 		if streamUsage != nil {
 			s.recordUsageAsync(r.Context(), auth, fullModel, *streamUsage, time.Since(streamStart).Milliseconds(), "ok", "", "")
 		}
+		respBody := streamAuditResponseBody(chatID, fullModel, auditContent.String(), auditReasoning.String(), auditToolCalls, auditFinish, streamUsage)
 		s.recordLLMCallAsync(r.Context(), llmAuditParams{
 			auth: auth, source: audit.resolveSource(), endpoint: audit.endpoint,
 			traceID: audit.traceID, sessionID: audit.sessionID, userField: audit.userField,
-			requestBody: audit.requestBody, requestedModel: audit.requestedModel, fullModel: fullModel,
-			responseBody: streamAuditResponseBody(chatID, fullModel, auditContent.String(), auditReasoning.String(), auditToolCalls, auditFinish, streamUsage),
+			requestBody: audit.requestBody, requestedModel: audit.requestedModel, fullModel: fullModel, metadata: audit.metadata,
+			responseBody: respBody,
 			usage:        usageOrZero(streamUsage), latencyMs: time.Since(streamStart).Milliseconds(), ttftMs: ttftMs,
 			streamed: true, status: "ok", finishReason: auditFinish,
 		})
+		if audit.onComplete != nil {
+			audit.onComplete(respBody)
+		}
 	} else {
 		// Fallback: fake streaming via non-streaming Chat call. Same
 		// retry semantics as the non-streaming path above — bounded
@@ -964,7 +1119,7 @@ func (s *Server) handleStreamingChat(
 			s.recordLLMCallAsync(r.Context(), llmAuditParams{
 				auth: auth, source: audit.resolveSource(), endpoint: audit.endpoint,
 				traceID: audit.traceID, sessionID: audit.sessionID, userField: audit.userField,
-				requestBody: audit.requestBody, requestedModel: audit.requestedModel, fullModel: fullModel,
+				requestBody: audit.requestBody, requestedModel: audit.requestedModel, fullModel: fullModel, metadata: audit.metadata,
 				latencyMs: fakeLatencyMs, streamed: true, status: "error",
 				errCode: classifyHTTPError(err), errMsg: err.Error(),
 			})
@@ -1079,6 +1234,9 @@ func (s *Server) handleStreamingChat(
 				usage: resp.Usage, latencyMs: fakeLatencyMs, streamed: true, status: "ok",
 				finishReason: normalizeFinishReason(resp),
 			})
+			if audit.onComplete != nil {
+				audit.onComplete(respBody)
+			}
 		}
 	}
 
@@ -1258,17 +1416,35 @@ func (s *Server) shouldResetUsage(token *service.APIToken) bool {
 // latencyMs, status, errCode, and errMsg are best-effort — callers pass zero /
 // empty when they don't have the info (the summary response usually does).
 func (s *Server) recordUsageAsync(ctx context.Context, auth *authResult, fullModel string, usage service.Usage, latencyMs int64, status, errCode, errMsg string) {
-	if auth == nil || auth.token == nil || auth.token.ID == "" {
-		return // config token or unrestricted — no tracking
-	}
-	tokenID := auth.token.ID
+	s.recordScaledUsageAsync(ctx, auth, fullModel, usage, 1, latencyMs, status, errCode, errMsg)
+}
 
+// recordScaledUsageAsync is recordUsageAsync with the estimated cost
+// multiplied by costScale, for calls billed below list price (provider
+// batch APIs).
+func (s *Server) recordScaledUsageAsync(ctx context.Context, auth *authResult, fullModel string, usage service.Usage, costScale float64, latencyMs int64, status, errCode, errMsg string) {
 	// Default successful status if caller left it empty.
 	if status == "" {
 		status = "ok"
 	}
-	// Skip entirely if we have nothing to record.
 	hasUsage := usage.TotalTokenCount() > 0 || usage.PromptTokens > 0 || usage.CompletionTokens > 0 || usage.CacheReadTokens > 0 || usage.CacheWriteTokens > 0
+	providerKey, actualModel := splitProviderModel(fullModel)
+
+	// Cost is needed by cost_events and the metrics; estimate it once.
+	var costCents float64
+	if hasUsage && (s.costEventStore != nil || metrics.Default.Enabled()) {
+		costCents = s.estimateGatewayUsageCostCents(context.WithoutCancel(ctx), providerKey, actualModel, fullModel, usage) * costScale
+	}
+
+	// Metrics cover every gateway call, including config tokens.
+	observeGatewayRequest(auth, fullModel, usage, costCents, latencyMs, status)
+
+	if auth == nil || auth.token == nil || auth.token.ID == "" {
+		return // config token or unrestricted — no tracking
+	}
+	tokenID := auth.token.ID
+
+	// Skip entirely if we have nothing to record.
 	if !hasUsage && status == "ok" {
 		return
 	}
@@ -1284,13 +1460,6 @@ func (s *Server) recordUsageAsync(ctx context.Context, auth *authResult, fullMod
 
 	// 2. cost_events: per-call row with latency/status for the usage dashboard.
 	if s.costEventStore != nil {
-		providerKey, actualModel := splitProviderModel(fullModel)
-
-		var costCents float64
-		if hasUsage {
-			costCents = s.estimateGatewayUsageCostCents(context.WithoutCancel(ctx), providerKey, actualModel, fullModel, usage)
-		}
-
 		// There is no billing_code on APIToken today; use the token Name as a
 		// human-readable attribution tag for gateway-originated calls.
 		billingCode := ""
@@ -1302,6 +1471,7 @@ func (s *Server) recordUsageAsync(ctx context.Context, auth *authResult, fullMod
 			if err := s.costEventStore.RecordCostEvent(context.WithoutCancel(ctx), service.CostEvent{
 				AgentID:          "gateway:" + tokenID, // gateway calls have no agent; tag with token ID
 				Provider:         providerKey,
+				APIKey:           usage.APIKey,
 				Model:            actualModel,
 				BillingCode:      billingCode,
 				InputTokens:      int64(usage.PromptTokens),
@@ -25,6 +25,13 @@ func (m *mockGatewayTokenStore) ListAPITokens(context.Context, *query.Query) (*s
 	return nil, nil
 }
 
+func (m *mockGatewayTokenStore) GetAPIToken(_ context.Context, id string) (*service.APIToken, error) {
+	if m.token != nil && m.token.ID == id {
+		return m.token, nil
+	}
+	return nil, nil
+}
+
 func (m *mockGatewayTokenStore) GetAPITokenByHash(_ context.Context, hash string) (*service.APIToken, error) {
 	if hash == m.tokenHash {
 		return m.token, nil
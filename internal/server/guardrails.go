@@ -0,0 +1,986 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/rakunlabs/at/internal/service"
+	"github.com/rakunlabs/at/internal/service/llm/common"
+	"github.com/rakunlabs/query"
+)
+
+// ─── Guardrails ───
+//
+// Guardrail policies (service.GuardrailPolicy) are attached by ID to API
+// tokens, agents and bots. The provider of every chat call made on their
+// behalf is wrapped in a guardedProvider, which runs the input checks
+// before the upstream call — redacting matches in a copy of the messages
+// or rejecting the call — and the output checks on the answer. Every
+// violation is recorded as an "event" observation named "guardrail" with
+// level warning (redacted / warned) or error (blocked).
+//
+// Streams are forwarded as they arrive; output checks run when the finish
+// chunk shows up and a blocked answer ends the stream with an error.
+//
+// Gateway calls that send a token's content upstream outside a chat call
+// (native token counting, embeddings) run the input checks through
+// guardTokenInput. Paths that cannot be checked are not taken for tokens
+// with guardrails: the semantic cache probe is skipped and batches run on
+// the gateway workers instead of a provider batch API.
+
+// guardrailCacheTTL bounds how long a loaded policy is reused before the
+// store is read again. Updates and deletes through the API evict at once.
+const guardrailCacheTTL = 30 * time.Second
+
+// guardrailError is a call rejected by a guardrail policy. It maps to a 400
+// in classifyGatewayError and does not trigger fallbacks.
+type guardrailError struct {
+	policy  string
+	stage   string // "input" | "output"
+	check   string
+	message string
+}
+
+func (e *guardrailError) Error() string { return e.message }
+
+// guardrailScope carries the attribution recorded on violation
+// observations.
+type guardrailScope struct {
+	auth      *authResult
+	source    string
+	fullModel string
+	sessionID string
+	agentID   string
+	orgID     string
+}
+
+// compiledGuardrail is a loaded policy with its patterns compiled.
+type compiledGuardrail struct {
+	policy   service.GuardrailPolicy
+	patterns []*regexp.Regexp // parallel to policy.Config.Input.Patterns
+	loadedAt time.Time
+}
+
+// ─── PII detection ───
+
+type piiDetector struct {
+	re    *regexp.Regexp
+	valid func(match string) bool
+}
+
+var guardrailPIIDetectors = map[string]piiDetector{
+	service.GuardrailPIIEmail: {
+		re: regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`),
+	},
+	service.GuardrailPIIPhone: {
+		re:    regexp.MustCompile(`\+?\(?\d{1,4}\)?[ \-]?\(?\d{2,4}\)?[ \-]?\d{3,4}[ \-]?\d{3,4}`),
+		valid: func(m string) bool { n := countDigits(m); return n >= 9 && n <= 15 },
+	},
+	service.GuardrailPIICreditCard: {
+		re:    regexp.MustCompile(`\b(?:\d[ \-]?){12,18}\d\b`),
+		valid: luhnValid,
+	},
+	service.GuardrailPIISSN: {
+		re: regexp.MustCompile(`\b\d{3}-\d{2}-\d{4}\b`),
+	},
+	service.GuardrailPIIIBAN: {
+		re:    regexp.MustCompile(`\b[A-Z]{2}\d{2}(?: ?[A-Z0-9]){11,30}\b`),
+		valid: ibanValid,
+	},
+	service.GuardrailPIIIPAddress: {
+		re: regexp.MustCompile(`\b(?:(?:25[0-5]|2[0-4]\d|1?\d?\d)\.){3}(?:25[0-5]|2[0-4]\d|1?\d?\d)\b|\b(?:[0-9A-Fa-f]{1,4}:){7}[0-9A-Fa-f]{1,4}\b`),
+	},
+	service.GuardrailPIIAPIKey: {
+		re: regexp.MustCompile(`\b(?:sk-(?:ant-|proj-)?[A-Za-z0-9_\-]{20,}|AKIA[0-9A-Z]{16}|gh[pousr]_[A-Za-z0-9]{36,}|xox[abpr]-[A-Za-z0-9\-]{10,}|AIza[0-9A-Za-z_\-]{35})`),
+	},
+}
+
+func countDigits(s string) int {
+	n := 0
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			n++
+		}
+	}
+	return n
+}
+
+func luhnValid(s string) bool {
+	sum, n := 0, 0
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if n%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		n++
+	}
+	return n >= 13 && n <= 19 && sum%10 == 0
+}
+
+func ibanValid(s string) bool {
+	s = strings.ReplaceAll(s, " ", "")
+	if len(s) < 15 || len(s) > 34 {
+		return false
+	}
+	var digits strings.Builder
+	for _, r := range s[4:] + s[:4] {
+		switch {
+		case r >= '0' && r <= '9':
+			digits.WriteRune(r)
+		case r >= 'A' && r <= 'Z':
+			fmt.Fprintf(&digits, "%d", r-'A'+10)
+		default:
+			return false
+		}
+	}
+	n, ok := new(big.Int).SetString(digits.String(), 10)
+	return ok && new(big.Int).Mod(n, big.NewInt(97)).Int64() == 1
+}
+
+// ─── Policy loading ───
+
+// loadGuardrails returns the enabled policies among ids, in order. Unknown
+// IDs are skipped with a warning; store errors fail the call.
+func (s *Server) loadGuardrails(ctx context.Context, ids []string) ([]*compiledGuardrail, error) {
+	if s.guardrailStore == nil || len(ids) == 0 {
+		return nil, nil
+	}
+
+	out := make([]*compiledGuardrail, 0, len(ids))
+	for _, id := range ids {
+		if v, ok := s.guardrailCache.Load(id); ok {
+			if g := v.(*compiledGuardrail); time.Since(g.loadedAt) < guardrailCacheTTL {
+				if g.policy.Enabled {
+					out = append(out, g)
+				}
+				continue
+			}
+		}
+
+		p, err := s.guardrailStore.GetGuardrailPolicy(ctx, id)
+		if err != nil {
+			return nil, fmt.Errorf("load guardrail policy %q: %w", id, err)
+		}
+		if p == nil {
+			slog.Warn("guardrail policy not found; skipping", "id", id)
+			continue
+		}
+		g, err := compileGuardrail(*p)
+		if err != nil {
+			return nil, err
+		}
+		s.guardrailCache.Store(id, g)
+		if g.policy.Enabled {
+			out = append(out, g)
+		}
+	}
+
+	return out, nil
+}
+
+func compileGuardrail(p service.GuardrailPolicy) (*compiledGuardrail, error) {
+	g := &compiledGuardrail{policy: p, loadedAt: time.Now()}
+	for _, pat := range p.Config.Input.Patterns {
+		re, err := regexp.Compile(pat.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("guardrail policy %q: pattern %q: %w", p.Name, pat.Name, err)
+		}
+		g.patterns = append(g.patterns, re)
+	}
+	return g, nil
+}
+
+// validateGuardrailPolicy returns a problem description, or "" when the
+// policy is valid.
+func validateGuardrailPolicy(p service.GuardrailPolicy) string {
+	if strings.TrimSpace(p.Name) == "" {
+		return "name is required"
+	}
+
+	in := p.Config.Input
+	for _, rule := range in.PII {
+		if _, ok := guardrailPIIDetectors[rule.Entity]; !ok {
+			return fmt.Sprintf("unknown pii entity %q", rule.Entity)
+		}
+		if !slices.Contains([]string{"", service.GuardrailActionRedact, service.GuardrailActionBlock, service.GuardrailActionWarn}, rule.Action) {
+			return fmt.Sprintf("invalid action %q for pii entity %q", rule.Action, rule.Entity)
+		}
+	}
+	for _, pat := range in.Patterns {
+		if pat.Name == "" {
+			return "pattern name is required"
+		}
+		if _, err := regexp.Compile(pat.Pattern); err != nil {
+			return fmt.Sprintf("invalid pattern %q: %v", pat.Name, err)
+		}
+		if !slices.Contains([]string{"", service.GuardrailActionRedact, service.GuardrailActionBlock, service.GuardrailActionWarn}, pat.Action) {
+			return fmt.Sprintf("invalid action %q for pattern %q", pat.Action, pat.Name)
+		}
+	}
+	for _, m := range []*service.GuardrailModeration{in.Moderation, p.Config.Output.Moderation} {
+		if m == nil {
+			continue
+		}
+		if _, _, err := parseModelID(m.Model); err != nil {
+			return fmt.Sprintf("moderation model: %v", err)
+		}
+		if !slices.Contains([]string{"", service.GuardrailActionBlock, service.GuardrailActionWarn}, m.Action) {
+			return fmt.Sprintf("invalid moderation action %q", m.Action)
+		}
+	}
+	if !slices.Contains([]string{"", service.GuardrailActionBlock, service.GuardrailActionWarn}, p.Config.Output.JSONAction) {
+		return fmt.Sprintf("invalid json_action %q", p.Config.Output.JSONAction)
+	}
+
+	return ""
+}
+
+// ─── Provider wrapping ───
+
+// guardedProvider runs guardrail policies around Chat.
+type guardedProvider struct {
+	service.LLMProvider
+	s     *Server
+	ids   []string
+	scope guardrailScope
+}
+
+// guardedStreamProvider adds a guarded ChatStream for providers that
+// stream natively.
+type guardedStreamProvider struct {
+	guardedProvider
+	stream service.LLMStreamProvider
+}
+
+// withGuardrails wraps provider so the policies in ids apply to its chat
+// calls. It returns provider unchanged when there is nothing to apply.
+func (s *Server) withGuardrails(provider service.LLMProvider, ids []string, scope guardrailScope) service.LLMProvider {
+	if s.guardrailStore == nil || len(ids) == 0 || provider == nil {
+		return provider
+	}
+	g := guardedProvider{LLMProvider: provider, s: s, ids: ids, scope: scope}
+	if sp, ok := service.As[service.LLMStreamProvider](provider); ok {
+		return guardedStreamProvider{guardedProvider: g, stream: sp}
+	}
+	return g
+}
+
+// tokenGuardrails applies the policies attached to the caller's API token
+// to a gateway call target.
+func (s *Server) tokenGuardrails(auth *authResult, target *chatCallTarget, source string) {
+	if auth == nil || auth.token == nil || len(auth.token.GuardrailIDs) == 0 || target.err != nil {
+		return
+	}
+	target.info.provider = s.withGuardrails(target.info.provider, auth.token.GuardrailIDs, guardrailScope{
+		auth: auth, source: source, fullModel: target.fullModel,
+	})
+}
+
+// hasTokenGuardrails reports whether the caller's API token has guardrail
+// policies attached.
+func hasTokenGuardrails(auth *authResult) bool {
+	return auth != nil && auth.token != nil && len(auth.token.GuardrailIDs) > 0
+}
+
+// guardTokenInput runs the input checks of the caller's token policies on
+// messages sent upstream outside a chat call and returns the (possibly
+// redacted) copy.
+func (s *Server) guardTokenInput(ctx context.Context, auth *authResult, source, fullModel string, messages []service.Message, tools []service.Tool) ([]service.Message, error) {
+	if s.guardrailStore == nil || !hasTokenGuardrails(auth) {
+		return messages, nil
+	}
+	policies, err := s.loadGuardrails(ctx, auth.token.GuardrailIDs)
+	if err != nil {
+		return nil, err
+	}
+	return s.checkGuardrailInput(ctx, policies, guardrailScope{auth: auth, source: source, fullModel: fullModel}, messages, tools)
+}
+
+func (g guardedProvider) Chat(ctx context.Context, model string, messages []service.Message, tools []service.Tool, opts *service.ChatOptions) (*service.LLMResponse, error) {
+	policies, err := g.s.loadGuardrails(ctx, g.ids)
+	if err != nil {
+		return nil, err
+	}
+	messages, err = g.s.checkGuardrailInput(ctx, policies, g.scope, messages, tools)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := g.LLMProvider.Chat(ctx, model, messages, tools, opts)
+	if err != nil || resp == nil {
+		return resp, err
+	}
+
+	// Native n > 1 answers carry the other choices in ExtraChoices; each
+	// is checked like the first.
+	for _, choice := range append([]service.LLMResponse{*resp}, resp.ExtraChoices...) {
+		if err := g.s.checkGuardrailOutput(ctx, policies, g.scope, choice.Content, len(choice.ToolCalls) > 0, opts); err != nil {
+			return nil, err
+		}
+	}
+
+	return resp, nil
+}
+
+// NativeChoices implements service.NativeChoicesProvider for the wrapped
+// provider, so guarded calls keep native n > 1.
+func (g guardedProvider) NativeChoices() bool {
+	nc, ok := service.As[service.NativeChoicesProvider](g.LLMProvider)
+	return ok && nc.NativeChoices()
+}
+
+func (g guardedStreamProvider) ChatStream(ctx context.Context, model string, messages []service.Message, tools []service.Tool, opts *service.ChatOptions) (<-chan service.StreamChunk, http.Header, error) {
+	policies, err := g.s.loadGuardrails(ctx, g.ids)
+	if err != nil {
+		return nil, nil, err
+	}
+	messages, err = g.s.checkGuardrailInput(ctx, policies, g.scope, messages, tools)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ch, header, err := g.stream.ChatStream(ctx, model, messages, tools, opts)
+	if err != nil || !hasOutputGuardrails(policies, opts) {
+		return ch, header, err
+	}
+
+	out := make(chan service.StreamChunk)
+	go func() {
+		defer close(out)
+
+		var (
+			text      strings.Builder
+			toolCalls bool
+			checked   bool
+			blocked   bool
+		)
+		check := func() error {
+			checked = true
+			return g.s.checkGuardrailOutput(ctx, policies, g.scope, text.String(), toolCalls, opts)
+		}
+
+		for chunk := range ch {
+			if blocked {
+				continue // drain so the producer can finish
+			}
+			text.WriteString(chunk.Content)
+			if len(chunk.ToolCalls) > 0 {
+				toolCalls = true
+			}
+			if chunk.FinishReason != "" && chunk.Error == nil && !checked {
+				if err := check(); err != nil {
+					blocked = true
+					out <- service.StreamChunk{Error: err}
+					continue
+				}
+			}
+			out <- chunk
+		}
+
+		if !checked && !blocked {
+			if err := check(); err != nil {
+				out <- service.StreamChunk{Error: err}
+			}
+		}
+	}()
+
+	return out, header, nil
+}
+
+func (g guardedStreamProvider) Proxy(w http.ResponseWriter, r *http.Request, path string) error {
+	return g.stream.Proxy(w, r, path)
+}
+
+func hasOutputGuardrails(policies []*compiledGuardrail, opts *service.ChatOptions) bool {
+	for _, g := range policies {
+		out := g.policy.Config.Output
+		if out.Moderation != nil || out.RequireJSON || wantsJSONResponse(opts) {
+			return true
+		}
+	}
+	return false
+}
+
+func wantsJSONResponse(opts *service.ChatOptions) bool {
+	if opts == nil {
+		return false
+	}
+	t, _ := opts.ResponseFormat["type"].(string)
+	return t == "json_object" || t == "json_schema"
+}
+
+// ─── Checks ───
+
+// checkGuardrailInput runs the input checks of every policy in order and
+// returns the (possibly redacted) messages. The caller's slice is never
+// modified.
+func (s *Server) checkGuardrailInput(ctx context.Context, policies []*compiledGuardrail, scope guardrailScope, messages []service.Message, tools []service.Tool) ([]service.Message, error) {
+	for _, g := range policies {
+		in := g.policy.Config.Input
+
+		for _, rule := range in.PII {
+			det := guardrailPIIDetectors[rule.Entity]
+			replacement := rule.Replacement
+			if replacement == "" {
+				replacement = "[REDACTED_" + strings.ToUpper(rule.Entity) + "]"
+			}
+			var err error
+			messages, err = s.applyGuardrailMatcher(ctx, g, scope, messages, "pii:"+rule.Entity, rule.Action, replacement,
+				func(text string) [][]int { return filterMatches(text, det.re.FindAllStringIndex(text, -1), det.valid) })
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		for i, pat := range in.Patterns {
+			re := g.patterns[i]
+			replacement := pat.Replacement
+			if replacement == "" {
+				replacement = "[REDACTED]"
+			}
+			var err error
+			messages, err = s.applyGuardrailMatcher(ctx, g, scope, messages, "pattern:"+pat.Name, pat.Action, replacement,
+				func(text string) [][]int { return re.FindAllStringIndex(text, -1) })
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		texts := guardrailInputTexts(messages)
+
+		if len(in.DenyList) > 0 {
+			for _, text := range texts {
+				lower := strings.ToLower(text)
+				for _, term := range in.DenyList {
+					if term != "" && strings.Contains(lower, strings.ToLower(term)) {
+						return nil, s.guardrailViolation(ctx, g, scope, "input", "deny_list", service.GuardrailActionBlock,
+							fmt.Sprintf("request contains the denied term %q", term))
+					}
+				}
+			}
+		}
+
+		if in.MaxChars > 0 {
+			n := 0
+			for _, text := range texts {
+				n += len([]rune(text))
+			}
+			if n > in.MaxChars {
+				return nil, s.guardrailViolation(ctx, g, scope, "input", "max_chars", service.GuardrailActionBlock,
+					fmt.Sprintf("request has %d characters, over the limit of %d", n, in.MaxChars))
+			}
+		}
+
+		if in.MaxTokens > 0 {
+			if n := common.CountTokensLocal(messages, tools); n > in.MaxTokens {
+				return nil, s.guardrailViolation(ctx, g, scope, "input", "max_tokens", service.GuardrailActionBlock,
+					fmt.Sprintf("request is about %d tokens, over the limit of %d", n, in.MaxTokens))
+			}
+		}
+
+		if in.Moderation != nil && len(texts) > 0 {
+			if err := s.guardrailModerate(ctx, g, scope, "input", in.Moderation, texts); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return messages, nil
+}
+
+// checkGuardrailOutput runs the output checks of every policy on the
+// assistant's text.
+func (s *Server) checkGuardrailOutput(ctx context.Context, policies []*compiledGuardrail, scope guardrailScope, content string, toolCalls bool, opts *service.ChatOptions) error {
+	for _, g := range policies {
+		out := g.policy.Config.Output
+
+		if out.Moderation != nil && strings.TrimSpace(content) != "" {
+			if err := s.guardrailModerate(ctx, g, scope, "output", out.Moderation, []string{content}); err != nil {
+				return err
+			}
+		}
+
+		if (out.RequireJSON || wantsJSONResponse(opts)) && !(toolCalls && strings.TrimSpace(content) == "") {
+			if !json.Valid([]byte(stripJSONFence(content))) {
+				action := out.JSONAction
+				if action == "" {
+					action = service.GuardrailActionBlock
+				}
+				if err := s.guardrailViolation(ctx, g, scope, "output", "json", action, "response is not valid JSON"); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// applyGuardrailMatcher applies one redact/block/warn rule to the scanned
+// messages.
+func (s *Server) applyGuardrailMatcher(
+	ctx context.Context,
+	g *compiledGuardrail,
+	scope guardrailScope,
+	messages []service.Message,
+	check, action, replacement string,
+	find func(text string) [][]int,
+) ([]service.Message, error) {
+	if action == "" {
+		action = service.GuardrailActionRedact
+	}
+
+	count := 0
+	for _, text := range guardrailInputTexts(messages) {
+		count += len(find(text))
+	}
+	if count == 0 {
+		return messages, nil
+	}
+
+	if action == service.GuardrailActionRedact {
+		messages = rewriteGuardrailInput(messages, func(text string) string {
+			locs := find(text)
+			if len(locs) == 0 {
+				return text
+			}
+			var b strings.Builder
+			last := 0
+			for _, loc := range locs {
+				b.WriteString(text[last:loc[0]])
+				b.WriteString(replacement)
+				last = loc[1]
+			}
+			b.WriteString(text[last:])
+			return b.String()
+		})
+	}
+
+	verb := map[string]string{
+		service.GuardrailActionRedact: "redacted",
+		service.GuardrailActionBlock:  "blocked",
+		service.GuardrailActionWarn:   "found",
+	}[action]
+	return messages, s.guardrailViolation(ctx, g, scope, "input", check, action,
+		fmt.Sprintf("%d %s match(es) %s", count, check, verb))
+}
+
+// guardrailModerate runs texts through the policy's moderation provider.
+func (s *Server) guardrailModerate(ctx context.Context, g *compiledGuardrail, scope guardrailScope, stage string, m *service.GuardrailModeration, texts []string) error {
+	action := m.Action
+	if action == "" {
+		action = service.GuardrailActionBlock
+	}
+
+	providerKey, actualModel, err := parseModelID(m.Model)
+	if err != nil {
+		return fmt.Errorf("guardrail policy %q: moderation model: %w", g.policy.Name, err)
+	}
+	info, ok := s.getProviderInfo(providerKey)
+	if !ok {
+		return fmt.Errorf("guardrail policy %q: moderation provider %q not found", g.policy.Name, providerKey)
+	}
+	mod, ok := service.As[service.ModerationProvider](info.provider)
+	if !ok {
+		return fmt.Errorf("guardrail policy %q: provider %q does not support moderations", g.policy.Name, providerKey)
+	}
+
+	resp, err := mod.Moderate(ctx, service.ModerationRequest{Input: texts, Model: actualModel})
+	if err != nil {
+		return fmt.Errorf("guardrail policy %q: moderation: %w", g.policy.Name, err)
+	}
+
+	var flagged []string
+	for _, res := range resp.Results {
+		if !res.Flagged {
+			continue
+		}
+		if len(m.Categories) == 0 {
+			for cat, hit := range res.Categories {
+				if hit && !slices.Contains(flagged, cat) {
+					flagged = append(flagged, cat)
+				}
+			}
+			if len(flagged) == 0 {
+				flagged = append(flagged, "flagged")
+			}
+			continue
+		}
+		for _, cat := range m.Categories {
+			if res.Categories[cat] && !slices.Contains(flagged, cat) {
+				flagged = append(flagged, cat)
+			}
+		}
+	}
+	if len(flagged) == 0 {
+		return nil
+	}
+	slices.Sort(flagged)
+
+	what := "request"
+	if stage == "output" {
+		what = "response"
+	}
+	return s.guardrailViolation(ctx, g, scope, stage, "moderation", action,
+		fmt.Sprintf("%s flagged by moderation: %s", what, strings.Join(flagged, ", ")))
+}
+
+// guardrailViolation records a violation and returns a *guardrailError for
+// block actions, nil otherwise.
+func (s *Server) guardrailViolation(ctx context.Context, g *compiledGuardrail, scope guardrailScope, stage, check, action, detail string) error {
+	level := service.ObservationLevelWarning
+	status := "ok"
+	if action == service.GuardrailActionBlock {
+		level = service.ObservationLevelError
+		status = "error"
+	}
+
+	slog.Info("guardrail violation",
+		"policy", g.policy.Name, "stage", stage, "check", check, "action", action, "model", scope.fullModel)
+
+	s.recordLLMCallAsync(ctx, llmAuditParams{
+		auth: scope.auth, source: scope.source, sessionID: scope.sessionID,
+		obsType: service.ObservationEvent, name: "guardrail",
+		input: detail, level: level,
+		metadata: map[string]any{
+			"policy_id":   g.policy.ID,
+			"policy_name": g.policy.Name,
+			"stage":       stage,
+			"check":       check,
+			"action":      action,
+		},
+		requestedModel: scope.fullModel, fullModel: scope.fullModel,
+		status: status, errCode: "guardrail_violation",
+		agentID: scope.agentID, orgID: scope.orgID,
+	})
+
+	if action != service.GuardrailActionBlock {
+		return nil
+	}
+
+	return &guardrailError{
+		policy:  g.policy.Name,
+		stage:   stage,
+		check:   check,
+		message: fmt.Sprintf("%s blocked by guardrail policy %q: %s", map[string]string{"input": "request", "output": "response"}[stage], g.policy.Name, detail),
+	}
+}
+
+func filterMatches(text string, locs [][]int, valid func(string) bool) [][]int {
+	if valid == nil {
+		return locs
+	}
+	out := locs[:0]
+	for _, loc := range locs {
+		if valid(text[loc[0]:loc[1]]) {
+			out = append(out, loc)
+		}
+	}
+	return out
+}
+
+func stripJSONFence(s string) string {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "```") && strings.HasSuffix(s, "```") && len(s) >= 6 {
+		s = strings.TrimSuffix(s[3:], "```")
+		s = strings.TrimPrefix(s, "json")
+	}
+	return strings.TrimSpace(s)
+}
+
+// ─── Message text access ───
+
+// guardrailScanned reports whether a message role is scanned by input
+// checks. System and developer prompts are operator-authored.
+func guardrailScanned(role string) bool {
+	return role != "system" && role != "developer"
+}
+
+// guardrailInputTexts returns the text pieces of the scanned messages.
+func guardrailInputTexts(messages []service.Message) []string {
+	var texts []string
+	rewriteGuardrailInput(messages, func(text string) string {
+		if text != "" {
+			texts = append(texts, text)
+		}
+		return text
+	})
+	return texts
+}
+
+// rewriteGuardrailInput returns messages with fn applied to every text
+// piece of the scanned messages, in both the content-block form and the
+// OpenAI map form. Messages fn leaves unchanged are shared, changed ones
+// are copied.
+func rewriteGuardrailInput(messages []service.Message, fn func(string) string) []service.Message {
+	var out []service.Message
+	for i, m := range messages {
+		role := m.Role
+		if raw, ok := m.Content.(map[string]any); ok {
+			if r, _ := raw["role"].(string); r != "" {
+				role = r
+			}
+		}
+		if !guardrailScanned(role) {
+			continue
+		}
+
+		content, changed := rewriteGuardrailContent(m.Content, fn)
+		if !changed {
+			continue
+		}
+		if out == nil {
+			out = slices.Clone(messages)
+		}
+		out[i] = service.Message{Role: m.Role, Content: content}
+	}
+	if out == nil {
+		return messages
+	}
+	return out
+}
+
+func rewriteGuardrailContent(content any, fn func(string) string) (any, bool) {
+	switch c := content.(type) {
+	case string:
+		v := fn(c)
+		return v, v != c
+	case []service.ContentBlock:
+		var out []service.ContentBlock
+		for i, b := range c {
+			nb := b
+			nb.Text = fn(b.Text)
+			if b.Type == "tool_result" {
+				nb.Content = fn(b.Content)
+			}
+			if nb.Text == b.Text && nb.Content == b.Content {
+				continue
+			}
+			if out == nil {
+				out = slices.Clone(c)
+			}
+			out[i] = nb
+		}
+		if out == nil {
+			return content, false
+		}
+		return out, true
+	case []any:
+		var out []any
+		for i, p := range c {
+			part, ok := p.(map[string]any)
+			if !ok {
+				continue
+			}
+			text, ok := part["text"].(string)
+			if !ok {
+				continue
+			}
+			v := fn(text)
+			if v == text {
+				continue
+			}
+			if out == nil {
+				out = slices.Clone(c)
+			}
+			np := make(map[string]any, len(part))
+			for k, val := range part {
+				np[k] = val
+			}
+			np["text"] = v
+			out[i] = np
+		}
+		if out == nil {
+			return content, false
+		}
+		return out, true
+	case map[string]any:
+		inner, changed := rewriteGuardrailContent(c["content"], fn)
+		if !changed {
+			return content, false
+		}
+		out := make(map[string]any, len(c))
+		for k, v := range c {
+			out[k] = v
+		}
+		out["content"] = inner
+		return out, true
+	}
+	return content, false
+}
+
+// ─── Guardrail Policy CRUD API ───
+
+// ListGuardrailPoliciesAPI handles GET /api/v1/guardrails.
+func (s *Server) ListGuardrailPoliciesAPI(w http.ResponseWriter, r *http.Request) {
+	if s.guardrailStore == nil {
+		httpResponse(w, "store not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	q, err := query.Parse(r.URL.RawQuery)
+	if err != nil {
+		httpResponse(w, fmt.Sprintf("invalid query: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	records, err := s.guardrailStore.ListGuardrailPolicies(r.Context(), q)
+	if err != nil {
+		slog.Error("list guardrail policies failed", "error", err)
+		httpResponse(w, fmt.Sprintf("failed to list guardrail policies: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	if records == nil {
+		records = &service.ListResult[service.GuardrailPolicy]{Data: []service.GuardrailPolicy{}}
+	}
+
+	httpResponseJSON(w, records, http.StatusOK)
+}
+
+// GetGuardrailPolicyAPI handles GET /api/v1/guardrails/{id}.
+func (s *Server) GetGuardrailPolicyAPI(w http.ResponseWriter, r *http.Request) {
+	if s.guardrailStore == nil {
+		httpResponse(w, "store not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	id := r.PathValue("id")
+	if id == "" {
+		httpResponse(w, "guardrail policy id is required", http.StatusBadRequest)
+		return
+	}
+
+	record, err := s.guardrailStore.GetGuardrailPolicy(r.Context(), id)
+	if err != nil {
+		slog.Error("get guardrail policy failed", "id", id, "error", err)
+		httpResponse(w, fmt.Sprintf("failed to get guardrail policy: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	if record == nil {
+		httpResponse(w, fmt.Sprintf("guardrail policy %q not found", id), http.StatusNotFound)
+		return
+	}
+
+	httpResponseJSON(w, record, http.StatusOK)
+}
+
+// CreateGuardrailPolicyAPI handles POST /api/v1/guardrails.
+func (s *Server) CreateGuardrailPolicyAPI(w http.ResponseWriter, r *http.Request) {
+	if s.guardrailStore == nil {
+		httpResponse(w, "store not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	var req service.GuardrailPolicy
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpResponse(w, fmt.Sprintf("invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if problem := validateGuardrailPolicy(req); problem != "" {
+		httpResponse(w, problem, http.StatusBadRequest)
+		return
+	}
+
+	userEmail := s.getUserEmail(r)
+	req.CreatedBy = userEmail
+	req.UpdatedBy = userEmail
+
+	record, err := s.guardrailStore.CreateGuardrailPolicy(r.Context(), req)
+	if err != nil {
+		if isUniqueViolation(err) {
+			httpResponse(w, fmt.Sprintf("guardrail policy %q already exists", req.Name), http.StatusConflict)
+			return
+		}
+		slog.Error("create guardrail policy failed", "name", req.Name, "error", err)
+		httpResponse(w, fmt.Sprintf("failed to create guardrail policy: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	httpResponseJSON(w, record, http.StatusCreated)
+}
+
+// UpdateGuardrailPolicyAPI handles PUT /api/v1/guardrails/{id}.
+func (s *Server) UpdateGuardrailPolicyAPI(w http.ResponseWriter, r *http.Request) {
+	if s.guardrailStore == nil {
+		httpResponse(w, "store not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	id := r.PathValue("id")
+	if id == "" {
+		httpResponse(w, "guardrail policy id is required", http.StatusBadRequest)
+		return
+	}
+
+	var req service.GuardrailPolicy
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpResponse(w, fmt.Sprintf("invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if problem := validateGuardrailPolicy(req); problem != "" {
+		httpResponse(w, problem, http.StatusBadRequest)
+		return
+	}
+
+	req.UpdatedBy = s.getUserEmail(r)
+
+	record, err := s.guardrailStore.UpdateGuardrailPolicy(r.Context(), id, req)
+	if err != nil {
+		slog.Error("update guardrail policy failed", "id", id, "error", err)
+		httpResponse(w, fmt.Sprintf("failed to update guardrail policy: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	if record == nil {
+		httpResponse(w, fmt.Sprintf("guardrail policy %q not found", id), http.StatusNotFound)
+		return
+	}
+
+	s.guardrailCache.Delete(id)
+
+	httpResponseJSON(w, record, http.StatusOK)
+}
+
+// DeleteGuardrailPolicyAPI handles DELETE /api/v1/guardrails/{id}.
+func (s *Server) DeleteGuardrailPolicyAPI(w http.ResponseWriter, r *http.Request) {
+	if s.guardrailStore == nil {
+		httpResponse(w, "store not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	id := r.PathValue("id")
+	if id == "" {
+		httpResponse(w, "guardrail policy id is required", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.guardrailStore.DeleteGuardrailPolicy(r.Context(), id); err != nil {
+		slog.Error("delete guardrail policy failed", "id", id, "error", err)
+		httpResponse(w, fmt.Sprintf("failed to delete guardrail policy: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	s.guardrailCache.Delete(id)
+
+	httpResponseJSON(w, map[string]string{"status": "deleted"}, http.StatusOK)
+}
@@ -0,0 +1,462 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rakunlabs/at/internal/service"
+	"github.com/rakunlabs/query"
+	"github.com/worldline-go/types"
+)
+
+// memoryGuardrailStore is an in-memory GuardrailPolicyStorer.
+type memoryGuardrailStore struct {
+	items map[string]service.GuardrailPolicy
+}
+
+func newMemoryGuardrailStore(items ...service.GuardrailPolicy) *memoryGuardrailStore {
+	st := &memoryGuardrailStore{items: map[string]service.GuardrailPolicy{}}
+	for _, p := range items {
+		st.items[p.ID] = p
+	}
+	return st
+}
+
+func (m *memoryGuardrailStore) ListGuardrailPolicies(context.Context, *query.Query) (*service.ListResult[service.GuardrailPolicy], error) {
+	out := &service.ListResult[service.GuardrailPolicy]{}
+	for _, p := range m.items {
+		out.Data = append(out.Data, p)
+	}
+	return out, nil
+}
+
+func (m *memoryGuardrailStore) GetGuardrailPolicy(_ context.Context, id string) (*service.GuardrailPolicy, error) {
+	p, ok := m.items[id]
+	if !ok {
+		return nil, nil
+	}
+	return &p, nil
+}
+
+func (m *memoryGuardrailStore) CreateGuardrailPolicy(_ context.Context, p service.GuardrailPolicy) (*service.GuardrailPolicy, error) {
+	p.ID = p.Name
+	m.items[p.ID] = p
+	return &p, nil
+}
+
+func (m *memoryGuardrailStore) UpdateGuardrailPolicy(_ context.Context, id string, p service.GuardrailPolicy) (*service.GuardrailPolicy, error) {
+	if _, ok := m.items[id]; !ok {
+		return nil, nil
+	}
+	p.ID = id
+	m.items[id] = p
+	return &p, nil
+}
+
+func (m *memoryGuardrailStore) DeleteGuardrailPolicy(_ context.Context, id string) error {
+	delete(m.items, id)
+	return nil
+}
+
+// fakeModerationProvider flags every input containing "attack".
+type fakeModerationProvider struct {
+	messagesCaptureProvider
+}
+
+func (p *fakeModerationProvider) Moderate(_ context.Context, req service.ModerationRequest) (*service.ModerationResponse, error) {
+	resp := &service.ModerationResponse{}
+	for _, in := range req.Input {
+		flagged := strings.Contains(in, "attack")
+		resp.Results = append(resp.Results, service.ModerationResult{
+			Flagged:    flagged,
+			Categories: map[string]bool{"violence": flagged},
+		})
+	}
+	return resp, nil
+}
+
+func newGuardrailTestServer(provider service.LLMProvider, policies ...service.GuardrailPolicy) (*Server, *fakeLLMCallStore) {
+	ids := make([]string, 0, len(policies))
+	for _, p := range policies {
+		ids = append(ids, p.ID)
+	}
+	obs := &fakeLLMCallStore{}
+	s := &Server{
+		providers: map[string]ProviderInfo{
+			"gemini": {provider: provider, providerType: "openai"},
+			"mod":    {provider: &fakeModerationProvider{}, providerType: "openai"},
+		},
+		tokenStore: gatewayTestToken("test-token", service.APIToken{
+			ID:                   "tok-1",
+			AllowedProvidersMode: service.AccessModeAll,
+			AllowedModelsMode:    service.AccessModeAll,
+			GuardrailIDs:         ids,
+		}),
+		guardrailStore: newMemoryGuardrailStore(policies...),
+		llmCallStore:   obs,
+	}
+	return s, obs
+}
+
+func guardrailObservations(t *testing.T, store *fakeLLMCallStore, n int) []service.LLMCall {
+	t.Helper()
+	var out []service.LLMCall
+	for _, o := range waitForObservations(t, store, n) {
+		if o.Name == "guardrail" {
+			out = append(out, o)
+		}
+	}
+	return out
+}
+
+func decodeGatewayErrorCode(t *testing.T, body []byte) string {
+	t.Helper()
+	var resp struct {
+		Error struct {
+			Code string `json:"code"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		t.Fatalf("decode: %v; body=%s", err, body)
+	}
+	return resp.Error.Code
+}
+
+func TestChatCompletionsGuardrailRedactsPII(t *testing.T) {
+	provider := &messagesCaptureProvider{resp: &service.LLMResponse{Content: "ok", Finished: true}}
+	s, obs := newGuardrailTestServer(provider, service.GuardrailPolicy{
+		ID: "g1", Name: "pii", Enabled: true,
+		Config: service.GuardrailConfig{Input: service.GuardrailInputConfig{
+			PII: []service.GuardrailPIIRule{
+				{Entity: service.GuardrailPIIEmail, Action: service.GuardrailActionRedact},
+				{Entity: service.GuardrailPIICreditCard, Action: service.GuardrailActionRedact, Replacement: "[CARD]"},
+			},
+		}},
+	})
+
+	rec := doCapabilityChat(s, `{"model":"gemini/m","messages":[
+		{"role":"system","content":"support is help@example.com"},
+		{"role":"user","content":"I am jane@example.com, card 4111 1111 1111 1111, order 1234 5678 9012 3456"}]}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d; body=%s", rec.Code, rec.Body.String())
+	}
+
+	raw, _ := json.Marshal(provider.messages)
+	got := string(raw)
+	if strings.Contains(got, "jane@example.com") || strings.Contains(got, "4111 1111 1111 1111") {
+		t.Fatalf("PII reached the provider: %s", got)
+	}
+	if !strings.Contains(got, "[REDACTED_EMAIL]") || !strings.Contains(got, "[CARD]") {
+		t.Errorf("redaction markers missing: %s", got)
+	}
+	if !strings.Contains(got, "help@example.com") {
+		t.Errorf("system prompt should not be scanned: %s", got)
+	}
+	if !strings.Contains(got, "1234 5678 9012 3456") {
+		t.Errorf("number failing the Luhn check was redacted: %s", got)
+	}
+
+	events := guardrailObservations(t, obs, 2)
+	if len(events) != 2 {
+		t.Fatalf("guardrail events = %d, want 2", len(events))
+	}
+	for _, e := range events {
+		if e.ObservationType != service.ObservationEvent || e.Level != service.ObservationLevelWarning {
+			t.Errorf("event = %s/%s, want event/warning", e.ObservationType, e.Level)
+		}
+		if strings.Contains(e.Input, "jane@example.com") {
+			t.Errorf("observation leaks the matched value: %q", e.Input)
+		}
+	}
+}
+
+func TestChatCompletionsGuardrailBlocks(t *testing.T) {
+	tests := []struct {
+		name   string
+		config service.GuardrailInputConfig
+		prompt string
+	}{
+		{"deny list", service.GuardrailInputConfig{DenyList: []string{"Project X"}}, "tell me about project x"},
+		{"max chars", service.GuardrailInputConfig{MaxChars: 10}, "this prompt is too long"},
+		{"max tokens", service.GuardrailInputConfig{MaxTokens: 5}, strings.Repeat("word ", 50)},
+		{"pii block", service.GuardrailInputConfig{PII: []service.GuardrailPIIRule{{Entity: service.GuardrailPIISSN, Action: service.GuardrailActionBlock}}}, "my ssn is 123-45-6789"},
+		{"pattern block", service.GuardrailInputConfig{Patterns: []service.GuardrailPattern{{Name: "ticket", Pattern: `TICKET-\d+`, Action: service.GuardrailActionBlock}}}, "see TICKET-42"},
+		{"moderation", service.GuardrailInputConfig{Moderation: &service.GuardrailModeration{Model: "mod/omni"}}, "plan an attack"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := &messagesCaptureProvider{resp: &service.LLMResponse{Content: "ok", Finished: true}}
+			s, obs := newGuardrailTestServer(provider, service.GuardrailPolicy{
+				ID: "g1", Name: "strict", Enabled: true,
+				Config: service.GuardrailConfig{Input: tt.config},
+			})
+
+			body, _ := json.Marshal(map[string]any{
+				"model":    "gemini/m",
+				"messages": []map[string]any{{"role": "user", "content": tt.prompt}},
+			})
+			rec := doCapabilityChat(s, string(body))
+			if rec.Code != http.StatusBadRequest {
+				t.Fatalf("status = %d; body=%s", rec.Code, rec.Body.String())
+			}
+			if code := decodeGatewayErrorCode(t, rec.Body.Bytes()); code != "guardrail_violation" {
+				t.Errorf("code = %q, want guardrail_violation", code)
+			}
+			if provider.messages != nil {
+				t.Error("provider was called for a blocked request")
+			}
+
+			events := guardrailObservations(t, obs, 2)
+			if len(events) != 1 || events[0].Level != service.ObservationLevelError {
+				t.Fatalf("guardrail events = %+v, want one error event", events)
+			}
+		})
+	}
+}
+
+func TestChatCompletionsGuardrailDisabledPolicyIsSkipped(t *testing.T) {
+	provider := &messagesCaptureProvider{resp: &service.LLMResponse{Content: "ok", Finished: true}}
+	s, _ := newGuardrailTestServer(provider, service.GuardrailPolicy{
+		ID: "g1", Name: "off", Enabled: false,
+		Config: service.GuardrailConfig{Input: service.GuardrailInputConfig{DenyList: []string{"hello"}}},
+	})
+
+	rec := doCapabilityChat(s, `{"model":"gemini/m","messages":[{"role":"user","content":"hello"}]}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d; body=%s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestChatCompletionsGuardrailOutputChecks(t *testing.T) {
+	t.Run("json", func(t *testing.T) {
+		provider := &messagesCaptureProvider{resp: &service.LLMResponse{Content: "not json", Finished: true}}
+		s, _ := newGuardrailTestServer(provider, service.GuardrailPolicy{ID: "g1", Name: "json", Enabled: true})
+
+		rec := doCapabilityChat(s, `{"model":"gemini/m","response_format":{"type":"json_object"},"messages":[{"role":"user","content":"hi"}]}`)
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("status = %d; body=%s", rec.Code, rec.Body.String())
+		}
+
+		provider.resp = &service.LLMResponse{Content: "```json\n{\"ok\":true}\n```", Finished: true}
+		rec = doCapabilityChat(s, `{"model":"gemini/m","response_format":{"type":"json_object"},"messages":[{"role":"user","content":"hi"}]}`)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("fenced JSON: status = %d; body=%s", rec.Code, rec.Body.String())
+		}
+	})
+
+	t.Run("moderation warn", func(t *testing.T) {
+		provider := &messagesCaptureProvider{resp: &service.LLMResponse{Content: "an attack plan", Finished: true}}
+		s, obs := newGuardrailTestServer(provider, service.GuardrailPolicy{
+			ID: "g1", Name: "mod", Enabled: true,
+			Config: service.GuardrailConfig{Output: service.GuardrailOutputConfig{
+				Moderation: &service.GuardrailModeration{Model: "mod/omni", Action: service.GuardrailActionWarn},
+			}},
+		})
+
+		rec := doCapabilityChat(s, `{"model":"gemini/m","messages":[{"role":"user","content":"hi"}]}`)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d; body=%s", rec.Code, rec.Body.String())
+		}
+		events := guardrailObservations(t, obs, 1)
+		if len(events) != 1 || events[0].Level != service.ObservationLevelWarning {
+			t.Fatalf("guardrail events = %+v, want one warning", events)
+		}
+		if events[0].Metadata["stage"] != "output" {
+			t.Errorf("stage = %v, want output", events[0].Metadata["stage"])
+		}
+	})
+
+	t.Run("stream", func(t *testing.T) {
+		provider := &messagesCaptureProvider{}
+		s, _ := newGuardrailTestServer(provider, service.GuardrailPolicy{
+			ID: "g1", Name: "json", Enabled: true,
+			Config: service.GuardrailConfig{Output: service.GuardrailOutputConfig{RequireJSON: true}},
+		})
+
+		// The capture stream answers "Hello" plus a tool call, so the
+		// text is checked and rejected on the finish chunk.
+		rec := doCapabilityChat(s, `{"model":"gemini/m","stream":true,"messages":[{"role":"user","content":"hi"}]}`)
+		body := rec.Body.String()
+		if !strings.Contains(body, "Hel") || !strings.Contains(body, "guardrail") {
+			t.Fatalf("stream should forward deltas then fail on the guardrail: %s", body)
+		}
+		if strings.Contains(body, `"finish_reason":"tool_calls"`) {
+			t.Errorf("finish chunk was forwarded after a block: %s", body)
+		}
+	})
+}
+
+func TestRewriteGuardrailInputForms(t *testing.T) {
+	upper := func(s string) string { return strings.ToUpper(s) }
+	blocks := []service.ContentBlock{{Type: "text", Text: "a"}, {Type: "tool_result", Content: "b"}}
+	parts := []any{map[string]any{"type": "text", "text": "c"}, map[string]any{"type": "image_url"}}
+	in := []service.Message{
+		{Role: "system", Content: "keep"},
+		{Role: "user", Content: blocks},
+		{Role: "user", Content: map[string]any{"role": "user", "content": parts}},
+	}
+
+	out := rewriteGuardrailInput(in, upper)
+
+	if out[0].Content != "keep" {
+		t.Errorf("system content changed: %v", out[0].Content)
+	}
+	gotBlocks := out[1].Content.([]service.ContentBlock)
+	if gotBlocks[0].Text != "A" || gotBlocks[1].Content != "B" {
+		t.Errorf("blocks = %+v", gotBlocks)
+	}
+	gotParts := out[2].Content.(map[string]any)["content"].([]any)
+	if gotParts[0].(map[string]any)["text"] != "C" {
+		t.Errorf("parts = %+v", gotParts)
+	}
+	if blocks[0].Text != "a" || parts[0].(map[string]any)["text"] != "c" {
+		t.Error("input messages were modified in place")
+	}
+}
+
+func TestGuardrailPIIValidators(t *testing.T) {
+	if !luhnValid("4111 1111 1111 1111") || luhnValid("4111 1111 1111 1112") {
+		t.Error("luhn check")
+	}
+	if !ibanValid("GB82 WEST 1234 5698 7654 32") || ibanValid("GB82 WEST 1234 5698 7654 33") {
+		t.Error("iban check")
+	}
+	if problem := validateGuardrailPolicy(service.GuardrailPolicy{
+		Name:   "bad",
+		Config: service.GuardrailConfig{Input: service.GuardrailInputConfig{PII: []service.GuardrailPIIRule{{Entity: "passport"}}}},
+	}); problem == "" {
+		t.Error("unknown entity should be rejected")
+	}
+}
+
+// redactEmailBlockProjectX redacts e-mail addresses and blocks "Project X".
+var redactEmailBlockProjectX = service.GuardrailPolicy{
+	ID: "g1", Name: "strict", Enabled: true,
+	Config: service.GuardrailConfig{Input: service.GuardrailInputConfig{
+		PII:      []service.GuardrailPIIRule{{Entity: service.GuardrailPIIEmail, Action: service.GuardrailActionRedact}},
+		DenyList: []string{"Project X"},
+	}},
+}
+
+func TestTokenGuardrailsApplyToNativeTokenCount(t *testing.T) {
+	for _, endpoint := range []string{"tokenize", "count_tokens"} {
+		t.Run(endpoint, func(t *testing.T) {
+			count := func(s *Server, text string) *httptest.ResponseRecorder {
+				if endpoint == "tokenize" {
+					return doTokenize(s, `{"model":"gemini/m","input":"`+text+`"}`)
+				}
+				return doMessages(s, "/gateway/v1/messages/count_tokens", `{"model":"gemini/m","messages":[{"role":"user","content":"`+text+`"}]}`)
+			}
+
+			provider := &tokenCountingProvider{count: 7}
+			s, _ := newGuardrailTestServer(provider, redactEmailBlockProjectX)
+			if rec := count(s, "mail jane@example.com"); rec.Code != http.StatusOK {
+				t.Fatalf("status = %d; body=%s", rec.Code, rec.Body.String())
+			}
+			raw, _ := json.Marshal(provider.messages)
+			if strings.Contains(string(raw), "jane@example.com") || !strings.Contains(string(raw), "[REDACTED_EMAIL]") {
+				t.Fatalf("counted messages = %s", raw)
+			}
+
+			provider = &tokenCountingProvider{count: 7}
+			s, _ = newGuardrailTestServer(provider, redactEmailBlockProjectX)
+			if rec := count(s, "about project x"); rec.Code != http.StatusBadRequest {
+				t.Fatalf("blocked status = %d; body=%s", rec.Code, rec.Body.String())
+			}
+			if provider.messages != nil {
+				t.Error("blocked prompt reached the token counting API")
+			}
+		})
+	}
+}
+
+func TestTokenGuardrailsApplyToEmbeddings(t *testing.T) {
+	embed := func(s *Server, input string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/gateway/v1/embeddings", strings.NewReader(`{"model":"gemini/emb","input":["hello",`+input+`]}`))
+		req.Header.Set("Authorization", "Bearer test-token")
+		rec := httptest.NewRecorder()
+		s.Embeddings(rec, req)
+		return rec
+	}
+
+	provider := &embeddingCaptureProvider{}
+	s, _ := newGuardrailTestServer(provider, redactEmailBlockProjectX)
+	if rec := embed(s, `"mail jane@example.com"`); rec.Code != http.StatusOK {
+		t.Fatalf("status = %d; body=%s", rec.Code, rec.Body.String())
+	}
+	if in := provider.req.Input; len(in) != 2 || in[0] != "hello" || in[1] != "mail [REDACTED_EMAIL]" {
+		t.Fatalf("embedded input = %q", in)
+	}
+
+	provider = &embeddingCaptureProvider{}
+	s, _ = newGuardrailTestServer(provider, redactEmailBlockProjectX)
+	rec := embed(s, `"about project x"`)
+	if rec.Code != http.StatusBadRequest || decodeGatewayErrorCode(t, rec.Body.Bytes()) != "guardrail_violation" {
+		t.Fatalf("blocked status = %d; body=%s", rec.Code, rec.Body.String())
+	}
+	if provider.req.Input != nil {
+		t.Error("blocked input reached the embedding provider")
+	}
+}
+
+func TestTokenGuardrailsSkipSemanticCacheProbe(t *testing.T) {
+	provider := &semanticChatProvider{}
+	s := newResponseCacheTestServer(provider, true)
+	s.responseCache.semanticModel = "openai/text-embedding-3-small"
+	s.guardrailStore = newMemoryGuardrailStore(redactEmailBlockProjectX)
+	s.tokenStore = gatewayTestToken("test-token", service.APIToken{
+		ID:                   "tok-1",
+		AllowedProvidersMode: service.AccessModeAll,
+		AllowedModelsMode:    service.AccessModeAll,
+		CacheEnabled:         true,
+		SemanticThreshold:    types.NewNull(0.9),
+		GuardrailIDs:         []string{"g1"},
+	})
+
+	for _, text := range []string{"reset the password for jane@example.com", "password reset for jane@example.com please"} {
+		if rec := doCachedChat(s, `{"model":"openai/gpt-4o","messages":[{"role":"user","content":"`+text+`"}]}`, nil); rec.Code != http.StatusOK {
+			t.Fatalf("status = %d; body=%s", rec.Code, rec.Body.String())
+		}
+	}
+	if provider.embedCalls != 0 {
+		t.Fatalf("embedding calls = %d; the raw prompt was sent to the semantic cache probe", provider.embedCalls)
+	}
+}
+
+// nativeChoicesGuardrailProvider answers n natively with a valid first
+// choice and the extra choices it is given.
+type nativeChoicesGuardrailProvider struct {
+	messagesCaptureProvider
+	extra []service.LLMResponse
+	calls int
+}
+
+func (p *nativeChoicesGuardrailProvider) Chat(context.Context, string, []service.Message, []service.Tool, *service.ChatOptions) (*service.LLMResponse, error) {
+	p.calls++
+	return &service.LLMResponse{Content: `{"ok":true}`, Finished: true, ExtraChoices: p.extra}, nil
+}
+
+func (p *nativeChoicesGuardrailProvider) NativeChoices() bool { return true }
+
+func TestChatCompletionsGuardrailChecksEveryChoice(t *testing.T) {
+	provider := &nativeChoicesGuardrailProvider{extra: []service.LLMResponse{{Content: `{"ok":true}`, Finished: true}}}
+	s, _ := newGuardrailTestServer(provider, service.GuardrailPolicy{
+		ID: "g1", Name: "json", Enabled: true,
+		Config: service.GuardrailConfig{Output: service.GuardrailOutputConfig{RequireJSON: true}},
+	})
+	body := `{"model":"gemini/m","n":2,"messages":[{"role":"user","content":"hi"}]}`
+
+	// The guarded provider must still answer n natively.
+	if rec := doCapabilityChat(s, body); rec.Code != http.StatusOK || provider.calls != 1 {
+		t.Fatalf("status = %d, calls = %d; body=%s", rec.Code, provider.calls, rec.Body.String())
+	}
+
+	provider.extra = []service.LLMResponse{{Content: "not json", Finished: true}}
+	if rec := doCapabilityChat(s, body); rec.Code != http.StatusBadRequest {
+		t.Fatalf("extra choice passed the guardrail: status = %d; body=%s", rec.Code, rec.Body.String())
+	}
+}
@@ -13,21 +13,25 @@ import (
 
 	"github.com/oklog/ulid/v2"
 	"github.com/rakunlabs/at/internal/service"
-	"go.opentelemetry.io/otel"
+	"github.com/rakunlabs/at/internal/service/metrics"
+	"github.com/rakunlabs/at/internal/service/tracing"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
 	oteltrace "go.opentelemetry.io/otel/trace"
 )
 
 // auditTraceInfo extracts the client-supplied trace and session correlation
-// IDs from request headers. Both are optional: an empty trace ID causes the
-// recorder to mint a fresh ULID, grouping only the fallback attempts of this
-// one request. Clients that want to stitch a multi-turn conversation set
+// IDs from request headers. Both are optional: without a trace header the
+// trace ID of the request's OTel span is used (so audit rows line up with
+// exported traces), and without one of those the recorder mints a fresh
+// ULID, grouping only the fallback attempts of this one request. Clients
+// that want to stitch a multi-turn conversation set
 // x-at-session-id (or the standard OpenAI-ecosystem headers) to a stable value.
 func auditTraceInfo(r *http.Request) (traceID, sessionID string) {
 	traceID = firstNonEmpty(
 		r.Header.Get("x-at-trace-id"),
 		r.Header.Get("x-trace-id"),
+		tracing.TraceIDFromContext(r.Context()),
 	)
 	sessionID = firstNonEmpty(
 		r.Header.Get("x-at-session-id"),
@@ -134,6 +138,9 @@ func (s *Server) llmAuditEnabled(ctx context.Context) bool {
 // generation); returns "" when no store is wired.
 func (s *Server) recordLLMCallAsync(ctx context.Context, p llmAuditParams) string {
 	if s.llmCallStore == nil {
+		if metrics.Default.Enabled() {
+			observeLLMCall(p, s.buildLLMCall(ctx, p))
+		}
 		return ""
 	}
 
@@ -141,6 +148,7 @@ func (s *Server) recordLLMCallAsync(ctx context.Context, p llmAuditParams) strin
 
 	// Snapshot everything we need off the request goroutine.
 	call := s.buildLLMCall(ctx, p)
+	observeLLMCall(p, call)
 
 	var reqBody, respBody []byte
 	if bodies {
@@ -156,7 +164,7 @@ func (s *Server) recordLLMCallAsync(ctx context.Context, p llmAuditParams) strin
 		if err := s.llmCallStore.RecordLLMCall(bg, call); err != nil {
 			slog.Error("failed to record llm call", "trace_id", call.TraceID, "model", call.Model, "error", err.Error())
 		}
-		s.emitLLMSpan(call, reqBody, respBody)
+		s.emitLLMSpan(bg, call, reqBody, respBody)
 	}()
 
 	return call.ID
@@ -184,7 +192,7 @@ func (s *Server) buildLLMCall(ctx context.Context, p llmAuditParams) service.LLM
 
 	traceID := p.traceID
 	if traceID == "" {
-		traceID = ulid.Make().String()
+		traceID = traceIDFor(ctx)
 	}
 
 	obsType := p.obsType
@@ -311,46 +319,24 @@ func (s *Server) llmAuditRoot() string {
 	return filepath.Join(root, llmAuditDumpDir)
 }
 
-// emitLLMSpan emits a completed OTEL span following the gen-ai semantic
-// conventions, plus Langfuse-compatible trace/session/input/output
-// attributes. Tool observations become gen-ai tool spans; event
-// observations are skipped (they carry no duration and the DB row is the
-// system of record). When no tracer provider is configured (telemetry off)
+// emitLLMSpan emits a completed OTEL span for a generation following the
+// gen-ai semantic conventions, plus Langfuse-compatible
+// trace/session/input/output attributes. The span is parented on the span in
+// ctx (the gateway request, agent iteration, ...), so it lands next to the
+// live provider span of the same call. Tool observations are skipped: the
+// agent loops trace them live with tracing.StartToolSpan. Event
+// observations carry no duration and are skipped too; the DB row is the
+// system of record. When no tracer provider is configured (telemetry off)
 // the global tracer is a no-op, so this is safe and cheap.
-func (s *Server) emitLLMSpan(call service.LLMCall, reqBody, respBody []byte) {
-	if call.ObservationType == service.ObservationEvent {
+func (s *Server) emitLLMSpan(ctx context.Context, call service.LLMCall, reqBody, respBody []byte) {
+	if call.ObservationType == service.ObservationEvent || call.ObservationType == service.ObservationTool {
 		return
 	}
 
-	tracer := otel.Tracer("github.com/rakunlabs/at/gateway")
-
 	end := time.Now()
 	start := end.Add(-time.Duration(call.LatencyMs) * time.Millisecond)
 
-	if call.ObservationType == service.ObservationTool {
-		_, span := tracer.Start(context.Background(), "tool "+call.Name,
-			oteltrace.WithTimestamp(start),
-			oteltrace.WithSpanKind(oteltrace.SpanKindInternal),
-		)
-		span.SetAttributes(
-			attribute.String("gen_ai.operation.name", "execute_tool"),
-			attribute.String("gen_ai.tool.name", call.Name),
-			attribute.String("langfuse.trace.id", call.TraceID),
-			attribute.String("langfuse.observation.type", "span"),
-			attribute.String("gen_ai.tool.call.arguments", clipSpanBody([]byte(call.Input))),
-			attribute.String("gen_ai.tool.call.result", clipSpanBody([]byte(call.Output))),
-		)
-		if call.SessionID != "" {
-			span.SetAttributes(attribute.String("langfuse.session.id", call.SessionID))
-		}
-		if call.Level == service.ObservationLevelError || call.Status == "error" {
-			span.SetStatus(codes.Error, call.ErrorMessage)
-		}
-		span.End(oteltrace.WithTimestamp(end))
-		return
-	}
-
-	_, span := tracer.Start(context.Background(), "chat "+call.Model,
+	_, span := tracer().Start(ctx, "chat "+call.Model,
 		oteltrace.WithTimestamp(start),
 		oteltrace.WithSpanKind(oteltrace.SpanKindClient),
 	)
@@ -419,6 +405,19 @@ type streamAuditCtx struct {
 	userField      string
 	requestBody    []byte
 	requestedModel string
+
+	// onComplete, when set, receives the reconstructed OpenAI-shape
+	// response body after a stream finishes successfully (used by the
+	// response cache to store streamed misses).
+	onComplete func(respBody []byte)
+
+	// metadata is attached to every observation of the stream (hedge
+	// role and winner flag for at_hedge requests).
+	metadata map[string]any
+	// startedAt, when set, is when the upstream request was sent; latency
+	// and time-to-first-token are measured from it instead of from the
+	// start of the relay (at_hedge opens the stream before relaying).
+	startedAt time.Time
 }
 
 // resolveSource returns the audit source, defaulting to "gateway_stream"
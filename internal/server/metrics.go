@@ -0,0 +1,98 @@
+package server
+
+import (
+	"strings"
+
+	"github.com/rakunlabs/at/internal/config"
+	"github.com/rakunlabs/at/internal/service"
+	"github.com/rakunlabs/at/internal/service/metrics"
+)
+
+// ─── Prometheus metrics ───
+//
+// Metric definitions live in internal/service/metrics; this file holds the
+// server-side glue that turns gateway and agent events into observations.
+// Every helper is a no-op while metrics are disabled.
+
+// metricsOptions converts the `server.metrics` config block.
+func metricsOptions(cfg *config.Metrics) metrics.Options {
+	if cfg == nil {
+		return metrics.Options{}
+	}
+	return metrics.Options{
+		Enabled:    cfg.Enabled,
+		DropLabels: cfg.DropLabels,
+		MaxSeries:  cfg.MaxSeries,
+	}
+}
+
+// metricsPath returns the endpoint path under base_path.
+func metricsPath(cfg *config.Metrics) string {
+	path := strings.TrimSpace(cfg.Path)
+	if path == "" {
+		return "/metrics"
+	}
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return path
+}
+
+// metricsTokenLabel is the API token name used as the "token" label;
+// empty for config tokens and unrestricted access.
+func metricsTokenLabel(auth *authResult) string {
+	if auth == nil || auth.token == nil {
+		return ""
+	}
+	return auth.token.Name
+}
+
+// observeGatewayRequest records one finished gateway request: its count,
+// latency, and token / cost counters.
+func observeGatewayRequest(auth *authResult, fullModel string, usage service.Usage, costCents float64, latencyMs int64, status string) {
+	provider, model := splitProviderModel(fullModel)
+	token := metricsTokenLabel(auth)
+
+	metrics.GatewayRequests.Inc(provider, model, token, status)
+	if latencyMs > 0 {
+		metrics.GatewayRequestDuration.Observe(metrics.Seconds(latencyMs), provider, model, token, status)
+	}
+	observeLLMUsage("gateway", provider, model, token, usage, costCents)
+}
+
+// observeLLMUsage adds usage and cost to the token and cost counters.
+func observeLLMUsage(source, provider, model, token string, usage service.Usage, costCents float64) {
+	for _, t := range []struct {
+		typ string
+		n   int
+	}{
+		{"input", usage.PromptTokens},
+		{"output", usage.CompletionTokens},
+		{"cache_read", usage.CacheReadTokens},
+		{"cache_write", usage.CacheWriteTokens},
+	} {
+		metrics.LLMTokens.Add(float64(t.n), source, provider, model, token, t.typ)
+	}
+	metrics.LLMCostCents.Add(costCents, source, provider, model, token)
+}
+
+// observeLLMCall records the metrics carried by an audit observation:
+// time-to-first-token for streamed generations, usage of non-gateway
+// generations (gateway usage is counted by observeGatewayRequest), and
+// tool call durations and errors.
+func observeLLMCall(p llmAuditParams, call service.LLMCall) {
+	switch call.ObservationType {
+	case service.ObservationGeneration:
+		if call.TimeToFirstTokenMs > 0 {
+			metrics.GatewayTimeToFirstToken.Observe(metrics.Seconds(call.TimeToFirstTokenMs), call.Provider, call.Model, metricsTokenLabel(p.auth))
+		}
+		if p.auth == nil {
+			observeLLMUsage(call.Source, call.Provider, call.Model, "", p.usage, call.CostCents)
+		}
+	case service.ObservationTool:
+		metrics.ToolCallDuration.Observe(metrics.Seconds(call.LatencyMs), call.Name, call.Source)
+		if call.Level == service.ObservationLevelError {
+			metrics.ToolCallErrors.Inc(call.Name, call.Source)
+		}
+	}
+}
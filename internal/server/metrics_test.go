@@ -0,0 +1,103 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/rakunlabs/at/internal/service"
+	"github.com/rakunlabs/at/internal/service/metrics"
+)
+
+// enableTestMetrics turns the process-wide registry on for one test.
+func enableTestMetrics(t *testing.T, opts metrics.Options) {
+	t.Helper()
+	opts.Enabled = true
+	metrics.Default.Configure(opts)
+	t.Cleanup(func() { metrics.Default.Configure(metrics.Options{}) })
+}
+
+func scrapeMetrics(t *testing.T) string {
+	t.Helper()
+	var b strings.Builder
+	if _, err := metrics.Default.WriteTo(&b); err != nil {
+		t.Fatalf("WriteTo: %v", err)
+	}
+	return b.String()
+}
+
+func TestChatCompletionsRecordsMetrics(t *testing.T) {
+	enableTestMetrics(t, metrics.Options{})
+
+	provider := &messagesCaptureProvider{resp: &service.LLMResponse{
+		Content:  "ok",
+		Finished: true,
+		Usage:    service.Usage{PromptTokens: 12, CompletionTokens: 3},
+	}}
+	s := newGatewayHookTestServer(provider, nil, nil)
+
+	rec := doCapabilityChat(s, `{"model":"gemini/m","messages":[{"role":"user","content":"hi"}]}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d; body=%s", rec.Code, rec.Body.String())
+	}
+
+	out := scrapeMetrics(t)
+	for _, line := range []string{
+		`at_gateway_requests_total{provider="gemini",model="m",token="team-a",status="ok"} 1`,
+		`at_llm_tokens_total{source="gateway",provider="gemini",model="m",token="team-a",type="input"} 12`,
+		`at_llm_tokens_total{source="gateway",provider="gemini",model="m",token="team-a",type="output"} 3`,
+	} {
+		if !strings.Contains(out, line) {
+			t.Errorf("missing %q in:\n%s", line, out)
+		}
+	}
+}
+
+func TestMetricsDropTokenLabel(t *testing.T) {
+	enableTestMetrics(t, metrics.Options{DropLabels: []string{"token"}})
+
+	provider := &messagesCaptureProvider{resp: &service.LLMResponse{Content: "ok", Finished: true}}
+	s := newGatewayHookTestServer(provider, nil, nil)
+	doCapabilityChat(s, `{"model":"gemini/m","messages":[{"role":"user","content":"hi"}]}`)
+
+	out := scrapeMetrics(t)
+	if !strings.Contains(out, `at_gateway_requests_total{provider="gemini",model="m",status="ok"} 1`) {
+		t.Errorf("token label not dropped:\n%s", out)
+	}
+}
+
+func TestObserveLLMCallToolMetrics(t *testing.T) {
+	enableTestMetrics(t, metrics.Options{})
+
+	s := &Server{}
+	s.recordLLMCallAsync(context.Background(), llmAuditParams{
+		source: "chat", obsType: service.ObservationTool, name: "bash_execute",
+		level: service.ObservationLevelError, latencyMs: 1500,
+	})
+
+	out := scrapeMetrics(t)
+	for _, line := range []string{
+		`at_tool_call_duration_seconds_count{tool="bash_execute",source="chat"} 1`,
+		`at_tool_call_errors_total{tool="bash_execute",source="chat"} 1`,
+	} {
+		if !strings.Contains(out, line) {
+			t.Errorf("missing %q in:\n%s", line, out)
+		}
+	}
+}
+
+func TestRegisterRunTracksActiveRuns(t *testing.T) {
+	enableTestMetrics(t, metrics.Options{})
+
+	s := &Server{}
+	_, _, cleanup := s.registerRun(context.Background(), "wf-1", "api")
+	if out := scrapeMetrics(t); !strings.Contains(out, `at_workflow_runs_active{source="api"} 1`) {
+		t.Errorf("active run not counted:\n%s", out)
+	}
+	cleanup()
+	cleanup()
+	if out := scrapeMetrics(t); !strings.Contains(out, `at_workflow_runs_active{source="api"} 0`) {
+		t.Errorf("active run not released exactly once:\n%s", out)
+	}
+}
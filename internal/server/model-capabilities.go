@@ -0,0 +1,348 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rakunlabs/at/internal/service"
+)
+
+// ─── Model capability registry ───
+//
+// service.ModelCapability rows describe what each provider/model accepts
+// (context window, max output, vision, audio, tools, reasoning, JSON
+// schema, streaming). The gateway reads them through a short-lived cache
+// to validate and adapt requests (gateway-capabilities.go) and to
+// advertise metadata on /gateway/v1/models. Rows are seeded by
+// POST /api/v1/model-capabilities/sync and editable through the API.
+
+// modelCapabilityCacheTTL bounds how stale another instance's edits can
+// be; local edits invalidate the cache immediately.
+const modelCapabilityCacheTTL = 30 * time.Second
+
+type modelCapabilityCache struct {
+	mu       sync.Mutex
+	entries  map[string]service.ModelCapability // key: provider_key/model
+	loadedAt time.Time
+}
+
+func modelCapabilityKey(providerKey, model string) string {
+	return providerKey + "/" + model
+}
+
+// modelCapabilities returns the registry keyed by provider_key/model,
+// reloading it when the cache has expired. A failed reload keeps serving
+// the previous entries.
+func (s *Server) modelCapabilities(ctx context.Context) map[string]service.ModelCapability {
+	if s.capabilityStore == nil {
+		return nil
+	}
+
+	c := &s.capabilityCache
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries != nil && time.Since(c.loadedAt) < modelCapabilityCacheTTL {
+		return c.entries
+	}
+
+	list, err := s.capabilityStore.ListModelCapabilities(ctx)
+	if err != nil {
+		slog.Warn("load model capabilities failed", "error", err)
+		c.loadedAt = time.Now()
+		return c.entries
+	}
+	entries := make(map[string]service.ModelCapability, len(list))
+	for _, mc := range list {
+		entries[modelCapabilityKey(mc.ProviderKey, mc.Model)] = mc
+	}
+	c.entries, c.loadedAt = entries, time.Now()
+	return entries
+}
+
+// modelCapability looks up the record for one provider/model.
+func (s *Server) modelCapability(ctx context.Context, providerKey, model string) (service.ModelCapability, bool) {
+	mc, ok := s.modelCapabilities(ctx)[modelCapabilityKey(providerKey, model)]
+	return mc, ok
+}
+
+func (s *Server) invalidateModelCapabilities() {
+	s.capabilityCache.mu.Lock()
+	s.capabilityCache.entries = nil
+	s.capabilityCache.mu.Unlock()
+}
+
+// ListModelCapabilitiesAPI handles GET /api/v1/model-capabilities.
+func (s *Server) ListModelCapabilitiesAPI(w http.ResponseWriter, r *http.Request) {
+	if s.capabilityStore == nil {
+		httpResponse(w, "store not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	records, err := s.capabilityStore.ListModelCapabilities(r.Context())
+	if err != nil {
+		slog.Error("list model capabilities failed", "error", err)
+		httpResponse(w, fmt.Sprintf("failed to list model capabilities: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if records == nil {
+		records = []service.ModelCapability{}
+	}
+
+	httpResponseJSON(w, records, http.StatusOK)
+}
+
+// SetModelCapabilityAPI handles POST /api/v1/model-capabilities. Edited
+// rows are marked as manual overrides so syncs keep them.
+func (s *Server) SetModelCapabilityAPI(w http.ResponseWriter, r *http.Request) {
+	if s.capabilityStore == nil {
+		httpResponse(w, "store not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	var req service.ModelCapability
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpResponse(w, fmt.Sprintf("invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+	if req.ProviderKey == "" {
+		httpResponse(w, "provider_key is required", http.StatusBadRequest)
+		return
+	}
+	if req.Model == "" {
+		httpResponse(w, "model is required", http.StatusBadRequest)
+		return
+	}
+	if req.ContextWindow < 0 || req.MaxOutputTokens < 0 {
+		httpResponse(w, "context_window and max_output_tokens must not be negative", http.StatusBadRequest)
+		return
+	}
+	req.Source = "manual"
+	req.ManualOverride = true
+
+	if err := s.capabilityStore.SetModelCapability(r.Context(), req); err != nil {
+		slog.Error("set model capability failed", "error", err)
+		httpResponse(w, fmt.Sprintf("failed to set model capability: %v", err), http.StatusInternalServerError)
+		return
+	}
+	s.invalidateModelCapabilities()
+
+	httpResponse(w, "capability updated", http.StatusOK)
+}
+
+// DeleteModelCapabilityAPI handles DELETE /api/v1/model-capabilities/{id}.
+func (s *Server) DeleteModelCapabilityAPI(w http.ResponseWriter, r *http.Request) {
+	if s.capabilityStore == nil {
+		httpResponse(w, "store not configured", http.StatusServiceUnavailable)
+		return
+	}
+	id := r.PathValue("id")
+	if id == "" {
+		httpResponse(w, "capability id is required", http.StatusBadRequest)
+		return
+	}
+	if err := s.capabilityStore.DeleteModelCapability(r.Context(), id); err != nil {
+		slog.Error("delete model capability failed", "id", id, "error", err)
+		httpResponse(w, fmt.Sprintf("failed to delete model capability: %v", err), http.StatusInternalServerError)
+		return
+	}
+	s.invalidateModelCapabilities()
+
+	httpResponse(w, "capability deleted", http.StatusOK)
+}
+
+// ─── Sync ───
+
+type modelCapabilitySyncRequest struct {
+	OverwriteOverrides bool `json:"overwrite_overrides,omitempty"`
+}
+
+type modelCapabilitySyncResponse struct {
+	Applied int      `json:"applied"`
+	Skipped int      `json:"skipped"`
+	Errors  []string `json:"errors,omitempty"`
+}
+
+// fetchCapabilityCatalog and discoverGeminiCapabilityModels are swapped
+// out in tests.
+var (
+	fetchCapabilityCatalog         = fetchPiDevModelPricing
+	discoverGeminiCapabilityModels = func(ctx context.Context, s *Server, providerKey string) ([]geminiModel, error) {
+		rec, err := s.store.GetProvider(ctx, providerKey)
+		if err != nil {
+			return nil, err
+		}
+		if rec == nil {
+			return nil, fmt.Errorf("provider %q not found", providerKey)
+		}
+		return listGeminiModels(ctx, rec.Config, false)
+	}
+)
+
+// SyncModelCapabilitiesAPI handles POST /api/v1/model-capabilities/sync.
+// Every configured model is seeded from, in increasing precedence:
+// built-in model-family defaults, the pi.dev catalog's context window,
+// and provider discovery (Gemini's model list reports token limits,
+// thinking and streaming support). Manual overrides are kept unless
+// overwrite_overrides is set.
+func (s *Server) SyncModelCapabilitiesAPI(w http.ResponseWriter, r *http.Request) {
+	if s.capabilityStore == nil {
+		httpResponse(w, "store not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	var req modelCapabilitySyncRequest
+	if r.ContentLength > 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			httpResponse(w, fmt.Sprintf("invalid request body: %v", err), http.StatusBadRequest)
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Minute)
+	defer cancel()
+
+	resp, err := s.syncModelCapabilities(ctx, req.OverwriteOverrides)
+	if err != nil {
+		slog.Error("sync model capabilities failed", "error", err)
+		httpResponse(w, fmt.Sprintf("failed to sync model capabilities: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	httpResponseJSON(w, resp, http.StatusOK)
+}
+
+func (s *Server) syncModelCapabilities(ctx context.Context, overwriteOverrides bool) (modelCapabilitySyncResponse, error) {
+	var resp modelCapabilitySyncResponse
+
+	existing, err := s.capabilityStore.ListModelCapabilities(ctx)
+	if err != nil {
+		return resp, err
+	}
+	current := make(map[string]service.ModelCapability, len(existing))
+	for _, mc := range existing {
+		current[modelCapabilityKey(mc.ProviderKey, mc.Model)] = mc
+	}
+
+	catalog, err := fetchCapabilityCatalog(ctx)
+	if err != nil {
+		resp.Errors = append(resp.Errors, fmt.Sprintf("%s: %v", piDevPricingSource, err))
+	}
+
+	discovered := make(map[string]map[string]geminiModel)
+	targets := s.configuredPricingModels()
+	for _, t := range targets {
+		if t.ProviderType != "gemini" || discovered[t.ProviderKey] != nil {
+			continue
+		}
+		discovered[t.ProviderKey] = map[string]geminiModel{}
+		if info, ok := s.getProviderInfo(t.ProviderKey); !ok || info.authType != "" {
+			continue
+		}
+		models, err := discoverGeminiCapabilityModels(ctx, s, t.ProviderKey)
+		if err != nil {
+			resp.Errors = append(resp.Errors, fmt.Sprintf("%s discovery: %v", t.ProviderKey, err))
+			continue
+		}
+		for _, m := range models {
+			discovered[t.ProviderKey][strings.TrimPrefix(m.Name, "models/")] = m
+		}
+	}
+
+	for _, t := range targets {
+		mc, sources := defaultModelCapability(t.Model)
+
+		if item, _, _, ok := matchPiDevPricing(catalog, t.ProviderType, t.Model); ok && item.ContextWindow > 0 {
+			mc.ContextWindow = item.ContextWindow
+			sources = append(sources, piDevPricingSource)
+		}
+
+		if gm, ok := discovered[t.ProviderKey][t.Model]; ok {
+			applyGeminiDiscovery(&mc, gm)
+			sources = append(sources, "discovery")
+		}
+
+		if len(sources) == 0 {
+			resp.Skipped++
+			continue
+		}
+		if cur, ok := current[modelCapabilityKey(t.ProviderKey, t.Model)]; ok && cur.ManualOverride && !overwriteOverrides {
+			resp.Skipped++
+			continue
+		}
+
+		mc.ProviderKey, mc.Model = t.ProviderKey, t.Model
+		mc.Source = strings.Join(sources, ",")
+		if err := s.capabilityStore.SetModelCapability(ctx, mc); err != nil {
+			resp.Errors = append(resp.Errors, fmt.Sprintf("%s/%s: %v", t.ProviderKey, t.Model, err))
+			continue
+		}
+		resp.Applied++
+	}
+
+	s.invalidateModelCapabilities()
+	return resp, nil
+}
+
+func applyGeminiDiscovery(mc *service.ModelCapability, gm geminiModel) {
+	if gm.InputTokenLimit > 0 {
+		mc.ContextWindow = gm.InputTokenLimit
+	}
+	if gm.OutputTokenLimit > 0 {
+		mc.MaxOutputTokens = gm.OutputTokenLimit
+	}
+	if gm.Thinking {
+		mc.Reasoning = boolPtr(true)
+	}
+	mc.Streaming = boolPtr(slices.Contains(gm.SupportedGenerationMethods, "streamGenerateContent"))
+}
+
+func boolPtr(b bool) *bool { return &b }
+
+// defaultModelCapability returns the well-known capabilities of common
+// model families. It only covers families whose feature set is stable
+// across versions; anything else is left unknown. The second result
+// names the source when something matched.
+func defaultModelCapability(model string) (service.ModelCapability, []string) {
+	m := strings.ToLower(model)
+	if i := strings.LastIndex(m, "/"); i >= 0 {
+		m = m[i+1:]
+	}
+	hasPrefix := func(prefixes ...string) bool {
+		for _, p := range prefixes {
+			if strings.HasPrefix(m, p) {
+				return true
+			}
+		}
+		return false
+	}
+
+	t, f := boolPtr(true), boolPtr(false)
+	var mc service.ModelCapability
+	switch {
+	case strings.Contains(m, "embed"):
+		mc = service.ModelCapability{Vision: f, Audio: f, Tools: f, Reasoning: f, Streaming: f}
+	case hasPrefix("claude-"):
+		mc = service.ModelCapability{Vision: t, Tools: t, Streaming: t, Audio: f}
+		mc.Reasoning = boolPtr(hasPrefix("claude-3-7", "claude-sonnet-4", "claude-opus-4", "claude-haiku-4"))
+	case hasPrefix("gpt-3.5"):
+		mc = service.ModelCapability{Vision: f, Tools: t, Reasoning: f, Streaming: t}
+	case hasPrefix("gpt-4o", "chatgpt-4o", "gpt-4.1"):
+		mc = service.ModelCapability{Vision: t, Tools: t, Reasoning: f, JSONSchema: t, Streaming: t}
+		mc.Audio = boolPtr(strings.Contains(m, "audio"))
+	case hasPrefix("gpt-5", "o3", "o4"):
+		mc = service.ModelCapability{Vision: t, Tools: t, Reasoning: t, JSONSchema: t, Streaming: t}
+	case hasPrefix("gemini-"):
+		mc = service.ModelCapability{Vision: t, Audio: t, Tools: t, JSONSchema: t, Streaming: t}
+		mc.Reasoning = boolPtr(hasPrefix("gemini-2.5", "gemini-3"))
+	default:
+		return mc, nil
+	}
+	return mc, []string{"defaults"}
+}
@@ -0,0 +1,299 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rakunlabs/at/internal/config"
+	"github.com/rakunlabs/at/internal/service"
+	"github.com/rakunlabs/at/internal/service/llm/common"
+)
+
+// memoryCapabilityStore is an in-memory ModelCapabilityStorer.
+type memoryCapabilityStore struct {
+	items map[string]service.ModelCapability
+}
+
+func newMemoryCapabilityStore(items ...service.ModelCapability) *memoryCapabilityStore {
+	st := &memoryCapabilityStore{items: map[string]service.ModelCapability{}}
+	for _, mc := range items {
+		_ = st.SetModelCapability(context.Background(), mc)
+	}
+	return st
+}
+
+func (m *memoryCapabilityStore) ListModelCapabilities(context.Context) ([]service.ModelCapability, error) {
+	out := make([]service.ModelCapability, 0, len(m.items))
+	for _, mc := range m.items {
+		out = append(out, mc)
+	}
+	return out, nil
+}
+
+func (m *memoryCapabilityStore) SetModelCapability(_ context.Context, c service.ModelCapability) error {
+	key := modelCapabilityKey(c.ProviderKey, c.Model)
+	if cur, ok := m.items[key]; ok {
+		c.ID = cur.ID
+	} else if c.ID == "" {
+		c.ID = key
+	}
+	m.items[key] = c
+	return nil
+}
+
+func (m *memoryCapabilityStore) DeleteModelCapability(_ context.Context, id string) error {
+	for k, mc := range m.items {
+		if mc.ID == id {
+			delete(m.items, k)
+		}
+	}
+	return nil
+}
+
+func doCapabilityChat(s *Server, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/gateway/v1/chat/completions", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer test-token")
+	rec := httptest.NewRecorder()
+	s.ChatCompletions(rec, req)
+	return rec
+}
+
+func TestChatCompletionsRejectsUnsupportedCapabilities(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		code string
+	}{
+		{
+			"tools",
+			`{"model":"gemini/small","messages":[{"role":"user","content":"hi"}],"tools":[{"type":"function","function":{"name":"lookup","parameters":{"type":"object"}}}]}`,
+			"unsupported_tools",
+		},
+		{
+			"image",
+			`{"model":"gemini/small","messages":[{"role":"user","content":[{"type":"text","text":"what is this?"},{"type":"image_url","image_url":{"url":"data:image/png;base64,AAAA"}}]}]}`,
+			"unsupported_content",
+		},
+		{
+			"context window",
+			`{"model":"gemini/small","messages":[{"role":"user","content":"` + strings.Repeat("word ", 200) + `"}]}`,
+			"context_length_exceeded",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := &messagesCaptureProvider{resp: &service.LLMResponse{Content: "ok", Finished: true}}
+			s := newMessagesTestServer(provider, "openai")
+			s.capabilityStore = newMemoryCapabilityStore(service.ModelCapability{
+				ProviderKey: "gemini", Model: "small",
+				ContextWindow: 50, Tools: boolPtr(false), Vision: boolPtr(false),
+			})
+
+			rec := doCapabilityChat(s, tt.body)
+			if rec.Code != http.StatusBadRequest {
+				t.Fatalf("status = %d; body=%s", rec.Code, rec.Body.String())
+			}
+			var body struct {
+				Error struct {
+					Code string `json:"code"`
+				} `json:"error"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if body.Error.Code != tt.code {
+				t.Errorf("code = %q, want %q", body.Error.Code, tt.code)
+			}
+			if provider.messages != nil {
+				t.Error("provider was called for a rejected request")
+			}
+		})
+	}
+}
+
+func TestChatCompletionsLeavesNearContextWindowToProvider(t *testing.T) {
+	provider := &messagesCaptureProvider{resp: &service.LLMResponse{Content: "ok", Finished: true}}
+	s := newMessagesTestServer(provider, "openai")
+	text := strings.Repeat("word ", 200)
+	estimate := common.CountTokensLocal([]service.Message{{Role: "user", Content: text}}, nil)
+	// The estimate is just over the window but within the margin.
+	s.capabilityStore = newMemoryCapabilityStore(service.ModelCapability{
+		ProviderKey: "gemini", Model: "small", ContextWindow: estimate - estimate/20,
+	})
+
+	rec := doCapabilityChat(s, `{"model":"gemini/small","messages":[{"role":"user","content":"`+text+`"}]}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d; body=%s", rec.Code, rec.Body.String())
+	}
+	if provider.messages == nil {
+		t.Error("provider was not called")
+	}
+}
+
+func TestChatCompletionsAdaptsToCapabilities(t *testing.T) {
+	provider := &messagesCaptureProvider{resp: &service.LLMResponse{Content: "ok", Finished: true}}
+	s := newMessagesTestServer(provider, "openai")
+	s.capabilityStore = newMemoryCapabilityStore(service.ModelCapability{
+		ProviderKey: "gemini", Model: "small",
+		MaxOutputTokens: 1024, Reasoning: boolPtr(false), JSONSchema: boolPtr(false),
+	})
+
+	rec := doCapabilityChat(s, `{"model":"gemini/small","messages":[{"role":"user","content":"hi"}],"max_tokens":8192,"reasoning_effort":"high","response_format":{"type":"json_schema","json_schema":{"name":"x","schema":{"type":"object"}}}}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d; body=%s", rec.Code, rec.Body.String())
+	}
+	opts := provider.opts
+	if opts == nil || opts.MaxTokens == nil || *opts.MaxTokens != 1024 {
+		t.Fatalf("max_tokens not clamped: %+v", opts)
+	}
+	if opts.ReasoningEffort != "" {
+		t.Errorf("reasoning_effort = %q, want dropped", opts.ReasoningEffort)
+	}
+	if opts.ResponseFormat["type"] != "json_object" {
+		t.Errorf("response_format = %v, want json_object", opts.ResponseFormat)
+	}
+}
+
+func TestChatCompletionsCapabilityRejectionFallsBack(t *testing.T) {
+	primary := &messagesCaptureProvider{resp: &service.LLMResponse{Content: "primary", Finished: true}}
+	backup := &messagesCaptureProvider{resp: &service.LLMResponse{Content: "backup", Finished: true}}
+	s := newMessagesTestServer(primary, "openai")
+	s.providers["backup"] = ProviderInfo{provider: backup, providerType: "openai"}
+	s.capabilityStore = newMemoryCapabilityStore(service.ModelCapability{
+		ProviderKey: "gemini", Model: "small", Tools: boolPtr(false),
+	})
+
+	rec := doCapabilityChat(s, `{"model":"gemini/small","at_fallbacks":["backup/large"],"messages":[{"role":"user","content":"hi"}],"tools":[{"type":"function","function":{"name":"lookup","parameters":{"type":"object"}}}]}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d; body=%s", rec.Code, rec.Body.String())
+	}
+	if got := rec.Header().Get("x-at-model-used"); got != "backup/large" {
+		t.Errorf("x-at-model-used = %q, want backup/large", got)
+	}
+	if primary.messages != nil {
+		t.Error("primary was called despite lacking tool support")
+	}
+}
+
+func TestListModelsIncludesCapabilities(t *testing.T) {
+	s := newMessagesTestServer(&messagesCaptureProvider{}, "gemini")
+	info := s.providers["gemini"]
+	info.models = []string{"gemini-2.5-pro", "custom"}
+	s.providers["gemini"] = info
+	s.capabilityStore = newMemoryCapabilityStore(service.ModelCapability{
+		ProviderKey: "gemini", Model: "gemini-2.5-pro",
+		ContextWindow: 1048576, MaxOutputTokens: 65536, Vision: boolPtr(true), Tools: boolPtr(true),
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/gateway/v1/models", nil)
+	req.Header.Set("Authorization", "Bearer test-token")
+	rec := httptest.NewRecorder()
+	s.ListModels(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d; body=%s", rec.Code, rec.Body.String())
+	}
+
+	var resp ModelsResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	byID := map[string]ModelData{}
+	for _, m := range resp.Data {
+		byID[m.ID] = m
+	}
+	pro := byID["gemini/gemini-2.5-pro"]
+	if pro.ContextWindow != 1048576 || pro.MaxOutputTokens != 65536 {
+		t.Errorf("limits = %d/%d", pro.ContextWindow, pro.MaxOutputTokens)
+	}
+	if !pro.Capabilities["vision"] || !pro.Capabilities["tools"] {
+		t.Errorf("capabilities = %v", pro.Capabilities)
+	}
+	if _, ok := pro.Capabilities["audio"]; ok {
+		t.Error("unknown capability was advertised")
+	}
+	if custom := byID["gemini/custom"]; custom.ContextWindow != 0 || custom.Capabilities != nil {
+		t.Errorf("custom model has metadata: %+v", custom)
+	}
+}
+
+func TestSyncModelCapabilitiesLayersSourcesAndKeepsOverrides(t *testing.T) {
+	origCatalog, origDiscover := fetchCapabilityCatalog, discoverGeminiCapabilityModels
+	t.Cleanup(func() { fetchCapabilityCatalog, discoverGeminiCapabilityModels = origCatalog, origDiscover })
+
+	fetchCapabilityCatalog = func(context.Context) ([]piDevModelPricing, error) {
+		return []piDevModelPricing{{Provider: "openai", Model: "gpt-4o", ContextWindow: 128000}}, nil
+	}
+	discoverGeminiCapabilityModels = func(context.Context, *Server, string) ([]geminiModel, error) {
+		return []geminiModel{{
+			Name:                       "models/gemini-2.5-pro",
+			InputTokenLimit:            1048576,
+			OutputTokenLimit:           65536,
+			Thinking:                   true,
+			SupportedGenerationMethods: []string{"generateContent", "streamGenerateContent"},
+		}}, nil
+	}
+
+	store := newMemoryCapabilityStore(service.ModelCapability{
+		ProviderKey: "openai", Model: "gpt-4o-mini", ContextWindow: 1000, Source: "manual", ManualOverride: true,
+	})
+	s := &Server{
+		providers: map[string]ProviderInfo{
+			"openai": {providerType: "openai", models: []string{"gpt-4o", "gpt-4o-mini", "unknown-model"}},
+			"gemini": {providerType: "gemini", models: []string{"gemini-2.5-pro"}},
+		},
+		capabilityStore: store,
+	}
+
+	resp, err := s.syncModelCapabilities(context.Background(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Applied != 2 || resp.Skipped != 2 {
+		t.Fatalf("applied/skipped = %d/%d, want 2/2 (errors: %v)", resp.Applied, resp.Skipped, resp.Errors)
+	}
+
+	gpt := store.items["openai/gpt-4o"]
+	if gpt.ContextWindow != 128000 || gpt.Vision == nil || !*gpt.Vision {
+		t.Errorf("gpt-4o = %+v", gpt)
+	}
+	if gpt.Source != "defaults,"+piDevPricingSource {
+		t.Errorf("gpt-4o source = %q", gpt.Source)
+	}
+	gem := store.items["gemini/gemini-2.5-pro"]
+	if gem.ContextWindow != 1048576 || gem.MaxOutputTokens != 65536 || gem.Streaming == nil || !*gem.Streaming {
+		t.Errorf("gemini = %+v", gem)
+	}
+	if mini := store.items["openai/gpt-4o-mini"]; mini.ContextWindow != 1000 || mini.Source != "manual" {
+		t.Errorf("manual override replaced: %+v", mini)
+	}
+
+	if _, err := s.syncModelCapabilities(context.Background(), true); err != nil {
+		t.Fatal(err)
+	}
+	if mini := store.items["openai/gpt-4o-mini"]; mini.ManualOverride {
+		t.Errorf("overwrite_overrides kept the override: %+v", mini)
+	}
+}
+
+func TestChatCompletionsAllowsToolsForEmulatedModels(t *testing.T) {
+	provider := &messagesCaptureProvider{resp: &service.LLMResponse{Content: "ok", Finished: true}}
+	s := newMessagesTestServer(provider, "openai")
+	info := s.providers["gemini"]
+	info.toolEmulation = &config.ToolEmulationConfig{Models: []string{"small"}}
+	s.providers["gemini"] = info
+	s.capabilityStore = newMemoryCapabilityStore(service.ModelCapability{
+		ProviderKey: "gemini", Model: "small", Tools: boolPtr(false),
+	})
+
+	rec := doCapabilityChat(s, `{"model":"gemini/small","messages":[{"role":"user","content":"hi"}],"tools":[{"type":"function","function":{"name":"lookup","parameters":{"type":"object"}}}]}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d; body=%s", rec.Code, rec.Body.String())
+	}
+	if len(provider.tools) != 1 {
+		t.Errorf("tools = %+v, want forwarded", provider.tools)
+	}
+}
@@ -180,6 +180,7 @@ type piDevModelPricing struct {
 	Model                string
 	Name                 string
 	Path                 string
+	ContextWindow        int
 	PromptPricePer1M     float64
 	CompletionPricePer1M float64
 	CacheReadPricePer1M  float64
@@ -301,6 +302,9 @@ func (s *Server) ApplyModelPricingSyncAPI(w http.ResponseWriter, r *http.Request
 		}
 		out.Applied++
 	}
+	if out.Applied > 0 {
+		s.invalidateModelPricing()
+	}
 
 	status := http.StatusOK
 	if len(out.Errors) > 0 && out.Applied == 0 {
@@ -399,6 +403,9 @@ func (s *Server) ImportModelPricingCatalogAPI(w http.ResponseWriter, r *http.Req
 		}
 		out.Applied++
 	}
+	if out.Applied > 0 {
+		s.invalidateModelPricing()
+	}
 
 	status := http.StatusOK
 	if len(out.Errors) > 0 && out.Applied == 0 {
@@ -523,7 +530,7 @@ func (s *Server) buildPricingPreview(ctx context.Context, source string, match f
 // catalog only publishes base input/output prices. Anthropic's five-minute
 // prompt cache charges 1.25x input for writes and 0.1x input for reads.
 func applyCachePricingDefaults(providerType string, src modelPricingSourceMatch) modelPricingSourceMatch {
-	if providerType != "anthropic" || src.PromptPricePer1M <= 0 {
+	if (providerType != "anthropic" && providerType != "vertex-anthropic") || src.PromptPricePer1M <= 0 {
 		return src
 	}
 	if src.CacheReadPricePer1M == 0 {
@@ -956,7 +963,7 @@ func matchPiDevPricing(catalog []piDevModelPricing, providerType, model string)
 
 func piDevProviderAliases(providerType string) []string {
 	switch strings.ToLower(providerType) {
-	case "anthropic", "antropic":
+	case "anthropic", "antropic", "vertex-anthropic":
 		return []string{"anthropic"}
 	case "gemini", "google":
 		return []string{"google"}
@@ -976,7 +983,7 @@ func piDevProviderAliases(providerType string) []string {
 
 func pricingProviderAliases(providerType string) []string {
 	switch strings.ToLower(providerType) {
-	case "anthropic", "antropic":
+	case "anthropic", "antropic", "vertex-anthropic":
 		return []string{"anthropic"}
 	case "gemini", "google", "vertex", "vertex-gemini", "google-vertex":
 		return []string{"google", "google-vertex"}
@@ -1158,6 +1165,7 @@ func parsePiDevModelPricing(r io.Reader) ([]piDevModelPricing, error) {
 			if inRow && t.Data == "tr" {
 				inRow = false
 				if row.Provider != "" && row.Model != "" && len(cells) >= 6 {
+					row.ContextWindow = parsePiDevTokenCount(cells[1])
 					row.PromptPricePer1M = parsePiDevPrice(cells[2])
 					row.CompletionPricePer1M = parsePiDevPrice(cells[3])
 					row.CacheReadPricePer1M = parsePiDevPrice(cells[4])
@@ -1191,3 +1199,21 @@ func parsePiDevPrice(s string) float64 {
 	}
 	return v
 }
+
+// parsePiDevTokenCount reads a context-window cell such as "200,000",
+// "128K" or "1M"; anything else is 0 (unknown).
+func parsePiDevTokenCount(s string) int {
+	s = strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(s), ",", ""))
+	mult := 1.0
+	switch {
+	case strings.HasSuffix(s, "K"):
+		mult, s = 1_000, strings.TrimSuffix(s, "K")
+	case strings.HasSuffix(s, "M"):
+		mult, s = 1_000_000, strings.TrimSuffix(s, "M")
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil || v <= 0 {
+		return 0
+	}
+	return int(v * mult)
+}
@@ -34,6 +34,9 @@ func TestParsePiDevModelPricing(t *testing.T) {
 	if got.PromptPricePer1M != 3 || got.CompletionPricePer1M != 15 || got.CacheReadPricePer1M != 0.3 || got.CacheWritePricePer1M != 3.75 {
 		t.Fatalf("prices = %+v", got)
 	}
+	if got.ContextWindow != 200000 {
+		t.Fatalf("context window = %d, want 200000", got.ContextWindow)
+	}
 }
 
 func TestParseLLMPricesModelPricing(t *testing.T) {
@@ -247,6 +250,33 @@ func TestImportModelPricingCatalogSkipsManualOverride(t *testing.T) {
 	}
 }
 
+func TestGatewayCostEstimateCachesPricing(t *testing.T) {
+	store := &pricingTestBudgetStore{pricing: []service.ModelPricing{{ProviderKey: "openai", Model: "gpt-4o", PromptPricePer1M: 1}}}
+	s := &Server{agentBudgetStore: store}
+	usage := service.Usage{PromptTokens: 1_000_000}
+
+	for range 3 {
+		if got := s.estimateGatewayUsageCostCents(context.Background(), "openai", "gpt-4o", "openai/gpt-4o", usage); got != 100 {
+			t.Fatalf("cost = %v, want 100", got)
+		}
+	}
+	if store.lists != 1 {
+		t.Errorf("pricing loaded %d times, want 1", store.lists)
+	}
+
+	// A pricing write through the API is picked up on the next call.
+	store.pricing = []service.ModelPricing{{ProviderKey: "openai", Model: "gpt-4o", PromptPricePer1M: 2}}
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/model-pricing", strings.NewReader(`{"provider_key":"openai","model":"gpt-4o","prompt_price_per_1m":2}`))
+	w := httptest.NewRecorder()
+	s.SetModelPricingAPI(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body.String())
+	}
+	if got := s.estimateGatewayUsageCostCents(context.Background(), "openai", "gpt-4o", "openai/gpt-4o", usage); got != 200 {
+		t.Errorf("cost after update = %v, want 200", got)
+	}
+}
+
 type pricingTestProvider struct {
 	content string
 }
@@ -258,6 +288,7 @@ func (p *pricingTestProvider) Chat(context.Context, string, []service.Message, [
 type pricingTestBudgetStore struct {
 	pricing []service.ModelPricing
 	set     []service.ModelPricing
+	lists   int
 }
 
 func (s *pricingTestBudgetStore) GetAgentBudget(context.Context, string) (*service.AgentBudget, error) {
@@ -285,6 +316,7 @@ func (s *pricingTestBudgetStore) GetAgentTotalSpend(context.Context, string) (fl
 }
 
 func (s *pricingTestBudgetStore) ListModelPricing(context.Context) ([]service.ModelPricing, error) {
+	s.lists++
 	return s.pricing, nil
 }
 
@@ -14,7 +14,11 @@ import (
 
 	"github.com/oklog/ulid/v2"
 	"github.com/rakunlabs/at/internal/service"
+	"github.com/rakunlabs/at/internal/service/metrics"
+	"github.com/rakunlabs/at/internal/service/tracing"
 	"github.com/rakunlabs/at/internal/service/workflow"
+	"go.opentelemetry.io/otel/attribute"
+	oteltrace "go.opentelemetry.io/otel/trace"
 )
 
 const conversationStatePrefix = "[CONVERSATION_STATE]"
@@ -146,6 +150,10 @@ func (s *Server) runOrgDelegation(ctx context.Context, org *service.Organization
 	if model == "" {
 		model = info.defaultModel
 	}
+	info.provider = s.withGuardrails(info.provider, agent.Config.GuardrailIDs, guardrailScope{
+		source: "agent", fullModel: agent.Config.Provider + "/" + model,
+		agentID: agentID, orgID: org.ID,
+	})
 
 	// d) Get direct reports.
 	reports, err := s.getDirectReports(ctx, org.ID, agentID)
@@ -527,15 +535,16 @@ func (s *Server) runOrgDelegation(ctx context.Context, org *service.Organization
 
 	// Trace identity: each runOrgDelegation invocation is one trace; the
 	// whole delegation tree groups into a session keyed by the root task
-	// ID. A parent delegation pre-mints the child's trace ID (so the
+	// ID. A parent delegation pre-mints the child's trace (so the
 	// delegate_to_* tool observation can cross-link it) and passes it via
-	// context.
+	// context; the OTel run span is then owned by the parent.
 	runTraceID := orgTraceIDFromContext(ctx)
 	if runTraceID == "" {
-		runTraceID = ulid.Make().String()
 		// Store it back so downstream helpers (completeTaskWithStatus,
 		// createDelegationTask) attribute their events to this trace.
-		ctx = contextWithOrgTraceID(ctx, runTraceID)
+		var runSpan oteltrace.Span
+		ctx, runSpan, runTraceID = startOrgRunTrace(ctx, task.ID, agentID)
+		defer runSpan.End()
 	}
 	traceSessionID := s.resolveRootTaskID(ctx, task)
 
@@ -711,7 +720,30 @@ func (s *Server) runOrgDelegation(ctx context.Context, org *service.Organization
 	// duplicate event and overwrite the tool-provided result).
 	terminalToolStatus := ""
 
+	// Each iteration gets a span under the run span; tool and provider
+	// spans nest under it. The previous iteration's span is ended when the
+	// next one starts (or when the run returns).
+	loopCtx := ctx
+	var iterSpan oteltrace.Span
+	defer func() {
+		if iterSpan != nil {
+			iterSpan.End()
+		}
+	}()
+
 	for iteration := 0; iteration < maxIterations; iteration++ {
+		metrics.AgentLoopIterations.Inc(agent.Name, "task")
+
+		if iterSpan != nil {
+			iterSpan.End()
+		}
+		ctx, span := tracing.StartSpan(loopCtx, "agent.iteration",
+			attribute.String("at.agent_id", agentID),
+			attribute.String("at.task_id", task.ID),
+			attribute.Int("at.iteration", iteration),
+		)
+		iterSpan = span
+
 		// Check context cancellation.
 		if err := ctx.Err(); err != nil {
 			slog.Warn("org-delegation: context cancelled",
@@ -752,11 +784,13 @@ func (s *Server) runOrgDelegation(ctx context.Context, org *service.Organization
 		var chatErr error
 		var latencyMs int64
 		var windowed []service.Message
-		chatOpts := s.loopGov.ChatOptions()
+		chatOpts := s.loopGov.ChatOptions(agent.Config)
 		for attempt := 0; attempt < 3; attempt++ {
 			windowed, _ = s.loopGov.LimitWithTools(ctx, agentID, task.ID, messages, llmTools)
 			callStart := time.Now()
-			resp, chatErr = info.provider.Chat(ctx, model, windowed, llmTools, chatOpts)
+			chatCtx, chatSpan := tracing.StartProviderSpan(ctx, agent.Config.Provider, model, attempt+1)
+			resp, chatErr = info.provider.Chat(chatCtx, model, windowed, llmTools, chatOpts)
+			tracing.EndProviderSpan(chatSpan, resp, chatErr)
 			latencyMs = time.Since(callStart).Milliseconds()
 			if chatErr == nil {
 				break
@@ -975,10 +1009,12 @@ func (s *Server) runOrgDelegation(ctx context.Context, org *service.Organization
 		var wg sync.WaitGroup
 		var resultMu sync.Mutex
 
-		// recordToolObs records a tool observation parented to this
-		// iteration's generation (skill / builtin / MCP / unknown tools;
-		// delegation tools record inline to attach child-trace links).
-		recordToolObs := func(name string, args map[string]any, output string, hasErr bool, latencyMs int64) {
+		// recordToolObs ends the tool's span and records a tool
+		// observation parented to this iteration's generation (skill /
+		// builtin / MCP / unknown tools; delegation tools record inline to
+		// attach child-trace links).
+		recordToolObs := func(span oteltrace.Span, name string, args map[string]any, output string, hasErr bool, latencyMs int64) {
+			tracing.EndToolSpan(span, output, hasErr)
 			level := service.ObservationLevelDefault
 			if hasErr {
 				level = service.ObservationLevelError
@@ -1007,6 +1043,7 @@ func (s *Server) runOrgDelegation(ctx context.Context, org *service.Organization
 			toolStarted := time.Now()
 			slog.Debug("org-delegation: tool call",
 				"tool", tc.Name, "task_id", task.ID, "iteration", iteration)
+			ctx, toolSpan := tracing.StartToolSpan(ctx, tc.Name, "agent", tc.Arguments)
 
 			if reportAgentID, ok := delegateToolMap[tc.Name]; ok {
 				delegatedAgents[reportAgentID] = true
@@ -1028,11 +1065,13 @@ func (s *Server) runOrgDelegation(ctx context.Context, org *service.Organization
 
 					childTask, err := s.createDelegationTask(ctx, org, task, targetAgentID, taskText, depth)
 					if err != nil {
+						errResult := fmt.Sprintf("Error: failed to create delegation task: %v", err)
+						tracing.EndToolSpan(toolSpan, errResult, true)
 						resultMu.Lock()
 						toolResults[idx] = service.ContentBlock{
 							Type:      "tool_result",
 							ToolUseID: toolCall.ID,
-							Content:   fmt.Sprintf("Error: failed to create delegation task: %v", err),
+							Content:   errResult,
 						}
 						resultMu.Unlock()
 						return
@@ -1042,13 +1081,14 @@ func (s *Server) runOrgDelegation(ctx context.Context, org *service.Organization
 						"parent_task", task.ID, "child_task", childTask.ID,
 						"from_agent", agentID, "to_agent", targetAgentID, "depth", depth+1)
 
-					// Pre-mint the child run's trace ID so this tool
+					// Pre-mint the child run's trace so this tool
 					// observation can cross-link the child trace.
-					childTraceID := ulid.Make().String()
-					childCtx := contextWithOrgTraceID(ctx, childTraceID)
+					childCtx, childSpan, childTraceID := startOrgRunTrace(ctx, childTask.ID, targetAgentID)
 
 					var result string
-					if delegErr := s.runOrgDelegation(childCtx, org, childTask, targetAgentID, depth+1); delegErr != nil {
+					delegErr := s.runOrgDelegation(childCtx, org, childTask, targetAgentID, depth+1)
+					tracing.EndSpan(childSpan, delegErr)
+					if delegErr != nil {
 						result = fmt.Sprintf("Error: delegation failed: %v", delegErr)
 					} else {
 						updated, getErr := s.taskStore.GetTask(ctx, childTask.ID)
@@ -1077,6 +1117,8 @@ func (s *Server) runOrgDelegation(ctx context.Context, org *service.Organization
 					// Observation: delegation tool call, parented to the
 					// generation that requested it and cross-linked to the
 					// child run's trace.
+					toolSpan.SetAttributes(attribute.String("at.child_trace_id", childTraceID))
+					tracing.EndToolSpan(toolSpan, result, delegErr != nil)
 					argsJSON, _ := json.Marshal(toolCall.Arguments)
 					s.recordLLMCallAsync(ctx, llmAuditParams{
 						source:              "agent",
@@ -1158,7 +1200,7 @@ func (s *Server) runOrgDelegation(ctx context.Context, org *service.Organization
 
 				// Observation: skill tool call (JS/bash handler) with its
 				// arguments and (post-truncation) result.
-				recordToolObs(tc.Name, tc.Arguments, result, callErr != nil, time.Since(toolStarted).Milliseconds())
+				recordToolObs(toolSpan, tc.Name, tc.Arguments, result, callErr != nil, time.Since(toolStarted).Milliseconds())
 			} else if _, ok := builtinToolMap[tc.Name]; ok {
 				// Builtin tool — execute via dispatchBuiltinTool.
 				var result string
@@ -1201,7 +1243,7 @@ func (s *Server) runOrgDelegation(ctx context.Context, org *service.Organization
 				// Observation: builtin tool call (task_create /
 				// bash_execute / mem_save ...) with structured input and
 				// the (truncated) output that got fed back into the LLM.
-				recordToolObs(tc.Name, tc.Arguments, result, callErr != nil, time.Since(toolStarted).Milliseconds())
+				recordToolObs(toolSpan, tc.Name, tc.Arguments, result, callErr != nil, time.Since(toolStarted).Milliseconds())
 			} else if setName, ok := mcpSetToolMap[tc.Name]; ok {
 				// MCP-set tool resolved server-side (workflow exposed as a
 				// wf_* tool, or a skill/builtin/HTTP tool declared via
@@ -1218,7 +1260,7 @@ func (s *Server) runOrgDelegation(ctx context.Context, org *service.Organization
 					ToolUseID: tc.ID,
 					Content:   result,
 				}
-				recordToolObs(tc.Name, tc.Arguments, result, callErr != nil, time.Since(toolStarted).Milliseconds())
+				recordToolObs(toolSpan, tc.Name, tc.Arguments, result, callErr != nil, time.Since(toolStarted).Milliseconds())
 			} else if mcpToolNames[tc.Name] {
 				// MCP tool served by a connected client (HTTP endpoint or a
 				// stdio/HTTP upstream such as the ElevenLabs MCP).
@@ -1234,7 +1276,7 @@ func (s *Server) runOrgDelegation(ctx context.Context, org *service.Organization
 					ToolUseID: tc.ID,
 					Content:   result,
 				}
-				recordToolObs(tc.Name, tc.Arguments, result, callErr != nil, time.Since(toolStarted).Milliseconds())
+				recordToolObs(toolSpan, tc.Name, tc.Arguments, result, callErr != nil, time.Since(toolStarted).Milliseconds())
 			} else {
 				// Unknown tool — handle synchronously (no goroutine needed).
 				toolResults[i] = service.ContentBlock{
@@ -1246,7 +1288,7 @@ func (s *Server) runOrgDelegation(ctx context.Context, org *service.Organization
 				// Observation: unknown tool call. The output is the
 				// synthetic error message we fed back to the LLM — useful
 				// for spotting agents calling tools they don't have.
-				recordToolObs(tc.Name, tc.Arguments, fmt.Sprintf("Error: unknown tool %q", tc.Name), true, time.Since(toolStarted).Milliseconds())
+				recordToolObs(toolSpan, tc.Name, tc.Arguments, fmt.Sprintf("Error: unknown tool %q", tc.Name), true, time.Since(toolStarted).Milliseconds())
 			}
 		}
 
@@ -1551,6 +1593,18 @@ func orgTraceIDFromContext(ctx context.Context) string {
 	return ""
 }
 
+// startOrgRunTrace starts the root span of one delegation run (linked to
+// the span in ctx, e.g. the parent's delegate tool call) and stores the
+// run's trace ID in the returned context. The caller ends the span once
+// the run returns.
+func startOrgRunTrace(ctx context.Context, taskID, agentID string) (context.Context, oteltrace.Span, string) {
+	ctx, span, traceID := startRunTrace(ctx, "agent.run",
+		attribute.String("at.task_id", taskID),
+		attribute.String("at.agent_id", agentID),
+	)
+	return contextWithOrgTraceID(ctx, traceID), span, traceID
+}
+
 // resolveRootTaskID walks up the ParentID chain to find the root task ID.
 // This is used to create a shared workspace directory for the entire delegation chain.
 func (s *Server) resolveRootTaskID(ctx context.Context, task *service.Task) string {
@@ -0,0 +1,132 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/rakunlabs/at/internal/service"
+)
+
+// ─── Provider model management ───
+//
+// Providers that host their own models (Ollama) implement
+// service.ModelManager. These endpoints let the provider page and the
+// builtin tools list, pull and delete models on the live provider.
+
+// modelManagerFor returns the live provider behind key as a ModelManager.
+// The returned status is the HTTP status to use when it is not one.
+func (s *Server) modelManagerFor(key string) (service.ModelManager, int, error) {
+	info, ok := s.getProviderInfo(key)
+	if !ok {
+		return nil, http.StatusNotFound, fmt.Errorf("provider %q not found", key)
+	}
+	mm, ok := info.provider.(service.ModelManager)
+	if !ok {
+		return nil, http.StatusBadRequest, fmt.Errorf("provider %q (type %s) does not support model management", key, info.providerType)
+	}
+	return mm, 0, nil
+}
+
+// ListProviderModelsAPI handles GET /api/v1/providers/{key}/models.
+// It returns the models installed on the provider.
+func (s *Server) ListProviderModelsAPI(w http.ResponseWriter, r *http.Request) {
+	mm, status, err := s.modelManagerFor(r.PathValue("key"))
+	if err != nil {
+		httpResponse(w, err.Error(), status)
+		return
+	}
+
+	models, err := mm.ListModels(r.Context())
+	if err != nil {
+		httpResponse(w, fmt.Sprintf("failed to list models: %v", err), http.StatusBadGateway)
+		return
+	}
+	if models == nil {
+		models = []string{}
+	}
+
+	httpResponseJSON(w, discoverResponse{Models: models}, http.StatusOK)
+}
+
+type pullModelRequest struct {
+	Model string `json:"model"`
+}
+
+// PullProviderModelAPI handles POST /api/v1/providers/{key}/models/pull.
+// Pulls take minutes, so progress is streamed as SSE `data:` events
+// (service.ModelPullProgress). The last event is {"status":"success"} or
+// {"error":"..."}.
+func (s *Server) PullProviderModelAPI(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+	mm, status, err := s.modelManagerFor(key)
+	if err != nil {
+		httpResponse(w, err.Error(), status)
+		return
+	}
+
+	var req pullModelRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpResponse(w, fmt.Sprintf("invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+	req.Model = strings.TrimSpace(req.Model)
+	if req.Model == "" {
+		httpResponse(w, "model is required", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		httpResponse(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	writeSSE := func(data any) {
+		jsonData, _ := json.Marshal(data)
+		fmt.Fprintf(w, "data: %s\n\n", jsonData)
+		flusher.Flush()
+	}
+
+	err = mm.PullModel(r.Context(), req.Model, func(p service.ModelPullProgress) {
+		writeSSE(p)
+	})
+	if err != nil {
+		slog.Warn("pull model failed", "provider", key, "model", req.Model, "error", err)
+		writeSSE(map[string]string{"error": err.Error()})
+		return
+	}
+	slog.Info("model pulled", "provider", key, "model", req.Model)
+}
+
+// DeleteProviderModelAPI handles DELETE /api/v1/providers/{key}/models?model=...
+// The model name goes in the query because Ollama names may contain "/".
+func (s *Server) DeleteProviderModelAPI(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+	mm, status, err := s.modelManagerFor(key)
+	if err != nil {
+		httpResponse(w, err.Error(), status)
+		return
+	}
+
+	model := strings.TrimSpace(r.URL.Query().Get("model"))
+	if model == "" {
+		httpResponse(w, "model query parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	if err := mm.DeleteModel(r.Context(), model); err != nil {
+		httpResponse(w, fmt.Sprintf("failed to delete model: %v", err), http.StatusBadGateway)
+		return
+	}
+	slog.Info("model deleted", "provider", key, "model", model)
+
+	httpResponse(w, "deleted", http.StatusOK)
+}
@@ -0,0 +1,175 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rakunlabs/at/internal/config"
+	"github.com/rakunlabs/at/internal/service"
+	"github.com/rakunlabs/at/internal/service/llm/ollama"
+)
+
+// newOllamaStandIn serves the /api/tags, /api/pull and /api/delete
+// endpoints of a local Ollama with an in-memory model list.
+func newOllamaStandIn(t *testing.T) *httptest.Server {
+	t.Helper()
+	installed := map[string]bool{"llama3.2:latest": true, "nomic-embed-text:latest": true}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Model string `json:"model"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		switch r.URL.Path {
+		case "/api/tags":
+			var models []map[string]string
+			for name := range installed {
+				models = append(models, map[string]string{"name": name})
+			}
+			_ = json.NewEncoder(w).Encode(map[string]any{"models": models})
+		case "/api/pull":
+			if body.Model == "nope" {
+				_, _ = w.Write([]byte(`{"error":"pull model manifest: file does not exist"}` + "\n"))
+				return
+			}
+			installed[body.Model+":latest"] = true
+			_, _ = w.Write([]byte(`{"status":"pulling manifest"}` + "\n" +
+				`{"status":"downloading","digest":"sha256:1","total":10,"completed":10}` + "\n" +
+				`{"status":"success"}` + "\n"))
+		case "/api/delete":
+			if !installed[body.Model] {
+				w.WriteHeader(http.StatusNotFound)
+				_, _ = w.Write([]byte(`{"error":"model not found"}`))
+				return
+			}
+			delete(installed, body.Model)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func newModelManagerTestServer(t *testing.T) *Server {
+	t.Helper()
+	p, err := ollama.New("", "llama3.2", newOllamaStandIn(t).URL, "", false)
+	if err != nil {
+		t.Fatalf("ollama.New: %v", err)
+	}
+	return &Server{
+		providers: map[string]ProviderInfo{
+			"local":  {provider: p, providerType: "ollama"},
+			"openai": {provider: &embeddingCaptureProvider{}, providerType: "openai"},
+		},
+	}
+}
+
+func listProviderModels(t *testing.T, s *Server, key string) (int, []string) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/providers/"+key+"/models", nil)
+	req.SetPathValue("key", key)
+	rec := httptest.NewRecorder()
+	s.ListProviderModelsAPI(rec, req)
+
+	var resp discoverResponse
+	_ = json.NewDecoder(rec.Body).Decode(&resp)
+	return rec.Code, resp.Models
+}
+
+func TestProviderModelsAPI(t *testing.T) {
+	s := newModelManagerTestServer(t)
+
+	if code, models := listProviderModels(t, s, "local"); code != http.StatusOK || len(models) != 2 {
+		t.Fatalf("list = %d %v", code, models)
+	}
+	if code, _ := listProviderModels(t, s, "openai"); code != http.StatusBadRequest {
+		t.Errorf("list on openai = %d, want 400", code)
+	}
+	if code, _ := listProviderModels(t, s, "missing"); code != http.StatusNotFound {
+		t.Errorf("list on missing = %d, want 404", code)
+	}
+
+	// Pull streams progress as SSE and ends with "success".
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/providers/local/models/pull", strings.NewReader(`{"model":"qwen3"}`))
+	req.SetPathValue("key", "local")
+	rec := httptest.NewRecorder()
+	s.PullProviderModelAPI(rec, req)
+	if ct := rec.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("content-type = %q; body=%s", ct, rec.Body.String())
+	}
+	var events []service.ModelPullProgress
+	for _, line := range strings.Split(rec.Body.String(), "\n") {
+		if data, ok := strings.CutPrefix(line, "data: "); ok {
+			var ev service.ModelPullProgress
+			_ = json.Unmarshal([]byte(data), &ev)
+			events = append(events, ev)
+		}
+	}
+	if len(events) != 3 || events[len(events)-1].Status != "success" {
+		t.Errorf("events = %+v", events)
+	}
+	if _, models := listProviderModels(t, s, "local"); len(models) != 3 {
+		t.Errorf("models after pull = %v", models)
+	}
+
+	// A failed pull ends the stream with an error event.
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/providers/local/models/pull", strings.NewReader(`{"model":"nope"}`))
+	req.SetPathValue("key", "local")
+	rec = httptest.NewRecorder()
+	s.PullProviderModelAPI(rec, req)
+	if !strings.Contains(rec.Body.String(), `"error":"ollama pull nope: pull model manifest: file does not exist"`) {
+		t.Errorf("failed pull body = %s", rec.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodDelete, "/api/v1/providers/local/models?model=qwen3:latest", nil)
+	req.SetPathValue("key", "local")
+	rec = httptest.NewRecorder()
+	s.DeleteProviderModelAPI(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("delete = %d %s", rec.Code, rec.Body.String())
+	}
+	if _, models := listProviderModels(t, s, "local"); len(models) != 2 {
+		t.Errorf("models after delete = %v", models)
+	}
+
+	req = httptest.NewRequest(http.MethodDelete, "/api/v1/providers/local/models?model=qwen3:latest", nil)
+	req.SetPathValue("key", "local")
+	rec = httptest.NewRecorder()
+	s.DeleteProviderModelAPI(rec, req)
+	if rec.Code != http.StatusBadGateway {
+		t.Errorf("second delete = %d, want 502", rec.Code)
+	}
+}
+
+func TestProviderModelTools(t *testing.T) {
+	s := newModelManagerTestServer(t)
+	ctx := context.Background()
+
+	if _, err := s.execProviderPullModel(ctx, map[string]any{"key": "local", "model": "qwen3"}); err != nil {
+		t.Fatalf("pull: %v", err)
+	}
+	if _, err := s.execProviderPullModel(ctx, map[string]any{"key": "openai", "model": "qwen3"}); err == nil {
+		t.Error("pull on a provider without model management should fail")
+	}
+	if _, err := s.execProviderDeleteModel(ctx, map[string]any{"key": "local", "model": "qwen3:latest"}); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := s.execProviderDeleteModel(ctx, map[string]any{"key": "local"}); err == nil {
+		t.Error("delete without model should fail")
+	}
+}
+
+func TestDiscoverOllamaModels(t *testing.T) {
+	srv := newOllamaStandIn(t)
+	models, err := discoverOllamaModels(context.Background(), config.LLMConfig{Type: "ollama", BaseURL: srv.URL})
+	if err != nil || len(models) != 2 {
+		t.Fatalf("discover = %v, %v", models, err)
+	}
+	if embed := filterEmbeddingModelIDs(models); len(embed) != 1 || embed[0] != "nomic-embed-text:latest" {
+		t.Errorf("embedding models = %v", embed)
+	}
+}
@@ -5,10 +5,12 @@ import (
 	"fmt"
 	"log/slog"
 	"net/http"
+	"slices"
 	"strings"
 
 	"github.com/rakunlabs/at/internal/config"
 	"github.com/rakunlabs/at/internal/service"
+	"github.com/rakunlabs/at/internal/service/llm/keypool"
 	"github.com/rakunlabs/at/internal/service/workflow"
 	"github.com/rakunlabs/query"
 )
@@ -102,6 +104,24 @@ func validateRateLimitConfig(rl *config.RateLimitConfig) string {
 	return ""
 }
 
+// validateAPIKeys checks the api_keys pool: unique names and valid
+// per-key rate limits.
+func validateAPIKeys(keys []config.APIKeyConfig) string {
+	seen := make(map[string]bool, len(keys))
+	for i, k := range keys {
+		if k.Name != "" {
+			if seen[k.Name] {
+				return fmt.Sprintf("api_keys: duplicate name %q", k.Name)
+			}
+			seen[k.Name] = true
+		}
+		if msg := validateRateLimitConfig(k.RateLimit); msg != "" {
+			return fmt.Sprintf("api_keys[%d].%s", i, msg)
+		}
+	}
+	return ""
+}
+
 // providerResponse wraps a single provider record for JSON output.
 type providerResponse struct {
 	service.ProviderRecord
@@ -144,6 +164,51 @@ func (s *Server) ListProvidersAPI(w http.ResponseWriter, r *http.Request) {
 	httpResponseJSON(w, result, http.StatusOK)
 }
 
+// keyUsageReporter is implemented by providers backed by an API key pool.
+type keyUsageReporter interface {
+	KeyUsage() []keypool.Usage
+}
+
+// ProviderKeyUsageAPI handles GET /api/v1/providers/{key}/key-usage.
+// It returns the per-key usage of a provider configured with api_keys.
+// Served requests and tokens are summed from the cost events, which carry
+// the key label, so they survive restarts and cover every instance.
+// Errors, rate limits and cooldowns are this instance's live pool state.
+func (s *Server) ProviderKeyUsageAPI(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+	info, ok := s.getProviderInfo(key)
+	if !ok {
+		httpResponse(w, fmt.Sprintf("provider %q not found", key), http.StatusNotFound)
+		return
+	}
+
+	usage := []keypool.Usage{}
+	if reporter, ok := info.provider.(keyUsageReporter); ok {
+		usage = reporter.KeyUsage()
+	}
+
+	if s.costEventStore != nil && len(usage) > 0 {
+		rows, err := s.costEventStore.GetUsageGrouped(r.Context(), service.UsageFilter{Providers: []string{key}}, "api_key", 0)
+		if err != nil {
+			slog.Error("load provider key usage failed", "provider", key, "error", err)
+			httpResponse(w, fmt.Sprintf("failed to load key usage: %v", err), http.StatusInternalServerError)
+			return
+		}
+		recorded := make(map[string]service.UsageSummary, len(rows))
+		for _, row := range rows {
+			recorded[row.Key] = row
+		}
+		for i := range usage {
+			row := recorded[usage[i].Name]
+			usage[i].Requests = row.RequestCount
+			usage[i].PromptTokens = row.InputTokens
+			usage[i].CompletionTokens = row.OutputTokens
+		}
+	}
+
+	httpResponseJSON(w, map[string]any{"keys": usage}, http.StatusOK)
+}
+
 // GetProviderAPI handles GET /api/v1/providers/:key.
 func (s *Server) GetProviderAPI(w http.ResponseWriter, r *http.Request) {
 	if s.store == nil {
@@ -209,6 +274,10 @@ func (s *Server) CreateProviderAPI(w http.ResponseWriter, r *http.Request) {
 		httpResponse(w, msg, http.StatusBadRequest)
 		return
 	}
+	if msg := validateAPIKeys(req.Config.APIKeys); msg != "" {
+		httpResponse(w, msg, http.StatusBadRequest)
+		return
+	}
 
 	// Check if provider already exists.
 	existing, err := s.store.GetProvider(r.Context(), req.Key)
@@ -275,6 +344,10 @@ func (s *Server) UpdateProviderAPI(w http.ResponseWriter, r *http.Request) {
 		httpResponse(w, msg, http.StatusBadRequest)
 		return
 	}
+	if msg := validateAPIKeys(req.Config.APIKeys); msg != "" {
+		httpResponse(w, msg, http.StatusBadRequest)
+		return
+	}
 
 	// Preserve managed OAuth fields that redacted UI/API responses omit.
 	existing, err := s.store.GetProvider(r.Context(), key)
@@ -349,6 +422,15 @@ func redactProviderRecord(rec *service.ProviderRecord) {
 	if rec.Config.RefreshToken != "" {
 		rec.Config.RefreshToken = "***"
 	}
+	if len(rec.Config.APIKeys) > 0 {
+		keys := slices.Clone(rec.Config.APIKeys)
+		for i := range keys {
+			if keys[i].Key != "" {
+				keys[i].Key = "***"
+			}
+		}
+		rec.Config.APIKeys = keys
+	}
 }
 
 func preserveProviderManagedAuth(next *config.LLMConfig, existing config.LLMConfig) {
@@ -358,6 +440,7 @@ func preserveProviderManagedAuth(next *config.LLMConfig, existing config.LLMConf
 	if next.APIKey == "" {
 		next.APIKey = existing.APIKey
 	}
+	preserveAPIKeys(next.APIKeys, existing.APIKeys)
 	if next.RefreshToken == "" {
 		next.RefreshToken = existing.RefreshToken
 	}
@@ -374,3 +457,20 @@ func preserveProviderManagedAuth(next *config.LLMConfig, existing config.LLMConf
 		next.ExtraHeaders["ChatGPT-Account-ID"] = existing.ExtraHeaders["ChatGPT-Account-ID"]
 	}
 }
+
+// preserveAPIKeys restores pool keys sent back empty or redacted from the
+// stored entry with the same name, or the same position for unnamed keys.
+func preserveAPIKeys(next, existing []config.APIKeyConfig) {
+	for i := range next {
+		if next[i].Key != "" && next[i].Key != "***" {
+			continue
+		}
+		next[i].Key = ""
+		for j, old := range existing {
+			if (next[i].Name != "" && old.Name == next[i].Name) || (next[i].Name == "" && old.Name == "" && i == j) {
+				next[i].Key = old.Key
+				break
+			}
+		}
+	}
+}
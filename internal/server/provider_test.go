@@ -1,12 +1,18 @@
 package server
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/rakunlabs/at/internal/config"
+	"github.com/rakunlabs/at/internal/service"
+	"github.com/rakunlabs/at/internal/service/llm/keypool"
 )
 
 func TestInfoAPIIncludesBuildMetadata(t *testing.T) {
@@ -34,3 +40,176 @@ func TestInfoAPIIncludesBuildMetadata(t *testing.T) {
 		t.Fatalf("unexpected build metadata: version=%q commit=%q build_date=%q", response.Version, response.Commit, response.BuildDate)
 	}
 }
+
+func TestProviderAPIKeysRedactAndPreserve(t *testing.T) {
+	stored := config.LLMConfig{
+		Type: "openai",
+		APIKeys: []config.APIKeyConfig{
+			{Name: "a", Key: "sk-a"},
+			{Key: "sk-unnamed"},
+		},
+	}
+
+	rec := service.ProviderRecord{Key: "openai", Config: stored}
+	redactProviderRecord(&rec)
+	if rec.Config.APIKeys[0].Key != "***" || rec.Config.APIKeys[1].Key != "***" {
+		t.Fatalf("keys not redacted: %+v", rec.Config.APIKeys)
+	}
+	if stored.APIKeys[0].Key != "sk-a" {
+		t.Fatalf("redaction mutated the stored config")
+	}
+
+	// The UI echoes redacted keys back or leaves them empty, and may add
+	// new keys. Named keys match by name, unnamed keys by position.
+	next := config.LLMConfig{
+		Type: "openai",
+		APIKeys: []config.APIKeyConfig{
+			{Name: "a", Key: ""},
+			{Key: "***"},
+			{Name: "b", Key: "sk-b"},
+			{Name: "c", Key: "***"},
+		},
+	}
+	preserveProviderManagedAuth(&next, stored)
+	got := []string{next.APIKeys[0].Key, next.APIKeys[1].Key, next.APIKeys[2].Key, next.APIKeys[3].Key}
+	if want := []string{"sk-a", "sk-unnamed", "sk-b", ""}; !slices.Equal(got, want) {
+		t.Errorf("keys = %q, want %q", got, want)
+	}
+}
+
+func TestValidateAPIKeys(t *testing.T) {
+	if msg := validateAPIKeys([]config.APIKeyConfig{{Name: "a"}, {Name: "a"}}); msg == "" {
+		t.Error("duplicate names should be rejected")
+	}
+	if msg := validateAPIKeys([]config.APIKeyConfig{{Name: "a", RateLimit: &config.RateLimitConfig{RequestsPerMinute: -1}}}); msg != "api_keys[0].rate_limit.requests_per_minute must be >= 0" {
+		t.Errorf("msg = %q", msg)
+	}
+	if msg := validateAPIKeys([]config.APIKeyConfig{{Key: "x"}, {Key: "y"}}); msg != "" {
+		t.Errorf("unnamed keys should be valid, got %q", msg)
+	}
+}
+
+// keyUsageCostStore records cost events and groups them by API key.
+type keyUsageCostStore struct {
+	videoCostStore
+}
+
+func (s *keyUsageCostStore) GetUsageGrouped(_ context.Context, filter service.UsageFilter, groupBy string, _ int) ([]service.UsageSummary, error) {
+	if groupBy != "api_key" {
+		return nil, nil
+	}
+	byKey := map[string]*service.UsageSummary{}
+	var out []service.UsageSummary
+	for _, e := range s.recorded() {
+		if !slices.Contains(filter.Providers, e.Provider) {
+			continue
+		}
+		row, ok := byKey[e.APIKey]
+		if !ok {
+			row = &service.UsageSummary{Key: e.APIKey}
+			byKey[e.APIKey] = row
+		}
+		row.RequestCount++
+		row.InputTokens += e.InputTokens
+		row.OutputTokens += e.OutputTokens
+	}
+	for _, row := range byKey {
+		out = append(out, *row)
+	}
+	return out, nil
+}
+
+func TestProviderKeyUsageComesFromCostEvents(t *testing.T) {
+	resp := &service.LLMResponse{Content: "ok", Finished: true, Usage: service.Usage{PromptTokens: 7, CompletionTokens: 3}}
+	pool, err := keypool.New("gemini", []keypool.Member{
+		{Name: "a", Provider: &messagesCaptureProvider{resp: resp}},
+		{Name: "b", Provider: &messagesCaptureProvider{resp: resp}},
+	})
+	if err != nil {
+		t.Fatalf("keypool.New: %v", err)
+	}
+	store := &keyUsageCostStore{}
+	s := newMessagesTestServer(pool, "openai")
+	s.costEventStore = store
+
+	for range 3 {
+		if rec := doCapabilityChat(s, `{"model":"gemini/m","messages":[{"role":"user","content":"hi"}]}`); rec.Code != http.StatusOK {
+			t.Fatalf("status = %d; body=%s", rec.Code, rec.Body.String())
+		}
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for len(store.recorded()) < 3 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	for _, e := range store.recorded() {
+		if e.APIKey != "a" && e.APIKey != "b" {
+			t.Fatalf("cost event api_key = %q, want a pool key", e.APIKey)
+		}
+	}
+
+	// A rebuilt pool (provider reload, restart) starts with empty counters;
+	// the report still comes from the recorded events.
+	fresh, _ := keypool.New("gemini", []keypool.Member{
+		{Name: "a", Provider: &messagesCaptureProvider{resp: resp}},
+		{Name: "b", Provider: &messagesCaptureProvider{resp: resp}},
+	})
+	s.providers["gemini"] = ProviderInfo{provider: fresh, providerType: "openai"}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/providers/gemini/key-usage", nil)
+	req.SetPathValue("key", "gemini")
+	rec := httptest.NewRecorder()
+	s.ProviderKeyUsageAPI(rec, req)
+	var got struct {
+		Keys []keypool.Usage `json:"keys"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("decode: %v; body=%s", err, rec.Body.String())
+	}
+	var requests, prompt int64
+	for _, k := range got.Keys {
+		requests += k.Requests
+		prompt += k.PromptTokens
+	}
+	if len(got.Keys) != 2 || requests != 3 || prompt != 21 {
+		t.Errorf("key usage = %+v, want 3 requests and 21 prompt tokens over keys a and b", got.Keys)
+	}
+}
+
+func TestPooledProviderKeepsMemberCapabilities(t *testing.T) {
+	// choicesTestProvider has neither ChatStream nor any media interface.
+	pool, err := keypool.New("p", []keypool.Member{
+		{Name: "a", Provider: &choicesTestProvider{}},
+		{Name: "b", Provider: &choicesTestProvider{}},
+	})
+	if err != nil {
+		t.Fatalf("keypool.New: %v", err)
+	}
+	pooled := newChoicesTestServer(pool)
+	bare := newChoicesTestServer(&choicesTestProvider{})
+
+	// Streaming is emulated from Chat, as for the bare provider.
+	rec := doCapabilityChat(pooled, `{"model":"p/m","stream":true,"messages":[{"role":"user","content":"hi"}]}`)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "answer 1") || !strings.Contains(rec.Body.String(), "[DONE]") {
+		t.Fatalf("stream status = %d; body=%s", rec.Code, rec.Body.String())
+	}
+
+	for _, path := range []string{"/gateway/v1/images/generations", "/gateway/v1/embeddings"} {
+		body := `{"model":"p/m","prompt":"x","input":"x"}`
+		got, want := httptest.NewRecorder(), httptest.NewRecorder()
+		for _, c := range []struct {
+			s   *Server
+			rec *httptest.ResponseRecorder
+		}{{pooled, got}, {bare, want}} {
+			req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+			req.Header.Set("Authorization", "Bearer test-token")
+			if strings.HasSuffix(path, "embeddings") {
+				c.s.Embeddings(c.rec, req)
+			} else {
+				c.s.Images(c.rec, req)
+			}
+		}
+		if got.Code != want.Code || got.Body.String() != want.Body.String() {
+			t.Errorf("%s: pooled = %d %s, bare = %d %s", path, got.Code, got.Body.String(), want.Code, want.Body.String())
+		}
+	}
+}
@@ -7,6 +7,8 @@ import (
 	"time"
 
 	"github.com/oklog/ulid/v2"
+
+	"github.com/rakunlabs/at/internal/service/metrics"
 )
 
 // activeRun tracks a single in-flight workflow execution.
@@ -46,9 +48,12 @@ func (s *Server) registerRun(parent context.Context, workflowID, source string)
 		Cancel:     cancel,
 	}
 	s.activeRuns.Store(runID, run)
+	metrics.WorkflowRunsActive.Inc(source)
 
 	cleanup := func() {
-		s.activeRuns.Delete(runID)
+		if _, ok := s.activeRuns.LoadAndDelete(runID); ok {
+			metrics.WorkflowRunsActive.Dec(source)
+		}
 		cancel()
 	}
 
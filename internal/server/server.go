@@ -10,16 +10,19 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/oklog/ulid/v2"
 	"github.com/rakunlabs/ada"
 	"github.com/rakunlabs/at/internal/cluster"
 	"github.com/rakunlabs/at/internal/config"
 	"github.com/rakunlabs/at/internal/service"
 	"github.com/rakunlabs/at/internal/service/container"
 	"github.com/rakunlabs/at/internal/service/loopgov"
+	"github.com/rakunlabs/at/internal/service/metrics"
 	"github.com/rakunlabs/at/internal/service/workflow"
 
 	mfolder "github.com/rakunlabs/ada/handler/folder"
@@ -55,6 +58,29 @@ type ProviderInfo struct {
 	//     "no rate-limit config at all" which we treat the same.
 	//   - -1 means "no cap" (sleep whatever upstream says).
 	retryAfterCap time.Duration
+
+	// rateLimit is the provider's configured rate-limit policy (nil when
+	// unlimited). The limiter itself lives in the provider client; batch
+	// workers read it to size their concurrency.
+	rateLimit *config.RateLimitConfig
+
+	// toolEmulation is the provider's prompt-based tool-calling config
+	// (nil when tools are native). Capability checks let tool requests
+	// through for emulated models.
+	toolEmulation *config.ToolEmulationConfig
+
+	// hookIDs are the gateway hooks run on chat completion requests routed
+	// to this provider.
+	hookIDs []string
+}
+
+// emulatesTools reports whether the provider emulates tool calling for
+// model.
+func (p ProviderInfo) emulatesTools(model string) bool {
+	if p.toolEmulation == nil {
+		return false
+	}
+	return len(p.toolEmulation.Models) == 0 || slices.Contains(p.toolEmulation.Models, model)
 }
 
 // RetryAfterCap returns the duration to cap an upstream Retry-After at.
@@ -64,8 +90,10 @@ func (p ProviderInfo) RetryAfterCap() time.Duration {
 }
 
 // ProviderFactory is a function that creates an LLMProvider from an LLMConfig.
-// This is injected from main.go so the server can hot-reload providers.
-type ProviderFactory func(cfg config.LLMConfig) (service.LLMProvider, error)
+// key is the provider key the instance is registered under (used to label
+// per-provider metrics). This is injected from main.go so the server can
+// hot-reload providers.
+type ProviderFactory func(key string, cfg config.LLMConfig) (service.LLMProvider, error)
 
 type Server struct {
 	config config.Server
@@ -226,6 +254,56 @@ type Server struct {
 	// Idempotency-Key header (5-minute TTL). Per-token scoped.
 	idempotency *idempotencyCache
 
+	// responseCache serves repeated identical gateway requests for tokens
+	// with cache_enabled. Nil disables caching.
+	responseCache *responseCache
+
+	// responseStore persists Responses API results for previous_response_id
+	// and the retrieval endpoints. Nil disables storage.
+	responseStore     service.StoredResponseStorer
+	storedResponseTTL time.Duration
+
+	// batchStore persists Files/Batch API files and jobs. Nil disables
+	// the endpoints.
+	batchStore   service.BatchStorer
+	batchFileTTL time.Duration
+
+	// instanceID identifies this process when claiming run leases on
+	// batches shared with other replicas.
+	instanceID string
+
+	// batchRuns tracks batches executing on this instance.
+	// map key: batch ID (string), value: context.CancelFunc
+	batchRuns sync.Map
+
+	// videoStore persists Videos API jobs. Nil disables the endpoints.
+	videoStore service.VideoGenerationStorer
+
+	// videoRuns tracks video jobs polled on this instance.
+	// map key: video ID (string), value: context.CancelFunc
+	videoRuns sync.Map
+
+	// capabilityStore holds the per-model capability registry; reads go
+	// through capabilityCache. Nil disables capability checks.
+	capabilityStore service.ModelCapabilityStorer
+	capabilityCache modelCapabilityCache
+
+	// pricingCache holds the model pricing table read by cost estimation,
+	// so priced calls avoid a DB read each.
+	pricingCache modelPricingCache
+
+	// guardrailStore holds guardrail policies; loaded policies are cached
+	// in guardrailCache (map key: policy ID, value: *compiledGuardrail).
+	// Nil disables guardrails.
+	guardrailStore service.GuardrailPolicyStorer
+	guardrailCache sync.Map
+
+	// gatewayHookStore holds gateway request/response hooks; compiled hooks
+	// are cached in gatewayHookCache (map key: hook ID, value:
+	// *compiledGatewayHook). Nil disables hooks.
+	gatewayHookStore service.GatewayHookStorer
+	gatewayHookCache sync.Map
+
 	// skillTemplates holds predefined skill templates loaded from embedded JSON.
 	skillTemplates []SkillTemplate
 
@@ -403,6 +481,16 @@ func New(ctx context.Context, cfg config.Server, providers map[string]ProviderIn
 		server:                   mux,
 		providers:                providers,
 		idempotency:              newIdempotencyCache(),
+		responseCache:            newResponseCache(cfg.ResponseCache, store),
+		responseStore:            store,
+		storedResponseTTL:        cfg.StoredResponseTTL,
+		batchStore:               store,
+		instanceID:               ulid.Make().String(),
+		videoStore:               store,
+		batchFileTTL:             cfg.BatchFileTTL,
+		capabilityStore:          store,
+		guardrailStore:           store,
+		gatewayHookStore:         store,
 		store:                    store,
 		tokenStore:               store,
 		tokenUsageStore:          store,
@@ -520,6 +608,18 @@ func New(ctx context.Context, cfg config.Server, providers map[string]ProviderIn
 	// request/response bodies older than LLMCallRetention (default 7d).
 	s.startLLMAuditJanitor(ctx)
 
+	// Start the response cache janitor: drops expired cache entries.
+	s.startResponseCacheJanitor(ctx)
+
+	// Start the stored response janitor: drops expired Responses API results.
+	s.startStoredResponseJanitor(ctx)
+
+	// Resume batches interrupted by a restart and sweep expired batch files.
+	s.startBatchWorkers(ctx)
+
+	// Resume polling of video jobs interrupted by a restart.
+	s.startVideoWorkers(ctx)
+
 	// Initialize cron trigger scheduler if trigger store is available.
 	{
 		providerLookup := func(key string) (service.LLMProvider, string, error) {
@@ -610,13 +710,45 @@ func New(ctx context.Context, cfg config.Server, providers map[string]ProviderIn
 
 	baseGroup := mux.Group(cfg.BasePath)
 
+	// Prometheus metrics (top-level, not behind ForwardAuth; optionally
+	// protected by its own bearer token).
+	metrics.Default.Configure(metricsOptions(cfg.Metrics))
+	if cfg.Metrics != nil && cfg.Metrics.Enabled {
+		path := cfg.BasePath + metricsPath(cfg.Metrics)
+		mux.GET(path, metrics.Default.Handler(cfg.Metrics.Token).ServeHTTP)
+		slog.Info("prometheus metrics enabled", "path", path)
+	}
+
 	// OpenAI-compatible gateway API (separate prefix so clients use /gateway/v1/ as base URL)
 	gatewayGroup := mux.Group(cfg.BasePath + "/gateway")
 	gatewayGroup.POST("/v1/chat/completions", s.ChatCompletions)
 	gatewayGroup.GET("/v1/models", s.ListModels)
 	gatewayGroup.POST("/v1/embeddings", s.Embeddings)
+	gatewayGroup.POST("/v1/tokenize", s.Tokenize)
 	gatewayGroup.POST("/v1/responses", s.Responses)
+	gatewayGroup.GET("/v1/responses/{id}", s.GetResponse)
+	gatewayGroup.DELETE("/v1/responses/{id}", s.DeleteResponse)
+	gatewayGroup.GET("/v1/responses/{id}/input_items", s.ListResponseInputItems)
+	gatewayGroup.POST("/v1/files", s.UploadFile)
+	gatewayGroup.GET("/v1/files", s.ListFiles)
+	gatewayGroup.GET("/v1/files/{id}", s.GetFile)
+	gatewayGroup.DELETE("/v1/files/{id}", s.DeleteFile)
+	gatewayGroup.GET("/v1/files/{id}/content", s.GetFileContent)
+	gatewayGroup.POST("/v1/batches", s.CreateBatch)
+	gatewayGroup.GET("/v1/batches", s.ListBatches)
+	gatewayGroup.GET("/v1/batches/{id}", s.GetBatch)
+	gatewayGroup.POST("/v1/batches/{id}/cancel", s.CancelBatch)
+	gatewayGroup.POST("/v1/messages", s.Messages)
+	gatewayGroup.POST("/v1/messages/count_tokens", s.MessagesCountTokens)
+	gatewayGroup.POST("/v1beta/models/*", s.GenerateContent) // {provider/model}:generateContent | :streamGenerateContent
 	gatewayGroup.POST("/v1/images/generations", s.Images)
+	gatewayGroup.POST("/v1/images/edits", s.ImageEdits)
+	gatewayGroup.POST("/v1/images/variations", s.ImageVariations)
+	gatewayGroup.POST("/v1/videos", s.CreateVideo)
+	gatewayGroup.GET("/v1/videos", s.ListVideos)
+	gatewayGroup.GET("/v1/videos/{id}", s.GetVideo)
+	gatewayGroup.GET("/v1/videos/{id}/content", s.GetVideoContent)
+	gatewayGroup.DELETE("/v1/videos/{id}", s.CancelVideo)
 	gatewayGroup.POST("/v1/audio/speech", s.AudioSpeech)
 	gatewayGroup.POST("/v1/audio/transcriptions", s.AudioTranscriptions)
 	gatewayGroup.POST("/v1/moderations", s.Moderations)
@@ -682,6 +814,10 @@ func New(ctx context.Context, cfg config.Server, providers map[string]ProviderIn
 	apiGroup.GET("/v1/providers/{key}", s.GetProviderAPI)
 	apiGroup.PUT("/v1/providers/{key}", s.UpdateProviderAPI)
 	apiGroup.DELETE("/v1/providers/{key}", s.DeleteProviderAPI)
+	apiGroup.GET("/v1/providers/{key}/key-usage", s.ProviderKeyUsageAPI)
+	apiGroup.GET("/v1/providers/{key}/models", s.ListProviderModelsAPI)
+	apiGroup.POST("/v1/providers/{key}/models/pull", s.PullProviderModelAPI)
+	apiGroup.DELETE("/v1/providers/{key}/models", s.DeleteProviderModelAPI)
 
 	// API Token management
 	apiGroup.GET("/v1/api-tokens", s.ListAPITokensAPI)
@@ -691,6 +827,9 @@ func New(ctx context.Context, cfg config.Server, providers map[string]ProviderIn
 	apiGroup.GET("/v1/api-tokens/{id}/usage", s.GetTokenUsageAPI)
 	apiGroup.POST("/v1/api-tokens/{id}/usage/reset", s.ResetTokenUsageAPI)
 
+	// Gateway response cache
+	apiGroup.DELETE("/v1/response-cache", s.PurgeResponseCacheAPI)
+
 	// Workflow management
 	apiGroup.GET("/v1/workflow-node-types", s.ListWorkflowNodeTypesAPI)
 	apiGroup.GET("/v1/workflows", s.ListWorkflowsAPI)
@@ -847,6 +986,25 @@ func New(ctx context.Context, cfg config.Server, providers map[string]ProviderIn
 	apiGroup.POST("/v1/model-pricing/sync/apply", s.ApplyModelPricingSyncAPI)
 	apiGroup.DELETE("/v1/model-pricing/{id}", s.DeleteModelPricingAPI)
 	apiGroup.POST("/v1/model-pricing/{id}/reset", s.ResetModelPricingAPI)
+	apiGroup.GET("/v1/model-capabilities", s.ListModelCapabilitiesAPI)
+	apiGroup.POST("/v1/model-capabilities", s.SetModelCapabilityAPI)
+	apiGroup.POST("/v1/model-capabilities/sync", s.SyncModelCapabilitiesAPI)
+	apiGroup.DELETE("/v1/model-capabilities/{id}", s.DeleteModelCapabilityAPI)
+
+	// Guardrail policies
+	apiGroup.GET("/v1/guardrails", s.ListGuardrailPoliciesAPI)
+	apiGroup.POST("/v1/guardrails", s.CreateGuardrailPolicyAPI)
+	apiGroup.GET("/v1/guardrails/{id}", s.GetGuardrailPolicyAPI)
+	apiGroup.PUT("/v1/guardrails/{id}", s.UpdateGuardrailPolicyAPI)
+	apiGroup.DELETE("/v1/guardrails/{id}", s.DeleteGuardrailPolicyAPI)
+
+	// Gateway hooks
+	apiGroup.GET("/v1/gateway-hooks", s.ListGatewayHooksAPI)
+	apiGroup.POST("/v1/gateway-hooks", s.CreateGatewayHookAPI)
+	apiGroup.POST("/v1/gateway-hooks/test", s.TestGatewayHookAPI)
+	apiGroup.GET("/v1/gateway-hooks/{id}", s.GetGatewayHookAPI)
+	apiGroup.PUT("/v1/gateway-hooks/{id}", s.UpdateGatewayHookAPI)
+	apiGroup.DELETE("/v1/gateway-hooks/{id}", s.DeleteGatewayHookAPI)
 
 	// Project management
 	apiGroup.GET("/v1/projects", s.ListProjectsAPI)
@@ -1112,6 +1270,9 @@ func NewProviderInfo(provider service.LLMProvider, cfg config.LLMConfig) Provide
 		models:          cfg.Models,
 		embeddingModels: cfg.EmbeddingModels,
 		retryAfterCap:   cap,
+		rateLimit:       cfg.RateLimit,
+		toolEmulation:   cfg.ToolEmulation,
+		hookIDs:         cfg.HookIDs,
 	}
 }
 
@@ -1163,7 +1324,7 @@ func (s *Server) reloadProvider(key string, cfg config.LLMConfig) error {
 		return fmt.Errorf("no provider factory configured")
 	}
 
-	provider, err := s.providerFactory(cfg)
+	provider, err := s.providerFactory(key, cfg)
 	if err != nil {
 		return fmt.Errorf("create provider %q: %w", key, err)
 	}
@@ -1277,15 +1438,13 @@ func (s *Server) recordUsageFunc() workflow.RecordUsageFunc {
 	return func(ctx context.Context, event workflow.UsageEvent) error {
 		// Look up model pricing to estimate cost.
 		var costCents float64
-		if s.agentBudgetStore != nil {
-			pricingList, err := s.agentBudgetStore.ListModelPricing(ctx)
-			if err == nil {
-				fullModel := event.Model
-				if event.Provider != "" && !strings.Contains(event.Model, "/") {
-					fullModel = event.Provider + "/" + event.Model
-				}
-				costCents = estimateUsageCostCents(pricingList, event.Provider, event.Model, fullModel, event.Usage)
+		if pricingList := s.modelPricing(ctx); len(pricingList) > 0 {
+			fullModel := event.Model
+			if event.Provider != "" && !strings.Contains(event.Model, "/") {
+				fullModel = event.Provider + "/" + event.Model
 			}
+			costCents = estimateUsageCostCents(pricingList, event.Provider, event.Model, fullModel, event.Usage) +
+				estimateVideoCostCents(pricingList, event.Provider, event.Model, fullModel, event.VideoSeconds)
 		}
 
 		// Dollar-denominated for agent_usage (historic), cents for cost_events.
@@ -1306,6 +1465,7 @@ func (s *Server) recordUsageFunc() workflow.RecordUsageFunc {
 				BillingCode:      event.BillingCode,
 				RunID:            event.RunID,
 				Provider:         event.Provider,
+				APIKey:           event.Usage.APIKey,
 				Model:            event.Model,
 				InputTokens:      int64(event.Usage.PromptTokens),
 				OutputTokens:     int64(event.Usage.CompletionTokens),
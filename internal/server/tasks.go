@@ -12,6 +12,7 @@ import (
 	"github.com/oklog/ulid/v2"
 	"github.com/rakunlabs/at/internal/service"
 	"github.com/rakunlabs/query"
+	oteltrace "go.opentelemetry.io/otel/trace"
 )
 
 // ListTasksAPI handles GET /api/v1/tasks.
@@ -458,14 +459,17 @@ func (s *Server) ProcessTaskAPI(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Fire async delegation in a tracked, cancellable background goroutine.
+	reqSpan := oteltrace.SpanContextFromContext(r.Context())
 	go func() {
 		delegCtx, cleanup := s.registerDelegation(context.Background(), task.ID, org.HeadAgentID, org.ID)
 		defer cleanup()
 
-		// Pre-mint the run's trace ID so the trigger event joins the
-		// same trace as the delegation run it starts.
-		runTraceID := ulid.Make().String()
-		delegCtx = contextWithOrgTraceID(delegCtx, runTraceID)
+		// Pre-mint the run's trace so the trigger event joins the same
+		// trace as the delegation run it starts. The run span links back
+		// to the API request that triggered it.
+		delegCtx = oteltrace.ContextWithSpanContext(delegCtx, reqSpan)
+		delegCtx, runSpan, runTraceID := startOrgRunTrace(delegCtx, task.ID, org.HeadAgentID)
+		defer runSpan.End()
 
 		// Observation: task processing triggered.
 		s.recordLLMCallAsync(delegCtx, llmAuditParams{
@@ -0,0 +1,60 @@
+package server
+
+import (
+	"context"
+
+	"github.com/oklog/ulid/v2"
+	"go.opentelemetry.io/otel/attribute"
+	oteltrace "go.opentelemetry.io/otel/trace"
+
+	"github.com/rakunlabs/at/internal/service/tracing"
+)
+
+// ─── OpenTelemetry spans ───
+//
+// Inbound requests get their server span (parented on the caller's
+// traceparent) from the telemetry middleware. Provider, tool and iteration
+// spans come from the shared helpers in the workflow package; this file
+// holds the server-side pieces that tie spans to audit traces.
+
+// tracer returns AT's tracer; the audit generation spans use the same
+// instrumentation scope as the live spans.
+func tracer() oteltrace.Tracer {
+	return tracing.Tracer()
+}
+
+// traceIDFor returns the audit trace ID for work running under ctx: the
+// OTel trace ID when one is active, so LLMCall rows and exported spans
+// share an ID, else a fresh ULID.
+func traceIDFor(ctx context.Context) string {
+	if id := tracing.TraceIDFromContext(ctx); id != "" {
+		return id
+	}
+	return ulid.Make().String()
+}
+
+// startRunTrace starts the root span of a new trace for a unit of work
+// that is recorded as its own audit trace (an org delegation run). The
+// span is linked to, not parented on, the span in ctx, so the caller's
+// trace and the run's trace stay separate while remaining navigable. It
+// returns the run's audit trace ID.
+func startRunTrace(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, oteltrace.Span, string) {
+	opts := []oteltrace.SpanStartOption{
+		oteltrace.WithNewRoot(),
+		oteltrace.WithSpanKind(oteltrace.SpanKindInternal),
+		oteltrace.WithAttributes(attrs...),
+	}
+	parent := oteltrace.SpanContextFromContext(ctx)
+	if parent.IsValid() {
+		opts = append(opts, oteltrace.WithLinks(oteltrace.Link{SpanContext: parent}))
+	}
+	ctx, span := tracer().Start(ctx, name, opts...)
+
+	// Without a tracer provider the no-op tracer ignores WithNewRoot and
+	// carries the parent's span context forward; the run still needs a
+	// trace ID of its own.
+	if sc := span.SpanContext(); sc.TraceID().IsValid() && sc.TraceID() != parent.TraceID() {
+		return ctx, span, sc.TraceID().String()
+	}
+	return ctx, span, ulid.Make().String()
+}
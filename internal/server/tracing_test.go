@@ -0,0 +1,182 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	oteltrace "go.opentelemetry.io/otel/trace"
+
+	"github.com/rakunlabs/at/internal/service"
+)
+
+const inboundTraceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+// recordTestSpans installs an in-memory tracer provider and the W3C
+// propagator for one test.
+func recordTestSpans(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	rec := tracetest.NewSpanRecorder()
+	prevProvider, prevPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prevProvider)
+		otel.SetTextMapPropagator(prevPropagator)
+	})
+	return rec
+}
+
+// waitForSpan polls until a span named name has ended.
+func waitForSpan(t *testing.T, rec *tracetest.SpanRecorder, name string) sdktrace.ReadOnlySpan {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if s := findSpans(rec, name); len(s) > 0 {
+			return s[0]
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("span %q never ended", name)
+	return nil
+}
+
+func findSpans(rec *tracetest.SpanRecorder, name string) []sdktrace.ReadOnlySpan {
+	var out []sdktrace.ReadOnlySpan
+	for _, s := range rec.Ended() {
+		if s.Name() == name {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// ctxCaptureProvider records the context the gateway called it with.
+type ctxCaptureProvider struct {
+	messagesCaptureProvider
+	ctx context.Context
+}
+
+func (p *ctxCaptureProvider) Chat(ctx context.Context, model string, messages []service.Message, tools []service.Tool, opts *service.ChatOptions) (*service.LLMResponse, error) {
+	p.ctx = ctx
+	return p.messagesCaptureProvider.Chat(ctx, model, messages, tools, opts)
+}
+
+func TestChatCompletionsJoinsInboundTrace(t *testing.T) {
+	rec := recordTestSpans(t)
+
+	provider := &ctxCaptureProvider{messagesCaptureProvider: messagesCaptureProvider{resp: &service.LLMResponse{
+		Content: "ok", Finished: true, Usage: service.Usage{PromptTokens: 4, CompletionTokens: 2},
+	}}}
+	s := newGatewayHookTestServer(provider, nil, nil)
+	obsStore := &fakeLLMCallStore{}
+	s.llmCallStore = obsStore
+
+	// Stand in for the telemetry middleware: extract the inbound
+	// traceparent and open the server span.
+	req := httptest.NewRequest(http.MethodPost, "/gateway/v1/chat/completions",
+		strings.NewReader(`{"model":"gemini/m","messages":[{"role":"user","content":"hi"}]}`))
+	req.Header.Set("Authorization", "Bearer test-token")
+	req.Header.Set("traceparent", inboundTraceparent)
+	ctx := otel.GetTextMapPropagator().Extract(req.Context(), propagation.HeaderCarrier(req.Header))
+	ctx, serverSpan := otel.Tracer("test").Start(ctx, "POST /gateway/v1/chat/completions", oteltrace.WithSpanKind(oteltrace.SpanKindServer))
+	recorder := httptest.NewRecorder()
+	s.ChatCompletions(recorder, req.WithContext(ctx))
+	serverSpan.End()
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d; body=%s", recorder.Code, recorder.Body.String())
+	}
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+
+	// The upstream call runs under the provider span, so provider HTTP
+	// clients forward the inbound trace.
+	upstream := oteltrace.SpanContextFromContext(provider.ctx)
+	if upstream.TraceID().String() != traceID {
+		t.Fatalf("provider ctx trace ID = %s, want %s", upstream.TraceID(), traceID)
+	}
+	attempt := waitForSpan(t, rec, "provider gemini")
+	if attempt.SpanContext().SpanID() != upstream.SpanID() || attempt.Parent().SpanID() != serverSpan.SpanContext().SpanID() {
+		t.Fatalf("provider span not the upstream parent / not under the server span")
+	}
+
+	obs := waitForObservations(t, obsStore, 1)
+	if obs[0].TraceID != traceID {
+		t.Fatalf("LLMCall.TraceID = %q, want the OTel trace ID %q", obs[0].TraceID, traceID)
+	}
+	gen := waitForSpan(t, rec, "chat m")
+	if gen.Parent().SpanID() != serverSpan.SpanContext().SpanID() {
+		t.Fatalf("generation span not parented on the server span")
+	}
+}
+
+func TestOrgDelegationLiveSpans(t *testing.T) {
+	rec := recordTestSpans(t)
+
+	provider := &fakeObsProvider{responses: []*service.LLMResponse{
+		{ToolCalls: []service.ToolCall{{ID: "tc1", Name: "mystery_tool", Arguments: map[string]any{"x": 1}}}},
+		{Content: "all done", Finished: true},
+	}}
+	obsStore := &fakeLLMCallStore{}
+	agents := map[string]*service.Agent{
+		"agent-a": {ID: "agent-a", Name: "Alpha", Config: service.AgentConfig{Provider: "prov1", Model: "m1", MaxIterations: 5}},
+	}
+	s, taskStore := newObsTestServer(t, provider, obsStore, agents, nil)
+	task, err := taskStore.CreateTask(context.Background(), service.Task{
+		OrganizationID: "org1", Title: "trace me", Status: service.TaskStatusOpen, AssignedAgentID: "agent-a",
+	})
+	if err != nil {
+		t.Fatalf("CreateTask: %v", err)
+	}
+
+	ctx, caller := otel.Tracer("test").Start(context.Background(), "caller")
+	org := &service.Organization{ID: "org1", IssuePrefix: "OBS"}
+	if err := s.runOrgDelegation(ctx, org, task, "agent-a", 0); err != nil {
+		t.Fatalf("runOrgDelegation: %v", err)
+	}
+	caller.End()
+
+	// The run is its own trace, linked back to the caller.
+	run := waitForSpan(t, rec, "agent.run")
+	if run.Parent().IsValid() || run.SpanContext().TraceID() == caller.SpanContext().TraceID() {
+		t.Fatalf("delegation run should start a new trace")
+	}
+	if links := run.Links(); len(links) != 1 || links[0].SpanContext.SpanID() != caller.SpanContext().SpanID() {
+		t.Fatalf("run span links = %+v, want the caller span", run.Links())
+	}
+
+	iterations := findSpans(rec, "agent.iteration")
+	if len(iterations) != 2 {
+		t.Fatalf("expected 2 iteration spans, got %d", len(iterations))
+	}
+	for _, it := range iterations {
+		if it.Parent().SpanID() != run.SpanContext().SpanID() {
+			t.Fatalf("iteration span not under the run span")
+		}
+	}
+	if n := len(findSpans(rec, "provider prov1")); n != 2 {
+		t.Fatalf("expected 2 provider spans, got %d", n)
+	}
+	tool := waitForSpan(t, rec, "execute_tool mystery_tool")
+	if tool.Parent().SpanID() != iterations[0].SpanContext().SpanID() {
+		t.Fatalf("tool span not under the first iteration")
+	}
+	if tool.Status().Code != codes.Error {
+		t.Fatalf("unknown tool span status = %v, want error", tool.Status())
+	}
+
+	runTraceID := run.SpanContext().TraceID().String()
+	for _, o := range waitForObservations(t, obsStore, 5) {
+		if o.TraceID != runTraceID {
+			t.Fatalf("observation %s:%s trace ID = %q, want run trace %q", o.ObservationType, o.Name, o.TraceID, runTraceID)
+		}
+	}
+}
@@ -74,6 +74,19 @@ type ChatCompletionRequest struct {
 	// `context.WithTimeout(ctx, d)` before issuing the provider request.
 	// 0 means use the inherited request context (no extra cap).
 	TimeoutMs int `json:"timeout_ms,omitempty"`
+
+	// AtHedge, when set, races the primary against the first usable
+	// at_fallbacks entry: if the primary has not produced a first token
+	// after the hedge delay, the same request is sent to the fallback and
+	// whichever answers first is used. The loser is cancelled.
+	AtHedge *HedgeOptions `json:"at_hedge,omitempty"`
+}
+
+// HedgeOptions configures the at_hedge extension.
+type HedgeOptions struct {
+	// DelayMs is how long the primary may go without a first token before
+	// the hedge request is sent. 0 uses the default (500ms).
+	DelayMs int `json:"delay_ms,omitempty"`
 }
 
 // ThinkingReq is the client-facing thinking configuration.
@@ -93,6 +106,11 @@ type OpenAIMessage struct {
 	ToolCalls  []OpenAIToolCall `json:"tool_calls,omitempty"`
 	ToolCallID string           `json:"tool_call_id,omitempty"`
 	Name       string           `json:"name,omitempty"`
+
+	// Thinking holds signed Claude thinking blocks of an assistant turn
+	// received on /v1/messages. They are replayed ahead of the turn's
+	// tool_use blocks for Claude targets only.
+	Thinking []service.ContentBlock `json:"-"`
 }
 
 type OpenAIToolCall struct {
@@ -181,6 +199,12 @@ type ModelData struct {
 	ID      string `json:"id"`
 	Object  string `json:"object"`
 	OwnedBy string `json:"owned_by"`
+
+	// Capability registry metadata (AT extension); omitted when the
+	// model has no capability record.
+	ContextWindow   int             `json:"context_window,omitempty"`
+	MaxOutputTokens int             `json:"max_output_tokens,omitempty"`
+	Capabilities    map[string]bool `json:"capabilities,omitempty"`
 }
 
 // ─── Streaming response types (SSE / chat.completion.chunk format) ───
@@ -326,9 +350,10 @@ func translateOpenAIToAnthropic(msgs []OpenAIMessage) (systemPrompt string, mess
 			}
 
 		case "assistant":
-			if len(msg.ToolCalls) > 0 {
-				// Convert to Anthropic tool_use content blocks
-				var blocks []service.ContentBlock
+			if len(msg.ToolCalls) > 0 || len(msg.Thinking) > 0 {
+				// Convert to Anthropic tool_use content blocks. Thinking
+				// blocks must come first in the turn.
+				blocks := append([]service.ContentBlock(nil), msg.Thinking...)
 				content := extractContentString(msg.Content)
 				if content != "" {
 					blocks = append(blocks, service.ContentBlock{
@@ -564,6 +589,25 @@ func parseToolChoice(raw json.RawMessage) any {
 // ─── Translation: service.LLMResponse → OpenAI response ───
 
 func buildOpenAIResponse(id, model string, resp *service.LLMResponse) *ChatCompletionResponse {
+	choices := make([]ChatCompletionChoice, 0, 1+len(resp.ExtraChoices))
+	choices = append(choices, buildOpenAIChoice(0, resp))
+	for i := range resp.ExtraChoices {
+		choices = append(choices, buildOpenAIChoice(i+1, &resp.ExtraChoices[i]))
+	}
+
+	return &ChatCompletionResponse{
+		ID:                id,
+		Object:            "chat.completion",
+		Created:           time.Now().Unix(),
+		Model:             model,
+		Choices:           choices,
+		Usage:             chatCompletionUsageFromService(resp.Usage),
+		SystemFingerprint: resp.SystemFingerprint,
+	}
+}
+
+// buildOpenAIChoice converts one completion into an OpenAI choice.
+func buildOpenAIChoice(index int, resp *service.LLMResponse) ChatCompletionChoice {
 	msg := ChatCompletionMessage{
 		Role: "assistant",
 	}
@@ -593,21 +637,11 @@ func buildOpenAIResponse(id, model string, resp *service.LLMResponse) *ChatCompl
 		})
 	}
 
-	return &ChatCompletionResponse{
-		ID:      id,
-		Object:  "chat.completion",
-		Created: time.Now().Unix(),
-		Model:   model,
-		Choices: []ChatCompletionChoice{
-			{
-				Index:        0,
-				Message:      msg,
-				FinishReason: normalizeFinishReason(resp),
-				Logprobs:     resp.Logprobs,
-			},
-		},
-		Usage:             chatCompletionUsageFromService(resp.Usage),
-		SystemFingerprint: resp.SystemFingerprint,
+	return ChatCompletionChoice{
+		Index:        index,
+		Message:      msg,
+		FinishReason: normalizeFinishReason(resp),
+		Logprobs:     resp.Logprobs,
 	}
 }
 
@@ -840,6 +874,16 @@ func convertOpenAIContentToAnthropic(raw json.RawMessage) []service.ContentBlock
 			}
 			fileData, _ := file["file_data"].(map[string]any)
 			if fileData == nil {
+				// {type:"file", file:{file_url:"https://..."}} → URL document.
+				if url, _ := file["file_url"].(string); url != "" {
+					blocks = append(blocks, service.ContentBlock{
+						Type: "document",
+						Source: &service.MediaSource{
+							Type: "url",
+							URL:  url,
+						},
+					})
+				}
 				continue
 			}
 			mimeType, _ := fileData["mime_type"].(string)
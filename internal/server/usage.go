@@ -17,7 +17,7 @@ import (
 //
 //   from          = RFC3339 lower bound (inclusive)
 //   to            = RFC3339 upper bound (exclusive)
-//   status        = single status value ("ok" or "error")
+//   status        = single status value ("ok", "error", or "cache_hit")
 //   provider      = repeated; any provider key in the set
 //   model         = repeated
 //   agent_id      = repeated
@@ -27,7 +27,7 @@ import (
 //   billing_code  = repeated
 //
 // Additional params:
-//   group_by  (for /usage/grouped) = provider|model|agent|org|project|goal|billing_code|status
+//   group_by  (for /usage/grouped) = provider|model|agent|org|project|goal|billing_code|status|api_key
 //   bucket    (for /usage/timeseries) = hour|day (default day)
 //   limit     (for /usage/grouped)  = top-N cap; 0 means no cap
 
@@ -83,7 +83,7 @@ func (s *Server) GetUsageGroupedAPI(w http.ResponseWriter, r *http.Request) {
 
 	groupBy := r.URL.Query().Get("group_by")
 	if groupBy == "" {
-		httpResponse(w, "group_by is required (provider|model|agent|org|project|goal|billing_code|status)", http.StatusBadRequest)
+		httpResponse(w, "group_by is required (provider|model|agent|org|project|goal|billing_code|status|api_key)", http.StatusBadRequest)
 		return
 	}
 
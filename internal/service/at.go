@@ -3,7 +3,7 @@
 // Types are organized across domain-specific files:
 //
 //   - types_llm.go     — LLM provider interfaces, message types, chat options
-//   - types_media.go   — Image, audio, embedding provider interfaces and types
+//   - types_media.go   — Image, audio, video, embedding provider interfaces and types
 //   - types_provider.go — Provider record, storer, key rotation interfaces
 //   - types_token.go   — API token and token usage types
 //   - types_workflow.go — Workflow, trigger, and node config types
@@ -14,6 +14,13 @@
 //   - types_chat.go    — Chat session, bot config, user preference, marketplace types
 //   - types_mcp.go     — MCP server, MCP set types
 //   - types_feature.go — runtime feature toggles
+//   - types_cache.go   — gateway response cache entries
+//   - types_responses.go — stored Responses API results
+//   - types_batch.go   — gateway Files and Batch API jobs
+//   - types_capability.go — per-model capability registry
+//   - types_guardrail.go — guardrail policies for gateway and agent calls
+//   - types_hook.go    — scriptable gateway request/response hooks
+//   - types_video.go   — gateway video generation jobs
 package service
 
 import (
@@ -77,6 +84,13 @@ type Storer interface {
 	ConnectorStorer
 	FeatureSettingStorer
 	LLMCallStorer
+	ResponseCacheStorer
+	StoredResponseStorer
+	BatchStorer
+	ModelCapabilityStorer
+	GuardrailPolicyStorer
+	GatewayHookStorer
+	VideoGenerationStorer
 }
 
 // Marketplace groups Skills and MCP Servers into one Claude Code
@@ -13,6 +13,9 @@ import (
 	"net/url"
 	"strings"
 	"sync/atomic"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
 )
 
 // mcpProtocolVersion is the latest MCP protocol revision this client
@@ -168,6 +171,8 @@ func (c *HTTPMCPClient) sendRequest(ctx context.Context, req MCPRequest) (*MCPRe
 	if c.protocolVersion != "" {
 		httpReq.Header.Set("MCP-Protocol-Version", c.protocolVersion)
 	}
+	// Propagate the caller's W3C trace context to the MCP server.
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(httpReq.Header))
 
 	resp, err := c.httpClient.Do(httpReq)
 	if err != nil {
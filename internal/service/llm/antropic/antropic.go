@@ -64,6 +64,11 @@ type Provider struct {
 	// caching IS applied (best-effort, no behaviour change if the model
 	// doesn't support it — the field is ignored).
 	promptCachingDisabled bool
+
+	// vertex, when set, sends requests to Vertex AI's Anthropic
+	// publisher endpoints with Google credentials instead of to
+	// api.anthropic.com. See vertex.go.
+	vertex *vertexConfig
 }
 
 // Option configures the Provider.
@@ -131,12 +136,13 @@ type Error struct {
 
 // Response structures
 type ContentBlock struct {
-	Type     string         `json:"type"`
-	Text     string         `json:"text"`
-	Thinking string         `json:"thinking"`
-	ID       string         `json:"id"`
-	Name     string         `json:"name"`
-	Input    map[string]any `json:"input"`
+	Type      string         `json:"type"`
+	Text      string         `json:"text"`
+	Thinking  string         `json:"thinking"`
+	Signature string         `json:"signature"`
+	ID        string         `json:"id"`
+	Name      string         `json:"name"`
+	Input     map[string]any `json:"input"`
 }
 
 type Usage struct {
@@ -186,8 +192,11 @@ func New(apiKey, model, baseURL, proxy string, insecureSkipVerify bool, opts ...
 	}
 
 	headers := http.Header{
-		"Anthropic-Version": []string{"2023-06-01"},
-		"Content-Type":      []string{"application/json"},
+		"Content-Type": []string{"application/json"},
+	}
+	// Vertex carries anthropic_version in the body instead (see vertex.go).
+	if p.vertex == nil {
+		headers["Anthropic-Version"] = []string{"2023-06-01"}
 	}
 	// Only set X-Api-Key as a default header when using static key auth.
 	// When a tokenSource is configured (e.g. OAuth), Bearer auth is used
@@ -205,6 +214,7 @@ func New(apiKey, model, baseURL, proxy string, insecureSkipVerify bool, opts ...
 		klient.WithDisableRetry(true),
 		klient.WithDisableEnvValues(true),
 		klient.WithHeaderSet(headers),
+		klient.WithInject(common.InjectTraceContext),
 	}
 	if proxy != "" {
 		klientOpts = append(klientOpts, klient.WithProxy(proxy))
@@ -237,21 +247,20 @@ func (p *Provider) Chat(ctx context.Context, model string, messages []service.Me
 	defer release()
 
 	reqBody := p.buildRequestBody(model, messages, tools, opts)
+	if p.vertex != nil {
+		p.vertex.adaptBody(reqBody)
+	}
 	jsonData, _ := json.Marshal(reqBody)
 
-	// On the OAuth path Anthropic expects /v1/messages?beta=true. The
-	// `?beta=true` query string activates the experimental message
-	// envelope Claude Code uses; without it the request is rejected
-	// before billing validation even runs. Static-API-key callers
-	// don't get the query param (it changes accounting on that path).
-	requestPath := "/v1/messages"
-	if p.tokenSource != nil {
-		requestPath = "/v1/messages?beta=true"
-	}
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, requestPath, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.messagesPath(model, false), bytes.NewBuffer(jsonData))
 	if err != nil {
 		return nil, err
 	}
+	if p.vertex != nil {
+		if err := p.vertex.authorize(req); err != nil {
+			return nil, err
+		}
+	}
 
 	// If a token source is configured, get a fresh token and use Bearer auth.
 	if p.tokenSource != nil {
@@ -288,6 +297,10 @@ func (p *Provider) Chat(ctx context.Context, model string, messages []service.Me
 		rawBody = string(bodyData)
 
 		if err := json.Unmarshal(bodyData, &result); err != nil {
+			if r.StatusCode >= http.StatusBadRequest {
+				// Non-Anthropic error envelope; reported below.
+				return nil
+			}
 			return fmt.Errorf("failed to decode response (status %d): %w (body: %s)", r.StatusCode, err, rawBody)
 		}
 
@@ -296,6 +309,16 @@ func (p *Provider) Chat(ctx context.Context, model string, messages []service.Me
 		return nil, err
 	}
 
+	// Vertex (and proxies in front of the API) answer errors with a
+	// Google-style {"error":{"code":429,...}} body that has no
+	// "type":"error". Treat any failing status as an error response.
+	if statusCode >= http.StatusBadRequest && result.Type != "error" {
+		result.Type = "error"
+		if result.Error.Message == "" {
+			result.Error.Message = rawBody
+		}
+	}
+
 	llmResp := &service.LLMResponse{
 		Finished:     result.StopReason != "tool_use",
 		FinishReason: result.StopReason,
@@ -341,6 +364,7 @@ func (p *Provider) Chat(ctx context.Context, model string, messages []service.Me
 		switch block.Type {
 		case "thinking":
 			llmResp.ReasoningContent += block.Thinking
+			llmResp.ReasoningSignature = block.Signature
 		case "text":
 			llmResp.Content += block.Text
 		case "tool_use":
@@ -385,8 +409,9 @@ type textDelta struct {
 }
 
 type thinkingDelta struct {
-	Type     string `json:"type"`
-	Thinking string `json:"thinking"`
+	Type      string `json:"type"`
+	Thinking  string `json:"thinking"`
+	Signature string `json:"signature"`
 }
 
 type toolInputDelta struct {
@@ -431,6 +456,9 @@ func (p *Provider) ChatStream(ctx context.Context, model string, messages []serv
 
 	reqBody := p.buildRequestBody(model, messages, tools, opts)
 	reqBody["stream"] = true
+	if p.vertex != nil {
+		p.vertex.adaptBody(reqBody)
+	}
 
 	jsonData, err := json.Marshal(reqBody)
 	if err != nil {
@@ -445,17 +473,17 @@ func (p *Provider) ChatStream(ctx context.Context, model string, messages []serv
 		"body_size", len(jsonData),
 	)
 
-	// Same OAuth-only ?beta=true switch as the Chat path. See the
-	// comment in Chat() for why this matters.
-	streamPath := "/v1/messages"
-	if p.tokenSource != nil {
-		streamPath = "/v1/messages?beta=true"
-	}
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, streamPath, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.messagesPath(model, true), bytes.NewBuffer(jsonData))
 	if err != nil {
 		releaseOnce()
 		return nil, nil, err
 	}
+	if p.vertex != nil {
+		if err := p.vertex.authorize(req); err != nil {
+			releaseOnce()
+			return nil, nil, err
+		}
+	}
 
 	// If a token source is configured, get a fresh token and use Bearer auth.
 	if p.tokenSource != nil {
@@ -592,9 +620,15 @@ func (p *Provider) ChatStream(ctx context.Context, model string, messages []serv
 				// Try thinking delta first (when inside a thinking block).
 				if inThinkingBlock {
 					var tkd thinkingDelta
-					if err := json.Unmarshal(event.Delta, &tkd); err == nil && tkd.Type == "thinking_delta" {
-						ch <- service.StreamChunk{ReasoningContent: tkd.Thinking}
-						continue
+					if err := json.Unmarshal(event.Delta, &tkd); err == nil {
+						switch tkd.Type {
+						case "thinking_delta":
+							ch <- service.StreamChunk{ReasoningContent: tkd.Thinking}
+							continue
+						case "signature_delta":
+							ch <- service.StreamChunk{ReasoningSignature: tkd.Signature}
+							continue
+						}
 					}
 				}
 
@@ -769,6 +803,10 @@ func (p *Provider) Proxy(w http.ResponseWriter, r *http.Request, path string) er
 					p.setOAuthHeaders(req, bodyModel)
 				}
 				req.Header.Set("anthropic-version", "2023-06-01")
+			} else if p.vertex != nil {
+				if err := p.vertex.authorize(req); err != nil {
+					slog.Error("failed to get vertex token in proxy", "error", err)
+				}
 			} else if p.APIKey != "" {
 				req.Header.Set("x-api-key", p.APIKey)
 				req.Header.Set("anthropic-version", "2023-06-01")
@@ -1092,15 +1130,16 @@ func (p *Provider) buildRequestBody(model string, messages []service.Message, to
 	}
 
 	// Default-on prompt caching: install ephemeral cache_control markers
-	// on the system block, all tools (single marker on the last one), and
-	// the last few message turns. This is a no-op on models that don't
-	// support caching, and reduces cost ~90% on repeated system prompts /
-	// tool definitions for models that do.
+	// on the system block, all tools (single marker on the last one), the
+	// last message and the previous user turn. This is a no-op on models
+	// that don't support caching, and reduces cost ~90% on repeated system
+	// prompts / tool definitions for models that do.
 	//
-	// Skip only when the operator explicitly disabled it. Claude Code OAuth
-	// enables prompt caching through a beta header, but still requires explicit
-	// cache_control breakpoints in the request body.
-	if !p.promptCachingDisabled {
+	// Skip only when the operator or the agent (opts.DisablePromptCache)
+	// explicitly disabled it. Claude Code OAuth enables prompt caching
+	// through a beta header, but still requires explicit cache_control
+	// breakpoints in the request body.
+	if !p.promptCachingDisabled && (opts == nil || !opts.DisablePromptCache) {
 		installPromptCacheMarkers(reqBody)
 	}
 
@@ -1116,102 +1155,160 @@ func (p *Provider) buildRequestBody(model string, messages []service.Message, to
 	return reqBody
 }
 
+// maxCacheBreakpoints is Anthropic's per-request limit on cache_control
+// markers. Requests with more are rejected, so markers the caller placed
+// itself count against it.
+const maxCacheBreakpoints = 4
+
 // installPromptCacheMarkers attaches `cache_control: {type: "ephemeral"}`
-// to the system block, the last tool, and the last message content block.
-// Idempotent — re-running does nothing if a marker is already present.
+// breakpoints to the stable prefix of the request, in priority order:
+//
+//  1. the last system block (instructions, skill catalog),
+//  2. the last tool (the whole tool definition block),
+//  3. the last message, which caches the conversation for the next turn,
+//  4. the previous user turn — the last message of the previous agent
+//     iteration — so the history still hits the cache when the latest
+//     turn added more blocks than the cache lookback covers.
+//
+// Anthropic's prompt cache hashes everything up to and including a marked
+// block. Blocks that cannot carry a marker (thinking, empty text) are
+// skipped. Idempotent — a block that already has a marker is left alone.
 func installPromptCacheMarkers(reqBody map[string]any) {
 	cacheMark := map[string]any{"type": "ephemeral"}
 
-	// System: convert string to [{type:"text", text:"...", cache_control: ...}]
-	switch sys := reqBody["system"].(type) {
-	case string:
-		if sys != "" {
-			reqBody["system"] = []any{map[string]any{
-				"type":          "text",
-				"text":          sys,
-				"cache_control": cacheMark,
-			}}
-		}
-	case []any:
-		// Already an array — mark the last block if no marker present.
-		if n := len(sys); n > 0 {
-			if last, ok := sys[n-1].(map[string]any); ok {
-				if _, has := last["cache_control"]; !has {
-					last["cache_control"] = cacheMark
-				}
+	if sys, ok := reqBody["system"].(string); ok && sys != "" {
+		reqBody["system"] = []any{map[string]any{"type": "text", "text": sys}}
+	}
+	msgs := requestMessages(reqBody)
+
+	budget := maxCacheBreakpoints - countCacheMarkers(reqBody, msgs)
+	mark := func(blocks []map[string]any) {
+		for i := len(blocks) - 1; i >= 0 && budget > 0; i-- {
+			b := blocks[i]
+			if _, has := b["cache_control"]; has {
+				return
 			}
-		}
-	case []map[string]any:
-		if n := len(sys); n > 0 {
-			last := sys[n-1]
-			if _, has := last["cache_control"]; !has {
-				last["cache_control"] = cacheMark
+			if !cacheableBlock(b) {
+				continue
 			}
+			b["cache_control"] = cacheMark
+			budget--
+			return
 		}
 	}
 
-	// Tools: mark the last tool to cache the whole tool definition block.
-	if tools, ok := reqBody["tools"].([]map[string]any); ok && len(tools) > 0 {
-		last := tools[len(tools)-1]
-		if _, has := last["cache_control"]; !has {
-			last["cache_control"] = cacheMark
+	mark(blockMaps(reqBody["system"]))
+	if tools := blockMaps(reqBody["tools"]); len(tools) > 0 {
+		mark(tools[len(tools)-1:])
+	}
+	if len(msgs) == 0 || budget <= 0 {
+		return
+	}
+	last := len(msgs) - 1
+	mark(msgs[last].blocks())
+	for i := last - 1; i >= 0 && budget > 0; i-- {
+		if msgs[i].role == "user" {
+			mark(msgs[i].blocks())
+			break
 		}
-	} else if toolsAny, ok := reqBody["tools"].([]any); ok && len(toolsAny) > 0 {
-		if last, ok := toolsAny[len(toolsAny)-1].(map[string]any); ok {
-			if _, has := last["cache_control"]; !has {
-				last["cache_control"] = cacheMark
-			}
+	}
+}
+
+// requestMessage is a view over one entry of reqBody["messages"], which
+// holds []service.Message on the API-key path and []any of maps on the
+// OAuth path.
+type requestMessage struct {
+	role    string
+	content func() any
+	set     func(any)
+}
+
+// blocks returns the message's content blocks, converting plain string
+// content to a single text block first so it can carry a marker.
+func (m requestMessage) blocks() []map[string]any {
+	if s, ok := m.content().(string); ok {
+		if s == "" {
+			return nil
 		}
+		m.set([]any{map[string]any{"type": "text", "text": s}})
 	}
+	return blockMaps(m.content())
+}
 
-	// Messages: mark the LAST content block on the LAST message.
-	// Anthropic's prompt cache breakpoint hashes everything up to and
-	// including the marked block, so putting it on the last message
-	// caches the full conversation prefix for the next turn.
-	switch m := reqBody["messages"].(type) {
+func requestMessages(reqBody map[string]any) []requestMessage {
+	var out []requestMessage
+	switch msgs := reqBody["messages"].(type) {
 	case []service.Message:
-		if len(m) == 0 {
-			return
+		for i := range msgs {
+			out = append(out, requestMessage{
+				role:    msgs[i].Role,
+				content: func() any { return msgs[i].Content },
+				set:     func(c any) { msgs[i].Content = c },
+			})
 		}
-		markMessageContent(m[len(m)-1].Content, cacheMark, func(content any) {
-			m[len(m)-1].Content = content
-		})
-		reqBody["messages"] = m
 	case []any:
-		if len(m) == 0 {
-			return
-		}
-		last, ok := m[len(m)-1].(map[string]any)
-		if !ok {
-			return
+		for _, raw := range msgs {
+			m, ok := raw.(map[string]any)
+			if !ok {
+				continue
+			}
+			role, _ := m["role"].(string)
+			out = append(out, requestMessage{
+				role:    role,
+				content: func() any { return m["content"] },
+				set:     func(c any) { m["content"] = c },
+			})
 		}
-		markMessageContent(last["content"], cacheMark, func(content any) {
-			last["content"] = content
-		})
-	default:
-		return
 	}
+	return out
 }
 
-func markMessageContent(content any, cacheMark map[string]any, set func(any)) {
-	switch c := content.(type) {
-	case string:
-		if c != "" {
-			set([]any{map[string]any{
-				"type":          "text",
-				"text":          c,
-				"cache_control": cacheMark,
-			}})
-		}
+// blockMaps returns the map elements of a content-block, system or tools
+// slice. The returned maps are shared, so setting a key marks the request.
+func blockMaps(v any) []map[string]any {
+	switch blocks := v.(type) {
+	case []map[string]any:
+		return blocks
 	case []any:
-		if n := len(c); n > 0 {
-			if last, ok := c[n-1].(map[string]any); ok {
-				if _, has := last["cache_control"]; !has {
-					last["cache_control"] = cacheMark
-				}
+		out := make([]map[string]any, 0, len(blocks))
+		for _, b := range blocks {
+			if m, ok := b.(map[string]any); ok {
+				out = append(out, m)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+// cacheableBlock reports whether Anthropic accepts a cache_control marker
+// on the block.
+func cacheableBlock(b map[string]any) bool {
+	switch b["type"] {
+	case "thinking", "redacted_thinking":
+		return false
+	case "text":
+		text, _ := b["text"].(string)
+		return text != ""
+	}
+	return true
+}
+
+func countCacheMarkers(reqBody map[string]any, msgs []requestMessage) int {
+	n := 0
+	count := func(blocks []map[string]any) {
+		for _, b := range blocks {
+			if _, has := b["cache_control"]; has {
+				n++
 			}
 		}
 	}
+	count(blockMaps(reqBody["system"]))
+	count(blockMaps(reqBody["tools"]))
+	for _, m := range msgs {
+		count(blockMaps(m.content()))
+	}
+	return n
 }
 
 // mergeConsecutiveMessages merges adjacent messages that share the same role.
@@ -1571,6 +1668,14 @@ func contentBlockToMap(b service.ContentBlock) map[string]any {
 			m["content"] = b.Content
 		}
 		return m
+	case "thinking":
+		return map[string]any{
+			"type":      "thinking",
+			"thinking":  b.Thinking,
+			"signature": b.Signature,
+		}
+	case "redacted_thinking":
+		return map[string]any{"type": "redacted_thinking", "data": b.Data}
 	case "image", "document", "audio", "video":
 		// Media content blocks carry their data in a Source field
 		// (base64-encoded or URL reference).
@@ -265,8 +265,9 @@ func TestBuildRequestBodyPromptCachingOAuth(t *testing.T) {
 		t.Fatalf("marshal body: %v", err)
 	}
 
-	if got := strings.Count(string(bodyJSON), `"cache_control":{"type":"ephemeral"}`); got != 3 {
-		t.Fatalf("cache marker count = %d, want 3; body=%s", got, bodyJSON)
+	// system, tool, latest turn and the previous user turn.
+	if got := strings.Count(string(bodyJSON), `"cache_control":{"type":"ephemeral"}`); got != 4 {
+		t.Fatalf("cache marker count = %d, want 4; body=%s", got, bodyJSON)
 	}
 	if !bytesContainsString(bodyJSON, `"name":"mcp_Task_get"`) {
 		t.Fatalf("OAuth tool transform missing: %s", bodyJSON)
@@ -293,6 +294,115 @@ func TestBuildRequestBodyPromptCachingOAuthDisabled(t *testing.T) {
 	}
 }
 
+func TestBuildRequestBodyPromptCachingBlocks(t *testing.T) {
+	p := &Provider{MaxTokens: 1024}
+	messages := []service.Message{
+		{Role: "system", Content: "stable agent instructions"},
+		{Role: "user", Content: "run the task"},
+		{Role: "assistant", Content: []any{
+			map[string]any{"type": "thinking", "thinking": "let me look", "signature": "sig"},
+			map[string]any{"type": "tool_use", "id": "t1", "name": "task_get", "input": map[string]any{"id": "1"}},
+		}},
+		{Role: "user", Content: []service.ContentBlock{
+			{Type: "tool_result", ToolUseID: "t1", Content: "task one"},
+		}},
+		{Role: "assistant", Content: []any{
+			map[string]any{"type": "tool_use", "id": "t2", "name": "task_get", "input": map[string]any{"id": "2"}},
+		}},
+		{Role: "user", Content: []service.ContentBlock{
+			{Type: "tool_result", ToolUseID: "t2", Content: "task two"},
+		}},
+	}
+	tools := []service.Tool{{Name: "task_get", InputSchema: map[string]any{"type": "object"}}}
+
+	body := p.buildRequestBody("claude-sonnet-4-6", messages, tools, nil)
+
+	marked := func(v any) int {
+		n := 0
+		for _, b := range blockMaps(v) {
+			if _, ok := b["cache_control"]; ok {
+				n++
+			}
+		}
+		return n
+	}
+	msgs := body["messages"].([]service.Message)
+	if len(msgs) != 5 {
+		t.Fatalf("messages = %d, want 5", len(msgs))
+	}
+	// The latest tool_result turn and the one before it (the last message
+	// of the previous iteration) carry the history breakpoints.
+	for i, want := range []int{0, 0, 1, 0, 1} {
+		if got := marked(msgs[i].Content); got != want {
+			t.Errorf("message %d (%s) markers = %d, want %d", i, msgs[i].Role, got, want)
+		}
+	}
+	if marked(body["system"]) != 1 || marked(body["tools"]) != 1 {
+		t.Errorf("system/tools not marked: %v / %v", body["system"], body["tools"])
+	}
+}
+
+func TestInstallPromptCacheMarkersSkipsThinking(t *testing.T) {
+	toolUse := map[string]any{"type": "tool_use", "id": "t1", "name": "task_get", "input": map[string]any{}}
+	thinking := map[string]any{"type": "thinking", "thinking": "hmm", "signature": "sig"}
+	reqBody := map[string]any{"messages": []any{
+		map[string]any{"role": "assistant", "content": []any{toolUse, thinking}},
+	}}
+
+	installPromptCacheMarkers(reqBody)
+
+	if _, ok := thinking["cache_control"]; ok {
+		t.Error("thinking block was marked")
+	}
+	if _, ok := toolUse["cache_control"]; !ok {
+		t.Error("block before the thinking block was not marked")
+	}
+}
+
+func TestBuildRequestBodyPromptCachingBudget(t *testing.T) {
+	p := &Provider{MaxTokens: 1024}
+	callerMark := map[string]any{"type": "ephemeral", "ttl": "1h"}
+	messages := []service.Message{
+		{Role: "system", Content: "stable agent instructions"},
+		{Role: "user", Content: []any{
+			map[string]any{"type": "text", "text": "big document", "cache_control": callerMark},
+		}},
+		{Role: "assistant", Content: "read it"},
+		{Role: "user", Content: "summarise"},
+	}
+	tools := []service.Tool{{Name: "task_get", InputSchema: map[string]any{"type": "object"}}}
+
+	body := p.buildRequestBody("claude-sonnet-4-6", messages, tools, nil)
+	bodyJSON, err := json.Marshal(body)
+	if err != nil {
+		t.Fatalf("marshal body: %v", err)
+	}
+	// The caller's own breakpoint leaves room for system, tools and the
+	// latest turn; the previous user turn is already marked.
+	if got := strings.Count(string(bodyJSON), `"cache_control"`); got != maxCacheBreakpoints {
+		t.Fatalf("cache marker count = %d, want %d; body=%s", got, maxCacheBreakpoints, bodyJSON)
+	}
+	if !strings.Contains(string(bodyJSON), `"ttl":"1h"`) {
+		t.Errorf("caller marker was replaced: %s", bodyJSON)
+	}
+}
+
+func TestBuildRequestBodyPromptCachingDisabledPerCall(t *testing.T) {
+	p := &Provider{MaxTokens: 1024}
+
+	body := p.buildRequestBody("claude-sonnet-4-6", []service.Message{
+		{Role: "system", Content: "stable agent instructions"},
+		{Role: "user", Content: "latest turn"},
+	}, nil, &service.ChatOptions{DisablePromptCache: true})
+	bodyJSON, err := json.Marshal(body)
+	if err != nil {
+		t.Fatalf("marshal body: %v", err)
+	}
+	if bytesContainsString(bodyJSON, `"cache_control"`) {
+		t.Fatalf("cache marker present while disabled: %s", bodyJSON)
+	}
+}
+
 // bytesContainsString is a small helper to keep the test asserts
 // readable without pulling in another dep.
 func bytesContainsString(b []byte, sub string) bool {
@@ -437,3 +547,60 @@ func TestConvertContentHandlesRawAnySlice(t *testing.T) {
 		}
 	}
 }
+
+func TestChatStreamReplaysAndReturnsThinkingSignature(t *testing.T) {
+	var body struct {
+		Messages []struct {
+			Role    string           `json:"role"`
+			Content []map[string]any `json:"content"`
+		} `json:"messages"`
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, ev := range []string{
+			`{"type":"content_block_start","index":0,"content_block":{"type":"thinking","thinking":""}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"thinking_delta","thinking":"hmm"}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"signature_delta","signature":"sig-2"}}`,
+			`{"type":"content_block_stop","index":0}`,
+			`{"type":"message_stop"}`,
+		} {
+			_, _ = io.WriteString(w, "data: "+ev+"\n\n")
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	p, err := New("test-key", "claude-sonnet-4-5", srv.URL, "", false)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	ch, _, err := p.ChatStream(t.Context(), "", []service.Message{
+		{Role: "user", Content: "look up go"},
+		{Role: "assistant", Content: []service.ContentBlock{
+			{Type: "thinking", Thinking: "need a lookup", Signature: "sig-1"},
+			{Type: "redacted_thinking", Data: "opaque"},
+			{Type: "tool_use", ID: "toolu_1", Name: "lookup", Input: map[string]any{"q": "go"}},
+		}},
+		{Role: "user", Content: []service.ContentBlock{{Type: "tool_result", ToolUseID: "toolu_1", Content: "found"}}},
+	}, nil, nil)
+	if err != nil {
+		t.Fatalf("ChatStream() error = %v", err)
+	}
+	var reasoning, signature string
+	for chunk := range ch {
+		reasoning += chunk.ReasoningContent
+		signature += chunk.ReasoningSignature
+	}
+	if reasoning != "hmm" || signature != "sig-2" {
+		t.Errorf("reasoning = %q, signature = %q", reasoning, signature)
+	}
+
+	if len(body.Messages) != 3 {
+		t.Fatalf("messages = %+v", body.Messages)
+	}
+	blocks := body.Messages[1].Content
+	if len(blocks) != 3 || blocks[0]["type"] != "thinking" || blocks[0]["signature"] != "sig-1" ||
+		blocks[1]["type"] != "redacted_thinking" || blocks[1]["data"] != "opaque" {
+		t.Errorf("assistant blocks = %+v", blocks)
+	}
+}
@@ -0,0 +1,86 @@
+package antropic
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/rakunlabs/at/internal/service"
+)
+
+// ─── Token counting ───
+//
+// Native Anthropic endpoint:
+//   POST /v1/messages/count_tokens
+// Vertex AI endpoint (model stays in the body):
+//   POST /v1/projects/{project}/locations/{region}/publishers/anthropic/models/count-tokens:rawPredict
+// Both take the same messages/system/tools as /v1/messages but rejects the
+// sampling fields, so only the counted keys are forwarded.
+
+var countTokensFields = []string{"model", "messages", "system", "tools", "tool_choice", "thinking"}
+
+// CountTokens implements service.TokenCountProvider. Only static API key
+// auth is supported; the OAuth path rewrites the request envelope and
+// returns service.ErrTokenCountUnsupported.
+func (p *Provider) CountTokens(ctx context.Context, model string, messages []service.Message, tools []service.Tool) (int, error) {
+	if p.tokenSource != nil {
+		return 0, service.ErrTokenCountUnsupported
+	}
+	if model == "" {
+		model = p.Model
+	}
+
+	full := p.buildRequestBody(model, messages, tools, nil)
+	reqBody := make(map[string]any, len(countTokensFields))
+	for _, k := range countTokensFields {
+		if v, ok := full[k]; ok {
+			reqBody[k] = v
+		}
+	}
+	jsonData, err := json.Marshal(reqBody)
+	if err != nil {
+		return 0, fmt.Errorf("marshal count_tokens request: %w", err)
+	}
+
+	path := "/v1/messages/count_tokens"
+	if p.vertex != nil {
+		path = p.vertex.modelPath("count-tokens", "rawPredict")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, path, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return 0, err
+	}
+	if p.vertex != nil {
+		if err := p.vertex.authorize(req); err != nil {
+			return 0, err
+		}
+	}
+
+	var result struct {
+		InputTokens int `json:"input_tokens"`
+		Error       struct {
+			Type    string `json:"type"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := p.Client.Do(req, func(r *http.Response) error {
+		bodyData, err := io.ReadAll(r.Body)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(bodyData, &result); err != nil {
+			return fmt.Errorf("failed to decode count_tokens response (status %d): %w (body: %s)", r.StatusCode, err, string(bodyData))
+		}
+		if r.StatusCode != http.StatusOK {
+			return fmt.Errorf("anthropic count_tokens error [%s] (status %d): %s", result.Error.Type, r.StatusCode, result.Error.Message)
+		}
+		return nil
+	}); err != nil {
+		return 0, err
+	}
+
+	return result.InputTokens, nil
+}
@@ -0,0 +1,57 @@
+package antropic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rakunlabs/at/internal/service"
+)
+
+func TestCountTokensSendsOnlyCountedFields(t *testing.T) {
+	var body map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/messages/count_tokens" {
+			t.Errorf("path = %q", r.URL.Path)
+		}
+		if r.Header.Get("x-api-key") != "test-key" {
+			t.Errorf("x-api-key = %q", r.Header.Get("x-api-key"))
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"input_tokens":17}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	p, err := New("test-key", "claude-sonnet-4-5", srv.URL, "", false)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	n, err := p.CountTokens(context.Background(), "", []service.Message{
+		{Role: "system", Content: "be brief"},
+		{Role: "user", Content: "hello"},
+	}, []service.Tool{{Name: "lookup", InputSchema: map[string]any{"type": "object"}}})
+	if err != nil || n != 17 {
+		t.Fatalf("CountTokens = %d, err=%v", n, err)
+	}
+
+	if body["model"] != "claude-sonnet-4-5" || body["system"] == nil || body["tools"] == nil || body["messages"] == nil {
+		t.Errorf("body = %v", body)
+	}
+	if _, ok := body["max_tokens"]; ok {
+		t.Errorf("max_tokens must not be sent: %v", body)
+	}
+}
+
+func TestCountTokensUnsupportedWithOAuth(t *testing.T) {
+	p, err := New("", "claude-sonnet-4-5", "http://127.0.0.1:0", "", false, WithTokenSource(NewStaticTokenSource("oauth-token")))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if _, err := p.CountTokens(context.Background(), "", []service.Message{{Role: "user", Content: "hi"}}, nil); !errors.Is(err, service.ErrTokenCountUnsupported) {
+		t.Fatalf("err = %v, want ErrTokenCountUnsupported", err)
+	}
+}
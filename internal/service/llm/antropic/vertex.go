@@ -0,0 +1,95 @@
+package antropic
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// ─── Claude on Vertex AI ───
+//
+// Vertex serves Anthropic models through the publisher endpoints
+//   POST /v1/projects/{project}/locations/{region}/publishers/anthropic/models/{model}:rawPredict
+//   POST /v1/projects/{project}/locations/{region}/publishers/anthropic/models/{model}:streamRawPredict
+// The body is the Messages API body with two differences: the model lives
+// in the URL instead of the body, and anthropic_version moves from the
+// header into the body. Auth is a Google OAuth access token (ADC).
+
+// VertexAnthropicVersion is the anthropic_version Vertex expects in the body.
+const VertexAnthropicVersion = "vertex-2023-10-16"
+
+// GoogleTokenSource yields Google OAuth access tokens. It matches
+// gemini.GoogleTokenSource so the same ADC adapter serves both providers.
+type GoogleTokenSource interface {
+	Token() (string, error)
+}
+
+type vertexConfig struct {
+	project     string
+	region      string
+	tokenSource GoogleTokenSource
+}
+
+// WithVertex routes Chat, ChatStream and CountTokens through Vertex AI's
+// Anthropic endpoints for project/region, authenticating with ts.
+// Message translation, thinking and prompt caching are unchanged.
+func WithVertex(project, region string, ts GoogleTokenSource) Option {
+	return func(p *Provider) {
+		p.vertex = &vertexConfig{project: project, region: region, tokenSource: ts}
+	}
+}
+
+// VertexBaseURL returns the Vertex AI host for region. The "global"
+// region uses the non-regional host.
+func VertexBaseURL(region string) string {
+	if region == "" || region == "global" {
+		return "https://aiplatform.googleapis.com"
+	}
+	return "https://" + region + "-aiplatform.googleapis.com"
+}
+
+// modelPath returns the publisher path for model and method
+// (rawPredict or streamRawPredict).
+func (v *vertexConfig) modelPath(model, method string) string {
+	return fmt.Sprintf("/v1/projects/%s/locations/%s/publishers/anthropic/models/%s:%s",
+		url.PathEscape(v.project), url.PathEscape(v.region), url.PathEscape(model), method)
+}
+
+// adaptBody rewrites a Messages API body for rawPredict.
+func (v *vertexConfig) adaptBody(reqBody map[string]any) {
+	delete(reqBody, "model")
+	reqBody["anthropic_version"] = VertexAnthropicVersion
+}
+
+// authorize sets the Google bearer token on req.
+func (v *vertexConfig) authorize(req *http.Request) error {
+	if v.tokenSource == nil {
+		return fmt.Errorf("vertex: no Google token source configured")
+	}
+	token, err := v.tokenSource.Token()
+	if err != nil {
+		return fmt.Errorf("vertex: get access token: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
+
+// messagesPath returns the request path for a Messages call.
+func (p *Provider) messagesPath(model string, stream bool) string {
+	switch {
+	case p.vertex != nil:
+		if stream {
+			return p.vertex.modelPath(model, "streamRawPredict")
+		}
+		return p.vertex.modelPath(model, "rawPredict")
+	case p.tokenSource != nil:
+		// On the OAuth path Anthropic expects /v1/messages?beta=true. The
+		// `?beta=true` query string activates the experimental message
+		// envelope Claude Code uses; without it the request is rejected
+		// before billing validation even runs. Static-API-key callers
+		// don't get the query param (it changes accounting on that path).
+		return "/v1/messages?beta=true"
+	default:
+		return "/v1/messages"
+	}
+}
@@ -0,0 +1,144 @@
+package antropic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rakunlabs/at/internal/service"
+)
+
+type fakeGoogleTokenSource string
+
+func (f fakeGoogleTokenSource) Token() (string, error) { return string(f), nil }
+
+func newVertexTestProvider(t *testing.T, baseURL string) *Provider {
+	t.Helper()
+	p, err := New("", "claude-sonnet-4-5@20250929", baseURL, "", false,
+		WithVertex("my-proj", "us-east5", fakeGoogleTokenSource("g-token")))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	return p
+}
+
+func TestVertexChatUsesRawPredict(t *testing.T) {
+	var body map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		want := "/v1/projects/my-proj/locations/us-east5/publishers/anthropic/models/claude-sonnet-4-5@20250929:rawPredict"
+		if r.URL.Path != want {
+			t.Errorf("path = %q, want %q", r.URL.Path, want)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer g-token" {
+			t.Errorf("Authorization = %q", got)
+		}
+		if r.Header.Get("x-api-key") != "" || r.Header.Get("anthropic-version") != "" {
+			t.Errorf("unexpected Anthropic auth headers: %v", r.Header)
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		_, _ = w.Write([]byte(`{"type":"message","role":"assistant","stop_reason":"end_turn",
+			"content":[{"type":"thinking","thinking":"hmm"},{"type":"text","text":"hi"}],
+			"usage":{"input_tokens":5,"output_tokens":2,"cache_read_input_tokens":3}}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	p := newVertexTestProvider(t, srv.URL)
+	resp, err := p.Chat(context.Background(), "", []service.Message{
+		{Role: "system", Content: "be brief"},
+		{Role: "user", Content: "hello"},
+	}, nil, &service.ChatOptions{ReasoningEffort: "low"})
+	if err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+	if resp.Content != "hi" || resp.ReasoningContent != "hmm" || resp.Usage.CacheReadTokens != 3 {
+		t.Errorf("resp = %+v", resp)
+	}
+
+	if _, ok := body["model"]; ok {
+		t.Errorf("model must not be sent in the body: %v", body)
+	}
+	if body["anthropic_version"] != VertexAnthropicVersion {
+		t.Errorf("anthropic_version = %v", body["anthropic_version"])
+	}
+	if body["thinking"] == nil {
+		t.Errorf("thinking missing: %v", body)
+	}
+	sys, _ := body["system"].([]any)
+	if len(sys) == 0 {
+		t.Fatalf("system = %#v, want cache-marked blocks", body["system"])
+	}
+	if block, _ := sys[len(sys)-1].(map[string]any); block["cache_control"] == nil {
+		t.Errorf("system block missing cache_control: %v", block)
+	}
+}
+
+func TestVertexChatStreamUsesStreamRawPredict(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		want := "/v1/projects/my-proj/locations/us-east5/publishers/anthropic/models/claude-sonnet-4-5@20250929:streamRawPredict"
+		if r.URL.Path != want {
+			t.Errorf("path = %q, want %q", r.URL.Path, want)
+		}
+		var body map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if body["stream"] != true || body["anthropic_version"] != VertexAnthropicVersion {
+			t.Errorf("body = %v", body)
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = w.Write([]byte("event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"usage\":{\"input_tokens\":4}}}\n\n" +
+			"data: {\"type\":\"content_block_start\",\"content_block\":{\"type\":\"text\"}}\n\n" +
+			"data: {\"type\":\"content_block_delta\",\"delta\":{\"type\":\"text_delta\",\"text\":\"ok\"}}\n\n" +
+			"data: {\"type\":\"message_delta\",\"delta\":{\"stop_reason\":\"end_turn\",\"usage\":{\"output_tokens\":1}}}\n\n" +
+			"data: {\"type\":\"message_stop\"}\n\n"))
+	}))
+	t.Cleanup(srv.Close)
+
+	p := newVertexTestProvider(t, srv.URL)
+	ch, _, err := p.ChatStream(context.Background(), "", []service.Message{{Role: "user", Content: "hi"}}, nil, nil)
+	if err != nil {
+		t.Fatalf("ChatStream() error = %v", err)
+	}
+	var content, finish string
+	var usage *service.Usage
+	for chunk := range ch {
+		if chunk.Error != nil {
+			t.Fatalf("chunk error: %v", chunk.Error)
+		}
+		content += chunk.Content
+		if chunk.FinishReason != "" {
+			finish = chunk.FinishReason
+		}
+		if chunk.Usage != nil {
+			usage = chunk.Usage
+		}
+	}
+	if content != "ok" || finish != "end_turn" || usage == nil || usage.PromptTokens != 4 || usage.CompletionTokens != 1 {
+		t.Errorf("content=%q finish=%q usage=%+v", content, finish, usage)
+	}
+}
+
+func TestVertexChatGoogleRateLimitError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+		_, _ = w.Write([]byte(`[{"error":{"code":429,"message":"Quota exceeded","status":"RESOURCE_EXHAUSTED"}}]`))
+	}))
+	t.Cleanup(srv.Close)
+
+	p := newVertexTestProvider(t, srv.URL)
+	_, err := p.Chat(context.Background(), "", []service.Message{{Role: "user", Content: "hi"}}, nil, nil)
+	var rle *service.RateLimitError
+	if !errors.As(err, &rle) || rle.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("err = %v, want 429 *service.RateLimitError", err)
+	}
+}
+
+func TestVertexBaseURL(t *testing.T) {
+	if got := VertexBaseURL("us-east5"); got != "https://us-east5-aiplatform.googleapis.com" {
+		t.Errorf("regional = %q", got)
+	}
+	if got := VertexBaseURL("global"); got != "https://aiplatform.googleapis.com" {
+		t.Errorf("global = %q", got)
+	}
+}
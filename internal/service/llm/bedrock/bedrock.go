@@ -20,9 +20,8 @@
 // AWS_REGION env var. We do NOT call IMDSv2 / STS — that would couple us
 // to running on EC2.
 //
-// This provider is non-streaming: Chat uses the /converse endpoint and the
-// gateway fake-streams the result. True streaming would require the AWS
-// event-stream binary protocol (ConverseStream) — not implemented.
+// Chat uses the /converse endpoint; ChatStream uses /converse-stream and
+// decodes the AWS event-stream binary framing (see eventstream.go).
 package bedrock
 
 import (
@@ -32,6 +31,7 @@ import (
 	"crypto/tls"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
@@ -185,10 +185,24 @@ type converseMessage struct {
 }
 
 type converseContentB struct {
-	Text       string              `json:"text,omitempty"`
-	ToolUse    *converseToolUse    `json:"toolUse,omitempty"`
-	ToolResult *converseToolResult `json:"toolResult,omitempty"`
-	Image      *converseImage      `json:"image,omitempty"`
+	Text             string                    `json:"text,omitempty"`
+	ToolUse          *converseToolUse          `json:"toolUse,omitempty"`
+	ToolResult       *converseToolResult       `json:"toolResult,omitempty"`
+	Image            *converseImage            `json:"image,omitempty"`
+	Document         *converseDocument         `json:"document,omitempty"`
+	ReasoningContent *converseReasoningContent `json:"reasoningContent,omitempty"`
+}
+
+// converseReasoningContent is a Claude thinking block; signed reasoning
+// must be sent back unchanged on the assistant turn of a tool loop.
+type converseReasoningContent struct {
+	ReasoningText   *converseReasoningText `json:"reasoningText,omitempty"`
+	RedactedContent string                 `json:"redactedContent,omitempty"`
+}
+
+type converseReasoningText struct {
+	Text      string `json:"text"`
+	Signature string `json:"signature,omitempty"`
 }
 
 type converseSystemBlock struct {
@@ -250,6 +264,12 @@ type converseImageSourceB struct {
 	Bytes string `json:"bytes,omitempty"` // base64
 }
 
+type converseDocument struct {
+	Format string               `json:"format"`
+	Name   string               `json:"name"`
+	Source converseImageSourceB `json:"source"`
+}
+
 // converseResponse is the Bedrock Converse API response body.
 type converseResponse struct {
 	Output struct {
@@ -308,6 +328,7 @@ func (p *Provider) Chat(ctx context.Context, model string, messages []service.Me
 		return nil, fmt.Errorf("sign bedrock request: %w", err)
 	}
 
+	common.InjectTraceContext(ctx, req)
 	resp, err := p.httpClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("bedrock http: %w", err)
@@ -319,26 +340,8 @@ func (p *Provider) Chat(ctx context.Context, model string, messages []service.Me
 		return nil, fmt.Errorf("read bedrock response: %w", err)
 	}
 
-	if resp.StatusCode == http.StatusTooManyRequests {
-		return nil, &service.RateLimitError{
-			StatusCode: resp.StatusCode,
-			RetryAfter: common.ParseRetryAfter(resp.Header),
-			Provider:   "bedrock",
-			Message:    string(respBody),
-			Underlying: fmt.Errorf("bedrock 429: %s", string(respBody)),
-		}
-	}
 	if resp.StatusCode >= 400 {
-		// Bedrock returns {"message":"..."} on error
-		var errBody struct {
-			Message string `json:"message"`
-		}
-		_ = json.Unmarshal(respBody, &errBody)
-		msg := errBody.Message
-		if msg == "" {
-			msg = string(respBody)
-		}
-		return nil, fmt.Errorf("bedrock API error (status %d): %s", resp.StatusCode, msg)
+		return nil, bedrockHTTPError(resp, respBody)
 	}
 
 	var parsed converseResponse
@@ -363,6 +366,10 @@ func (p *Provider) Chat(ctx context.Context, model string, messages []service.Me
 		if block.Text != "" {
 			out.Content += block.Text
 		}
+		if rc := block.ReasoningContent; rc != nil && rc.ReasoningText != nil {
+			out.ReasoningContent += rc.ReasoningText.Text
+			out.ReasoningSignature = rc.ReasoningText.Signature
+		}
 		if block.ToolUse != nil {
 			out.ToolCalls = append(out.ToolCalls, service.ToolCall{
 				ID:        block.ToolUse.ToolUseID,
@@ -375,6 +382,251 @@ func (p *Provider) Chat(ctx context.Context, model string, messages []service.Me
 	return out, nil
 }
 
+// bedrockHTTPError turns a non-2xx Bedrock response into an error. 429s
+// become a typed *RateLimitError so retry loops honour Retry-After.
+func bedrockHTTPError(resp *http.Response, body []byte) error {
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return &service.RateLimitError{
+			StatusCode: resp.StatusCode,
+			RetryAfter: common.ParseRetryAfter(resp.Header),
+			Provider:   "bedrock",
+			Message:    string(body),
+			Underlying: fmt.Errorf("bedrock 429: %s", string(body)),
+		}
+	}
+
+	// Bedrock returns {"message":"..."} on error
+	var errBody struct {
+		Message string `json:"message"`
+	}
+	_ = json.Unmarshal(body, &errBody)
+	msg := errBody.Message
+	if msg == "" {
+		msg = string(body)
+	}
+	return fmt.Errorf("bedrock API error (status %d): %s", resp.StatusCode, msg)
+}
+
+// ─── ConverseStream ───
+
+// converseStreamEvent is the JSON payload of one ConverseStream event. The
+// event type comes from the frame's :event-type header; only the fields of
+// that type are set.
+type converseStreamEvent struct {
+	ContentBlockIndex int `json:"contentBlockIndex"`
+	Start             *struct {
+		ToolUse *struct {
+			ToolUseID string `json:"toolUseId"`
+			Name      string `json:"name"`
+		} `json:"toolUse,omitempty"`
+	} `json:"start,omitempty"`
+	Delta *struct {
+		Text    string `json:"text,omitempty"`
+		ToolUse *struct {
+			Input string `json:"input"`
+		} `json:"toolUse,omitempty"`
+		ReasoningContent *struct {
+			Text      string `json:"text,omitempty"`
+			Signature string `json:"signature,omitempty"`
+		} `json:"reasoningContent,omitempty"`
+	} `json:"delta,omitempty"`
+	StopReason string `json:"stopReason,omitempty"`
+	Usage      *struct {
+		InputTokens           int `json:"inputTokens"`
+		OutputTokens          int `json:"outputTokens"`
+		TotalTokens           int `json:"totalTokens"`
+		CacheReadInputTokens  int `json:"cacheReadInputTokens"`
+		CacheWriteInputTokens int `json:"cacheWriteInputTokens"`
+	} `json:"usage,omitempty"`
+	Message string `json:"message,omitempty"` // exception events
+}
+
+// streamToolUse accumulates one tool_use block's partial JSON input.
+type streamToolUse struct {
+	id    string
+	name  string
+	input strings.Builder
+}
+
+// ChatStream implements service.LLMStreamProvider via the ConverseStream
+// API.
+func (p *Provider) ChatStream(ctx context.Context, model string, messages []service.Message, tools []service.Tool, opts *service.ChatOptions) (<-chan service.StreamChunk, http.Header, error) {
+	if model == "" {
+		model = p.model
+	}
+	if model == "" {
+		return nil, nil, fmt.Errorf("bedrock: model is required")
+	}
+
+	// The release runs after the stream goroutine finishes; synchronous
+	// error paths release immediately.
+	release, err := p.limiter.Acquire(ctx, common.EstimateInputTokens("", messages, tools))
+	if err != nil {
+		return nil, nil, err
+	}
+	releaseOnce := func() {
+		if release != nil {
+			release()
+			release = nil
+		}
+	}
+
+	bodyBytes, err := json.Marshal(p.buildConverseRequest(messages, tools, opts))
+	if err != nil {
+		releaseOnce()
+		return nil, nil, fmt.Errorf("marshal bedrock request: %w", err)
+	}
+
+	// Path: /model/{modelId}/converse-stream
+	endpoint := fmt.Sprintf("%s/model/%s/converse-stream", p.endpoint, url.PathEscape(model))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(bodyBytes))
+	if err != nil {
+		releaseOnce()
+		return nil, nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/vnd.amazon.eventstream")
+
+	if err := p.signSigV4(req, bodyBytes); err != nil {
+		releaseOnce()
+		return nil, nil, fmt.Errorf("sign bedrock request: %w", err)
+	}
+
+	common.InjectTraceContext(ctx, req)
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		releaseOnce()
+		return nil, nil, fmt.Errorf("bedrock http: %w", err)
+	}
+
+	if resp.StatusCode >= 400 {
+		defer resp.Body.Close()
+		defer releaseOnce()
+		respBody, _ := io.ReadAll(resp.Body)
+		return nil, nil, bedrockHTTPError(resp, respBody)
+	}
+
+	ch := make(chan service.StreamChunk, 64)
+
+	go func() {
+		defer close(ch)
+		defer resp.Body.Close()
+		defer releaseOnce()
+
+		// Tool input arrives as partial JSON per content block; it is
+		// parsed and emitted as one ToolCall when the block stops.
+		toolUses := map[int]*streamToolUse{}
+
+		dec := newEventStreamDecoder(resp.Body)
+		for {
+			msg, err := dec.Next()
+			if err != nil {
+				if !errors.Is(err, io.EOF) {
+					ch <- service.StreamChunk{Error: fmt.Errorf("bedrock stream read error: %w", err)}
+				}
+				return
+			}
+
+			var event converseStreamEvent
+			if err := json.Unmarshal(msg.Payload, &event); err != nil {
+				ch <- service.StreamChunk{Error: fmt.Errorf("decode bedrock stream event: %w", err)}
+				return
+			}
+
+			if msgType := msg.Headers[":message-type"]; msgType != "" && msgType != "event" {
+				name := firstNonEmpty(msg.Headers[":exception-type"], msg.Headers[":error-code"])
+				text := firstNonEmpty(event.Message, msg.Headers[":error-message"], string(msg.Payload))
+				if name == "throttlingException" {
+					ch <- service.StreamChunk{Error: &service.RateLimitError{
+						StatusCode: http.StatusTooManyRequests,
+						Provider:   "bedrock",
+						Message:    text,
+						Underlying: fmt.Errorf("bedrock stream %s: %s", name, text),
+					}}
+					return
+				}
+				ch <- service.StreamChunk{Error: fmt.Errorf("bedrock stream %s: %s", name, text)}
+				return
+			}
+
+			switch msg.Headers[":event-type"] {
+			case "contentBlockStart":
+				if event.Start != nil && event.Start.ToolUse != nil {
+					toolUses[event.ContentBlockIndex] = &streamToolUse{
+						id:   event.Start.ToolUse.ToolUseID,
+						name: event.Start.ToolUse.Name,
+					}
+				}
+
+			case "contentBlockDelta":
+				if event.Delta == nil {
+					continue
+				}
+				switch {
+				case event.Delta.Text != "":
+					ch <- service.StreamChunk{Content: event.Delta.Text}
+				case event.Delta.ReasoningContent != nil && event.Delta.ReasoningContent.Text != "":
+					ch <- service.StreamChunk{ReasoningContent: event.Delta.ReasoningContent.Text}
+				case event.Delta.ReasoningContent != nil && event.Delta.ReasoningContent.Signature != "":
+					ch <- service.StreamChunk{ReasoningSignature: event.Delta.ReasoningContent.Signature}
+				case event.Delta.ToolUse != nil:
+					if tu := toolUses[event.ContentBlockIndex]; tu != nil {
+						tu.input.WriteString(event.Delta.ToolUse.Input)
+					}
+				}
+
+			case "contentBlockStop":
+				tu := toolUses[event.ContentBlockIndex]
+				if tu == nil {
+					continue
+				}
+				delete(toolUses, event.ContentBlockIndex)
+				args := map[string]any{}
+				if tu.input.Len() > 0 {
+					if err := json.Unmarshal([]byte(tu.input.String()), &args); err != nil {
+						slog.Warn("bedrock: invalid tool input JSON in stream", "tool", tu.name, "error", err)
+					}
+				}
+				ch <- service.StreamChunk{ToolCalls: []service.ToolCall{{
+					ID:        tu.id,
+					Name:      tu.name,
+					Arguments: args,
+				}}}
+
+			case "messageStop":
+				// Raw Converse stop reason (end_turn, tool_use, max_tokens,
+				// ...); the gateway maps it onto OpenAI's vocabulary.
+				if event.StopReason != "" {
+					ch <- service.StreamChunk{FinishReason: event.StopReason}
+				}
+
+			case "metadata":
+				// Final event: token usage for the whole response.
+				if event.Usage != nil {
+					ch <- service.StreamChunk{Usage: &service.Usage{
+						PromptTokens:     event.Usage.InputTokens,
+						CompletionTokens: event.Usage.OutputTokens,
+						CacheReadTokens:  event.Usage.CacheReadInputTokens,
+						CacheWriteTokens: event.Usage.CacheWriteInputTokens,
+						TotalTokens:      event.Usage.TotalTokens,
+					}}
+				}
+			}
+		}
+	}()
+
+	return ch, resp.Header, nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
 // buildConverseRequest translates internal Message + Tool slices into the
 // Bedrock Converse request shape.
 func (p *Provider) buildConverseRequest(messages []service.Message, tools []service.Tool, opts *service.ChatOptions) *converseRequest {
@@ -468,6 +720,16 @@ func convertContentToConverse(content any) []converseContentB {
 				if b.Text != "" {
 					out = append(out, converseContentB{Text: b.Text})
 				}
+			case "thinking":
+				if b.Signature != "" {
+					out = append(out, converseContentB{ReasoningContent: &converseReasoningContent{
+						ReasoningText: &converseReasoningText{Text: b.Thinking, Signature: b.Signature},
+					}})
+				}
+			case "redacted_thinking":
+				if b.Data != "" {
+					out = append(out, converseContentB{ReasoningContent: &converseReasoningContent{RedactedContent: b.Data}})
+				}
 			case "tool_use":
 				out = append(out, converseContentB{ToolUse: &converseToolUse{
 					ToolUseID: b.ID,
@@ -487,6 +749,15 @@ func convertContentToConverse(content any) []converseContentB {
 						Source: converseImageSourceB{Bytes: b.Source.Data},
 					}})
 				}
+			case "document":
+				// Converse takes inline bytes only; URL documents are dropped.
+				if b.Source != nil && b.Source.Data != "" {
+					out = append(out, converseContentB{Document: &converseDocument{
+						Format: documentFormatFromMime(b.Source.MediaType),
+						Name:   fmt.Sprintf("document-%d", len(out)+1),
+						Source: converseImageSourceB{Bytes: b.Source.Data},
+					}})
+				}
 			}
 		}
 		return out
@@ -613,6 +884,29 @@ func imageFormatFromMime(mime string) string {
 	return "png"
 }
 
+func documentFormatFromMime(mime string) string {
+	switch strings.ToLower(mime) {
+	case "text/csv":
+		return "csv"
+	case "application/msword":
+		return "doc"
+	case "application/vnd.openxmlformats-officedocument.wordprocessingml.document":
+		return "docx"
+	case "application/vnd.ms-excel":
+		return "xls"
+	case "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet":
+		return "xlsx"
+	case "text/html":
+		return "html"
+	case "text/plain":
+		return "txt"
+	case "text/markdown":
+		return "md"
+	}
+	// Bedrock accepts: pdf | csv | doc | docx | xls | xlsx | html | txt | md
+	return "pdf"
+}
+
 // ─── SigV4 signing ───
 
 // signSigV4 attaches AWS Signature Version 4 headers to req for the
@@ -749,6 +1043,7 @@ func (p *Provider) Proxy(w http.ResponseWriter, r *http.Request, path string) er
 		return err
 	}
 
+	common.InjectTraceContext(req.Context(), req)
 	resp, err := p.httpClient.Do(req)
 	if err != nil {
 		return err
@@ -1,7 +1,12 @@
 package bedrock
 
 import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/rakunlabs/at/internal/service"
@@ -102,3 +107,147 @@ func TestImageFormatFromMime(t *testing.T) {
 		}
 	}
 }
+
+func TestConvertContentToConverseDocument(t *testing.T) {
+	out := convertContentToConverse([]service.ContentBlock{
+		{Type: "text", Text: "summarize"},
+		{Type: "document", Source: &service.MediaSource{Type: "base64", MediaType: "application/pdf", Data: "JVBERi0="}},
+		{Type: "document", Source: &service.MediaSource{Type: "url", URL: "https://example.com/a.pdf"}},
+	})
+	if len(out) != 2 || out[1].Document == nil {
+		t.Fatalf("out = %+v", out)
+	}
+	if d := out[1].Document; d.Format != "pdf" || d.Name != "document-2" || d.Source.Bytes != "JVBERi0=" {
+		t.Errorf("document = %+v", d)
+	}
+}
+
+func TestConvertContentToConverseThinking(t *testing.T) {
+	out := convertContentToConverse([]service.ContentBlock{
+		{Type: "thinking", Thinking: "need a lookup", Signature: "sig-1"},
+		{Type: "thinking", Thinking: "unsigned"},
+		{Type: "redacted_thinking", Data: "opaque"},
+		{Type: "tool_use", ID: "toolu_1", Name: "lookup"},
+	})
+	if len(out) != 3 || out[0].ReasoningContent == nil || out[1].ReasoningContent == nil || out[2].ToolUse == nil {
+		t.Fatalf("out = %+v", out)
+	}
+	if rt := out[0].ReasoningContent.ReasoningText; rt == nil || rt.Text != "need a lookup" || rt.Signature != "sig-1" {
+		t.Errorf("reasoning text = %+v", rt)
+	}
+	if out[1].ReasoningContent.RedactedContent != "opaque" {
+		t.Errorf("redacted = %+v", out[1].ReasoningContent)
+	}
+}
+
+func newStreamTestProvider(t *testing.T, handler http.HandlerFunc) *Provider {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	p, err := New("AK:SK", "m", srv.URL, "", false)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return p
+}
+
+func writeStreamEvent(w http.ResponseWriter, eventType, payload string) {
+	_, _ = w.Write(encodeEventStreamFrame(map[string]string{
+		":message-type": "event",
+		":event-type":   eventType,
+		":content-type": "application/json",
+	}, []byte(payload)))
+}
+
+func TestChatStream(t *testing.T) {
+	p := newStreamTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/model/m/converse-stream" {
+			t.Errorf("path = %q", r.URL.Path)
+		}
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 ") {
+			t.Errorf("request not SigV4 signed: %q", r.Header.Get("Authorization"))
+		}
+		w.Header().Set("Content-Type", "application/vnd.amazon.eventstream")
+		writeStreamEvent(w, "messageStart", `{"role":"assistant"}`)
+		writeStreamEvent(w, "contentBlockDelta", `{"contentBlockIndex":0,"delta":{"reasoningContent":{"text":"hmm"}}}`)
+		writeStreamEvent(w, "contentBlockDelta", `{"contentBlockIndex":1,"delta":{"text":"Hel"}}`)
+		writeStreamEvent(w, "contentBlockDelta", `{"contentBlockIndex":1,"delta":{"text":"lo"}}`)
+		writeStreamEvent(w, "contentBlockStop", `{"contentBlockIndex":1}`)
+		writeStreamEvent(w, "contentBlockStart", `{"contentBlockIndex":2,"start":{"toolUse":{"toolUseId":"tu1","name":"lookup"}}}`)
+		writeStreamEvent(w, "contentBlockDelta", `{"contentBlockIndex":2,"delta":{"toolUse":{"input":"{\"q\":"}}}`)
+		writeStreamEvent(w, "contentBlockDelta", `{"contentBlockIndex":2,"delta":{"toolUse":{"input":"\"go\"}"}}}`)
+		writeStreamEvent(w, "contentBlockStop", `{"contentBlockIndex":2}`)
+		writeStreamEvent(w, "messageStop", `{"stopReason":"tool_use"}`)
+		writeStreamEvent(w, "metadata", `{"usage":{"inputTokens":10,"outputTokens":5,"totalTokens":15,"cacheReadInputTokens":3},"metrics":{"latencyMs":42}}`)
+	})
+
+	ch, _, err := p.ChatStream(context.Background(), "", []service.Message{{Role: "user", Content: "hi"}}, nil, nil)
+	if err != nil {
+		t.Fatalf("ChatStream: %v", err)
+	}
+
+	var content, reasoning, finish string
+	var calls []service.ToolCall
+	var usage *service.Usage
+	for chunk := range ch {
+		if chunk.Error != nil {
+			t.Fatalf("stream error: %v", chunk.Error)
+		}
+		content += chunk.Content
+		reasoning += chunk.ReasoningContent
+		calls = append(calls, chunk.ToolCalls...)
+		if chunk.FinishReason != "" {
+			finish = chunk.FinishReason
+		}
+		if chunk.Usage != nil {
+			usage = chunk.Usage
+		}
+	}
+
+	if content != "Hello" || reasoning != "hmm" || finish != "tool_use" {
+		t.Errorf("content=%q reasoning=%q finish=%q", content, reasoning, finish)
+	}
+	if len(calls) != 1 || calls[0].ID != "tu1" || calls[0].Name != "lookup" || calls[0].Arguments["q"] != "go" {
+		t.Errorf("tool calls = %+v", calls)
+	}
+	if usage == nil || usage.PromptTokens != 10 || usage.CompletionTokens != 5 || usage.CacheReadTokens != 3 {
+		t.Errorf("usage = %+v", usage)
+	}
+}
+
+func TestChatStreamThrottlingException(t *testing.T) {
+	p := newStreamTestProvider(t, func(w http.ResponseWriter, _ *http.Request) {
+		writeStreamEvent(w, "contentBlockDelta", `{"contentBlockIndex":0,"delta":{"text":"partial"}}`)
+		_, _ = w.Write(encodeEventStreamFrame(map[string]string{
+			":message-type":   "exception",
+			":exception-type": "throttlingException",
+		}, []byte(`{"message":"slow down"}`)))
+	})
+
+	ch, _, err := p.ChatStream(context.Background(), "", []service.Message{{Role: "user", Content: "hi"}}, nil, nil)
+	if err != nil {
+		t.Fatalf("ChatStream: %v", err)
+	}
+	var streamErr error
+	for chunk := range ch {
+		if chunk.Error != nil {
+			streamErr = chunk.Error
+		}
+	}
+	var rle *service.RateLimitError
+	if !errors.As(streamErr, &rle) || !strings.Contains(rle.Message, "slow down") {
+		t.Fatalf("stream error = %v, want a RateLimitError", streamErr)
+	}
+}
+
+func TestChatStreamHTTPError(t *testing.T) {
+	p := newStreamTestProvider(t, func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"message":"bad model"}`))
+	})
+
+	_, _, err := p.ChatStream(context.Background(), "", []service.Message{{Role: "user", Content: "hi"}}, nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "bad model") {
+		t.Fatalf("err = %v", err)
+	}
+}
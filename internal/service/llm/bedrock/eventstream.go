@@ -0,0 +1,142 @@
+package bedrock
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+// ─── AWS event-stream decoding ───
+//
+// ConverseStream responses use the AWS event-stream binary framing
+// (application/vnd.amazon.eventstream). Each message is:
+//
+//	total length   uint32 (big endian, whole message incl. CRCs)
+//	headers length uint32
+//	prelude CRC    uint32 (CRC-32 of the first 8 bytes)
+//	headers        (name len uint8, name, value type uint8, value)*
+//	payload        (JSON for Bedrock events)
+//	message CRC    uint32 (CRC-32 of everything before it)
+
+const (
+	eventStreamPreludeLen = 12
+	eventStreamCRCLen     = 4
+	// eventStreamMaxMessage bounds a single frame; Bedrock frames are a
+	// few KB, this only guards against a corrupt length prefix.
+	eventStreamMaxMessage = 16 * 1024 * 1024
+)
+
+// eventStreamMessage is one decoded event-stream frame. Only string header
+// values are kept; Bedrock sends every header it uses as a string.
+type eventStreamMessage struct {
+	Headers map[string]string
+	Payload []byte
+}
+
+// eventStreamDecoder reads event-stream frames from a response body.
+type eventStreamDecoder struct {
+	r *bufio.Reader
+}
+
+func newEventStreamDecoder(r io.Reader) *eventStreamDecoder {
+	return &eventStreamDecoder{r: bufio.NewReader(r)}
+}
+
+// Next returns the next frame, or io.EOF once the stream ended cleanly
+// between frames.
+func (d *eventStreamDecoder) Next() (*eventStreamMessage, error) {
+	prelude := make([]byte, eventStreamPreludeLen)
+	if _, err := io.ReadFull(d.r, prelude); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, fmt.Errorf("event-stream: truncated prelude")
+		}
+		return nil, err
+	}
+
+	totalLen := binary.BigEndian.Uint32(prelude[0:4])
+	headersLen := binary.BigEndian.Uint32(prelude[4:8])
+	if got, want := crc32.ChecksumIEEE(prelude[0:8]), binary.BigEndian.Uint32(prelude[8:12]); got != want {
+		return nil, fmt.Errorf("event-stream: prelude checksum mismatch")
+	}
+	if totalLen > eventStreamMaxMessage ||
+		totalLen < eventStreamPreludeLen+eventStreamCRCLen ||
+		headersLen > totalLen-eventStreamPreludeLen-eventStreamCRCLen {
+		return nil, fmt.Errorf("event-stream: invalid frame lengths (total %d, headers %d)", totalLen, headersLen)
+	}
+
+	msg := make([]byte, totalLen)
+	copy(msg, prelude)
+	if _, err := io.ReadFull(d.r, msg[eventStreamPreludeLen:]); err != nil {
+		return nil, fmt.Errorf("event-stream: truncated frame: %w", err)
+	}
+
+	crcAt := totalLen - eventStreamCRCLen
+	if got, want := crc32.ChecksumIEEE(msg[:crcAt]), binary.BigEndian.Uint32(msg[crcAt:]); got != want {
+		return nil, fmt.Errorf("event-stream: message checksum mismatch")
+	}
+
+	headersEnd := eventStreamPreludeLen + headersLen
+	headers, err := decodeEventStreamHeaders(msg[eventStreamPreludeLen:headersEnd])
+	if err != nil {
+		return nil, err
+	}
+
+	return &eventStreamMessage{
+		Headers: headers,
+		Payload: msg[headersEnd:crcAt],
+	}, nil
+}
+
+// eventStreamValueSizes maps fixed-size header value types to their length.
+// Types 6 (bytes) and 7 (string) are length-prefixed.
+var eventStreamValueSizes = map[byte]int{
+	0: 0,  // bool true
+	1: 0,  // bool false
+	2: 1,  // byte
+	3: 2,  // short
+	4: 4,  // int
+	5: 8,  // long
+	8: 8,  // timestamp
+	9: 16, // uuid
+}
+
+func decodeEventStreamHeaders(b []byte) (map[string]string, error) {
+	headers := make(map[string]string)
+	for len(b) > 0 {
+		nameLen := int(b[0])
+		if len(b) < 1+nameLen+1 {
+			return nil, fmt.Errorf("event-stream: truncated header name")
+		}
+		name := string(b[1 : 1+nameLen])
+		valueType := b[1+nameLen]
+		b = b[2+nameLen:]
+
+		switch valueType {
+		case 6, 7:
+			if len(b) < 2 {
+				return nil, fmt.Errorf("event-stream: truncated header %q", name)
+			}
+			n := int(binary.BigEndian.Uint16(b[:2]))
+			if len(b) < 2+n {
+				return nil, fmt.Errorf("event-stream: truncated header %q", name)
+			}
+			if valueType == 7 {
+				headers[name] = string(b[2 : 2+n])
+			}
+			b = b[2+n:]
+		default:
+			size, ok := eventStreamValueSizes[valueType]
+			if !ok {
+				return nil, fmt.Errorf("event-stream: header %q has unknown value type %d", name, valueType)
+			}
+			if len(b) < size {
+				return nil, fmt.Errorf("event-stream: truncated header %q", name)
+			}
+			b = b[size:]
+		}
+	}
+	return headers, nil
+}
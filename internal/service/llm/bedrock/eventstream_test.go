@@ -0,0 +1,89 @@
+package bedrock
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"testing"
+)
+
+// encodeEventStreamFrame builds one event-stream frame with string headers.
+func encodeEventStreamFrame(headers map[string]string, payload []byte) []byte {
+	var hb bytes.Buffer
+	for name, value := range headers {
+		hb.WriteByte(byte(len(name)))
+		hb.WriteString(name)
+		hb.WriteByte(7)
+		_ = binary.Write(&hb, binary.BigEndian, uint16(len(value)))
+		hb.WriteString(value)
+	}
+	return encodeEventStreamRaw(hb.Bytes(), payload)
+}
+
+func encodeEventStreamRaw(headers, payload []byte) []byte {
+	total := eventStreamPreludeLen + len(headers) + len(payload) + eventStreamCRCLen
+	var b bytes.Buffer
+	_ = binary.Write(&b, binary.BigEndian, uint32(total))
+	_ = binary.Write(&b, binary.BigEndian, uint32(len(headers)))
+	_ = binary.Write(&b, binary.BigEndian, crc32.ChecksumIEEE(b.Bytes()))
+	b.Write(headers)
+	b.Write(payload)
+	_ = binary.Write(&b, binary.BigEndian, crc32.ChecksumIEEE(b.Bytes()))
+	return b.Bytes()
+}
+
+func TestEventStreamDecoder(t *testing.T) {
+	// A non-string header (type 4, int32) must be skipped, not break
+	// decoding of the headers after it.
+	var hb bytes.Buffer
+	hb.WriteByte(4)
+	hb.WriteString(":len")
+	hb.WriteByte(4)
+	hb.Write([]byte{0, 0, 0, 7})
+	hb.WriteByte(11)
+	hb.WriteString(":event-type")
+	hb.WriteByte(7)
+	hb.Write([]byte{0, 12})
+	hb.WriteString("messageStart")
+
+	stream := append(encodeEventStreamRaw(hb.Bytes(), []byte(`{"role":"assistant"}`)),
+		encodeEventStreamFrame(map[string]string{":event-type": "messageStop"}, []byte(`{"stopReason":"end_turn"}`))...)
+
+	dec := newEventStreamDecoder(bytes.NewReader(stream))
+	first, err := dec.Next()
+	if err != nil {
+		t.Fatalf("first frame: %v", err)
+	}
+	if first.Headers[":event-type"] != "messageStart" || string(first.Payload) != `{"role":"assistant"}` {
+		t.Fatalf("first frame = %+v", first)
+	}
+	second, err := dec.Next()
+	if err != nil || second.Headers[":event-type"] != "messageStop" {
+		t.Fatalf("second frame = %+v, %v", second, err)
+	}
+	if _, err := dec.Next(); !errors.Is(err, io.EOF) {
+		t.Fatalf("after last frame err = %v, want io.EOF", err)
+	}
+}
+
+func TestEventStreamDecoderRejectsCorruptFrames(t *testing.T) {
+	frame := encodeEventStreamFrame(map[string]string{":event-type": "x"}, []byte(`{}`))
+
+	corrupt := append([]byte(nil), frame...)
+	corrupt[len(corrupt)-6] ^= 0xff // payload byte
+	if _, err := newEventStreamDecoder(bytes.NewReader(corrupt)).Next(); err == nil {
+		t.Error("payload corruption not detected")
+	}
+
+	corrupt = append([]byte(nil), frame...)
+	corrupt[3] ^= 0x01 // total length
+	if _, err := newEventStreamDecoder(bytes.NewReader(corrupt)).Next(); err == nil {
+		t.Error("prelude corruption not detected")
+	}
+
+	if _, err := newEventStreamDecoder(bytes.NewReader(frame[:len(frame)-2])).Next(); err == nil {
+		t.Error("truncated frame not detected")
+	}
+}
@@ -1,6 +1,6 @@
 // Package cohere implements an LLM provider that talks to Cohere's native
-// chat (`/v2/chat`), rerank (`/v2/rerank`), and embeddings (`/v2/embed`)
-// endpoints.
+// chat (`/v2/chat`, including SSE streaming), rerank (`/v2/rerank`), and
+// embeddings (`/v2/embed`) endpoints.
 //
 // Cohere's wire format is close to OpenAI but not identical, and the
 // rerank endpoint is something nobody else offers natively — first-class
@@ -8,6 +8,7 @@
 package cohere
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"crypto/tls"
@@ -30,7 +31,8 @@ const (
 	defaultModel   = "command-r-plus-08-2024"
 )
 
-// Provider implements service.LLMProvider plus EmbeddingProvider and RerankProvider.
+// Provider implements service.LLMProvider, LLMStreamProvider, EmbeddingProvider
+// and RerankProvider.
 type Provider struct {
 	apiKey     string
 	model      string
@@ -108,6 +110,7 @@ func (p *Provider) doJSON(ctx context.Context, method, path string, body any, ou
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Accept", "application/json")
 
+	common.InjectTraceContext(ctx, req)
 	resp, err := p.httpClient.Do(req)
 	if err != nil {
 		return nil, 0, fmt.Errorf("cohere http: %w", err)
@@ -151,6 +154,7 @@ type chatRequest struct {
 	Seed           *int          `json:"seed,omitempty"`
 	ToolChoice     string        `json:"tool_choice,omitempty"`
 	ResponseFormat any           `json:"response_format,omitempty"`
+	Stream         bool          `json:"stream,omitempty"`
 }
 
 type chatMessage struct {
@@ -185,17 +189,29 @@ type chatResponse struct {
 		Content   []contentBlock `json:"content"`
 		ToolCalls []chatToolCall `json:"tool_calls"`
 	} `json:"message"`
-	FinishReason string `json:"finish_reason"`
-	Usage        struct {
-		BilledUnits struct {
-			InputTokens  int `json:"input_tokens"`
-			OutputTokens int `json:"output_tokens"`
-		} `json:"billed_units"`
-		Tokens struct {
-			InputTokens  int `json:"input_tokens"`
-			OutputTokens int `json:"output_tokens"`
-		} `json:"tokens"`
-	} `json:"usage"`
+	FinishReason string    `json:"finish_reason"`
+	Usage        chatUsage `json:"usage"`
+}
+
+type chatUsage struct {
+	BilledUnits struct {
+		InputTokens  int `json:"input_tokens"`
+		OutputTokens int `json:"output_tokens"`
+	} `json:"billed_units"`
+	Tokens struct {
+		InputTokens  int `json:"input_tokens"`
+		OutputTokens int `json:"output_tokens"`
+	} `json:"tokens"`
+}
+
+// serviceUsage prefers the raw token counts and falls back to billed units.
+func (u chatUsage) serviceUsage() service.Usage {
+	out := service.Usage{
+		PromptTokens:     pickInt(u.Tokens.InputTokens, u.BilledUnits.InputTokens),
+		CompletionTokens: pickInt(u.Tokens.OutputTokens, u.BilledUnits.OutputTokens),
+	}
+	out.TotalTokens = out.PromptTokens + out.CompletionTokens
+	return out
 }
 
 type contentBlock struct {
@@ -214,35 +230,8 @@ func (p *Provider) Chat(ctx context.Context, model string, messages []service.Me
 	}
 	defer release()
 
-	body := chatRequest{
-		Model:    model,
-		Messages: translateMessagesToCohere(messages),
-	}
-	if opts != nil {
-		body.Temperature = opts.Temperature
-		body.P = opts.TopP
-		if opts.MaxCompletionTokens != nil {
-			body.MaxTokens = opts.MaxCompletionTokens
-		} else if opts.MaxTokens != nil {
-			body.MaxTokens = opts.MaxTokens
-		}
-		if len(opts.Stop) > 0 {
-			body.StopSeq = opts.Stop
-		}
-		body.Seed = opts.Seed
-		body.ToolChoice = translateCohereToolChoice(opts.ToolChoice)
-		body.ResponseFormat = translateCohereResponseFormat(opts.ResponseFormat)
-	}
-	for _, t := range tools {
-		ct := chatTool{Type: "function"}
-		ct.Function.Name = t.Name
-		ct.Function.Description = t.Description
-		ct.Function.Parameters = service.SanitizeSchema(t.InputSchema)
-		body.Tools = append(body.Tools, ct)
-	}
-
 	// Marshal once, merging extra_body for the litellm escape hatch.
-	jsonData, err := marshalCohereWithExtra(body, opts)
+	jsonData, err := marshalCohereWithExtra(buildChatRequest(model, messages, tools, opts), opts)
 	if err != nil {
 		return nil, err
 	}
@@ -254,6 +243,7 @@ func (p *Provider) Chat(ctx context.Context, model string, messages []service.Me
 	req.Header.Set("Authorization", "Bearer "+p.apiKey)
 	req.Header.Set("Content-Type", "application/json")
 
+	common.InjectTraceContext(ctx, req)
 	resp, err := p.httpClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("cohere http: %w", err)
@@ -282,12 +272,8 @@ func (p *Provider) Chat(ctx context.Context, model string, messages []service.Me
 		Header:       resp.Header,
 		Finished:     parsed.FinishReason != "TOOL_CALL",
 		FinishReason: parsed.FinishReason,
-		Usage: service.Usage{
-			PromptTokens:     pickInt(parsed.Usage.Tokens.InputTokens, parsed.Usage.BilledUnits.InputTokens),
-			CompletionTokens: pickInt(parsed.Usage.Tokens.OutputTokens, parsed.Usage.BilledUnits.OutputTokens),
-		},
+		Usage:        parsed.Usage.serviceUsage(),
 	}
-	out.Usage.TotalTokens = out.Usage.PromptTokens + out.Usage.CompletionTokens
 
 	for _, block := range parsed.Message.Content {
 		if block.Type == "text" {
@@ -306,6 +292,213 @@ func (p *Provider) Chat(ctx context.Context, model string, messages []service.Me
 	return out, nil
 }
 
+// chatStreamEvent is one Cohere v2 streaming event. Every event shares the
+// same envelope; which delta fields are populated depends on Type.
+type chatStreamEvent struct {
+	Type  string `json:"type"`
+	Index int    `json:"index"`
+	Delta struct {
+		Message struct {
+			Content struct {
+				Type     string `json:"type"`
+				Text     string `json:"text"`
+				Thinking string `json:"thinking"`
+			} `json:"content"`
+			ToolPlan  string       `json:"tool_plan"`
+			ToolCalls chatToolCall `json:"tool_calls"`
+		} `json:"message"`
+		FinishReason string     `json:"finish_reason"`
+		Usage        *chatUsage `json:"usage"`
+		Error        string     `json:"error"`
+	} `json:"delta"`
+}
+
+// ChatStream implements service.LLMStreamProvider against Cohere v2's
+// streaming chat. Text and thinking deltas are forwarded as they arrive;
+// tool calls are accumulated per index and emitted complete on
+// tool-call-end. The raw finish reason (COMPLETE, TOOL_CALL, MAX_TOKENS,
+// ...) and usage are sent on the final chunk.
+func (p *Provider) ChatStream(ctx context.Context, model string, messages []service.Message, tools []service.Tool, opts *service.ChatOptions) (<-chan service.StreamChunk, http.Header, error) {
+	if model == "" {
+		model = p.model
+	}
+	release, err := p.limiter.Acquire(ctx, common.EstimateInputTokens("", messages, tools))
+	if err != nil {
+		return nil, nil, err
+	}
+	releaseOnce := func() {
+		if release != nil {
+			release()
+			release = nil
+		}
+	}
+
+	body := buildChatRequest(model, messages, tools, opts)
+	body.Stream = true
+	jsonData, err := marshalCohereWithExtra(body, opts)
+	if err != nil {
+		releaseOnce()
+		return nil, nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/v2/chat", bytes.NewReader(jsonData))
+	if err != nil {
+		releaseOnce()
+		return nil, nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+p.apiKey)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "text/event-stream")
+
+	common.InjectTraceContext(ctx, req)
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		releaseOnce()
+		return nil, nil, fmt.Errorf("cohere http: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		defer releaseOnce()
+		respBody, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode == http.StatusTooManyRequests {
+			return nil, nil, &service.RateLimitError{
+				StatusCode: resp.StatusCode,
+				RetryAfter: common.ParseRetryAfter(resp.Header),
+				Provider:   "cohere",
+				Message:    string(respBody),
+				Underlying: fmt.Errorf("cohere 429: %s", string(respBody)),
+			}
+		}
+		return nil, nil, fmt.Errorf("cohere API error (status %d): %s", resp.StatusCode, string(respBody))
+	}
+
+	ch := make(chan service.StreamChunk, 64)
+
+	go func() {
+		defer close(ch)
+		defer resp.Body.Close()
+		defer releaseOnce()
+
+		// Tool call arguments arrive as JSON fragments keyed by the
+		// event index; the id and name only come with tool-call-start.
+		toolCalls := map[int]*chatToolCall{}
+
+		scanner := bufio.NewScanner(resp.Body)
+		scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+		for scanner.Scan() {
+			line := scanner.Text()
+			if !strings.HasPrefix(line, "data:") {
+				continue
+			}
+			data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+			if data == "" || data == "[DONE]" {
+				continue
+			}
+
+			var event chatStreamEvent
+			if err := json.Unmarshal([]byte(data), &event); err != nil {
+				ch <- service.StreamChunk{Error: fmt.Errorf("cohere: parse stream event: %w", err)}
+				return
+			}
+
+			switch event.Type {
+			case "content-delta":
+				content := event.Delta.Message.Content
+				if content.Thinking != "" {
+					ch <- service.StreamChunk{ReasoningContent: content.Thinking}
+				}
+				if content.Text != "" {
+					ch <- service.StreamChunk{Content: content.Text}
+				}
+
+			case "tool-plan-delta":
+				if plan := event.Delta.Message.ToolPlan; plan != "" {
+					ch <- service.StreamChunk{ReasoningContent: plan}
+				}
+
+			case "tool-call-start":
+				tc := event.Delta.Message.ToolCalls
+				toolCalls[event.Index] = &tc
+
+			case "tool-call-delta":
+				if tc, ok := toolCalls[event.Index]; ok {
+					tc.Function.Arguments += event.Delta.Message.ToolCalls.Function.Arguments
+				}
+
+			case "tool-call-end":
+				tc, ok := toolCalls[event.Index]
+				if !ok {
+					continue
+				}
+				delete(toolCalls, event.Index)
+				args := map[string]any{}
+				if tc.Function.Arguments != "" {
+					_ = json.Unmarshal([]byte(tc.Function.Arguments), &args)
+				}
+				ch <- service.StreamChunk{
+					ToolCalls: []service.ToolCall{{
+						ID:        tc.ID,
+						Name:      tc.Function.Name,
+						Arguments: args,
+					}},
+				}
+
+			case "message-end":
+				if event.Delta.Error != "" {
+					ch <- service.StreamChunk{Error: fmt.Errorf("cohere stream error: %s", event.Delta.Error)}
+					return
+				}
+				final := service.StreamChunk{FinishReason: event.Delta.FinishReason}
+				if event.Delta.Usage != nil {
+					usage := event.Delta.Usage.serviceUsage()
+					final.Usage = &usage
+				}
+				ch <- final
+				return
+			}
+		}
+
+		if err := scanner.Err(); err != nil {
+			ch <- service.StreamChunk{Error: fmt.Errorf("cohere: stream read: %w", err)}
+		}
+	}()
+
+	return ch, resp.Header, nil
+}
+
+// buildChatRequest assembles the typed /v2/chat body shared by Chat and
+// ChatStream.
+func buildChatRequest(model string, messages []service.Message, tools []service.Tool, opts *service.ChatOptions) chatRequest {
+	body := chatRequest{
+		Model:    model,
+		Messages: translateMessagesToCohere(messages),
+	}
+	if opts != nil {
+		body.Temperature = opts.Temperature
+		body.P = opts.TopP
+		if opts.MaxCompletionTokens != nil {
+			body.MaxTokens = opts.MaxCompletionTokens
+		} else if opts.MaxTokens != nil {
+			body.MaxTokens = opts.MaxTokens
+		}
+		if len(opts.Stop) > 0 {
+			body.StopSeq = opts.Stop
+		}
+		body.Seed = opts.Seed
+		body.ToolChoice = translateCohereToolChoice(opts.ToolChoice)
+		body.ResponseFormat = translateCohereResponseFormat(opts.ResponseFormat)
+	}
+	for _, t := range tools {
+		ct := chatTool{Type: "function"}
+		ct.Function.Name = t.Name
+		ct.Function.Description = t.Description
+		ct.Function.Parameters = service.SanitizeSchema(t.InputSchema)
+		body.Tools = append(body.Tools, ct)
+	}
+	return body
+}
+
 func translateMessagesToCohere(messages []service.Message) []chatMessage {
 	out := make([]chatMessage, 0, len(messages))
 	for _, m := range messages {
@@ -563,6 +756,7 @@ func (p *Provider) Proxy(w http.ResponseWriter, r *http.Request, path string) er
 	}
 	req.Header.Set("Authorization", "Bearer "+p.apiKey)
 
+	common.InjectTraceContext(req.Context(), req)
 	resp, err := p.httpClient.Do(req)
 	if err != nil {
 		return err
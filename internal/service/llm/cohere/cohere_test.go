@@ -3,10 +3,12 @@ package cohere
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/rakunlabs/at/internal/service"
 )
@@ -109,3 +111,110 @@ func TestTranslateCohereResponseFormat(t *testing.T) {
 		})
 	}
 }
+
+func TestChatStream(t *testing.T) {
+	t.Parallel()
+
+	events := []string{
+		`{"type":"message-start","id":"m1","delta":{"message":{"role":"assistant"}}}`,
+		`{"type":"content-start","index":0,"delta":{"message":{"content":{"type":"text","text":""}}}}`,
+		`{"type":"content-delta","index":0,"delta":{"message":{"content":{"text":"Hel"}}}}`,
+		`{"type":"content-delta","index":0,"delta":{"message":{"content":{"text":"lo"}}}}`,
+		`{"type":"content-end","index":0}`,
+		`{"type":"tool-plan-delta","delta":{"message":{"tool_plan":"look it up"}}}`,
+		`{"type":"tool-call-start","index":1,"delta":{"message":{"tool_calls":{"id":"call_1","type":"function","function":{"name":"lookup","arguments":""}}}}}`,
+		`{"type":"tool-call-delta","index":1,"delta":{"message":{"tool_calls":{"function":{"arguments":"{\"q\":"}}}}}`,
+		`{"type":"tool-call-delta","index":1,"delta":{"message":{"tool_calls":{"function":{"arguments":"\"x\"}"}}}}}`,
+		`{"type":"tool-call-end","index":1}`,
+		`{"type":"message-end","delta":{"finish_reason":"TOOL_CALL","usage":{"billed_units":{"input_tokens":3,"output_tokens":4},"tokens":{"input_tokens":10,"output_tokens":6}}}}`,
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body chatRequest
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+		if !body.Stream {
+			t.Errorf("stream = false")
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, e := range events {
+			var typ struct {
+				Type string `json:"type"`
+			}
+			_ = json.Unmarshal([]byte(e), &typ)
+			_, _ = w.Write([]byte("event: " + typ.Type + "\ndata: " + e + "\n\n"))
+		}
+	}))
+	defer server.Close()
+
+	provider, err := New("test-key", "command-a", server.URL, "", false)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	ch, _, err := provider.ChatStream(context.Background(), "", []service.Message{{Role: "user", Content: "hi"}}, nil, nil)
+	if err != nil {
+		t.Fatalf("ChatStream: %v", err)
+	}
+
+	var (
+		content, reasoning, finish string
+		toolCalls                  []service.ToolCall
+		usage                      *service.Usage
+	)
+	for chunk := range ch {
+		if chunk.Error != nil {
+			t.Fatalf("chunk error: %v", chunk.Error)
+		}
+		content += chunk.Content
+		reasoning += chunk.ReasoningContent
+		toolCalls = append(toolCalls, chunk.ToolCalls...)
+		if chunk.FinishReason != "" {
+			finish = chunk.FinishReason
+		}
+		if chunk.Usage != nil {
+			usage = chunk.Usage
+		}
+	}
+
+	if content != "Hello" {
+		t.Errorf("content = %q", content)
+	}
+	if reasoning != "look it up" {
+		t.Errorf("reasoning = %q", reasoning)
+	}
+	want := []service.ToolCall{{ID: "call_1", Name: "lookup", Arguments: map[string]any{"q": "x"}}}
+	if !reflect.DeepEqual(toolCalls, want) {
+		t.Errorf("tool calls = %#v", toolCalls)
+	}
+	if finish != "TOOL_CALL" {
+		t.Errorf("finish reason = %q", finish)
+	}
+	if usage == nil || usage.PromptTokens != 10 || usage.CompletionTokens != 6 || usage.TotalTokens != 16 {
+		t.Errorf("usage = %+v", usage)
+	}
+}
+
+func TestChatStreamRateLimited(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "7")
+		w.WriteHeader(http.StatusTooManyRequests)
+		_, _ = w.Write([]byte(`{"message":"slow down"}`))
+	}))
+	defer server.Close()
+
+	provider, err := New("test-key", "command-a", server.URL, "", false)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	_, _, err = provider.ChatStream(context.Background(), "", []service.Message{{Role: "user", Content: "hi"}}, nil, nil)
+	var rle *service.RateLimitError
+	if !errors.As(err, &rle) {
+		t.Fatalf("err = %v, want *service.RateLimitError", err)
+	}
+	if rle.StatusCode != http.StatusTooManyRequests || rle.RetryAfter != 7*time.Second {
+		t.Errorf("rate limit error = %+v", rle)
+	}
+}
@@ -0,0 +1,194 @@
+package common
+
+import (
+	"encoding/json"
+	"regexp"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/rakunlabs/at/internal/service"
+)
+
+// tokenPieceRe mirrors the pre-tokenization split of tiktoken's cl100k
+// encoding (contractions, letter runs with an optional leading space,
+// digit groups of up to three, punctuation runs, whitespace). Go's regexp
+// has no lookahead, so the trailing-whitespace rule is folded into \s+.
+var tokenPieceRe = regexp.MustCompile(`(?i:'s|'t|'re|'ve|'m|'ll|'d)| ?\p{L}+| ?\p{N}{1,3}| ?[^\s\p{L}\p{N}]+|\s+`)
+
+const (
+	// perMessageTokens is the chat-format overhead OpenAI documents for
+	// each message (role and separators); replyPrimingTokens covers the
+	// assistant header every prompt ends with.
+	perMessageTokens   = 3
+	replyPrimingTokens = 3
+
+	// imageTokens is charged per image block: a high-detail 1024×1024
+	// image under OpenAI's tiling rule. Real costs vary by provider and
+	// resolution.
+	imageTokens = 765
+)
+
+// CountTokensLocal approximates a BPE tokenizer without loading any
+// vocabulary: text is split the way tiktoken pre-tokenizes it and each
+// piece is charged the tokens BPE typically produces for it. It is more
+// accurate than EstimateInputTokens for prose and code and is what the
+// gateway reports when a provider has no native counting API.
+func CountTokensLocal(messages []service.Message, tools []service.Tool) int {
+	n := 0
+	for _, m := range messages {
+		n += perMessageTokens + CountTextTokens(m.Role)
+		switch c := m.Content.(type) {
+		case nil:
+		case string:
+			n += CountTextTokens(c)
+		case []byte:
+			n += CountTextTokens(string(c))
+		case []service.ContentBlock:
+			for _, b := range c {
+				n += countBlockTokens(b)
+			}
+		case map[string]any:
+			n += countOpenAIMessageTokens(c)
+		default:
+			if buf, err := json.Marshal(c); err == nil {
+				n += CountTextTokens(string(buf))
+			}
+		}
+	}
+	if len(messages) > 0 {
+		n += replyPrimingTokens
+	}
+
+	for _, t := range tools {
+		n += CountTextTokens(t.Name) + CountTextTokens(t.Description)
+		if t.InputSchema != nil {
+			if buf, err := json.Marshal(t.InputSchema); err == nil {
+				n += CountTextTokens(string(buf))
+			}
+		}
+	}
+
+	return n
+}
+
+func countBlockTokens(b service.ContentBlock) int {
+	n := CountTextTokens(b.Text) + CountTextTokens(b.Content) + CountTextTokens(b.Name)
+	if len(b.Input) > 0 {
+		if buf, err := json.Marshal(b.Input); err == nil {
+			n += CountTextTokens(string(buf))
+		}
+	}
+	if b.Source != nil {
+		switch b.Type {
+		case "image":
+			n += imageTokens
+		default:
+			// Documents and audio: charge the decoded payload size at
+			// the prose ratio; URL sources are unknown until fetched.
+			n += len(b.Source.Data) * 3 / 4 / charsPerToken
+		}
+	}
+	return n
+}
+
+// countOpenAIMessageTokens counts a message kept in the OpenAI wire shape
+// (the gateway's pass-through form): text parts as text, media parts at
+// the same rates as content blocks, everything else as its JSON.
+func countOpenAIMessageTokens(m map[string]any) int {
+	n := 0
+	for k, v := range m {
+		switch k {
+		case "role":
+			continue
+		case "content":
+			switch c := v.(type) {
+			case string:
+				n += CountTextTokens(c)
+				continue
+			case []any:
+				for _, p := range c {
+					n += countOpenAIPartTokens(p)
+				}
+				continue
+			}
+		}
+		if buf, err := json.Marshal(v); err == nil {
+			n += CountTextTokens(string(buf))
+		}
+	}
+	return n
+}
+
+func countOpenAIPartTokens(p any) int {
+	part, _ := p.(map[string]any)
+	switch part["type"] {
+	case "text", "input_text":
+		text, _ := part["text"].(string)
+		return CountTextTokens(text)
+	case "image_url", "input_image":
+		return imageTokens
+	case "input_audio":
+		audio, _ := part["input_audio"].(map[string]any)
+		data, _ := audio["data"].(string)
+		return len(data) * 3 / 4 / charsPerToken
+	}
+	if buf, err := json.Marshal(p); err == nil {
+		return CountTextTokens(string(buf))
+	}
+	return 0
+}
+
+// CountTextTokens returns the approximate BPE token count of s.
+func CountTextTokens(s string) int {
+	if s == "" {
+		return 0
+	}
+	n := 0
+	for _, piece := range tokenPieceRe.FindAllString(s, -1) {
+		n += pieceTokens(piece)
+	}
+	return n
+}
+
+// pieceTokens charges one pre-tokenized piece. Common English words
+// (including their leading space) are a single token and longer ones
+// split roughly every six letters; CJK characters are about one token
+// each and other scripts about two letters per token; punctuation merges
+// in pairs.
+func pieceTokens(piece string) int {
+	r, _ := utf8.DecodeRuneInString(piece)
+	if r == ' ' && len(piece) > 1 {
+		r, _ = utf8.DecodeRuneInString(piece[1:])
+	}
+
+	switch {
+	case unicode.IsSpace(r):
+		return 1
+	case unicode.IsLetter(r):
+		letters, cjk := 0, 0
+		latin := true
+		for _, c := range piece {
+			if c == ' ' {
+				continue
+			}
+			letters++
+			switch {
+			case unicode.In(c, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul):
+				cjk++
+			case !unicode.Is(unicode.Latin, c):
+				latin = false
+			}
+		}
+		switch {
+		case cjk > 0:
+			return cjk + (letters-cjk+1)/2
+		case !latin:
+			return (letters + 1) / 2
+		}
+		return (letters + 5) / 6
+	case unicode.IsDigit(r):
+		return 1
+	default:
+		return (utf8.RuneCountInString(piece) + 1) / 2
+	}
+}
@@ -0,0 +1,63 @@
+package common
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/rakunlabs/at/internal/service"
+)
+
+func TestCountTextTokens(t *testing.T) {
+	tests := []struct {
+		text     string
+		min, max int
+	}{
+		{"", 0, 0},
+		{"Hello world", 2, 2},
+		{"The quick brown fox jumps over the lazy dog.", 9, 11},
+		{"internationalization", 2, 5},
+		{"1234567", 3, 3},
+		{"你好世界", 4, 4},
+		{`{"key": [1, 2, 3]}`, 8, 14},
+	}
+	for _, tt := range tests {
+		if got := CountTextTokens(tt.text); got < tt.min || got > tt.max {
+			t.Errorf("CountTextTokens(%q) = %d, want %d..%d", tt.text, got, tt.min, tt.max)
+		}
+	}
+}
+
+func TestCountTokensLocal(t *testing.T) {
+	messages := []service.Message{
+		{Role: "system", Content: "be brief"},
+		{Role: "user", Content: []service.ContentBlock{
+			{Type: "text", Text: strings.Repeat("word ", 100)},
+			{Type: "image", Source: &service.MediaSource{Type: "base64", MediaType: "image/png", Data: "iVBORw0KGgo="}},
+		}},
+	}
+	got := CountTokensLocal(messages, nil)
+	want := 100 + imageTokens
+	if got < want || got > want+30 {
+		t.Fatalf("CountTokensLocal = %d, want about %d", got, want)
+	}
+
+	withTools := CountTokensLocal(messages, []service.Tool{{
+		Name:        "lookup",
+		Description: "Look up a record by id",
+		InputSchema: map[string]any{"type": "object", "properties": map[string]any{"id": map[string]any{"type": "string"}}},
+	}})
+	if withTools <= got {
+		t.Fatalf("tools not counted: %d <= %d", withTools, got)
+	}
+}
+
+func TestCountTokensLocalOpenAIParts(t *testing.T) {
+	image := map[string]any{"type": "image_url", "image_url": map[string]any{"url": "data:image/png;base64," + strings.Repeat("A", 100_000)}}
+	got := CountTokensLocal([]service.Message{{Role: "user", Content: map[string]any{
+		"role":    "user",
+		"content": []any{map[string]any{"type": "text", "text": "what is this?"}, image},
+	}}}, nil)
+	if got < imageTokens || got > imageTokens+20 {
+		t.Fatalf("CountTokensLocal = %d, want about %d", got, imageTokens)
+	}
+}
@@ -0,0 +1,412 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/oklog/ulid/v2"
+
+	"github.com/rakunlabs/at/internal/service"
+)
+
+// ─── Prompt-based tool-calling emulation ───
+//
+// Models without native function calling are given the tool schemas in
+// the system prompt and asked to answer with tool-call blocks:
+//
+//	```tool_call
+//	{"name": "lookup", "arguments": {"q": "go"}}
+//	```
+//
+// or, with the "xml" format:
+//
+//	<tool_call>
+//	<name>lookup</name>
+//	<arguments>{"q": "go"}</arguments>
+//	</tool_call>
+//
+// The blocks are parsed back into service.ToolCall values. Earlier tool
+// calls and tool results in the history are rewritten as text so the
+// upstream never sees `tools`, `tool_calls` or role "tool". Both block
+// formats (and a JSON object inside <tool_call>) are accepted whichever
+// format was requested, since models mix them up.
+
+const (
+	ToolEmulationFormatJSON = "json"
+	ToolEmulationFormatXML  = "xml"
+)
+
+// ToolEmulation configures prompt-based tool calling for a provider. A nil
+// *ToolEmulation disables emulation.
+type ToolEmulation struct {
+	// Models limits emulation to these models; empty means all models.
+	Models []string
+	// Format is the block format the prompt asks for: "json" (default)
+	// or "xml".
+	Format string
+}
+
+// Enabled reports whether tool calls for model are emulated.
+func (e *ToolEmulation) Enabled(model string) bool {
+	if e == nil {
+		return false
+	}
+	return len(e.Models) == 0 || slices.Contains(e.Models, model)
+}
+
+func (e *ToolEmulation) format() string {
+	if e != nil && e.Format == ToolEmulationFormatXML {
+		return ToolEmulationFormatXML
+	}
+	return ToolEmulationFormatJSON
+}
+
+// ToolEmulationPrompt renders the system-prompt section describing tools
+// and how to call them.
+func (e *ToolEmulation) ToolEmulationPrompt(tools []service.Tool) string {
+	var b strings.Builder
+	b.WriteString("You can call the following tools. Each tool's parameters are given as a JSON schema.\n\n")
+	for _, t := range tools {
+		schema, _ := json.Marshal(service.SanitizeSchema(t.InputSchema))
+		fmt.Fprintf(&b, "- %s: %s\n  parameters: %s\n", t.Name, strings.TrimSpace(t.Description), schema)
+	}
+	b.WriteString("\nTo call a tool, reply with one block per call in exactly this format:\n\n")
+	b.WriteString(e.formatToolCall("tool_name", `{"parameter": "value"}`))
+	b.WriteString("\n\nYou may call several tools at once. Write nothing after your tool-call blocks; " +
+		"the results will be sent back in <tool_result> blocks. " +
+		"When no tool is needed, answer normally without any tool-call block.")
+	return b.String()
+}
+
+func (e *ToolEmulation) formatToolCall(name, arguments string) string {
+	if e.format() == ToolEmulationFormatXML {
+		return "<tool_call>\n<name>" + name + "</name>\n<arguments>" + arguments + "</arguments>\n</tool_call>"
+	}
+	return "```tool_call\n{\"name\": " + jsonString(name) + ", \"arguments\": " + arguments + "}\n```"
+}
+
+func jsonString(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
+
+// EmulateToolMessages rewrites OpenAI wire-format messages for a model
+// without tool support: the tool prompt is appended to the system message,
+// assistant tool_calls become tool-call blocks in the assistant text, and
+// role "tool" results become user messages with <tool_result> blocks
+// (consecutive results are merged into one message).
+func (e *ToolEmulation) EmulateToolMessages(messages []any, tools []service.Tool) []any {
+	names := map[string]string{}
+	out := make([]any, 0, len(messages)+1)
+
+	for _, raw := range messages {
+		m, ok := raw.(map[string]any)
+		if !ok {
+			out = append(out, raw)
+			continue
+		}
+
+		role, _ := m["role"].(string)
+		switch {
+		case role == "assistant" && m["tool_calls"] != nil:
+			var b strings.Builder
+			b.WriteString(openAIContentText(m["content"]))
+			for _, tc := range openAIToolCallMaps(m["tool_calls"]) {
+				fn, _ := tc["function"].(map[string]any)
+				name, _ := fn["name"].(string)
+				args, _ := fn["arguments"].(string)
+				if strings.TrimSpace(args) == "" {
+					args = "{}"
+				}
+				if id, _ := tc["id"].(string); id != "" {
+					names[id] = name
+				}
+				if b.Len() > 0 {
+					b.WriteString("\n\n")
+				}
+				b.WriteString(e.formatToolCall(name, args))
+			}
+			out = append(out, map[string]any{"role": "assistant", "content": b.String()})
+		case role == "tool":
+			id, _ := m["tool_call_id"].(string)
+			result := fmt.Sprintf("<tool_result id=%q name=%q>\n%s\n</tool_result>",
+				id, names[id], openAIContentText(m["content"]))
+			if n := len(out); n > 0 {
+				if prev, ok := out[n-1].(map[string]any); ok && prev["role"] == "user" && prev[toolResultMarker] == true {
+					prev["content"] = prev["content"].(string) + "\n\n" + result
+					continue
+				}
+			}
+			out = append(out, map[string]any{"role": "user", "content": result, toolResultMarker: true})
+		default:
+			out = append(out, m)
+		}
+	}
+
+	for _, raw := range out {
+		if m, ok := raw.(map[string]any); ok {
+			delete(m, toolResultMarker)
+		}
+	}
+
+	if len(tools) == 0 {
+		return out
+	}
+
+	prompt := e.ToolEmulationPrompt(tools)
+	if len(out) > 0 {
+		if first, ok := out[0].(map[string]any); ok && (first["role"] == "system" || first["role"] == "developer") {
+			if text, ok := first["content"].(string); ok {
+				out[0] = map[string]any{"role": first["role"], "content": text + "\n\n" + prompt}
+				return out
+			}
+		}
+	}
+	return append([]any{map[string]any{"role": "system", "content": prompt}}, out...)
+}
+
+// toolResultMarker tags the user messages EmulateToolMessages builds from
+// tool results so consecutive results merge; it is removed before return.
+const toolResultMarker = "\x00tool_result"
+
+func openAIToolCallMaps(v any) []map[string]any {
+	var out []map[string]any
+	switch tcs := v.(type) {
+	case []any:
+		for _, tc := range tcs {
+			if m, ok := tc.(map[string]any); ok {
+				out = append(out, m)
+			}
+		}
+	case []map[string]any:
+		out = tcs
+	}
+	return out
+}
+
+// openAIContentText flattens an OpenAI content value (string or parts)
+// to its text.
+func openAIContentText(v any) string {
+	switch c := v.(type) {
+	case string:
+		return c
+	case []any:
+		var parts []string
+		for _, p := range c {
+			if m, ok := p.(map[string]any); ok {
+				if text, ok := m["text"].(string); ok {
+					parts = append(parts, text)
+				}
+			}
+		}
+		return strings.Join(parts, "\n")
+	case nil:
+		return ""
+	default:
+		b, _ := json.Marshal(c)
+		return string(b)
+	}
+}
+
+var (
+	toolCallFenceRe = regexp.MustCompile("(?s)```tool_call[ \t]*\n?(.*?)```")
+	toolCallTagRe   = regexp.MustCompile(`(?s)<tool_call>(.*?)</tool_call>`)
+	toolCallNameRe  = regexp.MustCompile(`(?s)<name>\s*(.*?)\s*</name>`)
+	toolCallArgsRe  = regexp.MustCompile(`(?s)<arguments>(.*?)</arguments>`)
+)
+
+// ParseEmulatedToolCalls extracts tool-call blocks from model text. It
+// returns the text with the blocks removed and the parsed calls. Blocks
+// that do not parse are left in the text.
+func ParseEmulatedToolCalls(text string) (string, []service.ToolCall) {
+	type match struct {
+		start, end int
+		call       service.ToolCall
+	}
+	var matches []match
+	for _, re := range []*regexp.Regexp{toolCallFenceRe, toolCallTagRe} {
+		for _, loc := range re.FindAllStringSubmatchIndex(text, -1) {
+			if call, ok := parseEmulatedToolCall(text[loc[2]:loc[3]]); ok {
+				matches = append(matches, match{loc[0], loc[1], call})
+			}
+		}
+	}
+	if len(matches) == 0 {
+		return text, nil
+	}
+	slices.SortFunc(matches, func(a, b match) int { return a.start - b.start })
+
+	var b strings.Builder
+	var calls []service.ToolCall
+	last := 0
+	for _, m := range matches {
+		if m.start < last {
+			continue
+		}
+		b.WriteString(text[last:m.start])
+		calls = append(calls, m.call)
+		last = m.end
+	}
+	b.WriteString(text[last:])
+	return strings.TrimSpace(b.String()), calls
+}
+
+func parseEmulatedToolCall(body string) (service.ToolCall, bool) {
+	body = strings.TrimSpace(body)
+
+	var name string
+	var args any
+	if strings.HasPrefix(body, "{") {
+		var obj struct {
+			Name       string `json:"name"`
+			Arguments  any    `json:"arguments"`
+			Parameters any    `json:"parameters"`
+		}
+		if err := json.Unmarshal([]byte(body), &obj); err != nil {
+			return service.ToolCall{}, false
+		}
+		name, args = obj.Name, obj.Arguments
+		if args == nil {
+			args = obj.Parameters
+		}
+	} else {
+		nm := toolCallNameRe.FindStringSubmatch(body)
+		if nm == nil {
+			return service.ToolCall{}, false
+		}
+		name = nm[1]
+		if am := toolCallArgsRe.FindStringSubmatch(body); am != nil {
+			args = strings.TrimSpace(am[1])
+		}
+	}
+	if name == "" {
+		return service.ToolCall{}, false
+	}
+
+	// Arguments may arrive as an object or as a JSON-encoded string.
+	arguments := map[string]any{}
+	switch a := args.(type) {
+	case map[string]any:
+		arguments = a
+	case string:
+		if a != "" {
+			if err := json.Unmarshal([]byte(a), &arguments); err != nil {
+				return service.ToolCall{}, false
+			}
+		}
+	}
+
+	return service.ToolCall{
+		ID:        "call_" + strings.ToLower(ulid.Make().String()),
+		Name:      name,
+		Arguments: arguments,
+	}, true
+}
+
+// emulatedToolCallOpeners start a tool-call block in streamed text.
+var emulatedToolCallOpeners = []string{"```tool_call", "<tool_call>"}
+
+// EmulateToolCallStream turns tool-call blocks in a streamed response into
+// ToolCalls. Text is forwarded as it arrives until a block starts; from
+// then on content is held back and parsed when the stream finishes. A
+// partial opener at the end of a delta is held until the next delta shows
+// whether it starts a block.
+func EmulateToolCallStream(in <-chan service.StreamChunk) <-chan service.StreamChunk {
+	out := make(chan service.StreamChunk, cap(in))
+
+	go func() {
+		defer close(out)
+
+		var pending strings.Builder
+		capturing := false
+
+		// flush parses whatever is held back and returns the chunk that
+		// finishes the response.
+		flush := func(chunk service.StreamChunk) service.StreamChunk {
+			text := pending.String()
+			pending.Reset()
+			capturing = false
+			clean, calls := ParseEmulatedToolCalls(text)
+			if len(calls) == 0 {
+				chunk.Content = text + chunk.Content
+				return chunk
+			}
+			chunk.Content = clean
+			chunk.ToolCalls = append(chunk.ToolCalls, calls...)
+			chunk.FinishReason = "tool_calls"
+			return chunk
+		}
+
+		for chunk := range in {
+			if chunk.Error != nil {
+				out <- chunk
+				continue
+			}
+
+			if chunk.Content != "" {
+				pending.WriteString(chunk.Content)
+				chunk.Content = ""
+				if !capturing {
+					text := pending.String()
+					if i := indexToolCallOpener(text); i >= 0 {
+						chunk.Content = text[:i]
+						pending.Reset()
+						pending.WriteString(text[i:])
+						capturing = true
+					} else {
+						hold := partialToolCallOpener(text)
+						chunk.Content = text[:len(text)-hold]
+						pending.Reset()
+						pending.WriteString(text[len(text)-hold:])
+					}
+				}
+			}
+
+			if chunk.FinishReason != "" {
+				chunk = flush(chunk)
+			}
+
+			if chunk.Content == "" && chunk.ReasoningContent == "" && len(chunk.ToolCalls) == 0 &&
+				len(chunk.InlineImages) == 0 && chunk.FinishReason == "" && chunk.Usage == nil {
+				continue
+			}
+			out <- chunk
+		}
+
+		// Upstream closed without a finish reason: still deliver what was
+		// held back.
+		if pending.Len() > 0 {
+			out <- flush(service.StreamChunk{})
+		}
+	}()
+
+	return out
+}
+
+func indexToolCallOpener(text string) int {
+	idx := -1
+	for _, o := range emulatedToolCallOpeners {
+		if i := strings.Index(text, o); i >= 0 && (idx < 0 || i < idx) {
+			idx = i
+		}
+	}
+	return idx
+}
+
+// partialToolCallOpener returns the length of the longest suffix of text
+// that is a proper prefix of a tool-call opener.
+func partialToolCallOpener(text string) int {
+	longest := 0
+	for _, o := range emulatedToolCallOpeners {
+		for n := min(len(o)-1, len(text)); n > longest; n-- {
+			if strings.HasSuffix(text, o[:n]) {
+				longest = n
+				break
+			}
+		}
+	}
+	return longest
+}
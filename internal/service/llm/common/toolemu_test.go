@@ -0,0 +1,159 @@
+package common
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/rakunlabs/at/internal/service"
+)
+
+func TestParseEmulatedToolCalls(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		clean string
+		calls []string
+	}{
+		{
+			name:  "fenced json",
+			text:  "Let me check.\n```tool_call\n{\"name\": \"lookup\", \"arguments\": {\"q\": \"go\"}}\n```",
+			clean: "Let me check.",
+			calls: []string{"lookup"},
+		},
+		{
+			name:  "xml tags",
+			text:  "<tool_call>\n<name>lookup</name>\n<arguments>{\"q\": \"go\"}</arguments>\n</tool_call>\n<tool_call>{\"name\":\"fetch\",\"arguments\":\"{\\\"url\\\":\\\"x\\\"}\"}</tool_call>",
+			calls: []string{"lookup", "fetch"},
+		},
+		{
+			name:  "plain answer",
+			text:  "The answer is 42.",
+			clean: "The answer is 42.",
+		},
+		{
+			name:  "broken block is kept",
+			text:  "<tool_call>{not json}</tool_call>",
+			clean: "<tool_call>{not json}</tool_call>",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clean, calls := ParseEmulatedToolCalls(tt.text)
+			if clean != tt.clean {
+				t.Errorf("clean = %q, want %q", clean, tt.clean)
+			}
+			if len(calls) != len(tt.calls) {
+				t.Fatalf("calls = %+v, want %v", calls, tt.calls)
+			}
+			for i, c := range calls {
+				if c.Name != tt.calls[i] {
+					t.Errorf("call %d name = %q, want %q", i, c.Name, tt.calls[i])
+				}
+				if c.ID == "" || c.Arguments == nil {
+					t.Errorf("call %d incomplete: %+v", i, c)
+				}
+			}
+			if len(calls) > 0 && calls[0].Name == "lookup" && calls[0].Arguments["q"] != "go" {
+				t.Errorf("arguments = %v", calls[0].Arguments)
+			}
+		})
+	}
+}
+
+func TestEmulateToolMessages(t *testing.T) {
+	e := &ToolEmulation{Format: ToolEmulationFormatXML}
+	tools := []service.Tool{{Name: "lookup", Description: "Search the docs", InputSchema: map[string]any{"type": "object"}}}
+	messages := []any{
+		map[string]any{"role": "system", "content": "be brief"},
+		map[string]any{"role": "user", "content": "find go"},
+		map[string]any{"role": "assistant", "tool_calls": []any{
+			map[string]any{"id": "call_1", "type": "function", "function": map[string]any{"name": "lookup", "arguments": `{"q":"go"}`}},
+			map[string]any{"id": "call_2", "type": "function", "function": map[string]any{"name": "lookup", "arguments": `{"q":"golang"}`}},
+		}},
+		map[string]any{"role": "tool", "tool_call_id": "call_1", "content": "found 1"},
+		map[string]any{"role": "tool", "tool_call_id": "call_2", "content": "found 2"},
+	}
+
+	out := e.EmulateToolMessages(messages, tools)
+	if len(out) != 4 {
+		t.Fatalf("len = %d, want 4: %v", len(out), out)
+	}
+
+	system := out[0].(map[string]any)["content"].(string)
+	if !strings.HasPrefix(system, "be brief\n\n") || !strings.Contains(system, "- lookup: Search the docs") || !strings.Contains(system, "<tool_call>") {
+		t.Errorf("system = %q", system)
+	}
+
+	assistant := out[2].(map[string]any)
+	if assistant["tool_calls"] != nil || strings.Count(assistant["content"].(string), "<name>lookup</name>") != 2 {
+		t.Errorf("assistant = %v", assistant)
+	}
+
+	results := out[3].(map[string]any)
+	content := results["content"].(string)
+	if results["role"] != "user" || !strings.Contains(content, `<tool_result id="call_1" name="lookup">`) || !strings.Contains(content, "found 2") {
+		t.Errorf("tool results = %v", results)
+	}
+	if _, ok := results[toolResultMarker]; ok {
+		t.Error("internal marker leaked")
+	}
+}
+
+func TestEmulateToolCallStream(t *testing.T) {
+	in := make(chan service.StreamChunk, 8)
+	for _, c := range []string{"Checking", " now.\n<tool", "_call>{\"name\":\"lookup\",", "\"arguments\":{\"q\":\"go\"}}</tool_call>"} {
+		in <- service.StreamChunk{Content: c}
+	}
+	in <- service.StreamChunk{FinishReason: "stop"}
+	in <- service.StreamChunk{Usage: &service.Usage{PromptTokens: 5}}
+	close(in)
+
+	var text strings.Builder
+	var calls []service.ToolCall
+	var finish string
+	var usage bool
+	for c := range EmulateToolCallStream(in) {
+		text.WriteString(c.Content)
+		calls = append(calls, c.ToolCalls...)
+		if c.FinishReason != "" {
+			finish = c.FinishReason
+		}
+		usage = usage || c.Usage != nil
+	}
+
+	if text.String() != "Checking now.\n" {
+		t.Errorf("text = %q", text.String())
+	}
+	if len(calls) != 1 || calls[0].Name != "lookup" || calls[0].Arguments["q"] != "go" {
+		t.Errorf("calls = %+v", calls)
+	}
+	if finish != "tool_calls" {
+		t.Errorf("finish = %q, want tool_calls", finish)
+	}
+	if !usage {
+		t.Error("usage chunk dropped")
+	}
+}
+
+func TestEmulateToolCallStreamPlainText(t *testing.T) {
+	in := make(chan service.StreamChunk, 4)
+	in <- service.StreamChunk{Content: "a < b and "}
+	in <- service.StreamChunk{Content: "```go\nx := 1\n```"}
+	in <- service.StreamChunk{FinishReason: "stop"}
+	close(in)
+
+	var text strings.Builder
+	var finish string
+	for c := range EmulateToolCallStream(in) {
+		text.WriteString(c.Content)
+		if len(c.ToolCalls) > 0 {
+			t.Errorf("unexpected tool calls: %+v", c.ToolCalls)
+		}
+		if c.FinishReason != "" {
+			finish = c.FinishReason
+		}
+	}
+	if text.String() != "a < b and ```go\nx := 1\n```" || finish != "stop" {
+		t.Errorf("text = %q finish = %q", text.String(), finish)
+	}
+}
@@ -0,0 +1,17 @@
+package common
+
+import (
+	"context"
+	"net/http"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// InjectTraceContext writes the W3C trace context carried by ctx into the
+// request headers so upstream providers can join the caller's trace. It is
+// a no-op when ctx has no span or no propagator is configured. Its
+// signature matches klient.WithInject.
+func InjectTraceContext(ctx context.Context, req *http.Request) {
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+}
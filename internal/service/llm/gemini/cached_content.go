@@ -0,0 +1,244 @@
+package gemini
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/rakunlabs/at/internal/service"
+)
+
+// ─── Context caching ───
+//
+// Native Gemini endpoint:
+//   POST /v1beta/cachedContents
+//
+// Agent loops resend the same system instruction and tool catalog on every
+// iteration. When that prefix is long enough, the provider stores it once
+// as a cachedContent and later calls send only its name. Gemini bills the
+// cached tokens at the reduced rate and reports them as
+// usageMetadata.cachedContentTokenCount (Usage.CacheReadTokens); the call
+// that creates a cache reports the stored tokens as Usage.CacheWriteTokens.
+//
+// Caches are keyed by model + prefix and requested with cachedContentTTL.
+// The provider stops using one shortly before it expires and creates a
+// fresh one. A failed creation (model without caching support, prefix under
+// the model's minimum) is remembered for the same TTL so the agent loop
+// does not retry it every iteration. Only the public API uses explicit
+// caches; Vertex relies on Gemini's implicit caching.
+
+const (
+	// minCachedContentTokens is the estimated prefix size below which no
+	// cache is created. Gemini's own minimum is model-dependent (1024 to
+	// 4096 tokens) and smaller prefixes are not worth the storage charge.
+	minCachedContentTokens = 4096
+
+	// cachedContentTTL is the lifetime requested for every cache.
+	cachedContentTTL = 10 * time.Minute
+
+	// cachedContentRefresh is how long before expiry a cache stops being
+	// referenced, so a request never races the deletion.
+	cachedContentRefresh = time.Minute
+)
+
+// cachedContentRequest is both the cache key material and the create body.
+type cachedContentRequest struct {
+	Model             string       `json:"model"`
+	SystemInstruction *content     `json:"systemInstruction,omitempty"`
+	Tools             []googleTool `json:"tools,omitempty"`
+	ToolConfig        *toolConfig  `json:"toolConfig,omitempty"`
+	TTL               string       `json:"ttl,omitempty"`
+}
+
+type cachedContentResponse struct {
+	Name          string `json:"name"`
+	UsageMetadata struct {
+		TotalTokenCount int `json:"totalTokenCount"`
+	} `json:"usageMetadata"`
+}
+
+// contextCache tracks the caches created by one provider. The zero value
+// is ready to use.
+type contextCache struct {
+	mu      sync.Mutex
+	entries map[string]contextCacheEntry
+}
+
+type contextCacheEntry struct {
+	// name is empty while the cache is being created and after a failed
+	// creation; requests are then sent with the prefix inline.
+	name    string
+	expires time.Time
+}
+
+// lookup returns the cache name for key. create is true when the caller
+// should create the cache; the key is then reserved so concurrent calls
+// send their prefix inline instead of creating duplicates.
+func (c *contextCache) lookup(key string, now time.Time) (name string, create bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.entries[key]; ok && now.Before(e.expires) {
+		return e.name, false
+	}
+	if c.entries == nil {
+		c.entries = make(map[string]contextCacheEntry)
+	}
+	for k, e := range c.entries {
+		if !now.Before(e.expires) {
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = contextCacheEntry{expires: now.Add(cachedContentTTL)}
+	return "", true
+}
+
+// store records the outcome of creating the cache for key.
+func (c *contextCache) store(key, name string, created time.Time) {
+	expires := created.Add(cachedContentTTL)
+	if name != "" {
+		expires = expires.Add(-cachedContentRefresh)
+	}
+	c.mu.Lock()
+	c.entries[key] = contextCacheEntry{name: name, expires: expires}
+	c.mu.Unlock()
+}
+
+// forget drops key so the next call creates the cache again.
+func (c *contextCache) forget(key string) {
+	if key == "" {
+		return
+	}
+	c.mu.Lock()
+	delete(c.entries, key)
+	c.mu.Unlock()
+}
+
+// cachedPrefix is the outcome of applying the context cache to a request.
+type cachedPrefix struct {
+	key     string // set when the request references a cache
+	written int    // tokens stored by a cache this call created
+}
+
+// addTo reports the tokens written to the cache on the call's usage.
+func (c cachedPrefix) addTo(u *service.Usage) {
+	if c.written == 0 {
+		return
+	}
+	u.CacheWriteTokens += c.written
+	if u.TotalTokens > 0 {
+		u.TotalTokens += c.written
+	}
+}
+
+// useCachedContent moves the stable prefix of req (system instruction,
+// tools, tool config) into a cachedContent when it is large enough, and
+// points req at it. Any failure leaves req untouched.
+func (p *Provider) useCachedContent(ctx context.Context, model string, req *generateContentRequest, opts *service.ChatOptions) cachedPrefix {
+	if p.contextCachingDisabled || p.pathPrefix != "" || (req.SystemInstruction == nil && len(req.Tools) == 0) {
+		return cachedPrefix{}
+	}
+	if opts != nil {
+		if opts.DisablePromptCache {
+			return cachedPrefix{}
+		}
+		// The caller manages the prefix itself.
+		for _, k := range []string{"cachedContent", "systemInstruction", "tools", "toolConfig"} {
+			if _, ok := opts.ExtraBody[k]; ok {
+				return cachedPrefix{}
+			}
+		}
+	}
+
+	prefix := cachedContentRequest{
+		Model:             "models/" + model,
+		SystemInstruction: req.SystemInstruction,
+		Tools:             req.Tools,
+		ToolConfig:        req.ToolConfig,
+	}
+	prefixJSON, err := json.Marshal(prefix)
+	// ~4 characters per token; JSON overhead errs on the large side.
+	if err != nil || len(prefixJSON)/4 < minCachedContentTokens {
+		return cachedPrefix{}
+	}
+	sum := sha256.Sum256(prefixJSON)
+	key := hex.EncodeToString(sum[:])
+
+	name, create := p.contextCache.lookup(key, time.Now())
+	written := 0
+	if create {
+		created := time.Now()
+		prefix.TTL = fmt.Sprintf("%ds", int(cachedContentTTL.Seconds()))
+		name, written, err = p.createCachedContent(ctx, prefix)
+		switch {
+		case ctx.Err() != nil:
+			p.contextCache.forget(key)
+			return cachedPrefix{}
+		case err != nil:
+			slog.Warn("gemini: context cache not created; sending the prefix inline",
+				"model", model, "error", err)
+		}
+		p.contextCache.store(key, name, created)
+	}
+	if name == "" {
+		return cachedPrefix{}
+	}
+
+	req.CachedContent = name
+	req.SystemInstruction, req.Tools, req.ToolConfig = nil, nil, nil
+	return cachedPrefix{key: key, written: written}
+}
+
+// createCachedContent stores prefix and returns the cache name and the
+// number of tokens it holds.
+func (p *Provider) createCachedContent(ctx context.Context, prefix cachedContentRequest) (string, int, error) {
+	jsonData, err := json.Marshal(prefix)
+	if err != nil {
+		return "", 0, fmt.Errorf("marshal gemini cachedContent: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.BaseURL+"/v1beta/cachedContents", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return "", 0, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if p.tokenSource != nil {
+		tk, terr := p.tokenSource.Token()
+		if terr != nil {
+			return "", 0, fmt.Errorf("gemini auth: %w", terr)
+		}
+		httpReq.Header.Set("Authorization", "Bearer "+tk)
+	} else if p.APIKey != "" {
+		httpReq.Header.Set("x-goog-api-key", p.APIKey)
+	}
+
+	resp, err := p.client.HTTP.Do(httpReq)
+	if err != nil {
+		return "", 0, fmt.Errorf("gemini cachedContents http: %w", err)
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", 0, fmt.Errorf("read cachedContents response: %w", err)
+	}
+	if resp.StatusCode >= 400 {
+		return "", 0, fmt.Errorf("gemini cachedContents API error (status %d): %s", resp.StatusCode, string(respBody))
+	}
+
+	var parsed cachedContentResponse
+	if err := json.Unmarshal(respBody, &parsed); err != nil {
+		return "", 0, fmt.Errorf("decode cachedContents response: %w (body: %s)", err, string(respBody))
+	}
+	if parsed.Name == "" {
+		return "", 0, fmt.Errorf("gemini cachedContents response has no name: %s", string(respBody))
+	}
+	return parsed.Name, parsed.UsageMetadata.TotalTokenCount, nil
+}
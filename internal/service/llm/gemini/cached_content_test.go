@@ -0,0 +1,151 @@
+package gemini
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/rakunlabs/at/internal/service"
+)
+
+// cachedContentServer fakes the cachedContents and generateContent
+// endpoints and records what each generateContent call carried.
+type cachedContentServer struct {
+	createStatus int
+
+	creates  atomic.Int32
+	requests []map[string]any
+}
+
+func (s *cachedContentServer) handler(t *testing.T) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Fatalf("decode request: %v", err)
+		}
+		switch r.URL.Path {
+		case "/v1beta/cachedContents":
+			s.creates.Add(1)
+			if body["model"] != "models/gemini-2.5-flash" || body["systemInstruction"] == nil || body["ttl"] != "600s" {
+				t.Errorf("create body = %v", body)
+			}
+			if s.createStatus != 0 {
+				w.WriteHeader(s.createStatus)
+				_, _ = w.Write([]byte(`{"error":{"code":400,"message":"too small"}}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"name":"cachedContents/abc","usageMetadata":{"totalTokenCount":5000}}`))
+		case "/v1beta/models/gemini-2.5-flash:generateContent":
+			s.requests = append(s.requests, body)
+			cached := 0
+			if body["cachedContent"] != nil {
+				cached = 5000
+			}
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"candidates": []any{map[string]any{
+					"content":      map[string]any{"role": "model", "parts": []any{map[string]any{"text": "ok"}}},
+					"finishReason": "STOP",
+				}},
+				"usageMetadata": map[string]any{
+					"promptTokenCount":        cached + 20,
+					"candidatesTokenCount":    2,
+					"cachedContentTokenCount": cached,
+					"totalTokenCount":         cached + 22,
+				},
+			})
+		default:
+			t.Errorf("unexpected path %q", r.URL.Path)
+		}
+	}
+}
+
+func newCachedContentProvider(t *testing.T, fake *cachedContentServer) *Provider {
+	t.Helper()
+	server := httptest.NewServer(fake.handler(t))
+	t.Cleanup(server.Close)
+	provider, err := New("test-key", "gemini-2.5-flash", server.URL, "", false)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return provider
+}
+
+func cachedContentMessages(system string) []service.Message {
+	return []service.Message{
+		{Role: "system", Content: system},
+		{Role: "user", Content: "hello"},
+	}
+}
+
+func TestChatCreatesAndReusesCachedContent(t *testing.T) {
+	fake := &cachedContentServer{}
+	provider := newCachedContentProvider(t, fake)
+	messages := cachedContentMessages(strings.Repeat("stable instructions ", 1000))
+	tools := []service.Tool{{Name: "task_get", InputSchema: map[string]any{"type": "object"}}}
+
+	first, err := provider.Chat(context.Background(), "", messages, tools, nil)
+	if err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+	second, err := provider.Chat(context.Background(), "", messages, tools, nil)
+	if err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+
+	if got := fake.creates.Load(); got != 1 {
+		t.Fatalf("creates = %d, want 1", got)
+	}
+	for i, req := range fake.requests {
+		if req["cachedContent"] != "cachedContents/abc" || req["systemInstruction"] != nil || req["tools"] != nil {
+			t.Errorf("request %d = %v, want the cached prefix", i, req)
+		}
+	}
+	if first.Usage.CacheWriteTokens != 5000 || first.Usage.CacheReadTokens != 5000 || first.Usage.PromptTokens != 20 {
+		t.Errorf("first usage = %+v", first.Usage)
+	}
+	if second.Usage.CacheWriteTokens != 0 || second.Usage.CacheReadTokens != 5000 {
+		t.Errorf("second usage = %+v", second.Usage)
+	}
+}
+
+func TestChatSendsPrefixInlineWithoutCachedContent(t *testing.T) {
+	tests := []struct {
+		name   string
+		system string
+		opts   *service.ChatOptions
+		status int
+	}{
+		{name: "short prefix", system: "be brief"},
+		{name: "disabled per call", system: strings.Repeat("stable instructions ", 1000), opts: &service.ChatOptions{DisablePromptCache: true}},
+		{name: "creation fails", system: strings.Repeat("stable instructions ", 1000), status: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &cachedContentServer{createStatus: tt.status}
+			provider := newCachedContentProvider(t, fake)
+
+			for range 2 {
+				if _, err := provider.Chat(context.Background(), "", cachedContentMessages(tt.system), nil, tt.opts); err != nil {
+					t.Fatalf("Chat: %v", err)
+				}
+			}
+
+			wantCreates := int32(0)
+			if tt.status != 0 {
+				wantCreates = 1 // failure is remembered, not retried
+			}
+			if got := fake.creates.Load(); got != wantCreates {
+				t.Errorf("creates = %d, want %d", got, wantCreates)
+			}
+			for i, req := range fake.requests {
+				if req["cachedContent"] != nil || req["systemInstruction"] == nil {
+					t.Errorf("request %d = %v, want the prefix inline", i, req)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package gemini
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/rakunlabs/at/internal/service"
+)
+
+// ─── Token counting ───
+//
+// Native Gemini endpoint:
+//   POST /v1beta/models/{model}:countTokens
+// The public API wraps the full request in generateContentRequest so the
+// system instruction and tools are counted; Vertex takes the fields at
+// the top level.
+
+type countTokensRequest struct {
+	GenerateContentRequest *countTokensContentRequest `json:"generateContentRequest,omitempty"`
+
+	// Vertex shape.
+	Contents          []content    `json:"contents,omitempty"`
+	Tools             []googleTool `json:"tools,omitempty"`
+	SystemInstruction *content     `json:"systemInstruction,omitempty"`
+}
+
+type countTokensContentRequest struct {
+	Model             string       `json:"model"`
+	Contents          []content    `json:"contents"`
+	Tools             []googleTool `json:"tools,omitempty"`
+	SystemInstruction *content     `json:"systemInstruction,omitempty"`
+}
+
+type countTokensResponse struct {
+	TotalTokens int `json:"totalTokens"`
+}
+
+// CountTokens implements service.TokenCountProvider for the Gemini provider.
+func (p *Provider) CountTokens(ctx context.Context, model string, messages []service.Message, tools []service.Tool) (int, error) {
+	if model == "" {
+		model = p.Model
+	}
+
+	gen := p.buildRequest(ctx, messages, tools, nil)
+	var body countTokensRequest
+	if p.pathPrefix != "" {
+		body.Contents = gen.Contents
+		body.Tools = gen.Tools
+		body.SystemInstruction = gen.SystemInstruction
+	} else {
+		body.GenerateContentRequest = &countTokensContentRequest{
+			Model:             "models/" + model,
+			Contents:          gen.Contents,
+			Tools:             gen.Tools,
+			SystemInstruction: gen.SystemInstruction,
+		}
+	}
+
+	jsonData, err := json.Marshal(body)
+	if err != nil {
+		return 0, fmt.Errorf("marshal gemini countTokens: %w", err)
+	}
+
+	path := fmt.Sprintf("/v1beta/models/%s:countTokens", model)
+	if p.pathPrefix != "" {
+		path = p.pathPrefix + fmt.Sprintf("/models/%s:countTokens", model)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.BaseURL+path, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return 0, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if p.tokenSource != nil {
+		tk, terr := p.tokenSource.Token()
+		if terr != nil {
+			return 0, fmt.Errorf("gemini auth: %w", terr)
+		}
+		httpReq.Header.Set("Authorization", "Bearer "+tk)
+	} else if p.APIKey != "" {
+		httpReq.Header.Set("x-goog-api-key", p.APIKey)
+	}
+
+	resp, err := p.client.HTTP.Do(httpReq)
+	if err != nil {
+		return 0, fmt.Errorf("gemini countTokens http: %w", err)
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, fmt.Errorf("read countTokens response: %w", err)
+	}
+	if resp.StatusCode >= 400 {
+		return 0, fmt.Errorf("gemini countTokens API error (status %d): %s", resp.StatusCode, string(respBody))
+	}
+
+	var parsed countTokensResponse
+	if err := json.Unmarshal(respBody, &parsed); err != nil {
+		return 0, fmt.Errorf("decode countTokens response: %w (body: %s)", err, string(respBody))
+	}
+	return parsed.TotalTokens, nil
+}
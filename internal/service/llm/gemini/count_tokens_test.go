@@ -0,0 +1,46 @@
+package gemini
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rakunlabs/at/internal/service"
+)
+
+func TestCountTokensWrapsGenerateContentRequest(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1beta/models/gemini-2.5-pro:countTokens" {
+			t.Errorf("path = %q", r.URL.Path)
+		}
+		if r.Header.Get("x-goog-api-key") != "test-key" {
+			t.Errorf("api key = %q", r.Header.Get("x-goog-api-key"))
+		}
+		var body countTokensRequest
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Fatalf("decode request: %v", err)
+		}
+		gen := body.GenerateContentRequest
+		if gen == nil || gen.Model != "models/gemini-2.5-pro" || len(gen.Contents) != 1 || gen.SystemInstruction == nil {
+			t.Errorf("request = %+v", body)
+		}
+		_, _ = w.Write([]byte(`{"totalTokens":42}`))
+	}))
+	defer server.Close()
+
+	provider, err := New("test-key", "unused", server.URL, "", false)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	n, err := provider.CountTokens(context.Background(), "gemini-2.5-pro", []service.Message{
+		{Role: "system", Content: "be brief"},
+		{Role: "user", Content: "hello"},
+	}, nil)
+	if err != nil || n != 42 {
+		t.Fatalf("CountTokens = %d, err=%v", n, err)
+	}
+}
@@ -50,6 +50,11 @@ type Provider struct {
 	// pathPrefix is prepended to the /v1beta/... path on every call.
 	// Vertex needs a project/region prefix; the public API does not.
 	pathPrefix string
+
+	// contextCachingDisabled turns off the automatic cachedContents for
+	// long system instructions and tool catalogs. See cached_content.go.
+	contextCachingDisabled bool
+	contextCache           contextCache
 }
 
 // GoogleTokenSource produces short-lived OAuth tokens (e.g. via Google ADC)
@@ -90,6 +95,15 @@ func WithRateLimiter(l *ratelimit.Limiter) Option {
 	}
 }
 
+// WithContextCachingDisabled turns off the automatic cachedContents the
+// provider creates for long system instructions and tool catalogs.
+// Default behaviour is ON.
+func WithContextCachingDisabled(disabled bool) Option {
+	return func(p *Provider) {
+		p.contextCachingDisabled = disabled
+	}
+}
+
 // New creates a Google AI (Gemini) provider.
 //
 // apiKey is the API key from Google AI Studio (aistudio.google.com).
@@ -120,6 +134,7 @@ func New(apiKey, model, baseURL, proxy string, insecureSkipVerify bool, opts ...
 		klient.WithDisableRetry(true),
 		klient.WithDisableEnvValues(true),
 		klient.WithHeaderSet(headers),
+		klient.WithInject(common.InjectTraceContext),
 	}
 	if proxy != "" {
 		klientOpts = append(klientOpts, klient.WithProxy(proxy))
@@ -155,6 +170,9 @@ type generateContentRequest struct {
 	GenerationConfig  *generationConfig `json:"generationConfig,omitempty"`
 	ToolConfig        *toolConfig       `json:"toolConfig,omitempty"`
 	SafetySettings    []safetySetting   `json:"safetySettings,omitempty"`
+	// CachedContent names a cachedContents entry holding the system
+	// instruction, tools and tool config; those fields are then omitted.
+	CachedContent string `json:"cachedContent,omitempty"`
 }
 
 // safetySetting controls Gemini's per-category content blocking. We default
@@ -249,6 +267,13 @@ type generationConfig struct {
 	PresencePenalty  *float64        `json:"presencePenalty,omitempty"`
 	FrequencyPenalty *float64        `json:"frequencyPenalty,omitempty"`
 	CandidateCount   int             `json:"candidateCount,omitempty"`
+	// ResponseModalities and ImageConfig are set for image generation.
+	ResponseModalities []string     `json:"responseModalities,omitempty"`
+	ImageConfig        *imageConfig `json:"imageConfig,omitempty"`
+}
+
+type imageConfig struct {
+	AspectRatio string `json:"aspectRatio,omitempty"`
 }
 
 type thinkingConfig struct {
@@ -323,6 +348,7 @@ func (p *Provider) Chat(ctx context.Context, model string, messages []service.Me
 	defer release()
 
 	reqBody := p.buildRequest(ctx, messages, tools, opts)
+	cached := p.useCachedContent(ctx, model, reqBody, opts)
 
 	var extra map[string]any
 	if opts != nil {
@@ -378,8 +404,13 @@ func (p *Provider) Chat(ctx context.Context, model string, messages []service.Me
 
 		return nil
 	}); err != nil {
+		p.contextCache.forget(cached.key)
 		return nil, err
 	}
+	if statusCode != http.StatusOK {
+		// The cache may have been deleted upstream; recreate it next call.
+		p.contextCache.forget(cached.key)
+	}
 
 	// Surface 429 / RESOURCE_EXHAUSTED as a typed error so the agent
 	// retry loop can honour Retry-After.
@@ -398,7 +429,12 @@ func (p *Provider) Chat(ctx context.Context, model string, messages []service.Me
 		}
 	}
 
-	return parseResponse(&result, headers)
+	resp, err := parseResponse(&result, headers)
+	if err != nil {
+		return nil, err
+	}
+	cached.addTo(&resp.Usage)
+	return resp, nil
 }
 
 // ─── Streaming ───
@@ -423,6 +459,7 @@ func (p *Provider) ChatStream(ctx context.Context, model string, messages []serv
 	}
 
 	reqBody := p.buildRequest(ctx, messages, tools, opts)
+	cached := p.useCachedContent(ctx, model, reqBody, opts)
 
 	var extra map[string]any
 	if opts != nil {
@@ -473,6 +510,7 @@ func (p *Provider) ChatStream(ctx context.Context, model string, messages []serv
 	if resp.StatusCode != http.StatusOK {
 		defer resp.Body.Close()
 		defer releaseOnce()
+		p.contextCache.forget(cached.key)
 		bodyData, _ := io.ReadAll(resp.Body)
 		if resp.StatusCode == http.StatusTooManyRequests {
 			return nil, nil, &service.RateLimitError{
@@ -533,6 +571,7 @@ func (p *Provider) ChatStream(ctx context.Context, model string, messages []serv
 			// Capture usage metadata from each chunk; the last one has final totals.
 			if sr.UsageMetadata != nil {
 				usage := geminiServiceUsage(sr.UsageMetadata)
+				cached.addTo(&usage)
 				lastUsage = &usage
 			}
 
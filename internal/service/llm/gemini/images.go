@@ -0,0 +1,225 @@
+package gemini
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/rakunlabs/at/internal/service"
+	"github.com/rakunlabs/at/internal/service/llm/common"
+)
+
+// ─── Image generation ───
+//
+// Gemini image models ("Nano Banana") generate and edit images through the
+// regular endpoint:
+//   POST /v1beta/models/{model}:generateContent
+// with responseModalities ["TEXT", "IMAGE"]. Edits send the source images
+// as inline parts next to the prompt. There is no mask input: a mask is sent
+// as one more image with an instruction to confine the edit to its
+// transparent area. Variations are edits with a fixed prompt.
+//
+// The image models return one image per call, so N images take N calls and
+// their usage is summed.
+
+const (
+	// defaultImageModel is used when the request names no model.
+	defaultImageModel = "gemini-2.5-flash-image"
+
+	maskInstruction      = "The last image is a mask. Change only the areas where the mask is transparent and keep everything else identical."
+	variationInstruction = "Create a variation of this image that keeps its subject, composition and style."
+)
+
+// geminiAspectRatios are the aspect ratios the image models accept.
+var geminiAspectRatios = map[string]bool{
+	"1:1": true, "2:3": true, "3:2": true, "3:4": true, "4:3": true,
+	"4:5": true, "5:4": true, "9:16": true, "16:9": true, "21:9": true,
+}
+
+// GenerateImage implements service.ImageProvider.
+func (p *Provider) GenerateImage(ctx context.Context, req service.ImageGenerateRequest) (*service.ImageResponse, error) {
+	parts := []part{{Text: req.Prompt}}
+	return p.generateImages(ctx, req.Model, req.Size, req.N, parts)
+}
+
+// EditImage implements service.ImageProvider.
+func (p *Provider) EditImage(ctx context.Context, req service.ImageEditRequest) (*service.ImageResponse, error) {
+	if len(req.Images) == 0 {
+		return nil, fmt.Errorf("gemini image edit: at least one image is required")
+	}
+
+	prompt := req.Prompt
+	images := req.Images
+	if req.Mask != nil {
+		prompt = strings.TrimSpace(prompt + "\n\n" + maskInstruction)
+		images = append(append([]service.ImageInput(nil), images...), *req.Mask)
+	}
+
+	parts := []part{{Text: prompt}}
+	for _, img := range images {
+		parts = append(parts, imageInputPart(img))
+	}
+	return p.generateImages(ctx, req.Model, req.Size, req.N, parts)
+}
+
+// CreateImageVariation implements service.ImageProvider.
+func (p *Provider) CreateImageVariation(ctx context.Context, req service.ImageVariationRequest) (*service.ImageResponse, error) {
+	parts := []part{{Text: variationInstruction}, imageInputPart(req.Image)}
+	return p.generateImages(ctx, req.Model, req.Size, req.N, parts)
+}
+
+// generateImages runs n generateContent calls with parts and collects the
+// returned images.
+func (p *Provider) generateImages(ctx context.Context, model, size string, n int, parts []part) (*service.ImageResponse, error) {
+	if model == "" {
+		model = defaultImageModel
+	}
+	if n <= 0 {
+		n = 1
+	}
+
+	body := generateContentRequest{
+		Contents: []content{{Role: "user", Parts: parts}},
+		GenerationConfig: &generationConfig{
+			ResponseModalities: []string{"TEXT", "IMAGE"},
+		},
+		SafetySettings: defaultSafetySettings(),
+	}
+	if ratio := aspectRatioFromSize(size); ratio != "" {
+		body.GenerationConfig.ImageConfig = &imageConfig{AspectRatio: ratio}
+	}
+
+	out := &service.ImageResponse{}
+	for range n {
+		resp, err := p.generateImageContent(ctx, model, &body)
+		if err != nil {
+			return nil, err
+		}
+		if len(resp.InlineImages) == 0 {
+			return nil, fmt.Errorf("gemini returned no image (finish reason %q): %s", resp.FinishReason, resp.Content)
+		}
+		for _, img := range resp.InlineImages {
+			out.Images = append(out.Images, service.GeneratedImage{Base64: img.Data})
+		}
+		out.Usage = out.Usage.Add(resp.Usage)
+	}
+	return out, nil
+}
+
+// generateImageContent sends one generateContent request for model.
+func (p *Provider) generateImageContent(ctx context.Context, model string, body *generateContentRequest) (*service.LLMResponse, error) {
+	jsonData, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("marshal gemini image request: %w", err)
+	}
+
+	path := fmt.Sprintf("/v1beta/models/%s:generateContent", model)
+	if p.pathPrefix != "" {
+		path = p.pathPrefix + fmt.Sprintf("/models/%s:generateContent", model)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.BaseURL+path, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if p.tokenSource != nil {
+		tk, terr := p.tokenSource.Token()
+		if terr != nil {
+			return nil, fmt.Errorf("gemini auth: %w", terr)
+		}
+		httpReq.Header.Set("Authorization", "Bearer "+tk)
+	} else if p.APIKey != "" {
+		httpReq.Header.Set("x-goog-api-key", p.APIKey)
+	}
+
+	// Every image is its own call; each one takes a limiter slot.
+	release, err := p.limiter.Acquire(ctx, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	resp, err := p.client.HTTP.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("gemini image http: %w", err)
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read image response: %w", err)
+	}
+
+	var parsed generateContentResponse
+	_ = json.Unmarshal(respBody, &parsed)
+	if resp.StatusCode == http.StatusTooManyRequests {
+		msg := "rate limited"
+		if parsed.Error != nil {
+			msg = parsed.Error.Message
+		}
+		return nil, &service.RateLimitError{
+			StatusCode: resp.StatusCode,
+			RetryAfter: common.ParseRetryAfter(resp.Header),
+			Provider:   "gemini",
+			Message:    msg,
+			Underlying: fmt.Errorf("gemini image API error (status %d): %s", resp.StatusCode, msg),
+		}
+	}
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("gemini image API error (status %d): %s", resp.StatusCode, string(respBody))
+	}
+
+	return parseResponse(&parsed, resp.Header)
+}
+
+// imageInputPart converts an uploaded image to an inline part.
+func imageInputPart(img service.ImageInput) part {
+	mimeType, data := parseGeminiDataURL(img.Base64)
+	if data == "" {
+		data = img.Base64
+	}
+	if img.ContentType != "" {
+		mimeType = img.ContentType
+	}
+	if mimeType == "" {
+		mimeType = "image/png"
+	}
+	return part{InlineData: &inlineData{MimeType: mimeType, Data: data}}
+}
+
+// aspectRatioFromSize maps an OpenAI-style size ("1792x1024") or an aspect
+// ratio ("16:9") to a ratio the image models accept. Sizes that reduce to
+// no supported ratio are dropped and the model picks its default.
+func aspectRatioFromSize(size string) string {
+	if geminiAspectRatios[size] {
+		return size
+	}
+	w, h, ok := strings.Cut(size, "x")
+	if !ok {
+		return ""
+	}
+	width, werr := strconv.Atoi(w)
+	height, herr := strconv.Atoi(h)
+	if werr != nil || herr != nil || width <= 0 || height <= 0 {
+		return ""
+	}
+	best, bestDiff := "", 0.05
+	for ratio := range geminiAspectRatios {
+		a, b, _ := strings.Cut(ratio, ":")
+		rw, _ := strconv.Atoi(a)
+		rh, _ := strconv.Atoi(b)
+		diff := float64(width)/float64(height) - float64(rw)/float64(rh)
+		if diff < 0 {
+			diff = -diff
+		}
+		if diff < bestDiff {
+			best, bestDiff = ratio, diff
+		}
+	}
+	return best
+}
@@ -0,0 +1,160 @@
+package gemini
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rakunlabs/at/internal/service"
+	"github.com/rakunlabs/at/internal/service/ratelimit"
+)
+
+func newImageTestProvider(t *testing.T, handler http.HandlerFunc) *Provider {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	provider, err := New("test-key", "gemini-2.5-flash", server.URL, "", false)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return provider
+}
+
+func TestEditImageSendsInlineImagesAndMask(t *testing.T) {
+	var requests []generateContentRequest
+	provider := newImageTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1beta/models/gemini-2.5-flash-image:generateContent" {
+			t.Errorf("path = %q", r.URL.Path)
+		}
+		var body generateContentRequest
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Fatalf("decode request: %v", err)
+		}
+		requests = append(requests, body)
+		_, _ = w.Write([]byte(`{"candidates":[{"content":{"role":"model","parts":[{"text":"here"},{"inlineData":{"mimeType":"image/png","data":"SU1H"}}]},"finishReason":"STOP"}],"usageMetadata":{"promptTokenCount":300,"candidatesTokenCount":1290,"totalTokenCount":1590}}`))
+	})
+
+	resp, err := provider.EditImage(context.Background(), service.ImageEditRequest{
+		Images: []service.ImageInput{{Base64: "data:image/jpeg;base64,SlBH"}},
+		Mask:   &service.ImageInput{Base64: "TUFTSw==", ContentType: "image/png"},
+		Prompt: "add a hat",
+		Size:   "1792x1024",
+		N:      2,
+	})
+	if err != nil {
+		t.Fatalf("EditImage: %v", err)
+	}
+
+	if len(requests) != 2 {
+		t.Fatalf("requests = %d, want one per image", len(requests))
+	}
+	req := requests[0]
+	parts := req.Contents[0].Parts
+	if len(parts) != 3 || !strings.HasPrefix(parts[0].Text, "add a hat") || !strings.Contains(parts[0].Text, "mask") {
+		t.Fatalf("parts = %+v", parts)
+	}
+	if parts[1].InlineData.MimeType != "image/jpeg" || parts[1].InlineData.Data != "SlBH" || parts[2].InlineData.Data != "TUFTSw==" {
+		t.Errorf("image parts = %+v, %+v", parts[1].InlineData, parts[2].InlineData)
+	}
+	cfg := req.GenerationConfig
+	if len(cfg.ResponseModalities) != 2 || cfg.ImageConfig == nil || cfg.ImageConfig.AspectRatio != "16:9" {
+		t.Errorf("generationConfig = %+v", cfg)
+	}
+
+	if len(resp.Images) != 2 || resp.Images[0].Base64 != "SU1H" {
+		t.Errorf("images = %+v", resp.Images)
+	}
+	if resp.Usage.PromptTokens != 600 || resp.Usage.CompletionTokens != 2580 {
+		t.Errorf("usage = %+v, want summed", resp.Usage)
+	}
+}
+
+func TestGenerateImageWithoutImageFails(t *testing.T) {
+	provider := newImageTestProvider(t, func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{"candidates":[{"content":{"role":"model","parts":[{"text":"I can't draw that."}]},"finishReason":"STOP"}]}`))
+	})
+
+	_, err := provider.GenerateImage(context.Background(), service.ImageGenerateRequest{Prompt: "x"})
+	if err == nil || !strings.Contains(err.Error(), "I can't draw that.") {
+		t.Fatalf("err = %v, want the model's text", err)
+	}
+}
+
+func TestGenerateImageRateLimit(t *testing.T) {
+	provider := newImageTestProvider(t, func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+		_, _ = w.Write([]byte(`{"error":{"code":429,"message":"quota","status":"RESOURCE_EXHAUSTED"}}`))
+	})
+
+	_, err := provider.CreateImageVariation(context.Background(), service.ImageVariationRequest{
+		Image: service.ImageInput{Base64: "SU1H"},
+	})
+	var rateLimitErr *service.RateLimitError
+	if !errors.As(err, &rateLimitErr) || rateLimitErr.Message != "quota" {
+		t.Fatalf("err = %T %v, want *service.RateLimitError", err, err)
+	}
+}
+
+func TestGenerateImageUsesRateLimiter(t *testing.T) {
+	var inflight, maxSeen atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		n := inflight.Add(1)
+		defer inflight.Add(-1)
+		for {
+			cur := maxSeen.Load()
+			if n <= cur || maxSeen.CompareAndSwap(cur, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		_, _ = w.Write([]byte(`{"candidates":[{"content":{"role":"model","parts":[{"inlineData":{"mimeType":"image/png","data":"SU1H"}}]},"finishReason":"STOP"}]}`))
+	}))
+	t.Cleanup(server.Close)
+
+	limiter := ratelimit.New(ratelimit.Config{MaxConcurrent: 1, WaitTimeout: 5 * time.Second})
+	provider, err := New("test-key", "gemini-2.5-flash", server.URL, "", false, WithRateLimiter(limiter))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := provider.GenerateImage(context.Background(), service.ImageGenerateRequest{Prompt: "a cat", N: 2}); err != nil {
+				t.Errorf("GenerateImage: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := maxSeen.Load(); got > 1 {
+		t.Fatalf("max in-flight image calls = %d, want 1 with MaxConcurrent=1", got)
+	}
+}
+
+func TestAspectRatioFromSize(t *testing.T) {
+	tests := map[string]string{
+		"":          "",
+		"1024x1024": "1:1",
+		"1792x1024": "16:9",
+		"1024x1792": "9:16",
+		"1536x1024": "3:2",
+		"4:3":       "4:3",
+		"3000x1000": "",
+		"auto":      "",
+	}
+	for size, want := range tests {
+		if got := aspectRatioFromSize(size); got != want {
+			t.Errorf("aspectRatioFromSize(%q) = %q, want %q", size, got, want)
+		}
+	}
+}
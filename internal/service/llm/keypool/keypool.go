@@ -0,0 +1,369 @@
+// Package keypool spreads one provider's traffic over a pool of API keys.
+//
+// Each key is backed by its own fully configured provider (and therefore
+// its own rate limiter). Calls go to the keys round-robin; a key that
+// answers with a *service.RateLimitError is cooled down for the upstream
+// Retry-After and the call moves on to the next key. A call whose key's
+// local limiter gave up waiting (*ratelimit.Error) moves on as well,
+// without a cooldown. Other errors are returned as-is: they are not
+// specific to the key that happened to serve the request.
+//
+// Pool implements service.LLMProvider, service.LLMStreamProvider,
+// service.TokenCountProvider, service.NativeChoicesProvider and the media
+// provider interfaces. Calls for an interface the pooled provider type
+// lacks fail with service.ErrUnsupportedOperation
+// (service.ErrTokenCountUnsupported for CountTokens). Pool is a
+// service.Unwrapper, so capability checks made with service.As see only
+// what the pooled provider type supports.
+package keypool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rakunlabs/at/internal/service"
+	"github.com/rakunlabs/at/internal/service/ratelimit"
+)
+
+// DefaultCooldown is how long a rate-limited key rests when the upstream
+// response carried no Retry-After.
+const DefaultCooldown = 10 * time.Second
+
+// Call outcomes reported to the Observer.
+const (
+	OutcomeOK          = "ok"
+	OutcomeError       = "error"
+	OutcomeRateLimited = "rate_limited"
+)
+
+// Member is one key of the pool: its label and the provider built with it.
+type Member struct {
+	Name     string
+	Provider service.LLMProvider
+}
+
+// Usage is the per-key usage recorded since the pool was built. Calls
+// served by a key also carry its name in service.Usage.APIKey, so callers
+// can persist per-key usage with their own records.
+type Usage struct {
+	Name             string     `json:"name"`
+	Requests         int64      `json:"requests"`
+	Errors           int64      `json:"errors"`
+	RateLimited      int64      `json:"rate_limited"`
+	PromptTokens     int64      `json:"prompt_tokens"`
+	CompletionTokens int64      `json:"completion_tokens"`
+	CooldownUntil    *time.Time `json:"cooldown_until,omitempty"`
+}
+
+// Observer receives the outcome of every call made with a key. usage is
+// nil when the call reported none.
+type Observer func(key, outcome string, usage *service.Usage)
+
+// Option configures a Pool.
+type Option func(*Pool)
+
+// WithObserver sets a callback for per-key call outcomes (metrics).
+func WithObserver(o Observer) Option {
+	return func(p *Pool) {
+		p.observe = o
+	}
+}
+
+// Pool is an LLM provider that rotates calls over its members.
+type Pool struct {
+	name    string
+	keys    []*poolKey
+	next    atomic.Uint64
+	observe Observer
+}
+
+type poolKey struct {
+	Member
+
+	mu            sync.Mutex
+	cooldownUntil time.Time
+	usage         Usage
+}
+
+// New builds a pool named after the provider key. Members are tried in
+// the given order, starting from a different one on every call.
+func New(name string, members []Member, opts ...Option) (*Pool, error) {
+	if len(members) == 0 {
+		return nil, fmt.Errorf("key pool %q has no keys", name)
+	}
+	p := &Pool{name: name}
+	seen := make(map[string]bool, len(members))
+	for _, m := range members {
+		if m.Provider == nil {
+			return nil, fmt.Errorf("key pool %q: key %q has no provider", name, m.Name)
+		}
+		if seen[m.Name] {
+			return nil, fmt.Errorf("key pool %q: duplicate key name %q", name, m.Name)
+		}
+		seen[m.Name] = true
+		p.keys = append(p.keys, &poolKey{Member: m, usage: Usage{Name: m.Name}})
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p, nil
+}
+
+// KeyUsage returns a snapshot of the per-key usage, in pool order.
+func (p *Pool) KeyUsage() []Usage {
+	now := time.Now()
+	out := make([]Usage, 0, len(p.keys))
+	for _, k := range p.keys {
+		k.mu.Lock()
+		u := k.usage
+		if k.cooldownUntil.After(now) {
+			until := k.cooldownUntil
+			u.CooldownUntil = &until
+		}
+		k.mu.Unlock()
+		out = append(out, u)
+	}
+	return out
+}
+
+// ─── Key selection ───
+
+// candidates returns the keys to try for one call: the ones not cooling
+// down, in round-robin order. When every key is cooling down it returns
+// nil and the time until the first one is usable again.
+func (p *Pool) candidates() ([]*poolKey, time.Duration) {
+	now := time.Now()
+	start := int(p.next.Add(1)-1) % len(p.keys)
+
+	var (
+		out  []*poolKey
+		wait time.Duration
+	)
+	for i := range p.keys {
+		k := p.keys[(start+i)%len(p.keys)]
+		k.mu.Lock()
+		until := k.cooldownUntil
+		k.mu.Unlock()
+		if remaining := until.Sub(now); remaining > 0 {
+			if wait == 0 || remaining < wait {
+				wait = remaining
+			}
+			continue
+		}
+		out = append(out, k)
+	}
+	return out, wait
+}
+
+// call runs fn with the next usable key, moving on to the following key
+// when one is rate limited. The caller records the successful outcome on
+// the returned key (usage is only known to the caller).
+func call[T any](ctx context.Context, p *Pool, fn func(service.LLMProvider) (T, error)) (T, *poolKey, error) {
+	var zero T
+
+	keys, wait := p.candidates()
+	if len(keys) == 0 {
+		return zero, nil, &service.RateLimitError{
+			StatusCode: http.StatusTooManyRequests,
+			RetryAfter: wait,
+			Provider:   p.name,
+			Message:    "all API keys are cooling down",
+		}
+	}
+
+	var lastErr error
+	for _, k := range keys {
+		res, err := fn(k.Provider)
+		if err == nil {
+			return res, k, nil
+		}
+		if errors.Is(err, service.ErrUnsupportedOperation) || errors.Is(err, service.ErrTokenCountUnsupported) {
+			return zero, nil, err
+		}
+
+		var rle *service.RateLimitError
+		var lim *ratelimit.Error
+		switch {
+		case errors.As(err, &rle):
+			k.coolDown(rle.RetryAfter)
+			k.record(OutcomeRateLimited, nil, p.observe)
+		case errors.As(err, &lim):
+			k.record(OutcomeRateLimited, nil, p.observe)
+		default:
+			k.record(OutcomeError, nil, p.observe)
+			return zero, nil, err
+		}
+
+		lastErr = err
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return zero, nil, lastErr
+}
+
+func (k *poolKey) coolDown(d time.Duration) {
+	if d <= 0 {
+		d = DefaultCooldown
+	}
+	until := time.Now().Add(d)
+	k.mu.Lock()
+	if until.After(k.cooldownUntil) {
+		k.cooldownUntil = until
+	}
+	k.mu.Unlock()
+}
+
+// served records a successful call on k and labels its usage with the
+// key name.
+func (k *poolKey) served(usage *service.Usage, observe Observer) {
+	usage.APIKey = k.Name
+	k.record(OutcomeOK, usage, observe)
+}
+
+func (k *poolKey) record(outcome string, usage *service.Usage, observe Observer) {
+	k.mu.Lock()
+	k.usage.Requests++
+	switch outcome {
+	case OutcomeError:
+		k.usage.Errors++
+	case OutcomeRateLimited:
+		k.usage.RateLimited++
+	}
+	if usage != nil {
+		k.usage.PromptTokens += int64(usage.PromptTokens)
+		k.usage.CompletionTokens += int64(usage.CompletionTokens)
+	}
+	k.mu.Unlock()
+
+	if observe != nil {
+		observe(k.Name, outcome, usage)
+	}
+}
+
+// ─── LLMProvider / LLMStreamProvider ───
+
+// Chat implements service.LLMProvider.
+func (p *Pool) Chat(ctx context.Context, model string, messages []service.Message, tools []service.Tool, opts *service.ChatOptions) (*service.LLMResponse, error) {
+	resp, k, err := call(ctx, p, func(prov service.LLMProvider) (*service.LLMResponse, error) {
+		return prov.Chat(ctx, model, messages, tools, opts)
+	})
+	if err != nil {
+		return nil, err
+	}
+	k.served(&resp.Usage, p.observe)
+	return resp, nil
+}
+
+type streamStart struct {
+	ch     <-chan service.StreamChunk
+	header http.Header
+}
+
+// ChatStream implements service.LLMStreamProvider. Only the start of the
+// stream can move to another key; the usage of the final chunk is
+// recorded once the stream ends.
+func (p *Pool) ChatStream(ctx context.Context, model string, messages []service.Message, tools []service.Tool, opts *service.ChatOptions) (<-chan service.StreamChunk, http.Header, error) {
+	start, k, err := call(ctx, p, func(prov service.LLMProvider) (streamStart, error) {
+		sp, ok := prov.(service.LLMStreamProvider)
+		if !ok {
+			return streamStart{}, fmt.Errorf("%w: streaming", service.ErrUnsupportedOperation)
+		}
+		ch, header, err := sp.ChatStream(ctx, model, messages, tools, opts)
+		return streamStart{ch: ch, header: header}, err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	out := make(chan service.StreamChunk, 64)
+	go func() {
+		defer close(out)
+
+		var (
+			usage   *service.Usage
+			outcome = OutcomeOK
+		)
+		for chunk := range start.ch {
+			if chunk.Usage != nil {
+				labeled := *chunk.Usage
+				labeled.APIKey = k.Name
+				chunk.Usage = &labeled
+				usage = chunk.Usage
+			}
+			if chunk.Error != nil {
+				outcome = OutcomeError
+				var rle *service.RateLimitError
+				if errors.As(chunk.Error, &rle) {
+					outcome = OutcomeRateLimited
+					k.coolDown(rle.RetryAfter)
+				}
+			}
+			out <- chunk
+		}
+		k.record(outcome, usage, p.observe)
+	}()
+
+	return out, start.header, nil
+}
+
+// Proxy implements service.LLMStreamProvider. Raw passthrough can be
+// stateful (files, batches, stored responses), so it always uses the
+// first key of the pool.
+func (p *Pool) Proxy(w http.ResponseWriter, r *http.Request, path string) error {
+	sp, ok := p.keys[0].Provider.(interface {
+		Proxy(w http.ResponseWriter, r *http.Request, path string) error
+	})
+	if !ok {
+		return fmt.Errorf("%w: proxy", service.ErrUnsupportedOperation)
+	}
+	return sp.Proxy(w, r, path)
+}
+
+// CountTokens implements service.TokenCountProvider.
+func (p *Pool) CountTokens(ctx context.Context, model string, messages []service.Message, tools []service.Tool) (int, error) {
+	n, k, err := call(ctx, p, func(prov service.LLMProvider) (int, error) {
+		tc, ok := prov.(service.TokenCountProvider)
+		if !ok {
+			return 0, service.ErrTokenCountUnsupported
+		}
+		return tc.CountTokens(ctx, model, messages, tools)
+	})
+	if err != nil {
+		return 0, err
+	}
+	k.record(OutcomeOK, nil, p.observe)
+	return n, nil
+}
+
+// Unwrap implements service.Unwrapper. Every member is the same provider
+// type, so the first one stands for the pool.
+func (p *Pool) Unwrap() service.LLMProvider {
+	return p.keys[0].Provider
+}
+
+// NativeChoices implements service.NativeChoicesProvider. Every member
+// is the same provider type, so the first one answers for the pool.
+func (p *Pool) NativeChoices() bool {
+	nc, ok := p.keys[0].Provider.(service.NativeChoicesProvider)
+	return ok && nc.NativeChoices()
+}
+
+var (
+	_ service.LLMProvider           = (*Pool)(nil)
+	_ service.LLMStreamProvider     = (*Pool)(nil)
+	_ service.TokenCountProvider    = (*Pool)(nil)
+	_ service.ImageProvider         = (*Pool)(nil)
+	_ service.AudioProvider         = (*Pool)(nil)
+	_ service.EmbeddingProvider     = (*Pool)(nil)
+	_ service.ModerationProvider    = (*Pool)(nil)
+	_ service.RerankProvider        = (*Pool)(nil)
+	_ service.VideoProvider         = (*Pool)(nil)
+	_ service.NativeChoicesProvider = (*Pool)(nil)
+	_ service.Unwrapper             = (*Pool)(nil)
+)
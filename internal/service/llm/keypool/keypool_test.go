@@ -0,0 +1,339 @@
+package keypool
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rakunlabs/at/internal/service"
+	"github.com/rakunlabs/at/internal/service/ratelimit"
+)
+
+// fakeProvider answers with a queue of errors, then successes.
+type fakeProvider struct {
+	mu    sync.Mutex
+	calls int
+	errs  []error
+}
+
+func (f *fakeProvider) next() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+	if len(f.errs) > 0 {
+		err := f.errs[0]
+		f.errs = f.errs[1:]
+		return err
+	}
+	return nil
+}
+
+func (f *fakeProvider) Chat(context.Context, string, []service.Message, []service.Tool, *service.ChatOptions) (*service.LLMResponse, error) {
+	if err := f.next(); err != nil {
+		return nil, err
+	}
+	return &service.LLMResponse{Content: "ok", Usage: service.Usage{PromptTokens: 3, CompletionTokens: 2}}, nil
+}
+
+func (f *fakeProvider) ChatStream(context.Context, string, []service.Message, []service.Tool, *service.ChatOptions) (<-chan service.StreamChunk, http.Header, error) {
+	if err := f.next(); err != nil {
+		return nil, nil, err
+	}
+	ch := make(chan service.StreamChunk, 2)
+	ch <- service.StreamChunk{Content: "hi"}
+	ch <- service.StreamChunk{FinishReason: "stop", Usage: &service.Usage{PromptTokens: 5, CompletionTokens: 1}}
+	close(ch)
+	return ch, nil, nil
+}
+
+func (f *fakeProvider) Proxy(http.ResponseWriter, *http.Request, string) error { return nil }
+
+func rateLimited(retryAfter time.Duration) error {
+	return &service.RateLimitError{StatusCode: http.StatusTooManyRequests, RetryAfter: retryAfter, Provider: "openai"}
+}
+
+func newTestPool(t *testing.T, observe Observer, providers ...*fakeProvider) *Pool {
+	t.Helper()
+	members := make([]Member, len(providers))
+	for i, prov := range providers {
+		members[i] = Member{Name: string(rune('a' + i)), Provider: prov}
+	}
+	var opts []Option
+	if observe != nil {
+		opts = append(opts, WithObserver(observe))
+	}
+	p, err := New("openai", members, opts...)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return p
+}
+
+func TestChatSpreadsAcrossKeys(t *testing.T) {
+	a, b := &fakeProvider{}, &fakeProvider{}
+	p := newTestPool(t, nil, a, b)
+
+	for range 4 {
+		if _, err := p.Chat(context.Background(), "", nil, nil, nil); err != nil {
+			t.Fatalf("Chat: %v", err)
+		}
+	}
+	if a.calls != 2 || b.calls != 2 {
+		t.Errorf("calls a=%d b=%d, want 2/2", a.calls, b.calls)
+	}
+
+	usage := p.KeyUsage()
+	if usage[0].Requests != 2 || usage[0].PromptTokens != 6 || usage[0].CompletionTokens != 4 {
+		t.Errorf("usage[a] = %+v", usage[0])
+	}
+}
+
+func TestServedUsageCarriesKeyName(t *testing.T) {
+	p := newTestPool(t, nil, &fakeProvider{errs: []error{rateLimited(0)}}, &fakeProvider{})
+
+	resp, err := p.Chat(context.Background(), "", nil, nil, nil)
+	if err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+	if resp.Usage.APIKey != "b" {
+		t.Errorf("chat usage key = %q, want b", resp.Usage.APIKey)
+	}
+
+	ch, _, err := p.ChatStream(context.Background(), "", nil, nil, nil)
+	if err != nil {
+		t.Fatalf("ChatStream: %v", err)
+	}
+	var key string
+	for chunk := range ch {
+		if chunk.Usage != nil {
+			key = chunk.Usage.APIKey
+		}
+	}
+	if key != "b" {
+		t.Errorf("stream usage key = %q, want b (a is cooling down)", key)
+	}
+}
+
+func TestChatRotatesAndCoolsDownOnRateLimit(t *testing.T) {
+	a := &fakeProvider{errs: []error{rateLimited(time.Minute)}}
+	b := &fakeProvider{}
+
+	var outcomes []string
+	p := newTestPool(t, func(key, outcome string, _ *service.Usage) {
+		outcomes = append(outcomes, key+":"+outcome)
+	}, a, b)
+
+	// First call starts at a, is rate limited there and moves on to b.
+	if _, err := p.Chat(context.Background(), "", nil, nil, nil); err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+	// a is cooling down, so every following call goes to b.
+	for range 3 {
+		if _, err := p.Chat(context.Background(), "", nil, nil, nil); err != nil {
+			t.Fatalf("Chat: %v", err)
+		}
+	}
+	if a.calls != 1 || b.calls != 4 {
+		t.Errorf("calls a=%d b=%d, want 1/4", a.calls, b.calls)
+	}
+	if len(outcomes) != 5 || outcomes[0] != "a:rate_limited" || outcomes[1] != "b:ok" {
+		t.Errorf("outcomes = %v", outcomes)
+	}
+
+	usage := p.KeyUsage()
+	if usage[0].RateLimited != 1 || usage[0].CooldownUntil == nil || time.Until(*usage[0].CooldownUntil) < 50*time.Second {
+		t.Errorf("usage[a] = %+v", usage[0])
+	}
+}
+
+func TestChatAllKeysCoolingDown(t *testing.T) {
+	a := &fakeProvider{errs: []error{rateLimited(30 * time.Second)}}
+	b := &fakeProvider{errs: []error{rateLimited(20 * time.Second)}}
+	p := newTestPool(t, nil, a, b)
+
+	_, err := p.Chat(context.Background(), "", nil, nil, nil)
+	var rle *service.RateLimitError
+	if !errors.As(err, &rle) || rle.RetryAfter != 20*time.Second {
+		t.Fatalf("first call err = %v, want b's rate limit", err)
+	}
+
+	_, err = p.Chat(context.Background(), "", nil, nil, nil)
+	if !errors.As(err, &rle) || rle.RetryAfter <= 0 || rle.RetryAfter > 20*time.Second || rle.Message != "all API keys are cooling down" {
+		t.Fatalf("second call err = %v", err)
+	}
+	if a.calls != 1 || b.calls != 1 {
+		t.Errorf("calls a=%d b=%d, want no calls while cooling down", a.calls, b.calls)
+	}
+}
+
+func TestChatLocalLimiterMovesOnWithoutCooldown(t *testing.T) {
+	a := &fakeProvider{errs: []error{&ratelimit.Error{Reason: ratelimit.ReasonRPM}}}
+	b := &fakeProvider{}
+	p := newTestPool(t, nil, a, b)
+
+	if _, err := p.Chat(context.Background(), "", nil, nil, nil); err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+	if usage := p.KeyUsage(); usage[0].RateLimited != 1 || usage[0].CooldownUntil != nil {
+		t.Errorf("usage[a] = %+v", usage[0])
+	}
+}
+
+func TestChatOtherErrorsDoNotRotate(t *testing.T) {
+	a := &fakeProvider{errs: []error{errors.New("bad request")}}
+	b := &fakeProvider{}
+	p := newTestPool(t, nil, a, b)
+
+	if _, err := p.Chat(context.Background(), "", nil, nil, nil); err == nil || err.Error() != "bad request" {
+		t.Fatalf("err = %v", err)
+	}
+	if b.calls != 0 {
+		t.Errorf("b was called %d times", b.calls)
+	}
+	if usage := p.KeyUsage(); usage[0].Errors != 1 {
+		t.Errorf("usage[a] = %+v", usage[0])
+	}
+}
+
+func TestChatStreamRecordsUsageAtEnd(t *testing.T) {
+	a := &fakeProvider{errs: []error{rateLimited(0)}}
+	b := &fakeProvider{}
+	p := newTestPool(t, nil, a, b)
+
+	ch, _, err := p.ChatStream(context.Background(), "", nil, nil, nil)
+	if err != nil {
+		t.Fatalf("ChatStream: %v", err)
+	}
+	var content string
+	for chunk := range ch {
+		content += chunk.Content
+	}
+	if content != "hi" {
+		t.Errorf("content = %q", content)
+	}
+
+	usage := p.KeyUsage()
+	if usage[0].RateLimited != 1 || usage[0].CooldownUntil == nil {
+		t.Errorf("usage[a] = %+v, want default cooldown", usage[0])
+	}
+	if usage[1].Requests != 1 || usage[1].PromptTokens != 5 || usage[1].CompletionTokens != 1 {
+		t.Errorf("usage[b] = %+v", usage[1])
+	}
+}
+
+func TestUnsupportedOperation(t *testing.T) {
+	p := newTestPool(t, nil, &fakeProvider{})
+
+	if _, err := p.CreateEmbedding(context.Background(), service.EmbeddingRequest{}); !errors.Is(err, service.ErrUnsupportedOperation) {
+		t.Errorf("CreateEmbedding err = %v", err)
+	}
+	if _, err := p.CountTokens(context.Background(), "", nil, nil); !errors.Is(err, service.ErrTokenCountUnsupported) {
+		t.Errorf("CountTokens err = %v", err)
+	}
+	if usage := p.KeyUsage(); usage[0].Requests != 0 {
+		t.Errorf("unsupported calls were recorded: %+v", usage[0])
+	}
+}
+
+// chatOnlyProvider implements nothing beyond service.LLMProvider.
+type chatOnlyProvider struct{}
+
+func (chatOnlyProvider) Chat(context.Context, string, []service.Message, []service.Tool, *service.ChatOptions) (*service.LLMResponse, error) {
+	return &service.LLMResponse{Content: "ok", Finished: true}, nil
+}
+
+func TestCapabilitiesFollowMemberType(t *testing.T) {
+	chatOnly, err := New("minimax", []Member{{Name: "a", Provider: chatOnlyProvider{}}, {Name: "b", Provider: chatOnlyProvider{}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := service.As[service.LLMStreamProvider](chatOnly); ok {
+		t.Error("pool of non-streaming keys reports streaming")
+	}
+	if _, ok := service.As[service.EmbeddingProvider](chatOnly); ok {
+		t.Error("pool of chat-only keys reports embeddings")
+	}
+	if _, ok := service.As[service.VideoProvider](chatOnly); ok {
+		t.Error("pool of chat-only keys reports video")
+	}
+
+	streaming := newTestPool(t, nil, &fakeProvider{}, &fakeProvider{})
+	if _, ok := service.As[service.LLMStreamProvider](streaming); !ok {
+		t.Error("pool of streaming keys does not report streaming")
+	}
+}
+
+func TestNewRejectsDuplicateNames(t *testing.T) {
+	_, err := New("openai", []Member{{Name: "a", Provider: &fakeProvider{}}, {Name: "a", Provider: &fakeProvider{}}})
+	if err == nil {
+		t.Fatal("expected duplicate name error")
+	}
+}
+
+// fakeVideoProvider is a fakeProvider that also runs video jobs; its
+// job IDs name the provider so tests can see which key served a call.
+type fakeVideoProvider struct {
+	fakeProvider
+	name string
+}
+
+func (f *fakeVideoProvider) SubmitVideo(context.Context, service.VideoGenerateRequest) (*service.VideoJob, error) {
+	if err := f.next(); err != nil {
+		return nil, err
+	}
+	return &service.VideoJob{ID: "video_" + f.name, Status: service.VideoStatusQueued}, nil
+}
+
+func (f *fakeVideoProvider) GetVideo(_ context.Context, id string) (*service.VideoJob, error) {
+	if id != "video_"+f.name {
+		return nil, errors.New("video not found")
+	}
+	return &service.VideoJob{ID: id, Status: service.VideoStatusCompleted}, nil
+}
+
+func (f *fakeVideoProvider) CancelVideo(context.Context, string) error { return nil }
+
+func (f *fakeVideoProvider) DownloadVideo(context.Context, string) (io.ReadCloser, string, error) {
+	return io.NopCloser(strings.NewReader(f.name)), "video/mp4", nil
+}
+
+func TestVideoJobsStayOnSubmittingKey(t *testing.T) {
+	a := &fakeVideoProvider{fakeProvider: fakeProvider{errs: []error{rateLimited(time.Minute)}}, name: "a"}
+	b := &fakeVideoProvider{name: "b"}
+	p, err := New("openai", []Member{{Name: "a", Provider: a}, {Name: "b", Provider: b}})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	ctx := context.Background()
+
+	job, err := p.SubmitVideo(ctx, service.VideoGenerateRequest{Prompt: "x"})
+	if err != nil {
+		t.Fatalf("SubmitVideo: %v", err)
+	}
+	if job.ID != "b~video_b" {
+		t.Fatalf("job ID = %q, want b~video_b", job.ID)
+	}
+
+	// The next call would start at b; the job must still be polled on b.
+	got, err := p.GetVideo(ctx, job.ID)
+	if err != nil || got.ID != job.ID || got.Status != service.VideoStatusCompleted {
+		t.Fatalf("GetVideo = %+v, err=%v", got, err)
+	}
+	body, _, err := p.DownloadVideo(ctx, job.ID)
+	if err != nil {
+		t.Fatalf("DownloadVideo: %v", err)
+	}
+	data, _ := io.ReadAll(body)
+	if string(data) != "b" {
+		t.Errorf("downloaded from key %q, want b", data)
+	}
+
+	if _, err := p.GetVideo(ctx, "video_b"); err == nil {
+		t.Error("GetVideo accepted an ID without a key prefix")
+	}
+}
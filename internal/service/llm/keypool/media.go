@@ -0,0 +1,230 @@
+package keypool
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/rakunlabs/at/internal/service"
+)
+
+// ─── Media provider interfaces ───
+
+// GenerateImage implements service.ImageProvider.
+func (p *Pool) GenerateImage(ctx context.Context, req service.ImageGenerateRequest) (*service.ImageResponse, error) {
+	resp, k, err := call(ctx, p, func(prov service.LLMProvider) (*service.ImageResponse, error) {
+		ip, ok := prov.(service.ImageProvider)
+		if !ok {
+			return nil, fmt.Errorf("%w: image generation", service.ErrUnsupportedOperation)
+		}
+		return ip.GenerateImage(ctx, req)
+	})
+	if err != nil {
+		return nil, err
+	}
+	k.served(&resp.Usage, p.observe)
+	return resp, nil
+}
+
+// EditImage implements service.ImageProvider.
+func (p *Pool) EditImage(ctx context.Context, req service.ImageEditRequest) (*service.ImageResponse, error) {
+	resp, k, err := call(ctx, p, func(prov service.LLMProvider) (*service.ImageResponse, error) {
+		ip, ok := prov.(service.ImageProvider)
+		if !ok {
+			return nil, fmt.Errorf("%w: image edits", service.ErrUnsupportedOperation)
+		}
+		return ip.EditImage(ctx, req)
+	})
+	if err != nil {
+		return nil, err
+	}
+	k.served(&resp.Usage, p.observe)
+	return resp, nil
+}
+
+// CreateImageVariation implements service.ImageProvider.
+func (p *Pool) CreateImageVariation(ctx context.Context, req service.ImageVariationRequest) (*service.ImageResponse, error) {
+	resp, k, err := call(ctx, p, func(prov service.LLMProvider) (*service.ImageResponse, error) {
+		ip, ok := prov.(service.ImageProvider)
+		if !ok {
+			return nil, fmt.Errorf("%w: image variations", service.ErrUnsupportedOperation)
+		}
+		return ip.CreateImageVariation(ctx, req)
+	})
+	if err != nil {
+		return nil, err
+	}
+	k.served(&resp.Usage, p.observe)
+	return resp, nil
+}
+
+// GenerateAudio implements service.AudioProvider.
+func (p *Pool) GenerateAudio(ctx context.Context, req service.AudioGenerateRequest) (*service.AudioResponse, error) {
+	resp, k, err := call(ctx, p, func(prov service.LLMProvider) (*service.AudioResponse, error) {
+		ap, ok := prov.(service.AudioProvider)
+		if !ok {
+			return nil, fmt.Errorf("%w: speech generation", service.ErrUnsupportedOperation)
+		}
+		return ap.GenerateAudio(ctx, req)
+	})
+	if err != nil {
+		return nil, err
+	}
+	k.record(OutcomeOK, nil, p.observe)
+	return resp, nil
+}
+
+// TranscribeAudio implements service.AudioProvider.
+func (p *Pool) TranscribeAudio(ctx context.Context, req service.AudioTranscribeRequest) (*service.AudioTranscribeResponse, error) {
+	resp, k, err := call(ctx, p, func(prov service.LLMProvider) (*service.AudioTranscribeResponse, error) {
+		ap, ok := prov.(service.AudioProvider)
+		if !ok {
+			return nil, fmt.Errorf("%w: transcription", service.ErrUnsupportedOperation)
+		}
+		return ap.TranscribeAudio(ctx, req)
+	})
+	if err != nil {
+		return nil, err
+	}
+	k.record(OutcomeOK, nil, p.observe)
+	return resp, nil
+}
+
+// CreateEmbedding implements service.EmbeddingProvider.
+func (p *Pool) CreateEmbedding(ctx context.Context, req service.EmbeddingRequest) (*service.EmbeddingResponse, error) {
+	resp, k, err := call(ctx, p, func(prov service.LLMProvider) (*service.EmbeddingResponse, error) {
+		ep, ok := prov.(service.EmbeddingProvider)
+		if !ok {
+			return nil, fmt.Errorf("%w: embeddings", service.ErrUnsupportedOperation)
+		}
+		return ep.CreateEmbedding(ctx, req)
+	})
+	if err != nil {
+		return nil, err
+	}
+	k.served(&resp.Usage, p.observe)
+	return resp, nil
+}
+
+// Moderate implements service.ModerationProvider.
+func (p *Pool) Moderate(ctx context.Context, req service.ModerationRequest) (*service.ModerationResponse, error) {
+	resp, k, err := call(ctx, p, func(prov service.LLMProvider) (*service.ModerationResponse, error) {
+		mp, ok := prov.(service.ModerationProvider)
+		if !ok {
+			return nil, fmt.Errorf("%w: moderation", service.ErrUnsupportedOperation)
+		}
+		return mp.Moderate(ctx, req)
+	})
+	if err != nil {
+		return nil, err
+	}
+	k.record(OutcomeOK, nil, p.observe)
+	return resp, nil
+}
+
+// Rerank implements service.RerankProvider.
+func (p *Pool) Rerank(ctx context.Context, req service.RerankRequest) (*service.RerankResponse, error) {
+	resp, k, err := call(ctx, p, func(prov service.LLMProvider) (*service.RerankResponse, error) {
+		rp, ok := prov.(service.RerankProvider)
+		if !ok {
+			return nil, fmt.Errorf("%w: rerank", service.ErrUnsupportedOperation)
+		}
+		return rp.Rerank(ctx, req)
+	})
+	if err != nil {
+		return nil, err
+	}
+	k.served(&resp.Usage, p.observe)
+	return resp, nil
+}
+
+// ─── Video jobs ───
+//
+// A video job lives in the account of the key that submitted it, so the
+// pool prefixes job IDs with that key's name and sends every later call
+// for the job to the same key.
+
+// videoJobSep separates the key name from the upstream job ID.
+const videoJobSep = "~"
+
+// SubmitVideo implements service.VideoProvider.
+func (p *Pool) SubmitVideo(ctx context.Context, req service.VideoGenerateRequest) (*service.VideoJob, error) {
+	resp, k, err := call(ctx, p, func(prov service.LLMProvider) (*service.VideoJob, error) {
+		vp, ok := prov.(service.VideoProvider)
+		if !ok {
+			return nil, fmt.Errorf("%w: video generation", service.ErrUnsupportedOperation)
+		}
+		return vp.SubmitVideo(ctx, req)
+	})
+	if err != nil {
+		return nil, err
+	}
+	k.record(OutcomeOK, nil, p.observe)
+	job := *resp
+	job.ID = k.Name + videoJobSep + resp.ID
+	return &job, nil
+}
+
+// GetVideo implements service.VideoProvider.
+func (p *Pool) GetVideo(ctx context.Context, id string) (*service.VideoJob, error) {
+	k, vp, upstreamID, err := p.videoKey(id)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := vp.GetVideo(ctx, upstreamID)
+	if err != nil {
+		k.record(OutcomeError, nil, p.observe)
+		return nil, err
+	}
+	k.record(OutcomeOK, nil, p.observe)
+	job := *resp
+	job.ID = id
+	return &job, nil
+}
+
+// CancelVideo implements service.VideoProvider.
+func (p *Pool) CancelVideo(ctx context.Context, id string) error {
+	k, vp, upstreamID, err := p.videoKey(id)
+	if err != nil {
+		return err
+	}
+	if err := vp.CancelVideo(ctx, upstreamID); err != nil {
+		k.record(OutcomeError, nil, p.observe)
+		return err
+	}
+	k.record(OutcomeOK, nil, p.observe)
+	return nil
+}
+
+// DownloadVideo implements service.VideoProvider.
+func (p *Pool) DownloadVideo(ctx context.Context, id string) (io.ReadCloser, string, error) {
+	k, vp, upstreamID, err := p.videoKey(id)
+	if err != nil {
+		return nil, "", err
+	}
+	body, contentType, err := vp.DownloadVideo(ctx, upstreamID)
+	if err != nil {
+		k.record(OutcomeError, nil, p.observe)
+		return nil, "", err
+	}
+	k.record(OutcomeOK, nil, p.observe)
+	return body, contentType, nil
+}
+
+// videoKey resolves a pool job ID to the key that owns the job and the
+// upstream job ID.
+func (p *Pool) videoKey(id string) (*poolKey, service.VideoProvider, string, error) {
+	for _, k := range p.keys {
+		upstreamID, ok := strings.CutPrefix(id, k.Name+videoJobSep)
+		if !ok {
+			continue
+		}
+		vp, ok := k.Provider.(service.VideoProvider)
+		if !ok {
+			return nil, nil, "", fmt.Errorf("%w: video generation", service.ErrUnsupportedOperation)
+		}
+		return k, vp, upstreamID, nil
+	}
+	return nil, nil, "", fmt.Errorf("key pool %q: video job %q was not submitted through any of its keys", p.name, id)
+}
@@ -29,6 +29,7 @@ import (
 
 	"github.com/rakunlabs/at/internal/service"
 	antropic "github.com/rakunlabs/at/internal/service/llm/antropic"
+	"github.com/rakunlabs/at/internal/service/llm/common"
 )
 
 const (
@@ -129,6 +130,7 @@ func New(apiKey, model, baseURL, proxy string, insecureSkipVerify bool, extraHea
 		klient.WithHeaderSet(headers),
 		klient.WithDisableRetry(true),
 		klient.WithDisableEnvValues(true),
+		klient.WithInject(common.InjectTraceContext),
 	}
 	if proxy != "" {
 		klientOpts = append(klientOpts, klient.WithProxy(proxy))
@@ -261,6 +263,17 @@ func (p *Provider) GenerateImage(ctx context.Context, req service.ImageGenerateR
 	return &service.ImageResponse{Images: images}, nil
 }
 
+// EditImage implements service.ImageProvider. MiniMax has no image edit API.
+func (p *Provider) EditImage(_ context.Context, _ service.ImageEditRequest) (*service.ImageResponse, error) {
+	return nil, fmt.Errorf("minimax does not support image edits: %w", service.ErrUnsupportedOperation)
+}
+
+// CreateImageVariation implements service.ImageProvider. MiniMax has no
+// image variation API.
+func (p *Provider) CreateImageVariation(_ context.Context, _ service.ImageVariationRequest) (*service.ImageResponse, error) {
+	return nil, fmt.Errorf("minimax does not support image variations: %w", service.ErrUnsupportedOperation)
+}
+
 // sizeToAspectRatio converts OpenAI-style size strings to MiniMax aspect ratios.
 func sizeToAspectRatio(size string) string {
 	switch size {
@@ -0,0 +1,162 @@
+package ollama
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/rakunlabs/at/internal/service"
+)
+
+// ─── Embeddings ───
+
+type embedRequest struct {
+	Model      string   `json:"model"`
+	Input      []string `json:"input"`
+	Dimensions *int     `json:"dimensions,omitempty"`
+	KeepAlive  any      `json:"keep_alive,omitempty"`
+}
+
+type embedResponse struct {
+	Model           string      `json:"model"`
+	Embeddings      [][]float64 `json:"embeddings"`
+	PromptEvalCount int         `json:"prompt_eval_count"`
+}
+
+// CreateEmbedding implements service.EmbeddingProvider via /api/embed.
+// Ollama only returns floats; the gateway encodes base64 itself.
+func (p *Provider) CreateEmbedding(ctx context.Context, req service.EmbeddingRequest) (*service.EmbeddingResponse, error) {
+	model := req.Model
+	if model == "" {
+		model = p.model
+	}
+	body := embedRequest{
+		Model:      model,
+		Input:      req.Input,
+		Dimensions: req.Dimensions,
+	}
+	if p.keepAlive != "" {
+		body.KeepAlive = keepAliveValue(p.keepAlive)
+	}
+
+	var parsed embedResponse
+	if err := p.doJSON(ctx, http.MethodPost, "/api/embed", body, &parsed); err != nil {
+		return nil, err
+	}
+	return &service.EmbeddingResponse{
+		Embeddings: parsed.Embeddings,
+		Model:      model,
+		Usage: service.Usage{
+			PromptTokens: parsed.PromptEvalCount,
+			TotalTokens:  parsed.PromptEvalCount,
+		},
+	}, nil
+}
+
+// ─── Model management ───
+
+// ListModels implements service.ModelManager via /api/tags.
+func (p *Provider) ListModels(ctx context.Context) ([]string, error) {
+	var parsed struct {
+		Models []struct {
+			Name  string `json:"name"`
+			Model string `json:"model"`
+		} `json:"models"`
+	}
+	if err := p.doJSON(ctx, http.MethodGet, "/api/tags", nil, &parsed); err != nil {
+		return nil, err
+	}
+	models := make([]string, 0, len(parsed.Models))
+	for _, m := range parsed.Models {
+		name := m.Name
+		if name == "" {
+			name = m.Model
+		}
+		if name != "" {
+			models = append(models, name)
+		}
+	}
+	return models, nil
+}
+
+// PullModel implements service.ModelManager via /api/pull. Progress is
+// streamed as NDJSON; an {"error": ...} line aborts the pull.
+func (p *Provider) PullModel(ctx context.Context, model string, progress func(service.ModelPullProgress)) error {
+	if model == "" {
+		return fmt.Errorf("model is required")
+	}
+	body, err := json.Marshal(map[string]any{"model": model, "stream": true})
+	if err != nil {
+		return fmt.Errorf("marshal: %w", err)
+	}
+	resp, err := p.send(ctx, http.MethodPost, "/api/pull", body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	var last string
+	for scanner.Scan() {
+		var event struct {
+			service.ModelPullProgress
+			Error string `json:"error"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			continue
+		}
+		if event.Error != "" {
+			return fmt.Errorf("ollama pull %s: %s", model, event.Error)
+		}
+		last = event.Status
+		if progress != nil {
+			progress(event.ModelPullProgress)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("ollama pull %s: %w", model, err)
+	}
+	if last != "success" {
+		return fmt.Errorf("ollama pull %s: stream ended before success (last status %q)", model, last)
+	}
+	return nil
+}
+
+// DeleteModel implements service.ModelManager via /api/delete.
+func (p *Provider) DeleteModel(ctx context.Context, model string) error {
+	if model == "" {
+		return fmt.Errorf("model is required")
+	}
+	return p.doJSON(ctx, http.MethodDelete, "/api/delete", map[string]any{"model": model}, nil)
+}
+
+// doJSON wraps send for plain request/response calls.
+func (p *Provider) doJSON(ctx context.Context, method, path string, body any, out any) error {
+	var data []byte
+	if body != nil {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			return fmt.Errorf("marshal: %w", err)
+		}
+	}
+	resp, err := p.send(ctx, method, path, data)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("read body: %w", err)
+	}
+	if out != nil {
+		if err := json.Unmarshal(respBody, out); err != nil {
+			return fmt.Errorf("decode: %w (body: %s)", err, string(respBody))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,680 @@
+// Package ollama implements an LLM provider for Ollama's native API:
+// chat (`/api/chat`, NDJSON streaming), embeddings (`/api/embed`) and
+// model management (`/api/tags`, `/api/pull`, `/api/delete`).
+//
+// Ollama also serves an OpenAI-compatible endpoint, but only the native
+// API exposes keep_alive, thinking output, the installed model list and
+// model pulls.
+package ollama
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/oklog/ulid/v2"
+
+	"github.com/rakunlabs/at/internal/service"
+	"github.com/rakunlabs/at/internal/service/llm/common"
+	"github.com/rakunlabs/at/internal/service/ratelimit"
+)
+
+const defaultBaseURL = "http://localhost:11434"
+
+// Provider implements service.LLMProvider, LLMStreamProvider,
+// EmbeddingProvider and ModelManager.
+type Provider struct {
+	apiKey     string
+	model      string
+	baseURL    string
+	keepAlive  string
+	httpClient *http.Client
+	limiter    *ratelimit.Limiter
+}
+
+// Option mutates a Provider during construction.
+type Option func(*Provider)
+
+// WithRateLimiter attaches a per-provider rate limiter.
+func WithRateLimiter(l *ratelimit.Limiter) Option {
+	return func(p *Provider) { p.limiter = l }
+}
+
+// WithKeepAlive sets how long Ollama keeps a model loaded after a request:
+// a duration ("10m", "1h"), "0" to unload right away or "-1" to keep it
+// loaded. Empty leaves Ollama's default (5m).
+func WithKeepAlive(keepAlive string) Option {
+	return func(p *Provider) { p.keepAlive = keepAlive }
+}
+
+// New creates an Ollama provider. apiKey is optional and sent as a bearer
+// token for Ollama instances behind an authenticating proxy.
+func New(apiKey, model, baseURL, proxy string, insecureSkipVerify bool, opts ...Option) (*Provider, error) {
+	// No client timeout: local generation, model loads and pulls routinely
+	// run for minutes. Every request is bounded by its context instead.
+	httpClient := &http.Client{}
+	if proxy != "" || insecureSkipVerify {
+		t := http.DefaultTransport.(*http.Transport).Clone()
+		if proxy != "" {
+			u, err := url.Parse(proxy)
+			if err != nil {
+				return nil, fmt.Errorf("parse proxy URL: %w", err)
+			}
+			t.Proxy = http.ProxyURL(u)
+		}
+		if insecureSkipVerify {
+			if t.TLSClientConfig == nil {
+				t.TLSClientConfig = &tls.Config{} //nolint:gosec // operator opt-in
+			}
+			t.TLSClientConfig.InsecureSkipVerify = true
+		}
+		httpClient.Transport = t
+	}
+
+	p := &Provider{
+		apiKey:     apiKey,
+		model:      model,
+		baseURL:    normalizeBaseURL(baseURL),
+		httpClient: httpClient,
+	}
+	for _, o := range opts {
+		o(p)
+	}
+	return p, nil
+}
+
+// normalizeBaseURL accepts the server root as well as the URLs people
+// paste from the OpenAI-compatible setup (".../v1/chat/completions").
+func normalizeBaseURL(baseURL string) string {
+	if baseURL == "" {
+		return defaultBaseURL
+	}
+	baseURL = strings.TrimSuffix(baseURL, "/")
+	for _, suffix := range []string{"/v1/chat/completions", "/v1", "/api"} {
+		baseURL = strings.TrimSuffix(baseURL, suffix)
+	}
+	return baseURL
+}
+
+// send issues an authenticated JSON request and returns the response when
+// the status is 2xx. Failing responses are read, closed and turned into
+// errors; 429 and 503 (Ollama's "server busy" once OLLAMA_MAX_QUEUE is
+// full) become *service.RateLimitError.
+func (p *Provider) send(ctx context.Context, method, path string, body []byte) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, p.baseURL+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if p.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.apiKey)
+	}
+
+	common.InjectTraceContext(ctx, req)
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("ollama http: %w", err)
+	}
+	if resp.StatusCode < http.StatusBadRequest {
+		return resp, nil
+	}
+
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(resp.Body)
+	msg := string(respBody)
+	var apiErr struct {
+		Error string `json:"error"`
+	}
+	if json.Unmarshal(respBody, &apiErr) == nil && apiErr.Error != "" {
+		msg = apiErr.Error
+	}
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+		return nil, &service.RateLimitError{
+			StatusCode: resp.StatusCode,
+			RetryAfter: common.ParseRetryAfter(resp.Header),
+			Provider:   "ollama",
+			Message:    msg,
+			Underlying: fmt.Errorf("ollama %d: %s", resp.StatusCode, msg),
+		}
+	}
+	return nil, fmt.Errorf("ollama API error (status %d): %s", resp.StatusCode, msg)
+}
+
+// ─── Chat ───
+
+type chatMessage struct {
+	Role      string     `json:"role"`
+	Content   string     `json:"content"`
+	Images    []string   `json:"images,omitempty"`
+	ToolCalls []toolCall `json:"tool_calls,omitempty"`
+	ToolName  string     `json:"tool_name,omitempty"`
+}
+
+type toolCall struct {
+	ID       string `json:"id,omitempty"`
+	Function struct {
+		Name      string         `json:"name"`
+		Arguments map[string]any `json:"arguments"`
+	} `json:"function"`
+}
+
+type chatResponse struct {
+	Model   string `json:"model"`
+	Message struct {
+		Role      string     `json:"role"`
+		Content   string     `json:"content"`
+		Thinking  string     `json:"thinking"`
+		ToolCalls []toolCall `json:"tool_calls"`
+	} `json:"message"`
+	Done            bool   `json:"done"`
+	DoneReason      string `json:"done_reason"`
+	PromptEvalCount int    `json:"prompt_eval_count"`
+	EvalCount       int    `json:"eval_count"`
+	Error           string `json:"error"`
+}
+
+func (r *chatResponse) usage() service.Usage {
+	return service.Usage{
+		PromptTokens:     r.PromptEvalCount,
+		CompletionTokens: r.EvalCount,
+		TotalTokens:      r.PromptEvalCount + r.EvalCount,
+	}
+}
+
+// Chat implements service.LLMProvider.
+func (p *Provider) Chat(ctx context.Context, model string, messages []service.Message, tools []service.Tool, opts *service.ChatOptions) (*service.LLMResponse, error) {
+	if model == "" {
+		model = p.model
+	}
+	release, err := p.limiter.Acquire(ctx, common.EstimateInputTokens("", messages, tools))
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	jsonData, err := json.Marshal(p.buildRequestBody(model, messages, tools, opts, false))
+	if err != nil {
+		return nil, fmt.Errorf("marshal: %w", err)
+	}
+	resp, err := p.send(ctx, http.MethodPost, "/api/chat", jsonData)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read body: %w", err)
+	}
+	var parsed chatResponse
+	if err := json.Unmarshal(respBody, &parsed); err != nil {
+		return nil, fmt.Errorf("decode response: %w (body: %s)", err, string(respBody))
+	}
+	if parsed.Error != "" {
+		return nil, fmt.Errorf("ollama API error: %s", parsed.Error)
+	}
+
+	toolCalls := translateToolCalls(parsed.Message.ToolCalls)
+	return &service.LLMResponse{
+		Content:          parsed.Message.Content,
+		ReasoningContent: parsed.Message.Thinking,
+		ToolCalls:        toolCalls,
+		Finished:         len(toolCalls) == 0,
+		FinishReason:     finishReason(parsed.DoneReason, len(toolCalls) > 0),
+		Usage:            parsed.usage(),
+		Header:           resp.Header,
+	}, nil
+}
+
+// ChatStream implements service.LLMStreamProvider. Ollama streams NDJSON:
+// one chatResponse per line, with tool calls delivered complete and the
+// token counts on the final `done` line.
+func (p *Provider) ChatStream(ctx context.Context, model string, messages []service.Message, tools []service.Tool, opts *service.ChatOptions) (<-chan service.StreamChunk, http.Header, error) {
+	if model == "" {
+		model = p.model
+	}
+	release, err := p.limiter.Acquire(ctx, common.EstimateInputTokens("", messages, tools))
+	if err != nil {
+		return nil, nil, err
+	}
+	releaseOnce := func() {
+		if release != nil {
+			release()
+			release = nil
+		}
+	}
+
+	jsonData, err := json.Marshal(p.buildRequestBody(model, messages, tools, opts, true))
+	if err != nil {
+		releaseOnce()
+		return nil, nil, fmt.Errorf("marshal: %w", err)
+	}
+	resp, err := p.send(ctx, http.MethodPost, "/api/chat", jsonData)
+	if err != nil {
+		releaseOnce()
+		return nil, nil, err
+	}
+
+	ch := make(chan service.StreamChunk, 64)
+
+	go func() {
+		defer close(ch)
+		defer resp.Body.Close()
+		defer releaseOnce()
+
+		sawToolCalls := false
+		scanner := bufio.NewScanner(resp.Body)
+		scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+		for scanner.Scan() {
+			line := bytes.TrimSpace(scanner.Bytes())
+			if len(line) == 0 {
+				continue
+			}
+
+			var event chatResponse
+			if err := json.Unmarshal(line, &event); err != nil {
+				ch <- service.StreamChunk{Error: fmt.Errorf("ollama: parse stream line: %w", err)}
+				return
+			}
+			if event.Error != "" {
+				ch <- service.StreamChunk{Error: fmt.Errorf("ollama stream error: %s", event.Error)}
+				return
+			}
+
+			if event.Message.Thinking != "" {
+				ch <- service.StreamChunk{ReasoningContent: event.Message.Thinking}
+			}
+			if event.Message.Content != "" {
+				ch <- service.StreamChunk{Content: event.Message.Content}
+			}
+			if len(event.Message.ToolCalls) > 0 {
+				sawToolCalls = true
+				ch <- service.StreamChunk{ToolCalls: translateToolCalls(event.Message.ToolCalls)}
+			}
+
+			if event.Done {
+				usage := event.usage()
+				ch <- service.StreamChunk{
+					FinishReason: finishReason(event.DoneReason, sawToolCalls),
+					Usage:        &usage,
+				}
+				return
+			}
+		}
+
+		if err := scanner.Err(); err != nil {
+			ch <- service.StreamChunk{Error: fmt.Errorf("ollama: stream read: %w", err)}
+		}
+	}()
+
+	return ch, resp.Header, nil
+}
+
+// buildRequestBody creates the /api/chat body shared by Chat and ChatStream.
+func (p *Provider) buildRequestBody(model string, messages []service.Message, tools []service.Tool, opts *service.ChatOptions, stream bool) map[string]any {
+	reqBody := map[string]any{
+		"model":    model,
+		"messages": translateMessages(messages),
+		"stream":   stream,
+	}
+	if p.keepAlive != "" {
+		reqBody["keep_alive"] = keepAliveValue(p.keepAlive)
+	}
+
+	// Ollama has no tool_choice; "none" is honoured by not sending tools.
+	if len(tools) > 0 && (opts == nil || opts.ToolChoice != "none") {
+		ollamaTools := make([]map[string]any, 0, len(tools))
+		for _, t := range tools {
+			ollamaTools = append(ollamaTools, map[string]any{
+				"type": "function",
+				"function": map[string]any{
+					"name":        t.Name,
+					"description": t.Description,
+					"parameters":  service.SanitizeSchema(t.InputSchema),
+				},
+			})
+		}
+		reqBody["tools"] = ollamaTools
+	}
+
+	if opts == nil {
+		return reqBody
+	}
+
+	options := map[string]any{}
+	if opts.Temperature != nil {
+		options["temperature"] = *opts.Temperature
+	}
+	if opts.TopP != nil {
+		options["top_p"] = *opts.TopP
+	}
+	if opts.MaxCompletionTokens != nil {
+		options["num_predict"] = *opts.MaxCompletionTokens
+	} else if opts.MaxTokens != nil {
+		options["num_predict"] = *opts.MaxTokens
+	}
+	if len(opts.Stop) > 0 {
+		options["stop"] = opts.Stop
+	}
+	if opts.Seed != nil {
+		options["seed"] = *opts.Seed
+	}
+	if opts.PresencePenalty != nil {
+		options["presence_penalty"] = *opts.PresencePenalty
+	}
+	if opts.FrequencyPenalty != nil {
+		options["frequency_penalty"] = *opts.FrequencyPenalty
+	}
+	if len(options) > 0 {
+		reqBody["options"] = options
+	}
+
+	if format := translateResponseFormat(opts.ResponseFormat); format != nil {
+		reqBody["format"] = format
+	}
+
+	switch {
+	case opts.Thinking != nil:
+		reqBody["think"] = opts.Thinking.Type == "enabled"
+	case opts.ReasoningEffort == "none":
+		reqBody["think"] = false
+	case opts.ReasoningEffort != "":
+		reqBody["think"] = true
+	}
+
+	// extra_body is merged last so callers can set Ollama-native fields
+	// we don't map (options.num_ctx, a per-request keep_alive, …).
+	for k, v := range opts.ExtraBody {
+		reqBody[k] = v
+	}
+	return reqBody
+}
+
+// keepAliveValue sends bare integers as numbers (seconds; negative keeps
+// the model loaded) because Ollama only parses strings as durations.
+func keepAliveValue(v string) any {
+	if n, err := strconv.Atoi(v); err == nil {
+		return n
+	}
+	return v
+}
+
+// translateResponseFormat maps an OpenAI-style response_format to Ollama's
+// `format`: "json" for JSON mode, the schema itself for json_schema.
+func translateResponseFormat(rf map[string]any) any {
+	switch t, _ := rf["type"].(string); t {
+	case "json_object":
+		return "json"
+	case "json_schema":
+		if js, ok := rf["json_schema"].(map[string]any); ok {
+			if schema, ok := js["schema"].(map[string]any); ok {
+				return schema
+			}
+		}
+		return "json"
+	}
+	return nil
+}
+
+// translateMessages converts service messages into Ollama chat messages.
+// Everything is first normalised to the OpenAI wire shape (the form the
+// gateway passes through and ConvertContentBlocksToOpenAI produces), then
+// mapped field by field.
+func translateMessages(messages []service.Message) []chatMessage {
+	var openAIMessages []any
+	for _, msg := range messages {
+		switch c := msg.Content.(type) {
+		case map[string]any:
+			openAIMessages = append(openAIMessages, c)
+		case []service.ContentBlock:
+			for _, m := range common.ConvertContentBlocksToOpenAI(msg.Role, c) {
+				openAIMessages = append(openAIMessages, m)
+			}
+		default:
+			openAIMessages = append(openAIMessages, map[string]any{
+				"role":    msg.Role,
+				"content": msg.Content,
+			})
+		}
+	}
+	openAIMessages = common.RepairOpenAIToolPairs(openAIMessages)
+
+	// Ollama identifies tool results by function name, not call ID.
+	toolNames := map[string]string{}
+	out := make([]chatMessage, 0, len(openAIMessages))
+	for _, raw := range openAIMessages {
+		m, ok := raw.(map[string]any)
+		if !ok {
+			continue
+		}
+		cm := chatMessage{}
+		cm.Role, _ = m["role"].(string)
+		cm.Content, cm.Images = translateContent(m["content"])
+
+		for _, tc := range asSlice(m["tool_calls"]) {
+			tcMap, ok := tc.(map[string]any)
+			if !ok {
+				continue
+			}
+			var call toolCall
+			call.ID, _ = tcMap["id"].(string)
+			if fn, ok := tcMap["function"].(map[string]any); ok {
+				call.Function.Name, _ = fn["name"].(string)
+				switch args := fn["arguments"].(type) {
+				case string:
+					_ = json.Unmarshal([]byte(args), &call.Function.Arguments)
+				case map[string]any:
+					call.Function.Arguments = args
+				}
+			}
+			if call.Function.Arguments == nil {
+				call.Function.Arguments = map[string]any{}
+			}
+			if call.ID != "" {
+				toolNames[call.ID] = call.Function.Name
+			}
+			cm.ToolCalls = append(cm.ToolCalls, call)
+		}
+
+		if cm.Role == "tool" {
+			if id, _ := m["tool_call_id"].(string); id != "" {
+				cm.ToolName = toolNames[id]
+			}
+			if cm.ToolName == "" {
+				cm.ToolName, _ = m["name"].(string)
+			}
+		}
+		out = append(out, cm)
+	}
+	return out
+}
+
+// translateContent flattens OpenAI content (a string or a parts array)
+// into Ollama's text plus base64 images. Only data: URLs can be sent;
+// Ollama does not fetch remote images.
+func translateContent(content any) (string, []string) {
+	switch c := content.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return c, nil
+	case []any, []map[string]any:
+		var text strings.Builder
+		var images []string
+		for _, part := range asSlice(c) {
+			pm, ok := part.(map[string]any)
+			if !ok {
+				continue
+			}
+			switch pm["type"] {
+			case "text":
+				s, _ := pm["text"].(string)
+				text.WriteString(s)
+			case "image_url":
+				var u string
+				switch iu := pm["image_url"].(type) {
+				case map[string]any:
+					u, _ = iu["url"].(string)
+				case string:
+					u = iu
+				}
+				if _, data, ok := strings.Cut(u, ";base64,"); ok && strings.HasPrefix(u, "data:") {
+					images = append(images, data)
+				}
+			}
+		}
+		return text.String(), images
+	default:
+		return fmt.Sprint(c), nil
+	}
+}
+
+// asSlice returns v as []any, accepting the []map[string]any form the
+// content-block converter produces.
+func asSlice(v any) []any {
+	switch s := v.(type) {
+	case []any:
+		return s
+	case []map[string]any:
+		out := make([]any, len(s))
+		for i, m := range s {
+			out[i] = m
+		}
+		return out
+	}
+	return nil
+}
+
+// translateToolCalls converts Ollama tool calls, minting IDs when the
+// server did not send any (older Ollama versions never do).
+func translateToolCalls(calls []toolCall) []service.ToolCall {
+	if len(calls) == 0 {
+		return nil
+	}
+	out := make([]service.ToolCall, 0, len(calls))
+	for _, c := range calls {
+		id := c.ID
+		if id == "" {
+			id = "call_" + ulid.Make().String()
+		}
+		args := c.Function.Arguments
+		if args == nil {
+			args = map[string]any{}
+		}
+		out = append(out, service.ToolCall{ID: id, Name: c.Function.Name, Arguments: args})
+	}
+	return out
+}
+
+// finishReason maps Ollama's done_reason onto OpenAI's vocabulary. Ollama
+// reports "stop" after a tool call, so tool calls take precedence.
+func finishReason(doneReason string, hasToolCalls bool) string {
+	switch {
+	case hasToolCalls:
+		return "tool_calls"
+	case doneReason == "" || doneReason == "stop":
+		return "stop"
+	default:
+		return doneReason
+	}
+}
+
+// ─── Proxy ───
+
+// proxyAllowed lists the native endpoints the gateway proxy may reach.
+// Model management (pull, delete, create, copy, push) stays behind the
+// admin API; everything under /v1/ is Ollama's OpenAI-compatible API.
+var proxyAllowed = map[string]bool{
+	"/api/chat":     true,
+	"/api/generate": true,
+	"/api/embed":    true,
+	"/api/tags":     true,
+	"/api/show":     true,
+}
+
+// proxyPathAllowed reports whether the gateway may proxy urlPath.
+func proxyPathAllowed(urlPath string) bool {
+	clean := path.Clean("/" + urlPath)
+	return proxyAllowed[clean] || strings.HasPrefix(clean, "/v1/")
+}
+
+// Proxy forwards a raw HTTP request to the Ollama server. Paths outside
+// proxyAllowed and /v1/ are answered with 403.
+func (p *Provider) Proxy(w http.ResponseWriter, r *http.Request, urlPath string) error {
+	if !proxyPathAllowed(urlPath) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		_ = json.NewEncoder(w).Encode(map[string]any{"error": map[string]any{
+			"message": fmt.Sprintf("ollama endpoint %q is not available through the gateway", urlPath),
+			"type":    "permission_error",
+		}})
+		return nil
+	}
+
+	target := p.baseURL + path.Clean("/"+urlPath)
+	if r.URL.RawQuery != "" {
+		target += "?" + r.URL.RawQuery
+	}
+	req, err := http.NewRequestWithContext(r.Context(), r.Method, target, r.Body)
+	if err != nil {
+		return err
+	}
+	for k, v := range r.Header {
+		if strings.EqualFold(k, "authorization") || strings.EqualFold(k, "host") {
+			continue
+		}
+		req.Header[k] = v
+	}
+	if p.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.apiKey)
+	}
+
+	common.InjectTraceContext(req.Context(), req)
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	for k, vals := range resp.Header {
+		for _, v := range vals {
+			w.Header().Add(k, v)
+		}
+	}
+	w.WriteHeader(resp.StatusCode)
+
+	// Flush as we go so streamed chat and pull progress reach the client.
+	flusher, _ := w.(http.Flusher)
+	buf := make([]byte, 32*1024)
+	for {
+		n, readErr := resp.Body.Read(buf)
+		if n > 0 {
+			if _, err := w.Write(buf[:n]); err != nil {
+				slog.Warn("ollama proxy write failed", "error", err)
+				return nil
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+		if readErr != nil {
+			if readErr != io.EOF {
+				slog.Warn("ollama proxy copy failed", "error", readErr)
+			}
+			return nil
+		}
+	}
+}
@@ -0,0 +1,295 @@
+package ollama
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/rakunlabs/at/internal/service"
+)
+
+func newTestProvider(t *testing.T, handler http.HandlerFunc, opts ...Option) *Provider {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	p, err := New("", "llama3.2", srv.URL, "", false, opts...)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return p
+}
+
+func TestChatTranslatesRequestAndResponse(t *testing.T) {
+	t.Parallel()
+
+	var body map[string]any
+	p := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/chat" {
+			t.Errorf("path = %q", r.URL.Path)
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		_, _ = w.Write([]byte(`{"model":"llama3.2","message":{"role":"assistant","content":"","thinking":"hm",
+			"tool_calls":[{"function":{"name":"lookup","arguments":{"q":"x"}}}]},
+			"done":true,"done_reason":"stop","prompt_eval_count":12,"eval_count":5}`))
+	}, WithKeepAlive("-1"))
+
+	temp := 0.2
+	maxTokens := 64
+	resp, err := p.Chat(context.Background(), "", []service.Message{
+		{Role: "system", Content: "be brief"},
+		{Role: "assistant", Content: []service.ContentBlock{
+			{Type: "tool_use", ID: "call_1", Name: "lookup", Input: map[string]any{"q": "a"}},
+		}},
+		{Role: "user", Content: []service.ContentBlock{
+			{Type: "tool_result", ToolUseID: "call_1", Content: "found"},
+		}},
+		{Role: "user", Content: map[string]any{"role": "user", "content": []any{
+			map[string]any{"type": "text", "text": "what is this?"},
+			map[string]any{"type": "image_url", "image_url": map[string]any{"url": "data:image/png;base64,AAAA"}},
+		}}},
+	}, []service.Tool{{Name: "lookup", InputSchema: map[string]any{"type": "object"}}}, &service.ChatOptions{
+		Temperature:    &temp,
+		MaxTokens:      &maxTokens,
+		ResponseFormat: map[string]any{"type": "json_object"},
+	})
+	if err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+
+	if resp.ReasoningContent != "hm" || resp.Finished || resp.FinishReason != "tool_calls" {
+		t.Errorf("resp = %+v", resp)
+	}
+	if len(resp.ToolCalls) != 1 || resp.ToolCalls[0].Name != "lookup" || !strings.HasPrefix(resp.ToolCalls[0].ID, "call_") {
+		t.Errorf("tool calls = %+v", resp.ToolCalls)
+	}
+	if resp.Usage.PromptTokens != 12 || resp.Usage.CompletionTokens != 5 || resp.Usage.TotalTokens != 17 {
+		t.Errorf("usage = %+v", resp.Usage)
+	}
+
+	if body["stream"] != false || body["keep_alive"] != float64(-1) || body["format"] != "json" {
+		t.Errorf("body = %v", body)
+	}
+	if options, _ := body["options"].(map[string]any); options["temperature"] != 0.2 || options["num_predict"] != float64(64) {
+		t.Errorf("options = %v", body["options"])
+	}
+	msgs, _ := body["messages"].([]any)
+	if len(msgs) != 4 {
+		t.Fatalf("messages = %#v", body["messages"])
+	}
+	assistant := msgs[1].(map[string]any)
+	calls, _ := assistant["tool_calls"].([]any)
+	if len(calls) != 1 || !reflect.DeepEqual(calls[0].(map[string]any)["function"], map[string]any{"name": "lookup", "arguments": map[string]any{"q": "a"}}) {
+		t.Errorf("assistant = %v", assistant)
+	}
+	if tool := msgs[2].(map[string]any); tool["role"] != "tool" || tool["tool_name"] != "lookup" || tool["content"] != "found" {
+		t.Errorf("tool message = %v", tool)
+	}
+	if user := msgs[3].(map[string]any); user["content"] != "what is this?" || !reflect.DeepEqual(user["images"], []any{"AAAA"}) {
+		t.Errorf("user message = %v", user)
+	}
+}
+
+func TestChatStream(t *testing.T) {
+	t.Parallel()
+
+	p := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if body["stream"] != true {
+			t.Errorf("stream = %v", body["stream"])
+		}
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		for _, line := range []string{
+			`{"message":{"role":"assistant","content":"","thinking":"let me see"},"done":false}`,
+			`{"message":{"role":"assistant","content":"Hel"},"done":false}`,
+			`{"message":{"role":"assistant","content":"lo"},"done":false}`,
+			`{"message":{"role":"assistant","content":"","tool_calls":[{"id":"call_x","function":{"name":"lookup","arguments":{"q":"y"}}}]},"done":false}`,
+			`{"message":{"role":"assistant","content":""},"done":true,"done_reason":"stop","prompt_eval_count":7,"eval_count":3}`,
+		} {
+			_, _ = w.Write([]byte(line + "\n"))
+		}
+	})
+
+	ch, _, err := p.ChatStream(context.Background(), "", []service.Message{{Role: "user", Content: "hi"}}, nil, nil)
+	if err != nil {
+		t.Fatalf("ChatStream: %v", err)
+	}
+	var (
+		content, reasoning, finish string
+		toolCalls                  []service.ToolCall
+		usage                      *service.Usage
+	)
+	for chunk := range ch {
+		if chunk.Error != nil {
+			t.Fatalf("chunk error: %v", chunk.Error)
+		}
+		content += chunk.Content
+		reasoning += chunk.ReasoningContent
+		toolCalls = append(toolCalls, chunk.ToolCalls...)
+		if chunk.FinishReason != "" {
+			finish = chunk.FinishReason
+		}
+		if chunk.Usage != nil {
+			usage = chunk.Usage
+		}
+	}
+	if content != "Hello" || reasoning != "let me see" || finish != "tool_calls" {
+		t.Errorf("content=%q reasoning=%q finish=%q", content, reasoning, finish)
+	}
+	want := []service.ToolCall{{ID: "call_x", Name: "lookup", Arguments: map[string]any{"q": "y"}}}
+	if !reflect.DeepEqual(toolCalls, want) {
+		t.Errorf("tool calls = %#v", toolCalls)
+	}
+	if usage == nil || usage.PromptTokens != 7 || usage.CompletionTokens != 3 {
+		t.Errorf("usage = %+v", usage)
+	}
+}
+
+func TestChatServerBusyIsRateLimit(t *testing.T) {
+	t.Parallel()
+
+	p := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write([]byte(`{"error":"server busy, please try again.  maximum pending requests exceeded"}`))
+	})
+	_, err := p.Chat(context.Background(), "", []service.Message{{Role: "user", Content: "hi"}}, nil, nil)
+	var rle *service.RateLimitError
+	if !errors.As(err, &rle) || rle.Provider != "ollama" || !strings.Contains(rle.Message, "server busy") {
+		t.Fatalf("err = %v, want *service.RateLimitError", err)
+	}
+}
+
+func TestCreateEmbedding(t *testing.T) {
+	t.Parallel()
+
+	p := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/embed" {
+			t.Errorf("path = %q", r.URL.Path)
+		}
+		var body embedRequest
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if body.Model != "nomic-embed-text" || !reflect.DeepEqual(body.Input, []string{"a", "b"}) {
+			t.Errorf("body = %+v", body)
+		}
+		_, _ = w.Write([]byte(`{"model":"nomic-embed-text","embeddings":[[0.1,0.2],[0.3,0.4]],"prompt_eval_count":4}`))
+	})
+	resp, err := p.CreateEmbedding(context.Background(), service.EmbeddingRequest{Model: "nomic-embed-text", Input: []string{"a", "b"}})
+	if err != nil {
+		t.Fatalf("CreateEmbedding: %v", err)
+	}
+	if !reflect.DeepEqual(resp.Embeddings, [][]float64{{0.1, 0.2}, {0.3, 0.4}}) || resp.Usage.PromptTokens != 4 {
+		t.Errorf("resp = %+v", resp)
+	}
+}
+
+func TestModelManagement(t *testing.T) {
+	t.Parallel()
+
+	var deleted string
+	p := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/tags":
+			_, _ = w.Write([]byte(`{"models":[{"name":"llama3.2:latest"},{"name":"nomic-embed-text:latest"}]}`))
+		case "/api/pull":
+			var body map[string]any
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			if body["model"] == "missing" {
+				_, _ = w.Write([]byte(`{"status":"pulling manifest"}` + "\n" + `{"error":"pull model manifest: file does not exist"}` + "\n"))
+				return
+			}
+			_, _ = w.Write([]byte(`{"status":"pulling manifest"}` + "\n" +
+				`{"status":"pulling abc","digest":"sha256:abc","total":100,"completed":50}` + "\n" +
+				`{"status":"success"}` + "\n"))
+		case "/api/delete":
+			if r.Method != http.MethodDelete {
+				t.Errorf("method = %s", r.Method)
+			}
+			var body map[string]string
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			deleted = body["model"]
+			if deleted == "gone" {
+				w.WriteHeader(http.StatusNotFound)
+				_, _ = w.Write([]byte(`{"error":"model 'gone' not found"}`))
+			}
+		default:
+			t.Errorf("unexpected path %q", r.URL.Path)
+		}
+	})
+	ctx := context.Background()
+
+	models, err := p.ListModels(ctx)
+	if err != nil || !reflect.DeepEqual(models, []string{"llama3.2:latest", "nomic-embed-text:latest"}) {
+		t.Fatalf("ListModels = %v, %v", models, err)
+	}
+
+	var updates []service.ModelPullProgress
+	if err := p.PullModel(ctx, "llama3.2", func(u service.ModelPullProgress) { updates = append(updates, u) }); err != nil {
+		t.Fatalf("PullModel: %v", err)
+	}
+	if len(updates) != 3 || updates[1].Completed != 50 || updates[1].Total != 100 || updates[2].Status != "success" {
+		t.Errorf("updates = %+v", updates)
+	}
+	if err := p.PullModel(ctx, "missing", nil); err == nil || !strings.Contains(err.Error(), "file does not exist") {
+		t.Errorf("PullModel(missing) err = %v", err)
+	}
+
+	if err := p.DeleteModel(ctx, "llama3.2"); err != nil || deleted != "llama3.2" {
+		t.Errorf("DeleteModel = %v (deleted %q)", err, deleted)
+	}
+	if err := p.DeleteModel(ctx, "gone"); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("DeleteModel(gone) err = %v", err)
+	}
+}
+
+func TestProxyAllowsOnlyInferenceEndpoints(t *testing.T) {
+	t.Parallel()
+
+	var hits []string
+	p := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		hits = append(hits, r.URL.Path)
+		_, _ = w.Write([]byte(`{}`))
+	})
+
+	for path, want := range map[string]int{
+		"/api/chat":              http.StatusOK,
+		"/api/tags":              http.StatusOK,
+		"/v1/chat/completions":   http.StatusOK,
+		"/api/pull":              http.StatusForbidden,
+		"/api/delete":            http.StatusForbidden,
+		"/api/create":            http.StatusForbidden,
+		"/v1/../api/pull":        http.StatusForbidden,
+		"/api/chat/../../api/ps": http.StatusForbidden,
+	} {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/gateway/proxy", strings.NewReader(`{}`))
+		if err := p.Proxy(rec, req, path); err != nil {
+			t.Fatalf("Proxy(%s): %v", path, err)
+		}
+		if rec.Code != want {
+			t.Errorf("Proxy(%s) status = %d, want %d", path, rec.Code, want)
+		}
+	}
+	for _, hit := range hits {
+		if !proxyPathAllowed(hit) {
+			t.Errorf("blocked path %q reached the server", hit)
+		}
+	}
+}
+
+func TestNormalizeBaseURL(t *testing.T) {
+	for in, want := range map[string]string{
+		"":                     "http://localhost:11434",
+		"http://gpu:11434/":    "http://gpu:11434",
+		"http://gpu:11434/api": "http://gpu:11434",
+		"http://localhost:11434/v1/chat/completions": "http://localhost:11434",
+	} {
+		if got := normalizeBaseURL(in); got != want {
+			t.Errorf("normalizeBaseURL(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
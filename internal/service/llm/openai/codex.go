@@ -218,6 +218,7 @@ func (p *CodexProvider) ChatStream(ctx context.Context, model string, messages [
 		if err := p.authorize(ctx, req); err != nil {
 			return nil, err
 		}
+		common.InjectTraceContext(ctx, req)
 		return p.httpClient.Do(req)
 	}
 
@@ -269,6 +270,7 @@ func (p *CodexProvider) Models(ctx context.Context) ([]string, error) {
 		if err := p.authorize(ctx, req); err != nil {
 			return nil, err
 		}
+		common.InjectTraceContext(ctx, req)
 		return p.httpClient.Do(req)
 	}
 	resp, err := send()
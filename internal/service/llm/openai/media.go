@@ -6,7 +6,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"mime/multipart"
 	"net/http"
+	"net/textproto"
+	"strconv"
 	"strings"
 
 	"github.com/rakunlabs/at/internal/service"
@@ -131,13 +134,42 @@ type imagesRequest struct {
 	ResponseFormat string `json:"response_format,omitempty"`
 }
 
-// imagesResponse is the OpenAI images/generations API response.
+// imagesResponse is the OpenAI images API response, shared by
+// generations, edits and variations. Usage is only reported by the
+// gpt-image models.
 type imagesResponse struct {
 	Data []struct {
 		URL           string `json:"url"`
 		B64JSON       string `json:"b64_json"`
 		RevisedPrompt string `json:"revised_prompt"`
 	} `json:"data"`
+	Usage *struct {
+		InputTokens  int `json:"input_tokens"`
+		OutputTokens int `json:"output_tokens"`
+		TotalTokens  int `json:"total_tokens"`
+	} `json:"usage"`
+}
+
+// toService converts the API response to a service.ImageResponse.
+func (r *imagesResponse) toService() *service.ImageResponse {
+	images := make([]service.GeneratedImage, len(r.Data))
+	for i, img := range r.Data {
+		images[i] = service.GeneratedImage{
+			URL:           img.URL,
+			Base64:        img.B64JSON,
+			RevisedPrompt: img.RevisedPrompt,
+		}
+	}
+
+	out := &service.ImageResponse{Images: images}
+	if r.Usage != nil {
+		out.Usage = service.Usage{
+			PromptTokens:     r.Usage.InputTokens,
+			CompletionTokens: r.Usage.OutputTokens,
+			TotalTokens:      r.Usage.TotalTokens,
+		}
+	}
+	return out
 }
 
 // GenerateImage implements service.ImageProvider.
@@ -169,16 +201,163 @@ func (p *Provider) GenerateImage(ctx context.Context, req service.ImageGenerateR
 		return nil, fmt.Errorf("generate image: %w", err)
 	}
 
-	images := make([]service.GeneratedImage, len(apiResp.Data))
-	for i, img := range apiResp.Data {
-		images[i] = service.GeneratedImage{
-			URL:           img.URL,
-			Base64:        img.B64JSON,
-			RevisedPrompt: img.RevisedPrompt,
+	return apiResp.toService(), nil
+}
+
+// EditImage implements service.ImageProvider via the multipart
+// images/edits endpoint. Several source images are sent as "image[]",
+// which only the gpt-image models accept.
+func (p *Provider) EditImage(ctx context.Context, req service.ImageEditRequest) (*service.ImageResponse, error) {
+	if len(req.Images) == 0 {
+		return nil, fmt.Errorf("edit image: at least one image is required")
+	}
+
+	var buf bytes.Buffer
+	writer := newMultipartWriter(&buf)
+
+	field := "image"
+	if len(req.Images) > 1 {
+		field = "image[]"
+	}
+	for i, img := range req.Images {
+		if err := writeImagePart(writer, field, fmt.Sprintf("image-%d", i), img); err != nil {
+			return nil, fmt.Errorf("edit image: %w", err)
+		}
+	}
+	if req.Mask != nil {
+		if err := writeImagePart(writer, "mask", "mask", *req.Mask); err != nil {
+			return nil, fmt.Errorf("edit image: %w", err)
+		}
+	}
+
+	model := req.Model
+	if model == "" {
+		model = "dall-e-2"
+	}
+	_ = writer.WriteField("prompt", req.Prompt)
+	_ = writer.WriteField("model", model)
+	if req.N > 0 {
+		_ = writer.WriteField("n", strconv.Itoa(req.N))
+	}
+	if req.Size != "" {
+		_ = writer.WriteField("size", req.Size)
+	}
+	if req.Quality != "" {
+		_ = writer.WriteField("quality", req.Quality)
+	}
+
+	var apiResp imagesResponse
+	if err := p.doMultipart(ctx, p.apiURL("/images/edits"), writer, &buf, &apiResp); err != nil {
+		return nil, fmt.Errorf("edit image: %w", err)
+	}
+
+	return apiResp.toService(), nil
+}
+
+// CreateImageVariation implements service.ImageProvider via the multipart
+// images/variations endpoint (dall-e-2 only).
+func (p *Provider) CreateImageVariation(ctx context.Context, req service.ImageVariationRequest) (*service.ImageResponse, error) {
+	var buf bytes.Buffer
+	writer := newMultipartWriter(&buf)
+
+	if err := writeImagePart(writer, "image", "image", req.Image); err != nil {
+		return nil, fmt.Errorf("image variation: %w", err)
+	}
+
+	model := req.Model
+	if model == "" {
+		model = "dall-e-2"
+	}
+	_ = writer.WriteField("model", model)
+	if req.N > 0 {
+		_ = writer.WriteField("n", strconv.Itoa(req.N))
+	}
+	if req.Size != "" {
+		_ = writer.WriteField("size", req.Size)
+	}
+
+	var apiResp imagesResponse
+	if err := p.doMultipart(ctx, p.apiURL("/images/variations"), writer, &buf, &apiResp); err != nil {
+		return nil, fmt.Errorf("image variation: %w", err)
+	}
+
+	return apiResp.toService(), nil
+}
+
+// writeImagePart decodes img and adds it to writer as a file part. The
+// part keeps the image's content type; the images API rejects
+// application/octet-stream uploads.
+func writeImagePart(writer *multipart.Writer, field, name string, img service.ImageInput) error {
+	data, err := decodeBase64(img.Base64)
+	if err != nil {
+		return fmt.Errorf("decode %s base64: %w", field, err)
+	}
+
+	contentType := img.ContentType
+	if contentType == "" {
+		contentType = http.DetectContentType(data)
+	}
+	filename := img.Filename
+	if filename == "" {
+		filename = name + imageExtensionFromContentType(contentType)
+	}
+
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", fmt.Sprintf(`form-data; name=%q; filename=%q`, field, filename))
+	header.Set("Content-Type", contentType)
+	part, err := writer.CreatePart(header)
+	if err != nil {
+		return fmt.Errorf("create %s part: %w", field, err)
+	}
+	if _, err := part.Write(data); err != nil {
+		return fmt.Errorf("write %s: %w", field, err)
+	}
+	return nil
+}
+
+// doMultipart closes writer, posts the form in body and decodes the JSON
+// response.
+func (p *Provider) doMultipart(ctx context.Context, url string, writer *multipart.Writer, body *bytes.Buffer, result any) error {
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("close multipart: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, body)
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	if p.APIKey != "" {
+		token := p.APIKey
+		if p.tokenSource != nil {
+			t, err := p.tokenSource.Token(ctx)
+			if err != nil {
+				return fmt.Errorf("token source: %w", err)
+			}
+			token = t
 		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := p.client.HTTP.Do(req)
+	if err != nil {
+		return fmt.Errorf("http request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("read response: %w", err)
 	}
 
-	return &service.ImageResponse{Images: images}, nil
+	if resp.StatusCode >= 400 {
+		return mediaAPIError(resp, respBody)
+	}
+
+	if err := json.Unmarshal(respBody, result); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	return nil
 }
 
 // ─── Text-to-Speech ───
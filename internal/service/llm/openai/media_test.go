@@ -5,6 +5,7 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"errors"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -179,3 +180,165 @@ func TestTranscribeAudioRateLimit(t *testing.T) {
 		t.Errorf("Message = %q, want quota exhausted", rateLimitErr.Message)
 	}
 }
+
+func TestEditImageSendsMultipart(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/images/edits" {
+			t.Errorf("path = %q, want /v1/images/edits", r.URL.Path)
+		}
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			t.Fatalf("ParseMultipartForm: %v", err)
+		}
+		if r.FormValue("prompt") != "add a hat" || r.FormValue("model") != "gpt-image-1" || r.FormValue("n") != "2" {
+			t.Errorf("fields = %v", r.MultipartForm.Value)
+		}
+		if got := len(r.MultipartForm.File["image[]"]); got != 2 {
+			t.Errorf("image[] parts = %d, want 2", got)
+		}
+		masks := r.MultipartForm.File["mask"]
+		if len(masks) != 1 || masks[0].Header.Get("Content-Type") != "image/png" || masks[0].Filename != "mask.png" {
+			t.Errorf("mask = %+v", masks)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"data":[{"b64_json":"AAA="},{"b64_json":"BBB="}],"usage":{"input_tokens":50,"output_tokens":20,"total_tokens":70}}`))
+	}))
+	defer server.Close()
+
+	provider, err := New("test-key", "unused", server.URL+"/v1/chat/completions", "", false, nil)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	png := base64.StdEncoding.EncodeToString([]byte("\x89PNG\r\n\x1a\nfake"))
+	resp, err := provider.EditImage(context.Background(), service.ImageEditRequest{
+		Images: []service.ImageInput{
+			{Base64: png, ContentType: "image/png", Filename: "a.png"},
+			{Base64: "data:image/png;base64," + png},
+		},
+		Mask:   &service.ImageInput{Base64: png},
+		Prompt: "add a hat",
+		Model:  "gpt-image-1",
+		N:      2,
+	})
+	if err != nil {
+		t.Fatalf("EditImage: %v", err)
+	}
+	if len(resp.Images) != 2 || resp.Images[1].Base64 != "BBB=" {
+		t.Errorf("images = %+v", resp.Images)
+	}
+	if resp.Usage.PromptTokens != 50 || resp.Usage.CompletionTokens != 20 || resp.Usage.TotalTokens != 70 {
+		t.Errorf("usage = %+v", resp.Usage)
+	}
+}
+
+func TestCreateImageVariation(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/images/variations" {
+			t.Errorf("path = %q, want /v1/images/variations", r.URL.Path)
+		}
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			t.Fatalf("ParseMultipartForm: %v", err)
+		}
+		if r.FormValue("model") != "dall-e-2" || r.FormValue("size") != "512x512" || r.FormValue("prompt") != "" {
+			t.Errorf("fields = %v", r.MultipartForm.Value)
+		}
+		if _, _, err := r.FormFile("image"); err != nil {
+			t.Errorf("FormFile(image): %v", err)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"data":[{"url":"https://example.com/v.png"}]}`))
+	}))
+	defer server.Close()
+
+	provider, err := New("test-key", "unused", server.URL+"/v1/chat/completions", "", false, nil)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	resp, err := provider.CreateImageVariation(context.Background(), service.ImageVariationRequest{
+		Image: service.ImageInput{Base64: base64.StdEncoding.EncodeToString([]byte("fake png")), ContentType: "image/png"},
+		Size:  "512x512",
+	})
+	if err != nil {
+		t.Fatalf("CreateImageVariation: %v", err)
+	}
+	if len(resp.Images) != 1 || resp.Images[0].URL != "https://example.com/v.png" {
+		t.Errorf("images = %+v", resp.Images)
+	}
+}
+
+func TestVideoLifecycle(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/v1/videos":
+			if err := r.ParseMultipartForm(1 << 20); err != nil {
+				t.Fatalf("ParseMultipartForm: %v", err)
+			}
+			if r.FormValue("model") != "sora-2" || r.FormValue("prompt") != "a cat" || r.FormValue("seconds") != "4" || r.FormValue("size") != "720x1280" {
+				t.Errorf("fields = %v", r.MultipartForm.Value)
+			}
+			if _, _, err := r.FormFile("input_reference"); err != nil {
+				t.Errorf("FormFile(input_reference): %v", err)
+			}
+			_, _ = w.Write([]byte(`{"id":"video_1","object":"video","model":"sora-2","status":"queued","progress":0,"seconds":"4","size":"720x1280"}`))
+		case r.Method == http.MethodGet && r.URL.Path == "/v1/videos/video_1":
+			_, _ = w.Write([]byte(`{"id":"video_1","status":"failed","progress":40,"seconds":"4","error":{"code":"moderation_blocked","message":"blocked"}}`))
+		case r.Method == http.MethodGet && r.URL.Path == "/v1/videos/video_1/content":
+			w.Header().Set("Content-Type", "application/octet-stream")
+			_, _ = w.Write([]byte("mp4 bytes"))
+		case r.Method == http.MethodDelete && r.URL.Path == "/v1/videos/video_1":
+			_, _ = w.Write([]byte(`{"id":"video_1","deleted":true}`))
+		default:
+			t.Errorf("unexpected %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	provider, err := New("test-key", "unused", server.URL+"/v1/chat/completions", "", false, nil)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	ctx := context.Background()
+
+	job, err := provider.SubmitVideo(ctx, service.VideoGenerateRequest{
+		Prompt:     "a cat",
+		Seconds:    4,
+		Size:       "720x1280",
+		InputImage: &service.ImageInput{Base64: base64.StdEncoding.EncodeToString([]byte("fake png")), ContentType: "image/png"},
+	})
+	if err != nil {
+		t.Fatalf("SubmitVideo: %v", err)
+	}
+	if job.ID != "video_1" || job.Status != service.VideoStatusQueued || job.Seconds != 4 {
+		t.Errorf("submitted job = %+v", job)
+	}
+
+	job, err = provider.GetVideo(ctx, "video_1")
+	if err != nil {
+		t.Fatalf("GetVideo: %v", err)
+	}
+	if job.Status != service.VideoStatusFailed || job.Progress != 40 || job.Error != "blocked" {
+		t.Errorf("polled job = %+v", job)
+	}
+
+	body, contentType, err := provider.DownloadVideo(ctx, "video_1")
+	if err != nil {
+		t.Fatalf("DownloadVideo: %v", err)
+	}
+	data, _ := io.ReadAll(body)
+	body.Close()
+	if string(data) != "mp4 bytes" || contentType != "video/mp4" {
+		t.Errorf("download = %q (%s)", data, contentType)
+	}
+
+	if err := provider.CancelVideo(ctx, "video_1"); err != nil {
+		t.Fatalf("CancelVideo: %v", err)
+	}
+}
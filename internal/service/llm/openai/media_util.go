@@ -53,3 +53,17 @@ func extensionFromContentType(ct string) string {
 		return ".bin"
 	}
 }
+
+// imageExtensionFromContentType returns a file extension for an image MIME type.
+func imageExtensionFromContentType(ct string) string {
+	switch {
+	case strings.Contains(ct, "jpeg"), strings.Contains(ct, "jpg"):
+		return ".jpg"
+	case strings.Contains(ct, "webp"):
+		return ".webp"
+	case strings.Contains(ct, "gif"):
+		return ".gif"
+	default:
+		return ".png"
+	}
+}
@@ -33,6 +33,10 @@ type Provider struct {
 	// limiter is shared by all callers of this provider; nil means no
 	// rate limiting.
 	limiter *ratelimit.Limiter
+
+	// toolEmulation, when set, replaces native tool calling with
+	// prompt-based emulation for the configured models.
+	toolEmulation *common.ToolEmulation
 }
 
 // Option configures the Provider.
@@ -56,6 +60,15 @@ func WithRateLimiter(l *ratelimit.Limiter) Option {
 	}
 }
 
+// WithToolEmulation enables prompt-based tool calling for models served
+// without native function calling. Tools are described in the system
+// prompt and tool-call blocks in the reply are returned as ToolCalls.
+func WithToolEmulation(e *common.ToolEmulation) Option {
+	return func(p *Provider) {
+		p.toolEmulation = e
+	}
+}
+
 // New creates an OpenAI-compatible provider.
 //
 // extraHeaders allows setting additional HTTP headers for providers that
@@ -82,6 +95,7 @@ func New(apiKey, model, baseURL, proxy string, insecureSkipVerify bool, extraHea
 		klient.WithHeaderSet(headers),
 		klient.WithDisableRetry(true),
 		klient.WithDisableEnvValues(true),
+		klient.WithInject(common.InjectTraceContext),
 	}
 	if proxy != "" {
 		klientOpts = append(klientOpts, klient.WithProxy(proxy))
@@ -294,21 +308,51 @@ func (p *Provider) Chat(ctx context.Context, model string, messages []service.Me
 		return nil, fmt.Errorf("no response choices from provider")
 	}
 
-	choice := result.Choices[0]
-	llmResp := &service.LLMResponse{
-		Content:           choice.Message.Content,
-		ReasoningContent:  choice.Message.ReasoningContent,
-		Finished:          choice.FinishReason != "tool_calls",
-		FinishReason:      choice.FinishReason,
-		Header:            headers,
-		SystemFingerprint: result.SystemFingerprint,
-		Logprobs:          choice.Logprobs,
+	llmResp, err := p.choiceResponse(model, tools, result.Choices[0])
+	if err != nil {
+		return nil, err
 	}
-
+	llmResp.Header = headers
+	llmResp.SystemFingerprint = result.SystemFingerprint
 	if result.Usage != nil {
 		llmResp.Usage = openAIServiceUsage(result.Usage)
 	}
 
+	// n > 1: the remaining choices ride along on the first.
+	for _, choice := range result.Choices[1:] {
+		extra, err := p.choiceResponse(model, tools, choice)
+		if err != nil {
+			return nil, err
+		}
+		llmResp.ExtraChoices = append(llmResp.ExtraChoices, *extra)
+	}
+
+	return llmResp, nil
+}
+
+// NativeChoices implements service.NativeChoicesProvider: `n` is part of
+// the chat completions API.
+func (p *Provider) NativeChoices() bool { return true }
+
+// choiceResponse converts one response choice.
+func (p *Provider) choiceResponse(model string, tools []service.Tool, choice Choice) (*service.LLMResponse, error) {
+	// Emulated tool calls arrive as blocks in the reply text.
+	var emulatedCalls []service.ToolCall
+	if p.toolEmulation.Enabled(model) && len(tools) > 0 {
+		choice.Message.Content, emulatedCalls = common.ParseEmulatedToolCalls(choice.Message.Content)
+		if len(emulatedCalls) > 0 {
+			choice.FinishReason = "tool_calls"
+		}
+	}
+
+	llmResp := &service.LLMResponse{
+		Content:          choice.Message.Content,
+		ReasoningContent: choice.Message.ReasoningContent,
+		Finished:         choice.FinishReason != "tool_calls",
+		FinishReason:     choice.FinishReason,
+		Logprobs:         choice.Logprobs,
+	}
+
 	for _, tc := range choice.Message.ToolCalls {
 		var args map[string]any
 		if err := json.Unmarshal([]byte(tc.Function.Arguments), &args); err != nil {
@@ -321,6 +365,7 @@ func (p *Provider) Chat(ctx context.Context, model string, messages []service.Me
 			Arguments: args,
 		})
 	}
+	llmResp.ToolCalls = append(llmResp.ToolCalls, emulatedCalls...)
 
 	return llmResp, nil
 }
@@ -569,6 +614,10 @@ func (p *Provider) ChatStream(ctx context.Context, model string, messages []serv
 		}
 	}()
 
+	if p.toolEmulation.Enabled(model) && len(tools) > 0 {
+		return common.EmulateToolCallStream(ch), resp.Header, nil
+	}
+
 	return ch, resp.Header, nil
 }
 
@@ -695,6 +744,17 @@ func (p *Provider) buildRequestBody(model string, messages []service.Message, to
 	// rejects with "tool id (call_xxxx) not found".
 	reqMessages = common.RepairOpenAIToolPairs(reqMessages)
 
+	// Models without native tool calling get the tools in the prompt and
+	// a history without tool_calls / tool messages.
+	emulateTools := p.toolEmulation.Enabled(model)
+	if emulateTools {
+		if opts != nil && opts.ToolChoice == "none" {
+			tools = nil
+		}
+		reqMessages = p.toolEmulation.EmulateToolMessages(reqMessages, tools)
+		tools = nil
+	}
+
 	reqBody := map[string]any{
 		"model":    model,
 		"messages": reqMessages,
@@ -738,7 +798,7 @@ func (p *Provider) buildRequestBody(model string, messages []service.Message, to
 		if opts.ToolChoice != nil && len(tools) > 0 {
 			reqBody["tool_choice"] = opts.ToolChoice
 		}
-		if opts.ParallelToolCalls != nil {
+		if opts.ParallelToolCalls != nil && !emulateTools {
 			reqBody["parallel_tool_calls"] = *opts.ParallelToolCalls
 		}
 		if opts.N != nil {
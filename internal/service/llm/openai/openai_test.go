@@ -0,0 +1,44 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rakunlabs/at/internal/service"
+)
+
+func TestChatMultipleChoices(t *testing.T) {
+	var body map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"choices":[
+			{"index":0,"message":{"role":"assistant","content":"one"},"finish_reason":"stop"},
+			{"index":1,"message":{"role":"assistant","content":"two"},"finish_reason":"length"}],
+			"usage":{"prompt_tokens":5,"completion_tokens":4,"total_tokens":9}}`))
+	}))
+	defer srv.Close()
+
+	p, err := New("test-key", "gpt-4o", srv.URL, "", false, nil)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	n := 2
+	resp, err := p.Chat(context.Background(), "gpt-4o", []service.Message{{Role: "user", Content: "hi"}}, nil, &service.ChatOptions{N: &n})
+	if err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+
+	if body["n"] != float64(2) {
+		t.Errorf("n sent upstream = %v", body["n"])
+	}
+	if resp.Content != "one" || resp.Usage.CompletionTokens != 4 {
+		t.Errorf("resp = %+v", resp)
+	}
+	if len(resp.ExtraChoices) != 1 || resp.ExtraChoices[0].Content != "two" || resp.ExtraChoices[0].FinishReason != "length" {
+		t.Errorf("extra choices = %+v", resp.ExtraChoices)
+	}
+}
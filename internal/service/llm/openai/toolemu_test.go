@@ -0,0 +1,120 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rakunlabs/at/internal/service"
+	"github.com/rakunlabs/at/internal/service/llm/common"
+)
+
+func TestChatToolEmulation(t *testing.T) {
+	var body map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"Looking it up.\n` +
+			"```tool_call\\n{\\\"name\\\": \\\"lookup\\\", \\\"arguments\\\": {\\\"q\\\": \\\"go\\\"}}\\n```" +
+			`"},"finish_reason":"stop"}]}`))
+	}))
+	defer srv.Close()
+
+	p, err := New("test-key", "llama3", srv.URL, "", false, nil,
+		WithToolEmulation(&common.ToolEmulation{Models: []string{"llama3"}}))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	tools := []service.Tool{{Name: "lookup", Description: "Search", InputSchema: map[string]any{"type": "object"}}}
+	resp, err := p.Chat(context.Background(), "llama3", []service.Message{
+		{Role: "user", Content: "find go"},
+		{Role: "assistant", Content: []service.ContentBlock{{Type: "tool_use", ID: "call_0", Name: "lookup", Input: map[string]any{"q": "rust"}}}},
+		{Role: "user", Content: []service.ContentBlock{{Type: "tool_result", ToolUseID: "call_0", Content: "nothing"}}},
+	}, tools, &service.ChatOptions{ToolChoice: "auto"})
+	if err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+
+	if _, ok := body["tools"]; ok {
+		t.Error("tools sent upstream")
+	}
+	if _, ok := body["tool_choice"]; ok {
+		t.Error("tool_choice sent upstream")
+	}
+	raw, _ := json.Marshal(body["messages"])
+	if strings.Contains(string(raw), `"tool_calls"`) || strings.Contains(string(raw), `"role":"tool"`) {
+		t.Errorf("native tool history sent upstream: %s", raw)
+	}
+	if !strings.Contains(string(raw), "- lookup: Search") {
+		t.Errorf("tool prompt missing: %s", raw)
+	}
+
+	if resp.Content != "Looking it up." || resp.FinishReason != "tool_calls" || resp.Finished {
+		t.Errorf("resp = %+v", resp)
+	}
+	if len(resp.ToolCalls) != 1 || resp.ToolCalls[0].Name != "lookup" || resp.ToolCalls[0].Arguments["q"] != "go" {
+		t.Errorf("tool calls = %+v", resp.ToolCalls)
+	}
+}
+
+func TestChatToolEmulationOtherModelUsesNativeTools(t *testing.T) {
+	var body map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		_, _ = w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}]}`))
+	}))
+	defer srv.Close()
+
+	p, err := New("test-key", "gpt-4o", srv.URL, "", false, nil,
+		WithToolEmulation(&common.ToolEmulation{Models: []string{"llama3"}}))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if _, err := p.Chat(context.Background(), "gpt-4o", []service.Message{{Role: "user", Content: "hi"}},
+		[]service.Tool{{Name: "lookup", InputSchema: map[string]any{"type": "object"}}}, nil); err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+	if _, ok := body["tools"]; !ok {
+		t.Error("native tools missing for a non-emulated model")
+	}
+}
+
+func TestChatStreamToolEmulation(t *testing.T) {
+	srv := newSSEServer(t, []string{
+		`{"choices":[{"delta":{"content":"<tool_call>{\"name\":\"lookup\","},"finish_reason":null}]}`,
+		`{"choices":[{"delta":{"content":"\"arguments\":{\"q\":\"go\"}}</tool_call>"},"finish_reason":null}]}`,
+		`{"choices":[{"delta":{},"finish_reason":"stop"}]}`,
+		`[DONE]`,
+	})
+	defer srv.Close()
+
+	p, err := New("test-key", "llama3", srv.URL, "", false, nil,
+		WithToolEmulation(&common.ToolEmulation{}))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	ch, _, err := p.ChatStream(context.Background(), "llama3", []service.Message{{Role: "user", Content: "find go"}},
+		[]service.Tool{{Name: "lookup", InputSchema: map[string]any{"type": "object"}}}, nil)
+	if err != nil {
+		t.Fatalf("ChatStream: %v", err)
+	}
+
+	var calls []service.ToolCall
+	var finish string
+	for _, c := range collectStream(t, ch) {
+		if c.Content != "" {
+			t.Errorf("tool-call block leaked as content: %q", c.Content)
+		}
+		calls = append(calls, c.ToolCalls...)
+		if c.FinishReason != "" {
+			finish = c.FinishReason
+		}
+	}
+	if len(calls) != 1 || calls[0].Name != "lookup" || finish != "tool_calls" {
+		t.Errorf("calls = %+v finish = %q", calls, finish)
+	}
+}
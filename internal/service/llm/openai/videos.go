@@ -0,0 +1,140 @@
+package openai
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/rakunlabs/at/internal/service"
+)
+
+// ─── Video Generation ───
+
+// videoObject is the OpenAI Videos API job object.
+type videoObject struct {
+	ID       string `json:"id"`
+	Model    string `json:"model"`
+	Status   string `json:"status"`
+	Progress int    `json:"progress"`
+	Seconds  string `json:"seconds"`
+	Size     string `json:"size"`
+	Error    *struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// toService converts the API object to a service.VideoJob.
+func (v *videoObject) toService() *service.VideoJob {
+	job := &service.VideoJob{
+		ID:       v.ID,
+		Status:   v.Status,
+		Progress: v.Progress,
+		Model:    v.Model,
+		Size:     v.Size,
+	}
+	job.Seconds, _ = strconv.Atoi(v.Seconds)
+	if v.Error != nil {
+		job.Error = v.Error.Message
+		if job.Error == "" {
+			job.Error = v.Error.Code
+		}
+	}
+	return job
+}
+
+// SubmitVideo implements service.VideoProvider via the multipart videos
+// endpoint. InputImage is sent as "input_reference" and must match the
+// requested size.
+func (p *Provider) SubmitVideo(ctx context.Context, req service.VideoGenerateRequest) (*service.VideoJob, error) {
+	var buf bytes.Buffer
+	writer := newMultipartWriter(&buf)
+
+	model := req.Model
+	if model == "" {
+		model = "sora-2"
+	}
+	_ = writer.WriteField("model", model)
+	_ = writer.WriteField("prompt", req.Prompt)
+	if req.Seconds > 0 {
+		_ = writer.WriteField("seconds", strconv.Itoa(req.Seconds))
+	}
+	if req.Size != "" {
+		_ = writer.WriteField("size", req.Size)
+	}
+	if req.InputImage != nil {
+		if err := writeImagePart(writer, "input_reference", "input_reference", *req.InputImage); err != nil {
+			return nil, fmt.Errorf("submit video: %w", err)
+		}
+	}
+
+	var apiResp videoObject
+	if err := p.doMultipart(ctx, p.apiURL("/videos"), writer, &buf, &apiResp); err != nil {
+		return nil, fmt.Errorf("submit video: %w", err)
+	}
+
+	return apiResp.toService(), nil
+}
+
+// GetVideo implements service.VideoProvider.
+func (p *Provider) GetVideo(ctx context.Context, id string) (*service.VideoJob, error) {
+	var apiResp videoObject
+	if err := p.doJSON(ctx, "GET", p.apiURL("/videos/"+url.PathEscape(id)), nil, &apiResp); err != nil {
+		return nil, fmt.Errorf("get video %q: %w", id, err)
+	}
+
+	return apiResp.toService(), nil
+}
+
+// CancelVideo implements service.VideoProvider. The Videos API has no
+// separate cancel call; deleting the job stops it.
+func (p *Provider) CancelVideo(ctx context.Context, id string) error {
+	if err := p.doJSON(ctx, "DELETE", p.apiURL("/videos/"+url.PathEscape(id)), nil, nil); err != nil {
+		return fmt.Errorf("cancel video %q: %w", id, err)
+	}
+
+	return nil
+}
+
+// DownloadVideo implements service.VideoProvider by streaming the
+// finished MP4 from the content endpoint.
+func (p *Provider) DownloadVideo(ctx context.Context, id string) (io.ReadCloser, string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", p.apiURL("/videos/"+url.PathEscape(id)+"/content"), nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("create request: %w", err)
+	}
+	if p.APIKey != "" {
+		token := p.APIKey
+		if p.tokenSource != nil {
+			t, err := p.tokenSource.Token(ctx)
+			if err != nil {
+				return nil, "", fmt.Errorf("token source: %w", err)
+			}
+			token = t
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := p.client.HTTP.Do(req)
+	if err != nil {
+		return nil, "", fmt.Errorf("download video %q: http request: %w", id, err)
+	}
+
+	if resp.StatusCode >= 400 {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return nil, "", fmt.Errorf("download video %q: %w", id, mediaAPIError(resp, body))
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	if contentType == "" || strings.HasPrefix(contentType, "application/octet-stream") {
+		contentType = "video/mp4"
+	}
+
+	return resp.Body, contentType, nil
+}
@@ -78,6 +78,7 @@ func New(model, endpointURL, proxy string, insecureSkipVerify bool, opts ...Opti
 		klient.WithLogger(slog.Default()),
 		klient.WithDisableRetry(true),
 		klient.WithDisableEnvValues(true),
+		klient.WithInject(common.InjectTraceContext),
 	}
 	if proxy != "" {
 		klientOpts = append(klientOpts, klient.WithProxy(proxy))
@@ -284,21 +285,42 @@ func (p *Provider) Chat(ctx context.Context, model string, messages []service.Me
 		return nil, fmt.Errorf("no response choices from Vertex AI")
 	}
 
-	ch := result.Choices[0]
-	llmResp := &service.LLMResponse{
-		Content:           ch.Message.Content,
-		ReasoningContent:  ch.Message.ReasoningContent,
-		Finished:          ch.FinishReason != "tool_calls",
-		FinishReason:      ch.FinishReason,
-		Header:            headers,
-		SystemFingerprint: result.SystemFingerprint,
-		Logprobs:          ch.Logprobs,
+	llmResp, err := choiceResponse(result.Choices[0])
+	if err != nil {
+		return nil, err
 	}
-
+	llmResp.Header = headers
+	llmResp.SystemFingerprint = result.SystemFingerprint
 	if result.Usage != nil {
 		llmResp.Usage = vertexServiceUsage(result.Usage)
 	}
 
+	// n > 1: the remaining choices ride along on the first.
+	for _, ch := range result.Choices[1:] {
+		extra, err := choiceResponse(ch)
+		if err != nil {
+			return nil, err
+		}
+		llmResp.ExtraChoices = append(llmResp.ExtraChoices, *extra)
+	}
+
+	return llmResp, nil
+}
+
+// NativeChoices implements service.NativeChoicesProvider: Vertex AI's
+// OpenAI-compatible endpoint accepts `n`.
+func (p *Provider) NativeChoices() bool { return true }
+
+// choiceResponse converts one response choice.
+func choiceResponse(ch choice) (*service.LLMResponse, error) {
+	llmResp := &service.LLMResponse{
+		Content:          ch.Message.Content,
+		ReasoningContent: ch.Message.ReasoningContent,
+		Finished:         ch.FinishReason != "tool_calls",
+		FinishReason:     ch.FinishReason,
+		Logprobs:         ch.Logprobs,
+	}
+
 	for _, tc := range ch.Message.ToolCalls {
 		var args map[string]any
 		if err := json.Unmarshal([]byte(tc.Function.Arguments), &args); err != nil {
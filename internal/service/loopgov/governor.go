@@ -62,13 +62,21 @@ func (g *Governor) Disabled() bool { return g.cfg.Disabled }
 // Useful for tests and for callers that need the chat history limit.
 func (g *Governor) Config() Config { return g.cfg }
 
-// ChatOptions used to return a per-call MaxTokens cap. We removed the
-// platform-wide output-token cap because it broke structured outputs
-// (e.g. multi-scene Script Writer JSON for video shorts). Per-model
-// limits already live in the provider/agent config; the governor stays
-// out of the way. Returning nil is the documented "no cap" sentinel
-// for every provider adapter.
-func (g *Governor) ChatOptions() *service.ChatOptions { return nil }
+// ChatOptions returns the per-call options for an agent's loop. It used
+// to carry a MaxTokens cap; we removed the platform-wide output-token cap
+// because it broke structured outputs (e.g. multi-scene Script Writer
+// JSON for video shorts). Per-model limits already live in the
+// provider/agent config.
+//
+// Prompt caching is applied automatically by the provider adapters, so
+// the only option left is the agent's opt-out. Returning nil is the
+// documented "defaults" sentinel for every provider adapter.
+func (g *Governor) ChatOptions(agent service.AgentConfig) *service.ChatOptions {
+	if agent.DisablePromptCache {
+		return &service.ChatOptions{DisablePromptCache: true}
+	}
+	return nil
+}
 
 // ChatHistoryLimit returns the row cap for ListChatMessages. Returns 0
 // when disabled, meaning "no limit" — callers should pass 0 through to
@@ -108,8 +108,11 @@ func TestLimitDisabledModeIsPassThrough(t *testing.T) {
 	if sum.called != 0 {
 		t.Fatal("summarizer should not run when disabled")
 	}
-	if g.ChatOptions() != nil {
-		t.Fatal("ChatOptions should always return nil; output-token cap was removed")
+	if g.ChatOptions(service.AgentConfig{}) != nil {
+		t.Fatal("ChatOptions should return nil by default; output-token cap was removed")
+	}
+	if opts := g.ChatOptions(service.AgentConfig{DisablePromptCache: true}); opts == nil || !opts.DisablePromptCache {
+		t.Fatalf("ChatOptions should carry the prompt-cache opt-out, got %+v", opts)
 	}
 	if g.ChatHistoryLimit() != 0 {
 		t.Fatal("disabled ChatHistoryLimit should be 0")
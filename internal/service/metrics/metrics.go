@@ -0,0 +1,82 @@
+package metrics
+
+import "time"
+
+// Default is the process-wide registry holding the at_* metrics below.
+// It starts disabled; the server enables it from the `server.metrics`
+// config block.
+var Default = NewRegistry(Options{})
+
+var (
+	latencyBuckets = []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300}
+	ttftBuckets    = []float64{0.05, 0.1, 0.25, 0.5, 1, 2, 5, 10, 30}
+	waitBuckets    = []float64{0.001, 0.01, 0.1, 0.5, 1, 5, 10, 30, 60}
+	toolBuckets    = []float64{0.01, 0.05, 0.1, 0.5, 1, 5, 10, 30, 60, 300}
+)
+
+// Gateway.
+var (
+	GatewayRequests = Default.NewCounterVec("at_gateway_requests_total",
+		"Gateway requests by provider, model, API token and status.",
+		"provider", "model", "token", "status")
+	GatewayRequestDuration = Default.NewHistogramVec("at_gateway_request_duration_seconds",
+		"Gateway request latency in seconds, including retries and fallbacks.", latencyBuckets,
+		"provider", "model", "token", "status")
+	GatewayTimeToFirstToken = Default.NewHistogramVec("at_gateway_time_to_first_token_seconds",
+		"Time from the upstream call to the first streamed token, in seconds.", ttftBuckets,
+		"provider", "model", "token")
+)
+
+// LLM usage across the gateway, agents and workflows.
+var (
+	LLMTokens = Default.NewCounterVec("at_llm_tokens_total",
+		"LLM tokens consumed, by type (input, output, cache_read, cache_write).",
+		"source", "provider", "model", "token", "type")
+	LLMCostCents = Default.NewCounterVec("at_llm_cost_cents_total",
+		"Estimated LLM cost in US cents.",
+		"source", "provider", "model", "token")
+)
+
+// Provider rate limiters.
+var (
+	RateLimitWait = Default.NewHistogramVec("at_ratelimit_wait_seconds",
+		"Time spent waiting on a provider rate limiter, in seconds.", waitBuckets,
+		"provider")
+	RateLimitRejections = Default.NewCounterVec("at_ratelimit_rejections_total",
+		"Calls rejected because a provider rate limiter did not permit them in time.",
+		"provider", "reason")
+)
+
+// Provider API key pools.
+var (
+	ProviderKeyRequests = Default.NewCounterVec("at_provider_key_requests_total",
+		"Calls made with each key of a provider's API key pool, by outcome (ok, error, rate_limited).",
+		"provider", "key", "outcome")
+	ProviderKeyTokens = Default.NewCounterVec("at_provider_key_tokens_total",
+		"Tokens consumed through each key of a provider's API key pool, by type (input, output).",
+		"provider", "key", "type")
+)
+
+// Agents, tools, workflows and bots.
+var (
+	WorkflowRunsActive = Default.NewGaugeVec("at_workflow_runs_active",
+		"Workflow runs currently executing, by trigger source.",
+		"source")
+	AgentLoopIterations = Default.NewCounterVec("at_agent_loop_iterations_total",
+		"Agentic loop iterations, by agent and loop (chat or task).",
+		"agent", "loop")
+	ToolCallDuration = Default.NewHistogramVec("at_tool_call_duration_seconds",
+		"Agent tool call duration in seconds.", toolBuckets,
+		"tool", "source")
+	ToolCallErrors = Default.NewCounterVec("at_tool_call_errors_total",
+		"Agent tool calls that returned an error.",
+		"tool", "source")
+	BotMessages = Default.NewCounterVec("at_bot_messages_total",
+		"Messages received by bot adapters.",
+		"platform", "bot")
+)
+
+// Seconds converts milliseconds to the seconds histograms are recorded in.
+func Seconds(ms int64) float64 {
+	return (time.Duration(ms) * time.Millisecond).Seconds()
+}
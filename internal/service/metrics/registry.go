@@ -0,0 +1,407 @@
+// Package metrics is a small, dependency-free Prometheus exporter.
+//
+// It implements just what AT needs — counter, gauge and histogram
+// vectors rendered in the Prometheus text exposition format — plus the
+// cardinality controls an LLM gateway needs in practice: label
+// dimensions can be dropped entirely (e.g. "token" or "model" on a
+// multi-tenant deployment) and every metric caps the number of distinct
+// label sets it tracks, folding the excess into a single overflow series.
+//
+// A disabled Registry turns every observation into a cheap no-op, so
+// instrumented code paths never need to check whether metrics are on.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultMaxSeries is used when Options.MaxSeries is zero.
+const DefaultMaxSeries = 1000
+
+// OverflowValue replaces every label value of the series that collects
+// observations once a metric reached its series limit.
+const OverflowValue = "_other"
+
+// Options configures a Registry.
+type Options struct {
+	// Enabled turns recording on. A disabled registry ignores all
+	// observations and renders nothing.
+	Enabled bool
+
+	// DropLabels lists label names removed from every metric. Their
+	// values are discarded, so series that differ only in a dropped
+	// label are merged.
+	DropLabels []string
+
+	// MaxSeries caps the distinct label sets per metric. Observations
+	// for new label sets beyond the cap are recorded in one overflow
+	// series whose labels are all OverflowValue. 0 = DefaultMaxSeries,
+	// negative = unlimited.
+	MaxSeries int
+}
+
+type options struct {
+	enabled   bool
+	drop      map[string]bool
+	maxSeries int
+}
+
+// Registry holds a set of metric families and renders them.
+type Registry struct {
+	opts atomic.Pointer[options]
+
+	mu       sync.RWMutex
+	families map[string]*family
+}
+
+// NewRegistry returns a Registry configured with opts.
+func NewRegistry(opts Options) *Registry {
+	r := &Registry{families: make(map[string]*family)}
+	r.Configure(opts)
+	return r
+}
+
+// Configure replaces the registry options. Series recorded before the
+// call are reset so dropped labels and series limits apply uniformly.
+func (r *Registry) Configure(opts Options) {
+	o := &options{
+		enabled:   opts.Enabled,
+		drop:      make(map[string]bool, len(opts.DropLabels)),
+		maxSeries: opts.MaxSeries,
+	}
+	for _, l := range opts.DropLabels {
+		if l = strings.TrimSpace(l); l != "" {
+			o.drop[l] = true
+		}
+	}
+	if o.maxSeries == 0 {
+		o.maxSeries = DefaultMaxSeries
+	}
+	r.opts.Store(o)
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, f := range r.families {
+		f.reset()
+	}
+}
+
+// Enabled reports whether the registry records observations.
+func (r *Registry) Enabled() bool {
+	return r.opts.Load().enabled
+}
+
+// NewCounterVec registers a counter with the given label names.
+func (r *Registry) NewCounterVec(name, help string, labels ...string) *CounterVec {
+	return &CounterVec{f: r.register(name, help, "counter", nil, labels)}
+}
+
+// NewGaugeVec registers a gauge with the given label names.
+func (r *Registry) NewGaugeVec(name, help string, labels ...string) *GaugeVec {
+	return &GaugeVec{f: r.register(name, help, "gauge", nil, labels)}
+}
+
+// NewHistogramVec registers a histogram with the given upper bucket
+// bounds (sorted ascending; +Inf is implicit) and label names.
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	b := slices.Clone(buckets)
+	sort.Float64s(b)
+	return &HistogramVec{f: r.register(name, help, "histogram", b, labels)}
+}
+
+func (r *Registry) register(name, help, typ string, buckets []float64, labels []string) *family {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.families[name]; ok {
+		panic("metrics: duplicate metric " + name)
+	}
+	f := &family{
+		reg:     r,
+		name:    name,
+		help:    help,
+		typ:     typ,
+		labels:  labels,
+		buckets: buckets,
+		series:  make(map[string]*series),
+	}
+	r.families[name] = f
+	return f
+}
+
+// WriteTo renders every family in the Prometheus text exposition format.
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	if !r.Enabled() {
+		return 0, nil
+	}
+
+	r.mu.RLock()
+	names := make([]string, 0, len(r.families))
+	for name := range r.families {
+		names = append(names, name)
+	}
+	r.mu.RUnlock()
+	sort.Strings(names)
+
+	cw := &countingWriter{w: bufio.NewWriter(w)}
+	opts := r.opts.Load()
+	for _, name := range names {
+		r.mu.RLock()
+		f := r.families[name]
+		r.mu.RUnlock()
+		f.write(cw, opts)
+	}
+	if err := cw.w.Flush(); err != nil && cw.err == nil {
+		cw.err = err
+	}
+	return cw.n, cw.err
+}
+
+// Handler serves the registry. When token is non-empty, requests must
+// carry "Authorization: Bearer <token>".
+func (r *Registry) Handler(token string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if token != "" {
+			auth := req.Header.Get("Authorization")
+			if got := strings.TrimPrefix(auth, "Bearer "); got == auth || got != token {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		if _, err := r.WriteTo(w); err != nil {
+			slog.Warn("metrics: write failed", "error", err)
+		}
+	})
+}
+
+// ─── Families ───
+
+type family struct {
+	reg     *Registry
+	name    string
+	help    string
+	typ     string
+	labels  []string
+	buckets []float64
+
+	mu         sync.Mutex
+	series     map[string]*series
+	overflowed bool
+}
+
+type series struct {
+	values []string
+
+	value float64 // counter / gauge
+
+	counts []uint64 // histogram, per bucket (non-cumulative)
+	sum    float64
+	count  uint64
+}
+
+func (f *family) reset() {
+	f.mu.Lock()
+	f.series = make(map[string]*series)
+	f.overflowed = false
+	f.mu.Unlock()
+}
+
+// update resolves the series for labelValues and applies fn to it under
+// the family lock. It is a no-op while the registry is disabled.
+func (f *family) update(labelValues []string, fn func(*series)) {
+	opts := f.reg.opts.Load()
+	if !opts.enabled {
+		return
+	}
+	if len(labelValues) != len(f.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", f.name, len(f.labels), len(labelValues)))
+	}
+
+	values := make([]string, len(labelValues))
+	for i, v := range labelValues {
+		if !opts.drop[f.labels[i]] {
+			values[i] = v
+		}
+	}
+	key := strings.Join(values, "\xff")
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	s, ok := f.series[key]
+	if !ok {
+		if opts.maxSeries > 0 && len(f.series) >= opts.maxSeries {
+			for i := range values {
+				if !opts.drop[f.labels[i]] {
+					values[i] = OverflowValue
+				}
+			}
+			key = strings.Join(values, "\xff")
+			if !f.overflowed {
+				f.overflowed = true
+				slog.Warn("metrics: series limit reached, folding new label sets into overflow series",
+					"metric", f.name, "max_series", opts.maxSeries)
+			}
+			s, ok = f.series[key]
+		}
+		if !ok {
+			s = &series{values: values}
+			if f.typ == "histogram" {
+				s.counts = make([]uint64, len(f.buckets))
+			}
+			f.series[key] = s
+		}
+	}
+	fn(s)
+}
+
+func (f *family) write(w *countingWriter, opts *options) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.series) == 0 {
+		return
+	}
+
+	keys := make([]string, 0, len(f.series))
+	for k := range f.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	w.printf("# HELP %s %s\n", f.name, escapeHelp(f.help))
+	w.printf("# TYPE %s %s\n", f.name, f.typ)
+	for _, k := range keys {
+		s := f.series[k]
+		labels := f.labelPairs(s.values, opts)
+		if f.typ != "histogram" {
+			w.printf("%s%s %s\n", f.name, braces(labels), formatFloat(s.value))
+			continue
+		}
+		var cum uint64
+		for i, le := range f.buckets {
+			cum += s.counts[i]
+			w.printf("%s_bucket%s %d\n", f.name, braces(append(slices.Clone(labels), `le="`+formatFloat(le)+`"`)), cum)
+		}
+		w.printf("%s_bucket%s %d\n", f.name, braces(append(slices.Clone(labels), `le="+Inf"`)), s.count)
+		w.printf("%s_sum%s %s\n", f.name, braces(labels), formatFloat(s.sum))
+		w.printf("%s_count%s %d\n", f.name, braces(labels), s.count)
+	}
+}
+
+func (f *family) labelPairs(values []string, opts *options) []string {
+	pairs := make([]string, 0, len(values))
+	for i, v := range values {
+		if opts.drop[f.labels[i]] {
+			continue
+		}
+		pairs = append(pairs, f.labels[i]+`="`+escapeLabel(v)+`"`)
+	}
+	return pairs
+}
+
+// ─── Vectors ───
+
+// CounterVec is a monotonically increasing value per label set.
+type CounterVec struct{ f *family }
+
+// Add increases the counter by v. Negative values are ignored.
+func (c *CounterVec) Add(v float64, labelValues ...string) {
+	if v <= 0 || math.IsNaN(v) {
+		return
+	}
+	c.f.update(labelValues, func(s *series) { s.value += v })
+}
+
+// Inc increases the counter by one.
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// GaugeVec is a value per label set that can go up and down.
+type GaugeVec struct{ f *family }
+
+// Set sets the gauge to v.
+func (g *GaugeVec) Set(v float64, labelValues ...string) {
+	g.f.update(labelValues, func(s *series) { s.value = v })
+}
+
+// Add adds v (which may be negative) to the gauge.
+func (g *GaugeVec) Add(v float64, labelValues ...string) {
+	g.f.update(labelValues, func(s *series) { s.value += v })
+}
+
+// Inc increases the gauge by one.
+func (g *GaugeVec) Inc(labelValues ...string) { g.Add(1, labelValues...) }
+
+// Dec decreases the gauge by one.
+func (g *GaugeVec) Dec(labelValues ...string) { g.Add(-1, labelValues...) }
+
+// HistogramVec samples observations into buckets per label set.
+type HistogramVec struct{ f *family }
+
+// Observe records one observation.
+func (h *HistogramVec) Observe(v float64, labelValues ...string) {
+	if math.IsNaN(v) {
+		return
+	}
+	h.f.update(labelValues, func(s *series) {
+		if i := sort.SearchFloat64s(h.f.buckets, v); i < len(s.counts) {
+			s.counts[i]++
+		}
+		s.sum += v
+		s.count++
+	})
+}
+
+// ─── Formatting ───
+
+type countingWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (c *countingWriter) printf(format string, args ...any) {
+	if c.err != nil {
+		return
+	}
+	n, err := fmt.Fprintf(c.w, format, args...)
+	c.n += int64(n)
+	c.err = err
+}
+
+func braces(pairs []string) string {
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabel(v string) string { return labelEscaper.Replace(v) }
+func escapeHelp(v string) string  { return helpEscaper.Replace(v) }
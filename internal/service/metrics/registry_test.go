@@ -0,0 +1,124 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func render(t *testing.T, r *Registry) string {
+	t.Helper()
+	var b strings.Builder
+	if _, err := r.WriteTo(&b); err != nil {
+		t.Fatalf("WriteTo: %v", err)
+	}
+	return b.String()
+}
+
+func TestRegistryExposition(t *testing.T) {
+	r := NewRegistry(Options{Enabled: true})
+	c := r.NewCounterVec("requests_total", "Requests.", "provider", "status")
+	g := r.NewGaugeVec("active", "Active runs.", "source")
+	h := r.NewHistogramVec("latency_seconds", "Latency.", []float64{1, 0.5}, "provider")
+
+	c.Inc("openai", "ok")
+	c.Add(2, "openai", "ok")
+	c.Add(-5, "openai", "ok") // ignored
+	c.Inc("anth\"ropic", "error")
+	g.Inc("api")
+	g.Inc("api")
+	g.Dec("api")
+	h.Observe(0.2, "openai")
+	h.Observe(0.7, "openai")
+	h.Observe(3, "openai")
+
+	want := `# HELP active Active runs.
+# TYPE active gauge
+active{source="api"} 1
+# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{provider="openai",le="0.5"} 1
+latency_seconds_bucket{provider="openai",le="1"} 2
+latency_seconds_bucket{provider="openai",le="+Inf"} 3
+latency_seconds_sum{provider="openai"} 3.9
+latency_seconds_count{provider="openai"} 3
+# HELP requests_total Requests.
+# TYPE requests_total counter
+requests_total{provider="anth\"ropic",status="error"} 1
+requests_total{provider="openai",status="ok"} 3
+`
+	if got := render(t, r); got != want {
+		t.Errorf("exposition mismatch\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestRegistryDisabledRecordsNothing(t *testing.T) {
+	r := NewRegistry(Options{})
+	c := r.NewCounterVec("requests_total", "Requests.", "status")
+	c.Inc("ok")
+
+	r.Configure(Options{Enabled: true})
+	if got := render(t, r); got != "" {
+		t.Errorf("disabled registry recorded: %q", got)
+	}
+}
+
+func TestRegistryDropLabels(t *testing.T) {
+	r := NewRegistry(Options{Enabled: true, DropLabels: []string{"token"}})
+	c := r.NewCounterVec("requests_total", "Requests.", "model", "token")
+	c.Inc("gpt", "team-a")
+	c.Inc("gpt", "team-b")
+
+	got := render(t, r)
+	if !strings.Contains(got, `requests_total{model="gpt"} 2`) {
+		t.Errorf("token label not dropped:\n%s", got)
+	}
+}
+
+func TestRegistryMaxSeriesOverflow(t *testing.T) {
+	r := NewRegistry(Options{Enabled: true, MaxSeries: 2})
+	c := r.NewCounterVec("requests_total", "Requests.", "model", "status")
+	c.Inc("a", "ok")
+	c.Inc("b", "ok")
+	c.Inc("c", "ok")
+	c.Inc("d", "error")
+	c.Inc("a", "ok") // existing series keep counting
+
+	got := render(t, r)
+	for _, line := range []string{
+		`requests_total{model="a",status="ok"} 2`,
+		`requests_total{model="b",status="ok"} 1`,
+		`requests_total{model="_other",status="_other"} 2`,
+	} {
+		if !strings.Contains(got, line) {
+			t.Errorf("missing %q in:\n%s", line, got)
+		}
+	}
+	if strings.Contains(got, `model="c"`) {
+		t.Errorf("series beyond the limit was tracked:\n%s", got)
+	}
+}
+
+func TestRegistryHandlerToken(t *testing.T) {
+	r := NewRegistry(Options{Enabled: true})
+	r.NewCounterVec("requests_total", "Requests.").Inc()
+	h := r.Handler("secret")
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("status without token = %d, want 401", rec.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "requests_total 1") {
+		t.Fatalf("status = %d; body=%s", rec.Code, rec.Body.String())
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("content type = %q", ct)
+	}
+}
@@ -11,6 +11,7 @@ package ratelimit
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
@@ -42,8 +43,20 @@ type Config struct {
 	// WaitTimeout bounds how long Acquire will block waiting for the
 	// limiter to permit the call. 0 = use DefaultWaitTimeout.
 	WaitTimeout time.Duration
+
+	// Name identifies the limiter (typically the provider key) in
+	// Observer callbacks.
+	Name string
+
+	// Observer, if set, is called after every Acquire with the time
+	// spent waiting and the *Error when the call was not permitted.
+	Observer Observer
 }
 
+// Observer receives the outcome of one Acquire call. err is nil when
+// the call was permitted.
+type Observer func(name string, waited time.Duration, err *Error)
+
 // IsZero reports whether the config disables all limiting.
 func (c Config) IsZero() bool {
 	return c.RequestsPerMinute <= 0 && c.InputTokensPerMin <= 0 && c.MaxConcurrent <= 0
@@ -58,6 +71,9 @@ type Limiter struct {
 	rpm     *rate.Limiter // nil if RequestsPerMinute == 0
 	itpm    *rate.Limiter // nil if InputTokensPerMin == 0
 	timeout time.Duration
+
+	name    string
+	observe Observer
 }
 
 // New builds a Limiter from cfg. Returns nil if cfg disables all
@@ -69,6 +85,8 @@ func New(cfg Config) *Limiter {
 	}
 	l := &Limiter{
 		timeout: cfg.WaitTimeout,
+		name:    cfg.Name,
+		observe: cfg.Observer,
 	}
 	if l.timeout <= 0 {
 		l.timeout = DefaultWaitTimeout
@@ -106,7 +124,19 @@ func (l *Limiter) Acquire(ctx context.Context, estInputTokens int) (release func
 	if l == nil {
 		return func() {}, nil
 	}
+	if l.observe == nil {
+		return l.acquire(ctx, estInputTokens)
+	}
+
+	start := time.Now()
+	release, err = l.acquire(ctx, estInputTokens)
+	var rlErr *Error
+	errors.As(err, &rlErr)
+	l.observe(l.name, time.Since(start), rlErr)
+	return release, err
+}
 
+func (l *Limiter) acquire(ctx context.Context, estInputTokens int) (func(), error) {
 	waitCtx, cancel := context.WithTimeout(ctx, l.timeout)
 	defer cancel()
 
@@ -190,6 +190,41 @@ func TestRelease_Idempotent(t *testing.T) {
 	rel2()
 }
 
+func TestAcquire_ObserverReportsWaitAndRejection(t *testing.T) {
+	type call struct {
+		name string
+		err  *Error
+	}
+	var calls []call
+	l := New(Config{
+		MaxConcurrent: 1,
+		WaitTimeout:   30 * time.Millisecond,
+		Name:          "anthropic",
+		Observer: func(name string, waited time.Duration, err *Error) {
+			calls = append(calls, call{name, err})
+		},
+	})
+
+	release, err := l.Acquire(context.Background(), 0)
+	if err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+	defer release()
+	if _, err := l.Acquire(context.Background(), 0); err == nil {
+		t.Fatal("expected the second Acquire to time out")
+	}
+
+	if len(calls) != 2 {
+		t.Fatalf("observer calls = %d, want 2", len(calls))
+	}
+	if calls[0].name != "anthropic" || calls[0].err != nil {
+		t.Errorf("first call = %+v, want permitted", calls[0])
+	}
+	if calls[1].err == nil || calls[1].err.Reason != ReasonConcurrent {
+		t.Errorf("second call = %+v, want max_concurrent rejection", calls[1])
+	}
+}
+
 func TestReason_String(t *testing.T) {
 	cases := []struct {
 		r    Reason
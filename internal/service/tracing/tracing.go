@@ -0,0 +1,127 @@
+// Package tracing holds the OpenTelemetry span helpers shared by the
+// gateway, the agent loops and the workflow engine.
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/rakunlabs/at/internal/service"
+)
+
+// ─── OpenTelemetry spans ───
+//
+// Live spans for work running under a request or a run: agent-loop
+// iterations, provider attempts and tool calls. The server's agent loops,
+// the gateway and the agent_call node trace the same way through them.
+// When no tracer provider is configured the global tracer is a no-op and
+// these cost next to nothing.
+
+// TracerName is the instrumentation scope of AT's own spans.
+const TracerName = "github.com/rakunlabs/at"
+
+// spanBodyCap bounds tool arguments/results attached to a span; the audit
+// row, not the span, is the system of record.
+const spanBodyCap = 16384
+
+// Tracer returns AT's tracer from the global tracer provider.
+func Tracer() trace.Tracer {
+	return otel.Tracer(TracerName)
+}
+
+// TraceIDFromContext returns the OTel trace ID of the span carried by ctx,
+// or "" when ctx carries no valid span context.
+func TraceIDFromContext(ctx context.Context) string {
+	if sc := trace.SpanContextFromContext(ctx); sc.TraceID().IsValid() {
+		return sc.TraceID().String()
+	}
+	return ""
+}
+
+// StartSpan starts an internal child span of the span in ctx.
+func StartSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return Tracer().Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithAttributes(attrs...),
+	)
+}
+
+// EndSpan records err (if any) on span and ends it.
+func EndSpan(span trace.Span, err error) {
+	if err != nil {
+		markSpanError(span, err)
+	}
+	span.End()
+}
+
+// StartProviderSpan starts the client span of one upstream provider
+// attempt. Provider HTTP clients inject its context as the traceparent of
+// the upstream request. The audit layer emits the matching gen-ai
+// "chat <model>" generation span (with bodies and usage) once the call is
+// recorded; this span times the attempt itself.
+func StartProviderSpan(ctx context.Context, providerKey, model string, attempt int) (context.Context, trace.Span) {
+	return Tracer().Start(ctx, "provider "+providerKey,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("at.provider", providerKey),
+			attribute.String("gen_ai.request.model", model),
+			attribute.Int("at.attempt", attempt),
+		),
+	)
+}
+
+// EndProviderSpan annotates a provider span with the response usage and
+// ends it. result is whatever the attempt returned; only chat responses
+// carry usage.
+func EndProviderSpan(span trace.Span, result any, err error) {
+	if resp, ok := result.(*service.LLMResponse); ok && resp != nil {
+		span.SetAttributes(
+			attribute.Int("gen_ai.usage.input_tokens", resp.Usage.PromptTokens),
+			attribute.Int("gen_ai.usage.output_tokens", resp.Usage.CompletionTokens),
+		)
+		if resp.FinishReason != "" {
+			span.SetAttributes(attribute.StringSlice("gen_ai.response.finish_reasons", []string{resp.FinishReason}))
+		}
+	}
+	EndSpan(span, err)
+}
+
+// StartToolSpan starts the span of one agent tool call. source is the loop
+// running it ("chat", "agent", "workflow").
+func StartToolSpan(ctx context.Context, tool, source string, args map[string]any) (context.Context, trace.Span) {
+	argsJSON, _ := json.Marshal(args)
+	return StartSpan(ctx, "execute_tool "+tool,
+		attribute.String("gen_ai.operation.name", "execute_tool"),
+		attribute.String("gen_ai.tool.name", tool),
+		attribute.String("gen_ai.tool.call.arguments", clipSpanValue(string(argsJSON))),
+		attribute.String("at.source", source),
+	)
+}
+
+// EndToolSpan records the tool result on span and ends it. Tool failures
+// reach the agent as an error result, so failed only flags the span.
+func EndToolSpan(span trace.Span, result string, failed bool) {
+	span.SetAttributes(attribute.String("gen_ai.tool.call.result", clipSpanValue(result)))
+	if failed {
+		span.SetStatus(codes.Error, clipSpanValue(result))
+	}
+	span.End()
+}
+
+// markSpanError records err on span and marks it failed.
+func markSpanError(span trace.Span, err error) {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+func clipSpanValue(s string) string {
+	if len(s) <= spanBodyCap {
+		return s
+	}
+	return s[:spanBodyCap] + "...[truncated]"
+}
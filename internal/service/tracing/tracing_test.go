@@ -0,0 +1,56 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/rakunlabs/at/internal/service"
+)
+
+func TestProviderSpanRecordsAttemptAndUsage(t *testing.T) {
+	rec := tracetest.NewSpanRecorder()
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec)))
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+
+	ctx, parent := StartSpan(context.Background(), "agent.iteration")
+	spanCtx, span := StartProviderSpan(ctx, "openai", "gpt-4o", 2)
+	if got := TraceIDFromContext(spanCtx); got == "" || got != TraceIDFromContext(ctx) {
+		t.Fatalf("provider span trace ID = %q, want the parent's", got)
+	}
+	EndProviderSpan(span, &service.LLMResponse{
+		FinishReason: "stop",
+		Usage:        service.Usage{PromptTokens: 7, CompletionTokens: 3},
+	}, nil)
+	_, failed := StartProviderSpan(ctx, "openai", "gpt-4o", 3)
+	EndProviderSpan(failed, nil, errors.New("upstream 500"))
+	parent.End()
+
+	spans := rec.Ended()
+	if len(spans) != 3 {
+		t.Fatalf("expected 3 spans, got %d", len(spans))
+	}
+	attrs := map[attribute.Key]attribute.Value{}
+	for _, kv := range spans[0].Attributes() {
+		attrs[kv.Key] = kv.Value
+	}
+	if spans[0].Name() != "provider openai" || attrs["at.attempt"].AsInt64() != 2 || attrs["gen_ai.usage.input_tokens"].AsInt64() != 7 {
+		t.Fatalf("provider span = %q %v", spans[0].Name(), attrs)
+	}
+	if spans[0].Parent().SpanID() != spans[2].SpanContext().SpanID() {
+		t.Fatal("provider span not parented on the span in ctx")
+	}
+	if spans[1].Status().Code != codes.Error {
+		t.Fatalf("failed attempt status = %v", spans[1].Status())
+	}
+	if TraceIDFromContext(context.Background()) != "" {
+		t.Fatal("trace ID of an empty context should be empty")
+	}
+}
@@ -127,6 +127,14 @@ type AgentConfig struct {
 	// SkillRef.Connections and take priority over this map.
 	Connections map[string]string `json:"connections,omitempty"`
 
+	// GuardrailIDs lists guardrail policies applied to every LLM call the
+	// agent makes (chat sessions, bots and org delegation).
+	GuardrailIDs []string `json:"guardrail_ids,omitempty"`
+
+	// DisablePromptCache opts the agent out of automatic prompt caching
+	// (Anthropic cache breakpoints, Gemini cachedContents).
+	DisablePromptCache bool `json:"disable_prompt_cache,omitempty"`
+
 	// NOTE: Organizational fields (role, title, parent_agent_id, organization_id,
 	// status, delegation_rules, heartbeat_schedule) live on the OrganizationAgent
 	// join table so that agents can belong to multiple organizations with per-org metadata.
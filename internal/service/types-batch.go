@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"time"
+)
+
+// Batch statuses, matching the OpenAI Batch API.
+const (
+	BatchStatusValidating = "validating"
+	BatchStatusFailed     = "failed"
+	BatchStatusInProgress = "in_progress"
+	BatchStatusFinalizing = "finalizing"
+	BatchStatusCompleted  = "completed"
+	BatchStatusExpired    = "expired"
+	BatchStatusCancelling = "cancelling"
+	BatchStatusCancelled  = "cancelled"
+)
+
+// BatchFile is a file uploaded to (or produced by) the gateway's Files
+// API. Only batch input JSONL and batch output/error JSONL are kept.
+type BatchFile struct {
+	ID        string    `json:"id"`
+	TokenID   string    `json:"token_id"`
+	Filename  string    `json:"filename"`
+	Purpose   string    `json:"purpose"` // "batch" | "batch_output"
+	Bytes     int64     `json:"bytes"`
+	Content   []byte    `json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Batch is an OpenAI-compatible batch job executed by the gateway.
+//
+// ProviderKey and UpstreamBatchID are set when the job was handed to the
+// provider's native batch API; otherwise the gateway's own workers run
+// each request.
+type Batch struct {
+	ID               string            `json:"id"`
+	TokenID          string            `json:"token_id"`
+	TokenName        string            `json:"token_name"`
+	Endpoint         string            `json:"endpoint"`
+	InputFileID      string            `json:"input_file_id"`
+	OutputFileID     string            `json:"output_file_id,omitempty"`
+	ErrorFileID      string            `json:"error_file_id,omitempty"`
+	CompletionWindow string            `json:"completion_window"`
+	Status           string            `json:"status"`
+	ProviderKey      string            `json:"provider_key,omitempty"`
+	UpstreamBatchID  string            `json:"upstream_batch_id,omitempty"`
+	Metadata         map[string]string `json:"metadata,omitempty"`
+	RequestTotal     int               `json:"request_total"`
+	RequestCompleted int               `json:"request_completed"`
+	RequestFailed    int               `json:"request_failed"`
+	ErrorMessage     string            `json:"error_message,omitempty"`
+	CreatedAt        time.Time         `json:"created_at"`
+	ExpiresAt        time.Time         `json:"expires_at"`
+	InProgressAt     *time.Time        `json:"in_progress_at,omitempty"`
+	FinalizingAt     *time.Time        `json:"finalizing_at,omitempty"`
+	CompletedAt      *time.Time        `json:"completed_at,omitempty"`
+	FailedAt         *time.Time        `json:"failed_at,omitempty"`
+	ExpiredAt        *time.Time        `json:"expired_at,omitempty"`
+	CancellingAt     *time.Time        `json:"cancelling_at,omitempty"`
+	CancelledAt      *time.Time        `json:"cancelled_at,omitempty"`
+}
+
+// BatchStorer defines persistence for the gateway Files and Batch APIs.
+type BatchStorer interface {
+	// GetBatchFile returns the file with id including its content, or nil
+	// when it is missing or expired.
+	GetBatchFile(ctx context.Context, id string) (*BatchFile, error)
+	// ListBatchFiles returns the token's unexpired files, newest first,
+	// without content.
+	ListBatchFiles(ctx context.Context, tokenID string) ([]BatchFile, error)
+	// PutBatchFile inserts or replaces the file with file.ID.
+	PutBatchFile(ctx context.Context, file BatchFile) error
+	// DeleteBatchFile removes the file with id. Deleting a missing file
+	// is not an error.
+	DeleteBatchFile(ctx context.Context, id string) error
+	// DeleteBatchFilesBefore removes files that expired before the cutoff
+	// and returns the number deleted.
+	DeleteBatchFilesBefore(ctx context.Context, cutoff time.Time) (int64, error)
+
+	// GetBatch returns the batch with id, or nil when it is missing.
+	GetBatch(ctx context.Context, id string) (*Batch, error)
+	// ListBatches returns the token's batches, newest first.
+	ListBatches(ctx context.Context, tokenID string) ([]Batch, error)
+	// ListBatchesByStatus returns batches in any of the given statuses,
+	// oldest first. Used to resume work after a restart.
+	ListBatchesByStatus(ctx context.Context, statuses ...string) ([]Batch, error)
+	// PutBatch inserts or replaces the batch with b.ID.
+	PutBatch(ctx context.Context, b Batch) error
+	// ClaimBatch takes or renews the run lease on batch id for owner until
+	// the given time. It reports false when another owner holds a lease
+	// that has not expired, so one instance runs each batch.
+	ClaimBatch(ctx context.Context, id, owner string, until time.Time) (bool, error)
+	// ReleaseBatch drops owner's lease on batch id.
+	ReleaseBatch(ctx context.Context, id, owner string) error
+}
@@ -179,6 +179,7 @@ type ModelPricing struct {
 	CompletionPricePer1M       float64 `json:"completion_price_per_1m"`
 	CacheReadPricePer1M        float64 `json:"cache_read_price_per_1m"`
 	CacheWritePricePer1M       float64 `json:"cache_write_price_per_1m"`
+	PricePerSecond             float64 `json:"price_per_second"` // video generation, per second of output
 	Source                     string  `json:"source,omitempty"`
 	SourceProvider             string  `json:"source_provider,omitempty"`
 	SourceModel                string  `json:"source_model,omitempty"`
@@ -220,6 +221,7 @@ type CostEvent struct {
 	BillingCode      string  `json:"billing_code,omitempty"`
 	RunID            string  `json:"run_id,omitempty"`
 	Provider         string  `json:"provider"`
+	APIKey           string  `json:"api_key,omitempty"` // label of the pooled key that served the call
 	Model            string  `json:"model"`
 	InputTokens      int64   `json:"input_tokens"`
 	OutputTokens     int64   `json:"output_tokens"`
@@ -266,6 +268,7 @@ type UsageSummary struct {
 	OutputTokens     int64   `json:"output_tokens"`
 	CacheReadTokens  int64   `json:"cache_read_tokens"`
 	CacheWriteTokens int64   `json:"cache_write_tokens"`
+	CacheHitRate     float64 `json:"cache_hit_rate"` // see CacheHitRate
 	TotalTokens      int64   `json:"total_tokens"`
 	RequestCount     int64   `json:"request_count"`
 	ErrorCount       int64   `json:"error_count"`
@@ -284,6 +287,7 @@ type UsageTimeSeriesPoint struct {
 	OutputTokens     int64   `json:"output_tokens"`
 	CacheReadTokens  int64   `json:"cache_read_tokens"`
 	CacheWriteTokens int64   `json:"cache_write_tokens"`
+	CacheHitRate     float64 `json:"cache_hit_rate"` // see CacheHitRate
 	TotalTokens      int64   `json:"total_tokens"`
 	RequestCount     int64   `json:"request_count"`
 	ErrorCount       int64   `json:"error_count"`
@@ -291,6 +295,17 @@ type UsageTimeSeriesPoint struct {
 	AvgLatencyMs     float64 `json:"avg_latency_ms"`
 }
 
+// CacheHitRate is the share of input-side tokens served from the prompt
+// cache (0..1). Input tokens are the non-cached remainder, so the
+// denominator is input + cache reads + cache writes.
+func CacheHitRate(inputTokens, cacheReadTokens, cacheWriteTokens int64) float64 {
+	total := inputTokens + cacheReadTokens + cacheWriteTokens
+	if total <= 0 {
+		return 0
+	}
+	return float64(cacheReadTokens) / float64(total)
+}
+
 // BudgetUtilization combines an agent's budget with its current spend.
 type BudgetUtilization struct {
 	BudgetSchedule
@@ -339,7 +354,7 @@ type CostEventStorer interface {
 	GetUsageSummary(ctx context.Context, filter UsageFilter) (UsageSummary, error)
 	// GetUsageGrouped returns one aggregated row per distinct value of groupBy.
 	// Allowed groupBy values: "provider", "model", "agent", "organization",
-	// "project", "goal", "billing_code", "status".
+	// "project", "goal", "billing_code", "status", "api_key".
 	GetUsageGrouped(ctx context.Context, filter UsageFilter, groupBy string, limit int) ([]UsageSummary, error)
 	// GetUsageTimeSeries returns aggregated buckets.
 	// Allowed bucket values: "hour", "day".
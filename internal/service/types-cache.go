@@ -0,0 +1,49 @@
+package service
+
+import (
+	"context"
+	"time"
+)
+
+// Response cache entry kinds.
+const (
+	ResponseCacheKindChat       = "chat"
+	ResponseCacheKindEmbeddings = "embeddings"
+)
+
+// ResponseCacheEntry is one cached gateway response. Body holds the
+// serialized OpenAI-shaped response exactly as it was returned upstream.
+//
+// Scope and Embedding are set only for chat entries that also feed the
+// semantic cache: Scope groups entries that may answer each other (same
+// token, model, and system prompt) and Embedding is the vector of the
+// last user message.
+type ResponseCacheEntry struct {
+	Key       string    `json:"key"`
+	TokenID   string    `json:"token_id"`
+	Kind      string    `json:"kind"`
+	Model     string    `json:"model"`
+	Body      []byte    `json:"body"`
+	Scope     string    `json:"scope,omitempty"`
+	Embedding []float64 `json:"embedding,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// ResponseCacheStorer defines persistence for the gateway response cache.
+type ResponseCacheStorer interface {
+	// GetResponseCache returns the entry for key, or nil when it is
+	// missing or expired.
+	GetResponseCache(ctx context.Context, key string) (*ResponseCacheEntry, error)
+	// PutResponseCache inserts or replaces the entry for entry.Key.
+	PutResponseCache(ctx context.Context, entry ResponseCacheEntry) error
+	// DeleteResponseCacheBefore removes entries that expired before the
+	// cutoff and returns the number deleted.
+	DeleteResponseCacheBefore(ctx context.Context, cutoff time.Time) (int64, error)
+	// ListResponseCacheByScope returns unexpired entries in the semantic
+	// scope that carry an embedding, newest first, capped at limit.
+	ListResponseCacheByScope(ctx context.Context, scope string, limit int) ([]ResponseCacheEntry, error)
+	// PurgeResponseCache deletes every entry for tokenID ("" = all
+	// tokens) and returns the number deleted.
+	PurgeResponseCache(ctx context.Context, tokenID string) (int64, error)
+}
@@ -0,0 +1,41 @@
+package service
+
+import "context"
+
+// ─── Model capabilities ───
+
+// ModelCapability describes what a provider/model combination accepts.
+// Boolean capabilities are tri-state: nil means unknown, and the gateway
+// only rejects or adapts a request when a capability is explicitly false.
+// Zero limits are likewise treated as unknown.
+type ModelCapability struct {
+	ID          string `json:"id"`
+	ProviderKey string `json:"provider_key"`
+	Model       string `json:"model"`
+
+	ContextWindow   int `json:"context_window,omitempty"`
+	MaxOutputTokens int `json:"max_output_tokens,omitempty"`
+
+	Vision     *bool `json:"vision,omitempty"`
+	Audio      *bool `json:"audio,omitempty"`
+	Tools      *bool `json:"tools,omitempty"`
+	Reasoning  *bool `json:"reasoning,omitempty"`
+	JSONSchema *bool `json:"json_schema,omitempty"`
+	Streaming  *bool `json:"streaming,omitempty"`
+
+	// Source records where the values came from ("discovery", "pi.dev",
+	// "defaults", "manual"). ManualOverride rows are kept by syncs unless
+	// the sync asks to overwrite them.
+	Source         string `json:"source,omitempty"`
+	ManualOverride bool   `json:"manual_override"`
+	CreatedAt      string `json:"created_at"`
+	UpdatedAt      string `json:"updated_at"`
+}
+
+// ModelCapabilityStorer persists the per-model capability registry.
+type ModelCapabilityStorer interface {
+	ListModelCapabilities(ctx context.Context) ([]ModelCapability, error)
+	// SetModelCapability upserts by (provider_key, model).
+	SetModelCapability(ctx context.Context, c ModelCapability) error
+	DeleteModelCapability(ctx context.Context, id string) error
+}
@@ -133,6 +133,7 @@ type BotConfig struct {
 	ContainerMemory string             `json:"container_memory,omitempty"`
 	SpeechToText    string             `json:"speech_to_text,omitempty"`
 	WhisperModel    string             `json:"whisper_model,omitempty"`
+	GuardrailIDs    []string           `json:"guardrail_ids,omitempty"`
 	CreatedAt       string             `json:"created_at"`
 	UpdatedAt       string             `json:"updated_at"`
 	CreatedBy       string             `json:"created_by"`
@@ -0,0 +1,106 @@
+package service
+
+import (
+	"context"
+
+	"github.com/rakunlabs/query"
+)
+
+// ─── Guardrail Policies ───
+//
+// A GuardrailPolicy is a named set of checks run around an LLM call. Input
+// checks run before the upstream call (PII and regex redaction or
+// blocking, deny-lists, moderation, size limits); output checks run on the
+// answer (moderation, JSON validity). Policies are attached by ID to API
+// tokens, agents and bots; every attached policy applies, in order.
+
+// Guardrail actions.
+const (
+	GuardrailActionBlock  = "block"  // reject the call
+	GuardrailActionRedact = "redact" // replace the match and continue
+	GuardrailActionWarn   = "warn"   // record the violation and continue
+)
+
+// Built-in PII entities detected by GuardrailPIIRule.
+const (
+	GuardrailPIIEmail      = "email"
+	GuardrailPIIPhone      = "phone"
+	GuardrailPIICreditCard = "credit_card"
+	GuardrailPIISSN        = "ssn"
+	GuardrailPIIIBAN       = "iban"
+	GuardrailPIIIPAddress  = "ip_address"
+	GuardrailPIIAPIKey     = "api_key"
+)
+
+// GuardrailPolicy is a reusable set of pre- and post-call checks.
+type GuardrailPolicy struct {
+	ID          string          `json:"id"`
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Enabled     bool            `json:"enabled"`
+	Config      GuardrailConfig `json:"config"`
+	CreatedAt   string          `json:"created_at"`
+	UpdatedAt   string          `json:"updated_at"`
+	CreatedBy   string          `json:"created_by"`
+	UpdatedBy   string          `json:"updated_by"`
+}
+
+// GuardrailConfig holds a policy's checks, stored as JSON.
+type GuardrailConfig struct {
+	Input  GuardrailInputConfig  `json:"input"`
+	Output GuardrailOutputConfig `json:"output"`
+}
+
+// GuardrailInputConfig lists the checks run on the request messages before
+// the upstream call. System and developer messages are not scanned.
+type GuardrailInputConfig struct {
+	PII        []GuardrailPIIRule   `json:"pii,omitempty"`
+	Patterns   []GuardrailPattern   `json:"patterns,omitempty"`
+	DenyList   []string             `json:"deny_list,omitempty"` // case-insensitive substrings; any match blocks
+	Moderation *GuardrailModeration `json:"moderation,omitempty"`
+	MaxTokens  int                  `json:"max_tokens,omitempty"` // estimated prompt tokens; 0 = no limit
+	MaxChars   int                  `json:"max_chars,omitempty"`  // scanned text length; 0 = no limit
+}
+
+// GuardrailOutputConfig lists the checks run on the model's answer.
+type GuardrailOutputConfig struct {
+	Moderation *GuardrailModeration `json:"moderation,omitempty"`
+	// RequireJSON rejects answers that are not valid JSON. Requests with a
+	// json_object or json_schema response_format are checked even when
+	// this is false.
+	RequireJSON bool   `json:"require_json,omitempty"`
+	JSONAction  string `json:"json_action,omitempty"` // "block" (default) | "warn"
+}
+
+// GuardrailPIIRule detects one built-in PII entity.
+type GuardrailPIIRule struct {
+	Entity      string `json:"entity"`                // GuardrailPII* constant
+	Action      string `json:"action"`                // "redact" (default) | "block" | "warn"
+	Replacement string `json:"replacement,omitempty"` // default "[REDACTED_<ENTITY>]"
+}
+
+// GuardrailPattern is a custom regular expression check.
+type GuardrailPattern struct {
+	Name        string `json:"name"`
+	Pattern     string `json:"pattern"`               // RE2 syntax
+	Action      string `json:"action"`                // "redact" (default) | "block" | "warn"
+	Replacement string `json:"replacement,omitempty"` // default "[REDACTED]"
+}
+
+// GuardrailModeration runs text through a ModerationProvider.
+type GuardrailModeration struct {
+	Model  string `json:"model"`            // "provider/model" of a moderation-capable provider
+	Action string `json:"action,omitempty"` // "block" (default) | "warn"
+	// Categories limits which flagged categories count as a violation.
+	// Empty means any flagged result.
+	Categories []string `json:"categories,omitempty"`
+}
+
+// GuardrailPolicyStorer defines CRUD operations for guardrail policies.
+type GuardrailPolicyStorer interface {
+	ListGuardrailPolicies(ctx context.Context, q *query.Query) (*ListResult[GuardrailPolicy], error)
+	GetGuardrailPolicy(ctx context.Context, id string) (*GuardrailPolicy, error)
+	CreateGuardrailPolicy(ctx context.Context, p GuardrailPolicy) (*GuardrailPolicy, error)
+	UpdateGuardrailPolicy(ctx context.Context, id string, p GuardrailPolicy) (*GuardrailPolicy, error)
+	DeleteGuardrailPolicy(ctx context.Context, id string) error
+}
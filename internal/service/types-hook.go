@@ -0,0 +1,48 @@
+package service
+
+import (
+	"context"
+
+	"github.com/rakunlabs/query"
+)
+
+// ─── Gateway Hooks ───
+//
+// A GatewayHook is an admin-defined JavaScript snippet run by the gateway
+// around /v1/chat/completions calls. The script defines onRequest(req, ctx)
+// and/or onResponse(resp, req, ctx); each receives the OpenAI-shaped body
+// as a plain object and may mutate it in place or return a replacement.
+// Hooks are attached by ID to API tokens and providers.
+
+// Gateway hook error modes.
+const (
+	GatewayHookOnErrorSkip = "skip" // log and continue with the unmodified body
+	GatewayHookOnErrorFail = "fail" // reject the request
+)
+
+// GatewayHook is a stored request/response hook script.
+type GatewayHook struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Enabled     bool   `json:"enabled"`
+	Script      string `json:"script"`
+	// TimeoutMs bounds a single hook invocation. 0 means the server
+	// default; values above the server maximum are clamped.
+	TimeoutMs int `json:"timeout_ms"`
+	// OnError is GatewayHookOnErrorSkip (default) or GatewayHookOnErrorFail.
+	OnError   string `json:"on_error"`
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+	CreatedBy string `json:"created_by"`
+	UpdatedBy string `json:"updated_by"`
+}
+
+// GatewayHookStorer defines CRUD operations for gateway hooks.
+type GatewayHookStorer interface {
+	ListGatewayHooks(ctx context.Context, q *query.Query) (*ListResult[GatewayHook], error)
+	GetGatewayHook(ctx context.Context, id string) (*GatewayHook, error)
+	CreateGatewayHook(ctx context.Context, h GatewayHook) (*GatewayHook, error)
+	UpdateGatewayHook(ctx context.Context, id string, h GatewayHook) (*GatewayHook, error)
+	DeleteGatewayHook(ctx context.Context, id string) error
+}
@@ -20,8 +20,10 @@ var SupportedProviderTypes = []string{
 	"bedrock",
 	"vertex",
 	"vertex-gemini",
+	"vertex-anthropic",
 	"gemini",
 	"cohere",
+	"ollama",
 	"minimax",
 }
 
@@ -58,6 +60,78 @@ type LLMStreamProvider interface {
 	Proxy(w http.ResponseWriter, r *http.Request, path string) error
 }
 
+// TokenCountProvider is optionally implemented by providers that expose a
+// native token counting API (Anthropic count_tokens, Gemini countTokens).
+// The gateway's tokenize endpoint prefers it over the local estimate.
+// Implementations return ErrTokenCountUnsupported when the configured
+// auth scheme or endpoint cannot count tokens.
+type TokenCountProvider interface {
+	CountTokens(ctx context.Context, model string, messages []Message, tools []Tool) (int, error)
+}
+
+// ErrTokenCountUnsupported reports that a provider cannot count tokens
+// natively for the current configuration.
+var ErrTokenCountUnsupported = errors.New("native token counting is not supported")
+
+// Unwrapper is implemented by providers that wrap providers of a single
+// type (key pools). Such a wrapper implements every optional interface
+// whether or not the wrapped type does, and fails the calls it cannot
+// serve with ErrUnsupportedOperation.
+type Unwrapper interface {
+	Unwrap() LLMProvider
+}
+
+// As returns prov as the optional interface T when it really supports it:
+// prov must implement T, and so must the provider behind every Unwrapper.
+// Capability checks use As rather than a plain type assertion so that a
+// key pool is treated like the provider type it pools.
+func As[T any](prov LLMProvider) (T, bool) {
+	t, ok := prov.(T)
+	if !ok {
+		return t, false
+	}
+	for inner := prov; ; {
+		u, ok := inner.(Unwrapper)
+		if !ok {
+			return t, true
+		}
+		inner = u.Unwrap()
+		if _, ok := inner.(T); !ok {
+			var zero T
+			return zero, false
+		}
+	}
+}
+
+// NativeChoicesProvider is optionally implemented by providers whose Chat
+// honours ChatOptions.N upstream and returns the extra completions in
+// LLMResponse.ExtraChoices (OpenAI-compatible APIs). The gateway emulates
+// N > 1 for every other provider by fanning out parallel Chat calls.
+type NativeChoicesProvider interface {
+	NativeChoices() bool
+}
+
+// ModelManager is optionally implemented by providers that host their
+// own models and can install or remove them (Ollama). The provider admin
+// API and builtin tools check for it via type assertion.
+type ModelManager interface {
+	// ListModels returns the installed model names.
+	ListModels(ctx context.Context) ([]string, error)
+	// PullModel downloads model. progress, when non-nil, receives each
+	// status update as the pull runs.
+	PullModel(ctx context.Context, model string, progress func(ModelPullProgress)) error
+	// DeleteModel removes an installed model.
+	DeleteModel(ctx context.Context, model string) error
+}
+
+// ModelPullProgress is one status update from a model pull.
+type ModelPullProgress struct {
+	Status    string `json:"status"`
+	Digest    string `json:"digest,omitempty"`
+	Total     int64  `json:"total,omitempty"`
+	Completed int64  `json:"completed,omitempty"`
+}
+
 // ─── LLM Request Options ───
 
 // ChatOptions contains optional per-request parameters that control
@@ -119,8 +193,9 @@ type ChatOptions struct {
 	// (inverted). Gemini accepts it via toolConfig (best-effort).
 	ParallelToolCalls *bool
 
-	// N is the number of completions to generate. OpenAI/Vertex passthrough
-	// only; non-OpenAI providers always return 1.
+	// N is the number of completions to generate. Passed upstream only by
+	// NativeChoicesProvider implementations; other providers ignore it and
+	// the gateway emulates it with parallel calls.
 	N *int
 
 	// PresencePenalty (−2.0..2.0). OpenAI/Vertex passthrough.
@@ -150,6 +225,12 @@ type ChatOptions struct {
 	Metadata    map[string]any
 	ServiceTier string
 
+	// DisablePromptCache turns off automatic prompt caching for the call:
+	// the cache_control breakpoints the Anthropic adapter places on the
+	// stable prefix and the cachedContents the Gemini adapter creates for
+	// long system prompts and tool catalogs.
+	DisablePromptCache bool
+
 	// ExtraBody is merged into the upstream request body by every
 	// provider adapter AFTER its own field mapping. Keys collide-overwrite
 	// the adapter's own keys, so callers can use this to override what
@@ -188,6 +269,12 @@ type ContentBlock struct {
 	// thoughtSignature or Codex encrypted reasoning) that must be echoed back on
 	// the corresponding tool_use block across function-calling turns.
 	ThoughtSignature string `json:"thought_signature,omitempty"`
+	// Thinking and Signature carry an Anthropic "thinking" block; Data
+	// carries a "redacted_thinking" block. Claude requires them to be
+	// replayed unchanged before the tool_use they led to.
+	Thinking  string `json:"thinking,omitempty"`
+	Signature string `json:"signature,omitempty"`
+	Data      string `json:"data,omitempty"`
 }
 
 // MediaSource represents a media source for content blocks (images, documents, audio, video).
@@ -218,6 +305,11 @@ type Usage struct {
 	// CompletionTokens respectively.
 	AudioPromptTokens     int
 	AudioCompletionTokens int
+
+	// APIKey is the label of the pooled API key that served the call
+	// (providers with api_keys); empty otherwise. Recorded on cost events
+	// for per-key reporting, never sent to clients.
+	APIKey string `json:"-"`
 }
 
 // TotalInputTokens returns all input-side tokens, including prompt-cache reads
@@ -236,6 +328,28 @@ func (u Usage) TotalTokenCount() int {
 	return u.TotalInputTokens() + u.CompletionTokens
 }
 
+// Add returns the bucket-wise sum of u and o. A total is kept only when
+// both sides report one; the API key is u's, or o's when u has none.
+func (u Usage) Add(o Usage) Usage {
+	sum := Usage{
+		PromptTokens:          u.PromptTokens + o.PromptTokens,
+		CompletionTokens:      u.CompletionTokens + o.CompletionTokens,
+		CacheReadTokens:       u.CacheReadTokens + o.CacheReadTokens,
+		CacheWriteTokens:      u.CacheWriteTokens + o.CacheWriteTokens,
+		ReasoningTokens:       u.ReasoningTokens + o.ReasoningTokens,
+		AudioPromptTokens:     u.AudioPromptTokens + o.AudioPromptTokens,
+		AudioCompletionTokens: u.AudioCompletionTokens + o.AudioCompletionTokens,
+	}
+	if u.TotalTokens > 0 && o.TotalTokens > 0 {
+		sum.TotalTokens = u.TotalTokens + o.TotalTokens
+	}
+	sum.APIKey = u.APIKey
+	if sum.APIKey == "" {
+		sum.APIKey = o.APIKey
+	}
+	return sum
+}
+
 // InlineImage represents a base64-encoded image returned by a provider (e.g. Gemini).
 type InlineImage struct {
 	MimeType string // e.g. "image/png"
@@ -252,6 +366,10 @@ type StreamChunk struct {
 	// thinking models, Anthropic extended thinking).
 	ReasoningContent string
 
+	// ReasoningSignature is the signature of the thinking block streamed in
+	// ReasoningContent (Anthropic). It arrives once, at the end of the block.
+	ReasoningSignature string
+
 	// InlineImages contains any base64-encoded images in this chunk (e.g. from Gemini image generation).
 	InlineImages []InlineImage
 
@@ -274,11 +392,14 @@ type StreamChunk struct {
 type LLMResponse struct {
 	Content          string
 	ReasoningContent string
-	InlineImages     []InlineImage
-	ToolCalls        []ToolCall
-	Finished         bool
-	Usage            Usage
-	Header           http.Header
+	// ReasoningSignature signs ReasoningContent (Anthropic extended
+	// thinking); clients echo it back to continue a tool loop.
+	ReasoningSignature string
+	InlineImages       []InlineImage
+	ToolCalls          []ToolCall
+	Finished           bool
+	Usage              Usage
+	Header             http.Header
 
 	// FinishReason is the upstream finish reason normalised to OpenAI's
 	// vocabulary: "stop" | "length" | "content_filter" | "tool_calls" |
@@ -293,6 +414,11 @@ type LLMResponse struct {
 	// Logprobs, when non-nil, contains the raw logprobs object from the
 	// upstream response. Forwarded verbatim to clients (OpenAI passthrough).
 	Logprobs any
+
+	// ExtraChoices holds the completions after the first when several
+	// were requested (ChatOptions.N > 1); the response itself is choice 0.
+	// Usage and Header of the outer response cover all choices.
+	ExtraChoices []LLMResponse
 }
 
 // ToolCall represents a single tool invocation within an LLM response.
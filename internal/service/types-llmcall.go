@@ -21,7 +21,8 @@ const (
 	ObservationGeneration = "generation"
 	// ObservationTool is a tool execution requested by a generation.
 	ObservationTool = "tool"
-	// ObservationEvent is a point-in-time occurrence (task lifecycle).
+	// ObservationEvent is a point-in-time occurrence (task lifecycle,
+	// guardrail violations).
 	ObservationEvent = "event"
 )
 
@@ -138,7 +139,7 @@ type LLMCall struct {
 	// TimeToFirstTokenMs is only set for true streaming calls.
 	TimeToFirstTokenMs int64 `json:"time_to_first_token_ms,omitempty"`
 
-	Status       string `json:"status"` // "ok" | "error"
+	Status       string `json:"status"` // "ok" | "error" | "cancelled" (losing at_hedge attempt)
 	ErrorCode    string `json:"error_code,omitempty"`
 	ErrorMessage string `json:"error_message,omitempty"`
 	FinishReason string `json:"finish_reason,omitempty"`
@@ -3,6 +3,7 @@ package service
 import (
 	"context"
 	"errors"
+	"io"
 )
 
 // ErrUnsupportedOperation is returned by providers that implement a media
@@ -18,8 +19,12 @@ var ErrUnsupportedOperation = errors.New("operation not supported by provider")
 // The workflow engine checks for these via type assertion.
 
 // ImageProvider generates and edits images.
+// Providers without an edit or variation endpoint return
+// ErrUnsupportedOperation from those methods.
 type ImageProvider interface {
 	GenerateImage(ctx context.Context, req ImageGenerateRequest) (*ImageResponse, error)
+	EditImage(ctx context.Context, req ImageEditRequest) (*ImageResponse, error)
+	CreateImageVariation(ctx context.Context, req ImageVariationRequest) (*ImageResponse, error)
 }
 
 // AudioProvider handles text-to-speech and speech-to-text.
@@ -46,6 +51,19 @@ type RerankProvider interface {
 	Rerank(ctx context.Context, req RerankRequest) (*RerankResponse, error)
 }
 
+// VideoProvider generates videos as asynchronous jobs: SubmitVideo starts
+// a job, GetVideo polls it until it reaches a terminal status, and
+// DownloadVideo streams the finished file. Providers without a cancel
+// endpoint return ErrUnsupportedOperation from CancelVideo.
+type VideoProvider interface {
+	SubmitVideo(ctx context.Context, req VideoGenerateRequest) (*VideoJob, error)
+	GetVideo(ctx context.Context, id string) (*VideoJob, error)
+	CancelVideo(ctx context.Context, id string) error
+	// DownloadVideo returns the finished video and its content type. The
+	// caller closes the reader.
+	DownloadVideo(ctx context.Context, id string) (io.ReadCloser, string, error)
+}
+
 // ─── Media Request/Response Types ───
 
 // ImageGenerateRequest describes a text-to-image generation request.
@@ -58,6 +76,33 @@ type ImageGenerateRequest struct {
 	Style   string `json:"style,omitempty"`   // e.g. "vivid", "natural"
 }
 
+// ImageInput is an uploaded source image or mask.
+type ImageInput struct {
+	Base64      string `json:"base64"`             // base64-encoded image data (data URIs accepted)
+	ContentType string `json:"content_type"`       // e.g. "image/png"
+	Filename    string `json:"filename,omitempty"` // original filename, including its extension
+}
+
+// ImageEditRequest describes an edit (or inpainting, when Mask is set) of
+// one or more source images.
+type ImageEditRequest struct {
+	Images  []ImageInput `json:"images"`
+	Mask    *ImageInput  `json:"mask,omitempty"` // transparent areas mark the region to edit
+	Prompt  string       `json:"prompt"`
+	Model   string       `json:"model,omitempty"`
+	N       int          `json:"n,omitempty"`
+	Size    string       `json:"size,omitempty"`
+	Quality string       `json:"quality,omitempty"`
+}
+
+// ImageVariationRequest describes a request for variations of one image.
+type ImageVariationRequest struct {
+	Image ImageInput `json:"image"`
+	Model string     `json:"model,omitempty"`
+	N     int        `json:"n,omitempty"`
+	Size  string     `json:"size,omitempty"`
+}
+
 // ImageResponse is the result of an image generation or edit operation.
 type ImageResponse struct {
 	Images []GeneratedImage `json:"images"`
@@ -71,6 +116,47 @@ type GeneratedImage struct {
 	RevisedPrompt string `json:"revised_prompt,omitempty"` // prompt revised by the model (DALL-E 3)
 }
 
+// Video job statuses, matching the OpenAI Videos API.
+const (
+	VideoStatusQueued     = "queued"
+	VideoStatusInProgress = "in_progress"
+	VideoStatusCompleted  = "completed"
+	VideoStatusFailed     = "failed"
+	VideoStatusCancelled  = "cancelled"
+)
+
+// VideoStatusTerminal reports whether a job in status will not change
+// any more.
+func VideoStatusTerminal(status string) bool {
+	switch status {
+	case VideoStatusCompleted, VideoStatusFailed, VideoStatusCancelled:
+		return true
+	}
+	return false
+}
+
+// VideoGenerateRequest describes a text-to-video (or image-to-video, when
+// InputImage is set) generation request.
+type VideoGenerateRequest struct {
+	Prompt     string      `json:"prompt"`
+	Model      string      `json:"model,omitempty"`       // e.g. "sora-2"
+	Seconds    int         `json:"seconds,omitempty"`     // clip length; 0 = provider default
+	Size       string      `json:"size,omitempty"`        // e.g. "1280x720"
+	InputImage *ImageInput `json:"input_image,omitempty"` // first frame / reference image
+}
+
+// VideoJob is the provider's view of a video generation job.
+type VideoJob struct {
+	ID       string `json:"id"`
+	Status   string `json:"status"`
+	Progress int    `json:"progress,omitempty"` // 0-100
+	Model    string `json:"model,omitempty"`
+	Seconds  int    `json:"seconds,omitempty"`
+	Size     string `json:"size,omitempty"`
+	URL      string `json:"url,omitempty"` // download URL, for providers that return one
+	Error    string `json:"error,omitempty"`
+}
+
 // AudioGenerateRequest describes a text-to-speech request.
 type AudioGenerateRequest struct {
 	Input          string  `json:"input"`                     // text to synthesize
@@ -0,0 +1,38 @@
+package service
+
+import (
+	"context"
+	"time"
+)
+
+// StoredResponse is a Responses API result kept server-side so later
+// requests can continue from it with previous_response_id.
+//
+// InputItems holds only the input items sent with this request (as a JSON
+// array); earlier turns are reached through PreviousResponseID. Body is
+// the serialized response object returned to the client.
+type StoredResponse struct {
+	ID                 string    `json:"id"`
+	TokenID            string    `json:"token_id"`
+	PreviousResponseID string    `json:"previous_response_id,omitempty"`
+	Model              string    `json:"model"`
+	InputItems         []byte    `json:"input_items"`
+	Body               []byte    `json:"body"`
+	CreatedAt          time.Time `json:"created_at"`
+	ExpiresAt          time.Time `json:"expires_at"`
+}
+
+// StoredResponseStorer defines persistence for stored Responses API results.
+type StoredResponseStorer interface {
+	// GetStoredResponse returns the response with id, or nil when it is
+	// missing or expired.
+	GetStoredResponse(ctx context.Context, id string) (*StoredResponse, error)
+	// PutStoredResponse inserts or replaces the response with resp.ID.
+	PutStoredResponse(ctx context.Context, resp StoredResponse) error
+	// DeleteStoredResponse removes the response with id. Deleting a
+	// missing response is not an error.
+	DeleteStoredResponse(ctx context.Context, id string) error
+	// DeleteStoredResponsesBefore removes responses that expired before
+	// the cutoff and returns the number deleted.
+	DeleteStoredResponsesBefore(ctx context.Context, cutoff time.Time) (int64, error)
+}
@@ -22,20 +22,25 @@ const (
 type APIToken struct {
 	ID                   string                 `json:"id"`
 	Name                 string                 `json:"name"`
-	TokenPrefix          string                 `json:"token_prefix"`           // first 8 chars for display (e.g. "at_xxxx…")
-	AllowedProvidersMode string                 `json:"allowed_providers_mode"` // "all" (default/""), "none", or "list"
-	AllowedProviders     types.Slice[string]    `json:"allowed_providers"`      // used when mode = "list"
-	AllowedModelsMode    string                 `json:"allowed_models_mode"`    // "all" (default/""), "none", or "list"
-	AllowedModels        types.Slice[string]    `json:"allowed_models"`         // used when mode = "list" ("provider/model" format)
-	AllowedWebhooksMode  string                 `json:"allowed_webhooks_mode"`  // "all" (default/""), "none", or "list"
-	AllowedWebhooks      types.Slice[string]    `json:"allowed_webhooks"`       // used when mode = "list" (trigger IDs or aliases)
-	AllowedMCPsMode      string                 `json:"allowed_mcps_mode"`      // "all" (default/""), "none", or "list"
-	AllowedMCPs          types.Slice[string]    `json:"allowed_mcps"`           // used when mode = "list" (gateway MCP server names)
-	ExpiresAt            types.Null[types.Time] `json:"expires_at"`             // zero value = no expiry
-	TotalTokenLimit      types.Null[int64]      `json:"total_token_limit"`      // max total tokens allowed (across all models); nil = unlimited
-	SpendLimitCents      types.Null[float64]    `json:"spend_limit_cents"`      // max spend in cents for the current reset window; nil = unlimited
-	LimitResetInterval   types.Null[string]     `json:"limit_reset_interval"`   // "daily", "weekly", "monthly", or nil = manual only
-	LastResetAt          types.Null[types.Time] `json:"last_reset_at"`          // last time usage counters were reset
+	TokenPrefix          string                 `json:"token_prefix"`             // first 8 chars for display (e.g. "at_xxxx…")
+	AllowedProvidersMode string                 `json:"allowed_providers_mode"`   // "all" (default/""), "none", or "list"
+	AllowedProviders     types.Slice[string]    `json:"allowed_providers"`        // used when mode = "list"
+	AllowedModelsMode    string                 `json:"allowed_models_mode"`      // "all" (default/""), "none", or "list"
+	AllowedModels        types.Slice[string]    `json:"allowed_models"`           // used when mode = "list" ("provider/model" format)
+	AllowedWebhooksMode  string                 `json:"allowed_webhooks_mode"`    // "all" (default/""), "none", or "list"
+	AllowedWebhooks      types.Slice[string]    `json:"allowed_webhooks"`         // used when mode = "list" (trigger IDs or aliases)
+	AllowedMCPsMode      string                 `json:"allowed_mcps_mode"`        // "all" (default/""), "none", or "list"
+	AllowedMCPs          types.Slice[string]    `json:"allowed_mcps"`             // used when mode = "list" (gateway MCP server names)
+	ExpiresAt            types.Null[types.Time] `json:"expires_at"`               // zero value = no expiry
+	TotalTokenLimit      types.Null[int64]      `json:"total_token_limit"`        // max total tokens allowed (across all models); nil = unlimited
+	SpendLimitCents      types.Null[float64]    `json:"spend_limit_cents"`        // max spend in cents for the current reset window; nil = unlimited
+	LimitResetInterval   types.Null[string]     `json:"limit_reset_interval"`     // "daily", "weekly", "monthly", or nil = manual only
+	LastResetAt          types.Null[types.Time] `json:"last_reset_at"`            // last time usage counters were reset
+	CacheEnabled         bool                   `json:"cache_enabled"`            // opt-in to the gateway response cache
+	CacheTTL             types.Null[string]     `json:"cache_ttl"`                // cache entry lifetime (e.g. "1h"); nil = server default
+	SemanticThreshold    types.Null[float64]    `json:"semantic_cache_threshold"` // cosine similarity (0-1] for semantic hits; nil = exact matches only
+	GuardrailIDs         types.Slice[string]    `json:"guardrail_ids"`            // guardrail policies applied to every chat call
+	HookIDs              types.Slice[string]    `json:"hook_ids"`                 // gateway hooks run on chat completion requests
 	CreatedAt            types.Time             `json:"created_at"`
 	LastUsedAt           types.Null[types.Time] `json:"last_used_at"`
 	CreatedBy            string                 `json:"created_by"`
@@ -59,6 +64,7 @@ func ResolveAccessMode(mode string, items []string) string {
 // APITokenStorer defines CRUD operations for API tokens.
 type APITokenStorer interface {
 	ListAPITokens(ctx context.Context, q *query.Query) (*ListResult[APIToken], error)
+	GetAPIToken(ctx context.Context, id string) (*APIToken, error)
 	GetAPITokenByHash(ctx context.Context, hash string) (*APIToken, error)
 	CreateAPIToken(ctx context.Context, token APIToken, tokenHash string) (*APIToken, error)
 	UpdateAPIToken(ctx context.Context, id string, token APIToken) (*APIToken, error)
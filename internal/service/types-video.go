@@ -0,0 +1,49 @@
+package service
+
+import (
+	"context"
+	"time"
+)
+
+// VideoGeneration is a video job submitted through the gateway. The
+// upstream provider runs the job; the gateway polls it, downloads the
+// result into the workspace, and records its cost.
+type VideoGeneration struct {
+	ID           string     `json:"id"`
+	TokenID      string     `json:"token_id"`
+	TokenName    string     `json:"token_name"`
+	ProviderKey  string     `json:"provider_key"`
+	Model        string     `json:"model"`
+	UpstreamID   string     `json:"upstream_id"`
+	Prompt       string     `json:"prompt"`
+	Seconds      int        `json:"seconds"`
+	Size         string     `json:"size,omitempty"`
+	Status       string     `json:"status"`
+	Progress     int        `json:"progress"`
+	ErrorMessage string     `json:"error_message,omitempty"`
+	FilePath     string     `json:"file_path,omitempty"`
+	ContentType  string     `json:"content_type,omitempty"`
+	Bytes        int64      `json:"bytes"`
+	CostCents    float64    `json:"cost_cents"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+	CompletedAt  *time.Time `json:"completed_at,omitempty"`
+}
+
+// VideoGenerationStorer defines persistence for gateway video jobs.
+type VideoGenerationStorer interface {
+	// GetVideoGeneration returns the job with id, or nil when it is missing.
+	GetVideoGeneration(ctx context.Context, id string) (*VideoGeneration, error)
+	// ListVideoGenerations returns the token's jobs, newest first.
+	ListVideoGenerations(ctx context.Context, tokenID string) ([]VideoGeneration, error)
+	// ListVideoGenerationsByStatus returns jobs in any of the given
+	// statuses, oldest first. Used to resume polling after a restart.
+	ListVideoGenerationsByStatus(ctx context.Context, statuses ...string) ([]VideoGeneration, error)
+	// PutVideoGeneration inserts or replaces the job with v.ID.
+	PutVideoGeneration(ctx context.Context, v VideoGeneration) error
+	// UpdateVideoGeneration replaces the job with v.ID unless the stored
+	// job already reached a terminal status, in one conditional write. It
+	// reports whether the job was updated, so only one instance finishes
+	// (and bills) a job.
+	UpdateVideoGeneration(ctx context.Context, v VideoGeneration) (bool, error)
+}
@@ -347,7 +347,22 @@ func (e *Engine) parseGraph(ctx context.Context, graph service.WorkflowGraph, re
 // completes/fails if no output node is reached). This allows sync callers
 // to respond immediately while the rest of the graph continues in the
 // background. Pass nil if early output notification is not needed.
+//
+// The run and each node execution are traced as OpenTelemetry spans under
+// the span in ctx.
 func (e *Engine) Run(ctx context.Context, graph service.WorkflowGraph, inputs map[string]any, entryNodeIDs []string, outputCh chan<- EarlyOutput) (*RunResult, error) {
+	ctx, span := startRunSpan(ctx, entryNodeIDs, len(graph.Nodes))
+	defer span.End()
+
+	result, err := e.run(ctx, graph, inputs, entryNodeIDs, outputCh)
+	if err != nil {
+		markSpanError(span, err)
+	}
+
+	return result, err
+}
+
+func (e *Engine) run(ctx context.Context, graph service.WorkflowGraph, inputs map[string]any, entryNodeIDs []string, outputCh chan<- EarlyOutput) (*RunResult, error) {
 	// Ensure outputCh is always signaled exactly once so callers never block.
 	var outputOnce sync.Once
 	signalOutput := func(outputs map[string]any, err error) {
@@ -433,7 +448,7 @@ func (e *Engine) Run(ctx context.Context, graph service.WorkflowGraph, inputs ma
 		logi.Ctx(ctx).Debug("node started", nodeLogAttrs(st)...)
 
 		startTime := time.Now()
-		result, err := st.noder.Run(ctx, reg, nodeInputs)
+		result, err := runNode(ctx, st, reg, nodeInputs)
 		durationMs := time.Since(startTime).Milliseconds()
 
 		if err != nil {
@@ -633,7 +648,7 @@ func (e *Engine) runFanOutBranch(ctx context.Context, sourceNodeID string, data
 		nodeInputs := e.gatherInputs(nodeID, states, branchOutputs)
 
 		logi.Ctx(ctx).Debug("node started", nodeLogAttrs(st)...)
-		result, err := st.noder.Run(ctx, reg, nodeInputs)
+		result, err := runNode(ctx, st, reg, nodeInputs)
 		if err != nil {
 			if err == ErrStopBranch {
 				continue
@@ -115,6 +115,11 @@ func SetupGojaVM(vm *goja.Runtime, inputs map[string]any, varLookup ...VarLookup
 		return err
 	}
 
+	// Register HTTP helper functions for making external API calls from JS.
+	if err := registerGojaHTTPHelpers(vm); err != nil {
+		return err
+	}
+
 	// Register getVar if a lookup function was provided.
 	if len(varLookup) > 0 && varLookup[0] != nil {
 		lookup := varLookup[0]
@@ -146,6 +151,23 @@ func SetupGojaVM(vm *goja.Runtime, inputs map[string]any, varLookup ...VarLookup
 	return nil
 }
 
+// SetupGojaSandbox configures a goja runtime like SetupGojaVM but without
+// the HTTP helpers or getVar, for scripts that run inline on a request path
+// and must not block on I/O. Inputs are set as globals unchanged.
+func SetupGojaSandbox(vm *goja.Runtime, inputs map[string]any) error {
+	if err := registerGojaHelpers(vm); err != nil {
+		return err
+	}
+
+	for k, v := range inputs {
+		if err := vm.Set(k, v); err != nil {
+			return fmt.Errorf("failed to set %q: %w", k, err)
+		}
+	}
+
+	return nil
+}
+
 // registerGojaHelpers adds global utility functions to the goja VM.
 //
 // Available in JS:
@@ -300,11 +322,6 @@ func registerGojaHelpers(vm *goja.Runtime) error {
 		return err
 	}
 
-	// Register HTTP helper functions for making external API calls from JS.
-	if err := registerGojaHTTPHelpers(vm); err != nil {
-		return err
-	}
-
 	return nil
 }
 
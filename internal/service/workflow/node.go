@@ -446,7 +446,9 @@ type UsageEvent struct {
 	RunID          string
 	BillingCode    string
 	Usage          service.Usage
-	LatencyMs      int64
+	// VideoSeconds is the seconds of generated video, billed per second.
+	VideoSeconds int
+	LatencyMs    int64
 	// Status is "ok" on success, "error" on failure. Empty defaults to "ok".
 	Status       string
 	ErrorCode    string
@@ -485,8 +487,8 @@ type LoopGovernor interface {
 	LimitWithTools(ctx context.Context, agentID, taskID string, messages []service.Message, tools []service.Tool) ([]service.Message, error)
 	// ClampIterations applies the platform iteration ceiling.
 	ClampIterations(agentMax, taskMax int) int
-	// ChatOptions returns options to pass to provider.Chat.
-	ChatOptions() *service.ChatOptions
+	// ChatOptions returns options to pass to provider.Chat for the agent.
+	ChatOptions(agent service.AgentConfig) *service.ChatOptions
 	// TruncateToolResult caps a tool result before it enters context.
 	TruncateToolResult(runID, toolName, body string) (string, bool)
 }
@@ -9,8 +9,11 @@ import (
 
 	"github.com/oklog/ulid/v2"
 	"github.com/rakunlabs/at/internal/service"
+	"github.com/rakunlabs/at/internal/service/tracing"
 	"github.com/rakunlabs/at/internal/service/workflow"
 	"github.com/rakunlabs/logi"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // agentCallNode runs an agentic loop: it sends a prompt to an LLM provider,
@@ -615,11 +618,22 @@ func (n *agentCallNode) Run(ctx context.Context, reg *workflow.Registry, inputs
 		baseLLMTools = append(baseLLMTools, skillRuntime.LoadSkillToolDef())
 	}
 
-	// Trace identity: one agent_call node run is one trace. The Registry
-	// does not carry the owning workflow's identity, so the session is
-	// left empty and the recorder's source ("workflow") plus agent
-	// attribution locate the run.
-	runTraceID := ulid.Make().String()
+	// Trace identity: the node runs inside the workflow run's OTel trace,
+	// so its observations share that trace ID; without an active span one
+	// agent_call node run is one trace. The Registry does not carry the
+	// owning workflow's identity, so the session is left empty and the
+	// recorder's source ("workflow") plus agent attribution locate the run.
+	runTraceID := tracing.TraceIDFromContext(ctx)
+	if runTraceID == "" {
+		runTraceID = ulid.Make().String()
+	}
+	loopCtx := ctx
+	var iterSpan trace.Span
+	defer func() {
+		if iterSpan != nil {
+			iterSpan.End()
+		}
+	}()
 
 	// The legacy 0 = unlimited semantics is no longer supported; the
 	// loop runs at most maxIterations times and the platform ceiling
@@ -630,6 +644,15 @@ func (n *agentCallNode) Run(ctx context.Context, reg *workflow.Registry, inputs
 		llmTools := append([]service.Tool{}, baseLLMTools...)
 		llmTools = append(llmTools, skillRuntime.ActiveSkillTools()...)
 
+		if iterSpan != nil {
+			iterSpan.End()
+		}
+		ctx, span := tracing.StartSpan(loopCtx, "agent.iteration",
+			attribute.String("at.agent_id", n.agentID),
+			attribute.Int("at.iteration", iteration),
+		)
+		iterSpan = span
+
 		// Check for cancellation between iterations.
 		if err := ctx.Err(); err != nil {
 			return nil, fmt.Errorf("agent_call: cancelled: %w", err)
@@ -649,10 +672,16 @@ func (n *agentCallNode) Run(ctx context.Context, reg *workflow.Registry, inputs
 		if reg.LoopGov != nil {
 			windowed, _ := reg.LoopGov.LimitWithTools(ctx, n.agentID, "", messages, llmTools)
 			callMessages = windowed
-			chatOpts = reg.LoopGov.ChatOptions()
+			var agentCfg service.AgentConfig
+			if preset != nil {
+				agentCfg = preset.Config
+			}
+			chatOpts = reg.LoopGov.ChatOptions(agentCfg)
 		}
 		callStart := time.Now()
-		resp, err := provider.Chat(ctx, model, callMessages, llmTools, chatOpts)
+		chatCtx, chatSpan := tracing.StartProviderSpan(ctx, providerKey, model, 1)
+		resp, err := provider.Chat(chatCtx, model, callMessages, llmTools, chatOpts)
+		tracing.EndProviderSpan(chatSpan, resp, err)
 		latencyMs := time.Since(callStart).Milliseconds()
 		if err != nil {
 			// Record the failed call for the usage dashboard before returning.
@@ -780,6 +809,7 @@ func (n *agentCallNode) Run(ctx context.Context, reg *workflow.Registry, inputs
 			toolStarted := time.Now()
 			logi.Ctx(ctx).Debug("agent_call: tool call",
 				"tool", tc.Name, "iteration", iteration)
+			ctx, toolSpan := tracing.StartToolSpan(ctx, tc.Name, "workflow", tc.Arguments)
 
 			var result string
 			var callErr error
@@ -913,6 +943,7 @@ func (n *agentCallNode) Run(ctx context.Context, reg *workflow.Registry, inputs
 				}
 				result, _ = reg.LoopGov.TruncateToolResult(runID, tc.Name, result)
 			}
+			tracing.EndToolSpan(toolSpan, result, callErr != nil)
 
 			// Observation: tool call, parented to this iteration's
 			// generation — the workflow agent_call node leaves the same
@@ -102,7 +102,7 @@ func (n *audioGenerateNode) Run(ctx context.Context, reg *workflow.Registry, inp
 		return nil, fmt.Errorf("audio_generate: provider %q: %w", n.providerKey, err)
 	}
 
-	audioProvider, ok := provider.(service.AudioProvider)
+	audioProvider, ok := service.As[service.AudioProvider](provider)
 	if !ok {
 		return nil, fmt.Errorf("audio_generate: provider %q does not support audio generation", n.providerKey)
 	}
@@ -93,7 +93,7 @@ func (n *audioTranscribeNode) Run(ctx context.Context, reg *workflow.Registry, i
 		return nil, fmt.Errorf("audio_transcribe: provider %q: %w", n.providerKey, err)
 	}
 
-	audioProvider, ok := provider.(service.AudioProvider)
+	audioProvider, ok := service.As[service.AudioProvider](provider)
 	if !ok {
 		return nil, fmt.Errorf("audio_transcribe: provider %q does not support audio transcription", n.providerKey)
 	}
@@ -91,7 +91,7 @@ func (n *embeddingNode) Run(ctx context.Context, reg *workflow.Registry, inputs
 		return nil, fmt.Errorf("embedding: provider %q: %w", n.providerKey, err)
 	}
 
-	embProvider, ok := provider.(service.EmbeddingProvider)
+	embProvider, ok := service.As[service.EmbeddingProvider](provider)
 	if !ok {
 		return nil, fmt.Errorf("embedding: provider %q does not support embeddings", n.providerKey)
 	}
@@ -2,18 +2,24 @@ package nodes
 
 import (
 	"context"
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"io"
+	"net/http"
+	"strings"
 
 	"github.com/rakunlabs/at/internal/service"
 	"github.com/rakunlabs/at/internal/service/workflow"
 )
 
-// imageGenerateNode generates images from a text prompt using a provider
+// imageGenerateNode generates, edits or varies images using a provider
 // that implements service.ImageProvider.
 //
 // Config (node.Data):
 //
 //	"provider": string — provider key (required)
+//	"mode":     string — "generate" (default), "edit" or "variation"
 //	"model":    string — model override (optional)
 //	"size":     string — image size (optional, e.g. "1024x1024")
 //	"quality":  string — image quality (optional, e.g. "standard", "hd")
@@ -22,7 +28,9 @@ import (
 //
 // Input ports:
 //
-//	"prompt" — text prompt for image generation (string)
+//	"prompt" — text prompt (string; required unless mode is "variation")
+//	"image"  — source image for edit / variation: data URI, base64 or URL
+//	"mask"   — optional edit mask; transparent areas are repainted
 //
 // Output ports:
 //
@@ -31,6 +39,7 @@ import (
 //	"metadata" — generation metadata (revised prompt, model, etc.)
 type imageGenerateNode struct {
 	providerKey string
+	mode        string
 	model       string
 	size        string
 	quality     string
@@ -44,6 +53,10 @@ func init() {
 
 func newImageGenerateNode(node service.WorkflowNode) (workflow.Noder, error) {
 	providerKey, _ := node.Data["provider"].(string)
+	mode, _ := node.Data["mode"].(string)
+	if mode == "" {
+		mode = "generate"
+	}
 	model, _ := node.Data["model"].(string)
 	size, _ := node.Data["size"].(string)
 	quality, _ := node.Data["quality"].(string)
@@ -56,6 +69,7 @@ func newImageGenerateNode(node service.WorkflowNode) (workflow.Noder, error) {
 
 	return &imageGenerateNode{
 		providerKey: providerKey,
+		mode:        mode,
 		model:       model,
 		size:        size,
 		quality:     quality,
@@ -71,9 +85,11 @@ func (n *imageGenerateNode) Meta() workflow.NodeMeta {
 		Type:        "image_generate",
 		Label:       "Image Generate",
 		Category:    "media",
-		Description: "Generate images from a text prompt",
+		Description: "Generate images from a text prompt, or edit / vary an input image",
 		Inputs: []workflow.PortMeta{
-			{Name: "prompt", Type: workflow.PortTypeText, Required: true, Accept: []workflow.PortType{workflow.PortTypeData}, Label: "Prompt", Position: "left"},
+			{Name: "prompt", Type: workflow.PortTypeText, Accept: []workflow.PortType{workflow.PortTypeData}, Label: "Prompt", Position: "left"},
+			{Name: "image", Type: workflow.PortTypeImage, Accept: []workflow.PortType{workflow.PortTypeText, workflow.PortTypeData}, Label: "Image", Position: "left"},
+			{Name: "mask", Type: workflow.PortTypeImage, Accept: []workflow.PortType{workflow.PortTypeText, workflow.PortTypeData}, Label: "Mask", Position: "left"},
 		},
 		Outputs: []workflow.PortMeta{
 			{Name: "image", Type: workflow.PortTypeImage, Label: "Image", Position: "right"},
@@ -83,8 +99,9 @@ func (n *imageGenerateNode) Meta() workflow.NodeMeta {
 		Fields: []workflow.FieldMeta{
 			{Name: "label", Type: "string", Required: true, Description: "Display name"},
 			{Name: "provider", Type: "string", Required: true, Description: "Provider key"},
+			{Name: "mode", Type: "string", Default: "generate", Enum: []string{"generate", "edit", "variation"}, Description: "Generate from the prompt, edit the input image (with optional mask), or create variations of it"},
 			{Name: "model", Type: "string", Description: "Image model name"},
-			{Name: "size", Type: "string", Default: "1024x1024", Enum: []string{"1024x1024", "1792x1024", "1024x1792"}, Description: "Image size"},
+			{Name: "size", Type: "string", Default: "1024x1024", Enum: []string{"1024x1024", "1792x1024", "1024x1792", "512x512", "256x256"}, Description: "Image size"},
 			{Name: "quality", Type: "string", Default: "standard", Enum: []string{"standard", "hd"}, Description: "Image quality"},
 			{Name: "style", Type: "string", Default: "vivid", Enum: []string{"vivid", "natural"}, Description: "Image style"},
 			{Name: "n", Type: "number", Default: 1, Description: "Number of images (1-4)"},
@@ -100,6 +117,11 @@ func (n *imageGenerateNode) Validate(_ context.Context, reg *workflow.Registry)
 	if reg.ProviderLookup == nil {
 		return fmt.Errorf("image_generate: no provider lookup configured")
 	}
+	switch n.mode {
+	case "generate", "edit", "variation":
+	default:
+		return fmt.Errorf("image_generate: unknown mode %q", n.mode)
+	}
 	return nil
 }
 
@@ -109,7 +131,7 @@ func (n *imageGenerateNode) Run(ctx context.Context, reg *workflow.Registry, inp
 		return nil, fmt.Errorf("image_generate: provider %q: %w", n.providerKey, err)
 	}
 
-	imgProvider, ok := provider.(service.ImageProvider)
+	imgProvider, ok := service.As[service.ImageProvider](provider)
 	if !ok {
 		return nil, fmt.Errorf("image_generate: provider %q does not support image generation", n.providerKey)
 	}
@@ -121,20 +143,61 @@ func (n *imageGenerateNode) Run(ctx context.Context, reg *workflow.Registry, inp
 			prompt = toString(inputs["data"])
 		}
 	}
-	if prompt == "" {
-		return nil, fmt.Errorf("image_generate: no prompt provided")
-	}
 
-	req := service.ImageGenerateRequest{
-		Prompt:  prompt,
-		Model:   n.model,
-		N:       n.n,
-		Size:    n.size,
-		Quality: n.quality,
-		Style:   n.style,
-	}
+	var resp *service.ImageResponse
+	switch n.mode {
+	case "edit", "variation":
+		var image, mask *service.ImageInput
+		image, err = imageInput(ctx, inputs["image"])
+		if err != nil {
+			return nil, fmt.Errorf("image_generate: image: %w", err)
+		}
+		if image == nil {
+			return nil, fmt.Errorf("image_generate: mode %q needs an input image", n.mode)
+		}
 
-	resp, err := imgProvider.GenerateImage(ctx, req)
+		if n.mode == "variation" {
+			resp, err = imgProvider.CreateImageVariation(ctx, service.ImageVariationRequest{
+				Image: *image,
+				Model: n.model,
+				N:     n.n,
+				Size:  n.size,
+			})
+			break
+		}
+
+		if prompt == "" {
+			return nil, fmt.Errorf("image_generate: no prompt provided")
+		}
+		mask, err = imageInput(ctx, inputs["mask"])
+		if err != nil {
+			return nil, fmt.Errorf("image_generate: mask: %w", err)
+		}
+		resp, err = imgProvider.EditImage(ctx, service.ImageEditRequest{
+			Images:  []service.ImageInput{*image},
+			Mask:    mask,
+			Prompt:  prompt,
+			Model:   n.model,
+			N:       n.n,
+			Size:    n.size,
+			Quality: n.quality,
+		})
+	default:
+		if prompt == "" {
+			return nil, fmt.Errorf("image_generate: no prompt provided")
+		}
+		resp, err = imgProvider.GenerateImage(ctx, service.ImageGenerateRequest{
+			Prompt:  prompt,
+			Model:   n.model,
+			N:       n.n,
+			Size:    n.size,
+			Quality: n.quality,
+			Style:   n.style,
+		})
+	}
+	if errors.Is(err, service.ErrUnsupportedOperation) {
+		return nil, fmt.Errorf("image_generate: provider %q cannot run mode %q: %w", n.providerKey, n.mode, err)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("image_generate: %w", err)
 	}
@@ -170,3 +233,50 @@ func (n *imageGenerateNode) Run(ctx context.Context, reg *workflow.Registry, inp
 		"metadata": metadata,
 	}), nil
 }
+
+// maxInputImageBytes caps source images fetched from a URL.
+const maxInputImageBytes = 50 << 20
+
+// imageInput converts an image port value (data URI, raw base64 or an
+// http(s) URL, as produced by image_generate itself) to a provider input.
+// It returns nil when v is empty.
+func imageInput(ctx context.Context, v any) (*service.ImageInput, error) {
+	s := strings.TrimSpace(toString(v))
+	switch {
+	case s == "":
+		return nil, nil
+	case strings.HasPrefix(s, "data:"):
+		meta, data, ok := strings.Cut(strings.TrimPrefix(s, "data:"), ",")
+		if !ok {
+			return nil, fmt.Errorf("malformed data URI")
+		}
+		return &service.ImageInput{Base64: data, ContentType: strings.TrimSuffix(meta, ";base64")}, nil
+	case strings.HasPrefix(s, "http://"), strings.HasPrefix(s, "https://"):
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, s, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("fetch %s: %w", s, err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode >= 400 {
+			return nil, fmt.Errorf("fetch %s: status %d", s, resp.StatusCode)
+		}
+		data, err := io.ReadAll(io.LimitReader(resp.Body, maxInputImageBytes+1))
+		if err != nil {
+			return nil, fmt.Errorf("fetch %s: %w", s, err)
+		}
+		if len(data) > maxInputImageBytes {
+			return nil, fmt.Errorf("fetch %s: image larger than %d bytes", s, maxInputImageBytes)
+		}
+		contentType := resp.Header.Get("Content-Type")
+		if !strings.HasPrefix(contentType, "image/") {
+			contentType = http.DetectContentType(data)
+		}
+		return &service.ImageInput{Base64: base64.StdEncoding.EncodeToString(data), ContentType: contentType}, nil
+	default:
+		return &service.ImageInput{Base64: s}, nil
+	}
+}
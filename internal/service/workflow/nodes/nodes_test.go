@@ -4,7 +4,13 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
 	"slices"
+	"strings"
+	"sync"
 	"testing"
 
 	"github.com/rakunlabs/at/internal/service"
@@ -53,7 +59,7 @@ func newTestRegistry() *workflow.Registry {
 	)
 }
 
-func newTestRegistryWithProvider(mp *mockProvider) *workflow.Registry {
+func newTestRegistryWithProvider(mp service.LLMProvider) *workflow.Registry {
 	return workflow.NewRegistry(
 		func(key string) (service.LLMProvider, string, error) {
 			if key == "test-provider" {
@@ -937,6 +943,268 @@ func TestAgentCall_AcceptPositiveMaxIterations(t *testing.T) {
 	}
 }
 
+// ─── image_generate: edit and variation modes ───
+
+// mockImageProvider records the image requests it receives.
+type mockImageProvider struct {
+	mockProvider
+
+	edit      *service.ImageEditRequest
+	variation *service.ImageVariationRequest
+	err       error
+}
+
+func (m *mockImageProvider) GenerateImage(_ context.Context, _ service.ImageGenerateRequest) (*service.ImageResponse, error) {
+	return &service.ImageResponse{Images: []service.GeneratedImage{{URL: "https://example.com/gen.png"}}}, m.err
+}
+
+func (m *mockImageProvider) EditImage(_ context.Context, req service.ImageEditRequest) (*service.ImageResponse, error) {
+	m.edit = &req
+	if m.err != nil {
+		return nil, m.err
+	}
+	return &service.ImageResponse{Images: []service.GeneratedImage{{Base64: "RURJVA=="}}}, nil
+}
+
+func (m *mockImageProvider) CreateImageVariation(_ context.Context, req service.ImageVariationRequest) (*service.ImageResponse, error) {
+	m.variation = &req
+	if m.err != nil {
+		return nil, m.err
+	}
+	return &service.ImageResponse{Images: []service.GeneratedImage{{Base64: "VkFS"}}}, nil
+}
+
+func TestImageGenerate_EditWithMask(t *testing.T) {
+	mp := &mockImageProvider{}
+	reg := newTestRegistryWithProvider(mp)
+	node := makeNode(t, "image_generate", map[string]any{
+		"provider": "test-provider",
+		"mode":     "edit",
+	})
+	if err := node.Validate(context.Background(), reg); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+
+	result, err := node.Run(context.Background(), reg, map[string]any{
+		"prompt": "add a hat",
+		"image":  "data:image/jpeg;base64,SlBH",
+		"mask":   "TUFTSw==",
+	})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	req := mp.edit
+	if req == nil || req.Prompt != "add a hat" || len(req.Images) != 1 || req.Mask == nil {
+		t.Fatalf("edit request = %+v", req)
+	}
+	if req.Images[0].ContentType != "image/jpeg" || req.Images[0].Base64 != "SlBH" || req.Mask.Base64 != "TUFTSw==" {
+		t.Errorf("image = %+v, mask = %+v", req.Images[0], req.Mask)
+	}
+	if result.Data()["image"] != "RURJVA==" {
+		t.Errorf("image output = %v", result.Data()["image"])
+	}
+}
+
+func TestImageGenerate_VariationNeedsImage(t *testing.T) {
+	mp := &mockImageProvider{}
+	reg := newTestRegistryWithProvider(mp)
+	node := makeNode(t, "image_generate", map[string]any{
+		"provider": "test-provider",
+		"mode":     "variation",
+	})
+
+	if _, err := node.Run(context.Background(), reg, map[string]any{}); err == nil || !contains(err.Error(), "needs an input image") {
+		t.Fatalf("expected missing image error, got %v", err)
+	}
+
+	if _, err := node.Run(context.Background(), reg, map[string]any{"image": "SU1H"}); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if mp.variation == nil || mp.variation.Image.Base64 != "SU1H" {
+		t.Errorf("variation request = %+v", mp.variation)
+	}
+}
+
+func TestImageGenerate_UnsupportedMode(t *testing.T) {
+	mp := &mockImageProvider{err: fmt.Errorf("no edits: %w", service.ErrUnsupportedOperation)}
+	reg := newTestRegistryWithProvider(mp)
+	node := makeNode(t, "image_generate", map[string]any{
+		"provider": "test-provider",
+		"mode":     "edit",
+	})
+
+	_, err := node.Run(context.Background(), reg, map[string]any{"prompt": "x", "image": "SU1H"})
+	if !errors.Is(err, service.ErrUnsupportedOperation) || !contains(err.Error(), `cannot run mode "edit"`) {
+		t.Fatalf("expected unsupported operation error, got %v", err)
+	}
+
+	bad := makeNode(t, "image_generate", map[string]any{"provider": "test-provider", "mode": "upscale"})
+	if err := bad.Validate(context.Background(), reg); err == nil {
+		t.Fatal("expected unknown mode to fail validation")
+	}
+}
+
+// ─── video_generate ───
+
+// mockVideoProvider completes a job after `polls` status checks; a
+// negative count keeps it in progress forever. The first pollErrors
+// status checks fail.
+type mockVideoProvider struct {
+	mockProvider
+
+	mu         sync.Mutex
+	polls      int
+	pollErrors int
+	seconds    int
+	submitted  *service.VideoGenerateRequest
+	cancelled  string
+}
+
+func (m *mockVideoProvider) SubmitVideo(_ context.Context, req service.VideoGenerateRequest) (*service.VideoJob, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.submitted = &req
+	return &service.VideoJob{ID: "video_1", Status: service.VideoStatusQueued, Model: "sora-2", Seconds: req.Seconds}, nil
+}
+
+func (m *mockVideoProvider) GetVideo(_ context.Context, id string) (*service.VideoJob, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.pollErrors > 0 {
+		m.pollErrors--
+		return nil, errors.New("503 service unavailable")
+	}
+	if m.polls != 0 {
+		m.polls--
+		return &service.VideoJob{ID: id, Status: service.VideoStatusInProgress, Model: "sora-2"}, nil
+	}
+	return &service.VideoJob{ID: id, Status: service.VideoStatusCompleted, Model: "sora-2", Seconds: m.seconds, Size: "1280x720"}, nil
+}
+
+func (m *mockVideoProvider) CancelVideo(_ context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.cancelled = id
+	return nil
+}
+
+func (m *mockVideoProvider) DownloadVideo(_ context.Context, _ string) (io.ReadCloser, string, error) {
+	return io.NopCloser(strings.NewReader("MP4DATA")), "video/mp4", nil
+}
+
+func TestVideoGenerate_WaitsAndDownloads(t *testing.T) {
+	mp := &mockVideoProvider{polls: 2, seconds: 4}
+	reg := newTestRegistryWithProvider(mp)
+	var usage []workflow.UsageEvent
+	reg.RecordUsage = func(_ context.Context, event workflow.UsageEvent) error {
+		usage = append(usage, event)
+		return nil
+	}
+	node := makeNode(t, "video_generate", map[string]any{
+		"provider":      "test-provider",
+		"seconds":       float64(4),
+		"poll_interval": 0.001,
+		"agent_id":      "agent-1",
+	})
+	if err := node.Validate(context.Background(), reg); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+
+	dir := t.TempDir()
+	ctx := workflow.ContextWithWorkDir(context.Background(), dir)
+	result, err := node.Run(ctx, reg, map[string]any{"prompt": "a cat surfing", "image": "SU1H"})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	if mp.submitted == nil || mp.submitted.Prompt != "a cat surfing" || mp.submitted.Seconds != 4 || mp.submitted.InputImage == nil {
+		t.Fatalf("submitted = %+v", mp.submitted)
+	}
+	path, _ := result.Data()["video"].(string)
+	if path != filepath.Join(dir, "videos", "video_1.mp4") {
+		t.Errorf("video path = %q", path)
+	}
+	if data, err := os.ReadFile(path); err != nil || string(data) != "MP4DATA" {
+		t.Errorf("video file = %q, %v", data, err)
+	}
+	if len(usage) != 1 || usage[0].AgentID != "agent-1" || usage[0].VideoSeconds != 4 || usage[0].Status != "ok" {
+		t.Errorf("usage = %+v", usage)
+	}
+}
+
+func TestVideoGenerate_TimeoutCancelsJob(t *testing.T) {
+	mp := &mockVideoProvider{polls: -1}
+	reg := newTestRegistryWithProvider(mp)
+	node := makeNode(t, "video_generate", map[string]any{
+		"provider":      "test-provider",
+		"poll_interval": 0.001,
+		"timeout":       0.02,
+	})
+
+	_, err := node.Run(context.Background(), reg, map[string]any{"prompt": "slow"})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline error, got %v", err)
+	}
+	if mp.cancelled != "video_1" {
+		t.Errorf("cancelled = %q, want video_1", mp.cancelled)
+	}
+}
+
+func TestVideoGenerate_RetriesPollErrors(t *testing.T) {
+	mp := &mockVideoProvider{polls: 1, pollErrors: 2}
+	reg := newTestRegistryWithProvider(mp)
+	var usage []workflow.UsageEvent
+	reg.RecordUsage = func(_ context.Context, event workflow.UsageEvent) error {
+		usage = append(usage, event)
+		return nil
+	}
+	node := makeNode(t, "video_generate", map[string]any{
+		"provider":      "test-provider",
+		"poll_interval": 0.001,
+	})
+
+	ctx := workflow.ContextWithWorkDir(context.Background(), t.TempDir())
+	if _, err := node.Run(ctx, reg, map[string]any{"prompt": "flaky"}); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if mp.cancelled != "" {
+		t.Errorf("finished job was cancelled")
+	}
+	// Neither the config nor the provider named a length: bill the default.
+	if len(usage) != 1 || usage[0].VideoSeconds != 4 {
+		t.Errorf("usage = %+v, want 4 seconds billed", usage)
+	}
+}
+
+func TestVideoGenerate_PollErrorsUntilTimeoutCancelJob(t *testing.T) {
+	mp := &mockVideoProvider{pollErrors: 1 << 30}
+	reg := newTestRegistryWithProvider(mp)
+	node := makeNode(t, "video_generate", map[string]any{
+		"provider":      "test-provider",
+		"poll_interval": 0.001,
+		"timeout":       0.02,
+	})
+
+	_, err := node.Run(context.Background(), reg, map[string]any{"prompt": "down"})
+	if !errors.Is(err, context.DeadlineExceeded) || !contains(err.Error(), "503") {
+		t.Fatalf("expected deadline error with the last poll error, got %v", err)
+	}
+	if mp.cancelled != "video_1" {
+		t.Errorf("cancelled = %q, want video_1", mp.cancelled)
+	}
+}
+
+func TestVideoGenerate_UnsupportedProvider(t *testing.T) {
+	reg := newTestRegistryWithProvider(&mockProvider{})
+	node := makeNode(t, "video_generate", map[string]any{"provider": "test-provider"})
+
+	_, err := node.Run(context.Background(), reg, map[string]any{"prompt": "x"})
+	if err == nil || !contains(err.Error(), "does not support video generation") {
+		t.Fatalf("expected unsupported provider error, got %v", err)
+	}
+}
+
 func contains(s, sub string) bool {
 	return len(s) >= len(sub) && (s == sub || (len(sub) > 0 && (indexOf(s, sub) >= 0)))
 }
@@ -0,0 +1,355 @@
+package nodes
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/rakunlabs/logi"
+
+	"github.com/rakunlabs/at/internal/service"
+	"github.com/rakunlabs/at/internal/service/tracing"
+	"github.com/rakunlabs/at/internal/service/workflow"
+)
+
+// Defaults for video_generate polling. Generation usually takes minutes.
+const (
+	defaultVideoPollInterval = 10 * time.Second
+	defaultVideoTimeout      = 30 * time.Minute
+
+	// defaultVideoSeconds is the clip length OpenAI uses when a request
+	// sets none. A completed job reporting no length is billed at it.
+	defaultVideoSeconds = 4
+)
+
+// videoGenerateNode submits a video generation job to a provider that
+// implements service.VideoProvider and waits for it to finish.
+//
+// Config (node.Data):
+//
+//	"provider":      string  — provider key (required)
+//	"model":         string  — video model (optional, e.g. "sora-2")
+//	"seconds":       float64 — clip length in seconds (optional)
+//	"size":          string  — resolution (optional, e.g. "1280x720")
+//	"poll_interval": float64 — seconds between status checks (default 10)
+//	"timeout":       float64 — seconds to wait before cancelling (default 1800)
+//	"agent_id":      string  — agent the cost is attributed to (optional)
+//
+// Input ports:
+//
+//	"prompt" — text prompt (string, required)
+//	"image"  — optional reference image: data URI, base64 or URL
+//
+// Output ports:
+//
+//	"video"    — path of the downloaded video in the run's working directory
+//	"url"      — provider URL of the video, when it returns one
+//	"metadata" — job ID, status, seconds, size and model
+type videoGenerateNode struct {
+	providerKey  string
+	model        string
+	seconds      int
+	size         string
+	pollInterval time.Duration
+	timeout      time.Duration
+	agentID      string
+}
+
+func init() {
+	workflow.RegisterNodeType("video_generate", newVideoGenerateNode)
+}
+
+func newVideoGenerateNode(node service.WorkflowNode) (workflow.Noder, error) {
+	providerKey, _ := node.Data["provider"].(string)
+	model, _ := node.Data["model"].(string)
+	size, _ := node.Data["size"].(string)
+	agentID, _ := node.Data["agent_id"].(string)
+
+	seconds := 0
+	if v, ok := node.Data["seconds"].(float64); ok && v > 0 {
+		seconds = int(v)
+	}
+
+	pollInterval := defaultVideoPollInterval
+	if v, ok := node.Data["poll_interval"].(float64); ok && v > 0 {
+		pollInterval = time.Duration(v * float64(time.Second))
+	}
+
+	timeout := defaultVideoTimeout
+	if v, ok := node.Data["timeout"].(float64); ok && v > 0 {
+		timeout = time.Duration(v * float64(time.Second))
+	}
+
+	return &videoGenerateNode{
+		providerKey:  providerKey,
+		model:        model,
+		seconds:      seconds,
+		size:         size,
+		pollInterval: pollInterval,
+		timeout:      timeout,
+		agentID:      agentID,
+	}, nil
+}
+
+func (n *videoGenerateNode) Type() string { return "video_generate" }
+
+func (n *videoGenerateNode) Meta() workflow.NodeMeta {
+	return workflow.NodeMeta{
+		Type:        "video_generate",
+		Label:       "Video Generate",
+		Category:    "media",
+		Description: "Generate a video from a text prompt and wait for it to finish",
+		Inputs: []workflow.PortMeta{
+			{Name: "prompt", Type: workflow.PortTypeText, Accept: []workflow.PortType{workflow.PortTypeData}, Label: "Prompt", Position: "left"},
+			{Name: "image", Type: workflow.PortTypeImage, Accept: []workflow.PortType{workflow.PortTypeText, workflow.PortTypeData}, Label: "Image", Position: "left"},
+		},
+		Outputs: []workflow.PortMeta{
+			{Name: "video", Type: workflow.PortTypeVideo, Label: "Video", Position: "right"},
+			{Name: "url", Type: workflow.PortTypeText, Label: "URL", Position: "right"},
+			{Name: "metadata", Type: workflow.PortTypeData, Label: "Metadata", Position: "right"},
+		},
+		Fields: []workflow.FieldMeta{
+			{Name: "label", Type: "string", Required: true, Description: "Display name"},
+			{Name: "provider", Type: "string", Required: true, Description: "Provider key"},
+			{Name: "model", Type: "string", Description: "Video model name"},
+			{Name: "seconds", Type: "number", Description: "Clip length in seconds"},
+			{Name: "size", Type: "string", Description: "Resolution, e.g. 1280x720"},
+			{Name: "poll_interval", Type: "number", Default: 10, Description: "Seconds between status checks"},
+			{Name: "timeout", Type: "number", Default: 1800, Description: "Seconds to wait before cancelling the job"},
+			{Name: "agent_id", Type: "string", Description: "Agent the generation cost is attributed to"},
+		},
+		Color: "green",
+	}
+}
+
+func (n *videoGenerateNode) Validate(_ context.Context, reg *workflow.Registry) error {
+	if n.providerKey == "" {
+		return fmt.Errorf("video_generate: 'provider' is required")
+	}
+	if reg.ProviderLookup == nil {
+		return fmt.Errorf("video_generate: no provider lookup configured")
+	}
+	return nil
+}
+
+func (n *videoGenerateNode) Run(ctx context.Context, reg *workflow.Registry, inputs map[string]any) (workflow.NodeResult, error) {
+	provider, _, err := reg.ProviderLookup(n.providerKey)
+	if err != nil {
+		return nil, fmt.Errorf("video_generate: provider %q: %w", n.providerKey, err)
+	}
+
+	videoProvider, ok := service.As[service.VideoProvider](provider)
+	if !ok {
+		return nil, fmt.Errorf("video_generate: provider %q does not support video generation", n.providerKey)
+	}
+
+	prompt := toString(inputs["prompt"])
+	if prompt == "" {
+		prompt = toString(inputs["text"])
+		if prompt == "" {
+			prompt = toString(inputs["data"])
+		}
+	}
+	if prompt == "" {
+		return nil, fmt.Errorf("video_generate: no prompt provided")
+	}
+
+	image, err := imageInput(ctx, inputs["image"])
+	if err != nil {
+		return nil, fmt.Errorf("video_generate: image: %w", err)
+	}
+
+	start := time.Now()
+	job, err := videoProvider.SubmitVideo(ctx, service.VideoGenerateRequest{
+		Prompt:     prompt,
+		Model:      n.model,
+		Seconds:    n.seconds,
+		Size:       n.size,
+		InputImage: image,
+	})
+	if errors.Is(err, service.ErrUnsupportedOperation) {
+		return nil, fmt.Errorf("video_generate: provider %q cannot generate videos: %w", n.providerKey, err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("video_generate: submit: %w", err)
+	}
+
+	job, err = n.wait(ctx, videoProvider, job)
+	if err != nil {
+		n.recordUsage(ctx, reg, job, start, err)
+		return nil, err
+	}
+
+	path, err := n.download(ctx, videoProvider, job)
+	n.recordUsage(ctx, reg, job, start, err)
+	if err != nil {
+		return nil, err
+	}
+
+	return workflow.NewResult(map[string]any{
+		"video": path,
+		"url":   job.URL,
+		"metadata": map[string]any{
+			"id":      job.ID,
+			"status":  job.Status,
+			"seconds": job.Seconds,
+			"size":    job.Size,
+			"model":   job.Model,
+		},
+	}), nil
+}
+
+// wait polls the job until it reaches a terminal status. A failed poll
+// is retried until the timeout, since the job keeps running upstream
+// either way. Whenever wait returns with the job unfinished, the upstream
+// job is cancelled so an abandoned generation does not keep billing.
+func (n *videoGenerateNode) wait(ctx context.Context, vp service.VideoProvider, job *service.VideoJob) (*service.VideoJob, error) {
+	waitCtx, cancel := context.WithTimeout(ctx, n.timeout)
+	defer cancel()
+	defer func() {
+		if service.VideoStatusTerminal(job.Status) {
+			return
+		}
+		if err := vp.CancelVideo(context.WithoutCancel(ctx), job.ID); err != nil {
+			logi.Ctx(ctx).Warn("video_generate: cancel abandoned job", "id", job.ID, "error", err)
+		}
+	}()
+
+	ticker := time.NewTicker(n.pollInterval)
+	defer ticker.Stop()
+
+	var pollErr error
+	for !service.VideoStatusTerminal(job.Status) {
+		select {
+		case <-waitCtx.Done():
+			if pollErr != nil {
+				return job, fmt.Errorf("video_generate: job %s: %w (last poll error: %v)", job.ID, waitCtx.Err(), pollErr)
+			}
+			return job, fmt.Errorf("video_generate: job %s: %w", job.ID, waitCtx.Err())
+		case <-ticker.C:
+		}
+
+		next, err := vp.GetVideo(waitCtx, job.ID)
+		if err != nil {
+			if waitCtx.Err() == nil {
+				pollErr = err
+				logi.Ctx(ctx).Warn("video_generate: poll failed, retrying", "id", job.ID, "error", err)
+			}
+			continue
+		}
+		pollErr = nil
+		job = mergeVideoJob(job, next)
+	}
+
+	switch job.Status {
+	case service.VideoStatusCompleted:
+		return job, nil
+	case service.VideoStatusFailed:
+		return job, fmt.Errorf("video_generate: job %s failed: %s", job.ID, job.Error)
+	default:
+		return job, fmt.Errorf("video_generate: job %s ended with status %q", job.ID, job.Status)
+	}
+}
+
+// mergeVideoJob returns the polled job with the fields a status response
+// may leave out carried over from the previous one.
+func mergeVideoJob(prev, next *service.VideoJob) *service.VideoJob {
+	merged := *next
+	if merged.Seconds == 0 {
+		merged.Seconds = prev.Seconds
+	}
+	if merged.Size == "" {
+		merged.Size = prev.Size
+	}
+	if merged.Model == "" {
+		merged.Model = prev.Model
+	}
+	return &merged
+}
+
+// download saves the finished video under videos/ in the run's working
+// directory, or in the system temp directory when the run has none.
+func (n *videoGenerateNode) download(ctx context.Context, vp service.VideoProvider, job *service.VideoJob) (string, error) {
+	body, contentType, err := vp.DownloadVideo(ctx, job.ID)
+	if err != nil {
+		return "", fmt.Errorf("video_generate: download job %s: %w", job.ID, err)
+	}
+	defer body.Close()
+
+	base := workflow.WorkDirFromContext(ctx)
+	if base == "" {
+		base = os.TempDir()
+	}
+	dir := filepath.Join(base, "videos")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("video_generate: create video dir: %w", err)
+	}
+
+	ext := ".mp4"
+	if strings.Contains(contentType, "webm") {
+		ext = ".webm"
+	}
+	// Job IDs from a key pool carry a "key~" prefix; keep file names plain.
+	name := strings.NewReplacer("/", "_", "~", "_").Replace(job.ID) + ext
+	path := filepath.Join(dir, name)
+
+	f, err := os.Create(path)
+	if err != nil {
+		return "", fmt.Errorf("video_generate: create video file: %w", err)
+	}
+	_, err = io.Copy(f, body)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(path)
+		return "", fmt.Errorf("video_generate: save video: %w", err)
+	}
+
+	return path, nil
+}
+
+// recordUsage records the job as a per-second cost event. Completed jobs
+// are billed even when the download fails, since the provider charged;
+// a job that reports no length is billed at the configured or default
+// length.
+func (n *videoGenerateNode) recordUsage(ctx context.Context, reg *workflow.Registry, job *service.VideoJob, start time.Time, runErr error) {
+	if reg.RecordUsage == nil || job == nil {
+		return
+	}
+
+	agentID := n.agentID
+	if agentID == "" {
+		agentID = "workflow:video_generate" // no agent; tag with the node type
+	}
+
+	event := workflow.UsageEvent{
+		AgentID:   agentID,
+		Model:     job.Model,
+		Provider:  n.providerKey,
+		RunID:     tracing.TraceIDFromContext(ctx),
+		LatencyMs: time.Since(start).Milliseconds(),
+		Status:    "ok",
+	}
+	if event.Model == "" {
+		event.Model = n.model
+	}
+	if job.Status == service.VideoStatusCompleted {
+		event.VideoSeconds = cmp.Or(job.Seconds, n.seconds, defaultVideoSeconds)
+	}
+	if runErr != nil {
+		event.Status = "error"
+		event.ErrorCode = classifyLLMError(runErr)
+		event.ErrorMessage = runErr.Error()
+	}
+
+	if err := reg.RecordUsage(ctx, event); err != nil {
+		logi.Ctx(ctx).Warn("video_generate: failed to record usage", "id", job.ID, "error", err)
+	}
+}
@@ -0,0 +1,57 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/rakunlabs/at/internal/service/tracing"
+)
+
+// ─── OpenTelemetry spans ───
+//
+// Workflow runs and nodes get their own spans; LLM calls, tool calls and
+// outbound HTTP made by a node nest under the node's span.
+
+// startRunSpan starts the span of one workflow run. It is parented on the
+// span in ctx (the triggering request, schedule or agent tool call).
+func startRunSpan(ctx context.Context, entryNodeIDs []string, nodes int) (context.Context, trace.Span) {
+	return tracing.StartSpan(ctx, "workflow.run",
+		attribute.StringSlice("at.workflow.entry_nodes", entryNodeIDs),
+		attribute.Int("at.workflow.nodes", nodes),
+	)
+}
+
+// runNode runs one node under its own span, so LLM calls, tool calls and
+// outbound HTTP made by the node nest under it.
+func runNode(ctx context.Context, st *nodeState, reg *Registry, inputs map[string]any) (NodeResult, error) {
+	attrs := []attribute.KeyValue{
+		attribute.String("at.node.id", st.node.ID),
+		attribute.String("at.node.type", st.noder.Type()),
+	}
+	if st.node.NodeNumber != nil {
+		attrs = append(attrs, attribute.Int("at.node.number", *st.node.NodeNumber))
+	}
+
+	ctx, span := tracing.StartSpan(ctx, "workflow.node "+st.noder.Type(), attrs...)
+	defer span.End()
+
+	result, err := st.noder.Run(ctx, reg, inputs)
+	switch {
+	case errors.Is(err, ErrStopBranch):
+		span.SetAttributes(attribute.Bool("at.node.skipped", true))
+	case err != nil:
+		markSpanError(span, err)
+	}
+
+	return result, err
+}
+
+// markSpanError records err on span and marks it failed.
+func markSpanError(span trace.Span, err error) {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
@@ -0,0 +1,77 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/rakunlabs/at/internal/service"
+	"github.com/rakunlabs/at/internal/service/tracing"
+)
+
+// traceTestNode runs a fixed function so tests can drive node outcomes.
+type traceTestNode struct {
+	run func(ctx context.Context) (NodeResult, error)
+}
+
+func (n *traceTestNode) Type() string                              { return "trace_test" }
+func (n *traceTestNode) Validate(context.Context, *Registry) error { return nil }
+func (n *traceTestNode) Run(ctx context.Context, _ *Registry, _ map[string]any) (NodeResult, error) {
+	return n.run(ctx)
+}
+
+func TestEngineRunTracesRunAndNodes(t *testing.T) {
+	rec := tracetest.NewSpanRecorder()
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec)))
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+
+	var nodeTraceID string
+	RegisterNodeType("trace_test", func(n service.WorkflowNode) (Noder, error) {
+		return &traceTestNode{run: func(ctx context.Context) (NodeResult, error) {
+			if n.ID == "fail" {
+				return nil, errors.New("boom")
+			}
+			nodeTraceID = tracing.TraceIDFromContext(ctx)
+			return NewResult(map[string]any{"ok": true}), nil
+		}}, nil
+	})
+	t.Cleanup(func() { delete(nodeFactories, "trace_test") })
+
+	graph := service.WorkflowGraph{
+		Nodes: []service.WorkflowNode{{ID: "first", Type: "trace_test"}, {ID: "fail", Type: "trace_test"}},
+		Edges: []service.WorkflowEdge{{ID: "e1", Source: "first", Target: "fail"}},
+	}
+	e := NewEngine(nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil)
+	if _, err := e.Run(context.Background(), graph, nil, []string{"first"}, nil); err == nil {
+		t.Fatal("expected the failing node to fail the run")
+	}
+
+	spans := rec.Ended()
+	if len(spans) != 3 {
+		t.Fatalf("expected run + 2 node spans, got %d", len(spans))
+	}
+	run := spans[len(spans)-1]
+	if run.Name() != "workflow.run" || run.Status().Code != codes.Error {
+		t.Fatalf("run span = %q status %v", run.Name(), run.Status())
+	}
+	for _, s := range spans[:2] {
+		if s.Name() != "workflow.node trace_test" {
+			t.Fatalf("node span name = %q", s.Name())
+		}
+		if s.Parent().SpanID() != run.SpanContext().SpanID() {
+			t.Fatalf("node span %q not parented on the run span", s.Name())
+		}
+	}
+	if spans[1].Status().Code != codes.Error {
+		t.Fatalf("failing node span status = %v", spans[1].Status())
+	}
+	if nodeTraceID != run.SpanContext().TraceID().String() {
+		t.Fatalf("node ctx trace ID = %q, want %q", nodeTraceID, run.SpanContext().TraceID())
+	}
+}
@@ -52,6 +52,7 @@ type modelPricingRow struct {
 	CompletionPricePer1M       float64      `db:"completion_price_per_1m"`
 	CacheReadPricePer1M        float64      `db:"cache_read_price_per_1m"`
 	CacheWritePricePer1M       float64      `db:"cache_write_price_per_1m"`
+	PricePerSecond             float64      `db:"price_per_second"`
 	Source                     string       `db:"source"`
 	SourceProvider             string       `db:"source_provider"`
 	SourceModel                string       `db:"source_model"`
@@ -283,7 +284,7 @@ func (p *Postgres) GetAgentTotalSpend(ctx context.Context, agentID string) (floa
 
 func (p *Postgres) ListModelPricing(ctx context.Context) ([]service.ModelPricing, error) {
 	query, _, err := p.goqu.From(p.tableModelPricing).
-		Select("id", "provider_key", "model", "prompt_price_per_1m", "completion_price_per_1m", "cache_read_price_per_1m", "cache_write_price_per_1m", "source", "source_provider", "source_model", "source_url", "source_prompt_price_per_1m", "source_completion_price_per_1m", "source_cache_read_price_per_1m", "source_cache_write_price_per_1m", "manual_override", "last_synced_at", "created_at", "updated_at").
+		Select("id", "provider_key", "model", "prompt_price_per_1m", "completion_price_per_1m", "cache_read_price_per_1m", "cache_write_price_per_1m", "price_per_second", "source", "source_provider", "source_model", "source_url", "source_prompt_price_per_1m", "source_completion_price_per_1m", "source_cache_read_price_per_1m", "source_cache_write_price_per_1m", "manual_override", "last_synced_at", "created_at", "updated_at").
 		ToSQL()
 	if err != nil {
 		return nil, fmt.Errorf("build list model pricing query: %w", err)
@@ -301,7 +302,7 @@ func (p *Postgres) ListModelPricing(ctx context.Context) ([]service.ModelPricing
 		if err := rows.Scan(
 			&row.ID, &row.ProviderKey, &row.Model, &row.PromptPricePer1M,
 			&row.CompletionPricePer1M, &row.CacheReadPricePer1M, &row.CacheWritePricePer1M,
-			&row.Source, &row.SourceProvider, &row.SourceModel, &row.SourceURL,
+			&row.PricePerSecond, &row.Source, &row.SourceProvider, &row.SourceModel, &row.SourceURL,
 			&row.SourcePromptPricePer1M, &row.SourceCompletionPricePer1M,
 			&row.SourceCacheReadPricePer1M, &row.SourceCacheWritePricePer1M,
 			&row.ManualOverride, &row.LastSyncedAt, &row.CreatedAt, &row.UpdatedAt,
@@ -321,13 +322,14 @@ func (p *Postgres) SetModelPricing(ctx context.Context, pricing service.ModelPri
 
 	// Use raw SQL for ON CONFLICT upsert by (provider_key, model).
 	rawSQL := fmt.Sprintf(
-		`INSERT INTO %s (id, provider_key, model, prompt_price_per_1m, completion_price_per_1m, cache_read_price_per_1m, cache_write_price_per_1m, source, source_provider, source_model, source_url, source_prompt_price_per_1m, source_completion_price_per_1m, source_cache_read_price_per_1m, source_cache_write_price_per_1m, manual_override, last_synced_at, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19)
+		`INSERT INTO %s (id, provider_key, model, prompt_price_per_1m, completion_price_per_1m, cache_read_price_per_1m, cache_write_price_per_1m, price_per_second, source, source_provider, source_model, source_url, source_prompt_price_per_1m, source_completion_price_per_1m, source_cache_read_price_per_1m, source_cache_write_price_per_1m, manual_override, last_synced_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20)
 		ON CONFLICT (provider_key, model) DO UPDATE SET
 			prompt_price_per_1m = EXCLUDED.prompt_price_per_1m,
 			completion_price_per_1m = EXCLUDED.completion_price_per_1m,
 			cache_read_price_per_1m = EXCLUDED.cache_read_price_per_1m,
 			cache_write_price_per_1m = EXCLUDED.cache_write_price_per_1m,
+			price_per_second = CASE WHEN EXCLUDED.manual_override OR EXCLUDED.price_per_second <> 0 THEN EXCLUDED.price_per_second ELSE %[1]s.price_per_second END,
 			source = CASE WHEN EXCLUDED.source <> '' THEN EXCLUDED.source ELSE %[1]s.source END,
 			source_provider = CASE WHEN EXCLUDED.source <> '' THEN EXCLUDED.source_provider ELSE %[1]s.source_provider END,
 			source_model = CASE WHEN EXCLUDED.source <> '' THEN EXCLUDED.source_model ELSE %[1]s.source_model END,
@@ -353,7 +355,7 @@ func (p *Postgres) SetModelPricing(ctx context.Context, pricing service.ModelPri
 		id, pricing.ProviderKey, pricing.Model,
 		pricing.PromptPricePer1M, pricing.CompletionPricePer1M,
 		pricing.CacheReadPricePer1M, pricing.CacheWritePricePer1M,
-		pricing.Source, pricing.SourceProvider, pricing.SourceModel, pricing.SourceURL,
+		pricing.PricePerSecond, pricing.Source, pricing.SourceProvider, pricing.SourceModel, pricing.SourceURL,
 		pricing.SourcePromptPricePer1M, pricing.SourceCompletionPricePer1M,
 		pricing.SourceCacheReadPricePer1M, pricing.SourceCacheWritePricePer1M,
 		pricing.ManualOverride, lastSyncedAt,
@@ -446,6 +448,7 @@ func modelPricingRowToRecord(row modelPricingRow) *service.ModelPricing {
 		CompletionPricePer1M:       row.CompletionPricePer1M,
 		CacheReadPricePer1M:        row.CacheReadPricePer1M,
 		CacheWritePricePer1M:       row.CacheWritePricePer1M,
+		PricePerSecond:             row.PricePerSecond,
 		Source:                     row.Source,
 		SourceProvider:             row.SourceProvider,
 		SourceModel:                row.SourceModel,
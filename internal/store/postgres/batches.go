@@ -0,0 +1,436 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/doug-martin/goqu/v9/exp"
+	"github.com/rakunlabs/at/internal/service"
+)
+
+// ─── Batch files ───
+
+type batchFileRow struct {
+	ID        string    `db:"id"`
+	TokenID   string    `db:"token_id"`
+	Filename  string    `db:"filename"`
+	Purpose   string    `db:"purpose"`
+	Bytes     int64     `db:"bytes"`
+	Content   string    `db:"content"`
+	CreatedAt time.Time `db:"created_at"`
+	ExpiresAt time.Time `db:"expires_at"`
+}
+
+var batchFileColumns = []any{"id", "token_id", "filename", "purpose", "bytes", "created_at", "expires_at"}
+
+func scanBatchFileRow(sc rowScanner, row *batchFileRow, withContent bool) error {
+	if withContent {
+		return sc.Scan(&row.ID, &row.TokenID, &row.Filename, &row.Purpose, &row.Bytes, &row.CreatedAt, &row.ExpiresAt, &row.Content)
+	}
+	return sc.Scan(&row.ID, &row.TokenID, &row.Filename, &row.Purpose, &row.Bytes, &row.CreatedAt, &row.ExpiresAt)
+}
+
+func batchFileRowToRecord(row batchFileRow) service.BatchFile {
+	f := service.BatchFile{
+		ID:        row.ID,
+		TokenID:   row.TokenID,
+		Filename:  row.Filename,
+		Purpose:   row.Purpose,
+		Bytes:     row.Bytes,
+		CreatedAt: row.CreatedAt,
+		ExpiresAt: row.ExpiresAt,
+	}
+	if row.Content != "" {
+		f.Content = []byte(row.Content)
+	}
+	return f
+}
+
+func (p *Postgres) GetBatchFile(ctx context.Context, id string) (*service.BatchFile, error) {
+	query, _, err := p.goqu.From(p.tableBatchFiles).
+		Select(append(batchFileColumns, "content")...).
+		Where(
+			goqu.I("id").Eq(id),
+			goqu.I("expires_at").Gt(time.Now().UTC()),
+		).
+		ToSQL()
+	if err != nil {
+		return nil, fmt.Errorf("build get batch file query: %w", err)
+	}
+
+	var row batchFileRow
+	err = scanBatchFileRow(p.db.QueryRowContext(ctx, query), &row, true)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get batch file %q: %w", id, err)
+	}
+
+	f := batchFileRowToRecord(row)
+	return &f, nil
+}
+
+func (p *Postgres) ListBatchFiles(ctx context.Context, tokenID string) ([]service.BatchFile, error) {
+	query, _, err := p.goqu.From(p.tableBatchFiles).
+		Select(batchFileColumns...).
+		Where(
+			goqu.I("token_id").Eq(tokenID),
+			goqu.I("expires_at").Gt(time.Now().UTC()),
+		).
+		Order(goqu.I("created_at").Desc(), goqu.I("id").Desc()).
+		ToSQL()
+	if err != nil {
+		return nil, fmt.Errorf("build list batch files query: %w", err)
+	}
+
+	rows, err := p.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("list batch files: %w", err)
+	}
+	defer rows.Close()
+
+	var files []service.BatchFile
+	for rows.Next() {
+		var row batchFileRow
+		if err := scanBatchFileRow(rows, &row, false); err != nil {
+			return nil, fmt.Errorf("scan batch_files row: %w", err)
+		}
+		files = append(files, batchFileRowToRecord(row))
+	}
+
+	return files, rows.Err()
+}
+
+func (p *Postgres) PutBatchFile(ctx context.Context, file service.BatchFile) error {
+	createdAt := file.CreatedAt
+	if createdAt.IsZero() {
+		createdAt = time.Now()
+	}
+
+	record := goqu.Record{
+		"token_id":   file.TokenID,
+		"filename":   file.Filename,
+		"purpose":    file.Purpose,
+		"bytes":      int64(len(file.Content)),
+		"content":    string(file.Content),
+		"created_at": createdAt.UTC(),
+		"expires_at": file.ExpiresAt.UTC(),
+	}
+	insert := goqu.Record{"id": file.ID}
+	for k, v := range record {
+		insert[k] = v
+	}
+
+	query, _, err := p.goqu.Insert(p.tableBatchFiles).Rows(insert).
+		OnConflict(goqu.DoUpdate("id", record)).ToSQL()
+	if err != nil {
+		return fmt.Errorf("build put batch file query: %w", err)
+	}
+
+	if _, err := p.db.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("put batch file %q: %w", file.ID, err)
+	}
+
+	return nil
+}
+
+func (p *Postgres) DeleteBatchFile(ctx context.Context, id string) error {
+	query, _, err := p.goqu.Delete(p.tableBatchFiles).
+		Where(goqu.I("id").Eq(id)).
+		ToSQL()
+	if err != nil {
+		return fmt.Errorf("build delete batch file query: %w", err)
+	}
+
+	if _, err := p.db.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("delete batch file %q: %w", id, err)
+	}
+
+	return nil
+}
+
+func (p *Postgres) DeleteBatchFilesBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	query, _, err := p.goqu.Delete(p.tableBatchFiles).
+		Where(goqu.I("expires_at").Lt(cutoff.UTC())).
+		ToSQL()
+	if err != nil {
+		return 0, fmt.Errorf("build delete batch files query: %w", err)
+	}
+
+	res, err := p.db.ExecContext(ctx, query)
+	if err != nil {
+		return 0, fmt.Errorf("delete batch files before %q: %w", cutoff.Format(time.RFC3339), err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, nil //nolint:nilerr // count is informational
+	}
+
+	return n, nil
+}
+
+// ─── Batches ───
+
+type batchRow struct {
+	ID               string         `db:"id"`
+	TokenID          string         `db:"token_id"`
+	TokenName        string         `db:"token_name"`
+	Endpoint         string         `db:"endpoint"`
+	InputFileID      string         `db:"input_file_id"`
+	OutputFileID     string         `db:"output_file_id"`
+	ErrorFileID      string         `db:"error_file_id"`
+	CompletionWindow string         `db:"completion_window"`
+	Status           string         `db:"status"`
+	ProviderKey      string         `db:"provider_key"`
+	UpstreamBatchID  string         `db:"upstream_batch_id"`
+	Metadata         sql.NullString `db:"metadata"`
+	RequestTotal     int            `db:"request_total"`
+	RequestCompleted int            `db:"request_completed"`
+	RequestFailed    int            `db:"request_failed"`
+	ErrorMessage     string         `db:"error_message"`
+	CreatedAt        time.Time      `db:"created_at"`
+	ExpiresAt        time.Time      `db:"expires_at"`
+	InProgressAt     sql.NullTime   `db:"in_progress_at"`
+	FinalizingAt     sql.NullTime   `db:"finalizing_at"`
+	CompletedAt      sql.NullTime   `db:"completed_at"`
+	FailedAt         sql.NullTime   `db:"failed_at"`
+	ExpiredAt        sql.NullTime   `db:"expired_at"`
+	CancellingAt     sql.NullTime   `db:"cancelling_at"`
+	CancelledAt      sql.NullTime   `db:"cancelled_at"`
+}
+
+var batchColumns = []any{
+	"id", "token_id", "token_name", "endpoint", "input_file_id", "output_file_id", "error_file_id",
+	"completion_window", "status", "provider_key", "upstream_batch_id", "metadata",
+	"request_total", "request_completed", "request_failed", "error_message",
+	"created_at", "expires_at", "in_progress_at", "finalizing_at", "completed_at",
+	"failed_at", "expired_at", "cancelling_at", "cancelled_at",
+}
+
+func scanBatchRow(sc rowScanner, row *batchRow) error {
+	return sc.Scan(
+		&row.ID, &row.TokenID, &row.TokenName, &row.Endpoint, &row.InputFileID, &row.OutputFileID, &row.ErrorFileID,
+		&row.CompletionWindow, &row.Status, &row.ProviderKey, &row.UpstreamBatchID, &row.Metadata,
+		&row.RequestTotal, &row.RequestCompleted, &row.RequestFailed, &row.ErrorMessage,
+		&row.CreatedAt, &row.ExpiresAt, &row.InProgressAt, &row.FinalizingAt, &row.CompletedAt,
+		&row.FailedAt, &row.ExpiredAt, &row.CancellingAt, &row.CancelledAt,
+	)
+}
+
+func nullTimePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	v := t.Time
+	return &v
+}
+
+func timePtrValue(t *time.Time) any {
+	if t == nil {
+		return nil
+	}
+	return t.UTC()
+}
+
+func batchRowToRecord(row batchRow) service.Batch {
+	b := service.Batch{
+		ID:               row.ID,
+		TokenID:          row.TokenID,
+		TokenName:        row.TokenName,
+		Endpoint:         row.Endpoint,
+		InputFileID:      row.InputFileID,
+		OutputFileID:     row.OutputFileID,
+		ErrorFileID:      row.ErrorFileID,
+		CompletionWindow: row.CompletionWindow,
+		Status:           row.Status,
+		ProviderKey:      row.ProviderKey,
+		UpstreamBatchID:  row.UpstreamBatchID,
+		RequestTotal:     row.RequestTotal,
+		RequestCompleted: row.RequestCompleted,
+		RequestFailed:    row.RequestFailed,
+		ErrorMessage:     row.ErrorMessage,
+		CreatedAt:        row.CreatedAt,
+		ExpiresAt:        row.ExpiresAt,
+		InProgressAt:     nullTimePtr(row.InProgressAt),
+		FinalizingAt:     nullTimePtr(row.FinalizingAt),
+		CompletedAt:      nullTimePtr(row.CompletedAt),
+		FailedAt:         nullTimePtr(row.FailedAt),
+		ExpiredAt:        nullTimePtr(row.ExpiredAt),
+		CancellingAt:     nullTimePtr(row.CancellingAt),
+		CancelledAt:      nullTimePtr(row.CancelledAt),
+	}
+	if row.Metadata.Valid && row.Metadata.String != "" {
+		_ = json.Unmarshal([]byte(row.Metadata.String), &b.Metadata)
+	}
+	return b
+}
+
+func (p *Postgres) GetBatch(ctx context.Context, id string) (*service.Batch, error) {
+	query, _, err := p.goqu.From(p.tableBatches).
+		Select(batchColumns...).
+		Where(goqu.I("id").Eq(id)).
+		ToSQL()
+	if err != nil {
+		return nil, fmt.Errorf("build get batch query: %w", err)
+	}
+
+	var row batchRow
+	err = scanBatchRow(p.db.QueryRowContext(ctx, query), &row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get batch %q: %w", id, err)
+	}
+
+	b := batchRowToRecord(row)
+	return &b, nil
+}
+
+func (p *Postgres) ListBatches(ctx context.Context, tokenID string) ([]service.Batch, error) {
+	return p.listBatches(ctx, goqu.I("token_id").Eq(tokenID), goqu.I("created_at").Desc(), goqu.I("id").Desc())
+}
+
+func (p *Postgres) ListBatchesByStatus(ctx context.Context, statuses ...string) ([]service.Batch, error) {
+	if len(statuses) == 0 {
+		return nil, nil
+	}
+	return p.listBatches(ctx, goqu.I("status").In(statuses), goqu.I("created_at").Asc(), goqu.I("id").Asc())
+}
+
+func (p *Postgres) listBatches(ctx context.Context, where goqu.Expression, order ...exp.OrderedExpression) ([]service.Batch, error) {
+	query, _, err := p.goqu.From(p.tableBatches).
+		Select(batchColumns...).
+		Where(where).
+		Order(order...).
+		ToSQL()
+	if err != nil {
+		return nil, fmt.Errorf("build list batches query: %w", err)
+	}
+
+	rows, err := p.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("list batches: %w", err)
+	}
+	defer rows.Close()
+
+	var batches []service.Batch
+	for rows.Next() {
+		var row batchRow
+		if err := scanBatchRow(rows, &row); err != nil {
+			return nil, fmt.Errorf("scan batches row: %w", err)
+		}
+		batches = append(batches, batchRowToRecord(row))
+	}
+
+	return batches, rows.Err()
+}
+
+func (p *Postgres) PutBatch(ctx context.Context, b service.Batch) error {
+	createdAt := b.CreatedAt
+	if createdAt.IsZero() {
+		createdAt = time.Now()
+	}
+	metadata := []byte("{}")
+	if len(b.Metadata) > 0 {
+		var err error
+		if metadata, err = json.Marshal(b.Metadata); err != nil {
+			return fmt.Errorf("marshal batch metadata: %w", err)
+		}
+	}
+
+	record := goqu.Record{
+		"token_id":          b.TokenID,
+		"token_name":        b.TokenName,
+		"endpoint":          b.Endpoint,
+		"input_file_id":     b.InputFileID,
+		"output_file_id":    b.OutputFileID,
+		"error_file_id":     b.ErrorFileID,
+		"completion_window": b.CompletionWindow,
+		"status":            b.Status,
+		"provider_key":      b.ProviderKey,
+		"upstream_batch_id": b.UpstreamBatchID,
+		"metadata":          string(metadata),
+		"request_total":     b.RequestTotal,
+		"request_completed": b.RequestCompleted,
+		"request_failed":    b.RequestFailed,
+		"error_message":     b.ErrorMessage,
+		"created_at":        createdAt.UTC(),
+		"expires_at":        b.ExpiresAt.UTC(),
+		"in_progress_at":    timePtrValue(b.InProgressAt),
+		"finalizing_at":     timePtrValue(b.FinalizingAt),
+		"completed_at":      timePtrValue(b.CompletedAt),
+		"failed_at":         timePtrValue(b.FailedAt),
+		"expired_at":        timePtrValue(b.ExpiredAt),
+		"cancelling_at":     timePtrValue(b.CancellingAt),
+		"cancelled_at":      timePtrValue(b.CancelledAt),
+	}
+	insert := goqu.Record{"id": b.ID}
+	for k, v := range record {
+		insert[k] = v
+	}
+
+	query, _, err := p.goqu.Insert(p.tableBatches).Rows(insert).
+		OnConflict(goqu.DoUpdate("id", record)).ToSQL()
+	if err != nil {
+		return fmt.Errorf("build put batch query: %w", err)
+	}
+
+	if _, err := p.db.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("put batch %q: %w", b.ID, err)
+	}
+
+	return nil
+}
+
+func (p *Postgres) ClaimBatch(ctx context.Context, id, owner string, until time.Time) (bool, error) {
+	query, _, err := p.goqu.Update(p.tableBatches).
+		Set(goqu.Record{"lease_owner": owner, "lease_until": until.UTC()}).
+		Where(
+			goqu.I("id").Eq(id),
+			goqu.Or(
+				goqu.I("lease_owner").Eq(""),
+				goqu.I("lease_owner").Eq(owner),
+				goqu.I("lease_until").IsNull(),
+				goqu.I("lease_until").Lt(time.Now().UTC()),
+			),
+		).
+		ToSQL()
+	if err != nil {
+		return false, fmt.Errorf("build claim batch query: %w", err)
+	}
+
+	res, err := p.db.ExecContext(ctx, query)
+	if err != nil {
+		return false, fmt.Errorf("claim batch %q: %w", id, err)
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("claim batch %q: %w", id, err)
+	}
+
+	return rows > 0, nil
+}
+
+func (p *Postgres) ReleaseBatch(ctx context.Context, id, owner string) error {
+	query, _, err := p.goqu.Update(p.tableBatches).
+		Set(goqu.Record{"lease_owner": "", "lease_until": nil}).
+		Where(goqu.I("id").Eq(id), goqu.I("lease_owner").Eq(owner)).
+		ToSQL()
+	if err != nil {
+		return fmt.Errorf("build release batch query: %w", err)
+	}
+
+	if _, err := p.db.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("release batch %q: %w", id, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,137 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rakunlabs/at/internal/service"
+)
+
+func TestBatchFiles_PutGetListAndSweep(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t, nil)
+
+	now := time.Now().UTC()
+	file := service.BatchFile{
+		ID:        "file-1",
+		TokenID:   "tok-1",
+		Filename:  "input.jsonl",
+		Purpose:   "batch",
+		Content:   []byte(`{"custom_id":"a"}` + "\n"),
+		CreatedAt: now,
+		ExpiresAt: now.Add(time.Hour),
+	}
+	if err := store.PutBatchFile(ctx, file); err != nil {
+		t.Fatalf("PutBatchFile: %v", err)
+	}
+
+	got, err := store.GetBatchFile(ctx, "file-1")
+	if err != nil || got == nil || string(got.Content) != string(file.Content) || got.Bytes != int64(len(file.Content)) {
+		t.Fatalf("GetBatchFile = %+v, err=%v", got, err)
+	}
+
+	list, err := store.ListBatchFiles(ctx, "tok-1")
+	if err != nil || len(list) != 1 || list[0].Content != nil {
+		t.Fatalf("ListBatchFiles = %+v, err=%v", list, err)
+	}
+
+	file.ID = "file-2"
+	file.ExpiresAt = now.Add(-time.Minute)
+	if err := store.PutBatchFile(ctx, file); err != nil {
+		t.Fatalf("PutBatchFile (expired): %v", err)
+	}
+	if got, err := store.GetBatchFile(ctx, "file-2"); err != nil || got != nil {
+		t.Fatalf("expired file = %+v, err=%v", got, err)
+	}
+	if n, err := store.DeleteBatchFilesBefore(ctx, time.Now()); err != nil || n != 1 {
+		t.Fatalf("DeleteBatchFilesBefore = %d, err=%v", n, err)
+	}
+}
+
+func TestBatches_PutGetAndListByStatus(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t, nil)
+
+	now := time.Now().UTC()
+	b := service.Batch{
+		ID:               "batch_1",
+		TokenID:          "tok-1",
+		Endpoint:         "/v1/chat/completions",
+		InputFileID:      "file-1",
+		CompletionWindow: "24h",
+		Status:           service.BatchStatusInProgress,
+		Metadata:         map[string]string{"job": "classify"},
+		RequestTotal:     3,
+		CreatedAt:        now,
+		ExpiresAt:        now.Add(24 * time.Hour),
+		InProgressAt:     &now,
+	}
+	if err := store.PutBatch(ctx, b); err != nil {
+		t.Fatalf("PutBatch: %v", err)
+	}
+
+	b.RequestCompleted = 2
+	if err := store.PutBatch(ctx, b); err != nil {
+		t.Fatalf("PutBatch (update): %v", err)
+	}
+
+	got, err := store.GetBatch(ctx, "batch_1")
+	if err != nil || got == nil {
+		t.Fatalf("GetBatch = %+v, err=%v", got, err)
+	}
+	if got.RequestCompleted != 2 || got.Metadata["job"] != "classify" || got.InProgressAt == nil || got.CompletedAt != nil {
+		t.Fatalf("unexpected batch: %+v", got)
+	}
+
+	running, err := store.ListBatchesByStatus(ctx, service.BatchStatusInProgress, service.BatchStatusCancelling)
+	if err != nil || len(running) != 1 {
+		t.Fatalf("ListBatchesByStatus = %+v, err=%v", running, err)
+	}
+	if list, err := store.ListBatches(ctx, "tok-other"); err != nil || len(list) != 0 {
+		t.Fatalf("ListBatches(other) = %+v, err=%v", list, err)
+	}
+}
+
+func TestBatches_ClaimLease(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t, nil)
+
+	now := time.Now().UTC()
+	if err := store.PutBatch(ctx, service.Batch{ID: "batch_1", Status: service.BatchStatusInProgress, CreatedAt: now, ExpiresAt: now.Add(time.Hour)}); err != nil {
+		t.Fatalf("PutBatch: %v", err)
+	}
+
+	if ok, err := store.ClaimBatch(ctx, "batch_1", "a", now.Add(time.Minute)); err != nil || !ok {
+		t.Fatalf("first claim = %v, err=%v", ok, err)
+	}
+	if ok, err := store.ClaimBatch(ctx, "batch_1", "b", now.Add(time.Minute)); err != nil || ok {
+		t.Fatalf("claim of a held lease = %v, err=%v", ok, err)
+	}
+	if ok, err := store.ClaimBatch(ctx, "batch_1", "a", now.Add(2*time.Minute)); err != nil || !ok {
+		t.Fatalf("renew = %v, err=%v", ok, err)
+	}
+
+	// PutBatch keeps the lease.
+	if err := store.PutBatch(ctx, service.Batch{ID: "batch_1", Status: service.BatchStatusInProgress, CreatedAt: now, ExpiresAt: now.Add(time.Hour)}); err != nil {
+		t.Fatalf("PutBatch: %v", err)
+	}
+	if ok, _ := store.ClaimBatch(ctx, "batch_1", "b", now.Add(time.Minute)); ok {
+		t.Fatal("PutBatch dropped the lease")
+	}
+
+	if err := store.ReleaseBatch(ctx, "batch_1", "a"); err != nil {
+		t.Fatalf("ReleaseBatch: %v", err)
+	}
+	if ok, err := store.ClaimBatch(ctx, "batch_1", "b", now.Add(time.Minute)); err != nil || !ok {
+		t.Fatalf("claim after release = %v, err=%v", ok, err)
+	}
+
+	// An expired lease can be taken over.
+	if _, err := store.ClaimBatch(ctx, "batch_1", "b", now.Add(-time.Second)); err != nil {
+		t.Fatalf("shorten lease: %v", err)
+	}
+	if ok, err := store.ClaimBatch(ctx, "batch_1", "a", now.Add(time.Minute)); err != nil || !ok {
+		t.Fatalf("claim of an expired lease = %v, err=%v", ok, err)
+	}
+}
@@ -37,6 +37,7 @@ type botConfigRow struct {
 	ContainerMemory string         `db:"container_memory"`
 	SpeechToText    string         `db:"speech_to_text"`
 	WhisperModel    string         `db:"whisper_model"`
+	GuardrailIDs    types.RawJSON  `db:"guardrail_ids"`
 	CreatedAt       time.Time      `db:"created_at"`
 	UpdatedAt       time.Time      `db:"updated_at"`
 	CreatedBy       sql.NullString `db:"created_by"`
@@ -49,7 +50,7 @@ var botConfigColumns = []any{
 	"access_mode", "pending_approval", "allowed_users", "pending_users",
 	"enabled",
 	"user_containers", "container_image", "container_cpu", "container_memory",
-	"speech_to_text", "whisper_model",
+	"speech_to_text", "whisper_model", "guardrail_ids",
 	"created_at", "updated_at", "created_by", "updated_by",
 }
 
@@ -62,7 +63,7 @@ func scanBotConfigRow(scanner interface {
 		&row.AccessMode, &row.PendingApproval, &row.AllowedUsers, &row.PendingUsers,
 		&row.Enabled,
 		&row.UserContainers, &row.ContainerImage, &row.ContainerCPU, &row.ContainerMemory,
-		&row.SpeechToText, &row.WhisperModel,
+		&row.SpeechToText, &row.WhisperModel, &row.GuardrailIDs,
 		&row.CreatedAt, &row.UpdatedAt, &row.CreatedBy, &row.UpdatedBy,
 	)
 }
@@ -147,6 +148,13 @@ func (p *Postgres) CreateBotConfig(ctx context.Context, bot service.BotConfig) (
 	if err != nil {
 		return nil, fmt.Errorf("marshal custom_commands: %w", err)
 	}
+	if bot.GuardrailIDs == nil {
+		bot.GuardrailIDs = []string{}
+	}
+	guardrailIDsJSON, err := json.Marshal(bot.GuardrailIDs)
+	if err != nil {
+		return nil, fmt.Errorf("marshal guardrail_ids: %w", err)
+	}
 
 	id := ulid.Make().String()
 	now := time.Now().UTC()
@@ -176,6 +184,7 @@ func (p *Postgres) CreateBotConfig(ctx context.Context, bot service.BotConfig) (
 			"container_memory":  bot.ContainerMemory,
 			"speech_to_text":    bot.SpeechToText,
 			"whisper_model":     bot.WhisperModel,
+			"guardrail_ids":     types.RawJSON(guardrailIDsJSON),
 			"created_at":        now,
 			"updated_at":        now,
 			"created_by":        bot.CreatedBy,
@@ -214,6 +223,13 @@ func (p *Postgres) UpdateBotConfig(ctx context.Context, id string, bot service.B
 	if err != nil {
 		return nil, fmt.Errorf("marshal custom_commands: %w", err)
 	}
+	if bot.GuardrailIDs == nil {
+		bot.GuardrailIDs = []string{}
+	}
+	guardrailIDsJSON, err := json.Marshal(bot.GuardrailIDs)
+	if err != nil {
+		return nil, fmt.Errorf("marshal guardrail_ids: %w", err)
+	}
 
 	if bot.AccessMode == "" {
 		bot.AccessMode = "open"
@@ -240,6 +256,7 @@ func (p *Postgres) UpdateBotConfig(ctx context.Context, id string, bot service.B
 		"container_memory":  bot.ContainerMemory,
 		"speech_to_text":    bot.SpeechToText,
 		"whisper_model":     bot.WhisperModel,
+		"guardrail_ids":     types.RawJSON(guardrailIDsJSON),
 		"updated_at":        now,
 		"updated_by":        bot.UpdatedBy,
 	}
@@ -317,6 +334,13 @@ func botConfigRowToRecord(row botConfigRow) (*service.BotConfig, error) {
 		}
 	}
 
+	var guardrailIDs []string
+	if len(row.GuardrailIDs) > 0 {
+		if err := json.Unmarshal(row.GuardrailIDs, &guardrailIDs); err != nil {
+			return nil, fmt.Errorf("unmarshal guardrail_ids for %q: %w", row.ID, err)
+		}
+	}
+
 	accessMode := row.AccessMode
 	if accessMode == "" {
 		accessMode = "open"
@@ -342,6 +366,7 @@ func botConfigRowToRecord(row botConfigRow) (*service.BotConfig, error) {
 		ContainerMemory: row.ContainerMemory,
 		SpeechToText:    row.SpeechToText,
 		WhisperModel:    row.WhisperModel,
+		GuardrailIDs:    guardrailIDs,
 		CreatedAt:       row.CreatedAt.Format(time.RFC3339),
 		UpdatedAt:       row.UpdatedAt.Format(time.RFC3339),
 		CreatedBy:       row.CreatedBy.String,
@@ -25,6 +25,7 @@ type costEventRow struct {
 	BillingCode      sql.NullString `db:"billing_code"`
 	RunID            sql.NullString `db:"run_id"`
 	Provider         string         `db:"provider"`
+	APIKey           sql.NullString `db:"api_key"`
 	Model            string         `db:"model"`
 	InputTokens      int64          `db:"input_tokens"`
 	OutputTokens     int64          `db:"output_tokens"`
@@ -40,7 +41,7 @@ type costEventRow struct {
 
 var costEventColumns = []interface{}{
 	"id", "organization_id", "agent_id", "task_id", "project_id", "goal_id",
-	"billing_code", "run_id", "provider", "model",
+	"billing_code", "run_id", "provider", "api_key", "model",
 	"input_tokens", "output_tokens", "cache_read_tokens", "cache_write_tokens", "cost_cents",
 	"latency_ms", "status", "error_code", "error_message",
 	"created_at",
@@ -52,7 +53,7 @@ func scanCostEventRow(scanner interface {
 	return scanner.Scan(
 		&row.ID, &row.OrganizationID, &row.AgentID, &row.TaskID,
 		&row.ProjectID, &row.GoalID, &row.BillingCode, &row.RunID,
-		&row.Provider, &row.Model, &row.InputTokens, &row.OutputTokens,
+		&row.Provider, &row.APIKey, &row.Model, &row.InputTokens, &row.OutputTokens,
 		&row.CacheReadTokens, &row.CacheWriteTokens, &row.CostCents,
 		&row.LatencyMs, &row.Status, &row.ErrorCode, &row.ErrorMessage,
 		&row.CreatedAt,
@@ -79,6 +80,7 @@ func (p *Postgres) RecordCostEvent(ctx context.Context, event service.CostEvent)
 			"billing_code":       nullString(event.BillingCode),
 			"run_id":             nullString(event.RunID),
 			"provider":           event.Provider,
+			"api_key":            nullString(event.APIKey),
 			"model":              event.Model,
 			"input_tokens":       event.InputTokens,
 			"output_tokens":      event.OutputTokens,
@@ -249,6 +251,7 @@ func costEventRowToRecord(row costEventRow) *service.CostEvent {
 		BillingCode:      row.BillingCode.String,
 		RunID:            row.RunID.String,
 		Provider:         row.Provider,
+		APIKey:           row.APIKey.String,
 		Model:            row.Model,
 		InputTokens:      row.InputTokens,
 		OutputTokens:     row.OutputTokens,
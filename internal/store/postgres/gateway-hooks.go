@@ -0,0 +1,197 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/oklog/ulid/v2"
+	"github.com/rakunlabs/at/internal/service"
+	"github.com/rakunlabs/query"
+)
+
+// ─── Gateway Hook CRUD ───
+
+type gatewayHookRow struct {
+	ID          string         `db:"id"`
+	Name        string         `db:"name"`
+	Description string         `db:"description"`
+	Enabled     bool           `db:"enabled"`
+	Script      string         `db:"script"`
+	TimeoutMs   int            `db:"timeout_ms"`
+	OnError     string         `db:"on_error"`
+	CreatedAt   time.Time      `db:"created_at"`
+	UpdatedAt   time.Time      `db:"updated_at"`
+	CreatedBy   sql.NullString `db:"created_by"`
+	UpdatedBy   sql.NullString `db:"updated_by"`
+}
+
+var gatewayHookColumns = []any{
+	"id", "name", "description", "enabled", "script", "timeout_ms", "on_error",
+	"created_at", "updated_at", "created_by", "updated_by",
+}
+
+func scanGatewayHookRow(scanner interface {
+	Scan(...any) error
+}, row *gatewayHookRow) error {
+	return scanner.Scan(
+		&row.ID, &row.Name, &row.Description, &row.Enabled, &row.Script, &row.TimeoutMs, &row.OnError,
+		&row.CreatedAt, &row.UpdatedAt, &row.CreatedBy, &row.UpdatedBy,
+	)
+}
+
+func (p *Postgres) ListGatewayHooks(ctx context.Context, q *query.Query) (*service.ListResult[service.GatewayHook], error) {
+	sql, total, err := p.buildListQuery(ctx, p.tableGatewayHooks, q, gatewayHookColumns...)
+	if err != nil {
+		return nil, fmt.Errorf("build list gateway hooks query: %w", err)
+	}
+
+	rows, err := p.db.QueryContext(ctx, sql)
+	if err != nil {
+		return nil, fmt.Errorf("list gateway hooks: %w", err)
+	}
+	defer rows.Close()
+
+	var items []service.GatewayHook
+	for rows.Next() {
+		var row gatewayHookRow
+		if err := scanGatewayHookRow(rows, &row); err != nil {
+			return nil, fmt.Errorf("scan gateway hook row: %w", err)
+		}
+
+		rec, err := gatewayHookRowToRecord(row)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, *rec)
+	}
+
+	offset, limit := getPagination(q)
+
+	return &service.ListResult[service.GatewayHook]{
+		Data: items,
+		Meta: service.ListMeta{
+			Total:  total,
+			Offset: offset,
+			Limit:  limit,
+		},
+	}, rows.Err()
+}
+
+func (p *Postgres) GetGatewayHook(ctx context.Context, id string) (*service.GatewayHook, error) {
+	query, _, err := p.goqu.From(p.tableGatewayHooks).
+		Select(gatewayHookColumns...).
+		Where(goqu.I("id").Eq(id)).
+		ToSQL()
+	if err != nil {
+		return nil, fmt.Errorf("build get gateway hook query: %w", err)
+	}
+
+	var row gatewayHookRow
+	err = scanGatewayHookRow(p.db.QueryRowContext(ctx, query), &row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get gateway hook %q: %w", id, err)
+	}
+
+	return gatewayHookRowToRecord(row)
+}
+
+func (p *Postgres) CreateGatewayHook(ctx context.Context, g service.GatewayHook) (*service.GatewayHook, error) {
+	id := ulid.Make().String()
+	now := time.Now().UTC()
+
+	query, _, err := p.goqu.Insert(p.tableGatewayHooks).Rows(
+		goqu.Record{
+			"id":          id,
+			"name":        g.Name,
+			"description": g.Description,
+			"enabled":     g.Enabled,
+			"script":      g.Script,
+			"timeout_ms":  g.TimeoutMs,
+			"on_error":    g.OnError,
+			"created_at":  now,
+			"updated_at":  now,
+			"created_by":  g.CreatedBy,
+			"updated_by":  g.UpdatedBy,
+		},
+	).ToSQL()
+	if err != nil {
+		return nil, fmt.Errorf("build insert gateway hook query: %w", err)
+	}
+
+	if _, err := p.db.ExecContext(ctx, query); err != nil {
+		return nil, fmt.Errorf("create gateway hook %q: %w", g.Name, err)
+	}
+
+	return p.GetGatewayHook(ctx, id)
+}
+
+func (p *Postgres) UpdateGatewayHook(ctx context.Context, id string, g service.GatewayHook) (*service.GatewayHook, error) {
+	query, _, err := p.goqu.Update(p.tableGatewayHooks).Set(
+		goqu.Record{
+			"name":        g.Name,
+			"description": g.Description,
+			"enabled":     g.Enabled,
+			"script":      g.Script,
+			"timeout_ms":  g.TimeoutMs,
+			"on_error":    g.OnError,
+			"updated_at":  time.Now().UTC(),
+			"updated_by":  g.UpdatedBy,
+		},
+	).Where(goqu.I("id").Eq(id)).ToSQL()
+	if err != nil {
+		return nil, fmt.Errorf("build update gateway hook query: %w", err)
+	}
+
+	res, err := p.db.ExecContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("update gateway hook %q: %w", id, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("rows affected: %w", err)
+	}
+	if affected == 0 {
+		return nil, nil
+	}
+
+	return p.GetGatewayHook(ctx, id)
+}
+
+func (p *Postgres) DeleteGatewayHook(ctx context.Context, id string) error {
+	query, _, err := p.goqu.Delete(p.tableGatewayHooks).
+		Where(goqu.I("id").Eq(id)).
+		ToSQL()
+	if err != nil {
+		return fmt.Errorf("build delete gateway hook query: %w", err)
+	}
+
+	if _, err := p.db.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("delete gateway hook %q: %w", id, err)
+	}
+
+	return nil
+}
+
+func gatewayHookRowToRecord(row gatewayHookRow) (*service.GatewayHook, error) {
+	return &service.GatewayHook{
+		ID:          row.ID,
+		Name:        row.Name,
+		Description: row.Description,
+		Enabled:     row.Enabled,
+		Script:      row.Script,
+		TimeoutMs:   row.TimeoutMs,
+		OnError:     row.OnError,
+		CreatedAt:   row.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:   row.UpdatedAt.Format(time.RFC3339),
+		CreatedBy:   row.CreatedBy.String,
+		UpdatedBy:   row.UpdatedBy.String,
+	}, nil
+}
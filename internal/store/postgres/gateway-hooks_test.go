@@ -0,0 +1,65 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rakunlabs/at/internal/service"
+)
+
+func TestGatewayHook_CRUD(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t, nil)
+
+	created, err := store.CreateGatewayHook(ctx, service.GatewayHook{
+		Name:      "inject",
+		Enabled:   true,
+		Script:    `function onRequest(req) {}`,
+		TimeoutMs: 50,
+		OnError:   service.GatewayHookOnErrorFail,
+		CreatedBy: "tester",
+	})
+	if err != nil {
+		t.Fatalf("CreateGatewayHook: %v", err)
+	}
+	if created.ID == "" || created.TimeoutMs != 50 || created.OnError != service.GatewayHookOnErrorFail {
+		t.Fatalf("unexpected created hook: %+v", created)
+	}
+
+	created.Enabled = false
+	created.Script = `function onResponse(resp) {}`
+	updated, err := store.UpdateGatewayHook(ctx, created.ID, *created)
+	if err != nil {
+		t.Fatalf("UpdateGatewayHook: %v", err)
+	}
+	if updated == nil || updated.Enabled || updated.Script != created.Script {
+		t.Errorf("update not applied: %+v", updated)
+	}
+
+	token, err := store.CreateAPIToken(ctx, service.APIToken{
+		Name:        "hooked",
+		TokenPrefix: "at_test",
+		HookIDs:     []string{created.ID},
+	}, "hash-hooked")
+	if err != nil {
+		t.Fatalf("CreateAPIToken: %v", err)
+	}
+	got, err := store.GetAPITokenByHash(ctx, "hash-hooked")
+	if err != nil || got == nil {
+		t.Fatalf("GetAPITokenByHash: %v %v", got, err)
+	}
+	if len(got.HookIDs) != 1 || got.HookIDs[0] != created.ID || token.ID != got.ID {
+		t.Errorf("token hook_ids: got %v", got.HookIDs)
+	}
+
+	if err := store.DeleteGatewayHook(ctx, created.ID); err != nil {
+		t.Fatalf("DeleteGatewayHook: %v", err)
+	}
+	gone, err := store.GetGatewayHook(ctx, created.ID)
+	if err != nil {
+		t.Fatalf("GetGatewayHook: %v", err)
+	}
+	if gone != nil {
+		t.Fatalf("expected nil after delete, got %+v", gone)
+	}
+}
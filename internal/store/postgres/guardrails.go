@@ -0,0 +1,208 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/oklog/ulid/v2"
+	"github.com/rakunlabs/at/internal/service"
+	"github.com/rakunlabs/query"
+	"github.com/worldline-go/types"
+)
+
+// ─── Guardrail Policy CRUD ───
+
+type guardrailPolicyRow struct {
+	ID          string         `db:"id"`
+	Name        string         `db:"name"`
+	Description string         `db:"description"`
+	Enabled     bool           `db:"enabled"`
+	Config      types.RawJSON  `db:"config"`
+	CreatedAt   time.Time      `db:"created_at"`
+	UpdatedAt   time.Time      `db:"updated_at"`
+	CreatedBy   sql.NullString `db:"created_by"`
+	UpdatedBy   sql.NullString `db:"updated_by"`
+}
+
+var guardrailPolicyColumns = []any{
+	"id", "name", "description", "enabled", "config",
+	"created_at", "updated_at", "created_by", "updated_by",
+}
+
+func scanGuardrailPolicyRow(scanner interface {
+	Scan(...any) error
+}, row *guardrailPolicyRow) error {
+	return scanner.Scan(
+		&row.ID, &row.Name, &row.Description, &row.Enabled, &row.Config,
+		&row.CreatedAt, &row.UpdatedAt, &row.CreatedBy, &row.UpdatedBy,
+	)
+}
+
+func (p *Postgres) ListGuardrailPolicies(ctx context.Context, q *query.Query) (*service.ListResult[service.GuardrailPolicy], error) {
+	sql, total, err := p.buildListQuery(ctx, p.tableGuardrailPolicies, q, guardrailPolicyColumns...)
+	if err != nil {
+		return nil, fmt.Errorf("build list guardrail policies query: %w", err)
+	}
+
+	rows, err := p.db.QueryContext(ctx, sql)
+	if err != nil {
+		return nil, fmt.Errorf("list guardrail policies: %w", err)
+	}
+	defer rows.Close()
+
+	var items []service.GuardrailPolicy
+	for rows.Next() {
+		var row guardrailPolicyRow
+		if err := scanGuardrailPolicyRow(rows, &row); err != nil {
+			return nil, fmt.Errorf("scan guardrail policy row: %w", err)
+		}
+
+		rec, err := guardrailPolicyRowToRecord(row)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, *rec)
+	}
+
+	offset, limit := getPagination(q)
+
+	return &service.ListResult[service.GuardrailPolicy]{
+		Data: items,
+		Meta: service.ListMeta{
+			Total:  total,
+			Offset: offset,
+			Limit:  limit,
+		},
+	}, rows.Err()
+}
+
+func (p *Postgres) GetGuardrailPolicy(ctx context.Context, id string) (*service.GuardrailPolicy, error) {
+	query, _, err := p.goqu.From(p.tableGuardrailPolicies).
+		Select(guardrailPolicyColumns...).
+		Where(goqu.I("id").Eq(id)).
+		ToSQL()
+	if err != nil {
+		return nil, fmt.Errorf("build get guardrail policy query: %w", err)
+	}
+
+	var row guardrailPolicyRow
+	err = scanGuardrailPolicyRow(p.db.QueryRowContext(ctx, query), &row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get guardrail policy %q: %w", id, err)
+	}
+
+	return guardrailPolicyRowToRecord(row)
+}
+
+func (p *Postgres) CreateGuardrailPolicy(ctx context.Context, g service.GuardrailPolicy) (*service.GuardrailPolicy, error) {
+	configJSON, err := json.Marshal(g.Config)
+	if err != nil {
+		return nil, fmt.Errorf("marshal guardrail policy config: %w", err)
+	}
+
+	id := ulid.Make().String()
+	now := time.Now().UTC()
+
+	query, _, err := p.goqu.Insert(p.tableGuardrailPolicies).Rows(
+		goqu.Record{
+			"id":          id,
+			"name":        g.Name,
+			"description": g.Description,
+			"enabled":     g.Enabled,
+			"config":      types.RawJSON(configJSON),
+			"created_at":  now,
+			"updated_at":  now,
+			"created_by":  g.CreatedBy,
+			"updated_by":  g.UpdatedBy,
+		},
+	).ToSQL()
+	if err != nil {
+		return nil, fmt.Errorf("build insert guardrail policy query: %w", err)
+	}
+
+	if _, err := p.db.ExecContext(ctx, query); err != nil {
+		return nil, fmt.Errorf("create guardrail policy %q: %w", g.Name, err)
+	}
+
+	return p.GetGuardrailPolicy(ctx, id)
+}
+
+func (p *Postgres) UpdateGuardrailPolicy(ctx context.Context, id string, g service.GuardrailPolicy) (*service.GuardrailPolicy, error) {
+	configJSON, err := json.Marshal(g.Config)
+	if err != nil {
+		return nil, fmt.Errorf("marshal guardrail policy config: %w", err)
+	}
+
+	query, _, err := p.goqu.Update(p.tableGuardrailPolicies).Set(
+		goqu.Record{
+			"name":        g.Name,
+			"description": g.Description,
+			"enabled":     g.Enabled,
+			"config":      types.RawJSON(configJSON),
+			"updated_at":  time.Now().UTC(),
+			"updated_by":  g.UpdatedBy,
+		},
+	).Where(goqu.I("id").Eq(id)).ToSQL()
+	if err != nil {
+		return nil, fmt.Errorf("build update guardrail policy query: %w", err)
+	}
+
+	res, err := p.db.ExecContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("update guardrail policy %q: %w", id, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("rows affected: %w", err)
+	}
+	if affected == 0 {
+		return nil, nil
+	}
+
+	return p.GetGuardrailPolicy(ctx, id)
+}
+
+func (p *Postgres) DeleteGuardrailPolicy(ctx context.Context, id string) error {
+	query, _, err := p.goqu.Delete(p.tableGuardrailPolicies).
+		Where(goqu.I("id").Eq(id)).
+		ToSQL()
+	if err != nil {
+		return fmt.Errorf("build delete guardrail policy query: %w", err)
+	}
+
+	if _, err := p.db.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("delete guardrail policy %q: %w", id, err)
+	}
+
+	return nil
+}
+
+func guardrailPolicyRowToRecord(row guardrailPolicyRow) (*service.GuardrailPolicy, error) {
+	var cfg service.GuardrailConfig
+	if len(row.Config) > 0 {
+		if err := json.Unmarshal(row.Config, &cfg); err != nil {
+			return nil, fmt.Errorf("unmarshal guardrail policy config for %q: %w", row.ID, err)
+		}
+	}
+
+	return &service.GuardrailPolicy{
+		ID:          row.ID,
+		Name:        row.Name,
+		Description: row.Description,
+		Enabled:     row.Enabled,
+		Config:      cfg,
+		CreatedAt:   row.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:   row.UpdatedAt.Format(time.RFC3339),
+		CreatedBy:   row.CreatedBy.String,
+		UpdatedBy:   row.UpdatedBy.String,
+	}, nil
+}
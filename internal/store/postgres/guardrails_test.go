@@ -0,0 +1,107 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rakunlabs/at/internal/service"
+)
+
+func TestGuardrailPolicy_CRUD(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t, nil)
+
+	created, err := store.CreateGuardrailPolicy(ctx, service.GuardrailPolicy{
+		Name:    "pii",
+		Enabled: true,
+		Config: service.GuardrailConfig{
+			Input: service.GuardrailInputConfig{
+				PII:      []service.GuardrailPIIRule{{Entity: service.GuardrailPIIEmail, Action: service.GuardrailActionRedact}},
+				DenyList: []string{"secret project"},
+				MaxChars: 1000,
+			},
+			Output: service.GuardrailOutputConfig{RequireJSON: true},
+		},
+		CreatedBy: "tester",
+	})
+	if err != nil {
+		t.Fatalf("CreateGuardrailPolicy: %v", err)
+	}
+	if created.ID == "" || !created.Enabled {
+		t.Fatalf("unexpected created policy: %+v", created)
+	}
+	if len(created.Config.Input.PII) != 1 || created.Config.Input.MaxChars != 1000 || !created.Config.Output.RequireJSON {
+		t.Errorf("config not round-tripped: %+v", created.Config)
+	}
+
+	created.Enabled = false
+	created.Config.Input.DenyList = nil
+	updated, err := store.UpdateGuardrailPolicy(ctx, created.ID, *created)
+	if err != nil {
+		t.Fatalf("UpdateGuardrailPolicy: %v", err)
+	}
+	if updated == nil || updated.Enabled || len(updated.Config.Input.DenyList) != 0 {
+		t.Errorf("update not applied: %+v", updated)
+	}
+
+	list, err := store.ListGuardrailPolicies(ctx, nil)
+	if err != nil {
+		t.Fatalf("ListGuardrailPolicies: %v", err)
+	}
+	if len(list.Data) != 1 {
+		t.Fatalf("list: got %d policies", len(list.Data))
+	}
+
+	if err := store.DeleteGuardrailPolicy(ctx, created.ID); err != nil {
+		t.Fatalf("DeleteGuardrailPolicy: %v", err)
+	}
+	got, err := store.GetGuardrailPolicy(ctx, created.ID)
+	if err != nil {
+		t.Fatalf("GetGuardrailPolicy: %v", err)
+	}
+	if got != nil {
+		t.Fatalf("expected nil after delete, got %+v", got)
+	}
+}
+
+func TestGuardrailIDs_TokenAndBotRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t, nil)
+
+	token, err := store.CreateAPIToken(ctx, service.APIToken{
+		Name:         "guarded",
+		TokenPrefix:  "at_test",
+		GuardrailIDs: []string{"g1", "g2"},
+	}, "hash-guarded")
+	if err != nil {
+		t.Fatalf("CreateAPIToken: %v", err)
+	}
+	got, err := store.GetAPITokenByHash(ctx, "hash-guarded")
+	if err != nil || got == nil {
+		t.Fatalf("GetAPITokenByHash: %v %v", got, err)
+	}
+	if len(got.GuardrailIDs) != 2 || got.GuardrailIDs[1] != "g2" {
+		t.Errorf("token guardrail_ids: got %v", got.GuardrailIDs)
+	}
+	got.GuardrailIDs = []string{"g3"}
+	updated, err := store.UpdateAPIToken(ctx, token.ID, *got)
+	if err != nil {
+		t.Fatalf("UpdateAPIToken: %v", err)
+	}
+	if len(updated.GuardrailIDs) != 1 || updated.GuardrailIDs[0] != "g3" {
+		t.Errorf("updated token guardrail_ids: got %v", updated.GuardrailIDs)
+	}
+
+	bot, err := store.CreateBotConfig(ctx, service.BotConfig{
+		Platform:     "telegram",
+		Name:         "guarded-bot",
+		Token:        "tok",
+		GuardrailIDs: []string{"g1"},
+	})
+	if err != nil {
+		t.Fatalf("CreateBotConfig: %v", err)
+	}
+	if len(bot.GuardrailIDs) != 1 || bot.GuardrailIDs[0] != "g1" {
+		t.Errorf("bot guardrail_ids: got %v", bot.GuardrailIDs)
+	}
+}
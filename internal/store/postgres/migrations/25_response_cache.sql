@@ -0,0 +1,17 @@
+-- Opt-in exact-match response cache for gateway chat completions and embeddings.
+ALTER TABLE ${TABLE_PREFIX}tokens
+    ADD COLUMN IF NOT EXISTS cache_enabled BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN IF NOT EXISTS cache_ttl TEXT DEFAULT NULL;
+
+CREATE TABLE IF NOT EXISTS ${TABLE_PREFIX}response_cache (
+    cache_key TEXT PRIMARY KEY,
+    token_id TEXT NOT NULL DEFAULT '',
+    kind TEXT NOT NULL DEFAULT '',
+    model TEXT NOT NULL DEFAULT '',
+    body TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_${TABLE_PREFIX}response_cache_expires_at
+    ON ${TABLE_PREFIX}response_cache(expires_at);
@@ -0,0 +1,13 @@
+-- Semantic layer on top of the response cache: entries carry the scope and
+-- embedding of the last user message so near-duplicate prompts can match.
+ALTER TABLE ${TABLE_PREFIX}tokens
+    ADD COLUMN IF NOT EXISTS semantic_cache_threshold DOUBLE PRECISION DEFAULT NULL;
+
+ALTER TABLE ${TABLE_PREFIX}response_cache
+    ADD COLUMN IF NOT EXISTS scope TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS embedding JSONB DEFAULT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_${TABLE_PREFIX}response_cache_scope
+    ON ${TABLE_PREFIX}response_cache(scope, expires_at);
+CREATE INDEX IF NOT EXISTS idx_${TABLE_PREFIX}response_cache_token_id
+    ON ${TABLE_PREFIX}response_cache(token_id);
@@ -0,0 +1,14 @@
+-- Responses API results kept for previous_response_id and retrieval.
+CREATE TABLE IF NOT EXISTS ${TABLE_PREFIX}stored_responses (
+    id TEXT PRIMARY KEY,
+    token_id TEXT NOT NULL DEFAULT '',
+    previous_response_id TEXT NOT NULL DEFAULT '',
+    model TEXT NOT NULL DEFAULT '',
+    input_items TEXT NOT NULL DEFAULT '[]',
+    body TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_${TABLE_PREFIX}stored_responses_expires_at
+    ON ${TABLE_PREFIX}stored_responses(expires_at);
@@ -0,0 +1,50 @@
+-- Files and Batch API: uploaded batch input and produced output/error JSONL.
+CREATE TABLE IF NOT EXISTS ${TABLE_PREFIX}batch_files (
+    id TEXT PRIMARY KEY,
+    token_id TEXT NOT NULL DEFAULT '',
+    filename TEXT NOT NULL DEFAULT '',
+    purpose TEXT NOT NULL DEFAULT '',
+    bytes BIGINT NOT NULL DEFAULT 0,
+    content TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_${TABLE_PREFIX}batch_files_token_id
+    ON ${TABLE_PREFIX}batch_files(token_id);
+CREATE INDEX IF NOT EXISTS idx_${TABLE_PREFIX}batch_files_expires_at
+    ON ${TABLE_PREFIX}batch_files(expires_at);
+
+-- Batch jobs executed by the gateway (or handed to a provider's native batch API).
+CREATE TABLE IF NOT EXISTS ${TABLE_PREFIX}batches (
+    id TEXT PRIMARY KEY,
+    token_id TEXT NOT NULL DEFAULT '',
+    token_name TEXT NOT NULL DEFAULT '',
+    endpoint TEXT NOT NULL DEFAULT '',
+    input_file_id TEXT NOT NULL DEFAULT '',
+    output_file_id TEXT NOT NULL DEFAULT '',
+    error_file_id TEXT NOT NULL DEFAULT '',
+    completion_window TEXT NOT NULL DEFAULT '',
+    status TEXT NOT NULL DEFAULT '',
+    provider_key TEXT NOT NULL DEFAULT '',
+    upstream_batch_id TEXT NOT NULL DEFAULT '',
+    metadata JSONB NOT NULL DEFAULT '{}',
+    request_total INTEGER NOT NULL DEFAULT 0,
+    request_completed INTEGER NOT NULL DEFAULT 0,
+    request_failed INTEGER NOT NULL DEFAULT 0,
+    error_message TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMPTZ NOT NULL,
+    in_progress_at TIMESTAMPTZ,
+    finalizing_at TIMESTAMPTZ,
+    completed_at TIMESTAMPTZ,
+    failed_at TIMESTAMPTZ,
+    expired_at TIMESTAMPTZ,
+    cancelling_at TIMESTAMPTZ,
+    cancelled_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_${TABLE_PREFIX}batches_token_id
+    ON ${TABLE_PREFIX}batches(token_id);
+CREATE INDEX IF NOT EXISTS idx_${TABLE_PREFIX}batches_status
+    ON ${TABLE_PREFIX}batches(status);
@@ -0,0 +1,20 @@
+-- Per-model capability registry used by the gateway for request validation
+-- and /gateway/v1/models metadata. NULL booleans mean "unknown".
+CREATE TABLE IF NOT EXISTS ${TABLE_PREFIX}model_capabilities (
+    id TEXT PRIMARY KEY,
+    provider_key TEXT NOT NULL,
+    model TEXT NOT NULL,
+    context_window INTEGER NOT NULL DEFAULT 0,
+    max_output_tokens INTEGER NOT NULL DEFAULT 0,
+    vision BOOLEAN,
+    audio BOOLEAN,
+    tools BOOLEAN,
+    reasoning BOOLEAN,
+    json_schema BOOLEAN,
+    streaming BOOLEAN,
+    source TEXT NOT NULL DEFAULT '',
+    manual_override BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (provider_key, model)
+);
@@ -0,0 +1,19 @@
+-- Guardrail policies: pre- and post-call checks attached by ID to API
+-- tokens, agents (in the agent config JSON) and bots.
+CREATE TABLE IF NOT EXISTS ${TABLE_PREFIX}guardrail_policies (
+    id TEXT PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE,
+    description TEXT NOT NULL DEFAULT '',
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    config JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_by TEXT,
+    updated_by TEXT
+);
+
+ALTER TABLE ${TABLE_PREFIX}tokens
+    ADD COLUMN IF NOT EXISTS guardrail_ids JSONB DEFAULT NULL;
+
+ALTER TABLE ${TABLE_PREFIX}bot_configs
+    ADD COLUMN IF NOT EXISTS guardrail_ids JSONB NOT NULL DEFAULT '[]';
@@ -0,0 +1,18 @@
+-- Gateway hooks: JavaScript request/response hooks attached by ID to API
+-- tokens and providers (in the provider config JSON).
+CREATE TABLE IF NOT EXISTS ${TABLE_PREFIX}gateway_hooks (
+    id TEXT PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE,
+    description TEXT NOT NULL DEFAULT '',
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    script TEXT NOT NULL DEFAULT '',
+    timeout_ms INTEGER NOT NULL DEFAULT 0,
+    on_error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_by TEXT,
+    updated_by TEXT
+);
+
+ALTER TABLE ${TABLE_PREFIX}tokens
+    ADD COLUMN IF NOT EXISTS hook_ids JSONB DEFAULT NULL;
@@ -0,0 +1,30 @@
+-- Video generation jobs submitted through the gateway and polled upstream.
+CREATE TABLE IF NOT EXISTS ${TABLE_PREFIX}video_generations (
+    id TEXT PRIMARY KEY,
+    token_id TEXT NOT NULL DEFAULT '',
+    token_name TEXT NOT NULL DEFAULT '',
+    provider_key TEXT NOT NULL DEFAULT '',
+    model TEXT NOT NULL DEFAULT '',
+    upstream_id TEXT NOT NULL DEFAULT '',
+    prompt TEXT NOT NULL DEFAULT '',
+    seconds INTEGER NOT NULL DEFAULT 0,
+    size TEXT NOT NULL DEFAULT '',
+    status TEXT NOT NULL DEFAULT '',
+    progress INTEGER NOT NULL DEFAULT 0,
+    error_message TEXT NOT NULL DEFAULT '',
+    file_path TEXT NOT NULL DEFAULT '',
+    content_type TEXT NOT NULL DEFAULT '',
+    bytes BIGINT NOT NULL DEFAULT 0,
+    cost_cents DOUBLE PRECISION NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    completed_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_${TABLE_PREFIX}video_generations_token_id
+    ON ${TABLE_PREFIX}video_generations(token_id);
+CREATE INDEX IF NOT EXISTS idx_${TABLE_PREFIX}video_generations_status
+    ON ${TABLE_PREFIX}video_generations(status);
+
+-- Per-second pricing for video generation models.
+ALTER TABLE ${TABLE_PREFIX}model_pricing ADD COLUMN IF NOT EXISTS price_per_second DOUBLE PRECISION NOT NULL DEFAULT 0;
@@ -0,0 +1,3 @@
+-- Run lease on batches so only one gateway instance executes each batch.
+ALTER TABLE ${TABLE_PREFIX}batches ADD COLUMN IF NOT EXISTS lease_owner TEXT NOT NULL DEFAULT '';
+ALTER TABLE ${TABLE_PREFIX}batches ADD COLUMN IF NOT EXISTS lease_until TIMESTAMPTZ;
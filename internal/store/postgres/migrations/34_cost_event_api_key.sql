@@ -0,0 +1,4 @@
+-- Label of the pooled provider API key that served a call, for per-key usage.
+ALTER TABLE ${TABLE_PREFIX}cost_events ADD COLUMN IF NOT EXISTS api_key TEXT;
+CREATE INDEX IF NOT EXISTS idx_${TABLE_PREFIX}cost_events_provider_api_key
+    ON ${TABLE_PREFIX}cost_events(provider, api_key);
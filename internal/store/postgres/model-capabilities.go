@@ -0,0 +1,150 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/oklog/ulid/v2"
+
+	"github.com/rakunlabs/at/internal/service"
+)
+
+// ─── Model capabilities ───
+
+type modelCapabilityRow struct {
+	ID              string       `db:"id"`
+	ProviderKey     string       `db:"provider_key"`
+	Model           string       `db:"model"`
+	ContextWindow   int          `db:"context_window"`
+	MaxOutputTokens int          `db:"max_output_tokens"`
+	Vision          sql.NullBool `db:"vision"`
+	Audio           sql.NullBool `db:"audio"`
+	Tools           sql.NullBool `db:"tools"`
+	Reasoning       sql.NullBool `db:"reasoning"`
+	JSONSchema      sql.NullBool `db:"json_schema"`
+	Streaming       sql.NullBool `db:"streaming"`
+	Source          string       `db:"source"`
+	ManualOverride  bool         `db:"manual_override"`
+	CreatedAt       time.Time    `db:"created_at"`
+	UpdatedAt       time.Time    `db:"updated_at"`
+}
+
+func (p *Postgres) ListModelCapabilities(ctx context.Context) ([]service.ModelCapability, error) {
+	query, _, err := p.goqu.From(p.tableModelCapabilities).
+		Select("id", "provider_key", "model", "context_window", "max_output_tokens",
+			"vision", "audio", "tools", "reasoning", "json_schema", "streaming",
+			"source", "manual_override", "created_at", "updated_at").
+		Order(goqu.I("provider_key").Asc(), goqu.I("model").Asc()).
+		ToSQL()
+	if err != nil {
+		return nil, fmt.Errorf("build list model capabilities query: %w", err)
+	}
+
+	rows, err := p.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("list model capabilities: %w", err)
+	}
+	defer rows.Close()
+
+	var items []service.ModelCapability
+	for rows.Next() {
+		var row modelCapabilityRow
+		if err := rows.Scan(
+			&row.ID, &row.ProviderKey, &row.Model, &row.ContextWindow, &row.MaxOutputTokens,
+			&row.Vision, &row.Audio, &row.Tools, &row.Reasoning, &row.JSONSchema, &row.Streaming,
+			&row.Source, &row.ManualOverride, &row.CreatedAt, &row.UpdatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("scan model capability row: %w", err)
+		}
+		items = append(items, modelCapabilityRowToRecord(row))
+	}
+
+	return items, rows.Err()
+}
+
+func (p *Postgres) SetModelCapability(ctx context.Context, c service.ModelCapability) error {
+	now := time.Now().UTC()
+	record := goqu.Record{
+		"context_window":    c.ContextWindow,
+		"max_output_tokens": c.MaxOutputTokens,
+		"vision":            nullBoolValue(c.Vision),
+		"audio":             nullBoolValue(c.Audio),
+		"tools":             nullBoolValue(c.Tools),
+		"reasoning":         nullBoolValue(c.Reasoning),
+		"json_schema":       nullBoolValue(c.JSONSchema),
+		"streaming":         nullBoolValue(c.Streaming),
+		"source":            c.Source,
+		"manual_override":   c.ManualOverride,
+		"updated_at":        now,
+	}
+	insert := goqu.Record{
+		"id":           ulid.Make().String(),
+		"provider_key": c.ProviderKey,
+		"model":        c.Model,
+		"created_at":   now,
+	}
+	for k, v := range record {
+		insert[k] = v
+	}
+
+	query, _, err := p.goqu.Insert(p.tableModelCapabilities).Rows(insert).
+		OnConflict(goqu.DoUpdate("provider_key, model", record)).ToSQL()
+	if err != nil {
+		return fmt.Errorf("build set model capability query: %w", err)
+	}
+
+	if _, err := p.db.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("set model capability for %q/%q: %w", c.ProviderKey, c.Model, err)
+	}
+
+	return nil
+}
+
+func (p *Postgres) DeleteModelCapability(ctx context.Context, id string) error {
+	query, _, err := p.goqu.Delete(p.tableModelCapabilities).Where(goqu.I("id").Eq(id)).ToSQL()
+	if err != nil {
+		return fmt.Errorf("build delete model capability query: %w", err)
+	}
+	if _, err := p.db.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("delete model capability %q: %w", id, err)
+	}
+	return nil
+}
+
+func nullBoolValue(b *bool) any {
+	if b == nil {
+		return nil
+	}
+	return *b
+}
+
+func nullBoolPtr(b sql.NullBool) *bool {
+	if !b.Valid {
+		return nil
+	}
+	v := b.Bool
+	return &v
+}
+
+func modelCapabilityRowToRecord(row modelCapabilityRow) service.ModelCapability {
+	return service.ModelCapability{
+		ID:              row.ID,
+		ProviderKey:     row.ProviderKey,
+		Model:           row.Model,
+		ContextWindow:   row.ContextWindow,
+		MaxOutputTokens: row.MaxOutputTokens,
+		Vision:          nullBoolPtr(row.Vision),
+		Audio:           nullBoolPtr(row.Audio),
+		Tools:           nullBoolPtr(row.Tools),
+		Reasoning:       nullBoolPtr(row.Reasoning),
+		JSONSchema:      nullBoolPtr(row.JSONSchema),
+		Streaming:       nullBoolPtr(row.Streaming),
+		Source:          row.Source,
+		ManualOverride:  row.ManualOverride,
+		CreatedAt:       row.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:       row.UpdatedAt.Format(time.RFC3339),
+	}
+}
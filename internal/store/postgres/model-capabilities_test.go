@@ -0,0 +1,49 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rakunlabs/at/internal/service"
+)
+
+func TestModelCapabilities_SetListAndDelete(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t, nil)
+
+	yes, no := true, false
+	c := service.ModelCapability{
+		ProviderKey:   "openai",
+		Model:         "gpt-4o",
+		ContextWindow: 128000,
+		Vision:        &yes,
+		Tools:         &yes,
+		Source:        "defaults",
+	}
+	if err := store.SetModelCapability(ctx, c); err != nil {
+		t.Fatalf("SetModelCapability: %v", err)
+	}
+
+	c.Reasoning = &no
+	c.ManualOverride = true
+	if err := store.SetModelCapability(ctx, c); err != nil {
+		t.Fatalf("SetModelCapability (update): %v", err)
+	}
+
+	list, err := store.ListModelCapabilities(ctx)
+	if err != nil || len(list) != 1 {
+		t.Fatalf("ListModelCapabilities = %+v, err=%v", list, err)
+	}
+	got := list[0]
+	if got.ContextWindow != 128000 || got.Vision == nil || !*got.Vision || got.Reasoning == nil || *got.Reasoning ||
+		got.Audio != nil || !got.ManualOverride {
+		t.Fatalf("unexpected capability: %+v", got)
+	}
+
+	if err := store.DeleteModelCapability(ctx, got.ID); err != nil {
+		t.Fatalf("DeleteModelCapability: %v", err)
+	}
+	if list, err := store.ListModelCapabilities(ctx); err != nil || len(list) != 0 {
+		t.Fatalf("after delete = %+v, err=%v", list, err)
+	}
+}
@@ -79,6 +79,14 @@ type Postgres struct {
 	tableConnectors           exp.IdentifierExpression
 	tableFeatureSettings      exp.IdentifierExpression
 	tableLLMCalls             exp.IdentifierExpression
+	tableResponseCache        exp.IdentifierExpression
+	tableStoredResponses      exp.IdentifierExpression
+	tableBatchFiles           exp.IdentifierExpression
+	tableBatches              exp.IdentifierExpression
+	tableModelCapabilities    exp.IdentifierExpression
+	tableGuardrailPolicies    exp.IdentifierExpression
+	tableGatewayHooks         exp.IdentifierExpression
+	tableVideoGenerations     exp.IdentifierExpression
 
 	// encKey is the AES-256 key used to encrypt/decrypt sensitive provider
 	// fields. nil means encryption is disabled. Protected by encKeyMu.
@@ -219,6 +227,14 @@ func New(ctx context.Context, cfg *config.StorePostgres, encKey []byte) (*Postgr
 		tableConnectors:           goqu.T(tablePrefix + "connectors"),
 		tableFeatureSettings:      goqu.T(tablePrefix + "feature_settings"),
 		tableLLMCalls:             goqu.T(tablePrefix + "llm_calls"),
+		tableResponseCache:        goqu.T(tablePrefix + "response_cache"),
+		tableStoredResponses:      goqu.T(tablePrefix + "stored_responses"),
+		tableBatchFiles:           goqu.T(tablePrefix + "batch_files"),
+		tableBatches:              goqu.T(tablePrefix + "batches"),
+		tableModelCapabilities:    goqu.T(tablePrefix + "model_capabilities"),
+		tableGuardrailPolicies:    goqu.T(tablePrefix + "guardrail_policies"),
+		tableGatewayHooks:         goqu.T(tablePrefix + "gateway_hooks"),
+		tableVideoGenerations:     goqu.T(tablePrefix + "video_generations"),
 		encKey:                    encKey,
 	}, nil
 }
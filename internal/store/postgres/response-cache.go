@@ -0,0 +1,202 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/rakunlabs/at/internal/service"
+)
+
+type responseCacheRow struct {
+	Key       string         `db:"cache_key"`
+	TokenID   string         `db:"token_id"`
+	Kind      string         `db:"kind"`
+	Model     string         `db:"model"`
+	Body      string         `db:"body"`
+	Scope     string         `db:"scope"`
+	Embedding sql.NullString `db:"embedding"`
+	CreatedAt time.Time      `db:"created_at"`
+	ExpiresAt time.Time      `db:"expires_at"`
+}
+
+var responseCacheColumns = []any{"cache_key", "token_id", "kind", "model", "body", "scope", "embedding", "created_at", "expires_at"}
+
+func (p *Postgres) GetResponseCache(ctx context.Context, key string) (*service.ResponseCacheEntry, error) {
+	query, _, err := p.goqu.From(p.tableResponseCache).
+		Select(responseCacheColumns...).
+		Where(
+			goqu.I("cache_key").Eq(key),
+			goqu.I("expires_at").Gt(time.Now().UTC()),
+		).
+		ToSQL()
+	if err != nil {
+		return nil, fmt.Errorf("build get response cache query: %w", err)
+	}
+
+	var row responseCacheRow
+	err = scanResponseCacheRow(p.db.QueryRowContext(ctx, query), &row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get response cache %q: %w", key, err)
+	}
+
+	rec := responseCacheRowToRecord(row)
+	return &rec, nil
+}
+
+func (p *Postgres) ListResponseCacheByScope(ctx context.Context, scope string, limit int) ([]service.ResponseCacheEntry, error) {
+	ds := p.goqu.From(p.tableResponseCache).
+		Select(responseCacheColumns...).
+		Where(
+			goqu.I("scope").Eq(scope),
+			goqu.I("embedding").IsNotNull(),
+			goqu.I("expires_at").Gt(time.Now().UTC()),
+		).
+		Order(goqu.I("created_at").Desc())
+	if limit > 0 {
+		ds = ds.Limit(uint(limit))
+	}
+
+	query, _, err := ds.ToSQL()
+	if err != nil {
+		return nil, fmt.Errorf("build list response cache query: %w", err)
+	}
+
+	rows, err := p.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("list response cache by scope: %w", err)
+	}
+	defer rows.Close()
+
+	var items []service.ResponseCacheEntry
+	for rows.Next() {
+		var row responseCacheRow
+		if err := scanResponseCacheRow(rows, &row); err != nil {
+			return nil, fmt.Errorf("scan response_cache row: %w", err)
+		}
+		items = append(items, responseCacheRowToRecord(row))
+	}
+
+	return items, rows.Err()
+}
+
+func (p *Postgres) PutResponseCache(ctx context.Context, entry service.ResponseCacheEntry) error {
+	createdAt := entry.CreatedAt
+	if createdAt.IsZero() {
+		createdAt = time.Now()
+	}
+
+	var embedding any
+	if len(entry.Embedding) > 0 {
+		b, err := json.Marshal(entry.Embedding)
+		if err != nil {
+			return fmt.Errorf("marshal response cache embedding: %w", err)
+		}
+		embedding = string(b)
+	}
+
+	query, _, err := p.goqu.Insert(p.tableResponseCache).Rows(
+		goqu.Record{
+			"cache_key":  entry.Key,
+			"token_id":   entry.TokenID,
+			"kind":       entry.Kind,
+			"model":      entry.Model,
+			"body":       string(entry.Body),
+			"scope":      entry.Scope,
+			"embedding":  embedding,
+			"created_at": createdAt.UTC(),
+			"expires_at": entry.ExpiresAt.UTC(),
+		},
+	).OnConflict(goqu.DoUpdate("cache_key", goqu.Record{
+		"token_id":   entry.TokenID,
+		"kind":       entry.Kind,
+		"model":      entry.Model,
+		"body":       string(entry.Body),
+		"scope":      entry.Scope,
+		"embedding":  embedding,
+		"created_at": createdAt.UTC(),
+		"expires_at": entry.ExpiresAt.UTC(),
+	})).ToSQL()
+	if err != nil {
+		return fmt.Errorf("build put response cache query: %w", err)
+	}
+
+	if _, err := p.db.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("put response cache %q: %w", entry.Key, err)
+	}
+
+	return nil
+}
+
+func (p *Postgres) DeleteResponseCacheBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	query, _, err := p.goqu.Delete(p.tableResponseCache).
+		Where(goqu.I("expires_at").Lt(cutoff.UTC())).
+		ToSQL()
+	if err != nil {
+		return 0, fmt.Errorf("build delete response cache query: %w", err)
+	}
+
+	res, err := p.db.ExecContext(ctx, query)
+	if err != nil {
+		return 0, fmt.Errorf("delete response cache before %q: %w", cutoff.Format(time.RFC3339), err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, nil //nolint:nilerr // count is informational
+	}
+
+	return n, nil
+}
+
+func (p *Postgres) PurgeResponseCache(ctx context.Context, tokenID string) (int64, error) {
+	ds := p.goqu.Delete(p.tableResponseCache)
+	if tokenID != "" {
+		ds = ds.Where(goqu.I("token_id").Eq(tokenID))
+	}
+
+	query, _, err := ds.ToSQL()
+	if err != nil {
+		return 0, fmt.Errorf("build purge response cache query: %w", err)
+	}
+
+	res, err := p.db.ExecContext(ctx, query)
+	if err != nil {
+		return 0, fmt.Errorf("purge response cache: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, nil //nolint:nilerr // count is informational
+	}
+
+	return n, nil
+}
+
+func scanResponseCacheRow(sc rowScanner, row *responseCacheRow) error {
+	return sc.Scan(&row.Key, &row.TokenID, &row.Kind, &row.Model, &row.Body, &row.Scope, &row.Embedding, &row.CreatedAt, &row.ExpiresAt)
+}
+
+func responseCacheRowToRecord(row responseCacheRow) service.ResponseCacheEntry {
+	rec := service.ResponseCacheEntry{
+		Key:       row.Key,
+		TokenID:   row.TokenID,
+		Kind:      row.Kind,
+		Model:     row.Model,
+		Body:      []byte(row.Body),
+		Scope:     row.Scope,
+		CreatedAt: row.CreatedAt,
+		ExpiresAt: row.ExpiresAt,
+	}
+	if row.Embedding.Valid && row.Embedding.String != "" {
+		_ = json.Unmarshal([]byte(row.Embedding.String), &rec.Embedding)
+	}
+	return rec
+}
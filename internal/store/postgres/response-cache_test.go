@@ -0,0 +1,118 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rakunlabs/at/internal/service"
+)
+
+func TestResponseCache_PutGetAndSweep(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t, nil)
+
+	now := time.Now().UTC()
+	entry := service.ResponseCacheEntry{
+		Key:       "key-1",
+		TokenID:   "tok-1",
+		Kind:      service.ResponseCacheKindChat,
+		Model:     "openai/gpt-4o",
+		Body:      []byte(`{"id":"chatcmpl-1"}`),
+		CreatedAt: now,
+		ExpiresAt: now.Add(time.Hour),
+	}
+	if err := store.PutResponseCache(ctx, entry); err != nil {
+		t.Fatalf("PutResponseCache: %v", err)
+	}
+
+	got, err := store.GetResponseCache(ctx, "key-1")
+	if err != nil {
+		t.Fatalf("GetResponseCache: %v", err)
+	}
+	if got == nil || string(got.Body) != `{"id":"chatcmpl-1"}` || got.TokenID != "tok-1" {
+		t.Fatalf("unexpected entry: %+v", got)
+	}
+
+	// Upsert replaces the body.
+	entry.Body = []byte(`{"id":"chatcmpl-2"}`)
+	if err := store.PutResponseCache(ctx, entry); err != nil {
+		t.Fatalf("PutResponseCache (replace): %v", err)
+	}
+	got, err = store.GetResponseCache(ctx, "key-1")
+	if err != nil || got == nil || string(got.Body) != `{"id":"chatcmpl-2"}` {
+		t.Fatalf("replaced entry = %+v, err=%v", got, err)
+	}
+
+	// Expired entries miss and are swept.
+	expired := entry
+	expired.Key = "key-2"
+	expired.ExpiresAt = now.Add(-time.Minute)
+	if err := store.PutResponseCache(ctx, expired); err != nil {
+		t.Fatalf("PutResponseCache (expired): %v", err)
+	}
+	if got, err := store.GetResponseCache(ctx, "key-2"); err != nil || got != nil {
+		t.Fatalf("expired entry = %+v, err=%v", got, err)
+	}
+
+	n, err := store.DeleteResponseCacheBefore(ctx, time.Now())
+	if err != nil {
+		t.Fatalf("DeleteResponseCacheBefore: %v", err)
+	}
+	if n != 1 {
+		t.Fatalf("deleted %d rows, want 1", n)
+	}
+}
+
+func TestResponseCache_ScopeListAndPurge(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t, nil)
+
+	now := time.Now().UTC()
+	for i, key := range []string{"sem-1", "sem-2"} {
+		if err := store.PutResponseCache(ctx, service.ResponseCacheEntry{
+			Key:       key,
+			TokenID:   "tok-1",
+			Kind:      service.ResponseCacheKindChat,
+			Model:     "openai/gpt-4o",
+			Scope:     "scope-a",
+			Embedding: []float64{float64(i), 1},
+			Body:      []byte(`{}`),
+			CreatedAt: now.Add(time.Duration(i) * time.Second),
+			ExpiresAt: now.Add(time.Hour),
+		}); err != nil {
+			t.Fatalf("PutResponseCache %s: %v", key, err)
+		}
+	}
+	// Exact-only entry: same scope but no embedding.
+	if err := store.PutResponseCache(ctx, service.ResponseCacheEntry{
+		Key: "exact-1", TokenID: "tok-2", Kind: service.ResponseCacheKindChat,
+		Scope: "scope-a", Body: []byte(`{}`), CreatedAt: now, ExpiresAt: now.Add(time.Hour),
+	}); err != nil {
+		t.Fatalf("PutResponseCache exact: %v", err)
+	}
+
+	got, err := store.ListResponseCacheByScope(ctx, "scope-a", 10)
+	if err != nil {
+		t.Fatalf("ListResponseCacheByScope: %v", err)
+	}
+	if len(got) != 2 || got[0].Key != "sem-2" || len(got[0].Embedding) != 2 {
+		t.Fatalf("scope entries = %+v", got)
+	}
+
+	n, err := store.PurgeResponseCache(ctx, "tok-1")
+	if err != nil {
+		t.Fatalf("PurgeResponseCache: %v", err)
+	}
+	if n != 2 {
+		t.Fatalf("purged %d rows, want 2", n)
+	}
+	if e, _ := store.GetResponseCache(ctx, "exact-1"); e == nil {
+		t.Fatal("purge removed another token's entry")
+	}
+
+	n, err = store.PurgeResponseCache(ctx, "")
+	if err != nil || n != 1 {
+		t.Fatalf("purge all = %d, err=%v", n, err)
+	}
+}
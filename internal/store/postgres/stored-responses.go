@@ -0,0 +1,136 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/rakunlabs/at/internal/service"
+)
+
+type storedResponseRow struct {
+	ID                 string    `db:"id"`
+	TokenID            string    `db:"token_id"`
+	PreviousResponseID string    `db:"previous_response_id"`
+	Model              string    `db:"model"`
+	InputItems         string    `db:"input_items"`
+	Body               string    `db:"body"`
+	CreatedAt          time.Time `db:"created_at"`
+	ExpiresAt          time.Time `db:"expires_at"`
+}
+
+func (p *Postgres) GetStoredResponse(ctx context.Context, id string) (*service.StoredResponse, error) {
+	query, _, err := p.goqu.From(p.tableStoredResponses).
+		Select("id", "token_id", "previous_response_id", "model", "input_items", "body", "created_at", "expires_at").
+		Where(
+			goqu.I("id").Eq(id),
+			goqu.I("expires_at").Gt(time.Now().UTC()),
+		).
+		ToSQL()
+	if err != nil {
+		return nil, fmt.Errorf("build get stored response query: %w", err)
+	}
+
+	var row storedResponseRow
+	err = p.db.QueryRowContext(ctx, query).Scan(
+		&row.ID, &row.TokenID, &row.PreviousResponseID, &row.Model,
+		&row.InputItems, &row.Body, &row.CreatedAt, &row.ExpiresAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get stored response %q: %w", id, err)
+	}
+
+	return &service.StoredResponse{
+		ID:                 row.ID,
+		TokenID:            row.TokenID,
+		PreviousResponseID: row.PreviousResponseID,
+		Model:              row.Model,
+		InputItems:         []byte(row.InputItems),
+		Body:               []byte(row.Body),
+		CreatedAt:          row.CreatedAt,
+		ExpiresAt:          row.ExpiresAt,
+	}, nil
+}
+
+func (p *Postgres) PutStoredResponse(ctx context.Context, resp service.StoredResponse) error {
+	createdAt := resp.CreatedAt
+	if createdAt.IsZero() {
+		createdAt = time.Now()
+	}
+	inputItems := string(resp.InputItems)
+	if inputItems == "" {
+		inputItems = "[]"
+	}
+
+	query, _, err := p.goqu.Insert(p.tableStoredResponses).Rows(
+		goqu.Record{
+			"id":                   resp.ID,
+			"token_id":             resp.TokenID,
+			"previous_response_id": resp.PreviousResponseID,
+			"model":                resp.Model,
+			"input_items":          inputItems,
+			"body":                 string(resp.Body),
+			"created_at":           createdAt.UTC(),
+			"expires_at":           resp.ExpiresAt.UTC(),
+		},
+	).OnConflict(goqu.DoUpdate("id", goqu.Record{
+		"token_id":             resp.TokenID,
+		"previous_response_id": resp.PreviousResponseID,
+		"model":                resp.Model,
+		"input_items":          inputItems,
+		"body":                 string(resp.Body),
+		"created_at":           createdAt.UTC(),
+		"expires_at":           resp.ExpiresAt.UTC(),
+	})).ToSQL()
+	if err != nil {
+		return fmt.Errorf("build put stored response query: %w", err)
+	}
+
+	if _, err := p.db.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("put stored response %q: %w", resp.ID, err)
+	}
+
+	return nil
+}
+
+func (p *Postgres) DeleteStoredResponse(ctx context.Context, id string) error {
+	query, _, err := p.goqu.Delete(p.tableStoredResponses).
+		Where(goqu.I("id").Eq(id)).
+		ToSQL()
+	if err != nil {
+		return fmt.Errorf("build delete stored response query: %w", err)
+	}
+
+	if _, err := p.db.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("delete stored response %q: %w", id, err)
+	}
+
+	return nil
+}
+
+func (p *Postgres) DeleteStoredResponsesBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	query, _, err := p.goqu.Delete(p.tableStoredResponses).
+		Where(goqu.I("expires_at").Lt(cutoff.UTC())).
+		ToSQL()
+	if err != nil {
+		return 0, fmt.Errorf("build delete stored responses query: %w", err)
+	}
+
+	res, err := p.db.ExecContext(ctx, query)
+	if err != nil {
+		return 0, fmt.Errorf("delete stored responses before %q: %w", cutoff.Format(time.RFC3339), err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, nil //nolint:nilerr // count is informational
+	}
+
+	return n, nil
+}
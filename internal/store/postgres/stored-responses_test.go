@@ -0,0 +1,58 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rakunlabs/at/internal/service"
+)
+
+func TestStoredResponses_PutGetDeleteAndSweep(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t, nil)
+
+	now := time.Now().UTC()
+	resp := service.StoredResponse{
+		ID:                 "resp_2",
+		TokenID:            "tok-1",
+		PreviousResponseID: "resp_1",
+		Model:              "openai/gpt-4o",
+		InputItems:         []byte(`[{"type":"message","role":"user","content":"hi"}]`),
+		Body:               []byte(`{"id":"resp_2"}`),
+		CreatedAt:          now,
+		ExpiresAt:          now.Add(time.Hour),
+	}
+	if err := store.PutStoredResponse(ctx, resp); err != nil {
+		t.Fatalf("PutStoredResponse: %v", err)
+	}
+
+	got, err := store.GetStoredResponse(ctx, "resp_2")
+	if err != nil {
+		t.Fatalf("GetStoredResponse: %v", err)
+	}
+	if got == nil || got.PreviousResponseID != "resp_1" || string(got.InputItems) != string(resp.InputItems) || string(got.Body) != `{"id":"resp_2"}` {
+		t.Fatalf("unexpected response: %+v", got)
+	}
+
+	if err := store.DeleteStoredResponse(ctx, "resp_2"); err != nil {
+		t.Fatalf("DeleteStoredResponse: %v", err)
+	}
+	if got, err := store.GetStoredResponse(ctx, "resp_2"); err != nil || got != nil {
+		t.Fatalf("deleted response = %+v, err=%v", got, err)
+	}
+
+	// Expired responses miss and are swept.
+	resp.ID = "resp_3"
+	resp.ExpiresAt = now.Add(-time.Minute)
+	if err := store.PutStoredResponse(ctx, resp); err != nil {
+		t.Fatalf("PutStoredResponse (expired): %v", err)
+	}
+	if got, err := store.GetStoredResponse(ctx, "resp_3"); err != nil || got != nil {
+		t.Fatalf("expired response = %+v, err=%v", got, err)
+	}
+	n, err := store.DeleteStoredResponsesBefore(ctx, time.Now())
+	if err != nil || n != 1 {
+		t.Fatalf("DeleteStoredResponsesBefore = %d, err=%v", n, err)
+	}
+}
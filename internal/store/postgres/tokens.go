@@ -17,7 +17,7 @@ import (
 // ─── API Token CRUD ───
 
 func (p *Postgres) ListAPITokens(ctx context.Context, q *query.Query) (*service.ListResult[service.APIToken], error) {
-	sql, total, err := p.buildListQuery(ctx, p.tableAPITokens, q, "id", "name", "token_prefix", "allowed_providers_mode", "allowed_providers", "allowed_models_mode", "allowed_models", "allowed_webhooks_mode", "allowed_webhooks", "allowed_mcps_mode", "allowed_mcps", "expires_at", "total_token_limit", "spend_limit_cents", "limit_reset_interval", "last_reset_at", "created_at", "last_used_at", "created_by", "updated_by")
+	sql, total, err := p.buildListQuery(ctx, p.tableAPITokens, q, "id", "name", "token_prefix", "allowed_providers_mode", "allowed_providers", "allowed_models_mode", "allowed_models", "allowed_webhooks_mode", "allowed_webhooks", "allowed_mcps_mode", "allowed_mcps", "expires_at", "total_token_limit", "spend_limit_cents", "limit_reset_interval", "last_reset_at", "cache_enabled", "cache_ttl", "semantic_cache_threshold", "guardrail_ids", "hook_ids", "created_at", "last_used_at", "created_by", "updated_by")
 	if err != nil {
 		return nil, fmt.Errorf("build list tokens query: %w", err)
 	}
@@ -38,6 +38,7 @@ func (p *Postgres) ListAPITokens(ctx context.Context, q *query.Query) (*service.
 			&t.AllowedWebhooksMode, &t.AllowedWebhooks,
 			&t.AllowedMCPsMode, &t.AllowedMCPs,
 			&t.ExpiresAt, &t.TotalTokenLimit, &t.SpendLimitCents, &t.LimitResetInterval, &t.LastResetAt,
+			&t.CacheEnabled, &t.CacheTTL, &t.SemanticThreshold, &t.GuardrailIDs, &t.HookIDs,
 			&t.CreatedAt, &t.LastUsedAt, &t.CreatedBy, &t.UpdatedBy,
 		); err != nil {
 			return nil, fmt.Errorf("scan api_token row: %w", err)
@@ -57,9 +58,39 @@ func (p *Postgres) ListAPITokens(ctx context.Context, q *query.Query) (*service.
 	}, rows.Err()
 }
 
+func (p *Postgres) GetAPIToken(ctx context.Context, id string) (*service.APIToken, error) {
+	query, _, err := p.goqu.From(p.tableAPITokens).
+		Select("id", "name", "token_prefix", "allowed_providers_mode", "allowed_providers", "allowed_models_mode", "allowed_models", "allowed_webhooks_mode", "allowed_webhooks", "allowed_mcps_mode", "allowed_mcps", "expires_at", "total_token_limit", "spend_limit_cents", "limit_reset_interval", "last_reset_at", "cache_enabled", "cache_ttl", "semantic_cache_threshold", "guardrail_ids", "hook_ids", "created_at", "last_used_at", "created_by", "updated_by").
+		Where(goqu.I("id").Eq(id)).
+		ToSQL()
+	if err != nil {
+		return nil, fmt.Errorf("build get api_token query: %w", err)
+	}
+
+	var t service.APIToken
+	err = p.db.QueryRowContext(ctx, query).Scan(
+		&t.ID, &t.Name, &t.TokenPrefix,
+		&t.AllowedProvidersMode, &t.AllowedProviders,
+		&t.AllowedModelsMode, &t.AllowedModels,
+		&t.AllowedWebhooksMode, &t.AllowedWebhooks,
+		&t.AllowedMCPsMode, &t.AllowedMCPs,
+		&t.ExpiresAt, &t.TotalTokenLimit, &t.SpendLimitCents, &t.LimitResetInterval, &t.LastResetAt,
+		&t.CacheEnabled, &t.CacheTTL, &t.SemanticThreshold, &t.GuardrailIDs, &t.HookIDs,
+		&t.CreatedAt, &t.LastUsedAt, &t.CreatedBy, &t.UpdatedBy,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get api_token %q: %w", id, err)
+	}
+
+	return &t, nil
+}
+
 func (p *Postgres) GetAPITokenByHash(ctx context.Context, hash string) (*service.APIToken, error) {
 	query, _, err := p.goqu.From(p.tableAPITokens).
-		Select("id", "name", "token_prefix", "allowed_providers_mode", "allowed_providers", "allowed_models_mode", "allowed_models", "allowed_webhooks_mode", "allowed_webhooks", "allowed_mcps_mode", "allowed_mcps", "expires_at", "total_token_limit", "spend_limit_cents", "limit_reset_interval", "last_reset_at", "created_at", "last_used_at", "created_by", "updated_by").
+		Select("id", "name", "token_prefix", "allowed_providers_mode", "allowed_providers", "allowed_models_mode", "allowed_models", "allowed_webhooks_mode", "allowed_webhooks", "allowed_mcps_mode", "allowed_mcps", "expires_at", "total_token_limit", "spend_limit_cents", "limit_reset_interval", "last_reset_at", "cache_enabled", "cache_ttl", "semantic_cache_threshold", "guardrail_ids", "hook_ids", "created_at", "last_used_at", "created_by", "updated_by").
 		Where(goqu.I("token_hash").Eq(hash)).
 		ToSQL()
 	if err != nil {
@@ -74,6 +105,7 @@ func (p *Postgres) GetAPITokenByHash(ctx context.Context, hash string) (*service
 		&t.AllowedWebhooksMode, &t.AllowedWebhooks,
 		&t.AllowedMCPsMode, &t.AllowedMCPs,
 		&t.ExpiresAt, &t.TotalTokenLimit, &t.SpendLimitCents, &t.LimitResetInterval, &t.LastResetAt,
+		&t.CacheEnabled, &t.CacheTTL, &t.SemanticThreshold, &t.GuardrailIDs, &t.HookIDs,
 		&t.CreatedAt, &t.LastUsedAt, &t.CreatedBy, &t.UpdatedBy,
 	)
 	if errors.Is(err, sql.ErrNoRows) {
@@ -91,26 +123,31 @@ func (p *Postgres) CreateAPIToken(ctx context.Context, token service.APIToken, t
 	now := types.NewTime(time.Now().UTC())
 
 	record := goqu.Record{
-		"id":                     id,
-		"name":                   token.Name,
-		"token_hash":             tokenHash,
-		"token_prefix":           token.TokenPrefix,
-		"allowed_providers_mode": token.AllowedProvidersMode,
-		"allowed_providers":      token.AllowedProviders,
-		"allowed_models_mode":    token.AllowedModelsMode,
-		"allowed_models":         token.AllowedModels,
-		"allowed_webhooks_mode":  token.AllowedWebhooksMode,
-		"allowed_webhooks":       token.AllowedWebhooks,
-		"allowed_mcps_mode":      token.AllowedMCPsMode,
-		"allowed_mcps":           token.AllowedMCPs,
-		"expires_at":             token.ExpiresAt,
-		"total_token_limit":      token.TotalTokenLimit,
-		"spend_limit_cents":      token.SpendLimitCents,
-		"limit_reset_interval":   token.LimitResetInterval,
-		"last_reset_at":          token.LastResetAt,
-		"created_at":             now,
-		"created_by":             token.CreatedBy,
-		"updated_by":             token.UpdatedBy,
+		"id":                       id,
+		"name":                     token.Name,
+		"token_hash":               tokenHash,
+		"token_prefix":             token.TokenPrefix,
+		"allowed_providers_mode":   token.AllowedProvidersMode,
+		"allowed_providers":        token.AllowedProviders,
+		"allowed_models_mode":      token.AllowedModelsMode,
+		"allowed_models":           token.AllowedModels,
+		"allowed_webhooks_mode":    token.AllowedWebhooksMode,
+		"allowed_webhooks":         token.AllowedWebhooks,
+		"allowed_mcps_mode":        token.AllowedMCPsMode,
+		"allowed_mcps":             token.AllowedMCPs,
+		"expires_at":               token.ExpiresAt,
+		"total_token_limit":        token.TotalTokenLimit,
+		"spend_limit_cents":        token.SpendLimitCents,
+		"limit_reset_interval":     token.LimitResetInterval,
+		"last_reset_at":            token.LastResetAt,
+		"cache_enabled":            token.CacheEnabled,
+		"cache_ttl":                token.CacheTTL,
+		"semantic_cache_threshold": token.SemanticThreshold,
+		"guardrail_ids":            token.GuardrailIDs,
+		"hook_ids":                 token.HookIDs,
+		"created_at":               now,
+		"created_by":               token.CreatedBy,
+		"updated_by":               token.UpdatedBy,
 	}
 
 	query, _, err := p.goqu.Insert(p.tableAPITokens).Rows(record).ToSQL()
@@ -145,20 +182,25 @@ func (p *Postgres) DeleteAPIToken(ctx context.Context, id string) error {
 
 func (p *Postgres) UpdateAPIToken(ctx context.Context, id string, token service.APIToken) (*service.APIToken, error) {
 	record := goqu.Record{
-		"name":                   token.Name,
-		"allowed_providers_mode": token.AllowedProvidersMode,
-		"allowed_providers":      token.AllowedProviders,
-		"allowed_models_mode":    token.AllowedModelsMode,
-		"allowed_models":         token.AllowedModels,
-		"allowed_webhooks_mode":  token.AllowedWebhooksMode,
-		"allowed_webhooks":       token.AllowedWebhooks,
-		"allowed_mcps_mode":      token.AllowedMCPsMode,
-		"allowed_mcps":           token.AllowedMCPs,
-		"expires_at":             token.ExpiresAt,
-		"total_token_limit":      token.TotalTokenLimit,
-		"spend_limit_cents":      token.SpendLimitCents,
-		"limit_reset_interval":   token.LimitResetInterval,
-		"updated_by":             token.UpdatedBy,
+		"name":                     token.Name,
+		"allowed_providers_mode":   token.AllowedProvidersMode,
+		"allowed_providers":        token.AllowedProviders,
+		"allowed_models_mode":      token.AllowedModelsMode,
+		"allowed_models":           token.AllowedModels,
+		"allowed_webhooks_mode":    token.AllowedWebhooksMode,
+		"allowed_webhooks":         token.AllowedWebhooks,
+		"allowed_mcps_mode":        token.AllowedMCPsMode,
+		"allowed_mcps":             token.AllowedMCPs,
+		"expires_at":               token.ExpiresAt,
+		"total_token_limit":        token.TotalTokenLimit,
+		"spend_limit_cents":        token.SpendLimitCents,
+		"limit_reset_interval":     token.LimitResetInterval,
+		"cache_enabled":            token.CacheEnabled,
+		"cache_ttl":                token.CacheTTL,
+		"semantic_cache_threshold": token.SemanticThreshold,
+		"guardrail_ids":            token.GuardrailIDs,
+		"hook_ids":                 token.HookIDs,
+		"updated_by":               token.UpdatedBy,
 	}
 
 	query, _, err := p.goqu.Update(p.tableAPITokens).Set(record).
@@ -180,7 +222,7 @@ func (p *Postgres) UpdateAPIToken(ctx context.Context, id string, token service.
 
 	// Re-fetch the updated token.
 	fetchQuery, _, err := p.goqu.From(p.tableAPITokens).
-		Select("id", "name", "token_prefix", "allowed_providers_mode", "allowed_providers", "allowed_models_mode", "allowed_models", "allowed_webhooks_mode", "allowed_webhooks", "allowed_mcps_mode", "allowed_mcps", "expires_at", "total_token_limit", "spend_limit_cents", "limit_reset_interval", "last_reset_at", "created_at", "last_used_at", "created_by", "updated_by").
+		Select("id", "name", "token_prefix", "allowed_providers_mode", "allowed_providers", "allowed_models_mode", "allowed_models", "allowed_webhooks_mode", "allowed_webhooks", "allowed_mcps_mode", "allowed_mcps", "expires_at", "total_token_limit", "spend_limit_cents", "limit_reset_interval", "last_reset_at", "cache_enabled", "cache_ttl", "semantic_cache_threshold", "guardrail_ids", "hook_ids", "created_at", "last_used_at", "created_by", "updated_by").
 		Where(goqu.I("id").Eq(id)).
 		ToSQL()
 	if err != nil {
@@ -195,6 +237,7 @@ func (p *Postgres) UpdateAPIToken(ctx context.Context, id string, token service.
 		&t.AllowedWebhooksMode, &t.AllowedWebhooks,
 		&t.AllowedMCPsMode, &t.AllowedMCPs,
 		&t.ExpiresAt, &t.TotalTokenLimit, &t.SpendLimitCents, &t.LimitResetInterval, &t.LastResetAt,
+		&t.CacheEnabled, &t.CacheTTL, &t.SemanticThreshold, &t.GuardrailIDs, &t.HookIDs,
 		&t.CreatedAt, &t.LastUsedAt, &t.CreatedBy, &t.UpdatedBy,
 	)
 	if err != nil {
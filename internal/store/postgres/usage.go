@@ -99,6 +99,7 @@ func (p *Postgres) GetUsageSummary(ctx context.Context, filter service.UsageFilt
 	if err != nil {
 		return service.UsageSummary{}, fmt.Errorf("usage summary: %w", err)
 	}
+	sum.CacheHitRate = service.CacheHitRate(sum.InputTokens, sum.CacheReadTokens, sum.CacheWriteTokens)
 	if first.Valid {
 		sum.FirstEventAt = first.Time.Format(time.RFC3339)
 	}
@@ -126,6 +127,8 @@ func usageGroupColumn(groupBy string) (string, error) {
 		return "billing_code", nil
 	case "status":
 		return "status", nil
+	case "api_key":
+		return "api_key", nil
 	default:
 		return "", fmt.Errorf("invalid group_by: %q", groupBy)
 	}
@@ -169,6 +172,7 @@ func (p *Postgres) GetUsageGrouped(ctx context.Context, filter service.UsageFilt
 			return nil, fmt.Errorf("scan usage group row: %w", err)
 		}
 		row.Key = key.String
+		row.CacheHitRate = service.CacheHitRate(row.InputTokens, row.CacheReadTokens, row.CacheWriteTokens)
 		if first.Valid {
 			row.FirstEventAt = first.Time.Format(time.RFC3339)
 		}
@@ -227,6 +231,7 @@ func (p *Postgres) GetUsageTimeSeries(ctx context.Context, filter service.UsageF
 			return nil, fmt.Errorf("scan timeseries row: %w", err)
 		}
 		point.Bucket = bucketT.UTC().Format(time.RFC3339)
+		point.CacheHitRate = service.CacheHitRate(point.InputTokens, point.CacheReadTokens, point.CacheWriteTokens)
 		out = append(out, point)
 	}
 	return out, rows.Err()
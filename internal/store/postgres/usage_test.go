@@ -139,6 +139,42 @@ func TestUsage_GetUsageGrouped_Provider(t *testing.T) {
 	}
 }
 
+func TestUsage_GetUsageGrouped_APIKey(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t, nil)
+
+	for _, e := range []service.CostEvent{
+		{AgentID: "gateway:t1", Provider: "openai", APIKey: "primary", Model: "gpt-4o", InputTokens: 10, Status: "ok"},
+		{AgentID: "gateway:t1", Provider: "openai", APIKey: "key-2", Model: "gpt-4o", InputTokens: 20, Status: "ok"},
+		{AgentID: "gateway:t1", Provider: "openai", APIKey: "key-2", Model: "gpt-4o", InputTokens: 30, Status: "ok"},
+		{AgentID: "gateway:t1", Provider: "anthropic", APIKey: "key-2", Model: "claude", InputTokens: 40, Status: "ok"},
+	} {
+		if err := store.RecordCostEvent(ctx, e); err != nil {
+			t.Fatalf("RecordCostEvent: %v", err)
+		}
+	}
+
+	rows, err := store.GetUsageGrouped(ctx, service.UsageFilter{Providers: []string{"openai"}}, "api_key", 0)
+	if err != nil {
+		t.Fatalf("GetUsageGrouped: %v", err)
+	}
+	by := map[string]service.UsageSummary{}
+	for _, r := range rows {
+		by[r.Key] = r
+	}
+	if len(rows) != 2 || by["primary"].InputTokens != 10 || by["key-2"].RequestCount != 2 || by["key-2"].InputTokens != 50 {
+		t.Errorf("rows = %+v", rows)
+	}
+
+	events, err := store.ListCostEvents(ctx, nil)
+	if err != nil {
+		t.Fatalf("ListCostEvents: %v", err)
+	}
+	if len(events.Data) != 4 || events.Data[0].APIKey == "" {
+		t.Errorf("listed events = %+v, want api_key round-tripped", events.Data)
+	}
+}
+
 func TestUsage_GetUsageGrouped_InvalidGroupBy(t *testing.T) {
 	ctx := context.Background()
 	store := newTestStore(t, nil)
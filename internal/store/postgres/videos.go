@@ -0,0 +1,211 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/doug-martin/goqu/v9/exp"
+	"github.com/rakunlabs/at/internal/service"
+)
+
+type videoGenerationRow struct {
+	ID           string       `db:"id"`
+	TokenID      string       `db:"token_id"`
+	TokenName    string       `db:"token_name"`
+	ProviderKey  string       `db:"provider_key"`
+	Model        string       `db:"model"`
+	UpstreamID   string       `db:"upstream_id"`
+	Prompt       string       `db:"prompt"`
+	Seconds      int          `db:"seconds"`
+	Size         string       `db:"size"`
+	Status       string       `db:"status"`
+	Progress     int          `db:"progress"`
+	ErrorMessage string       `db:"error_message"`
+	FilePath     string       `db:"file_path"`
+	ContentType  string       `db:"content_type"`
+	Bytes        int64        `db:"bytes"`
+	CostCents    float64      `db:"cost_cents"`
+	CreatedAt    time.Time    `db:"created_at"`
+	UpdatedAt    time.Time    `db:"updated_at"`
+	CompletedAt  sql.NullTime `db:"completed_at"`
+}
+
+var videoGenerationColumns = []any{
+	"id", "token_id", "token_name", "provider_key", "model", "upstream_id", "prompt",
+	"seconds", "size", "status", "progress", "error_message", "file_path", "content_type",
+	"bytes", "cost_cents", "created_at", "updated_at", "completed_at",
+}
+
+func scanVideoGenerationRow(sc rowScanner, row *videoGenerationRow) error {
+	return sc.Scan(
+		&row.ID, &row.TokenID, &row.TokenName, &row.ProviderKey, &row.Model, &row.UpstreamID, &row.Prompt,
+		&row.Seconds, &row.Size, &row.Status, &row.Progress, &row.ErrorMessage, &row.FilePath, &row.ContentType,
+		&row.Bytes, &row.CostCents, &row.CreatedAt, &row.UpdatedAt, &row.CompletedAt,
+	)
+}
+
+func videoGenerationRowToRecord(row videoGenerationRow) service.VideoGeneration {
+	return service.VideoGeneration{
+		ID:           row.ID,
+		TokenID:      row.TokenID,
+		TokenName:    row.TokenName,
+		ProviderKey:  row.ProviderKey,
+		Model:        row.Model,
+		UpstreamID:   row.UpstreamID,
+		Prompt:       row.Prompt,
+		Seconds:      row.Seconds,
+		Size:         row.Size,
+		Status:       row.Status,
+		Progress:     row.Progress,
+		ErrorMessage: row.ErrorMessage,
+		FilePath:     row.FilePath,
+		ContentType:  row.ContentType,
+		Bytes:        row.Bytes,
+		CostCents:    row.CostCents,
+		CreatedAt:    row.CreatedAt,
+		UpdatedAt:    row.UpdatedAt,
+		CompletedAt:  nullTimePtr(row.CompletedAt),
+	}
+}
+
+func (p *Postgres) GetVideoGeneration(ctx context.Context, id string) (*service.VideoGeneration, error) {
+	query, _, err := p.goqu.From(p.tableVideoGenerations).
+		Select(videoGenerationColumns...).
+		Where(goqu.I("id").Eq(id)).
+		ToSQL()
+	if err != nil {
+		return nil, fmt.Errorf("build get video generation query: %w", err)
+	}
+
+	var row videoGenerationRow
+	err = scanVideoGenerationRow(p.db.QueryRowContext(ctx, query), &row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get video generation %q: %w", id, err)
+	}
+
+	v := videoGenerationRowToRecord(row)
+	return &v, nil
+}
+
+func (p *Postgres) ListVideoGenerations(ctx context.Context, tokenID string) ([]service.VideoGeneration, error) {
+	return p.listVideoGenerations(ctx, goqu.I("token_id").Eq(tokenID), goqu.I("created_at").Desc(), goqu.I("id").Desc())
+}
+
+func (p *Postgres) ListVideoGenerationsByStatus(ctx context.Context, statuses ...string) ([]service.VideoGeneration, error) {
+	if len(statuses) == 0 {
+		return nil, nil
+	}
+	return p.listVideoGenerations(ctx, goqu.I("status").In(statuses), goqu.I("created_at").Asc(), goqu.I("id").Asc())
+}
+
+func (p *Postgres) listVideoGenerations(ctx context.Context, where goqu.Expression, order ...exp.OrderedExpression) ([]service.VideoGeneration, error) {
+	query, _, err := p.goqu.From(p.tableVideoGenerations).
+		Select(videoGenerationColumns...).
+		Where(where).
+		Order(order...).
+		ToSQL()
+	if err != nil {
+		return nil, fmt.Errorf("build list video generations query: %w", err)
+	}
+
+	rows, err := p.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("list video generations: %w", err)
+	}
+	defer rows.Close()
+
+	var videos []service.VideoGeneration
+	for rows.Next() {
+		var row videoGenerationRow
+		if err := scanVideoGenerationRow(rows, &row); err != nil {
+			return nil, fmt.Errorf("scan video_generations row: %w", err)
+		}
+		videos = append(videos, videoGenerationRowToRecord(row))
+	}
+
+	return videos, rows.Err()
+}
+
+func videoGenerationRecord(v service.VideoGeneration) goqu.Record {
+	now := time.Now()
+	createdAt := v.CreatedAt
+	if createdAt.IsZero() {
+		createdAt = now
+	}
+	updatedAt := v.UpdatedAt
+	if updatedAt.IsZero() {
+		updatedAt = now
+	}
+
+	return goqu.Record{
+		"token_id":      v.TokenID,
+		"token_name":    v.TokenName,
+		"provider_key":  v.ProviderKey,
+		"model":         v.Model,
+		"upstream_id":   v.UpstreamID,
+		"prompt":        v.Prompt,
+		"seconds":       v.Seconds,
+		"size":          v.Size,
+		"status":        v.Status,
+		"progress":      v.Progress,
+		"error_message": v.ErrorMessage,
+		"file_path":     v.FilePath,
+		"content_type":  v.ContentType,
+		"bytes":         v.Bytes,
+		"cost_cents":    v.CostCents,
+		"created_at":    createdAt.UTC(),
+		"updated_at":    updatedAt.UTC(),
+		"completed_at":  timePtrValue(v.CompletedAt),
+	}
+}
+
+func (p *Postgres) PutVideoGeneration(ctx context.Context, v service.VideoGeneration) error {
+	record := videoGenerationRecord(v)
+	insert := goqu.Record{"id": v.ID}
+	for k, val := range record {
+		insert[k] = val
+	}
+
+	query, _, err := p.goqu.Insert(p.tableVideoGenerations).Rows(insert).
+		OnConflict(goqu.DoUpdate("id", record)).ToSQL()
+	if err != nil {
+		return fmt.Errorf("build put video generation query: %w", err)
+	}
+
+	if _, err := p.db.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("put video generation %q: %w", v.ID, err)
+	}
+
+	return nil
+}
+
+func (p *Postgres) UpdateVideoGeneration(ctx context.Context, v service.VideoGeneration) (bool, error) {
+	query, _, err := p.goqu.Update(p.tableVideoGenerations).
+		Set(videoGenerationRecord(v)).
+		Where(
+			goqu.I("id").Eq(v.ID),
+			goqu.I("status").NotIn(service.VideoStatusCompleted, service.VideoStatusFailed, service.VideoStatusCancelled),
+		).
+		ToSQL()
+	if err != nil {
+		return false, fmt.Errorf("build update video generation query: %w", err)
+	}
+
+	res, err := p.db.ExecContext(ctx, query)
+	if err != nil {
+		return false, fmt.Errorf("update video generation %q: %w", v.ID, err)
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("update video generation %q: %w", v.ID, err)
+	}
+
+	return rows > 0, nil
+}
@@ -0,0 +1,73 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rakunlabs/at/internal/service"
+)
+
+func TestVideoGenerations_PutGetAndListByStatus(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t, nil)
+
+	now := time.Now().UTC()
+	v := service.VideoGeneration{
+		ID:          "video_1",
+		TokenID:     "tok-1",
+		TokenName:   "ci",
+		ProviderKey: "openai",
+		Model:       "sora-2",
+		UpstreamID:  "video_abc",
+		Prompt:      "a cat on a skateboard",
+		Seconds:     4,
+		Size:        "1280x720",
+		Status:      service.VideoStatusQueued,
+		CreatedAt:   now,
+	}
+	if err := store.PutVideoGeneration(ctx, v); err != nil {
+		t.Fatalf("PutVideoGeneration: %v", err)
+	}
+
+	running, err := store.ListVideoGenerationsByStatus(ctx, service.VideoStatusQueued, service.VideoStatusInProgress)
+	if err != nil || len(running) != 1 {
+		t.Fatalf("ListVideoGenerationsByStatus = %+v, err=%v", running, err)
+	}
+
+	v.Status = service.VideoStatusCompleted
+	v.Progress = 100
+	v.FilePath = "/tmp/video_1.mp4"
+	v.Bytes = 1024
+	v.CostCents = 40
+	v.CompletedAt = &now
+	if updated, err := store.UpdateVideoGeneration(ctx, v); err != nil || !updated {
+		t.Fatalf("UpdateVideoGeneration = %v, err=%v", updated, err)
+	}
+
+	// A finished job is not updated again.
+	late := v
+	late.Status = service.VideoStatusFailed
+	late.CostCents = 0
+	if updated, err := store.UpdateVideoGeneration(ctx, late); err != nil || updated {
+		t.Fatalf("UpdateVideoGeneration of a finished job = %v, err=%v", updated, err)
+	}
+
+	got, err := store.GetVideoGeneration(ctx, "video_1")
+	if err != nil || got == nil {
+		t.Fatalf("GetVideoGeneration = %+v, err=%v", got, err)
+	}
+	if got.Status != service.VideoStatusCompleted || got.CostCents != 40 || got.CompletedAt == nil || got.UpstreamID != "video_abc" {
+		t.Fatalf("unexpected video generation: %+v", got)
+	}
+
+	if list, err := store.ListVideoGenerations(ctx, "tok-1"); err != nil || len(list) != 1 {
+		t.Fatalf("ListVideoGenerations = %+v, err=%v", list, err)
+	}
+	if running, err := store.ListVideoGenerationsByStatus(ctx, service.VideoStatusQueued); err != nil || len(running) != 0 {
+		t.Fatalf("ListVideoGenerationsByStatus after completion = %+v, err=%v", running, err)
+	}
+	if got, err := store.GetVideoGeneration(ctx, "video_missing"); err != nil || got != nil {
+		t.Fatalf("missing video generation = %+v, err=%v", got, err)
+	}
+}